@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+var addrMask bool //-addrmask 发送ICMP地址掩码请求(类型17)代替回显请求
+
+const (
+	icmpAddrMaskRequest = 17
+	icmpAddrMaskReply   = 18
+	addrMaskLen         = 12 //8字节头部 + 32位掩码
+)
+
+// 构造地址掩码请求：与回显请求头部相同，数据部分为置0的32位掩码
+func buildAddrMaskRequest(seq int) ([]byte, error) {
+	pkt := make([]byte, addrMaskLen)
+	icmp := ICMP{
+		Type:   icmpAddrMaskRequest,
+		ID:     echoID,
+		SeqNum: uint16(seq),
+	}
+	icmp.Marshal(pkt)
+
+	sum, err := checkSum(pkt)
+	if err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint16(pkt[2:4], sum)
+	return pkt, nil
+}
+
+// 解析地址掩码应答(类型18)的ICMP部分，返回标识、序号及掩码
+func parseAddrMaskReply(icmp []byte) (id, seq uint16, mask net.IPMask, err error) {
+	if len(icmp) < addrMaskLen {
+		return 0, 0, nil, errors.New("应答过短")
+	}
+	if icmp[0] != icmpAddrMaskReply {
+		return 0, 0, nil, fmt.Errorf("类型 %d 不是地址掩码应答", icmp[0])
+	}
+	mask = net.IPMask(append([]byte(nil), icmp[8:12]...))
+	return binary.BigEndian.Uint16(icmp[4:6]), binary.BigEndian.Uint16(icmp[6:8]), mask, nil
+}
+
+// 掩码的说明，如 255.255.255.0 (/24)，不连续的掩码只输出点分形式
+func maskText(mask net.IPMask) string {
+	s := net.IP(mask).String()
+	if ones, bits := mask.Size(); bits != 0 {
+		s += fmt.Sprintf(" (/%d)", ones)
+	}
+	return s
+}
+
+// RunAddrMask 以ICMP地址掩码请求(RFC 950)代替回显请求，输出目标认为自己所在网络的掩码
+// 多数现代系统不再应答，所有请求都没有应答时报告为不支持
+func (p *Pinger) RunAddrMask() {
+	p.Host = icmpHost(p.Arg)
+	conn, err := dialICMP(p.Host, time.Duration(p.Timeout)*time.Millisecond)
+	if err != nil {
+		p.fail(err)
+		p.printf("%s", dialErrorText(p.Host, err))
+		return
+	}
+	defer conn.Close()
+	p.Addr = conn.RemoteAddr().String()
+
+	p.printf("正在向 %s [%s] 发送地址掩码请求：\n", p.Host, p.Addr)
+
+	bufp := recvBufPool.Get().(*[]byte)
+	defer recvBufPool.Put(bufp)
+	buf := *bufp
+	var lastMask net.IPMask
+
+	for i := 0; forever || i < p.Count; i++ {
+		if !p.nextProbe(i) {
+			break
+		}
+
+		p.Stats.addSent()
+		req, err := buildAddrMaskRequest(i)
+		if err != nil {
+			p.Stats.addSendError(err)
+			p.Stats.addFailure()
+			p.printf("请求失败: %s\n", sendErrorText(err))
+			continue
+		}
+		conn.SetDeadline(time.Now().Add(time.Duration(p.Timeout) * time.Millisecond))
+
+		tStart := time.Now()
+		if _, err := conn.Write(req); err != nil {
+			p.Stats.addSendError(err)
+			p.Stats.addFailure()
+			p.printf("请求失败: %s\n", sendErrorText(err))
+			continue
+		}
+
+		//跳过其他ICMP报文(包括目标为本机时收到的自己的请求)
+		var mask net.IPMask
+		for {
+			n, rerr := conn.Read(buf)
+			if err = rerr; err != nil {
+				break
+			}
+			if checkIPv4Header(buf[:n]) != nil {
+				continue
+			}
+			id, seq, m, perr := parseAddrMaskReply(buf[int(buf[0]&0x0f)*4 : n])
+			if perr == nil && id == echoID && seq == uint16(i) {
+				mask = m
+				break
+			}
+		}
+		tSpend := time.Since(tStart).Milliseconds()
+		if err != nil {
+			p.Stats.addTimeout()
+			p.Stats.addFailure()
+			p.printf("请求超时。\n")
+			continue
+		}
+		p.Stats.addSuccess(tSpend)
+		p.printf("来自 %d.%d.%d.%d 的回复: 序号=%d 时间=%dms 掩码=%s\n", buf[12], buf[13], buf[14], buf[15], i, tSpend, maskText(mask))
+		if lastMask != nil && mask.String() != lastMask.String() {
+			p.printf("注意: 掩码由 %s 变为 %s\n", maskText(lastMask), maskText(mask))
+		}
+		lastMask = mask
+	}
+
+	if ss := p.Stats.Snapshot(); ss.Sent > 0 && ss.Received == 0 {
+		p.printf("%s 不支持地址掩码请求(%d 个请求均没有应答)。\n", p.Host, ss.Sent)
+	}
+	p.printSummary()
+}
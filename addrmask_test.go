@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+)
+
+// 地址掩码请求(RFC 950)：类型17 代码0 检验和 标识 序号，之后为置0的32位掩码
+func TestBuildAddrMaskRequest(t *testing.T) {
+	pkt, err := buildAddrMaskRequest(0x0102)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{17, 0, 0, 0, byte(echoID >> 8), byte(echoID), 0x01, 0x02, 0, 0, 0, 0}
+	sum := binary.BigEndian.Uint16(pkt[2:4])
+	pkt[2], pkt[3] = 0, 0
+	if !bytes.Equal(pkt, want) {
+		t.Errorf("请求 = % x，期望 % x", pkt, want)
+	}
+	if got, _ := checkSum(want); got != sum {
+		t.Errorf("检验和 = %#04x，期望 %#04x", sum, got)
+	}
+}
+
+func TestParseAddrMaskReply(t *testing.T) {
+	reply := []byte{18, 0, 0, 0, 0x12, 0x34, 0, 7, 255, 255, 255, 0}
+	id, seq, mask, err := parseAddrMaskReply(reply)
+	if err != nil || id != 0x1234 || seq != 7 || mask.String() != "ffffff00" {
+		t.Errorf("parseAddrMaskReply = %#04x %d %v %v", id, seq, mask, err)
+	}
+	tests := []struct {
+		name string
+		pkt  []byte
+		err  string
+	}{
+		{"过短", reply[:11], "应答过短"},
+		{"回显应答", append([]byte{0}, reply[1:]...), "类型 0 不是地址掩码应答"},
+		{"地址掩码请求", append([]byte{17}, reply[1:]...), "类型 17 不是地址掩码应答"},
+	}
+	for _, tt := range tests {
+		if _, _, _, err := parseAddrMaskReply(tt.pkt); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: 错误 = %v，期望 %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestMaskText(t *testing.T) {
+	tests := []struct {
+		mask net.IPMask
+		want string
+	}{
+		{net.CIDRMask(24, 32), "255.255.255.0 (/24)"},
+		{net.CIDRMask(0, 32), "0.0.0.0 (/0)"},
+		{net.CIDRMask(32, 32), "255.255.255.255 (/32)"},
+		{net.IPv4Mask(255, 0, 255, 0), "255.0.255.0"}, //不连续的掩码
+	}
+	for _, tt := range tests {
+		if got := maskText(tt.mask); got != tt.want {
+			t.Errorf("maskText(%v) = %q，期望 %q", tt.mask, got, tt.want)
+		}
+	}
+}
+
+// Linux不应答地址掩码请求，所有请求超时后报告为不支持
+func TestRunAddrMaskUnsupported(t *testing.T) {
+	needRawSocket(t)
+	parseArgs(t, "-addrmask", "-n", "2", "-w", "100", "-i", "0", "127.0.0.1")
+	p := newPinger("127.0.0.1")
+	stdout, _ := captureOutput(t, p.RunAddrMask)
+	if ss := p.Stats.Snapshot(); ss.Sent != 2 || ss.Lost != 2 {
+		t.Errorf("统计 = %+v", ss)
+	}
+	if !strings.Contains(stdout, "127.0.0.1 不支持地址掩码请求(2 个请求均没有应答)") {
+		t.Errorf("输出:\n%s", stdout)
+	}
+}
+
+// -mask 是 -addrmask 的别名
+func TestMaskAlias(t *testing.T) {
+	parseArgs(t, "-mask", "127.0.0.1")
+	if !addrMask {
+		t.Error("-mask 没有启用地址掩码请求")
+	}
+	parseArgs(t, "127.0.0.1")
+	if addrMask {
+		t.Error("默认启用了地址掩码请求")
+	}
+	stdout, _ := captureOutput(t, usage)
+	if !strings.Contains(stdout, "-addrmask, -mask") {
+		t.Errorf("用法中没有 -mask:\n%s", stdout)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import "time"
+
+// availability 根据每次探测的结果维护目标的在线/离线状态，累计离线时长
+// 所有时间由调用方传入，便于以脚本化的时间序列验证
+type availability struct {
+	start    time.Time     //第一次探测的时间
+	known    bool          //是否已有探测结果
+	down     bool          //当前是否离线
+	since    time.Time     //进入当前状态的时间
+	downtime time.Duration //已结束的离线时长合计
+	outages  int           //离线次数
+	longest  time.Duration //已结束的最长一次离线
+	last     time.Duration //最近一次已结束的离线时长
+	pausedAt time.Time     //暂停的时间，未暂停时为零值
+	paused   time.Duration //已结束的暂停时长合计
+	periods  []outagePeriod
+}
+
+// 一次离线
+type outagePeriod struct {
+	Start   time.Time
+	End     time.Time
+	Ongoing bool //截至统计时仍未恢复
+}
+
+// AvailSnapshot 某一时刻的可用性统计
+type AvailSnapshot struct {
+	Runtime  time.Duration //运行时长
+	Downtime time.Duration //离线时长合计，包括尚未恢复的离线
+	Outages  int           //离线次数
+	Longest  time.Duration //最长一次离线
+	Last     time.Duration //最近一次已结束的离线时长
+	Known    bool          //是否已有探测结果
+	Down     bool          //当前是否离线
+	Since    time.Time     //进入当前状态的时间，第一次探测即失败时为开始探测的时间
+}
+
+// 记录开始探测的时间，只有第一次调用有效
+func (a *availability) begin(at time.Time) {
+	if a.start.IsZero() {
+		a.start = at
+	}
+}
+
+// 记录一次探测结果
+// 第一次探测即失败时，离线从开始探测时算起
+func (a *availability) record(ok bool, at time.Time) {
+	a.begin(at)
+	switch {
+	case !a.known:
+		a.known, a.down, a.since = true, !ok, a.start
+		if !ok {
+			a.outages++
+			a.periods = append(a.periods, outagePeriod{Start: a.start})
+		}
+	case a.down && ok: //恢复
+		d := at.Sub(a.since)
+		a.downtime += d
+		if d > a.longest {
+			a.longest = d
+		}
+		a.last = d
+		a.down, a.since = false, at
+		a.periods[len(a.periods)-1].End = at
+	case !a.down && !ok: //离线
+		a.outages++
+		a.down, a.since = true, at
+		a.periods = append(a.periods, outagePeriod{Start: at})
+	}
+}
+
+// 截至now的各次离线，未恢复的离线以now为结束时间
+func (a *availability) outagePeriods(now time.Time) []outagePeriod {
+	if !a.pausedAt.IsZero() {
+		now = a.pausedAt
+	}
+	periods := append([]outagePeriod(nil), a.periods...)
+	if n := len(periods); n > 0 && periods[n-1].End.IsZero() {
+		periods[n-1].End, periods[n-1].Ongoing = now, true
+	}
+	return periods
+}
+
+// 暂停探测，开始探测之前的暂停不需要记录
+func (a *availability) pause(at time.Time) {
+	if !a.start.IsZero() && a.pausedAt.IsZero() {
+		a.pausedAt = at
+	}
+}
+
+// 恢复探测，暂停的时长从运行时长中扣除，离线期间暂停的部分不计入离线时长
+func (a *availability) resume(at time.Time) {
+	if a.pausedAt.IsZero() {
+		return
+	}
+	d := at.Sub(a.pausedAt)
+	a.paused += d
+	if a.down {
+		a.since = a.since.Add(d)
+	}
+	a.pausedAt = time.Time{}
+}
+
+// 截至now的统计，仍处于离线状态时把当前这次离线计入
+// 暂停中时统计截至暂停的时间
+func (a *availability) snapshot(now time.Time) AvailSnapshot {
+	s := AvailSnapshot{Downtime: a.downtime, Outages: a.outages, Longest: a.longest, Last: a.last, Known: a.known, Down: a.down, Since: a.since}
+	if a.start.IsZero() {
+		return s
+	}
+	if !a.pausedAt.IsZero() {
+		now = a.pausedAt
+	}
+	s.Runtime = now.Sub(a.start) - a.paused
+	if a.down {
+		d := now.Sub(a.since)
+		s.Downtime += d
+		if d > s.Longest {
+			s.Longest = d
+		}
+	}
+	return s
+}
+
+// 可用率(百分比)：在线时长 / 运行时长
+func (s AvailSnapshot) Percent() float64 {
+	if s.Runtime <= 0 {
+		if s.Outages > 0 {
+			return 0
+		}
+		return 100
+	}
+	return float64(s.Runtime-s.Downtime) / float64(s.Runtime) * 100
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 脚本中的一步：b 开始探测，u 探测成功，d 探测失败，p 暂停，r 恢复；at 为相对t0的秒数
+type availStep struct {
+	op byte
+	at int
+}
+
+// 按脚本化的状态变化驱动累加器，时间全部由测试给出
+func TestAvailability(t *testing.T) {
+	t0 := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	sec := func(n int) time.Time { return t0.Add(time.Duration(n) * time.Second) }
+	tests := []struct {
+		name     string
+		steps    []availStep
+		now      int
+		runtime  int
+		downtime int
+		outages  int
+		longest  int
+		down     bool
+		percent  float64
+	}{
+		{"没有探测", nil, 10, 0, 0, 0, 0, false, 100},
+		{"一直在线", []availStep{{'u', 0}, {'u', 1}, {'u', 2}}, 10, 10, 0, 0, 0, false, 100},
+		{"第一次探测即离线后恢复", []availStep{{'d', 0}, {'d', 1}, {'u', 4}, {'u', 5}}, 10, 10, 4, 1, 4, false, 60},
+		{"离线从开始探测时算起", []availStep{{'b', 0}, {'d', 1}, {'u', 3}}, 5, 5, 3, 1, 3, false, 40},
+		{"结束时仍离线", []availStep{{'u', 0}, {'d', 2}, {'d', 3}}, 10, 10, 8, 1, 8, true, 20},
+		{"从头到尾离线", []availStep{{'d', 0}, {'d', 1}}, 2, 2, 2, 1, 2, true, 0},
+		{"两次离线", []availStep{{'u', 0}, {'d', 2}, {'u', 5}, {'d', 7}, {'u', 8}}, 10, 10, 4, 2, 3, false, 60},
+		{"未结束的离线最长", []availStep{{'u', 0}, {'d', 1}, {'u', 2}, {'d', 5}}, 10, 10, 6, 2, 5, true, 40},
+		{"离线期间暂停", []availStep{{'u', 0}, {'d', 2}, {'p', 4}, {'r', 9}, {'u', 10}}, 12, 7, 3, 1, 3, false, 400.0 / 7},
+		{"暂停中结束", []availStep{{'u', 0}, {'d', 2}, {'p', 4}}, 10, 4, 2, 1, 2, true, 50},
+		{"开始探测前的暂停不计", []availStep{{'p', 0}, {'r', 5}, {'u', 6}}, 10, 4, 0, 0, 0, false, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a availability
+			for _, s := range tt.steps {
+				switch s.op {
+				case 'b':
+					a.begin(sec(s.at))
+				case 'u', 'd':
+					a.record(s.op == 'u', sec(s.at))
+				case 'p':
+					a.pause(sec(s.at))
+				case 'r':
+					a.resume(sec(s.at))
+				}
+			}
+			got := a.snapshot(sec(tt.now))
+			if got.Runtime != time.Duration(tt.runtime)*time.Second || got.Downtime != time.Duration(tt.downtime)*time.Second ||
+				got.Outages != tt.outages || got.Longest != time.Duration(tt.longest)*time.Second || got.Down != tt.down {
+				t.Errorf("snapshot = %+v，期望 运行 %ds，离线 %ds，%d 次，最长 %ds，离线中 %v", got, tt.runtime, tt.downtime, tt.outages, tt.longest, tt.down)
+			}
+			if p := got.Percent(); p < tt.percent-1e-9 || p > tt.percent+1e-9 {
+				t.Errorf("Percent() = %v，期望 %v", p, tt.percent)
+			}
+		})
+	}
+}
+
+// 离线、恢复时写入outage_start/outage_end事件，时间为状态变化的时间；-t 结束时写入availability事件
+func TestOutageEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	if err := startRecord(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stopRecord)
+
+	t0 := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	p := &Pinger{Host: "192.0.2.1", Addr: "192.0.2.1", Quiet: true, Stats: newStatistics()}
+	for i, ok := range []bool{false, false, true, true, false, true, false} {
+		p.Stats.addRecord(t0.Add(time.Duration(i)*time.Second), 1, ok)
+		p.noteOutage()
+	}
+	ss := p.Stats.Snapshot()
+	recordAvailability(p.Host, p.Labels, ss.Avail, true)
+	stopRecord()
+
+	got := readEvents(t, path)
+	want := []struct {
+		event    string
+		at       int
+		duration int64
+		outages  int
+	}{
+		{"outage_start", 0, 0, 1}, //第一次探测即离线
+		{"outage_end", 2, 2000, 1},
+		{"outage_start", 4, 0, 2},
+		{"outage_end", 5, 1000, 2},
+		{"outage_start", 6, 0, 3}, //结束时仍离线
+		{"availability", -1, 0, 3},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("写入了 %d 个事件，期望 %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		e := got[i]
+		if e.Event != w.event || e.Duration != w.duration || e.Outages != w.outages || e.Target != "192.0.2.1" {
+			t.Errorf("事件 %d = %+v，期望 %s duration_ms=%d outages=%d", i, e, w.event, w.duration, w.outages)
+		}
+		if w.at >= 0 && !e.Time.Equal(t0.Add(time.Duration(w.at)*time.Second)) {
+			t.Errorf("事件 %d 的时间 = %v，期望 t0+%ds", i, e.Time, w.at)
+		}
+	}
+	last := got[len(got)-1]
+	if !last.Final || !last.Down || last.Runtime != 6000 || last.Downtime != 3000 || last.Longest != 2000 ||
+		last.Availability == nil || *last.Availability != 50 {
+		t.Errorf("availability 事件 = %+v", last)
+	}
+}
+
+// 读取JSONL记录文件中的事件，跳过start及探测记录
+func readEvents(t *testing.T, path string) []eventRecord {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var events []eventRecord
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e eventRecord
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Event != "" && e.Event != "start" {
+			events = append(events, e)
+		}
+	}
+	return events
+}
@@ -0,0 +1,72 @@
+package main
+
+import "time"
+
+var (
+	useBackoff bool    //连续失败时逐步延长请求间隔
+	backoffMax float64 //退避后的最大间隔(秒)
+)
+
+const (
+	backoffAfter  = 3           //连续失败多少次后开始退避
+	backoffFactor = 2           //每次退避间隔乘以的倍数
+	backoffBase   = time.Second //未指定间隔时，退避从该间隔开始
+)
+
+// 根据上一次请求的结果计算退避后的间隔，间隔变化时输出一次提示
+func (p *Pinger) backoffInterval(base time.Duration) time.Duration {
+	if p.backoff == nil {
+		p.backoff = newBackoff(base, time.Duration(backoffMax*float64(time.Second)))
+	}
+	d, changed := p.backoff.next(p.Stats.Snapshot().Last >= 0)
+	if changed {
+		if p.backoff.fails > 0 {
+			p.backedOff = true
+			p.printf("连续 %d 次请求失败，请求间隔调整为 %s。\n", p.backoff.fails, d)
+		} else {
+			p.printf("请求成功，请求间隔恢复为 %s。\n", d)
+		}
+	}
+	return d
+}
+
+// backoff 连续失败时按倍数延长请求间隔，第一次成功后恢复为配置的间隔
+type backoff struct {
+	base  time.Duration //配置的间隔
+	max   time.Duration //间隔上限
+	fails int           //连续失败次数
+	cur   time.Duration //当前间隔
+}
+
+func newBackoff(base, max time.Duration) *backoff {
+	if max < base {
+		max = base
+	}
+	return &backoff{base: base, max: max, cur: base}
+}
+
+// 记录一次请求结果，返回下一次请求前应等待的间隔，以及间隔是否发生变化
+func (b *backoff) next(ok bool) (time.Duration, bool) {
+	prev := b.cur
+	if ok {
+		b.fails, b.cur = 0, b.base
+		return b.cur, b.cur != prev
+	}
+
+	b.fails++
+	if b.fails >= backoffAfter {
+		start := b.base
+		if start <= 0 {
+			start = backoffBase
+		}
+		if b.cur < start {
+			b.cur = start
+		} else {
+			b.cur *= backoffFactor
+		}
+		if b.cur > b.max {
+			b.cur = b.max
+		}
+	}
+	return b.cur, b.cur != prev
+}
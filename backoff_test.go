@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// 按请求结果序列检查每次返回的间隔及是否变化
+func TestBackoff(t *testing.T) {
+	type step struct {
+		ok      bool
+		want    time.Duration
+		changed bool
+	}
+	tests := []struct {
+		name      string
+		base, max time.Duration
+		steps     []step
+	}{
+		{"连续3次失败后开始加倍", time.Second, time.Minute, []step{
+			{false, time.Second, false},
+			{false, time.Second, false},
+			{false, 2 * time.Second, true},
+			{false, 4 * time.Second, true},
+			{false, 8 * time.Second, true},
+		}},
+		{"第一次成功后恢复", time.Second, time.Minute, []step{
+			{false, time.Second, false},
+			{false, time.Second, false},
+			{false, 2 * time.Second, true},
+			{true, time.Second, true},
+			{true, time.Second, false},
+			{false, time.Second, false}, //失败次数重新计算
+		}},
+		{"不超过上限", time.Second, 5 * time.Second, []step{
+			{false, time.Second, false},
+			{false, time.Second, false},
+			{false, 2 * time.Second, true},
+			{false, 4 * time.Second, true},
+			{false, 5 * time.Second, true},
+			{false, 5 * time.Second, false},
+		}},
+		{"未指定间隔时从1秒开始", 0, 10 * time.Second, []step{
+			{false, 0, false},
+			{false, 0, false},
+			{false, backoffBase, true},
+			{false, 2 * backoffBase, true},
+			{true, 0, true},
+		}},
+		{"上限小于间隔时不延长", 2 * time.Second, time.Second, []step{
+			{false, 2 * time.Second, false},
+			{false, 2 * time.Second, false},
+			{false, 2 * time.Second, false},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackoff(tt.base, tt.max)
+			for i, st := range tt.steps {
+				d, changed := b.next(st.ok)
+				if d != st.want || changed != st.changed {
+					t.Errorf("第 %d 次(ok=%v) = %s, %v，期望 %s, %v", i+1, st.ok, d, changed, st.want, st.changed)
+				}
+			}
+		})
+	}
+}
+
+// 间隔变化时只提示一次，并标记统计信息中需要说明发送频率不均匀
+func TestBackoffInterval(t *testing.T) {
+	oldMax := backoffMax
+	backoffMax = 60
+	t.Cleanup(func() { backoffMax = oldMax })
+
+	p := &Pinger{Quiet: true, Stats: newStatistics()}
+	var got []time.Duration
+	for i := 0; i < 4; i++ {
+		p.Stats.addSent()
+		p.Stats.addFailure()
+		got = append(got, p.backoffInterval(time.Second))
+	}
+	want := []time.Duration{time.Second, time.Second, 2 * time.Second, 4 * time.Second}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("间隔 = %v，期望 %v", got, want)
+		}
+	}
+	if !p.backedOff {
+		t.Error("退避后backedOff应为true")
+	}
+	p.Stats.addSent()
+	p.Stats.addSuccess(1)
+	if d := p.backoffInterval(time.Second); d != time.Second {
+		t.Errorf("成功后间隔 = %s，期望 1s", d)
+	}
+}
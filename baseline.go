@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+var (
+	saveBaselinePath    string  //保存本次结果的基线文件
+	compareBaselinePath string  //与之比较的基线文件
+	allowAvgIncrease    int64   //允许的平均耗时增加(毫秒)
+	allowLossIncrease   float64 //允许的丢失率增加(百分点)
+	baselineTolerance   float64 //耗时指标允许的相对增幅(百分比)
+)
+
+// 与基线相比变差时的退出码
+const exitBaselineRegressed = 6
+
+// 基线文件
+type baselineFile struct {
+	SchemaVersion string          `json:"schema_version,omitempty" desc:"输出格式的版本，旧版本保存的基线文件没有该字段"`
+	Saved         time.Time       `json:"saved" desc:"保存的时间"`
+	Targets       []baselineEntry `json:"targets" desc:"各目标的统计"`
+}
+
+// 单个目标的基线，也用于 -schedule 的统计摘要
+type baselineEntry struct {
+	Target   string  `json:"target" desc:"目标"`
+	Size     int     `json:"size" desc:"数据长度(字节)"`
+	Sent     int     `json:"sent" desc:"已发送的请求数"`
+	Received int     `json:"received" desc:"已接收的回复数"`
+	Loss     float64 `json:"loss_percent" desc:"丢失率(百分比)"`
+	Min      int64   `json:"min_ms" desc:"最短往返时间(毫秒)"`
+	Avg      int64   `json:"avg_ms" desc:"平均往返时间(毫秒)"`
+	P95      int64   `json:"p95_ms" desc:"往返时间的P95(毫秒)"`
+	Max      int64   `json:"max_ms" desc:"最长往返时间(毫秒)"`
+	StdDev   float64 `json:"stddev_ms" desc:"往返时间的标准差(毫秒)"`
+}
+
+// 当前结果与基线的差异
+type baselineDiff struct {
+	Loss    float64  //丢失率变化(百分点)
+	Min     int64    //最短耗时变化
+	Avg     int64    //平均耗时变化
+	P95     int64    //P95耗时变化
+	Max     int64    //最长耗时变化
+	StdDev  float64  //标准差变化
+	Latency bool     //双方都有回复，耗时可以比较
+	Pass    bool     //是否在允许范围内
+	Reasons []string //未通过的原因
+}
+
+// 由目标的统计数据生成基线，没有回复时耗时均记为0
+func baselineEntryOf(p *Pinger) baselineEntry {
+	ss := p.Stats.Snapshot()
+	e := baselineEntry{Target: p.Arg, Size: p.Size, Sent: ss.Sent, Received: ss.Received, Loss: ss.LossPercent()}
+	if ss.Received > 0 {
+		e.Min, e.Avg, e.P95, e.Max = ss.Min, ss.Avg(), p.Stats.percentile(95), ss.Max
+		e.StdDev = rttStdDev(sortedRTTs(p.Stats.sampleList()))
+	}
+	if baselineTrimmed {
+		if t, ok := trimRTT(p.Stats.sampleList()); ok {
+			e.Min, e.Avg, e.P95, e.Max = t.min, t.avg, t.p95, t.max
+		}
+	}
+	return e
+}
+
+// 耗时的标准差(总体标准差)，不足两个样本时为0
+func rttStdDev(rtts []int64) float64 {
+	if len(rtts) < 2 {
+		return 0
+	}
+	var sum float64
+	for _, v := range rtts {
+		sum += float64(v)
+	}
+	mean := sum / float64(len(rtts))
+	var sq float64
+	for _, v := range rtts {
+		sq += (float64(v) - mean) * (float64(v) - mean)
+	}
+	return math.Sqrt(sq / float64(len(rtts)))
+}
+
+// 相对基线的变化百分比，基线为0时无法计算
+func changePct(base, cur float64) (float64, bool) {
+	if base <= 0 {
+		return 0, false
+	}
+	return (cur - base) / base * 100, true
+}
+
+// 耗时指标相对基线的增幅是否超过tol%
+// 基线为0或增加不超过1ms(耗时以毫秒取整的误差)时不判定为变差
+func exceedsTolerance(base, cur, tol float64) bool {
+	pct, ok := changePct(base, cur)
+	return ok && cur-base > 1 && pct > tol
+}
+
+// 比较当前结果与基线：丢失率增加超过maxLoss个百分点、平均耗时增加超过maxAvg毫秒，
+// 或平均、P95、最长耗时及标准差的增幅超过tol%时不通过
+// 基线有回复而当前没有回复时同样不通过
+func compareBaseline(base, cur baselineEntry, maxAvg int64, maxLoss, tol float64) baselineDiff {
+	d := baselineDiff{Loss: cur.Loss - base.Loss, Pass: true}
+	if d.Loss > maxLoss {
+		d.Pass = false
+		d.Reasons = append(d.Reasons, fmt.Sprintf("丢失率增加 %.2f%%，超过允许的 %.2f%%", d.Loss, maxLoss))
+	}
+
+	switch {
+	case base.Received > 0 && cur.Received > 0:
+		d.Latency = true
+		d.Min, d.Avg, d.P95, d.Max = cur.Min-base.Min, cur.Avg-base.Avg, cur.P95-base.P95, cur.Max-base.Max
+		d.StdDev = cur.StdDev - base.StdDev
+		if d.Avg > maxAvg {
+			d.Pass = false
+			d.Reasons = append(d.Reasons, fmt.Sprintf("平均耗时增加 %dms，超过允许的 %dms", d.Avg, maxAvg))
+		}
+		metrics := []struct {
+			name      string
+			base, cur float64
+		}{
+			{"平均耗时", float64(base.Avg), float64(cur.Avg)},
+			{"P95耗时", float64(base.P95), float64(cur.P95)},
+			{"最长耗时", float64(base.Max), float64(cur.Max)},
+			{"耗时标准差", base.StdDev, cur.StdDev},
+		}
+		for _, m := range metrics {
+			if exceedsTolerance(m.base, m.cur, tol) {
+				pct, _ := changePct(m.base, m.cur)
+				d.Pass = false
+				d.Reasons = append(d.Reasons, fmt.Sprintf("%s增加 %.1f%%，超过允许的 %g%%", m.name, pct, tol))
+			}
+		}
+	case base.Received > 0:
+		d.Pass = false
+		d.Reasons = append(d.Reasons, "基线中可达，本次没有收到回复")
+	}
+	return d
+}
+
+// 把本次结果写入基线文件
+func saveBaseline(path string, pingers []*Pinger) error {
+	f := baselineFile{SchemaVersion: schemaVersion, Saved: time.Now()}
+	for _, p := range pingers {
+		f.Targets = append(f.Targets, baselineEntryOf(p))
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// 读取基线文件
+func loadBaseline(path string) (*baselineFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f baselineFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &f, nil
+}
+
+// 保存基线和/或与基线比较，返回退出码：全部通过为0，无法读写基线文件为1，
+// 与基线相比变差为exitBaselineRegressed
+// 目标或数据长度与基线不一致时输出警告到标准错误
+func checkBaseline(pingers []*Pinger) int {
+	code := 0
+	if compareBaselinePath != "" {
+		base, err := loadBaseline(compareBaselinePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "读取基线失败: %v\n", err)
+			return 1
+		}
+		if !reportBaseline(base, pingers) {
+			code = exitBaselineRegressed
+		}
+	}
+	if saveBaselinePath != "" {
+		if err := saveBaseline(saveBaselinePath, pingers); err != nil {
+			fmt.Fprintf(os.Stderr, "保存基线失败: %v\n", err)
+			return 1
+		}
+	}
+	return code
+}
+
+// 带符号的变化百分比，如 " (+15.0%)"，基线为0时为空
+func pctSuffix(base, cur float64) string {
+	if pct, ok := changePct(base, cur); ok {
+		return fmt.Sprintf(" (%+.1f%%)", pct)
+	}
+	return ""
+}
+
+// 输出与基线的比较结果
+func reportBaseline(base *baselineFile, pingers []*Pinger) bool {
+	entries := map[string]baselineEntry{}
+	for _, e := range base.Targets {
+		entries[e.Target] = e
+	}
+
+	fmt.Printf("\n与基线比较 (%s 保存):\n", base.Saved.Local().Format("2006-01-02 15:04:05"))
+	pass := true
+	seen := map[string]bool{}
+	for _, p := range pingers {
+		cur := baselineEntryOf(p)
+		seen[cur.Target] = true
+		b, ok := entries[cur.Target]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "警告: 基线中没有目标 %s，跳过比较\n", cur.Target)
+			continue
+		}
+		if b.Size != cur.Size {
+			fmt.Fprintf(os.Stderr, "警告: 目标 %s 的数据长度与基线不同(基线 %d 字节，本次 %d 字节)\n", cur.Target, b.Size, cur.Size)
+		}
+
+		d := compareBaseline(b, cur, allowAvgIncrease, allowLossIncrease, baselineTolerance)
+		fmt.Printf("  %s:\n    丢失率 %.2f%% -> %.2f%% (%+.2f%%)\n", cur.Target, b.Loss, cur.Loss, d.Loss)
+		if d.Latency {
+			fmt.Printf("    最短 %dms -> %dms (%+dms)，平均 %dms -> %dms (%+dms)，P95 %dms -> %dms (%+dms)\n",
+				b.Min, cur.Min, d.Min, b.Avg, cur.Avg, d.Avg, b.P95, cur.P95, d.P95)
+			fmt.Printf("    相对基线: Δ平均 = %+dms%s，Δ最长 = %+dms%s，Δ标准差 = %+.1fms%s\n",
+				d.Avg, pctSuffix(float64(b.Avg), float64(cur.Avg)), d.Max, pctSuffix(float64(b.Max), float64(cur.Max)),
+				d.StdDev, pctSuffix(b.StdDev, cur.StdDev))
+		}
+		if d.Pass {
+			fmt.Printf("    结果: 通过\n")
+		} else {
+			pass = false
+			for _, r := range d.Reasons {
+				fmt.Printf("    结果: 未通过，%s\n", r)
+			}
+		}
+	}
+	for _, e := range base.Targets {
+		if !seen[e.Target] {
+			fmt.Fprintf(os.Stderr, "警告: 基线中的目标 %s 本次未测量\n", e.Target)
+		}
+	}
+	return pass
+}
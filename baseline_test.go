@@ -0,0 +1,156 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompareBaseline(t *testing.T) {
+	reach := func(loss float64, min, avg, p95, max int64) baselineEntry {
+		return baselineEntry{Target: "example.com", Sent: 100, Received: 100 - int(loss), Loss: loss, Min: min, Avg: avg, P95: p95, Max: max}
+	}
+	down := baselineEntry{Target: "example.com", Sent: 100, Loss: 100}
+	tests := []struct {
+		name      string
+		base, cur baselineEntry
+		maxAvg    int64
+		maxLoss   float64
+		tol       float64
+		pass      bool
+		latency   bool
+		avg       int64
+		reason    string //未通过时原因中应包含的内容
+	}{
+		{"相同", reach(0, 10, 20, 30, 40), reach(0, 10, 20, 30, 40), 10, 1, 20, true, true, 0, ""},
+		{"丢失率增加在允许范围内", reach(0, 10, 20, 30, 40), reach(1, 10, 20, 30, 40), 10, 1, 20, true, true, 0, ""},
+		{"丢失率增加超过允许范围", reach(0, 10, 20, 30, 40), reach(3, 10, 20, 30, 40), 10, 1, 20, false, true, 0, "丢失率增加 3.00%"},
+		{"丢失率减少", reach(5, 10, 20, 30, 40), reach(0, 10, 20, 30, 40), 10, 1, 20, true, true, 0, ""},
+		{"平均耗时增加等于允许值", reach(0, 10, 20, 30, 40), reach(0, 10, 30, 30, 40), 10, 1, 1000, true, true, 10, ""},
+		{"平均耗时增加超过允许值", reach(0, 10, 20, 30, 40), reach(0, 10, 31, 30, 40), 10, 1, 1000, false, true, 11, "平均耗时增加 11ms"},
+		{"平均耗时减少", reach(0, 10, 20, 30, 40), reach(0, 5, 12, 20, 30), 10, 1, 20, true, true, -8, ""},
+		{"P95增幅超过容差", reach(0, 10, 20, 30, 40), reach(0, 10, 20, 45, 40), 10, 1, 20, false, true, 0, "P95耗时增加 50.0%"},
+		{"最长耗时增幅超过容差", reach(0, 10, 20, 30, 40), reach(0, 10, 20, 30, 80), 10, 1, 20, false, true, 0, "最长耗时增加 100.0%"},
+		{"增加1ms不计入容差", reach(0, 1, 2, 2, 2), reach(0, 1, 3, 3, 3), 10, 1, 20, true, true, 1, ""},
+		{"基线可达本次不可达", reach(0, 10, 20, 30, 40), down, 10, 1, 20, false, false, 0, "基线中可达"},
+		{"双方都不可达", down, down, 10, 1, 20, true, false, 0, ""},
+		{"基线不可达本次可达", down, reach(0, 10, 20, 30, 40), 10, 1, 20, true, false, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := compareBaseline(tt.base, tt.cur, tt.maxAvg, tt.maxLoss, tt.tol)
+			if d.Pass != tt.pass || d.Latency != tt.latency || d.Avg != tt.avg {
+				t.Errorf("compareBaseline = %+v，期望 Pass=%v Latency=%v Avg=%d", d, tt.pass, tt.latency, tt.avg)
+			}
+			if tt.pass && len(d.Reasons) > 0 {
+				t.Errorf("通过时不应有原因: %q", d.Reasons)
+			}
+			if tt.reason != "" && !strings.Contains(strings.Join(d.Reasons, "\n"), tt.reason) {
+				t.Errorf("原因 %q 中没有 %q", d.Reasons, tt.reason)
+			}
+		})
+	}
+}
+
+// 以给定的往返时间(毫秒)生成目标的统计数据，负数表示超时
+func baselinePinger(arg string, rtts ...int64) *Pinger {
+	p := &Pinger{Arg: arg, Stats: newStatistics()}
+	for _, rtt := range rtts {
+		p.Stats.addSent()
+		if rtt < 0 {
+			p.Stats.addFailure()
+			continue
+		}
+		p.Stats.addSuccess(rtt)
+	}
+	return p
+}
+
+// 保存的基线与同样的结果比较时通过；目标或数据长度不一致时警告，变差时退出码为6
+func TestCheckBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	pingers := []*Pinger{baselinePinger("example.com", 12, 15, -1, 20)}
+
+	parseArgs(t, "-save-baseline", path, "example.com")
+	if code := checkBaseline(pingers); code != 0 {
+		t.Fatalf("保存基线的退出码 = %d", code)
+	}
+	f, err := loadBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Targets) != 1 || f.Targets[0] != baselineEntryOf(pingers[0]) {
+		t.Fatalf("读回的基线 = %+v", f)
+	}
+
+	parseArgs(t, "-compare-baseline", path, "example.com")
+	var code int
+	out, errOut := captureOutput(t, func() { code = checkBaseline(pingers) })
+	if code != 0 || !strings.Contains(out, "结果: 通过") || errOut != "" {
+		t.Errorf("与自身比较: 退出码 %d\n%s%s", code, out, errOut)
+	}
+
+	//数据长度不同、多出一个目标时警告，基线中缺少的目标跳过比较
+	other := &Pinger{Arg: "other.example", Size: 64, Stats: newStatistics()}
+	pingers[0].Size = 64
+	_, errOut = captureOutput(t, func() { code = checkBaseline(append(pingers, other)) })
+	for _, want := range []string{"example.com 的数据长度与基线不同(基线 0 字节，本次 64 字节)", "基线中没有目标 other.example"} {
+		if !strings.Contains(errOut, want) {
+			t.Errorf("标准错误中没有 %q:\n%s", want, errOut)
+		}
+	}
+	_, errOut = captureOutput(t, func() { checkBaseline(nil) })
+	if !strings.Contains(errOut, "基线中的目标 example.com 本次未测量") {
+		t.Errorf("标准错误中没有未测量的警告:\n%s", errOut)
+	}
+
+	//平均耗时比基线增加超过允许值
+	parseArgs(t, "-compare-baseline", path, "-allow-avg-increase-ms", "0", "-baseline-tolerance", "1000", "example.com")
+	worse := []*Pinger{baselinePinger("example.com", 12, 15, -1, 20, 500)}
+	out, _ = captureOutput(t, func() { code = checkBaseline(worse) })
+	if code != exitBaselineRegressed || !strings.Contains(out, "未通过") {
+		t.Errorf("变差时退出码 = %d:\n%s", code, out)
+	}
+
+	//-baseline-load 同 -compare-baseline，最长耗时的增幅超过默认容差20%
+	parseArgs(t, "-baseline-load", path, "example.com")
+	slower := []*Pinger{baselinePinger("example.com", 12, 15, -1, 26)}
+	out, _ = captureOutput(t, func() { code = checkBaseline(slower) })
+	for _, want := range []string{"相对基线: Δ平均 = +2ms (+13.3%)，Δ最长 = +6ms (+30.0%)", "最长耗时增加 30.0%，超过允许的 20%"} {
+		if code != exitBaselineRegressed || !strings.Contains(out, want) {
+			t.Errorf("退出码 %d，输出中没有 %q:\n%s", code, want, out)
+		}
+	}
+
+	parseArgs(t, "-compare-baseline", filepath.Join(t.TempDir(), "missing.json"), "example.com")
+	captureOutput(t, func() { code = checkBaseline(pingers) })
+	if code != 1 {
+		t.Errorf("基线文件不存在时退出码 = %d，期望 1", code)
+	}
+}
+
+func TestRTTStdDev(t *testing.T) {
+	tests := []struct {
+		rtts []int64
+		want float64
+	}{
+		{nil, 0},
+		{[]int64{10}, 0},
+		{[]int64{10, 10, 10}, 0},
+		{[]int64{2, 4, 4, 4, 5, 5, 7, 9}, 2},
+	}
+	for _, tt := range tests {
+		if got := rttStdDev(tt.rtts); got != tt.want {
+			t.Errorf("rttStdDev(%v) = %v，期望 %v", tt.rtts, got, tt.want)
+		}
+	}
+}
+
+func TestBaselineToleranceFlag(t *testing.T) {
+	if errs := argErrors(t, "-baseline-tolerance", "-5", "x"); !hasArgError(errs, "-baseline-tolerance: 应为不小于0的百分比，实际为 -5") {
+		t.Errorf("错误 = %q", errs)
+	}
+	if errs := argErrors(t, "-baseline-save", "b.json", "-baseline-tolerance", "0", "x"); len(errs) != 0 {
+		t.Errorf("合法的参数报错: %q", errs)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"time"
+)
+
+var bfdEcho bool //BFD回显模式
+
+// BFD回显报文，沿用控制报文格式(RFC 5880 §4.1)，见 RFC 5880 §6.4 及 RFC 5881 §4
+const (
+	bfdEchoPort   = 3785 //BFD回显端口
+	bfdPacketLen  = 24   //报文长度
+	bfdVersion    = 1    //协议版本
+	bfdStateUp    = 3    //会话状态 Up
+	bfdDetectMult = 3    //检测倍数
+)
+
+// 构造BFD回显报文
+// 回显报文的内容由发送方自行解释：My Discriminator 标识本次会话，Your Discriminator 存放序号
+func buildBFDEcho(b []byte, myDisc, seq uint32, txInterval time.Duration) {
+	b[0] = bfdVersion << 5 //Vers(3) Diag(5)
+	b[1] = bfdStateUp << 6 //Sta(2) 标志位(6)
+	b[2] = bfdDetectMult
+	b[3] = bfdPacketLen
+	binary.BigEndian.PutUint32(b[4:8], myDisc)
+	binary.BigEndian.PutUint32(b[8:12], seq)
+	binary.BigEndian.PutUint32(b[12:16], uint32(txInterval/time.Microsecond)) //Desired Min TX Interval
+	binary.BigEndian.PutUint32(b[16:20], 0)                                   //Required Min RX Interval
+	binary.BigEndian.PutUint32(b[20:24], 0)                                   //Required Min Echo RX Interval
+}
+
+// 判断是否为本会话序号为seq的回显报文
+func isBFDEcho(b []byte, myDisc, seq uint32) bool {
+	return len(b) >= bfdPacketLen &&
+		b[0]>>5 == bfdVersion &&
+		binary.BigEndian.Uint32(b[4:8]) == myDisc &&
+		binary.BigEndian.Uint32(b[8:12]) == seq
+}
+
+// RunBFD 向对端发送BFD回显报文，等待对端环回，用于验证对端路由器的BFD回显环回是否正常
+// 不建立BFD会话；报文从本地3785端口发出，对端按回显机制转发回本机的3785端口
+func (p *Pinger) RunBFD() {
+	p.Host = icmpHost(p.Arg)
+	raddr, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(p.Host, strconv.Itoa(bfdEchoPort)))
+	if err != nil {
+		p.fail(err)
+		p.printf("Ping 请求找不到主机 %s。请检查该名称，然后重试。", p.Host)
+		return
+	}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: bfdEchoPort})
+	if err != nil {
+		p.fail(err)
+		p.printf("无法监听BFD回显端口 %d: %v\n", bfdEchoPort, err)
+		return
+	}
+	defer conn.Close()
+	p.Addr = raddr.IP.String()
+
+	var d [4]byte
+	rand.Read(d[:])
+	myDisc := binary.BigEndian.Uint32(d[:])
+
+	p.printf("正在向 %s 发送 BFD 回显报文 (本端标识 %d) 具有 %d 字节的数据：\n", raddr, myDisc, bfdPacketLen)
+
+	pkt := make([]byte, bfdPacketLen)
+	bufp := recvBufPool.Get().(*[]byte)
+	defer recvBufPool.Put(bufp)
+	buf := *bufp
+
+	for i := 0; forever || i < p.Count; i++ {
+		if !p.nextProbe(i) {
+			break
+		}
+
+		p.Stats.addSent()
+		buildBFDEcho(pkt, myDisc, uint32(i), time.Duration(p.Interval)*time.Millisecond)
+		conn.SetDeadline(time.Now().Add(time.Duration(p.Timeout) * time.Millisecond))
+
+		tStart := time.Now()
+		if _, err := conn.WriteToUDP(pkt, raddr); err != nil {
+			p.Stats.addFailure()
+			p.printf("请求失败: %v\n", err)
+			continue
+		}
+
+		//跳过其他会话或迟到的报文
+		var from *net.UDPAddr
+		for {
+			var n int
+			n, from, err = conn.ReadFromUDP(buf)
+			if err != nil || isBFDEcho(buf[:n], myDisc, uint32(i)) {
+				break
+			}
+		}
+		tSpend := time.Since(tStart).Milliseconds()
+		if err != nil {
+			p.Stats.addTimeout()
+			p.Stats.addFailure()
+			p.printf("请求超时。\n")
+			continue
+		}
+		p.Stats.addSuccess(tSpend)
+		p.printf("来自 %s 的回复: 序号=%d 时间=%dms\n", from.IP, i, tSpend)
+	}
+
+	p.printSummary()
+}
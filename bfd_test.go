@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildBFDEcho(t *testing.T) {
+	b := make([]byte, bfdPacketLen)
+	buildBFDEcho(b, 0x01020304, 7, 250*time.Millisecond)
+	want := []byte{
+		0x20, 0xc0, 3, 24, //Vers=1 Sta=Up 检测倍数 长度
+		1, 2, 3, 4, //My Discriminator
+		0, 0, 0, 7, //Your Discriminator 存放序号
+		0, 0x03, 0xd0, 0x90, //Desired Min TX Interval 250000us
+		0, 0, 0, 0,
+		0, 0, 0, 0,
+	}
+	if !bytes.Equal(b, want) {
+		t.Fatalf("buildBFDEcho = % x\n应为 % x", b, want)
+	}
+
+	tests := []struct {
+		name   string
+		b      []byte
+		disc   uint32
+		seq    uint32
+		expect bool
+	}{
+		{"本会话", b, 0x01020304, 7, true},
+		{"其他序号", b, 0x01020304, 6, false},
+		{"其他会话", b, 0x01020305, 7, false},
+		{"过短", b[:bfdPacketLen-1], 0x01020304, 7, false},
+		{"版本不符", append([]byte{0x40}, b[1:]...), 0x01020304, 7, false},
+	}
+	for _, tt := range tests {
+		if got := isBFDEcho(tt.b, tt.disc, tt.seq); got != tt.expect {
+			t.Errorf("%s: isBFDEcho = %v", tt.name, got)
+		}
+	}
+}
+
+// 发往本机3785端口的回显报文回到同一个socket，相当于对端环回
+func TestRunBFDLoopback(t *testing.T) {
+	if c, err := net.ListenUDP("udp4", &net.UDPAddr{Port: bfdEchoPort}); err != nil {
+		t.Skipf("端口 %d 不可用: %v", bfdEchoPort, err)
+	} else {
+		c.Close()
+	}
+	parseArgs(t, "-bfd", "-n", "2", "-w", "500", "127.0.0.1")
+	p := newPinger("127.0.0.1")
+	stdout, _ := captureOutput(t, p.RunBFD)
+	if n := strings.Count(stdout, "来自 127.0.0.1 的回复"); n != 2 {
+		t.Errorf("收到 %d 个回复，应为 2:\n%s", n, stdout)
+	}
+	if ss := p.Stats.Snapshot(); ss.Sent != 2 || ss.Received != 2 {
+		t.Errorf("统计 = %+v", ss)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sort"
+	"time"
+)
+
+var broadcast bool //-broadcast 向广播地址发送回显请求，输出每台应答的主机
+
+// 一台应答广播或组播的主机
+type groupHost struct {
+	ip      net.IP
+	name    string //输出的地址，IPv6链路本地地址带接口
+	replies int
+	minRTT  time.Duration
+}
+
+// 从未连接的原始套接字读到的一个应答
+type groupReply struct {
+	src   net.IP
+	name  string //输出及区分主机使用的地址
+	bytes int    //回显的数据长度
+	hops  string //TTL或跳数限制的说明，无法取得时为空
+}
+
+// 广播及组播在IPv4、IPv6下的差异：请求的构造及应答的解析
+// parse在报文不是本次请求(序号seq)的回显应答时返回false
+type groupProto struct {
+	fill  func(data []byte, seq int) error
+	parse func(pkt, oob []byte, from *net.IPAddr, seq int) (groupReply, bool)
+}
+
+var groupIPv4 = groupProto{fill: fillEcho, parse: parseGroupReply4}
+
+// 解析IPv4的回显应答，ReadMsgIP保留了IP头
+func parseGroupReply4(pkt, oob []byte, from *net.IPAddr, seq int) (groupReply, bool) {
+	if checkIPv4Header(pkt) != nil {
+		return groupReply{}, false
+	}
+	ihl := int(pkt[0]&0x0f) * 4
+	if len(pkt) < ihl+8 || pkt[ihl] != 0 || binary.BigEndian.Uint16(pkt[ihl+4:ihl+6]) != echoID || binary.BigEndian.Uint16(pkt[ihl+6:ihl+8]) != uint16(seq) {
+		return groupReply{}, false
+	}
+	src := net.IP(append([]byte(nil), pkt[12:16]...))
+	return groupReply{src: src, name: src.String(), bytes: len(pkt) - ihl - 8, hops: "TTL=" + ttlText(int(pkt[8]))}, true
+}
+
+// RunBroadcast 向广播地址(255.255.255.255 或子网广播地址)发送回显请求，
+// 每次请求在超时时间内收集所有主机的应答，每台主机输出一行
+// 连接的套接字不能用于广播，这里使用未连接的原始套接字并开启SO_BROADCAST
+func (p *Pinger) RunBroadcast() {
+	raddr, conn := p.listenGroup()
+	if conn == nil {
+		return
+	}
+	defer conn.Close()
+	if err := setBroadcast(conn); err != nil {
+		p.fail(err)
+		p.printf("无法开启广播: %v\n", err)
+		return
+	}
+
+	p.printf("正在向广播地址 %s 发送 %d 字节的数据，收集 %dms 内的所有应答：\n", p.Addr, p.Size, p.Timeout)
+	p.collectGroupReplies(conn, raddr, groupIPv4)
+}
+
+// 解析目标并建立未连接的原始套接字，失败时已输出原因并返回nil
+func (p *Pinger) listenGroup() (*net.IPAddr, *net.IPConn) {
+	p.Host = icmpHost(p.Arg)
+	raddr, err := net.ResolveIPAddr("ip4", p.Host)
+	if err != nil {
+		p.fail(err)
+		p.printf("%s", dialErrorText(p.Host, err))
+		return nil, nil
+	}
+	p.Addr = raddr.String()
+	pc, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		p.fail(err)
+		p.printf("%s", dialErrorText(p.Host, err))
+		return nil, nil
+	}
+	return raddr, pc.(*net.IPConn)
+}
+
+// 向广播或组播地址发送回显请求，每次请求在超时时间内收集所有主机的应答并输出统计信息
+// 至少有一台主机应答的请求计为成功，往返时间取最先到达的应答
+func (p *Pinger) collectGroupReplies(conn *net.IPConn, raddr *net.IPAddr, proto groupProto) {
+	bufp := recvBufPool.Get().(*[]byte)
+	defer recvBufPool.Put(bufp)
+	buf := *bufp
+	oob := make([]byte, 128) //IPv6的跳数限制以控制消息传递
+	data := make([]byte, 8+p.Size)
+	hosts := map[string]*groupHost{}
+
+	for i := 0; forever || i < p.Count; i++ {
+		if !p.nextProbe(i) {
+			break
+		}
+
+		p.Stats.addSent()
+		if err := proto.fill(data, i); err != nil {
+			p.Stats.addFailure()
+			continue
+		}
+		tStart := time.Now()
+		conn.SetDeadline(tStart.Add(time.Duration(p.Timeout) * time.Millisecond))
+		if _, err := conn.WriteTo(data, raddr); err != nil {
+			p.Stats.addSendError(err)
+			p.Stats.addFailure()
+			p.printf("请求失败: %s\n", sendErrorText(err))
+			continue
+		}
+
+		//读取到超时为止
+		var first time.Duration
+		seen := map[string]bool{} //本次请求已输出的主机，重复的应答不再输出
+		for {
+			n, oobn, _, from, err := conn.ReadMsgIP(buf, oob)
+			if err != nil {
+				break
+			}
+			rtt := time.Since(tStart)
+			r, ok := proto.parse(buf[:n], oob[:oobn], from, i)
+			if !ok {
+				continue
+			}
+			key := r.name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if first == 0 {
+				first = rtt
+			}
+			h := hosts[key]
+			if h == nil {
+				h = &groupHost{ip: r.src, name: r.name, minRTT: rtt}
+				hosts[key] = h
+			}
+			h.replies++
+			if rtt < h.minRTT {
+				h.minRTT = rtt
+			}
+			hops := ""
+			if r.hops != "" {
+				hops = " " + r.hops
+			}
+			p.printf("来自 %s 的回复: 字节=%d 时间=%dms%s\n", key, r.bytes, rtt.Milliseconds(), hops)
+		}
+		if len(seen) == 0 {
+			p.Stats.addTimeout()
+			p.Stats.addFailure()
+			p.printf("请求超时。\n")
+			continue
+		}
+		p.Stats.addSuccess(first.Milliseconds())
+	}
+
+	p.printGroupHosts(hosts)
+	p.printSummary()
+}
+
+// 按地址顺序输出应答过的主机
+func (p *Pinger) printGroupHosts(hosts map[string]*groupHost) {
+	if len(hosts) == 0 {
+		p.printf("没有主机应答(多数系统默认忽略发往广播及组播地址的回显请求，如Linux的 net.ipv4.icmp_echo_ignore_broadcasts)。\n")
+		return
+	}
+	list := make([]*groupHost, 0, len(hosts))
+	for _, h := range hosts {
+		list = append(list, h)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return bytes.Compare(list[i].ip.To16(), list[j].ip.To16()) < 0
+	})
+	p.printf("\n共 %d 台主机应答:\n", len(list))
+	for _, h := range list {
+		p.printf("    %-15s 应答 %d 次，最短 %dms\n", h.name, h.replies, h.minRTT.Milliseconds())
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 应答过的主机按地址顺序输出，而不是按字符串顺序
+func TestPrintGroupHosts(t *testing.T) {
+	p := &Pinger{}
+	stdout, _ := captureOutput(t, func() {
+		p.printGroupHosts(map[string]*groupHost{
+			"10.0.0.10": {ip: net.IPv4(10, 0, 0, 10), name: "10.0.0.10", replies: 1, minRTT: 3 * time.Millisecond},
+			"10.0.0.9":  {ip: net.IPv4(10, 0, 0, 9), name: "10.0.0.9", replies: 2, minRTT: time.Millisecond},
+		})
+	})
+	want := "\n共 2 台主机应答:\n" +
+		"    10.0.0.9        应答 2 次，最短 1ms\n" +
+		"    10.0.0.10       应答 1 次，最短 3ms\n"
+	if stdout != want {
+		t.Errorf("输出:\n%s\n期望:\n%s", stdout, want)
+	}
+
+	stdout, _ = captureOutput(t, func() { p.printGroupHosts(nil) })
+	if !strings.Contains(stdout, "没有主机应答") || !strings.Contains(stdout, "net.ipv4.icmp_echo_ignore_broadcasts") {
+		t.Errorf("没有主机应答时的输出:\n%s", stdout)
+	}
+}
+
+func TestBroadcastFlags(t *testing.T) {
+	if errs := argErrors(t, "-broadcast", "-drop-privs", "nobody", "127.255.255.255"); !hasArgError(errs, "-drop-privs 不能与") || !hasArgError(errs, "-broadcast") {
+		t.Errorf("-drop-privs 与 -broadcast 的错误 = %q", errs)
+	}
+}
+
+// 未连接的套接字同样可以发往单播地址，跳过回环上自己发出的请求
+func TestRunBroadcast(t *testing.T) {
+	needRawSocket(t)
+	parseArgs(t, "-broadcast", "-n", "2", "-w", "300", "-i", "0", "127.0.0.1")
+	p := newPinger("127.0.0.1")
+	stdout, _ := captureOutput(t, p.RunBroadcast)
+	if ss := p.Stats.Snapshot(); ss.Sent != 2 || ss.Received != 2 {
+		t.Errorf("统计 = %+v", ss)
+	}
+	for _, want := range []string{"正在向广播地址 127.0.0.1 发送 32 字节的数据，收集 300ms 内的所有应答", "来自 127.0.0.1 的回复: 字节=32 ", "共 1 台主机应答", "127.0.0.1       应答 2 次"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("输出中没有 %q:\n%s", want, stdout)
+		}
+	}
+}
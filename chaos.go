@@ -0,0 +1,26 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+var chaosLoss float64 //-chaos-loss 按该百分比随机丢弃发出的请求
+
+// 是否丢弃本次请求
+func chaosDrop() bool {
+	return chaosLoss > 0 && rand.Float64()*100 < chaosLoss
+}
+
+// 模拟一次被丢弃的请求：不发送报文，等待到超时为止，期间响应Ctrl+C
+// 返回实际等待的时长
+func (p *Pinger) chaosWait(wait time.Duration) time.Duration {
+	tStart := time.Now()
+	t := time.NewTimer(wait)
+	select {
+	case <-t.C:
+	case <-stop:
+		t.Stop()
+	}
+	return time.Since(tStart)
+}
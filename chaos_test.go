@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChaosDrop(t *testing.T) {
+	t.Cleanup(func() { chaosLoss = 0 })
+	tests := []struct {
+		loss     float64
+		min, max int //1000次中丢弃的次数
+	}{
+		{0, 0, 0},
+		{100, 1000, 1000},
+		{30, 200, 400},
+	}
+	for _, tt := range tests {
+		chaosLoss = tt.loss
+		n := 0
+		for i := 0; i < 1000; i++ {
+			if chaosDrop() {
+				n++
+			}
+		}
+		if n < tt.min || n > tt.max {
+			t.Errorf("-chaos-loss %v: 丢弃 %d 次，应在 %d-%d 之间", tt.loss, n, tt.min, tt.max)
+		}
+	}
+}
+
+// 被丢弃的请求等待到超时，Ctrl+C 时立即返回
+func TestChaosWait(t *testing.T) {
+	oldStop := stop
+	stop = make(chan struct{})
+	t.Cleanup(func() { stop = oldStop })
+
+	p := &Pinger{}
+	if d := p.chaosWait(50 * time.Millisecond); d < 50*time.Millisecond {
+		t.Errorf("等待了 %v，应为超时时间 50ms", d)
+	}
+	close(stop)
+	if d := p.chaosWait(10 * time.Second); d > time.Second {
+		t.Errorf("Ctrl+C 后仍等待了 %v", d)
+	}
+}
+
+// 全部丢弃时不发送请求，按超时计入丢失，统计中单独给出丢弃数
+func TestChaosLossRun(t *testing.T) {
+	needRawSocket(t)
+	parseArgs(t, "-n", "3", "-w", "50", "-chaos-loss", "100", "127.0.0.1")
+	p := newPinger("127.0.0.1")
+	stdout, _ := captureOutput(t, p.Run)
+	if n := strings.Count(stdout, "请求超时。"); n != 3 || strings.Contains(stdout, "来自 ") {
+		t.Errorf("输出:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, "其中 3 个请求被 -chaos-loss 丢弃，并未发送。") {
+		t.Errorf("统计中没有丢弃数:\n%s", stdout)
+	}
+	if ss := p.Stats.Snapshot(); ss.Sent != 3 || ss.Lost != 3 || ss.ChaosDropped != 3 {
+		t.Errorf("统计 = %+v", ss)
+	}
+	if errs := argErrors(t, "-chaos-loss", "101", "127.0.0.1"); !hasArgError(errs, "-chaos-loss: 取值 101 超出范围 0-100") {
+		t.Errorf("错误 = %q", errs)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import "golang.org/x/sys/cpu"
+
+const haveCheckSumASM = true
+
+// SSE2属于amd64的基本指令集，仍按CPU特性检测，测试中可以关闭以比较两种实现
+var haveCheckSumSSE2 = cpu.X86.HasSSE2
+
+// 汇编实现的检验和算法，见 checksum_amd64.s
+//
+//go:noescape
+func checkSumASM(data []byte) uint16
+
+// SSE2实现的检验和算法，见 checksum_sse2_amd64.s
+//
+//go:noescape
+func checkSumSSE2(data []byte) uint16
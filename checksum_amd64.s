@@ -0,0 +1,81 @@
+#include "textflag.h"
+
+// func checkSumASM(data []byte) uint16
+//
+// 以8字节为单位用ADCQ累加(带进位加法，进位在下一次加法中回卷)，
+// 按小端方式读取相当于把每个16位字的高低字节互换，
+// 反码求和与字节序无关，最后把结果的高低字节换回来即可
+TEXT ·checkSumASM(SB), NOSPLIT, $0-26
+	MOVQ data_base+0(FP), SI
+	MOVQ data_len+8(FP), CX
+	XORQ AX, AX
+
+	// 每次循环处理32字节
+loop32:
+	CMPQ CX, $32
+	JB   loop8
+	ADDQ 0(SI), AX
+	ADCQ 8(SI), AX
+	ADCQ 16(SI), AX
+	ADCQ 24(SI), AX
+	ADCQ $0, AX
+	ADDQ $32, SI
+	SUBQ $32, CX
+	JMP  loop32
+
+	// 每次循环处理8字节
+loop8:
+	CMPQ CX, $8
+	JB   tail4
+	ADDQ 0(SI), AX
+	ADCQ $0, AX
+	ADDQ $8, SI
+	SUBQ $8, CX
+	JMP  loop8
+
+	// 剩余不足8字节
+tail4:
+	CMPQ CX, $4
+	JB   tail2
+	MOVL 0(SI), DX
+	ADDQ DX, AX
+	ADCQ $0, AX
+	ADDQ $4, SI
+	SUBQ $4, CX
+
+tail2:
+	CMPQ CX, $2
+	JB   tail1
+	MOVWQZX 0(SI), DX
+	ADDQ DX, AX
+	ADCQ $0, AX
+	ADDQ $2, SI
+	SUBQ $2, CX
+
+	// 奇数长度的最后一个字节，小端下位于低8位，换回字节序后即为高8位
+tail1:
+	CMPQ CX, $1
+	JB   fold
+	MOVBQZX 0(SI), DX
+	ADDQ DX, AX
+	ADCQ $0, AX
+
+	// 64位折叠为32位
+fold:
+	MOVL AX, DX
+	SHRQ $32, AX
+	ADDQ DX, AX
+
+	// 32位不断折叠，直到高16位为0
+fold16:
+	MOVQ AX, DX
+	SHRQ $16, DX
+	ANDQ $0xffff, AX
+	ADDQ DX, AX
+	CMPQ AX, $0xffff
+	JA   fold16
+
+	ROLW $8, AX
+	NOTL AX
+	MOVW AX, ret+24(FP)
+	RET
@@ -0,0 +1,15 @@
+//go:build !amd64
+
+package main
+
+const haveCheckSumASM = false
+
+var haveCheckSumSSE2 = false
+
+func checkSumASM(data []byte) uint16 {
+	panic("unreachable")
+}
+
+func checkSumSSE2(data []byte) uint16 {
+	panic("unreachable")
+}
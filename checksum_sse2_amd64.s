@@ -0,0 +1,141 @@
+#include "textflag.h"
+
+// 每个32位通道的低16位
+DATA sse2lo16<>+0(SB)/8, $0x0000ffff0000ffff
+DATA sse2lo16<>+8(SB)/8, $0x0000ffff0000ffff
+GLOBL sse2lo16<>(SB), RODATA|NOPTR, $16
+
+// func checkSumSSE2(data []byte) uint16
+//
+// 每次循环用MOVOU读入64字节，把每个32位通道拆成低16位(PAND)及高16位(PSRLL)，
+// 分别用PADDL累加到X0、X1，通道足够宽，不需要逐次处理进位。
+// 每4096次循环(256KB)把各通道汇总到AX，X0、X1相加时也不会溢出。
+// 与checkSumASM一样按小端读取，剩余不足16字节的部分及折叠方式也相同
+TEXT ·checkSumSSE2(SB), NOSPLIT, $0-26
+	MOVQ  data_base+0(FP), SI
+	MOVQ  data_len+8(FP), CX
+	XORQ  AX, AX
+	MOVOU sse2lo16<>(SB), X6
+
+block:
+	PXOR X0, X0
+	PXOR X1, X1
+	MOVQ $4096, BX
+
+loop64:
+	CMPQ  CX, $64
+	JB    loop16
+	MOVOU 0(SI), X2
+	MOVOU 16(SI), X4
+	MOVOU 32(SI), X8
+	MOVOU 48(SI), X10
+	MOVO  X2, X3
+	MOVO  X4, X5
+	MOVO  X8, X9
+	MOVO  X10, X11
+	PAND  X6, X2
+	PAND  X6, X4
+	PAND  X6, X8
+	PAND  X6, X10
+	PSRLL $16, X3
+	PSRLL $16, X5
+	PSRLL $16, X9
+	PSRLL $16, X11
+	PADDL X2, X0
+	PADDL X3, X1
+	PADDL X4, X0
+	PADDL X5, X1
+	PADDL X8, X0
+	PADDL X9, X1
+	PADDL X10, X0
+	PADDL X11, X1
+	ADDQ  $64, SI
+	SUBQ  $64, CX
+	DECQ  BX
+	JNZ   loop64
+	JMP   flush
+
+	// 剩余不足64字节时每次循环处理16字节
+loop16:
+	CMPQ  CX, $16
+	JB    flush
+	MOVOU 0(SI), X2
+	MOVO  X2, X3
+	PAND  X6, X2
+	PSRLL $16, X3
+	PADDL X2, X0
+	PADDL X3, X1
+	ADDQ  $16, SI
+	SUBQ  $16, CX
+	JMP   loop16
+
+	// 把X0、X1的8个32位通道累加到AX
+flush:
+	PADDL  X1, X0
+	MOVQ   X0, DX
+	MOVL   DX, R8
+	SHRQ   $32, DX
+	ADDQ   R8, AX
+	ADDQ   DX, AX
+	PSRLDQ $8, X0
+	MOVQ   X0, DX
+	MOVL   DX, R8
+	SHRQ   $32, DX
+	ADDQ   R8, AX
+	ADDQ   DX, AX
+	CMPQ   CX, $16
+	JAE    block
+
+	// 剩余不足16字节
+	CMPQ CX, $8
+	JB   tail4
+	ADDQ 0(SI), AX
+	ADCQ $0, AX
+	ADDQ $8, SI
+	SUBQ $8, CX
+
+tail4:
+	CMPQ CX, $4
+	JB   tail2
+	MOVL 0(SI), DX
+	ADDQ DX, AX
+	ADCQ $0, AX
+	ADDQ $4, SI
+	SUBQ $4, CX
+
+tail2:
+	CMPQ    CX, $2
+	JB      tail1
+	MOVWQZX 0(SI), DX
+	ADDQ    DX, AX
+	ADCQ    $0, AX
+	ADDQ    $2, SI
+	SUBQ    $2, CX
+
+	// 奇数长度的最后一个字节，小端下位于低8位，换回字节序后即为高8位
+tail1:
+	CMPQ    CX, $1
+	JB      fold
+	MOVBQZX 0(SI), DX
+	ADDQ    DX, AX
+	ADCQ    $0, AX
+
+	// 64位折叠为32位
+fold:
+	MOVL AX, DX
+	SHRQ $32, AX
+	ADDQ DX, AX
+
+	// 32位不断折叠，直到高16位为0
+fold16:
+	MOVQ AX, DX
+	SHRQ $16, DX
+	ANDQ $0xffff, AX
+	ADDQ DX, AX
+	CMPQ AX, $0xffff
+	JA   fold16
+
+	ROLW $8, AX
+	NOTL AX
+	MOVW AX, ret+24(FP)
+	RET
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestCheckSum(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want uint16
+	}{
+		{"空", nil, 0xffff},
+		{"RFC 1071 示例", []byte{0x00, 0x01, 0xf2, 0x03, 0xf4, 0xf5, 0xf6, 0xf7}, 0x220d},
+		//奇数长度：最后一个字节作为高8位，0x01 按 0x0100 累加，不是 0x0001
+		{"单字节", []byte{0x01}, 0xfeff},
+		{"奇数长度", []byte{0x00, 0x01, 0xf2}, 0x0dfe},
+		{"奇数长度进位", []byte{0xff, 0xff, 0xff}, 0x00ff},
+		{"全1", []byte{0xff, 0xff, 0xff, 0xff}, 0x0000},
+	}
+	for _, tt := range tests {
+		if got := checkSumGeneric(tt.data); got != tt.want {
+			t.Errorf("%s: checkSumGeneric = %#04x，应为 %#04x", tt.name, got, tt.want)
+		}
+		if got, _ := checkSum(tt.data); got != tt.want {
+			t.Errorf("%s: checkSum = %#04x，应为 %#04x", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 写入检验和后重新计算整个报文的结果为0，奇数长度的数据也是如此
+func TestCheckSumVerifies(t *testing.T) {
+	for size := 0; size <= 9; size++ {
+		pkt := make([]byte, 8+size)
+		for i := 8; i < len(pkt); i++ {
+			pkt[i] = byte(0xa5 ^ i)
+		}
+		if err := fillEcho(pkt, 0x1234); err != nil {
+			t.Fatal(err)
+		}
+		if sum, _ := checkSum(pkt); sum != 0 {
+			t.Errorf("数据 %d 字节: 重新计算为 %#04x", size, sum)
+		}
+	}
+}
+
+// 各实现在不同长度上的耗时，SSE2与ADC循环的交叉点决定checkSumSSE2Min
+func BenchmarkCheckSum(b *testing.B) {
+	for _, n := range []int{64, 256, 512, 1500, 9000, 65535} {
+		data := make([]byte, n)
+		for i := range data {
+			data[i] = byte(i * 7)
+		}
+		impls := []struct {
+			name string
+			ok   bool
+			fn   func([]byte) uint16
+		}{
+			{"go", true, checkSumGeneric},
+			{"asm", haveCheckSumASM, checkSumASM},
+			{"sse2", haveCheckSumSSE2, checkSumSSE2},
+		}
+		for _, impl := range impls {
+			if !impl.ok {
+				continue
+			}
+			b.Run(fmt.Sprintf("%s/%d", impl.name, n), func(b *testing.B) {
+				b.SetBytes(int64(n))
+				for i := 0; i < b.N; i++ {
+					impl.fn(data)
+				}
+			})
+		}
+	}
+}
+
+// 汇编实现与纯Go实现在10000个随机输入(长度0-2048，含奇数长度)上结果相同
+func TestCheckSumASMMatchesGeneric(t *testing.T) {
+	if !haveCheckSumASM {
+		t.Skip("当前平台没有汇编实现")
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		data := make([]byte, rnd.Intn(2049))
+		rnd.Read(data)
+		off := rnd.Intn(8) //起始地址不对齐
+		buf := append(make([]byte, off), data...)[off:]
+		if got, want := checkSumASM(buf), checkSumGeneric(data); got != want {
+			t.Fatalf("长度 %d 偏移 %d: checkSumASM = %#04x，checkSumGeneric = %#04x", len(data), off, got, want)
+		}
+	}
+}
+
+// SSE2实现与纯Go实现在10000个随机输入(长度0-65535，含奇数长度及全1)上结果相同
+func TestCheckSumSSE2MatchesGeneric(t *testing.T) {
+	if !haveCheckSumSSE2 {
+		t.Skip("当前CPU不支持SSE2")
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		n := rnd.Intn(2049)
+		if i%10 == 0 {
+			n = rnd.Intn(65536) //每10个输入中有一个较长
+		}
+		data := make([]byte, n)
+		if i%7 == 0 {
+			for j := range data {
+				data[j] = 0xff //每个通道都取最大值，检查累加不会溢出
+			}
+		} else {
+			rnd.Read(data)
+		}
+		off := rnd.Intn(16) //起始地址不按16字节对齐
+		buf := append(make([]byte, off), data...)[off:]
+		if got, want := checkSumSSE2(buf), checkSumGeneric(data); got != want {
+			t.Fatalf("长度 %d 偏移 %d: checkSumSSE2 = %#04x，checkSumGeneric = %#04x", len(data), off, got, want)
+		}
+	}
+}
+
+// 超过汇总间隔(256KB)的输入与ADC循环结果相同，checkSumGeneric的32位累加在此长度会溢出
+func TestCheckSumSSE2Large(t *testing.T) {
+	if !haveCheckSumSSE2 {
+		t.Skip("当前CPU不支持SSE2")
+	}
+	for _, n := range []int{256 << 10, 256<<10 + 17, 1 << 20, 3<<20 + 5} {
+		data := make([]byte, n)
+		for i := range data {
+			data[i] = 0xff
+		}
+		data[0] = 0x01
+		if got, want := checkSumSSE2(data), checkSumASM(data); got != want {
+			t.Errorf("长度 %d: checkSumSSE2 = %#04x，checkSumASM = %#04x", n, got, want)
+		}
+	}
+}
+
+// checkSum在checkSumSSE2Min两侧分别使用两种实现，结果都与纯Go实现相同
+func TestCheckSumDispatch(t *testing.T) {
+	for _, n := range []int{checkSumSSE2Min - 1, checkSumSSE2Min, checkSumSSE2Min + 1, 1480} {
+		data := make([]byte, n)
+		for i := range data {
+			data[i] = byte(i*31 + 7)
+		}
+		if got, _ := checkSum(data); got != checkSumGeneric(data) {
+			t.Errorf("长度 %d: checkSum = %#04x，checkSumGeneric = %#04x", n, got, checkSumGeneric(data))
+		}
+	}
+}
+
+// 以纯Go实现为准比对汇编实现，长度不超过IP报文的上限
+func FuzzCheckSum(f *testing.F) {
+	f.Add([]byte{}, 0)
+	f.Add([]byte{0x00, 0x01, 0xf2, 0x03, 0xf4, 0xf5, 0xf6, 0xf7}, 1)
+	f.Add(make([]byte, 1500), 3)
+	f.Fuzz(func(t *testing.T, data []byte, off int) {
+		if len(data) > 65535 {
+			return
+		}
+		off &= 15
+		buf := append(make([]byte, off), data...)[off:]
+		want := checkSumGeneric(data)
+		if haveCheckSumASM {
+			if got := checkSumASM(buf); got != want {
+				t.Errorf("长度 %d: checkSumASM = %#04x，checkSumGeneric = %#04x", len(data), got, want)
+			}
+		}
+		if haveCheckSumSSE2 {
+			if got := checkSumSSE2(buf); got != want {
+				t.Errorf("长度 %d: checkSumSSE2 = %#04x，checkSumGeneric = %#04x", len(data), got, want)
+			}
+		}
+	})
+}
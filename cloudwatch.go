@@ -0,0 +1,94 @@
+//go:build cloudwatch
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// CloudWatch导出，以 -tags cloudwatch 编译
+
+const cloudWatchSupported = true
+
+const (
+	cloudWatchTimeout   = 10 * time.Second //每批发送的超时时间
+	cloudWatchBatchSize = 20               //每次PutMetricData的指标数
+)
+
+// cloudWatchAPI 发送指标用到的CloudWatch接口，测试时替换为假的客户端
+type cloudWatchAPI interface {
+	PutMetricData(ctx context.Context, in *cloudwatch.PutMetricDataInput, opts ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error)
+}
+
+// 一批探测结束后，在 -cloudwatch 命名空间下发送各目标的 RTT.Min、RTT.Avg、RTT.Max、PacketLoss，
+// 维度为 Host=目标；凭证按AWS的标准顺序获取(环境变量、配置文件、实例角色等)
+func cloudWatchBatch(pingers []*Pinger) {
+	if cloudWatchNamespace == "" {
+		return
+	}
+	data := cloudWatchData(pingers, time.Now())
+	if len(data) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cloudWatchTimeout)
+	defer cancel()
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "CloudWatch: 无法加载AWS配置: %v\n", err)
+		return
+	}
+	if err := putCloudWatch(ctx, cloudwatch.NewFromConfig(cfg), cloudWatchNamespace, data); err != nil {
+		fmt.Fprintf(os.Stderr, "CloudWatch: 发送失败: %v\n", err)
+	}
+}
+
+// 各目标的指标，没有发送过请求的目标跳过，没有收到回复时只有PacketLoss
+func cloudWatchData(pingers []*Pinger, now time.Time) []types.MetricDatum {
+	var data []types.MetricDatum
+	for _, p := range pingers {
+		ss := p.Stats.Snapshot()
+		if ss.Sent == 0 {
+			continue
+		}
+		dims := []types.Dimension{{Name: aws.String("Host"), Value: aws.String(p.Arg)}}
+		datum := func(name string, v float64, unit types.StandardUnit) types.MetricDatum {
+			return types.MetricDatum{MetricName: aws.String(name), Dimensions: dims, Timestamp: aws.Time(now), Value: aws.Float64(v), Unit: unit}
+		}
+		if ss.Received > 0 {
+			data = append(data,
+				datum("RTT.Min", float64(ss.Min), types.StandardUnitMilliseconds),
+				datum("RTT.Avg", float64(ss.Avg()), types.StandardUnitMilliseconds),
+				datum("RTT.Max", float64(ss.Max), types.StandardUnitMilliseconds))
+		}
+		data = append(data, datum("PacketLoss", ss.LossPercent(), types.StandardUnitPercent))
+	}
+	return data
+}
+
+// 按每次cloudWatchBatchSize个指标分批发送，遇到错误时停止
+func putCloudWatch(ctx context.Context, client cloudWatchAPI, namespace string, data []types.MetricDatum) error {
+	for len(data) > 0 {
+		n := len(data)
+		if n > cloudWatchBatchSize {
+			n = cloudWatchBatchSize
+		}
+		_, err := client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(namespace),
+			MetricData: data[:n],
+		})
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !cloudwatch
+
+package main
+
+// 默认编译不包含AWS SDK，-cloudwatch 需要以 -tags cloudwatch 编译
+const cloudWatchSupported = false
+
+func cloudWatchBatch(pingers []*Pinger) {}
@@ -0,0 +1,114 @@
+//go:build cloudwatch
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// fakeCloudWatch 记录每次PutMetricData的请求，第failAt次(从1开始)返回错误
+type fakeCloudWatch struct {
+	calls  []*cloudwatch.PutMetricDataInput
+	failAt int
+}
+
+func (f *fakeCloudWatch) PutMetricData(ctx context.Context, in *cloudwatch.PutMetricDataInput, opts ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error) {
+	f.calls = append(f.calls, in)
+	if len(f.calls) == f.failAt {
+		return nil, errors.New("throttled")
+	}
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+// 按记录的往返时间(毫秒，<0表示丢失)生成目标
+func cloudWatchPinger(host string, rtts ...int64) *Pinger {
+	p := &Pinger{Arg: host, Stats: newStatistics()}
+	at := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i, rtt := range rtts {
+		p.Stats.addRecord(at.Add(time.Duration(i)*time.Second), rtt, rtt >= 0)
+	}
+	return p
+}
+
+func TestCloudWatchData(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 1, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		pinger *Pinger
+		want   map[string]float64
+	}{
+		{"有回复", cloudWatchPinger("a", 10, 20, 30), map[string]float64{"RTT.Min": 10, "RTT.Avg": 20, "RTT.Max": 30, "PacketLoss": 0}},
+		{"部分丢失", cloudWatchPinger("b", 10, -1, 30, -1), map[string]float64{"RTT.Min": 10, "RTT.Avg": 20, "RTT.Max": 30, "PacketLoss": 50}},
+		{"全部丢失", cloudWatchPinger("c", -1, -1), map[string]float64{"PacketLoss": 100}},
+		{"没有发送", cloudWatchPinger("d"), map[string]float64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := cloudWatchData([]*Pinger{tt.pinger}, now)
+			got := map[string]float64{}
+			for _, d := range data {
+				if len(d.Dimensions) != 1 || aws.ToString(d.Dimensions[0].Name) != "Host" || aws.ToString(d.Dimensions[0].Value) != tt.pinger.Arg {
+					t.Errorf("%s 的维度 = %+v", aws.ToString(d.MetricName), d.Dimensions)
+				}
+				if !aws.ToTime(d.Timestamp).Equal(now) {
+					t.Errorf("%s 的时间 = %v", aws.ToString(d.MetricName), aws.ToTime(d.Timestamp))
+				}
+				unit := types.StandardUnitMilliseconds
+				if aws.ToString(d.MetricName) == "PacketLoss" {
+					unit = types.StandardUnitPercent
+				}
+				if d.Unit != unit {
+					t.Errorf("%s 的单位 = %s，应为 %s", aws.ToString(d.MetricName), d.Unit, unit)
+				}
+				got[aws.ToString(d.MetricName)] = aws.ToFloat64(d.Value)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("指标 = %v，应为 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPutCloudWatch(t *testing.T) {
+	var pingers []*Pinger
+	for i := 0; i < 7; i++ {
+		pingers = append(pingers, cloudWatchPinger(fmt.Sprintf("10.0.0.%d", i+1), 5, 15))
+	}
+	data := cloudWatchData(pingers, time.Now()) //每个目标4个指标，共28个
+	tests := []struct {
+		name    string
+		failAt  int
+		batches []int
+		wantErr bool
+	}{
+		{"分批发送", 0, []int{20, 8}, false},
+		{"出错时停止", 1, []int{20}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeCloudWatch{failAt: tt.failAt}
+			err := putCloudWatch(context.Background(), client, "Net/Ping", data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("putCloudWatch 错误 = %v", err)
+			}
+			var batches []int
+			for _, in := range client.calls {
+				if aws.ToString(in.Namespace) != "Net/Ping" {
+					t.Errorf("命名空间 = %q", aws.ToString(in.Namespace))
+				}
+				batches = append(batches, len(in.MetricData))
+			}
+			if fmt.Sprint(batches) != fmt.Sprint(tt.batches) {
+				t.Fatalf("各批指标数 = %v，应为 %v", batches, tt.batches)
+			}
+		})
+	}
+}
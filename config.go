@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 配置文件格式为TOML的一个子集：
+//
+//	# 全局默认值
+//	[defaults]
+//	timeout = 1000   # 毫秒，对应 -w
+//	count = 4        # 对应 -n
+//	size = 32        # 对应 -l
+//	interval = 1000  # 两次请求的间隔(毫秒)
+//
+//	[[target]]
+//	host = "192.168.1.1"
+//	timeout = 200
+//	labels = { site = "bj", role = "gw" }
+//
+// 优先级：命令行参数 > 目标配置 > [defaults] > 参数默认值
+
+// Config 配置文件内容
+type Config struct {
+	Defaults TargetConfig   //全局默认值
+	Targets  []TargetConfig //目标列表
+}
+
+// TargetConfig 单个目标的配置，数值项为nil表示未配置
+type TargetConfig struct {
+	Host     string
+	Timeout  *int64
+	Count    *int
+	Size     *int
+	Interval *int64
+	Labels   map[string]string
+}
+
+// 读取并校验配置文件
+func loadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg := &Config{}
+	var cur *TargetConfig //当前所在的表，nil表示还未进入任何表
+	section := ""         //当前表名，用于错误提示
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		switch {
+		case line == "[defaults]":
+			cur, section = &cfg.Defaults, "defaults"
+			continue
+		case line == "[[target]]":
+			cfg.Targets = append(cfg.Targets, TargetConfig{})
+			cur, section = &cfg.Targets[len(cfg.Targets)-1], fmt.Sprintf("target #%d", len(cfg.Targets))
+			continue
+		case strings.HasPrefix(line, "["):
+			return nil, fmt.Errorf("%s 第 %d 行: 不支持的表 %s", path, lineNo, line)
+		}
+
+		if cur == nil {
+			return nil, fmt.Errorf("%s 第 %d 行: 配置项必须位于 [defaults] 或 [[target]] 之下", path, lineNo)
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s 第 %d 行: 无法解析 %q", path, lineNo, line)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if err := cur.set(key, value, section == "defaults"); err != nil {
+			return nil, fmt.Errorf("%s 第 %d 行: [%s] 配置项 %q: %v", path, lineNo, section, key, err)
+		}
+
+		//目标有了host之后，错误提示中使用host更直观
+		if section != "defaults" && cur.Host != "" {
+			section = fmt.Sprintf("target %q", cur.Host)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Targets) == 0 {
+		return nil, fmt.Errorf("%s: 至少需要配置一个 [[target]]", path)
+	}
+	for i, t := range cfg.Targets {
+		if t.Host == "" {
+			return nil, fmt.Errorf("%s: [target #%d] 缺少配置项 \"host\"", path, i+1)
+		}
+	}
+	return cfg, nil
+}
+
+// 设置一个配置项
+func (t *TargetConfig) set(key, value string, isDefaults bool) error {
+	switch key {
+	case "host":
+		if isDefaults {
+			return fmt.Errorf("只能在 [[target]] 中配置")
+		}
+		s, err := parseString(value)
+		if err != nil {
+			return err
+		}
+		t.Host = s
+	case "timeout", "interval":
+		n, err := parsePositiveInt(value, key == "interval")
+		if err != nil {
+			return err
+		}
+		if key == "timeout" {
+			t.Timeout = &n
+		} else {
+			t.Interval = &n
+		}
+	case "count", "size":
+		n, err := parsePositiveInt(value, key == "size")
+		if err != nil {
+			return err
+		}
+		if key == "size" && n > maxPayload {
+			return fmt.Errorf("取值 %d 超出范围 0-%d", n, maxPayload)
+		}
+		v := int(n)
+		if key == "count" {
+			t.Count = &v
+		} else {
+			t.Size = &v
+		}
+	case "labels":
+		labels, err := parseInlineTable(value)
+		if err != nil {
+			return err
+		}
+		if err := validateLabels(labels); err != nil {
+			return err
+		}
+		t.Labels = labels
+	default:
+		return fmt.Errorf("未知的配置项")
+	}
+	return nil
+}
+
+// 去掉行尾注释，引号内的#不算注释
+func stripComment(line string) string {
+	inQuote := false
+	for i, c := range line {
+		switch c {
+		case '"':
+			inQuote = !inQuote
+		case '#':
+			if !inQuote {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+func parseString(value string) (string, error) {
+	s, err := strconv.Unquote(value)
+	if err != nil || !strings.HasPrefix(value, `"`) {
+		return "", fmt.Errorf("应为带双引号的字符串，实际为 %s", value)
+	}
+	return s, nil
+}
+
+func parsePositiveInt(value string, allowZero bool) (int64, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("应为整数，实际为 %s", value)
+	}
+	if n < 0 || (n == 0 && !allowZero) {
+		return 0, fmt.Errorf("取值 %d 超出范围", n)
+	}
+	return n, nil
+}
+
+// 解析形如 { a = "x", b = "y" } 的内联表，仅支持字符串值
+func parseInlineTable(value string) (map[string]string, error) {
+	if !strings.HasPrefix(value, "{") || !strings.HasSuffix(value, "}") {
+		return nil, fmt.Errorf("应为内联表 { key = \"value\", ... }")
+	}
+	labels := map[string]string{}
+	body := strings.TrimSpace(value[1 : len(value)-1])
+	if body == "" {
+		return labels, nil
+	}
+	for _, pair := range strings.Split(body, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("无法解析 %q", strings.TrimSpace(pair))
+		}
+		k = strings.TrimSpace(k)
+		s, err := parseString(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("标签 %q: %v", k, err)
+		}
+		labels[k] = s
+	}
+	return labels, nil
+}
+
+// 计算某个目标最终生效的参数，写入该目标的Pinger
+// 命令行中显式指定的参数优先级最高
+func (cfg *Config) apply(p *Pinger, t TargetConfig) {
+	if !explicit["timeout"] {
+		if v := pickInt64(t.Timeout, cfg.Defaults.Timeout); v != nil {
+			p.Timeout = *v
+		}
+	}
+	if !explicit["count"] {
+		if v := pickInt(t.Count, cfg.Defaults.Count); v != nil {
+			p.Count = *v
+		}
+	}
+	if !explicit["size"] {
+		if v := pickInt(t.Size, cfg.Defaults.Size); v != nil {
+			p.Size = *v
+		}
+	}
+	if !explicit["interval"] {
+		if v := pickInt64(t.Interval, cfg.Defaults.Interval); v != nil {
+			p.Interval = *v
+		}
+	}
+}
+
+func pickInt64(vs ...*int64) *int64 {
+	for _, v := range vs {
+		if v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+func pickInt(vs ...*int) *int {
+	for _, v := range vs {
+		if v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// 将标签格式化为 k=v,k=v，按key排序保证输出稳定
+func formatLabels(labels map[string]string) string {
+	keys := sortedKeys(labels)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+labels[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// 按字母顺序排列的标签名
+func sortedKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 读取样例配置，检查各目标最终生效的参数
+func TestConfigApply(t *testing.T) {
+	type want struct {
+		count, size int
+		timeout     int64
+		labels      string
+	}
+	tests := []struct {
+		name     string
+		explicit map[string]bool
+		want     map[string]want
+	}{
+		{"配置文件", nil, map[string]want{
+			"127.0.0.1": {3, 16, 800, "role=gw,site=bj"},
+			"localhost": {2, 100, 150, "site=sh"},
+		}},
+		{"命令行参数优先", map[string]bool{"count": true, "timeout": true}, map[string]want{
+			"127.0.0.1": {4, 16, 1000, "role=gw,site=bj"},
+			"localhost": {4, 100, 1000, "site=sh"},
+		}},
+	}
+	cfg, err := loadConfig("testdata/config.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Targets) != 2 {
+		t.Fatalf("目标数 = %d，应为 2", len(cfg.Targets))
+	}
+	defer func(t int64, n, l int, i int64, e map[string]bool) {
+		timeout, count, size, interval, explicit = t, n, l, i, e
+	}(timeout, count, size, interval, explicit)
+	timeout, count, size, interval = 1000, 4, 32, 1000
+	for _, tt := range tests {
+		for _, target := range cfg.Targets {
+			explicit = tt.explicit
+			p := newPinger(target.Host)
+			cfg.apply(p, target)
+			w := tt.want[target.Host]
+			if p.Count != w.count || p.Size != w.size || p.Timeout != w.timeout || p.Interval != 0 || formatLabels(target.Labels) != w.labels {
+				t.Errorf("%s %s: n=%d l=%d w=%d i=%d 标签=%s，应为 n=%d l=%d w=%d i=0 标签=%s", tt.name,
+					target.Host, p.Count, p.Size, p.Timeout, p.Interval, formatLabels(target.Labels), w.count, w.size, w.timeout, w.labels)
+			}
+		}
+	}
+}
+
+// recordingConn 在mockConn上记录每次发送的报文长度
+type recordingConn struct {
+	*mockConn
+	sizes []int
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	c.sizes = append(c.sizes, len(b))
+	return c.mockConn.Write(b)
+}
+
+// 读取样例配置，以模拟的连接ping回环地址，检查各目标最终生效的参数
+func TestConfigRoundTrip(t *testing.T) {
+	type want struct {
+		count, size int
+		timeout     int64
+		labels      string
+	}
+	tests := []struct {
+		name  string
+		flags []string
+		want  map[string]want
+	}{
+		{"配置文件", nil, map[string]want{
+			"127.0.0.1": {3, 16, 800, "role=gw,site=bj"},
+			"localhost": {2, 100, 150, "site=sh"},
+		}},
+		{"命令行参数优先", []string{"-n", "5", "-w", "300"}, map[string]want{
+			"127.0.0.1": {5, 16, 300, "role=gw,site=bj"},
+			"localhost": {5, 100, 300, "site=sh"},
+		}},
+		{"Linux风格的别名同样优先", []string{"-s", "40"}, map[string]want{
+			"127.0.0.1": {3, 40, 800, "role=gw,site=bj"},
+			"localhost": {2, 40, 150, "site=sh"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseArgs(t, append([]string{"-config", "testdata/config.toml"}, tt.flags...)...)
+			pingers := configPingers(configPath)
+			if len(pingers) != len(tt.want) {
+				t.Fatalf("目标数 = %d，应为 %d", len(pingers), len(tt.want))
+			}
+			for _, p := range pingers {
+				w := tt.want[p.Arg]
+				conn := &recordingConn{mockConn: newMockConn()}
+				predial(t, icmpHost(p.Arg), conn)
+				p.Quiet = true
+				p.Run()
+
+				if p.Count != w.count || p.Size != w.size || p.Timeout != w.timeout || formatLabels(p.Labels) != w.labels {
+					t.Errorf("%s: n=%d l=%d w=%d 标签=%s，应为 n=%d l=%d w=%d 标签=%s",
+						p.Arg, p.Count, p.Size, p.Timeout, formatLabels(p.Labels), w.count, w.size, w.timeout, w.labels)
+				}
+				ss := p.Stats.Snapshot()
+				if p.Err != nil || ss.Sent != w.count || ss.Received != w.count {
+					t.Errorf("%s: 发送 %d 收到 %d (%v)，应各为 %d", p.Arg, ss.Sent, ss.Received, p.Err, w.count)
+				}
+				for _, n := range conn.sizes {
+					if n != 8+w.size {
+						t.Errorf("%s: 发送了 %d 字节的请求，应为 %d", p.Arg, n, 8+w.size)
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+// 校验错误要指出出错的配置项及所在的目标
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"未知的配置项", "[[target]]\nhost = \"a\"\nttl = 3\n", []string{"第 3 行", `[target "a"]`, `"ttl"`, "未知的配置项"}},
+		{"目标中的非法取值", "[[target]]\nhost = \"a\"\ntimeout = -1\n", []string{`[target "a"]`, `"timeout"`, "超出范围"}},
+		{"size过大", "[defaults]\nsize = 70000\n[[target]]\nhost = \"a\"\n", []string{"[defaults]", `"size"`, "超出范围"}},
+		{"还没有host的目标", "[[target]]\ncount = x\n", []string{"[target #1]", `"count"`, "应为整数"}},
+		{"默认值中的host", "[defaults]\nhost = \"a\"\n", []string{"[defaults]", `"host"`}},
+		{"标签格式", "[[target]]\nhost = \"a\"\nlabels = site\n", []string{`"labels"`, "内联表"}},
+		{"缺少host", "[[target]]\ncount = 1\n", []string{"[target #1]", `"host"`}},
+		{"没有目标", "[defaults]\ncount = 1\n", []string{"至少需要配置一个 [[target]]"}},
+		{"不支持的表", "[server]\n", []string{"不支持的表"}},
+		{"不在表中", "count = 1\n", []string{"[defaults] 或 [[target]]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ping.toml")
+			if err := os.WriteFile(path, []byte(tt.body), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := loadConfig(path)
+			if err == nil {
+				t.Fatal("应返回错误")
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(err.Error(), s) {
+					t.Errorf("错误 %q 中没有 %q", err, s)
+				}
+			}
+		})
+	}
+}
+
+func TestParseInlineTable(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{`{}`, "", false},
+		{`{ site = "bj", role = "gw" }`, "role=gw,site=bj", false},
+		{`{ note = "a # b" }`, "note=a # b", false},
+		{`{ site = bj }`, "", true},
+		{`site = "bj"`, "", true},
+		{`{ site }`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseInlineTable(tt.in)
+		if (err != nil) != tt.wantErr || (err == nil && formatLabels(got) != tt.want) {
+			t.Errorf("parseInlineTable(%s) = %v, %v", tt.in, got, err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import "time"
+
+var (
+	cycleActive time.Duration //-cycle-active 每个周期中探测的时长
+	cyclePeriod time.Duration //-cycle-period 周期长度
+)
+
+// 周期调度使用的时钟，便于模拟
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+var cycleClock clock = realClock{}
+
+// 周期调度的状态
+type cycleState struct {
+	start time.Time //当前周期的开始时间
+	first int       //当前周期第一次请求的结果序号
+}
+
+// 检查是否仍在当前周期的探测时段内，超出时输出本周期的统计，
+// 等待到下一个周期开始；等待期间按暂停处理，不计入可用性统计。收到Ctrl+C时返回false
+func (p *Pinger) cycleWindow() bool {
+	now := cycleClock.Now()
+	if p.cycle.start.IsZero() {
+		p.cycle.start = now
+		return true
+	}
+	if now.Sub(p.cycle.start) < cycleActive {
+		return true
+	}
+
+	samples, nextSeq := p.Stats.samplesSince(p.cycle.first)
+	p.printCycleSummary(samples)
+
+	//探测时段可能因超时而超出周期，跳过已经错过的周期
+	next := p.cycle.start.Add(cyclePeriod)
+	for !next.After(now) {
+		next = next.Add(cyclePeriod)
+	}
+	p.printf("等待下一个周期(%s)...\n", next.Format("15:04:05"))
+	p.Stats.pause(now)
+	select {
+	case <-cycleClock.After(next.Sub(now)):
+	case <-stop:
+		return false
+	}
+	p.Stats.resume(next)
+	p.cycle = cycleState{start: next, first: nextSeq}
+	return true
+}
+
+// 输出一个周期的统计
+func (p *Pinger) printCycleSummary(samples []probeSample) {
+	received := 0
+	var min, max, total int64
+	for _, s := range samples {
+		if !s.OK {
+			continue
+		}
+		if received == 0 || s.RTT < min {
+			min = s.RTT
+		}
+		if s.RTT > max {
+			max = s.RTT
+		}
+		total += s.RTT
+		received++
+	}
+	sent := len(samples)
+	loss := 0.0
+	if sent > 0 {
+		loss = float64(sent-received) / float64(sent) * 100
+	}
+	p.printf("\n周期 %s 的统计: 已发送 = %d，已接收 = %d，丢失 = %d (%.2f%% 丢失)",
+		p.cycle.start.Format("2006-01-02 15:04:05"), sent, received, sent-received, loss)
+	if received > 0 {
+		p.printf("，最短 = %dms，最长 = %dms，平均 = %dms", min, max, total/int64(received))
+	}
+	p.printf("\n")
+}
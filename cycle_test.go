@@ -0,0 +1,146 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// 模拟的时钟：等待时立即把时间拨到等待结束，block为true时等待不返回
+type fakeClock struct {
+	now   time.Time
+	slept []time.Duration
+	block bool
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.slept = append(c.slept, d)
+	if c.block {
+		return nil
+	}
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// 以模拟的时钟代替cycleClock
+func useFakeClock(t *testing.T, at time.Time) *fakeClock {
+	c := &fakeClock{now: at}
+	old := cycleClock
+	cycleClock = c
+	t.Cleanup(func() { cycleClock = old })
+	return c
+}
+
+// 每10秒探测一次，按周期调度模拟6个周期；第2个周期全部丢失
+// 周期之间的等待不计入可用性统计，累计统计跨周期累加
+func TestCycleSchedule(t *testing.T) {
+	parseArgs(t, "-cycle-active", "30s", "-cycle-period", "10m", "10.0.0.1")
+	t0 := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	clk := useFakeClock(t, t0)
+	p := &Pinger{Addr: "10.0.0.1", Stats: newStatistics()}
+
+	out, _ := captureOutput(t, func() {
+		for probes := 0; probes < 18 && p.cycleWindow(); probes++ {
+			ok := clk.now.Sub(t0) < 10*time.Minute || clk.now.Sub(t0) >= 20*time.Minute
+			p.Stats.addRecord(clk.now, 20, ok)
+			clk.now = clk.now.Add(10 * time.Second)
+		}
+	})
+
+	if len(clk.slept) != 5 {
+		t.Fatalf("等待了 %d 次，期望 5 次", len(clk.slept))
+	}
+	for _, d := range clk.slept {
+		if d != 9*time.Minute+30*time.Second {
+			t.Errorf("等待 %v，期望 9m30s", d)
+		}
+	}
+	for i, want := range []string{
+		"周期 2024-03-04 00:00:00 的统计: 已发送 = 3，已接收 = 3，丢失 = 0 (0.00% 丢失)，最短 = 20ms",
+		"周期 2024-03-04 00:10:00 的统计: 已发送 = 3，已接收 = 0，丢失 = 3 (100.00% 丢失)\n",
+		"周期 2024-03-04 00:20:00 的统计: 已发送 = 3，已接收 = 3",
+		"周期 2024-03-04 00:40:00 的统计: 已发送 = 3，已接收 = 3",
+		"等待下一个周期(00:50:00)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("第 %d 项: 输出中没有 %q:\n%s", i, want, out)
+		}
+	}
+	if strings.Contains(out, "周期 2024-03-04 00:50:00") {
+		t.Error("最后一个周期未结束，不应输出统计")
+	}
+
+	ss := p.Stats.Snapshot()
+	if ss.Sent != 18 || ss.Received != 15 || ss.Lost != 3 {
+		t.Errorf("累计统计 = %+v", ss)
+	}
+	//5个完整周期各30秒、最后一个周期20秒；离线从第2个周期的第一次请求到该周期结束
+	if a := ss.Avail; a.Runtime != 170*time.Second || a.Downtime != 30*time.Second || a.Outages != 1 || a.Down {
+		t.Errorf("可用性 = %+v，期望 运行170s 离线30s 1次", a)
+	}
+}
+
+// 探测时段因超时超出了周期时，跳过已经错过的周期
+func TestCycleSkipsMissed(t *testing.T) {
+	parseArgs(t, "-cycle-active", "30s", "-cycle-period", "1m", "10.0.0.1")
+	t0 := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	clk := useFakeClock(t, t0)
+	p := &Pinger{Addr: "10.0.0.1", Quiet: true, Stats: newStatistics()}
+	p.cycleWindow()
+	p.Stats.addRecord(t0, 0, false)
+	clk.now = t0.Add(2*time.Minute + 30*time.Second)
+	if !p.cycleWindow() {
+		t.Fatal("cycleWindow返回false")
+	}
+	if len(clk.slept) != 1 || clk.slept[0] != 30*time.Second || !p.cycle.start.Equal(t0.Add(3*time.Minute)) {
+		t.Errorf("等待 %v，下一个周期 %v，期望 30s、00:03:00", clk.slept, p.cycle.start)
+	}
+	if p.cycle.first != 1 {
+		t.Errorf("下一个周期从第 %d 个结果开始，期望 1", p.cycle.first)
+	}
+}
+
+// 等待下一个周期时收到Ctrl+C立即返回false
+func TestCycleStop(t *testing.T) {
+	oldStop := stop
+	stop = make(chan struct{})
+	t.Cleanup(func() { stop = oldStop })
+
+	parseArgs(t, "-cycle-active", "30s", "-cycle-period", "10m", "10.0.0.1")
+	t0 := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	clk := useFakeClock(t, t0)
+	clk.block = true
+	p := &Pinger{Addr: "10.0.0.1", Quiet: true, Stats: newStatistics()}
+	p.cycleWindow()
+	clk.now = t0.Add(30 * time.Second)
+	close(stop)
+	if p.cycleWindow() {
+		t.Error("收到Ctrl+C后cycleWindow返回true")
+	}
+}
+
+func TestCycleFlags(t *testing.T) {
+	tests := []struct {
+		args    []string
+		err     string //空表示没有错误
+		forever bool
+	}{
+		{[]string{"-cycle-active", "30s", "-cycle-period", "10m", "x"}, "", true}, //默认持续到Ctrl+C
+		{[]string{"-cycle-active", "30s", "-cycle-period", "10m", "-n", "20", "x"}, "", false},
+		{[]string{"-cycle-active", "30s", "x"}, "必须同时指定", false},
+		{[]string{"-cycle-period", "10m", "x"}, "必须同时指定", false},
+		{[]string{"-cycle-active", "10m", "-cycle-period", "10m", "x"}, "必须小于 -cycle-period", false},
+		{[]string{"-cycle-active", "-1s", "-cycle-period", "10m", "x"}, "不能为负数", false},
+		{[]string{"-cycle-active", "30s", "-cycle-period", "10m", "-1", "x"}, "参数 -1 只发送一次请求", false},
+	}
+	for _, tt := range tests {
+		errs := argErrors(t, tt.args...)
+		if tt.err == "" && (len(errs) > 0 || forever != tt.forever) || tt.err != "" && !hasArgError(errs, tt.err) {
+			t.Errorf("参数 %q: 错误 %q，持续 %v，期望 %q、%v", tt.args, errs, forever, tt.err, tt.forever)
+		}
+	}
+}
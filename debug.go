@@ -0,0 +1,81 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	_ "net/http/pprof" //在 /debug/pprof/ 提供性能分析
+	"os"
+	"sync"
+)
+
+var debugListen string //-debug-listen 在该地址提供 /debug/vars 及 /debug/pprof/
+
+// expvar的变量在进程内只能发布一次，targets读取最近一次startDebugServer的目标
+var (
+	debugMu      sync.Mutex
+	debugPingers []*Pinger
+	debugPublish sync.Once
+)
+
+// /debug/vars 中单个目标的状态
+type debugTarget struct {
+	Target      string  `json:"target"`
+	Sent        int     `json:"sent"`
+	Received    int     `json:"received"`
+	LossPercent float64 `json:"loss_percent"`
+	SendErrors  int     `json:"send_errors"` //本机发送失败的请求数
+	Timeouts    int     `json:"timeouts"`
+	LastRTT     int64   `json:"last_rtt_ms"` //-1表示最近一次失败或尚未收到回复
+	State       string  `json:"state"`       //unknown / up / down / dns_error
+	LastError   string  `json:"last_error,omitempty"`
+}
+
+// 各目标当前的状态，取自Statistics的Snapshot()
+func debugTargets(pingers []*Pinger) []debugTarget {
+	targets := make([]debugTarget, 0, len(pingers))
+	for _, p := range pingers {
+		ss := p.Stats.Snapshot()
+		state := "unknown"
+		if ss.Avail.Known {
+			state = "up"
+			if ss.Avail.Down {
+				state = "down"
+			}
+		} else if isDNSError(p.Err) {
+			state = dnsEventError
+		}
+		targets = append(targets, debugTarget{
+			Target: p.Arg, Sent: ss.Sent, Received: ss.Received, LossPercent: ss.LossPercent(),
+			SendErrors: ss.SendErrors, Timeouts: ss.Timeouts,
+			LastRTT: ss.Last, State: state, LastError: ss.LastError,
+		})
+	}
+	return targets
+}
+
+// 开始提供调试接口：expvar的 /debug/vars(其中targets为各目标的状态)及pprof，直到进程退出
+// 返回实际监听的地址(addr的端口为0时由系统选取)；监听失败时返回错误，之后的服务错误只输出到标准错误
+func startDebugServer(addr string, pingers []*Pinger) (net.Addr, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	debugMu.Lock()
+	debugPingers = pingers
+	debugMu.Unlock()
+	debugPublish.Do(func() {
+		expvar.Publish("targets", expvar.Func(func() any {
+			debugMu.Lock()
+			defer debugMu.Unlock()
+			return debugTargets(debugPingers)
+		}))
+	})
+	go func() {
+		if err := http.Serve(ln, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "调试接口出错: %v\n", err)
+		}
+	}()
+	return ln.Addr(), nil
+}
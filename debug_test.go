@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+)
+
+// 读取 /debug/vars 中的targets
+func debugVars(t *testing.T, addr net.Addr) []debugTarget {
+	t.Helper()
+	resp, err := http.Get("http://" + addr.String() + "/debug/vars")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var vars struct {
+		Targets []debugTarget `json:"targets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+		t.Fatal(err)
+	}
+	return vars.Targets
+}
+
+// 在系统选取的端口上开启调试接口，探测结束后JSON反映各目标的状态
+func TestDebugServer(t *testing.T) {
+	needRawSocket(t)
+	parseArgs(t, "-n", "3", "-i", "0", "-w", "100", "127.0.0.1", "203.0.113.1")
+	up, down := newPinger("127.0.0.1"), newPinger("203.0.113.1")
+	up.Quiet, down.Quiet = true, true
+	nx := &Pinger{Arg: "nx.invalid", Stats: newStatistics()}
+	addr, err := startDebugServer("127.0.0.1:0", []*Pinger{up, down, nx})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := debugVars(t, addr)
+	if len(before) != 3 || before[0].State != "unknown" || before[0].Sent != 0 {
+		t.Fatalf("探测前 targets = %+v", before)
+	}
+
+	up.Run()
+	down.Run()
+	nx.fail(&net.DNSError{Err: "no such host", Name: "nx.invalid", IsNotFound: true})
+
+	want := []debugTarget{
+		{Target: "127.0.0.1", Sent: 3, Received: 3, State: "up"},
+		{Target: "203.0.113.1", Sent: 3, LossPercent: 100, Timeouts: 3, LastRTT: -1, State: "down"},
+		{Target: "nx.invalid", LastRTT: -1, State: dnsEventError, LastError: nx.Err.Error()},
+	}
+	got := debugVars(t, addr)
+	if len(got) != len(want) {
+		t.Fatalf("targets = %+v", got)
+	}
+	for i := range want {
+		if i == 0 {
+			if got[0].LastRTT < 0 {
+				t.Errorf("%s: last_rtt_ms = %d", got[0].Target, got[0].LastRTT)
+			}
+			got[0].LastRTT = 0 //回环的往返时间不固定
+		}
+		if got[i] != want[i] {
+			t.Errorf("第 %d 个目标 = %+v\n期望 %+v", i, got[i], want[i])
+		}
+	}
+
+	resp, err := http.Get("http://" + addr.String() + "/debug/pprof/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("/debug/pprof/ 返回 %s", resp.Status)
+	}
+
+	if _, err := startDebugServer(addr.String(), nil); err == nil {
+		t.Error("端口已被占用时没有报错")
+	}
+}
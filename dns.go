@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+var (
+	dnsServer string //-dns 要测量的DNS服务器
+	dnsQuery  string //查询的域名
+)
+
+const (
+	dnsPort       = "53"
+	dnsHeaderLen  = 12
+	dnsTypeA      = 1
+	dnsClassIN    = 1
+	dnsFlagRD     = 1 << 8  //期望递归
+	dnsFlagQR     = 1 << 15 //应答
+	dnsMaxLabel   = 63
+	dnsMaxName    = 253
+	dnsDefaultQry = "test.invalid"
+)
+
+var dnsRcodes = []string{"NOERROR", "FORMERR", "SERVFAIL", "NXDOMAIN", "NOTIMP", "REFUSED"}
+
+// 构造查询A记录的DNS请求
+func buildDNSQuery(id uint16, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || len(name) > dnsMaxName {
+		return nil, fmt.Errorf("无效的域名 %q", name)
+	}
+	b := make([]byte, dnsHeaderLen, dnsHeaderLen+len(name)+6)
+	binary.BigEndian.PutUint16(b[0:2], id)
+	binary.BigEndian.PutUint16(b[2:4], dnsFlagRD)
+	binary.BigEndian.PutUint16(b[4:6], 1) //QDCOUNT
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > dnsMaxLabel {
+			return nil, fmt.Errorf("无效的域名 %q", name)
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	b = append(b, 0, 0, dnsTypeA, 0, dnsClassIN)
+	return b, nil
+}
+
+// 解析DNS应答头部，返回ID、返回码及应答记录数
+func parseDNSReply(b []byte) (id uint16, rcode int, answers int, err error) {
+	if len(b) < dnsHeaderLen {
+		return 0, 0, 0, errors.New("应答过短")
+	}
+	flags := binary.BigEndian.Uint16(b[2:4])
+	if flags&dnsFlagQR == 0 {
+		return 0, 0, 0, errors.New("不是DNS应答")
+	}
+	return binary.BigEndian.Uint16(b[0:2]), int(flags & 0x0f), int(binary.BigEndian.Uint16(b[6:8])), nil
+}
+
+// DNS返回码的名称
+func dnsRcodeName(rcode int) string {
+	if rcode < len(dnsRcodes) {
+		return dnsRcodes[rcode]
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
+// RunDNS 以DNS查询的往返时间代替ICMP，测量DNS服务器的可用性
+// 服务器返回任何应答(包括NXDOMAIN)都视为成功
+func (p *Pinger) RunDNS() {
+	t := normalizeTarget(p.Arg)
+	if t.Port == "" {
+		t.Port = dnsPort
+	}
+	p.Host = net.JoinHostPort(t.Host, t.Port)
+	conn, err := net.DialTimeout("udp", p.Host, time.Duration(p.Timeout)*time.Millisecond)
+	if err != nil {
+		p.fail(err)
+		p.printf("无法连接DNS服务器 %s: %v\n", p.Host, err)
+		return
+	}
+	defer conn.Close()
+	p.Addr = conn.RemoteAddr().String()
+
+	p.printf("正在向DNS服务器 %s 查询 %s 的A记录：\n", p.Addr, dnsQuery)
+
+	bufp := recvBufPool.Get().(*[]byte)
+	defer recvBufPool.Put(bufp)
+	buf := *bufp
+	base := echoID //各次查询的ID从进程号开始递增
+
+	for i := 0; forever || i < p.Count; i++ {
+		if !p.nextProbe(i) {
+			break
+		}
+
+		p.Stats.addSent()
+		id := base + uint16(i)
+		query, _ := buildDNSQuery(id, dnsQuery) //域名已在getArgs中校验
+		conn.SetDeadline(time.Now().Add(time.Duration(p.Timeout) * time.Millisecond))
+
+		tStart := time.Now()
+		if _, err := conn.Write(query); err != nil {
+			p.Stats.addSendError(err)
+			p.Stats.addFailure()
+			p.printf("请求失败: %s\n", sendErrorText(err))
+			continue
+		}
+
+		//跳过迟到的旧应答
+		var rcode, answers int
+		for {
+			n, rerr := conn.Read(buf)
+			if err = rerr; err != nil {
+				break
+			}
+			var rid uint16
+			if rid, rcode, answers, err = parseDNSReply(buf[:n]); err == nil && rid == id {
+				break
+			}
+		}
+		tSpend := time.Since(tStart).Milliseconds()
+		if err != nil {
+			p.Stats.addFailure()
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				p.Stats.addTimeout()
+				p.printf("请求超时。\n")
+			} else {
+				p.printf("请求失败: %v\n", err)
+			}
+			continue
+		}
+		p.Stats.addSuccess(tSpend)
+		p.printf("来自 %s 的回复: 序号=%d 时间=%dms 状态=%s 应答数=%d\n", p.Addr, i, tSpend, dnsRcodeName(rcode), answers)
+	}
+
+	p.printSummary()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestBuildDNSQuery(t *testing.T) {
+	got, err := buildDNSQuery(0x1234, "www.example.com.")
+	want := []byte{
+		0x12, 0x34, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0, //ID RD QDCOUNT=1
+		3, 'w', 'w', 'w', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+		0, dnsTypeA, 0, dnsClassIN,
+	}
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("buildDNSQuery = % x, %v\n应为 % x", got, err, want)
+	}
+	for _, name := range []string{"", ".", "a..b", strings.Repeat("a", 64) + ".com", strings.Repeat("a.", 127) + "aa"} {
+		if _, err := buildDNSQuery(1, name); err == nil {
+			t.Errorf("buildDNSQuery(%q) 应返回错误", name)
+		}
+	}
+}
+
+func TestParseDNSReply(t *testing.T) {
+	query, _ := buildDNSQuery(7, "example.com")
+	if _, _, _, err := parseDNSReply(query); err == nil {
+		t.Error("请求不应被当作应答")
+	}
+	if _, _, _, err := parseDNSReply(query[:dnsHeaderLen-1]); err == nil {
+		t.Error("过短的应答应返回错误")
+	}
+	reply := append([]byte(nil), query...)
+	binary.BigEndian.PutUint16(reply[2:4], dnsFlagQR|dnsFlagRD|0x80|3) //RA NXDOMAIN
+	binary.BigEndian.PutUint16(reply[6:8], 2)
+	id, rcode, answers, err := parseDNSReply(reply)
+	if err != nil || id != 7 || rcode != 3 || answers != 2 {
+		t.Errorf("parseDNSReply = %d %d %d %v", id, rcode, answers, err)
+	}
+	if dnsRcodeName(rcode) != "NXDOMAIN" || dnsRcodeName(9) != "RCODE9" {
+		t.Errorf("dnsRcodeName = %q %q", dnsRcodeName(rcode), dnsRcodeName(9))
+	}
+}
+
+// 本地UDP服务器对每个查询先回一个ID不同的旧应答，再回NXDOMAIN
+func startDNSServer(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			reply := append([]byte(nil), buf[:n]...)
+			binary.BigEndian.PutUint16(reply[2:4], dnsFlagQR|dnsFlagRD|3)
+			stale := append([]byte(nil), reply...)
+			binary.BigEndian.PutUint16(stale[0:2], binary.BigEndian.Uint16(reply[0:2])-1)
+			pc.WriteTo(stale, addr)
+			pc.WriteTo(reply, addr)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+// 任何应答(包括NXDOMAIN)都视为成功，ID不符的旧应答被跳过
+func TestRunDNS(t *testing.T) {
+	addr := startDNSServer(t)
+	parseArgs(t, "-n", "3", "-w", "2000", "-dns", addr)
+	p := newPinger(dnsServer)
+	stdout, _ := captureOutput(t, p.RunDNS)
+	if n := strings.Count(stdout, "来自 "+addr+" 的回复"); n != 3 {
+		t.Fatalf("收到 %d 个回复，应为 3:\n%s", n, stdout)
+	}
+	if !strings.Contains(stdout, "查询 test.invalid 的A记录") || !strings.Contains(stdout, "序号=2 ") || !strings.Contains(stdout, "状态=NXDOMAIN 应答数=0") {
+		t.Errorf("输出:\n%s", stdout)
+	}
+	if ss := p.Stats.Snapshot(); ss.Sent != 3 || ss.Received != 3 {
+		t.Errorf("统计 = %+v", ss)
+	}
+}
+
+func TestRunDNSTimeout(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	parseArgs(t, "-n", "2", "-w", "100", "x")
+	p := newPinger(pc.LocalAddr().String())
+	stdout, _ := captureOutput(t, p.RunDNS)
+	if n := strings.Count(stdout, "请求超时。"); n != 2 {
+		t.Errorf("超时 %d 次，应为 2:\n%s", n, stdout)
+	}
+	if ss := p.Stats.Snapshot(); ss.Sent != 2 || ss.Lost != 2 {
+		t.Errorf("统计 = %+v", ss)
+	}
+}
+
+func TestDNSFlags(t *testing.T) {
+	parseArgs(t, "-dns", "192.0.2.53", "-dns-query", "example.com")
+	if dnsServer != "192.0.2.53" || dnsQuery != "example.com" {
+		t.Errorf("dnsServer = %q, dnsQuery = %q", dnsServer, dnsQuery)
+	}
+	if errs := argErrors(t, "-dns", "192.0.2.53", "-dns-query", "a..b"); !hasArgError(errs, `-dns-query: 无效的域名 "a..b"`) {
+		t.Errorf("错误 = %q", errs)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	dnsFailLimit  = 3                //连续多少次解析失败后标记为无法解析
+	dnsBackoffMin = time.Minute      //标记后第一次重试解析的间隔
+	dnsBackoffMax = 60 * time.Minute //重试间隔每次加倍，最长为该值
+)
+
+// 目标解析状态变化的事件名，同时用于表格及各输出中的状态
+const (
+	dnsEventError     = "dns_error"
+	dnsEventRecovered = "dns_recovered"
+)
+
+// 是否为域名解析失败(不存在、超时、服务器错误等)
+func isDNSError(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+// 单个目标的解析失败状态
+type dnsState struct {
+	failures     int           //连续解析失败的次数
+	unresolvable bool          //已标记为无法解析
+	backoff      time.Duration //当前的重试间隔
+	retryAt      time.Time     //标记后下一次允许解析的时间
+	lastErr      error
+}
+
+// 跟踪各目标连续的解析失败，避免拼错的域名在 -schedule 的每次执行中
+// 都等待DNS超时并重复输出相同的错误
+type dnsTracker struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	targets map[string]*dnsState
+}
+
+var dnsFailures = &dnsTracker{now: time.Now, targets: map[string]*dnsState{}}
+
+// 目标已标记为无法解析且未到重试时间时返回true及最近一次的解析错误，此时不再解析
+func (t *dnsTracker) skip(target string) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.targets[target]
+	if st == nil || !st.unresolvable || !t.now().Before(st.retryAt) {
+		return false, nil
+	}
+	return true, st.lastErr
+}
+
+// 目标是否已标记为无法解析
+func (t *dnsTracker) unresolvable(target string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.targets[target]
+	return st != nil && st.unresolvable
+}
+
+// 记录一次建立连接(包括解析)的结果，状态变化时返回dnsEventError或dnsEventRecovered
+// 解析以外的错误不影响状态
+func (t *dnsTracker) observe(target string, err error) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.targets[target]
+	if err == nil {
+		delete(t.targets, target)
+		if st != nil && st.unresolvable {
+			return dnsEventRecovered
+		}
+		return ""
+	}
+	if !isDNSError(err) {
+		return ""
+	}
+	if st == nil {
+		st = &dnsState{}
+		t.targets[target] = st
+	}
+	st.failures++
+	st.lastErr = err
+	switch {
+	case st.unresolvable:
+		st.backoff *= 2
+		if st.backoff > dnsBackoffMax {
+			st.backoff = dnsBackoffMax
+		}
+	case st.failures >= dnsFailLimit:
+		st.unresolvable, st.backoff = true, dnsBackoffMin
+	default:
+		return ""
+	}
+	st.retryAt = t.now().Add(st.backoff)
+	if st.failures == dnsFailLimit {
+		return dnsEventError
+	}
+	return ""
+}
+
+// 输出并记录目标解析状态的变化，每次变化只输出一次
+func announceDNSEvent(target string, labels map[string]string, event string, err error) {
+	switch event {
+	case dnsEventError:
+		fmt.Fprintf(os.Stderr, "目标 %s 连续 %d 次无法解析(%v)，状态变为 %s，之后降低解析的频率，恢复前不再重复输出该错误\n", target, dnsFailLimit, err, dnsEventError)
+	case dnsEventRecovered:
+		fmt.Fprintf(os.Stderr, "目标 %s 恢复解析\n", target)
+	default:
+		return
+	}
+	recordEvent(eventRecord{Time: time.Now(), Target: target, Event: event, Labels: labels})
+}
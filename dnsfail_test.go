@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 以可控的时钟创建空的解析失败跟踪器，代替dnsFailures
+func useDNSTracker(t *testing.T, clock *time.Time) *dnsTracker {
+	old := dnsFailures
+	dnsFailures = &dnsTracker{now: func() time.Time { return *clock }, targets: map[string]*dnsState{}}
+	t.Cleanup(func() { dnsFailures = old })
+	return dnsFailures
+}
+
+var errNXDomain = &net.DNSError{Err: "no such host", Name: "web.test", IsNotFound: true}
+
+// 按预先编排的解析结果检查状态变化、是否跳过解析及重试间隔
+func TestDNSTracker(t *testing.T) {
+	type step struct {
+		after time.Duration //距上一步的时间
+		err   error         //nil表示解析成功
+		skip  bool          //该步之前skip()的结果，为true时不解析
+		event string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"连续失败后标记", []step{
+			{0, errNXDomain, false, ""},
+			{0, errNXDomain, false, ""},
+			{0, errNXDomain, false, dnsEventError},
+			{30 * time.Second, nil, true, ""}, //未到重试时间
+		}},
+		{"重试间隔加倍", []step{
+			{0, errNXDomain, false, ""},
+			{0, errNXDomain, false, ""},
+			{0, errNXDomain, false, dnsEventError},
+			{time.Minute, errNXDomain, false, ""}, //重试仍然失败，不再重复事件
+			{time.Minute, nil, true, ""},          //间隔变为2分钟
+			{time.Minute, errNXDomain, false, ""},
+			{3 * time.Minute, nil, true, ""}, //间隔变为4分钟
+			{time.Minute, nil, false, dnsEventRecovered},
+			{0, errNXDomain, false, ""}, //恢复后重新计数
+		}},
+		{"中间成功时重新计数", []step{
+			{0, errNXDomain, false, ""},
+			{0, errNXDomain, false, ""},
+			{0, nil, false, ""},
+			{0, errNXDomain, false, ""},
+			{0, errNXDomain, false, ""},
+			{0, errNXDomain, false, dnsEventError},
+		}},
+		{"解析以外的错误", []step{
+			{0, errNXDomain, false, ""},
+			{0, errors.New("operation not permitted"), false, ""},
+			{0, errNXDomain, false, ""},
+			{0, errNXDomain, false, dnsEventError},
+		}},
+		{"包装的解析错误", []step{
+			{0, &net.OpError{Op: "dial", Err: errNXDomain}, false, ""},
+			{0, &net.OpError{Op: "dial", Err: errNXDomain}, false, ""},
+			{0, &net.OpError{Op: "dial", Err: errNXDomain}, false, dnsEventError},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+			tr := useDNSTracker(t, &now)
+			for i, s := range tt.steps {
+				now = now.Add(s.after)
+				skip, _ := tr.skip("web.test")
+				if skip != s.skip {
+					t.Fatalf("第 %d 步: skip = %v，期望 %v", i+1, skip, s.skip)
+				}
+				if skip {
+					continue
+				}
+				if ev := tr.observe("web.test", s.err); ev != s.event {
+					t.Fatalf("第 %d 步: 事件 %q，期望 %q", i+1, ev, s.event)
+				}
+			}
+		})
+	}
+}
+
+// 重试间隔最长为dnsBackoffMax
+func TestDNSTrackerBackoffMax(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	tr := useDNSTracker(t, &now)
+	for i := 0; i < 20; i++ {
+		now = now.Add(dnsBackoffMax)
+		tr.observe("web.test", errNXDomain)
+	}
+	if st := tr.targets["web.test"]; st.backoff != dnsBackoffMax || !st.retryAt.Equal(now.Add(dnsBackoffMax)) {
+		t.Errorf("重试间隔 = %v，期望 %v", st.backoff, dnsBackoffMax)
+	}
+	if tr.targets["other.test"] != nil || tr.unresolvable("other.test") {
+		t.Error("其他目标受到了影响")
+	}
+}
+
+// 本机UDP端口上的DNS服务器，ok为false时回答NXDOMAIN，为true时A记录为127.0.0.1
+type scriptedDNS struct {
+	ok      int32 //原子读写
+	queries int32
+}
+
+// 以scriptedDNS代替系统的DNS服务器
+func useScriptedDNS(t *testing.T) *scriptedDNS {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &scriptedDNS{}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := s.answer(buf[:n]); resp != nil {
+				pc.WriteTo(resp, addr)
+			}
+		}
+	}()
+	old := net.DefaultResolver
+	net.DefaultResolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", pc.LocalAddr().String())
+		},
+	}
+	t.Cleanup(func() {
+		net.DefaultResolver = old
+		pc.Close()
+	})
+	return s
+}
+
+// 按查询构造回答：复制头及问题部分，去掉附加记录(EDNS)
+func (s *scriptedDNS) answer(q []byte) []byte {
+	end := 12
+	for end < len(q) && q[end] != 0 {
+		end += int(q[end]) + 1
+	}
+	end += 5 //名称结尾的0、类型及类别
+	if len(q) < 12 || end > len(q) {
+		return nil
+	}
+	atomic.AddInt32(&s.queries, 1)
+	resp := append([]byte(nil), q[:end]...)
+	resp[2], resp[3] = 0x81, 0x80 //QR RD RA
+	binary.BigEndian.PutUint16(resp[6:], 0)
+	binary.BigEndian.PutUint32(resp[8:], 0) //没有授权及附加记录
+	if atomic.LoadInt32(&s.ok) == 0 {
+		resp[3] |= 3 //NXDOMAIN
+		return resp
+	}
+	if qtype := binary.BigEndian.Uint16(q[end-4:]); qtype == 1 {
+		binary.BigEndian.PutUint16(resp[6:], 1)
+		resp = append(resp, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 127, 0, 0, 1)
+	}
+	return resp
+}
+
+// 拼错的域名：第三次失败时输出一次状态变化并写入事件，之后降低解析频率且不重复错误，
+// 解析恢复后自动恢复
+func TestDNSFailureRun(t *testing.T) {
+	needRawSocket(t)
+	dns := useScriptedDNS(t)
+	now := time.Now()
+	useDNSTracker(t, &now)
+	path := filepath.Join(t.TempDir(), "rec.jsonl")
+	parseArgs(t, "-n", "1", "-w", "200", "web.test")
+	if err := startRecord(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stopRecord)
+
+	run := func() (*Pinger, string, string) {
+		p := newPinger("web.test")
+		stdout, stderr := captureOutput(t, p.Run)
+		return p, stdout, stderr
+	}
+	for i := 1; i <= dnsFailLimit; i++ {
+		p, stdout, stderr := run()
+		if !isDNSError(p.Err) || !strings.Contains(stdout, "找不到主机 web.test") {
+			t.Fatalf("第 %d 次: %v\n%s", i, p.Err, stdout)
+		}
+		if gotEvent := strings.Contains(stderr, "状态变为 dns_error"); gotEvent != (i == dnsFailLimit) {
+			t.Errorf("第 %d 次的标准错误: %q", i, stderr)
+		}
+	}
+
+	//未到重试时间：不解析，也不输出
+	queries := atomic.LoadInt32(&dns.queries)
+	p, stdout, stderr := run()
+	if atomic.LoadInt32(&dns.queries) != queries || stdout != "" || stderr != "" || !isDNSError(p.Err) {
+		t.Errorf("标记后仍然解析或输出: %d 次查询，%q %q %v", atomic.LoadInt32(&dns.queries)-queries, stdout, stderr, p.Err)
+	}
+	if got := renderTable([]tableRow{{name: "web.test", err: p.Err}}); !strings.Contains(got, dnsEventError) {
+		t.Errorf("表格中没有 dns_error:\n%s", got)
+	}
+
+	//到重试时间后解析，仍然失败时不重复输出
+	now = now.Add(dnsBackoffMin)
+	p, stdout, stderr = run()
+	if atomic.LoadInt32(&dns.queries) == queries || stdout != "" || stderr != "" || !isDNSError(p.Err) {
+		t.Errorf("重试: %q %q %v", stdout, stderr, p.Err)
+	}
+
+	//解析恢复
+	atomic.StoreInt32(&dns.ok, 1)
+	now = now.Add(2 * dnsBackoffMin)
+	p, _, stderr = run()
+	if p.Err != nil || !strings.Contains(stderr, "目标 web.test 恢复解析") || dnsFailures.unresolvable("web.test") {
+		t.Errorf("恢复: %q %v", stderr, p.Err)
+	}
+	if ss := p.Stats.Snapshot(); ss.Received != 1 {
+		t.Errorf("恢复后收到 %d 个回复，期望 1", ss.Received)
+	}
+	stopRecord()
+
+	events := readEvents(t, path)
+	if len(events) != 2 || events[0].Event != dnsEventError || events[1].Event != dnsEventRecovered || events[0].Target != "web.test" {
+		t.Errorf("事件 = %+v，期望 dns_error、dns_recovered", events)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net"
+	"time"
+)
+
+var noDrain bool //-no-drain 按下Ctrl+C时不等待正在进行的请求
+
+// 按下Ctrl+C后默认继续等待正在进行的请求收到回复或超时，再输出统计信息，避免高估丢失率
+// -no-drain 时立即使等待中的读取返回，该次请求计为丢失
+// 返回的函数在探测结束后调用，停止监视
+func interruptOnStop(conn net.Conn) func() {
+	done := make(chan struct{})
+	if noDrain {
+		s := stop
+		go func() {
+			select {
+			case <-s:
+				conn.SetReadDeadline(time.Now())
+			case <-done:
+			}
+		}()
+	}
+	return func() { close(done) }
+}
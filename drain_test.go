@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 延迟delay后才有回复的连接，读取遵守读超时，超时后返回os.ErrDeadlineExceeded
+type slowConn struct {
+	*mockConn
+	delay time.Duration
+
+	mu       sync.Mutex
+	deadline time.Time
+	changed  chan struct{} //读超时改变时关闭
+}
+
+func newSlowConn(delay time.Duration) *slowConn {
+	return &slowConn{mockConn: newMockConn(), delay: delay, changed: make(chan struct{})}
+}
+
+func (c *slowConn) Read(b []byte) (int, error) {
+	ready := time.NewTimer(c.delay)
+	defer ready.Stop()
+	for {
+		c.mu.Lock()
+		dl, changed := c.deadline, c.changed
+		c.mu.Unlock()
+		var expired <-chan time.Time
+		if !dl.IsZero() {
+			d := time.Until(dl)
+			if d <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+			t := time.NewTimer(d)
+			defer t.Stop()
+			expired = t.C
+		}
+		select {
+		case <-ready.C:
+			return c.mockConn.Read(b)
+		case <-changed:
+		case <-expired:
+		}
+	}
+}
+
+func (c *slowConn) SetDeadline(t time.Time) error { return c.SetReadDeadline(t) }
+
+func (c *slowConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.deadline = t
+	close(c.changed)
+	c.changed = make(chan struct{})
+	c.mu.Unlock()
+	return nil
+}
+
+// 请求发出后按下Ctrl+C：默认等待中的回复仍能收到；-no-drain 时读取立即超时返回
+func TestDrainOnStop(t *testing.T) {
+	oldStop := stop
+	t.Cleanup(func() { stop = oldStop })
+	const delay = 200 * time.Millisecond
+	tests := []struct {
+		name    string
+		flags   []string
+		ok      bool
+		minTime time.Duration
+		maxTime time.Duration
+	}{
+		{"等待回复", nil, true, delay, time.Second},
+		{"-no-drain", []string{"-no-drain"}, false, 0, delay},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseArgs(t, append(tt.flags, "127.0.0.1")...)
+			stop = make(chan struct{})
+			conn := newSlowConn(delay)
+			data := make([]byte, 8+32)
+			if err := fillEcho(data, 0); err != nil {
+				t.Fatal(err)
+			}
+			conn.SetDeadline(time.Now().Add(time.Second))
+			defer interruptOnStop(conn)()
+
+			start := time.Now()
+			conn.Write(data)
+			done := make(chan error, 1)
+			go func() {
+				_, err := conn.Read(make([]byte, 1500))
+				done <- err
+			}()
+			close(stop)
+			var err error
+			select {
+			case err = <-done:
+			case <-time.After(2 * time.Second):
+				t.Fatal("按下Ctrl+C后读取没有返回")
+			}
+			elapsed := time.Since(start)
+
+			if (err == nil) != tt.ok {
+				t.Errorf("读取的错误 = %v，期望收到回复: %v", err, tt.ok)
+			}
+			if elapsed < tt.minTime || elapsed >= tt.maxTime {
+				t.Errorf("用时 %s，期望在 [%s, %s) 之间", elapsed, tt.minTime, tt.maxTime)
+			}
+		})
+	}
+}
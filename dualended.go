@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	dualEnded string //-dual-ended 与该地址的代理配合，区分去程与回程丢失
+	agentAddr string //-agent 作为代理监听该地址
+)
+
+const dualSettle = time.Second //结束时等待代理报告最后几个请求的时间
+
+// 客户端连接代理后发送的第一行：本进程回显请求的ID
+type agentHello struct {
+	ID uint16 `json:"id"`
+}
+
+// 代理收到一个回显请求时发回的一行
+type agentSeen struct {
+	Seq uint16 `json:"seq"`
+}
+
+// 双端模式中与代理的连接，记录代理收到了哪些请求
+type dualPeer struct {
+	conn net.Conn
+	mu   sync.Mutex
+	seen map[uint16]bool
+}
+
+// 连接代理并开始接收报告
+func dialAgent(addr string, timeout time.Duration) (*dualPeer, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.NewEncoder(conn).Encode(agentHello{ID: echoID}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	d := &dualPeer{conn: conn, seen: map[uint16]bool{}}
+	go func() {
+		dec := json.NewDecoder(bufio.NewReader(conn))
+		for {
+			var s agentSeen
+			if dec.Decode(&s) != nil {
+				return
+			}
+			d.mu.Lock()
+			d.seen[s.Seq] = true
+			d.mu.Unlock()
+		}
+	}()
+	return d, nil
+}
+
+// 代理是否收到了该序号的请求；序号只有16位，超过65536个请求后会重复
+func (d *dualPeer) arrived(seq int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.seen[uint16(seq)]
+}
+
+// 按代理的报告把丢失的请求分为去程丢失(代理没有收到)与回程丢失(代理收到但没有回复到达)
+func (d *dualPeer) classify(lost []int) (forward, reverse int) {
+	for _, seq := range lost {
+		if d.arrived(seq) {
+			reverse++
+		} else {
+			forward++
+		}
+	}
+	return forward, reverse
+}
+
+// RunDualEnded 向代理所在的主机发送回显请求，由代理报告收到的请求，结束时区分丢失方向
+func (p *Pinger) RunDualEnded(agent string) {
+	peer, err := dialAgent(agent, time.Duration(p.Timeout)*time.Millisecond)
+	if err != nil {
+		p.fail(err)
+		p.printf("无法连接代理 %s: %v\n", agent, err)
+		return
+	}
+	defer peer.conn.Close()
+	p.dual = peer
+	p.Run()
+}
+
+// 输出丢失方向，先等待代理报告最后几个请求
+func (p *Pinger) printLossDirection() {
+	if len(p.dualLost) == 0 {
+		return
+	}
+	time.Sleep(dualSettle)
+	forward, reverse := p.dual.classify(p.dualLost)
+	p.printf("    丢失方向(-dual-ended): 去程 %d 个(代理没有收到请求)，回程 %d 个(代理收到请求，回复没有到达)。\n", forward, reverse)
+}
+
+// 作为代理运行：接受 -dual-ended 客户端的连接，报告收到的来自该客户端的回显请求，直到按下Ctrl+C
+func runAgent(addr string) int {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "无法监听 %s: %v\n", addr, err)
+		return 1
+	}
+	s := stop
+	go func() {
+		<-s
+		ln.Close()
+	}()
+	fmt.Printf("代理正在 %s 上等待 -dual-ended 客户端的连接。\n", ln.Addr())
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			if stopped() {
+				return 0
+			}
+			fmt.Fprintf(os.Stderr, "接受连接失败: %v\n", err)
+			return 1
+		}
+		go serveAgent(c)
+	}
+}
+
+// 为一个客户端报告收到的回显请求，客户端断开时结束
+func serveAgent(c net.Conn) {
+	defer c.Close()
+	client := c.RemoteAddr().(*net.TCPAddr).IP
+	var hello agentHello
+	if err := json.NewDecoder(c).Decode(&hello); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: 无效的请求: %v\n", client, err)
+		return
+	}
+	pc, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: 无法创建原始套接字: %v\n", client, err)
+		return
+	}
+	defer pc.Close()
+	fmt.Printf("客户端 %s 已连接(ID=%d)。\n", client, hello.ID)
+
+	//客户端断开或按下Ctrl+C时停止接收
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		buf := make([]byte, 1)
+		c.Read(buf)
+		pc.Close()
+	}()
+	s := stop
+	go func() {
+		select {
+		case <-s:
+			pc.Close()
+		case <-done:
+		}
+	}()
+
+	enc := json.NewEncoder(c)
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := pc.ReadFrom(buf) //ip4:icmp 的ReadFrom不含IP头
+		if err != nil {
+			break
+		}
+		ip, ok := from.(*net.IPAddr)
+		if !ok || !ip.IP.Equal(client) || n < 8 || buf[0] != 8 || binary.BigEndian.Uint16(buf[4:6]) != hello.ID {
+			continue
+		}
+		if enc.Encode(agentSeen{Seq: binary.BigEndian.Uint16(buf[6:8])}) != nil {
+			break
+		}
+	}
+	fmt.Printf("客户端 %s 已断开。\n", client)
+}
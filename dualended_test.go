@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 代理收到的请求计为回程丢失，其余为去程丢失；序号只按低16位比较
+func TestDualClassify(t *testing.T) {
+	d := &dualPeer{seen: map[uint16]bool{1: true, 3: true}}
+	if forward, reverse := d.classify([]int{0, 1, 2, 3, 65537}); forward != 2 || reverse != 3 {
+		t.Errorf("classify = 去程 %d 回程 %d，期望 2 3", forward, reverse)
+	}
+	if forward, reverse := d.classify(nil); forward != 0 || reverse != 0 {
+		t.Errorf("没有丢失时 = 去程 %d 回程 %d", forward, reverse)
+	}
+}
+
+func TestDualEndedFlags(t *testing.T) {
+	if errs := argErrors(t, "-dual-ended", "127.0.0.1", "127.0.0.1"); !hasArgError(errs, "-dual-ended: 无效的代理地址 \"127.0.0.1\"") {
+		t.Errorf("没有端口的代理地址: 错误 %q", errs)
+	}
+	if errs := argErrors(t, "-dual-ended", "127.0.0.1:7878", "127.0.0.1"); len(errs) != 0 {
+		t.Errorf("合法的参数报错: %q", errs)
+	}
+}
+
+// 请求照常发出，回复全部丢弃
+type replyLossConn struct {
+	net.Conn
+	first bool
+}
+
+func (c *replyLossConn) Write(b []byte) (int, error) {
+	if !c.first {
+		c.first = true
+		time.Sleep(100 * time.Millisecond) //代理收到ID后才创建原始套接字
+	}
+	return c.Conn.Write(b)
+}
+
+func (c *replyLossConn) Read(b []byte) (int, error) { return 0, os.ErrDeadlineExceeded }
+
+// 记录每次请求序号的连接
+type seqConn struct {
+	*mockConn
+	seqs []int
+}
+
+func (c *seqConn) Write(b []byte) (int, error) {
+	c.seqs = append(c.seqs, int(binary.BigEndian.Uint16(b[6:8])))
+	return c.mockConn.Write(b)
+}
+
+// 不回复的连接，读取立即超时
+type dropConn struct{ *seqConn }
+
+func (c *dropConn) Read(b []byte) (int, error) { return 0, os.ErrDeadlineExceeded }
+
+// 本机运行代理，请求没有发出时为去程丢失，发出后回复丢失时为回程丢失
+func TestRunDualEnded(t *testing.T) {
+	needRawSocket(t)
+	raw, err := dialICMP("127.0.0.1", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	tests := []struct {
+		name string
+		conn net.Conn
+		want string
+	}{
+		{"去程", &dropConn{seqConn: &seqConn{mockConn: newMockConn()}}, "丢失方向(-dual-ended): 去程 2 个(代理没有收到请求)，回程 0 个"},
+		{"回程", &replyLossConn{Conn: raw}, "丢失方向(-dual-ended): 去程 0 个(代理没有收到请求)，回程 2 个"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			agent := ln.Addr().String()
+			parseArgs(t, "-dual-ended", agent, "-n", "2", "-w", "500", "-i", "0", "127.0.0.1")
+			predial(t, "127.0.0.1", tt.conn)
+			p := newPinger("127.0.0.1")
+			stdout, _ := captureOutput(t, func() {
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					if c, err := ln.Accept(); err == nil {
+						serveAgent(c)
+					}
+				}()
+				p.RunDualEnded(agent)
+				<-done //代理在客户端断开后输出，等它结束再恢复标准输出
+			})
+			if !strings.Contains(stdout, tt.want) || !strings.Contains(stdout, "客户端 127.0.0.1 已连接") {
+				t.Errorf("输出中没有 %q:\n%s", tt.want, stdout)
+			}
+		})
+	}
+}
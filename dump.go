@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+var (
+	veryVerbose bool //-vv 在每条回复后输出报文的十六进制内容及各字段
+	dumpMax     int  //-vv 时最多输出的字节数
+)
+
+const hexDumpWidth = 16 //每行输出的字节数
+
+// 十六进制+ASCII形式输出一段数据，offset为该段在报文中的起始位置
+func hexDump(b []byte, offset int) []string {
+	var lines []string
+	for i := 0; i < len(b); i += hexDumpWidth {
+		row := b[i:minInt(i+hexDumpWidth, len(b))]
+		var hex, ascii strings.Builder
+		for j := 0; j < hexDumpWidth; j++ {
+			if j == hexDumpWidth/2 {
+				hex.WriteByte(' ')
+			}
+			if j >= len(row) {
+				hex.WriteString("   ")
+				continue
+			}
+			fmt.Fprintf(&hex, "%02x ", row[j])
+			if c := row[j]; c >= 0x20 && c < 0x7f {
+				ascii.WriteByte(c)
+			} else {
+				ascii.WriteByte('.')
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%04x  %s |%s|", offset+i, hex.String(), ascii.String()))
+	}
+	return lines
+}
+
+// 两个整数中较小的一个
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// 检验和校验结果
+func checksumVerdict(data []byte) string {
+	if sum, _ := checkSum(data); sum == 0 {
+		return "正确"
+	}
+	return "错误"
+}
+
+// 解码回复报文的IP头及ICMP头，每个字段一行
+// 检验和按整个IP头及整个ICMP报文计算，不受输出字节数限制
+func decodeReplyFields(pkt []byte) []string {
+	if len(pkt) < 20 {
+		return []string{fmt.Sprintf("报文过短: %d 字节", len(pkt))}
+	}
+	ihl := int(pkt[0]&0x0f) * 4
+	if ihl < 20 || ihl > len(pkt) {
+		return []string{fmt.Sprintf("IP头长度 %d 非法", ihl)}
+	}
+	flags := binary.BigEndian.Uint16(pkt[6:8])
+	var flagNames []string
+	if flags&0x4000 != 0 {
+		flagNames = append(flagNames, "DF")
+	}
+	if flags&0x2000 != 0 {
+		flagNames = append(flagNames, "MF")
+	}
+	lines := []string{
+		fmt.Sprintf("IP: 版本=%d 头长度=%d TOS=0x%02x 总长度=%d 标识=0x%04x", pkt[0]>>4, ihl, pkt[1], binary.BigEndian.Uint16(pkt[2:4]), binary.BigEndian.Uint16(pkt[4:6])),
+		fmt.Sprintf("    标志=[%s] 片偏移=%d TTL=%d 协议=%d 检验和=0x%04x(%s)", strings.Join(flagNames, ","), (flags&0x1fff)*8, pkt[8], pkt[9], binary.BigEndian.Uint16(pkt[10:12]), checksumVerdict(pkt[:ihl])),
+		fmt.Sprintf("    源地址=%d.%d.%d.%d 目的地址=%d.%d.%d.%d", pkt[12], pkt[13], pkt[14], pkt[15], pkt[16], pkt[17], pkt[18], pkt[19]),
+	}
+
+	icmp := pkt[ihl:]
+	if len(icmp) < 8 {
+		return append(lines, fmt.Sprintf("ICMP: 报文过短: %d 字节", len(icmp)))
+	}
+	return append(lines, fmt.Sprintf("ICMP: 类型=%d 代码=%d 检验和=0x%04x(%s) 标识=%d 序号=%d 数据=%d 字节",
+		icmp[0], icmp[1], binary.BigEndian.Uint16(icmp[2:4]), checksumVerdict(icmp), binary.BigEndian.Uint16(icmp[4:6]), binary.BigEndian.Uint16(icmp[6:8]), len(icmp)-8))
+}
+
+// 输出回复报文的各字段及IP头、ICMP头、数据三段的十六进制内容，最多输出limit字节
+func printReplyDump(pkt []byte, limit int) {
+	for _, line := range decodeReplyFields(pkt) {
+		fmt.Printf("    %s\n", line)
+	}
+
+	ihl := 20
+	if len(pkt) > 0 {
+		ihl = int(pkt[0]&0x0f) * 4
+	}
+	shown := pkt[:minInt(len(pkt), limit)]
+	sections := []struct {
+		name       string
+		start, end int
+	}{{"IP头", 0, ihl}, {"ICMP头", ihl, ihl + 8}, {"数据", ihl + 8, len(shown)}}
+	for _, s := range sections {
+		start, end := minInt(s.start, len(shown)), minInt(s.end, len(shown))
+		if start >= end {
+			continue
+		}
+		fmt.Printf("    %s:\n", s.name)
+		for _, line := range hexDump(shown[start:end], start) {
+			fmt.Printf("      %s\n", line)
+		}
+	}
+	if len(pkt) > len(shown) {
+		fmt.Printf("    (共 %d 字节，只显示前 %d 字节)\n", len(pkt), len(shown))
+	}
+}
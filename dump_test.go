@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestHexDump(t *testing.T) {
+	b := []byte("0123456789abcdef\x00\x01ping")
+	got := hexDump(b, 0x20)
+	want := []string{
+		"0020  30 31 32 33 34 35 36 37  38 39 61 62 63 64 65 66  |0123456789abcdef|",
+		"0030  00 01 70 69 6e 67                                 |..ping|",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("hexDump =\n%s\n应为\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if got := hexDump(nil, 0); len(got) != 0 {
+		t.Errorf("hexDump(nil) = %q", got)
+	}
+}
+
+// 带正确检验和的回显应答：IP头20字节，ICMP头8字节，数据n字节
+func dumpReply(n int) []byte {
+	pkt := make([]byte, 28+n)
+	copy(pkt, []byte{0x45, 0x10, 0, 0, 0x12, 0x34, 0x40, 0, 57, 1, 0, 0, 192, 0, 2, 1, 10, 0, 0, 2})
+	binary.BigEndian.PutUint16(pkt[2:4], uint16(len(pkt)))
+	sum, _ := checkSum(pkt[:20])
+	binary.BigEndian.PutUint16(pkt[10:12], sum)
+	icmp := pkt[20:]
+	binary.BigEndian.PutUint16(icmp[4:6], 0x0102)
+	binary.BigEndian.PutUint16(icmp[6:8], 7)
+	for i := range icmp[8:] {
+		icmp[8+i] = byte('a' + i%26)
+	}
+	sum, _ = checkSum(icmp)
+	binary.BigEndian.PutUint16(icmp[2:4], sum)
+	return pkt
+}
+
+func TestDecodeReplyFields(t *testing.T) {
+	pkt := dumpReply(32)
+	got := strings.Join(decodeReplyFields(pkt), "\n")
+	want := fmt.Sprintf("IP: 版本=4 头长度=20 TOS=0x10 总长度=60 标识=0x1234\n"+
+		"    标志=[DF] 片偏移=0 TTL=57 协议=1 检验和=0x%04x(正确)\n"+
+		"    源地址=192.0.2.1 目的地址=10.0.0.2\n"+
+		"ICMP: 类型=0 代码=0 检验和=0x%04x(正确) 标识=258 序号=7 数据=32 字节",
+		binary.BigEndian.Uint16(pkt[10:12]), binary.BigEndian.Uint16(pkt[22:24]))
+	if got != want {
+		t.Errorf("decodeReplyFields =\n%s\n应为\n%s", got, want)
+	}
+
+	pkt[len(pkt)-1] ^= 0xff //数据损坏
+	if got := decodeReplyFields(pkt); !strings.Contains(got[3], "(错误)") || !strings.Contains(got[1], "(正确)") {
+		t.Errorf("ICMP检验和错误时:\n%s", strings.Join(got, "\n"))
+	}
+
+	tests := []struct {
+		pkt  []byte
+		want string
+	}{
+		{pkt[:19], "报文过短: 19 字节"},
+		{append([]byte{0x4f}, pkt[1:30]...), "IP头长度 60 非法"},
+		{pkt[:24], "ICMP: 报文过短: 4 字节"},
+	}
+	for _, tt := range tests {
+		if got := decodeReplyFields(tt.pkt); got[len(got)-1] != tt.want {
+			t.Errorf("decodeReplyFields(% x) = %q，应以 %q 结束", tt.pkt, got, tt.want)
+		}
+	}
+}
+
+// 按IP头、ICMP头、数据分段输出，超出limit的部分省略
+func TestPrintReplyDump(t *testing.T) {
+	pkt := dumpReply(32)
+	stdout, _ := captureOutput(t, func() { printReplyDump(pkt, 256) })
+	for _, want := range []string{
+		"    IP头:\n      0000  45 10 00 3c",
+		"    ICMP头:\n      0014  00 00 ",
+		"    数据:\n      001c  61 62 63 64",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("输出中没有 %q:\n%s", want, stdout)
+		}
+	}
+	if strings.Contains(stdout, "只显示前") {
+		t.Errorf("未超出限制时不应提示:\n%s", stdout)
+	}
+
+	stdout, _ = captureOutput(t, func() { printReplyDump(pkt, 24) })
+	if strings.Contains(stdout, "数据:") || !strings.Contains(stdout, "    ICMP头:\n      0014  00 00 ") || !strings.HasSuffix(stdout, "    (共 60 字节，只显示前 24 字节)\n") {
+		t.Errorf("只显示24字节时:\n%s", stdout)
+	}
+}
+
+// -vv 同时开启 -v
+func TestDumpFlags(t *testing.T) {
+	parseArgs(t, "-vv", "-dump-max", "64", "192.0.2.1")
+	if !verbose || !veryVerbose || dumpMax != 64 {
+		t.Errorf("verbose = %v, veryVerbose = %v, dumpMax = %d", verbose, veryVerbose, dumpMax)
+	}
+	if errs := argErrors(t, "-vv", "-dump-max", "0", "192.0.2.1"); !hasArgError(errs, "-dump-max: 无效的取值 0") {
+		t.Errorf("错误 = %q", errs)
+	}
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/perf"
+	"github.com/cilium/ebpf/rlimit"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	xdpPerCPUBuffer = 64 << 10 //每个CPU的perf环形缓冲区大小
+	xdpReplyQueue   = 4        //每个目标缓存的应答数，读取不及时时丢弃
+	xdpDrop         = 1        //XDP_DROP
+	xdpPass         = 2        //XDP_PASS
+)
+
+// 构造XDP程序，把与本进程有关的报文经perf环形缓冲区(PERF_EVENT_ARRAY)交给用户态：
+// ID匹配的回显应答(type 0)交出后丢弃，不再经过内核的ICMP及socket处理；
+// 引用的原始报文是本进程回显请求(ID匹配)的ICMP差错(type 3、5、11、12)交出后仍交给内核(如更新路径MTU)
+// 其余报文原样交给内核。只处理不带VLAN标签的以太网帧中的IPv4报文
+// 每个样本为4字节的帧长度(本机字节序)及其后的以太网帧，帧长度按IP头中的总长度计算
+func xdpEchoProgram(id uint16, events *ebpf.Map) asm.Instructions {
+	return asm.Instructions{
+		asm.Mov.Reg(asm.R6, asm.R1),
+		asm.LoadMem(asm.R2, asm.R6, 0, asm.Word), //data
+		asm.LoadMem(asm.R3, asm.R6, 4, asm.Word), //data_end
+		//以太网头、最短的IP头及ICMP头
+		asm.Mov.Reg(asm.R4, asm.R2),
+		asm.Add.Imm(asm.R4, ethHeaderLen+20+8),
+		asm.JGT.Reg(asm.R4, asm.R3, "pass"),
+		//EtherType == IPv4 且协议 == ICMP
+		asm.LoadMem(asm.R0, asm.R2, 12, asm.Byte),
+		asm.JNE.Imm(asm.R0, 0x08, "pass"),
+		asm.LoadMem(asm.R0, asm.R2, 13, asm.Byte),
+		asm.JNE.Imm(asm.R0, 0x00, "pass"),
+		asm.LoadMem(asm.R0, asm.R2, ethHeaderLen+9, asm.Byte),
+		asm.JNE.Imm(asm.R0, syscall.IPPROTO_ICMP, "pass"),
+		//R7 = ICMP头
+		asm.LoadMem(asm.R0, asm.R2, ethHeaderLen, asm.Byte),
+		asm.And.Imm(asm.R0, 0xf),
+		asm.LSh.Imm(asm.R0, 2),
+		asm.Mov.Reg(asm.R7, asm.R2),
+		asm.Add.Imm(asm.R7, ethHeaderLen),
+		asm.Add.Reg(asm.R7, asm.R0),
+		asm.Mov.Reg(asm.R4, asm.R7),
+		asm.Add.Imm(asm.R4, 8),
+		asm.JGT.Reg(asm.R4, asm.R3, "pass"),
+		//ICMP type
+		asm.LoadMem(asm.R0, asm.R7, 0, asm.Byte),
+		asm.JEq.Imm(asm.R0, 0, "reply"),
+		asm.JEq.Imm(asm.R0, 3, "error"),
+		asm.JEq.Imm(asm.R0, 5, "error"),
+		asm.JEq.Imm(asm.R0, 11, "error"),
+		asm.JEq.Imm(asm.R0, 12, "error"),
+		asm.Ja.Label("pass"),
+		//回显应答：R8 = 应答的ICMP头，交出后丢弃
+		asm.Mov.Reg(asm.R8, asm.R7).WithSymbol("reply"),
+		asm.Mov.Imm(asm.R9, xdpDrop),
+		asm.Ja.Label("match"),
+		//ICMP差错：引用的IP头中协议 == ICMP，R8 = 引用的ICMP头，且为回显请求(type 8)，交出后仍交给内核
+		asm.Mov.Reg(asm.R4, asm.R7).WithSymbol("error"),
+		asm.Add.Imm(asm.R4, 8+20),
+		asm.JGT.Reg(asm.R4, asm.R3, "pass"),
+		asm.LoadMem(asm.R0, asm.R7, 8+9, asm.Byte),
+		asm.JNE.Imm(asm.R0, syscall.IPPROTO_ICMP, "pass"),
+		asm.LoadMem(asm.R0, asm.R7, 8, asm.Byte),
+		asm.And.Imm(asm.R0, 0xf),
+		asm.LSh.Imm(asm.R0, 2),
+		asm.Mov.Reg(asm.R8, asm.R7),
+		asm.Add.Imm(asm.R8, 8),
+		asm.Add.Reg(asm.R8, asm.R0),
+		asm.Mov.Reg(asm.R4, asm.R8),
+		asm.Add.Imm(asm.R4, 8),
+		asm.JGT.Reg(asm.R4, asm.R3, "pass"),
+		asm.LoadMem(asm.R0, asm.R8, 0, asm.Byte),
+		asm.JNE.Imm(asm.R0, 8, "pass"),
+		asm.Mov.Imm(asm.R9, xdpPass),
+		//R8处的ID == id (网络字节序)
+		asm.LoadMem(asm.R0, asm.R8, 4, asm.Byte).WithSymbol("match"),
+		asm.LSh.Imm(asm.R0, 8),
+		asm.LoadMem(asm.R1, asm.R8, 5, asm.Byte),
+		asm.Or.Reg(asm.R0, asm.R1),
+		asm.JNE.Imm(asm.R0, int32(id), "pass"),
+		//帧长度 = 以太网头 + IP总长度，保存在栈上作为样本的开头
+		asm.LoadMem(asm.R0, asm.R2, ethHeaderLen+2, asm.Byte),
+		asm.LSh.Imm(asm.R0, 8),
+		asm.LoadMem(asm.R1, asm.R2, ethHeaderLen+3, asm.Byte),
+		asm.Or.Reg(asm.R0, asm.R1),
+		asm.Add.Imm(asm.R0, ethHeaderLen),
+		asm.StoreMem(asm.RFP, -8, asm.R0, asm.Word),
+		//bpf_perf_event_output(ctx, events, BPF_F_CURRENT_CPU | 帧长度<<32, &帧长度, 4)，
+		//标志的高32位为从报文开头复制的字节数，超过实际长度时不输出
+		asm.LSh.Imm(asm.R0, 32),
+		asm.LoadImm(asm.R3, unix.BPF_F_CURRENT_CPU, asm.DWord),
+		asm.Or.Reg(asm.R3, asm.R0),
+		asm.Mov.Reg(asm.R1, asm.R6),
+		asm.LoadMapPtr(asm.R2, events.FD()),
+		asm.Mov.Reg(asm.R4, asm.RFP),
+		asm.Add.Imm(asm.R4, -8),
+		asm.Mov.Imm(asm.R5, 4),
+		asm.FnPerfEventOutput.Call(),
+		asm.Mov.Reg(asm.R0, asm.R9),
+		asm.Return(),
+		//其余报文交给内核
+		asm.Mov.Imm(asm.R0, xdpPass).WithSymbol("pass"),
+		asm.Return(),
+	}
+}
+
+// xdpReceiver 一个接口上挂载的XDP程序及读取perf环形缓冲区的goroutine，
+// 同一接口上的所有目标共用，按回复者(差错按引用的目标地址)分发给各目标
+type xdpReceiver struct {
+	ifindex int
+	events  *ebpf.Map
+	prog    *ebpf.Program
+	link    link.Link
+	reader  *perf.Reader
+	done    chan struct{}
+
+	mu    sync.Mutex
+	conns map[*xdpConn]struct{}
+}
+
+var (
+	xdpMu        sync.Mutex
+	xdpReceivers = map[int]*xdpReceiver{} //key为接口序号
+)
+
+// 把c登记到接口上的XDP接收，没有时加载程序并挂载
+// 加载需要CAP_BPF(或CAP_SYS_ADMIN)，挂载需要CAP_NET_ADMIN及5.9以上内核(bpf_link)，
+// 接口上已有其他XDP程序时也会失败
+func acquireXDPReceiver(ifindex int, c *xdpConn) (*xdpReceiver, error) {
+	xdpMu.Lock()
+	defer xdpMu.Unlock()
+	if r := xdpReceivers[ifindex]; r != nil {
+		r.add(c)
+		return r, nil
+	}
+
+	rlimit.RemoveMemlock()                                               //5.11之前的内核按RLIMIT_MEMLOCK计算eBPF内存，失败时由加载报告
+	events, err := ebpf.NewMap(&ebpf.MapSpec{Type: ebpf.PerfEventArray}) //每个CPU一项
+	if err != nil {
+		return nil, err
+	}
+	r := &xdpReceiver{ifindex: ifindex, events: events, done: make(chan struct{}), conns: map[*xdpConn]struct{}{}}
+	r.prog, err = ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:         ebpf.XDP,
+		Instructions: xdpEchoProgram(echoID, events),
+		License:      "GPL", //bpf_perf_event_output只能由GPL兼容的程序调用
+	})
+	if err == nil {
+		r.link, err = attachXDP(r.prog, ifindex)
+	}
+	if err == nil {
+		r.reader, err = perf.NewReader(events, xdpPerCPUBuffer)
+	}
+	if err != nil {
+		r.close()
+		return nil, err
+	}
+	xdpReceivers[ifindex] = r
+	r.add(c)
+	go r.receive()
+	return r, nil
+}
+
+// 以驱动模式挂载，驱动支持XDP但挂载失败(如virtio队列不足)时改用通用模式
+// 驱动不支持XDP时内核直接使用通用模式
+func attachXDP(prog *ebpf.Program, ifindex int) (link.Link, error) {
+	l, err := link.AttachXDP(link.XDPOptions{Program: prog, Interface: ifindex})
+	if err != nil {
+		l, err = link.AttachXDP(link.XDPOptions{Program: prog, Interface: ifindex, Flags: link.XDPGenericMode})
+	}
+	if err != nil {
+		return nil, err //不返回值为nil的*RawLink，close按接口是否为nil判断
+	}
+	return l, nil
+}
+
+func (r *xdpReceiver) add(c *xdpConn) {
+	r.mu.Lock()
+	r.conns[c] = struct{}{}
+	r.mu.Unlock()
+}
+
+// 卸载程序并结束读取，调用方需持有xdpMu
+func (r *xdpReceiver) close() {
+	if r.link != nil {
+		r.link.Close()
+	}
+	if r.reader != nil {
+		r.reader.Close() //结束receive中阻塞的Read
+		<-r.done
+	}
+	if r.prog != nil {
+		r.prog.Close()
+	}
+	r.events.Close()
+}
+
+// 目标关闭连接，最后一个目标关闭时卸载
+func (r *xdpReceiver) release(c *xdpConn) {
+	xdpMu.Lock()
+	defer xdpMu.Unlock()
+	r.mu.Lock()
+	delete(r.conns, c)
+	last := len(r.conns) == 0
+	r.mu.Unlock()
+	if last {
+		delete(xdpReceivers, r.ifindex)
+		r.close()
+	}
+}
+
+// 读取循环：环形缓冲区满时丢失的样本(LostSamples)不可恢复，按超时处理
+func (r *xdpReceiver) receive() {
+	defer close(r.done)
+	for {
+		rec, err := r.reader.Read()
+		if err != nil {
+			if errors.Is(err, perf.ErrClosed) {
+				return
+			}
+			continue
+		}
+		if pkt, ok := xdpSamplePacket(rec.RawSample); ok {
+			r.dispatch(pkt)
+		}
+	}
+}
+
+// 从样本中取出IP报文，去掉开头的帧长度、以太网头及样本末尾的填充
+func xdpSamplePacket(sample []byte) ([]byte, bool) {
+	if len(sample) < 4 {
+		return nil, false
+	}
+	n := int(binary.NativeEndian.Uint32(sample))
+	if n < ethHeaderLen || len(sample) < 4+n {
+		return nil, false
+	}
+	return sample[4+ethHeaderLen : 4+n], true
+}
+
+// 把应答交给目标地址相同的各目标，同一地址出现多次时每个目标都收到
+func (r *xdpReceiver) dispatch(pkt []byte) {
+	k, _, ok := echoKeyOf(pkt)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for c := range r.conns {
+		if c.ip != k.ip {
+			continue
+		}
+		select {
+		case c.replies <- pkt: //Read只复制，多个目标可以共用
+		default:
+		}
+	}
+}
+
+// xdpConn 以原始套接字发送、从XDP接收应答的连接，实现net.Conn，Pinger.Run无需区分
+// 原始套接字上挂载了丢弃所有报文的过滤程序，接收队列不会堆积
+type xdpConn struct {
+	net.Conn //原始套接字，用于发送、设置选项及写截止时间
+	r        *xdpReceiver
+	ip       [4]byte
+
+	replies chan []byte
+	wake    chan struct{} //读截止时间改变时唤醒Read
+	closed  chan struct{}
+	once    sync.Once
+
+	mu           sync.Mutex
+	readDeadline time.Time
+}
+
+// 在目标的出口接口上挂载XDP程序，应答改由perf环形缓冲区交付，失败时返回错误，调用方改用标准socket
+// 应答从出口接口到达(路由对称)时才能收到
+func newXDPConn(conn net.Conn) (net.Conn, error) {
+	raddr, ok := conn.RemoteAddr().(*net.IPAddr)
+	if !ok || raddr.IP.To4() == nil {
+		return nil, errors.New("不是IPv4原始套接字")
+	}
+	iface, err := outgoingInterface(raddr.IP.String())
+	if err != nil {
+		return nil, err
+	}
+	c := &xdpConn{
+		Conn:    conn,
+		replies: make(chan []byte, xdpReplyQueue),
+		wake:    make(chan struct{}, 1),
+		closed:  make(chan struct{}),
+	}
+	copy(c.ip[:], raddr.IP.To4())
+	if c.r, err = acquireXDPReceiver(iface.Index, c); err != nil {
+		return nil, fmt.Errorf("无法在接口 %s 上挂载XDP程序: %v", iface.Name, err)
+	}
+	if err := discardSocketInput(conn); err != nil {
+		c.r.release(c)
+		return nil, err
+	}
+	return c, nil
+}
+
+// 以SO_ATTACH_FILTER挂载只有一条"返回0"指令的经典BPF程序，套接字不再接收任何报文
+func discardSocketInput(conn net.Conn) error {
+	raw, err := syscallConnOf(conn)
+	if err != nil {
+		return err
+	}
+	drop := []unix.SockFilter{{Code: unix.BPF_RET | unix.BPF_K, K: 0}}
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = unix.SetsockoptSockFprog(int(fd), unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &unix.SockFprog{Len: 1, Filter: &drop[0]})
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+func (c *xdpConn) Read(b []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		deadline := c.readDeadline
+		c.mu.Unlock()
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if !deadline.IsZero() {
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+		select {
+		case pkt := <-c.replies:
+			if timer != nil {
+				timer.Stop()
+			}
+			return copy(b, pkt), nil
+		case <-expired:
+			return 0, os.ErrDeadlineExceeded
+		case <-c.wake:
+			if timer != nil {
+				timer.Stop()
+			}
+		case <-c.closed:
+			if timer != nil {
+				timer.Stop()
+			}
+			return 0, net.ErrClosed
+		}
+	}
+}
+
+// 关闭原始套接字，最后一个目标关闭时卸载XDP程序
+func (c *xdpConn) Close() error {
+	var err error
+	c.once.Do(func() {
+		close(c.closed)
+		c.r.release(c)
+		err = c.Conn.Close()
+	})
+	return err
+}
+
+func (c *xdpConn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	return c.Conn.SetWriteDeadline(t)
+}
+
+func (c *xdpConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
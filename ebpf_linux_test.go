@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/perf"
+)
+
+// 加载XDP程序及其perf事件数组，需要CAP_BPF，不能加载时跳过
+func loadXDPEcho(t *testing.T, id uint16) (*ebpf.Program, *perf.Reader) {
+	t.Helper()
+	events, err := ebpf.NewMap(&ebpf.MapSpec{Type: ebpf.PerfEventArray})
+	if err != nil {
+		t.Skipf("无法创建perf事件数组: %v", err)
+	}
+	t.Cleanup(func() { events.Close() })
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{Type: ebpf.XDP, Instructions: xdpEchoProgram(id, events), License: "GPL"})
+	if err != nil {
+		t.Skipf("无法加载XDP程序: %v", err)
+	}
+	t.Cleanup(func() { prog.Close() })
+	rd, err := perf.NewReader(events, xdpPerCPUBuffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rd.Close() })
+	return prog, rd
+}
+
+// 以太网帧：回显应答，来自192.0.2.1，ID为id
+func xdpEchoFrame(id uint16) []byte {
+	frame := make([]byte, ethHeaderLen+20+8+32)
+	binary.BigEndian.PutUint16(frame[12:14], 0x0800)
+	ip := frame[ethHeaderLen:]
+	ip[0], ip[8], ip[9] = 0x45, 64, 1
+	binary.BigEndian.PutUint16(ip[2:4], uint16(len(ip)))
+	copy(ip[12:16], net.IPv4(192, 0, 2, 1).To4())
+	binary.BigEndian.PutUint16(ip[20+4:20+6], id)
+	binary.BigEndian.PutUint16(ip[20+6:20+8], 1)
+	return frame
+}
+
+// 以太网帧：路由器192.0.2.254返回的差错，引用了发往192.0.2.1的回显请求(ID为id)，引用的IP头带有一个4字节的选项
+func xdpErrorFrame(typ, proto byte, id uint16) []byte {
+	frame := make([]byte, ethHeaderLen+20+8+24+8)
+	binary.BigEndian.PutUint16(frame[12:14], 0x0800)
+	ip := frame[ethHeaderLen:]
+	ip[0], ip[8], ip[9] = 0x45, 64, 1
+	binary.BigEndian.PutUint16(ip[2:4], uint16(len(ip)))
+	copy(ip[12:16], net.IPv4(192, 0, 2, 254).To4())
+	ip[20], ip[21] = typ, 1
+	inner := ip[28:]
+	inner[0], inner[8], inner[9] = 0x46, 64, proto
+	copy(inner[16:20], net.IPv4(192, 0, 2, 1).To4())
+	inner[24] = 8
+	binary.BigEndian.PutUint16(inner[28:30], id)
+	return frame
+}
+
+// ID匹配的回显应答交给用户态后丢弃，引用本进程请求的ICMP差错交给用户态后仍交给内核，
+// 其他ID的应答及差错、其他类型及不是IPv4的帧原样交给内核
+func TestXDPEchoProgram(t *testing.T) {
+	needRawSocket(t)
+	prog, rd := loadXDPEcho(t, echoID)
+	arp := xdpEchoFrame(echoID)
+	binary.BigEndian.PutUint16(arp[12:14], 0x0806)
+	tests := []struct {
+		name   string
+		frame  []byte
+		action uint32
+		sample bool
+	}{
+		{"本进程的ID", xdpEchoFrame(echoID), xdpDrop, true},
+		{"其他ID", xdpEchoFrame(echoID + 1), xdpPass, false},
+		{"目标不可达", xdpErrorFrame(3, 1, echoID), xdpPass, true},
+		{"TTL超时", xdpErrorFrame(11, 1, echoID), xdpPass, true},
+		{"其他ID的差错", xdpErrorFrame(3, 1, echoID+1), xdpPass, false},
+		{"引用的不是ICMP", xdpErrorFrame(3, 17, echoID), xdpPass, false},
+		{"其他类型", xdpErrorFrame(13, 1, echoID), xdpPass, false},
+		{"不是IPv4", arp, xdpPass, false},
+		{"过短", xdpEchoFrame(echoID)[:ethHeaderLen+20+4], xdpPass, false},
+	}
+	for _, tt := range tests {
+		action, _, err := prog.Test(tt.frame)
+		if err != nil {
+			t.Skipf("内核不支持BPF_PROG_TEST_RUN: %v", err)
+		}
+		if action != tt.action {
+			t.Errorf("%s: 返回 %d，期望 %d", tt.name, action, tt.action)
+		}
+
+		rd.SetDeadline(time.Now().Add(50 * time.Millisecond))
+		rec, err := rd.Read()
+		if !tt.sample {
+			if !errors.Is(err, os.ErrDeadlineExceeded) {
+				t.Errorf("%s: 不应交给用户态，读到 %v, %v", tt.name, rec, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		pkt, ok := xdpSamplePacket(rec.RawSample)
+		if !ok || string(pkt) != string(tt.frame[ethHeaderLen:]) {
+			t.Errorf("%s: 样本 % x，期望IP报文 % x", tt.name, rec.RawSample, tt.frame[ethHeaderLen:])
+		}
+	}
+}
+
+// 样本开头为帧长度，末尾可能有填充
+func TestXDPSamplePacket(t *testing.T) {
+	frame := xdpEchoFrame(1)
+	sample := binary.NativeEndian.AppendUint32(nil, uint32(len(frame)))
+	sample = append(append(sample, frame...), 0, 0, 0, 0)
+	if pkt, ok := xdpSamplePacket(sample); !ok || string(pkt) != string(frame[ethHeaderLen:]) {
+		t.Errorf("xdpSamplePacket = % x, %v", pkt, ok)
+	}
+	for _, bad := range [][]byte{nil, sample[:3], sample[:4+len(frame)-1], {1, 0, 0, 0}} {
+		if _, ok := xdpSamplePacket(bad); ok {
+			t.Errorf("% x: 应无效", bad)
+		}
+	}
+}
+
+// 在回环接口上挂载XDP程序后，应答经perf环形缓冲区交付；最后一个连接关闭时卸载
+func TestXDPConnLoopback(t *testing.T) {
+	needRawSocket(t)
+	conns := make([]net.Conn, 2)
+	for i, host := range []string{"127.0.0.1", "127.0.0.2"} {
+		conn, err := net.Dial("ip4:icmp", host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		xc, err := newXDPConn(conn)
+		if err != nil {
+			conn.Close()
+			t.Skipf("无法使用XDP: %v", err)
+		}
+		defer xc.Close()
+		conns[i] = xc
+	}
+	if len(xdpReceivers) != 1 {
+		t.Errorf("回环接口上挂载了 %d 次", len(xdpReceivers))
+	}
+
+	buf := make([]byte, 1500)
+	for i, conn := range conns {
+		data := make([]byte, 8+32)
+		if err := fillEcho(data, i+1); err != nil {
+			t.Fatal(err)
+		}
+		n, _, _, err := exchange(conn, nil, data, time.Second, buf, true)
+		if err != nil {
+			t.Fatalf("%s: %v", conn.RemoteAddr(), err)
+		}
+		r, err := parseEchoReply(buf[:n])
+		if err != nil || r.Seq != i+1 || r.Src.String() != conn.RemoteAddr().String() {
+			t.Fatalf("%s: 应答 = %+v, %v", conn.RemoteAddr(), r, err)
+		}
+	}
+
+	conns[0].Close()
+	conns[1].SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	if _, err := conns[1].Read(buf); !os.IsTimeout(err) {
+		t.Errorf("没有应答时 Read = %v", err)
+	}
+	conns[1].Close()
+	if len(xdpReceivers) != 0 {
+		t.Errorf("全部关闭后仍挂载在 %d 个接口上", len(xdpReceivers))
+	}
+}
+
+// 不是原始套接字时返回错误，不留下挂载的程序
+func TestNewXDPConnMock(t *testing.T) {
+	if _, err := newXDPConn(newMockConn()); err == nil {
+		t.Error("mockConn上使用XDP成功")
+	}
+	if len(xdpReceivers) != 0 {
+		t.Errorf("失败后仍挂载在 %d 个接口上", len(xdpReceivers))
+	}
+}
+
+// 应答不经socket，不能与依赖socket接收的 -iouring、-hw-ts 同时使用
+func TestEBPFFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		ok   bool
+	}{
+		{[]string{"-ebpf", "127.0.0.1"}, true},
+		{[]string{"-ebpf", "-iouring", "127.0.0.1"}, false},
+		{[]string{"-ebpf", "-hw-ts", "127.0.0.1"}, false},
+	}
+	for _, tt := range tests {
+		errs := argErrors(t, tt.args...)
+		if ok := !hasArgError(errs, "-ebpf"); ok != tt.ok {
+			t.Errorf("%v: %q", tt.args, errs)
+		}
+	}
+}
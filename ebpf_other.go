@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+// 以XDP程序接收应答，仅Linux支持
+func newXDPConn(conn net.Conn) (net.Conn, error) {
+	return nil, errors.New("当前平台不支持XDP")
+}
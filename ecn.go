@@ -0,0 +1,54 @@
+package main
+
+import "fmt"
+
+var ecnMode string //-ecn 发送报文的ECN标记：ect0 或 ect1
+
+// IP头TOS字段低2位的ECN代码点，见RFC 3168
+const (
+	ecnNotECT = 0
+	ecnECT1   = 1
+	ecnECT0   = 2
+	ecnCE     = 3
+)
+
+var ecnNames = [4]string{"Not-ECT", "ECT(1)", "ECT(0)", "CE"}
+
+// 解析 -ecn 参数，返回ECN代码点
+func parseECN(s string) (int, error) {
+	switch s {
+	case "ect0", "0":
+		return ecnECT0, nil
+	case "ect1", "1":
+		return ecnECT1, nil
+	}
+	return 0, fmt.Errorf("应为 ect0 或 ect1")
+}
+
+// 回复中ECN标记的统计
+type ecnStats struct {
+	sent  int    //发送的代码点
+	count [4]int //各代码点的回复数
+}
+
+// 记录一次回复的TOS字段，返回回复中的代码点
+func (e *ecnStats) observe(tos byte) int {
+	cp := int(tos & 0x03)
+	e.count[cp]++
+	return cp
+}
+
+// 被标记为CE的回复数，非0说明路径上有主动队列管理(如RED、CoDel)在丢包前以标记报文通告拥塞
+func (e *ecnStats) congested() int {
+	return e.count[ecnCE]
+}
+
+// 汇总：标记保留、被清除(Not-ECT)、被改写为另一种ECT、被标记为CE的次数
+func (e *ecnStats) summary() string {
+	other := ecnECT1
+	if e.sent == ecnECT1 {
+		other = ecnECT0
+	}
+	return fmt.Sprintf("ECN(发送 %s): 保留 %d 次，被清除为Not-ECT %d 次，被改写为 %s %d 次，CE %d 次",
+		ecnNames[e.sent], e.count[e.sent], e.count[ecnNotECT], ecnNames[other], e.count[other], e.count[ecnCE])
+}
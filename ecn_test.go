@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseECN(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		err  bool
+	}{
+		{"ect0", ecnECT0, false},
+		{"0", ecnECT0, false},
+		{"ect1", ecnECT1, false},
+		{"1", ecnECT1, false},
+		{"ce", 0, true}, //CE只能由路由器标记
+		{"ECT0", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseECN(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parseECN(%q) = %d, %v，期望 %d，出错 %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+	if errs := argErrors(t, "-ecn", "ce", "127.0.0.1"); !hasArgError(errs, "-ecn") {
+		t.Errorf("-ecn ce 没有报错: %q", errs)
+	}
+}
+
+// TOS字节的低2位为ECN，高6位的DSCP不影响结果
+func TestECNObserve(t *testing.T) {
+	for _, dscp := range []byte{0, 0xb8} { //默认、EF
+		var e ecnStats
+		for cp := 0; cp < 4; cp++ {
+			if got := e.observe(dscp | byte(cp)); got != cp {
+				t.Errorf("TOS %#02x: 代码点 = %d，期望 %d", dscp|byte(cp), got, cp)
+			}
+		}
+		if e.count != [4]int{1, 1, 1, 1} || e.congested() != 1 {
+			t.Errorf("DSCP %#02x: count = %v", dscp, e.count)
+		}
+	}
+}
+
+// 汇总中的"保留"及"改写"随发送的代码点而定
+func TestECNSummary(t *testing.T) {
+	tests := []struct {
+		sent  int
+		count [4]int
+		want  string
+	}{
+		{ecnECT0, [4]int{2, 1, 5, 0}, "ECN(发送 ECT(0)): 保留 5 次，被清除为Not-ECT 2 次，被改写为 ECT(1) 1 次，CE 0 次"},
+		{ecnECT1, [4]int{0, 3, 1, 2}, "ECN(发送 ECT(1)): 保留 3 次，被清除为Not-ECT 0 次，被改写为 ECT(0) 1 次，CE 2 次"},
+	}
+	for _, tt := range tests {
+		e := ecnStats{sent: tt.sent, count: tt.count}
+		if got := e.summary(); got != tt.want {
+			t.Errorf("summary() = %q\n期望 %q", got, tt.want)
+		}
+	}
+}
+
+// 有回复被标记为CE时统计信息中给出拥塞警告
+func TestECNCongestionWarning(t *testing.T) {
+	for _, ce := range []int{0, 3} {
+		p := &Pinger{Addr: "127.0.0.1", Stats: newStatistics(), ecn: &ecnStats{sent: ecnECT0, count: [4]int{0, 0, 4 - ce, ce}}}
+		for i := 0; i < 4; i++ {
+			p.Stats.addSent()
+			p.Stats.addSuccess(1)
+		}
+		stdout, _ := captureOutput(t, p.printSummary)
+		warned := strings.Contains(stdout, "警告: CONGESTION EXPERIENCED (3 个回复被标记为CE)")
+		if warned != (ce > 0) || ce == 0 && strings.Contains(stdout, "CONGESTION") {
+			t.Errorf("CE %d 次的输出:\n%s", ce, stdout)
+		}
+	}
+}
+
+// 回环地址的回显应答复制请求的TOS，发送的标记应原样返回
+func TestECNLoopback(t *testing.T) {
+	needRawSocket(t)
+	for _, mode := range []string{"ect0", "ect1"} {
+		parseArgs(t, "-n", "2", "-i", "0", "-v", "-ecn", mode, "127.0.0.1")
+		p := newPinger("127.0.0.1")
+		stdout, _ := captureOutput(t, p.Run)
+		if p.Err != nil || p.ecn == nil {
+			t.Fatalf("-ecn %s: %v", mode, p.Err)
+		}
+		cp, _ := parseECN(mode)
+		if p.ecn.count[cp] != 2 {
+			t.Errorf("-ecn %s: 各代码点的回复数 = %v", mode, p.ecn.count)
+		}
+		name := ecnNames[cp]
+		for _, want := range []string{"ECN: 发送 " + name + "，回复 " + name, "ECN(发送 " + name + "): 保留 2 次"} {
+			if !strings.Contains(stdout, want) {
+				t.Errorf("-ecn %s 的输出中没有 %q:\n%s", mode, want, stdout)
+			}
+		}
+	}
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+var noSharedSocket bool //-no-shared-socket 多目标并发时每个目标单独建立原始套接字
+
+const (
+	engineMaxInflight = 4096        //共享套接字上同时等待应答的请求数上限
+	engineExpireEvery = time.Second //清理已过期请求的间隔
+	engineNoDeadline  = time.Minute //调用方没有设置截止时间时请求的有效期
+	engineReplyQueue  = 4           //每个目标缓存的应答数，读取不及时时丢弃
+	engineRcvBuf      = 4 << 20     //共享套接字的接收缓冲区，数百个目标同时应答时默认大小会溢出
+)
+
+// 多目标并发探测时共享的原始套接字，为nil时每个目标单独建立连接
+var engine *icmpEngine
+
+// 在途请求的标识：应答的源地址及ICMP头中的ID、序号
+type echoKey struct {
+	ip      [4]byte
+	id, seq uint16
+}
+
+// 在途请求
+type inflightEcho struct {
+	conn    *engineConn
+	id      uint16    //调用方请求中原来的ID，交还应答前恢复
+	expires time.Time //超过后到达的应答按超时处理
+}
+
+// icmpEngine 以一个未连接的原始套接字向所有目标发送请求，由一个接收循环按
+// (源地址, ID, 序号) 把应答分发给各目标，文件描述符数量不随目标数增加
+// 各目标的Pinger仍各自统计，只把收发交给dial返回的engineConn
+type icmpEngine struct {
+	pc    *net.IPConn
+	slots chan struct{} //在途请求的名额，满时发送方等待
+
+	mu       sync.Mutex
+	inflight map[echoKey]inflightEcho
+	nextID   uint16 //分配给下一个目标的ID偏移
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// 建立共享套接字并启动接收及清理
+func newICMPEngine(maxInflight int) (*icmpEngine, error) {
+	pc, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return nil, err
+	}
+	pc.(*net.IPConn).SetReadBuffer(engineRcvBuf) //受net.core.rmem_max限制，设置失败时仍使用默认大小
+	e := &icmpEngine{
+		pc:       pc.(*net.IPConn),
+		slots:    make(chan struct{}, maxInflight),
+		inflight: map[echoKey]inflightEcho{},
+		done:     make(chan struct{}),
+	}
+	e.wg.Add(2)
+	go e.receive()
+	go e.expire()
+	return e, nil
+}
+
+// 关闭套接字，等待接收及清理结束
+func (e *icmpEngine) close() {
+	close(e.done)
+	e.pc.Close()
+	e.wg.Wait()
+}
+
+// 为目标建立模拟连接
+// 每个连接使用不同的ID，同一地址出现多次(重复的目标、解析到同一地址的主机名)时应答也不会混淆
+func (e *icmpEngine) dial(host string) (net.Conn, error) {
+	raddr, err := net.ResolveIPAddr("ip4", host)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.nextID++
+	id := echoID + e.nextID
+	e.mu.Unlock()
+
+	c := &engineConn{
+		e:       e,
+		raddr:   raddr,
+		id:      id,
+		replies: make(chan []byte, engineReplyQueue),
+		wake:    make(chan struct{}, 1),
+		closed:  make(chan struct{}),
+	}
+	copy(c.ip[:], raddr.IP.To4())
+	return c, nil
+}
+
+// 占用一个在途名额，到截止时间仍没有名额时返回错误
+func (e *icmpEngine) acquire(deadline time.Time) error {
+	select {
+	case e.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	var timer <-chan time.Time
+	if !deadline.IsZero() {
+		t := time.NewTimer(time.Until(deadline))
+		defer t.Stop()
+		timer = t.C
+	}
+	select {
+	case e.slots <- struct{}{}:
+		return nil
+	case <-timer:
+		return errors.New("共享套接字上等待应答的请求过多")
+	case <-e.done:
+		return net.ErrClosed
+	}
+}
+
+// 归还n个在途名额
+func (e *icmpEngine) release(n int) {
+	for ; n > 0; n-- {
+		<-e.slots
+	}
+}
+
+// 登记在途请求，同一标识的旧请求(序号回绕)被替换时归还其名额
+func (e *icmpEngine) register(k echoKey, req inflightEcho) {
+	e.mu.Lock()
+	_, replaced := e.inflight[k]
+	e.inflight[k] = req
+	e.mu.Unlock()
+	if replaced {
+		e.release(1)
+	}
+}
+
+// 取出并删除在途请求
+func (e *icmpEngine) take(k echoKey) (inflightEcho, bool) {
+	e.mu.Lock()
+	req, ok := e.inflight[k]
+	if ok {
+		delete(e.inflight, k)
+	}
+	e.mu.Unlock()
+	if ok {
+		e.release(1)
+	}
+	return req, ok
+}
+
+// 调用方等待超时后立即删除已过期的请求并归还名额，不必等到下一次清理
+func (e *icmpEngine) forget(k echoKey, c *engineConn, now time.Time) {
+	e.mu.Lock()
+	req, ok := e.inflight[k]
+	if ok = ok && req.conn == c && !req.expires.After(now); ok {
+		delete(e.inflight, k)
+	}
+	e.mu.Unlock()
+	if ok {
+		e.release(1)
+	}
+}
+
+// 删除满足条件的在途请求
+func (e *icmpEngine) drop(match func(inflightEcho) bool) {
+	n := 0
+	e.mu.Lock()
+	for k, req := range e.inflight {
+		if match(req) {
+			delete(e.inflight, k)
+			n++
+		}
+	}
+	e.mu.Unlock()
+	e.release(n)
+}
+
+// 接收循环：ReadMsgIP保留IP头(ReadFrom会去掉)，与连接的原始套接字读到的报文相同
+func (e *icmpEngine) receive() {
+	defer e.wg.Done()
+	buf := make([]byte, 1<<16)
+	for {
+		n, _, _, _, err := e.pc.ReadMsgIP(buf, nil)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		e.deliver(buf[:n])
+	}
+}
+
+// 报文对应的在途请求及其中回显报文头的偏移：回显应答为应答本身，ICMP差错(目标不可达、
+// 源抑制、重定向、超时、参数问题)为其引用的回显请求，标识取原始请求的目标地址、ID及序号
+func echoKeyOf(pkt []byte) (k echoKey, off int, ok bool) {
+	if checkIPv4Header(pkt) != nil {
+		return k, 0, false
+	}
+	ihl := int(pkt[0]&0x0f) * 4
+	if len(pkt) < ihl+8 {
+		return k, 0, false
+	}
+	switch pkt[ihl] {
+	case 0:
+		copy(k.ip[:], pkt[12:16])
+		off = ihl
+	case 3, 4, 5, 11, 12:
+		inner := pkt[ihl+8:] //差错报文的数据部分为原始IP头及其后至少8字节
+		if len(inner) < 20 || inner[9] != 1 {
+			return k, 0, false
+		}
+		innerIHL := int(inner[0]&0x0f) * 4
+		if innerIHL < 20 || len(inner) < innerIHL+8 || inner[innerIHL] != 8 {
+			return k, 0, false
+		}
+		copy(k.ip[:], inner[16:20])
+		off = ihl + 8 + innerIHL
+	default:
+		return k, 0, false
+	}
+	k.id = binary.BigEndian.Uint16(pkt[off+4 : off+6])
+	k.seq = binary.BigEndian.Uint16(pkt[off+6 : off+8])
+	return k, off, true
+}
+
+// 把回显应答及引用请求的ICMP差错交给发出请求的目标，差错由调用方按icmp_error报告
+// 其他报文及找不到请求的应答(重复、迟到)丢弃
+func (e *icmpEngine) deliver(pkt []byte) {
+	k, off, ok := echoKeyOf(pkt)
+	if !ok {
+		return
+	}
+	req, ok := e.take(k)
+	if !ok || time.Now().After(req.expires) {
+		return
+	}
+
+	//恢复调用方的ID并增量调整检验和，原来错误的检验和调整后仍然错误
+	//差错报文中改的是引用的请求，ID与其检验和的和不变，外层检验和仍然正确
+	reply := append([]byte(nil), pkt...)
+	echo := reply[off:]
+	sum := adjustCheckSum(binary.BigEndian.Uint16(echo[2:4]), k.id, req.id)
+	binary.BigEndian.PutUint16(echo[2:4], sum)
+	binary.BigEndian.PutUint16(echo[4:6], req.id)
+	select {
+	case req.conn.replies <- reply:
+	default:
+	}
+}
+
+// 定期删除已过期的请求，调用方超时后不再等待的请求由这里归还名额
+func (e *icmpEngine) expire() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(engineExpireEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.done:
+			return
+		case now := <-ticker.C:
+			e.drop(func(req inflightEcho) bool { return now.After(req.expires) })
+		}
+	}
+}
+
+// engineConn 共享套接字上与一个目标的模拟连接，实现net.Conn，Pinger.Run无需区分
+type engineConn struct {
+	e     *icmpEngine
+	raddr *net.IPAddr
+	ip    [4]byte
+	id    uint16 //发送时替换请求中的ID
+
+	replies chan []byte   //分发给该目标的应答
+	wake    chan struct{} //截止时间改变时唤醒Read
+	closed  chan struct{}
+	once    sync.Once
+	out     []byte  //发送缓冲区，Write在同一目标上不会并发调用
+	last    echoKey //最近一次发送的请求，Read超时时删除
+
+	mu            sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+func (c *engineConn) Read(b []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		deadline := c.readDeadline
+		c.mu.Unlock()
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if !deadline.IsZero() {
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				return 0, c.timedOut()
+			}
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+		select {
+		case pkt := <-c.replies:
+			if !c.current(pkt) {
+				if timer != nil {
+					timer.Stop()
+				}
+				continue //上一次请求超时后才分发的应答
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			return copy(b, pkt), nil
+		case <-expired:
+			return 0, c.timedOut()
+		case <-c.wake:
+			if timer != nil {
+				timer.Stop()
+			}
+		case <-c.closed:
+			if timer != nil {
+				timer.Stop()
+			}
+			return 0, net.ErrClosed
+		}
+	}
+}
+
+// 以该连接的ID发送回显请求，并登记为在途请求
+func (c *engineConn) Write(b []byte) (int, error) {
+	if len(b) < 8 {
+		return 0, errors.New("ICMP报文过短")
+	}
+	c.mu.Lock()
+	writeDeadline, readDeadline := c.writeDeadline, c.readDeadline
+	c.mu.Unlock()
+	if err := c.e.acquire(writeDeadline); err != nil {
+		return 0, err
+	}
+
+	c.out = append(c.out[:0], b...)
+	id := binary.BigEndian.Uint16(c.out[4:6])
+	sum := adjustCheckSum(binary.BigEndian.Uint16(c.out[2:4]), id, c.id)
+	binary.BigEndian.PutUint16(c.out[2:4], sum)
+	binary.BigEndian.PutUint16(c.out[4:6], c.id)
+
+	expires := readDeadline
+	if expires.IsZero() {
+		expires = time.Now().Add(engineNoDeadline)
+	}
+	k := echoKey{ip: c.ip, id: c.id, seq: binary.BigEndian.Uint16(c.out[6:8])}
+	c.mu.Lock()
+	c.last = k
+	c.mu.Unlock()
+	c.e.register(k, inflightEcho{conn: c, id: id, expires: expires})
+	if _, err := c.e.pc.WriteTo(c.out, c.raddr); err != nil {
+		c.e.take(k)
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// 关闭连接，删除该目标的在途请求，共享套接字不关闭
+func (c *engineConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+		c.e.drop(func(req inflightEcho) bool { return req.conn == c })
+	})
+	return nil
+}
+
+// Read超过截止时间：最近一次请求已过期时归还其名额，丢弃已分发但未读取的应答，
+// 否则下一次请求会把它当作自己的应答
+func (c *engineConn) timedOut() error {
+	c.mu.Lock()
+	k := c.last
+	c.mu.Unlock()
+	c.e.forget(k, c, time.Now())
+	for {
+		select {
+		case <-c.replies:
+		default:
+			return os.ErrDeadlineExceeded
+		}
+	}
+}
+
+// 应答或差错是否属于最近一次发送的请求，分发与Read超时同时发生时队列中可能留有上一次的应答
+func (c *engineConn) current(pkt []byte) bool {
+	k, _, _ := echoKeyOf(pkt) //deliver已校验
+	c.mu.Lock()
+	seq := c.last.seq
+	c.mu.Unlock()
+	return k.seq == seq
+}
+
+func (c *engineConn) LocalAddr() net.Addr {
+	return c.e.pc.LocalAddr()
+}
+
+func (c *engineConn) RemoteAddr() net.Addr {
+	return c.raddr
+}
+
+func (c *engineConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline, c.writeDeadline = t, t
+	c.mu.Unlock()
+	c.wakeReader()
+	return nil
+}
+
+func (c *engineConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	c.wakeReader()
+	return nil
+}
+
+func (c *engineConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.writeDeadline = t
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *engineConn) wakeReader() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// 是否可以使用共享套接字：多个目标，且没有指定需要对每个套接字单独设置的参数
+// (源路由、TTL、接收缓冲区、ECN、防火墙标记、优先级)或依赖连接的收发方式
+// (XDP、io_uring、时间戳)，-drop-privs 时套接字在放弃权限前已建立
+func useSharedSocket(targets int) bool {
+	return targets > 1 && sharedSocketSupported && !noSharedSocket &&
+		len(sourceRoute) == 0 && sendTTL == 0 && rcvBuf == 0 && ecnMode == "" &&
+		fwMark == 0 && priority < 0 && !useEBPF && !useIOUring && !hwTS && dropPrivs == ""
+}
+
+// 多目标并发探测前建立共享套接字，返回结束时调用的清理函数
+// 无法建立时(如权限不足)各目标仍单独建立连接，错误由各目标分别报告
+func startSharedSocket(targets int) func() {
+	if !useSharedSocket(targets) {
+		return func() {}
+	}
+	e, err := newICMPEngine(engineMaxInflight)
+	if err != nil {
+		return func() {}
+	}
+	engine = e
+	return func() {
+		engine = nil
+		e.close()
+	}
+}
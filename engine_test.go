@@ -0,0 +1,389 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 当前进程打开的文件描述符数，无法统计时跳过
+func openFDs(tb testing.TB) int {
+	tb.Helper()
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		tb.Skipf("无法统计文件描述符: %v", err)
+	}
+	return len(entries)
+}
+
+// 第i个目标的回环地址，127.0.0.0/8 内的地址都由本机应答
+func loopbackTarget(i int) string {
+	return fmt.Sprintf("127.0.%d.%d", i/250, i%250+1)
+}
+
+func newTestEngine(tb testing.TB, maxInflight int) *icmpEngine {
+	tb.Helper()
+	needRawSocket(tb)
+	e, err := newICMPEngine(maxInflight)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(e.close)
+	return e
+}
+
+// 经共享套接字探测一次，检查应答属于该目标且ID已恢复
+func engineExchange(conn net.Conn, seq int) error {
+	data := make([]byte, 8+32)
+	if err := fillEcho(data, seq); err != nil {
+		return err
+	}
+	buf := make([]byte, 1500)
+	n, _, _, err := exchange(conn, nil, data, 3*time.Second, buf, true)
+	if err != nil {
+		return err
+	}
+	r, err := parseEchoReply(buf[:n])
+	if err != nil {
+		return err
+	}
+	ihl := int(buf[0]&0x0f) * 4
+	if id := binary.BigEndian.Uint16(buf[ihl+4 : ihl+6]); id != echoID {
+		return fmt.Errorf("应答ID = %d，应为 %d", id, echoID)
+	}
+	if r.Seq != seq || r.Src.String() != conn.RemoteAddr().String() {
+		return fmt.Errorf("应答 = %+v，应来自 %s 序号 %d", r, conn.RemoteAddr(), seq)
+	}
+	return nil
+}
+
+// 数百个目标并发收发，应答按(源地址, ID, 序号)分发给各自的目标，结束后不留在途请求
+func TestEngineConcurrentTargets(t *testing.T) {
+	const targets, rounds = 300, 3
+	e := newTestEngine(t, engineMaxInflight)
+	var wg sync.WaitGroup
+	errs := make(chan error, targets)
+	for i := 0; i < targets; i++ {
+		conn, err := e.dial(loopbackTarget(i % 100)) //同一地址由多个目标共用，靠ID区分
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(conn net.Conn) {
+			defer wg.Done()
+			defer conn.Close()
+			for seq := 1; seq <= rounds; seq++ {
+				if err := engineExchange(conn, seq); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(conn)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	e.mu.Lock()
+	left := len(e.inflight)
+	e.mu.Unlock()
+	if left != 0 || len(e.slots) != 0 {
+		t.Fatalf("结束后在途请求 %d，占用名额 %d", left, len(e.slots))
+	}
+}
+
+// 目标数增加时文件描述符数不变
+func TestEngineFDsConstant(t *testing.T) {
+	e := newTestEngine(t, engineMaxInflight)
+	base := openFDs(t)
+	for _, n := range []int{1, 10, 500} {
+		conns := make([]net.Conn, n)
+		for i := range conns {
+			conn, err := e.dial(loopbackTarget(i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			conns[i] = conn
+		}
+		if err := engineExchange(conns[n-1], 1); err != nil {
+			t.Fatal(err)
+		}
+		if got := openFDs(t); got != base {
+			t.Errorf("%d 个目标时文件描述符 %d，应与 %d 相同", n, got, base)
+		}
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+}
+
+// 在途请求达到上限时发送等待到截止时间，关闭目标或等待超时后归还名额
+// 不启动接收循环，网络中返回的差错(如网络不可达)不会结束在途请求
+func TestEngineBoundedInflight(t *testing.T) {
+	needRawSocket(t)
+	pc, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &icmpEngine{pc: pc.(*net.IPConn), slots: make(chan struct{}, 2), inflight: map[echoKey]inflightEcho{}, done: make(chan struct{})}
+	t.Cleanup(e.close)
+	conns := make([]net.Conn, 3)
+	for i := range conns {
+		conn, err := e.dial(fmt.Sprintf("203.0.113.%d", i+1)) //TEST-NET-3
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns[i] = conn
+	}
+	data := make([]byte, 8)
+	send := func(conn net.Conn, wait time.Duration) error {
+		if err := fillEcho(data, 1); err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(wait))
+		_, err := conn.Write(data)
+		return err
+	}
+	for _, conn := range conns[:2] {
+		if err := send(conn, 50*time.Millisecond); err != nil {
+			t.Skipf("没有到测试地址的路由: %v", err)
+		}
+	}
+	if err := send(conns[2], 50*time.Millisecond); err == nil {
+		t.Fatal("超过在途上限时发送应失败")
+	}
+
+	conns[0].Close() //删除该目标的在途请求
+	if err := send(conns[2], 50*time.Millisecond); err != nil {
+		t.Fatalf("关闭目标后发送: %v", err)
+	}
+	if _, err := conns[1].Read(make([]byte, 1500)); !os.IsTimeout(err) {
+		t.Fatalf("Read 错误 = %v，应为超时", err)
+	}
+	if _, err := conns[2].Read(make([]byte, 1500)); !os.IsTimeout(err) {
+		t.Fatalf("Read 错误 = %v，应为超时", err)
+	}
+	if len(e.slots) != 0 {
+		t.Fatalf("超时后仍占用 %d 个名额", len(e.slots))
+	}
+}
+
+// 过期的请求由清理循环删除
+func TestEngineExpire(t *testing.T) {
+	e := newTestEngine(t, 4)
+	conn, err := e.dial("203.0.113.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	data := make([]byte, 8)
+	if err := fillEcho(data, 1); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Millisecond))
+	if _, err := conn.Write(data); err != nil {
+		t.Skipf("没有到测试地址的路由: %v", err)
+	}
+	//不调用Read，请求只能由清理循环删除
+	deadline := time.Now().Add(3 * engineExpireEvery)
+	for len(e.slots) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("过期的请求没有被清理")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// 不经套接字的共享引擎及一个目标，由测试调用deliver模拟收到的应答
+func newOfflineEngine(t *testing.T) (*icmpEngine, *engineConn) {
+	e := &icmpEngine{slots: make(chan struct{}, 4), inflight: map[echoKey]inflightEcho{}, done: make(chan struct{})}
+	c := &engineConn{
+		e:       e,
+		raddr:   &net.IPAddr{IP: net.IPv4(10, 0, 0, 1)},
+		id:      echoID + 1,
+		replies: make(chan []byte, engineReplyQueue),
+		wake:    make(chan struct{}, 1),
+		closed:  make(chan struct{}),
+	}
+	copy(c.ip[:], c.raddr.IP.To4())
+	return e, c
+}
+
+// 模拟Write登记序号为seq的请求
+func (c *engineConn) sendOffline(t *testing.T, seq uint16) {
+	k := echoKey{ip: c.ip, id: c.id, seq: seq}
+	if err := c.e.acquire(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	c.mu.Lock()
+	c.last = k
+	c.mu.Unlock()
+	c.e.register(k, inflightEcho{conn: c, id: echoID, expires: time.Now().Add(time.Second)})
+}
+
+// 目标发来的序号为seq的回显应答，ID为共享套接字上分配的ID
+func offlineReply(c *engineConn, seq uint16) []byte {
+	req := make([]byte, 8+16)
+	fillEcho(req, int(seq))
+	m := newMockConn()
+	m.Write(req)
+	copy(m.reply[12:16], c.raddr.IP.To4())
+	icmp := m.reply[20:]
+	binary.BigEndian.PutUint16(icmp[2:4], adjustCheckSum(binary.BigEndian.Uint16(icmp[2:4]), echoID, c.id))
+	binary.BigEndian.PutUint16(icmp[4:6], c.id)
+	return m.reply
+}
+
+// 上一次请求超时后才分发的应答不会被下一次请求读到
+func TestEngineStaleReply(t *testing.T) {
+	e, c := newOfflineEngine(t)
+	buf := make([]byte, 1500)
+	readSeq := func(wait time.Duration) (int, error) {
+		c.SetReadDeadline(time.Now().Add(wait))
+		n, err := c.Read(buf)
+		if err != nil {
+			return -1, err
+		}
+		r, err := parseEchoReply(buf[:n])
+		return r.Seq, err
+	}
+
+	//应答在Read超时的同时到达：分发时请求尚未被删除，应答留在队列中
+	c.sendOffline(t, 1)
+	if _, err := readSeq(10 * time.Millisecond); !os.IsTimeout(err) {
+		t.Fatalf("没有应答时 Read = %v", err)
+	}
+	e.deliver(offlineReply(c, 1))
+	c.sendOffline(t, 2)
+	if seq, err := readSeq(20 * time.Millisecond); !os.IsTimeout(err) {
+		t.Fatalf("第2次请求读到了序号 %d 的应答(%v)", seq, err)
+	}
+	c.sendOffline(t, 3)
+	e.deliver(offlineReply(c, 3))
+	if seq, err := readSeq(time.Second); err != nil || seq != 3 {
+		t.Fatalf("第3次请求: 序号 %d，%v", seq, err)
+	}
+
+	//超时时丢弃队列中已分发但未读取的应答
+	c.sendOffline(t, 4)
+	e.deliver(offlineReply(c, 4))
+	c.SetReadDeadline(time.Now().Add(-time.Millisecond))
+	if _, err := c.Read(buf); !os.IsTimeout(err) {
+		t.Fatalf("截止时间已过时 Read = %v", err)
+	}
+	if len(c.replies) != 0 {
+		t.Errorf("超时后队列中还有 %d 个应答", len(c.replies))
+	}
+}
+
+// 路由器192.0.2.254返回的差错，引用目标的序号为seq的请求，请求中的ID为id
+func offlineICMPError(c *engineConn, typ, code byte, id, seq uint16) []byte {
+	pkt := make([]byte, 20+8+20+8)
+	pkt[0], pkt[9] = 0x45, 1
+	copy(pkt[12:16], net.IPv4(192, 0, 2, 254).To4())
+	icmp := pkt[20:]
+	icmp[0], icmp[1] = typ, code
+	inner := icmp[8:]
+	inner[0], inner[9] = 0x45, 1
+	copy(inner[16:20], c.ip[:])
+	req := inner[20:]
+	fillEcho(req, int(seq))
+	binary.BigEndian.PutUint16(req[2:4], adjustCheckSum(binary.BigEndian.Uint16(req[2:4]), echoID, id))
+	binary.BigEndian.PutUint16(req[4:6], id)
+	sum, _ := checkSum(icmp)
+	binary.BigEndian.PutUint16(icmp[2:4], sum)
+	return pkt
+}
+
+// ICMP差错按引用的请求分发给等待的目标，由parseEchoReply报告为icmp_error；
+// 引用其他ID或已不在等待的序号的差错丢弃
+func TestEngineICMPError(t *testing.T) {
+	e, c := newOfflineEngine(t)
+	buf := make([]byte, 1500)
+
+	c.sendOffline(t, 5)
+	e.deliver(offlineICMPError(c, 3, 1, c.id+1, 5))
+	e.deliver(offlineICMPError(c, 3, 1, c.id, 4))
+	e.deliver(offlineICMPError(c, 11, 0, c.id, 5))
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := c.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = parseEchoReply(buf[:n])
+	var re *replyError
+	if !errors.As(err, &re) || re.kind != replyICMPError || re.typ != 11 || re.code != 0 {
+		t.Fatalf("parseEchoReply = %v，应为类型11的icmp_error", err)
+	}
+	if src := net.IP(buf[12:16]).String(); src != "192.0.2.254" {
+		t.Errorf("差错来自 %s", src)
+	}
+	if id := binary.BigEndian.Uint16(buf[20+8+20+4:]); id != echoID {
+		t.Errorf("引用的请求ID = %d，应恢复为 %d", id, echoID)
+	}
+	if sum, _ := checkSum(buf[20:n]); sum != 0 {
+		t.Errorf("恢复ID后外层检验和错误")
+	}
+	if len(c.replies) != 0 || len(e.inflight) != 0 {
+		t.Errorf("队列中还有 %d 个报文，在途请求 %d 个", len(c.replies), len(e.inflight))
+	}
+}
+
+// 每轮所有目标并发探测一次，fds为建立全部目标后增加的文件描述符数
+func BenchmarkEngineTargets(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("shared/targets=%d", n), func(b *testing.B) {
+			e := newTestEngine(b, engineMaxInflight)
+			base := openFDs(b)
+			conns := make([]net.Conn, n)
+			for i := range conns {
+				conn, err := e.dial(loopbackTarget(i))
+				if err != nil {
+					b.Fatal(err)
+				}
+				defer conn.Close()
+				conns[i] = conn
+			}
+			benchmarkTargets(b, conns, openFDs(b)-base)
+		})
+		b.Run(fmt.Sprintf("per-target/targets=%d", n), func(b *testing.B) {
+			needRawSocket(b)
+			base := openFDs(b)
+			conns := make([]net.Conn, n)
+			for i := range conns {
+				conn, err := net.Dial("ip4:icmp", loopbackTarget(i))
+				if err != nil {
+					b.Skipf("无法为 %d 个目标建立套接字: %v", n, err)
+				}
+				defer conn.Close()
+				conns[i] = conn
+			}
+			benchmarkTargets(b, conns, openFDs(b)-base)
+		})
+	}
+}
+
+func benchmarkTargets(b *testing.B, conns []net.Conn, fds int) {
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		for _, conn := range conns {
+			wg.Add(1)
+			go func(conn net.Conn) {
+				defer wg.Done()
+				if err := engineExchange(conn, i+1); err != nil {
+					b.Error(err)
+				}
+			}(conn)
+		}
+		wg.Wait()
+	}
+	b.ReportMetric(float64(fds), "fds")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const errRepeatEvery = 10 //输出不是终端时，同一差错每连续重复多少次重新输出一次
+
+// 目标不可达(类型3)各代码的说明，见RFC 792、RFC 1812
+var unreachCodeNames = map[byte]string{
+	0:  "网络不可达",
+	1:  "主机不可达",
+	2:  "协议不可达",
+	3:  "端口不可达",
+	4:  "需要分片但设置了DF",
+	9:  "目标网络被管理性禁止",
+	10: "目标主机被管理性禁止",
+	13: "通信被管理性禁止",
+}
+
+// ICMP差错的说明，未知的类型、代码返回空
+func icmpErrorName(typ, code byte) string {
+	switch typ {
+	case 3:
+		return unreachCodeNames[code]
+	case 5:
+		return "重定向"
+	case 11:
+		return "TTL超时"
+	case 12:
+		return "参数问题"
+	}
+	return ""
+}
+
+// 差错的特征：类型、代码及发出差错的地址
+type errSignature struct {
+	typ, code byte
+	src       string
+}
+
+// 合并连续重复的差错输出：同一特征连续出现时只输出一次并累加计数，
+// 终端中原地更新为 "… ×12"，否则每 errRepeatEvery 次重新输出一行
+// 只影响逐条输出，-record 等仍记录每一次
+type errCoalescer struct {
+	tty    bool
+	last   errSignature
+	run    int //last连续出现的次数，其他输出后清零
+	counts map[errSignature]int
+	order  []errSignature //各特征首次出现的顺序
+}
+
+// 记录一次差错，返回该特征连续出现的次数
+func (c *errCoalescer) observe(sig errSignature) int {
+	if c.counts == nil {
+		c.counts = map[errSignature]int{}
+	}
+	if c.counts[sig] == 0 {
+		c.order = append(c.order, sig)
+	}
+	c.counts[sig]++
+	if c.run > 0 && sig == c.last {
+		c.run++
+	} else {
+		c.last, c.run = sig, 1
+	}
+	return c.run
+}
+
+// 中间有其他输出，之后的差错重新开始计数
+func (c *errCoalescer) interrupt() {
+	c.run = 0
+}
+
+// 本次差错需要输出的内容，不需要输出时返回空
+// 终端中重复时先回到上一行并清除，再输出带计数的一行
+func (c *errCoalescer) render(line string, run int) string {
+	switch {
+	case run == 1:
+		return line + "\n"
+	case c.tty:
+		return fmt.Sprintf("\x1b[1A\x1b[2K%s ×%d\n", line, run)
+	case run%errRepeatEvery == 0:
+		return fmt.Sprintf("%s ×%d\n", line, run)
+	}
+	return ""
+}
+
+// 输出一条ICMP差错，与上一条相同时合并
+func (p *Pinger) printICMPError(typ, code byte, src string) {
+	line := fmt.Sprintf("来自 %s 的回复: 收到的不是回显应答: 类型=%d 代码=%d", src, typ, code)
+	if name := icmpErrorName(typ, code); name != "" {
+		line += " (" + name + ")"
+	}
+	if p.errs.counts == nil {
+		p.errs.tty = isTerminal(os.Stdout)
+	}
+	run := p.errs.observe(errSignature{typ, code, src})
+	if !p.Quiet {
+		fmt.Print(p.errs.render(line, run))
+	}
+}
+
+// 统计信息中按特征汇总的差错，没有差错时不输出
+func (p *Pinger) printErrorSummary() {
+	if len(p.errs.order) == 0 {
+		return
+	}
+	p.printf("ICMP差错:\n")
+	for _, sig := range p.errs.order {
+		name := icmpErrorName(sig.typ, sig.code)
+		if name != "" {
+			name = " (" + name + ")"
+		}
+		p.printf("    来自 %s 类型=%d 代码=%d%s: %d 次\n", sig.src, sig.typ, sig.code, name, p.errs.counts[sig])
+	}
+}
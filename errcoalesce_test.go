@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestICMPErrorName(t *testing.T) {
+	tests := []struct {
+		typ, code byte
+		want      string
+	}{
+		{3, 1, "主机不可达"},
+		{3, 13, "通信被管理性禁止"},
+		{3, 10, "目标主机被管理性禁止"},
+		{3, 7, ""}, //没有说明的代码
+		{11, 0, "TTL超时"},
+		{5, 1, "重定向"},
+		{12, 0, "参数问题"},
+		{42, 0, ""},
+	}
+	for _, tt := range tests {
+		if got := icmpErrorName(tt.typ, tt.code); got != tt.want {
+			t.Errorf("icmpErrorName(%d, %d) = %q，期望 %q", tt.typ, tt.code, got, tt.want)
+		}
+	}
+}
+
+// 差错序列中的一项，typ为0表示其他输出(如回显应答)
+type errStep struct {
+	typ, code byte
+	src       string
+}
+
+// 按序列调用errCoalescer，返回输出的各行(终端中的控制序列替换为"↑")
+func coalesce(tty bool, steps []errStep) []string {
+	c := errCoalescer{tty: tty}
+	var out []string
+	for _, s := range steps {
+		if s.typ == 0 {
+			c.interrupt()
+			out = append(out, "回复")
+			continue
+		}
+		line := fmt.Sprintf("%s %d/%d", s.src, s.typ, s.code)
+		if r := c.render(line, c.observe(errSignature{s.typ, s.code, s.src})); r != "" {
+			out = append(out, strings.TrimSuffix(strings.ReplaceAll(r, "\x1b[1A\x1b[2K", "↑"), "\n"))
+		}
+	}
+	return out
+}
+
+func repeatStep(s errStep, n int) []errStep {
+	var steps []errStep
+	for i := 0; i < n; i++ {
+		steps = append(steps, s)
+	}
+	return steps
+}
+
+func TestErrCoalescer(t *testing.T) {
+	prohibited := errStep{3, 13, "10.0.0.1"}
+	unreach := errStep{3, 1, "10.0.0.1"}
+	other := errStep{3, 13, "10.0.0.2"}
+	reply := errStep{}
+	tests := []struct {
+		name  string
+		tty   bool
+		steps []errStep
+		want  []string
+	}{
+		{"重复", false, repeatStep(prohibited, 25), []string{"10.0.0.1 3/13", "10.0.0.1 3/13 ×10", "10.0.0.1 3/13 ×20"}},
+		{"终端中重复", true, repeatStep(prohibited, 3), []string{"10.0.0.1 3/13", "↑10.0.0.1 3/13 ×2", "↑10.0.0.1 3/13 ×3"}},
+		{"交替的代码", false, []errStep{prohibited, unreach, prohibited, unreach}, []string{"10.0.0.1 3/13", "10.0.0.1 3/1", "10.0.0.1 3/13", "10.0.0.1 3/1"}},
+		{"交替的来源", true, []errStep{prohibited, other, other, prohibited}, []string{"10.0.0.1 3/13", "10.0.0.2 3/13", "↑10.0.0.2 3/13 ×2", "10.0.0.1 3/13"}},
+		{"中间有其他输出", true, []errStep{prohibited, prohibited, reply, prohibited, prohibited}, []string{"10.0.0.1 3/13", "↑10.0.0.1 3/13 ×2", "回复", "10.0.0.1 3/13", "↑10.0.0.1 3/13 ×2"}},
+		{"其他输出后重新计数", false, append(append(repeatStep(prohibited, 9), reply), repeatStep(prohibited, 10)...), []string{"10.0.0.1 3/13", "回复", "10.0.0.1 3/13", "10.0.0.1 3/13 ×10"}},
+	}
+	for _, tt := range tests {
+		if got := coalesce(tt.tty, tt.steps); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s:\n得到 %q\n期望 %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 汇总按特征首次出现的顺序，计数包括合并的重复
+func TestErrCoalescerCounts(t *testing.T) {
+	var c errCoalescer
+	for _, sig := range []errSignature{{3, 13, "a"}, {3, 1, "a"}, {3, 13, "a"}, {3, 13, "b"}, {3, 13, "a"}} {
+		c.observe(sig)
+	}
+	want := []string{"{3 13 a}:3", "{3 1 a}:1", "{3 13 b}:1"}
+	var got []string
+	for _, sig := range c.order {
+		got = append(got, fmt.Sprintf("%v:%d", sig, c.counts[sig]))
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("汇总 = %q，期望 %q", got, want)
+	}
+}
+
+func TestIsICMPSocketError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{syscall.EHOSTUNREACH, true},
+		{&net.OpError{Op: "read", Err: os.NewSyscallError("recvfrom", syscall.EHOSTUNREACH)}, true},
+		{&net.OpError{Op: "read", Err: os.NewSyscallError("recvfrom", syscall.ECONNREFUSED)}, true},
+		{&net.OpError{Op: "read", Err: os.NewSyscallError("recvfrom", syscall.ENETUNREACH)}, true},
+		{os.ErrDeadlineExceeded, false},
+		{&net.OpError{Op: "read", Err: os.NewSyscallError("recvfrom", syscall.EAGAIN)}, false},
+		{errors.New("host unreachable"), false},
+	}
+	for _, tt := range tests {
+		if got := isICMPSocketError(tt.err); got != tt.want {
+			t.Errorf("isICMPSocketError(%v) = %v，期望 %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// 每次请求都以给定的ICMP差错回复的连接
+type icmpErrorConn struct {
+	*mockConn
+	typ, code byte
+	src       net.IP
+}
+
+func (c *icmpErrorConn) Write(b []byte) (int, error) {
+	if _, err := c.mockConn.Write(b); err != nil {
+		return 0, err
+	}
+	copy(c.reply[12:16], c.src.To4())
+	c.reply[20], c.reply[21] = c.typ, c.code
+	return len(b), nil
+}
+
+// 不是终端时每10次重复输出一行，统计信息中汇总；-record 仍记录每一次
+func TestICMPErrorRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rec.jsonl")
+	parseArgs(t, "-n", "25", "-i", "0", "127.0.0.1")
+	if err := startRecord(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stopRecord)
+	predial(t, "127.0.0.1", &icmpErrorConn{mockConn: newMockConn(), typ: 3, code: 13, src: net.IPv4(10, 0, 0, 1)})
+	p := newPinger("127.0.0.1")
+	stdout, _ := captureOutput(t, p.Run)
+	stopRecord()
+
+	line := "来自 10.0.0.1 的回复: 收到的不是回显应答: 类型=3 代码=13 (通信被管理性禁止)"
+	if n := strings.Count(stdout, line); n != 3 || !strings.Contains(stdout, line+" ×10\n") || !strings.Contains(stdout, line+" ×20\n") {
+		t.Errorf("差错输出了 %d 行:\n%s", n, stdout)
+	}
+	if !strings.Contains(stdout, "ICMP差错:\n    来自 10.0.0.1 类型=3 代码=13 (通信被管理性禁止): 25 次") {
+		t.Errorf("统计信息中没有差错汇总:\n%s", stdout)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), `"outcome":"error"`); n != 25 {
+		t.Errorf("记录中有 %d 条差错，期望 25", n)
+	}
+}
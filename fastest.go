@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+var fastest bool //-fastest 先向主机名的每个地址各发送一次请求，之后只ping最快的地址
+
+// 一个地址的预探测结果
+type preProbe struct {
+	addr string
+	rtt  int64 //毫秒
+	ok   bool  //是否收到回复
+}
+
+// 选择往返时间最短的地址：有回复的优先，往返时间相同时取解析结果中靠前的
+// 所有地址都没有回复时返回0及false
+func selectFastest(results []preProbe) (int, bool) {
+	best := -1
+	for i, r := range results {
+		if r.ok && (best < 0 || r.rtt < results[best].rtt) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return 0, false
+	}
+	return best, true
+}
+
+// 并发向各地址发送一次请求
+func preProbeAll(addrs []string) []preProbe {
+	results := make([]preProbe, len(addrs))
+	var wg sync.WaitGroup
+	for i, addr := range addrs {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			p := newPinger(addr)
+			p.Count, p.Interval, p.Quiet = 1, 0, true
+			p.Run()
+			ss := p.Stats.Snapshot()
+			results[i] = preProbe{addr: addr, rtt: ss.Last, ok: ss.Received > 0}
+		}(i, addr)
+	}
+	wg.Wait()
+	return results
+}
+
+// 为主机名选择最快的地址并输出选择结果，只有一个地址或无法解析时原样返回
+// 选中的地址继承主机名的标签及单独参数
+func pickFastest(host string) string {
+	addrs, err := resolveAll(icmpHost(host))
+	if err != nil || len(addrs) < 2 {
+		return host
+	}
+	return chooseFastest(host, addrs, preProbeAll(addrs))
+}
+
+// 按预探测结果选择地址并输出选择结果，都没有回复时警告并使用第一个地址
+func chooseFastest(host string, addrs []string, results []preProbe) string {
+	fmt.Printf("%s 解析出 %d 个地址，预探测结果：\n", host, len(addrs))
+	for _, r := range results {
+		if r.ok {
+			fmt.Printf("    %s: %dms\n", r.addr, r.rtt)
+		} else {
+			fmt.Printf("    %s: 请求超时\n", r.addr)
+		}
+	}
+	i, ok := selectFastest(results)
+	if ok {
+		fmt.Printf("选择最快的地址 %s。\n\n", addrs[i])
+	} else {
+		fmt.Fprintf(os.Stderr, "警告: %s 的所有地址均无回复，使用解析结果中的第一个地址 %s。\n", host, addrs[i])
+		fmt.Println()
+	}
+	if labels := targetLabels[host]; labels != nil {
+		targetLabels[addrs[i]] = labels
+	}
+	if opts, ok := targetOptions[host]; ok {
+		targetOptions[addrs[i]] = opts
+	}
+	return addrs[i]
+}
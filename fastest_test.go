@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectFastest(t *testing.T) {
+	ok := func(rtt int64) preProbe { return preProbe{rtt: rtt, ok: true} }
+	lost := preProbe{}
+	tests := []struct {
+		name    string
+		results []preProbe
+		want    int
+		ok      bool
+	}{
+		{"最快的地址", []preProbe{ok(30), ok(12), ok(20)}, 1, true},
+		{"相同时取靠前的", []preProbe{ok(20), ok(12), ok(12)}, 1, true},
+		{"有回复的优先", []preProbe{lost, lost, ok(900)}, 2, true},
+		{"0ms也算有回复", []preProbe{lost, ok(0)}, 1, true},
+		{"都没有回复", []preProbe{lost, lost}, 0, false},
+		{"没有地址", nil, 0, false},
+	}
+	for _, tt := range tests {
+		if i, ok := selectFastest(tt.results); i != tt.want || ok != tt.ok {
+			t.Errorf("%s: selectFastest = %d, %v，期望 %d, %v", tt.name, i, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// 并发预探测各地址，结果与地址顺序一致
+func TestPreProbeAll(t *testing.T) {
+	needRawSocket(t)
+	parseArgs(t, "-w", "100", "127.0.0.1")
+	addrs := []string{"127.0.0.1", "203.0.113.1", "127.0.0.2"}
+	results := preProbeAll(addrs)
+	for i, want := range []bool{true, false, true} {
+		if results[i].addr != addrs[i] || results[i].ok != want {
+			t.Errorf("第 %d 个结果 = %+v，期望 ok=%v", i, results[i], want)
+		}
+	}
+}
+
+// 输出选择结果，选中的地址继承主机名的标签；都没有回复时警告并使用第一个地址
+func TestChooseFastest(t *testing.T) {
+	parseArgs(t, "example.com")
+	t.Cleanup(func() { targetLabels = map[string]map[string]string{} })
+	targetLabels["example.com"] = map[string]string{"name": "web"}
+	addrs := []string{"192.0.2.1", "192.0.2.2"}
+
+	var got string
+	stdout, stderr := captureOutput(t, func() {
+		got = chooseFastest("example.com", addrs, []preProbe{{addr: addrs[0]}, {addr: addrs[1], rtt: 15, ok: true}})
+	})
+	want := "example.com 解析出 2 个地址，预探测结果：\n    192.0.2.1: 请求超时\n    192.0.2.2: 15ms\n选择最快的地址 192.0.2.2。\n\n"
+	if got != "192.0.2.2" || stdout != want || stderr != "" {
+		t.Errorf("选择 %s，输出:\n%s%s", got, stdout, stderr)
+	}
+	if targetLabels["192.0.2.2"]["name"] != "web" {
+		t.Errorf("选中的地址没有继承标签: %v", targetLabels)
+	}
+
+	stdout, stderr = captureOutput(t, func() {
+		got = chooseFastest("example.com", addrs, []preProbe{{addr: addrs[0]}, {addr: addrs[1]}})
+	})
+	if got != "192.0.2.1" || !strings.Contains(stderr, "警告: example.com 的所有地址均无回复，使用解析结果中的第一个地址 192.0.2.1") || strings.Contains(stdout, "选择最快的地址") {
+		t.Errorf("都没有回复时选择 %s，输出:\n%s%s", got, stdout, stderr)
+	}
+}
+
+// 只有一个地址时不预探测
+func TestPickFastestSingle(t *testing.T) {
+	parseArgs(t, "-fastest", "127.0.0.1")
+	var got string
+	stdout, _ := captureOutput(t, func() { got = pickFastest("127.0.0.1") })
+	if got != "127.0.0.1" || stdout != "" {
+		t.Errorf("pickFastest = %q，输出 %q", got, stdout)
+	}
+}
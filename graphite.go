@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	graphiteAddr        string //-graphite Graphite明文协议的接收地址，如 localhost:2003
+	cloudWatchNamespace string //-cloudwatch CloudWatch指标的命名空间
+)
+
+const graphiteTimeout = 5 * time.Second //连接及发送的超时时间
+
+// Graphite路径中目标部分的转义：点、空白、冒号(IPv6)及斜杠替换为下划线
+var graphiteEscaper = strings.NewReplacer(".", "_", " ", "_", "\t", "_", ":", "_", "/", "_")
+
+// 一批探测结束后，以Graphite明文协议发送各目标的统计：
+// ping.TARGET.rtt.min VALUE EPOCH，没有回复时只发送丢失率，无法解析时发送 ping.TARGET.dns_error 1
+// 每次单独建立TCP连接，发送后即断开
+func graphiteBatch(pingers []*Pinger) {
+	if graphiteAddr == "" {
+		return
+	}
+	now := time.Now().Unix()
+	var b strings.Builder
+	for _, p := range pingers {
+		ss := p.Stats.Snapshot()
+		prefix := "ping." + graphiteEscaper.Replace(p.Arg)
+		if ss.Sent == 0 {
+			if isDNSError(p.Err) {
+				fmt.Fprintf(&b, "%s.dns_error 1 %d\n", prefix, now)
+			}
+			continue
+		}
+		if ss.Received > 0 {
+			fmt.Fprintf(&b, "%s.rtt.min %d %d\n", prefix, ss.Min, now)
+			fmt.Fprintf(&b, "%s.rtt.avg %d %d\n", prefix, ss.Avg(), now)
+			fmt.Fprintf(&b, "%s.rtt.max %d %d\n", prefix, ss.Max, now)
+		}
+		fmt.Fprintf(&b, "%s.loss_pct %g %d\n", prefix, ss.LossPercent(), now)
+		fmt.Fprintf(&b, "%s.sent %d %d\n", prefix, ss.Sent, now)
+		fmt.Fprintf(&b, "%s.received %d %d\n", prefix, ss.Received, now)
+	}
+	if b.Len() == 0 {
+		return
+	}
+	if err := sendGraphite(graphiteAddr, b.String()); err != nil {
+		fmt.Fprintf(os.Stderr, "Graphite: 发送失败: %v\n", err)
+	}
+}
+
+// 连接接收端，发送后断开
+func sendGraphite(addr, data string) error {
+	conn, err := net.DialTimeout("tcp", addr, graphiteTimeout)
+	if err != nil {
+		return err
+	}
+	conn.SetWriteDeadline(time.Now().Add(graphiteTimeout))
+	if _, err := conn.Write([]byte(data)); err != nil {
+		conn.Close()
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGraphiteEscaper(t *testing.T) {
+	tests := []struct {
+		arg, want string
+	}{
+		{"10.0.0.1", "10_0_0_1"},
+		{"www.example.com", "www_example_com"},
+		{"2001:db8::1", "2001_db8__1"},
+		{"10.0.0.0/24", "10_0_0_0_24"},
+		{"a b\tc", "a_b_c"},
+		{"localhost", "localhost"},
+	}
+	for _, tt := range tests {
+		if got := graphiteEscaper.Replace(tt.arg); got != tt.want {
+			t.Errorf("%q: %q，期望 %q", tt.arg, got, tt.want)
+		}
+	}
+}
+
+// 在本机端口接收Graphite明文协议，每个连接的内容送入返回的通道
+func graphiteReceiver(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	ch := make(chan string, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			data, _ := io.ReadAll(conn) //发送端断开后才返回
+			conn.Close()
+			ch <- string(data)
+		}
+	}()
+	return ln.Addr().String(), ch
+}
+
+// 每个目标发送rtt.min/avg/max、loss_pct、sent、received，没有回复时省略rtt，无法解析时为dns_error
+func TestGraphiteBatch(t *testing.T) {
+	addr, ch := graphiteReceiver(t)
+	old := graphiteAddr
+	graphiteAddr = addr
+	t.Cleanup(func() { graphiteAddr = old })
+
+	before := time.Now().Unix()
+	graphiteBatch([]*Pinger{
+		stateTarget("10.0.0.1", true, false, true, true), //10ms、12ms、13ms
+		stateTarget("2001:db8::1", false, false),
+		{Arg: "bad.example", Stats: newStatistics(), Err: &net.DNSError{Err: "no such host", Name: "bad.example", IsNotFound: true}},
+	})
+	after := time.Now().Unix()
+
+	var data string
+	select {
+	case data = <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("接收端没有收到数据")
+	}
+	want := []string{
+		"ping.10_0_0_1.rtt.min 10",
+		"ping.10_0_0_1.rtt.avg 11",
+		"ping.10_0_0_1.rtt.max 13",
+		"ping.10_0_0_1.loss_pct 25",
+		"ping.10_0_0_1.sent 4",
+		"ping.10_0_0_1.received 3",
+		"ping.2001_db8__1.loss_pct 100",
+		"ping.2001_db8__1.sent 2",
+		"ping.2001_db8__1.received 0",
+		"ping.bad_example.dns_error 1",
+	}
+	lines := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("收到 %d 行，期望 %d 行:\n%s", len(lines), len(want), data)
+	}
+	for i, line := range lines {
+		sp := strings.LastIndexByte(line, ' ')
+		epoch, err := strconv.ParseInt(line[sp+1:], 10, 64)
+		if err != nil || epoch < before || epoch > after {
+			t.Errorf("%q: 时间戳不正确", line)
+		}
+		line = line[:sp]
+		if line != want[i] {
+			t.Errorf("第 %d 行 = %q，期望 %q", i+1, line, want[i])
+		}
+	}
+
+	//每批单独连接
+	graphiteBatch([]*Pinger{stateTarget("10.0.0.1", true)})
+	select {
+	case data = <-ch:
+		if !strings.HasPrefix(data, "ping.10_0_0_1.rtt.min 10 ") {
+			t.Errorf("第二批 = %q", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("第二批没有单独连接")
+	}
+}
+
+// 接收端无法连接时输出到标准错误，不影响结束
+func TestGraphiteUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	old := graphiteAddr
+	graphiteAddr = addr
+	t.Cleanup(func() { graphiteAddr = old })
+
+	_, stderr := captureOutput(t, func() { graphiteBatch([]*Pinger{stateTarget("10.0.0.1", true)}) })
+	if !strings.Contains(stderr, "Graphite: 发送失败") {
+		t.Errorf("标准错误 = %q", stderr)
+	}
+}
+
+func TestGraphiteFlag(t *testing.T) {
+	tests := []struct {
+		addr string
+		ok   bool
+	}{
+		{"localhost:2003", true},
+		{"[::1]:2003", true},
+		{"localhost", false},
+		{"::1", false},
+	}
+	for _, tt := range tests {
+		errs := argErrors(t, "-graphite", tt.addr, "127.0.0.1")
+		if hasArgError(errs, "-graphite") == tt.ok {
+			t.Errorf("-graphite %s: %q", tt.addr, errs)
+		}
+	}
+}
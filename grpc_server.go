@@ -0,0 +1,128 @@
+//go:build grpc
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	pingv1 "icmptool/proto/ping/v1"
+
+	"google.golang.org/grpc"
+)
+
+// gRPC接口的实现，以 -tags grpc 编译；修改 proto/ping/v1/ping.proto 后执行 go generate 重新生成其中的代码
+
+type pingServer struct {
+	pingv1.UnimplementedPingServiceServer
+}
+
+// 在addr上提供PingService，直到监听出错
+func serveGRPC(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer()
+	pingv1.RegisterPingServiceServer(srv, &pingServer{})
+	return srv.Serve(ln)
+}
+
+// 按请求创建Pinger，未指定的取值使用命令行参数，不输出逐条信息
+func pingerFromRequest(req *pingv1.PingRequest) (*Pinger, error) {
+	if req.GetTarget() == "" {
+		return nil, errors.New("缺少目标")
+	}
+	p := newPinger(req.GetTarget())
+	p.Quiet = true
+	if req.GetCount() > 0 {
+		p.Count = int(req.GetCount())
+	}
+	if req.GetTimeoutMs() > 0 {
+		p.Timeout = req.GetTimeoutMs()
+	}
+	if req.GetSize() > 0 {
+		if req.GetSize() > maxPayload {
+			return nil, errors.New("数据长度超出范围")
+		}
+		p.Size = int(req.GetSize())
+	}
+	if req.GetIntervalMs() > 0 {
+		p.Interval = req.GetIntervalMs()
+	}
+	return p, nil
+}
+
+func statsMessage(ss StatsSnapshot) *pingv1.PingStats {
+	return &pingv1.PingStats{
+		Sent:        int32(ss.Sent),
+		Received:    int32(ss.Received),
+		Lost:        int32(ss.Lost),
+		LossPercent: ss.LossPercent(),
+		MinMs:       ss.Min,
+		AvgMs:       ss.Avg(),
+		MaxMs:       ss.Max,
+	}
+}
+
+// Ping 发送全部请求后返回统计信息
+func (s *pingServer) Ping(ctx context.Context, req *pingv1.PingRequest) (*pingv1.PingResponse, error) {
+	p, err := pingerFromRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	p.Run()
+	resp := &pingv1.PingResponse{Target: req.GetTarget(), Address: p.Addr, Stats: statsMessage(p.Stats.Snapshot())}
+	if p.Err != nil {
+		resp.Error = p.Err.Error()
+	}
+	return resp, nil
+}
+
+// StreamPing 每次发送一个请求，结束后返回一个事件，直到达到次数或客户端取消
+func (s *pingServer) StreamPing(req *pingv1.PingRequest, stream pingv1.PingService_StreamPingServer) error {
+	p, err := pingerFromRequest(req)
+	if err != nil {
+		return err
+	}
+	total := int(req.GetCount()) //0表示不限
+	interval := time.Duration(p.Interval) * time.Millisecond
+	p.Count = 1
+	for i := 0; total == 0 || i < total; i++ {
+		if i > 0 && interval > 0 {
+			t := time.NewTimer(interval)
+			select {
+			case <-t.C:
+			case <-stream.Context().Done():
+				t.Stop()
+				return stream.Context().Err()
+			}
+		}
+		if err := stream.Context().Err(); err != nil {
+			return err
+		}
+
+		before := p.Stats.Snapshot().Received
+		p.Run() //序号接着已发送的请求数继续
+		if p.Err != nil {
+			return p.Err
+		}
+		ss := p.Stats.Snapshot()
+		ev := &pingv1.PingEvent{
+			Target:       req.GetTarget(),
+			Seq:          int32(ss.Sent - 1),
+			Success:      ss.Received > before,
+			TimeUnixNano: time.Now().UnixNano(),
+			Stats:        statsMessage(ss),
+		}
+		if ev.Success {
+			ev.RttMs = ss.Last
+		}
+		if err := stream.Send(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
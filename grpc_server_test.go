@@ -0,0 +1,107 @@
+//go:build grpc
+
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	pingv1 "icmptool/proto/ping/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// 在内存连接上启动PingService，返回客户端
+func grpcClient(t *testing.T) pingv1.PingServiceClient {
+	t.Helper()
+	ln := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pingv1.RegisterPingServiceServer(srv, &pingServer{})
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pingv1.NewPingServiceClient(conn)
+}
+
+func TestGRPCPing(t *testing.T) {
+	needRawSocket(t)
+	parseArgs(t, "-i", "0", "127.0.0.1")
+	client := grpcClient(t)
+
+	resp, err := client.Ping(context.Background(), &pingv1.PingRequest{Target: "127.0.0.1", Count: 3, Size: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := resp.GetStats(); resp.GetAddress() != "127.0.0.1" || resp.GetError() != "" || s.GetSent() != 3 || s.GetReceived() != 3 || s.GetLossPercent() != 0 {
+		t.Errorf("Ping = %v", resp)
+	}
+
+	tests := []struct {
+		name string
+		req  *pingv1.PingRequest
+	}{
+		{"缺少目标", &pingv1.PingRequest{Count: 1}},
+		{"数据长度超出范围", &pingv1.PingRequest{Target: "127.0.0.1", Size: maxPayload + 1}},
+	}
+	for _, tt := range tests {
+		if _, err := client.Ping(context.Background(), tt.req); status.Code(err) != codes.Unknown || status.Convert(err).Message() != tt.name {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+	}
+}
+
+// 每次请求一个事件，统计逐次累加；count为0时持续到客户端取消
+func TestGRPCStreamPing(t *testing.T) {
+	needRawSocket(t)
+	parseArgs(t, "-i", "0", "127.0.0.1")
+	client := grpcClient(t)
+
+	stream, err := client.StreamPing(context.Background(), &pingv1.PingRequest{Target: "127.0.0.1", Count: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		ev, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.GetSeq() != int32(i) || !ev.GetSuccess() || ev.GetStats().GetSent() != int32(i+1) || ev.GetTimeUnixNano() == 0 {
+			t.Errorf("第 %d 个事件 = %v", i, ev)
+		}
+	}
+	if _, err := stream.Recv(); err == nil {
+		t.Error("3次请求后流没有结束")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err = client.StreamPing(ctx, &pingv1.PingRequest{Target: "127.0.0.1", IntervalMs: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := stream.Recv(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cancel()
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if status.Code(err) != codes.Canceled {
+				t.Errorf("取消后 err = %v", err)
+			}
+			break
+		}
+	}
+}
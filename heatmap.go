@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	heatmapPath string //-db-heatmap 汇总的记录文件
+	heatmapTZ   string //-db-heatmap 按该时区划分小时和星期
+)
+
+// 颜色由浅到深表示由好到差
+var heatShades = []string{"░░", "▒▒", "▓▓", "██"}
+
+const heatCellWidth = 2 + 6 + 1 + 4 //阴影、中位数、空格、丢包率
+
+// 热力图的一格：某个星期几某个小时的全部探测
+type heatCell struct {
+	rtts []int64 //成功的往返时间
+	sent int
+}
+
+func (c *heatCell) median() int64 {
+	sorted := append([]int64(nil), c.rtts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+func (c *heatCell) loss() float64 {
+	return float64(c.sent-len(c.rtts)) / float64(c.sent) * 100
+}
+
+// 一个目标的热力图，按星期(星期一为0)及小时(0-23)索引
+type heatmap struct {
+	target string
+	cells  [7][24]heatCell
+}
+
+// 解析 -tz，Local为本机时区
+func loadHeatmapTZ(name string) (*time.Location, error) {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("-tz: 无效的时区 %q，应为 Local、UTC 或 Asia/Shanghai 这样的IANA名称", name)
+	}
+	return loc, nil
+}
+
+// 把记录按目标汇总到各自的热力图，返回各目标(按首次出现的顺序)及无法解析的行数
+// 事件、CSV表头及空行跳过，-since/-until 同样适用
+func buildHeatmaps(r io.Reader, loc *time.Location) ([]*heatmap, int, error) {
+	var since, until time.Time
+	if replaySince != "" {
+		since, _ = parseReplayTime(replaySince) //已在getArgs中校验
+	}
+	if replayUntil != "" {
+		until, _ = parseReplayTime(replayUntil)
+	}
+
+	var maps []*heatmap
+	byTarget := map[string]*heatmap{}
+	bad := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, csvHeader[0]+",") || isEventRecord(line) {
+			continue
+		}
+		pr, err := parseProbeRecord(line)
+		if err != nil {
+			bad++
+			continue
+		}
+		if (!since.IsZero() && pr.Time.Before(since)) || (!until.IsZero() && pr.Time.After(until)) {
+			continue
+		}
+
+		h := byTarget[pr.Target]
+		if h == nil {
+			h = &heatmap{target: pr.Target}
+			byTarget[pr.Target] = h
+			maps = append(maps, h)
+		}
+		t := pr.Time.In(loc)
+		c := &h.cells[(t.Weekday()+6)%7][t.Hour()] //星期一为第一列
+		c.sent++
+		if pr.Outcome == "success" {
+			c.rtts = append(c.rtts, pr.RTT)
+		}
+	}
+	return maps, bad, scanner.Err()
+}
+
+// 各格的阴影等级：中位数在所有有数据的格子中的位置，与丢包率对应的等级取较差者
+func (h *heatmap) shades() [7][24]int {
+	var lo, hi int64 = -1, -1
+	for d := range h.cells {
+		for hr := range h.cells[d] {
+			if c := &h.cells[d][hr]; len(c.rtts) > 0 {
+				m := c.median()
+				if lo < 0 || m < lo {
+					lo = m
+				}
+				if m > hi {
+					hi = m
+				}
+			}
+		}
+	}
+
+	var levels [7][24]int
+	for d := range h.cells {
+		for hr := range h.cells[d] {
+			c := &h.cells[d][hr]
+			if c.sent == 0 {
+				continue
+			}
+			level := len(heatShades) - 1 //全部丢失
+			if len(c.rtts) > 0 {
+				level = int((c.median() - lo) * int64(len(heatShades)) / (hi - lo + 1))
+			}
+			switch loss := c.loss(); {
+			case loss >= 50:
+				level = max(level, 3)
+			case loss >= 10:
+				level = max(level, 2)
+			case loss > 0:
+				level = max(level, 1)
+			}
+			levels[d][hr] = level
+		}
+	}
+	return levels
+}
+
+// 以对齐的文本表格输出热力图，行为小时，列为星期，每格为阴影、往返时间中位数及丢包率
+// 没有样本的格子只有一个 ·，与全部丢失(- 100%)的格子区分
+func (h *heatmap) render(loc *time.Location) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s 按小时及星期的往返时间中位数及丢包率(时区 %s):\n", h.target, loc)
+	b.WriteString("    ")
+	for _, day := range []string{"周一", "周二", "周三", "周四", "周五", "周六", "周日"} {
+		b.WriteString(" " + strings.Repeat(" ", heatCellWidth-displayWidth(day)) + day)
+	}
+	b.WriteString("\n")
+	levels := h.shades()
+	for hr := 0; hr < 24; hr++ {
+		row := fmt.Sprintf("%02d时", hr)
+		for d := range h.cells {
+			c := &h.cells[d][hr]
+			switch {
+			case c.sent == 0:
+				row += fmt.Sprintf(" %*s%*s", heatCellWidth/2+1, "·", heatCellWidth-heatCellWidth/2-1, "")
+			case len(c.rtts) == 0:
+				row += fmt.Sprintf(" %s%6s %3.0f%%", heatShades[levels[d][hr]], "-", c.loss())
+			default:
+				row += fmt.Sprintf(" %s%6s %3.0f%%", heatShades[levels[d][hr]], fmt.Sprintf("%dms", c.median()), c.loss())
+			}
+		}
+		b.WriteString(strings.TrimRight(row, " ") + "\n")
+	}
+	fmt.Fprintf(&b, "图例: %s 由好到差，按中位数在各格中的高低；丢包率 >0%%、>=10%%、>=50%% 时至少为第2、3、4级。· 没有样本\n", strings.Join(heatShades, " "))
+	return b.String()
+}
+
+// 读取 -record 的记录文件，按 -tz 时区输出各目标的星期×小时热力图，不发送报文
+func runHeatmap(path string) int {
+	loc, _ := loadHeatmapTZ(heatmapTZ) //已在getArgs中校验
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取记录失败: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+	maps, bad, err := buildHeatmaps(f, loc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取记录失败: %v\n", err)
+		return 1
+	}
+
+	for i, h := range maps {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Print(h.render(loc))
+	}
+	if bad > 0 {
+		fmt.Fprintf(os.Stderr, "跳过 %d 行无法解析的记录\n", bad)
+	}
+	if len(maps) == 0 {
+		fmt.Fprintln(os.Stderr, "没有符合条件的记录")
+		return 1
+	}
+	return 0
+}
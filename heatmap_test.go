@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testdata/heatmap.jsonl：10.0.0.1 连续两周，工作日UTC 9时 10-14ms，20时 90-130ms 且四次丢一次，
+// 周六UTC 12时一次40ms，周日UTC 3时全部丢失；example.com 周三UTC 23:30 一次25ms
+// 另有start、path_change事件及一行无法解析的记录
+func loadHeatmapFixture(t *testing.T, tz string) ([]*heatmap, int) {
+	t.Helper()
+	loc, err := loadHeatmapTZ(tz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open("testdata/heatmap.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	maps, bad, err := buildHeatmaps(f, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return maps, bad
+}
+
+// 按 -tz 的时区划分星期及小时，跨过午夜的记录落到下一天
+func TestBuildHeatmaps(t *testing.T) {
+	type cell struct {
+		target    string
+		day, hour int //day为0表示星期一
+		sent      int
+		median    int64
+		loss      float64
+	}
+	tests := []struct {
+		tz    string
+		cells []cell
+	}{
+		{"UTC", []cell{
+			{"10.0.0.1", 0, 9, 8, 11, 0},
+			{"10.0.0.1", 4, 20, 8, 110, 25},
+			{"10.0.0.1", 5, 12, 1, 40, 0},
+			{"10.0.0.1", 6, 3, 3, -1, 100},
+			{"example.com", 2, 23, 1, 25, 0},
+		}},
+		{"Asia/Shanghai", []cell{
+			{"10.0.0.1", 0, 17, 8, 11, 0},
+			{"10.0.0.1", 1, 4, 8, 110, 25}, //周一UTC 20时为周二4时
+			{"10.0.0.1", 0, 4, 0, 0, 0},
+			{"10.0.0.1", 5, 4, 8, 110, 25}, //周五UTC 20时为周六4时
+			{"10.0.0.1", 6, 11, 3, -1, 100},
+			{"example.com", 3, 7, 1, 25, 0},
+		}},
+	}
+	for _, tt := range tests {
+		maps, bad := loadHeatmapFixture(t, tt.tz)
+		if bad != 1 || len(maps) != 2 || maps[0].target != "10.0.0.1" || maps[1].target != "example.com" {
+			t.Fatalf("%s: %d 个目标，跳过 %d 行", tt.tz, len(maps), bad)
+		}
+		byTarget := map[string]*heatmap{"10.0.0.1": maps[0], "example.com": maps[1]}
+		for _, want := range tt.cells {
+			c := &byTarget[want.target].cells[want.day][want.hour]
+			if c.sent != want.sent {
+				t.Errorf("%s %s 星期%d %d时: %d 次探测，期望 %d", tt.tz, want.target, want.day+1, want.hour, c.sent, want.sent)
+				continue
+			}
+			if c.sent == 0 {
+				continue
+			}
+			if want.median >= 0 && c.median() != want.median {
+				t.Errorf("%s %s 星期%d %d时: 中位数 %d，期望 %d", tt.tz, want.target, want.day+1, want.hour, c.median(), want.median)
+			}
+			if c.loss() != want.loss {
+				t.Errorf("%s %s 星期%d %d时: 丢包率 %v，期望 %v", tt.tz, want.target, want.day+1, want.hour, c.loss(), want.loss)
+			}
+		}
+	}
+}
+
+// 阴影按中位数在各格中的高低分级，丢包率提高等级，全部丢失为最深
+func TestHeatmapShades(t *testing.T) {
+	var h heatmap
+	set := func(day, hour int, rtts []int64, sent int) {
+		h.cells[day][hour] = heatCell{rtts: rtts, sent: sent}
+	}
+	set(0, 0, []int64{10}, 1)
+	set(0, 1, []int64{109}, 1)
+	set(0, 2, []int64{40}, 1)
+	set(0, 3, []int64{10, 10, 10, 10, 10, 10, 10, 10, 10}, 10) //10%丢包
+	set(0, 4, []int64{10}, 2)                                  //50%丢包
+	set(0, 5, nil, 3)
+	levels := h.shades()
+	for hour, want := range []int{0, 3, 1, 2, 3, 3} {
+		if got := levels[0][hour]; got != want {
+			t.Errorf("第 %d 格的等级 = %d，期望 %d", hour, got, want)
+		}
+	}
+}
+
+// 输出与golden文件一致；没有样本的格子与全部丢失的格子不同
+func TestRunHeatmap(t *testing.T) {
+	parseArgs(t, "-db-heatmap", "testdata/heatmap.jsonl", "-tz", "Asia/Shanghai")
+	var code int
+	stdout, stderr := captureOutput(t, func() { code = runHeatmap(heatmapPath) })
+	if code != 0 || stderr != "跳过 1 行无法解析的记录\n" {
+		t.Errorf("退出码 %d，标准错误 %q", code, stderr)
+	}
+	checkGolden(t, "heatmap.golden", []byte(stdout))
+	if !strings.Contains(stdout, "11时 ") || !strings.Contains(stdout, "██     - 100%") || !strings.Contains(stdout, "      ·       ") {
+		t.Errorf("输出中没有全部丢失或没有样本的格子:\n%s", stdout)
+	}
+
+	parseArgs(t, "-db-heatmap", "testdata/heatmap.jsonl", "-since", "2024-03-20", "-tz", "UTC")
+	stdout, stderr = captureOutput(t, func() { code = runHeatmap(heatmapPath) })
+	if code != 1 || stdout != "" || !strings.Contains(stderr, "没有符合条件的记录") {
+		t.Errorf("没有记录: 退出码 %d\n%s%s", code, stdout, stderr)
+	}
+}
+
+func TestHeatmapTZFlag(t *testing.T) {
+	parseArgs(t, "-db-heatmap", "x.jsonl")
+	if loc, _ := loadHeatmapTZ(heatmapTZ); loc != time.Local {
+		t.Errorf("默认时区 = %v，期望本机时区", loc)
+	}
+	if errs := argErrors(t, "-db-heatmap", "x.jsonl", "-tz", "Mars/Olympus"); !hasArgError(errs, "-tz") {
+		t.Errorf("无效的时区没有报错: %q", errs)
+	}
+}
@@ -0,0 +1,7 @@
+package main
+
+// syscall包中没有定义，见netinet6/in6.h
+const (
+	ipv6RecvHopLimit = 0x25 //IPV6_RECVHOPLIMIT
+	ipv6HopLimit     = 0x2f //IPV6_HOPLIMIT，控制消息的类型
+)
@@ -0,0 +1,10 @@
+//go:build linux || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import "syscall"
+
+const (
+	ipv6RecvHopLimit = syscall.IPV6_RECVHOPLIMIT
+	ipv6HopLimit     = syscall.IPV6_HOPLIMIT //控制消息的类型
+)
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var httpURL string //-http 要测量的URL
+
+// 一次HTTP请求各阶段的耗时
+type httpPhases struct {
+	dns, tcp, tls, http time.Duration
+	status              int
+}
+
+func (ph httpPhases) total() time.Duration {
+	return ph.dns + ph.tcp + ph.tls + ph.http
+}
+
+// 解析 -http 的URL，只支持http和https
+func parseHTTPURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("-http: 无效的URL %q，须以 http:// 或 https:// 开头", s)
+	}
+	return u, nil
+}
+
+// 依次进行DNS解析、TCP连接、TLS握手(https)、发送HEAD请求并读取状态行，分别计时
+// 每次请求都重新建立连接，不复用
+func httpProbe(u *url.URL, deadline time.Time) (httpPhases, error) {
+	var ph httpPhases
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	t := time.Now()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return ph, fmt.Errorf("DNS解析失败: %v", err)
+	}
+	ph.dns = time.Since(t)
+
+	t = time.Now()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(addrs[0].String(), port))
+	if err != nil {
+		return ph, fmt.Errorf("TCP连接失败: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(deadline)
+	ph.tcp = time.Since(t)
+
+	if u.Scheme == "https" {
+		t = time.Now()
+		tc := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tc.Handshake(); err != nil {
+			return ph, fmt.Errorf("TLS握手失败: %v", err)
+		}
+		conn = tc
+		ph.tls = time.Since(t)
+	}
+
+	t = time.Now()
+	req := &http.Request{Method: http.MethodHead, URL: u, Host: u.Host, Header: http.Header{"User-Agent": {"ping"}}, Close: true}
+	if err := req.Write(conn); err != nil {
+		return ph, fmt.Errorf("发送请求失败: %v", err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return ph, fmt.Errorf("读取响应失败: %v", err)
+	}
+	resp.Body.Close()
+	ph.http = time.Since(t)
+	ph.status = resp.StatusCode
+	return ph, nil
+}
+
+// RunHTTP 以HTTP请求代替ICMP，分别测量DNS解析、TCP连接、TLS握手及HTTP响应的耗时
+// 收到任何HTTP状态码都视为成功
+func (p *Pinger) RunHTTP() {
+	u, _ := parseHTTPURL(p.Arg) //已在getArgs中校验
+	p.Host, p.Addr = u.Host, u.Host
+	p.printf("正在请求 %s：\n", u)
+
+	var sum httpPhases //成功请求各阶段耗时合计
+	for i := 0; forever || i < p.Count; i++ {
+		if !p.nextProbe(i) {
+			break
+		}
+
+		p.Stats.addSent()
+		tStart := time.Now()
+		ph, err := httpProbe(u, tStart.Add(time.Duration(p.Timeout)*time.Millisecond))
+		tSpend := time.Since(tStart).Milliseconds()
+		if err != nil {
+			p.Stats.addFailure()
+			p.printf("请求失败: %v\n", err)
+			continue
+		}
+		p.Stats.addSuccess(tSpend)
+		sum.dns, sum.tcp, sum.tls, sum.http = sum.dns+ph.dns, sum.tcp+ph.tcp, sum.tls+ph.tls, sum.http+ph.http
+		p.printf("来自 %s 的回复: 状态=%d dns=%dms tcp=%dms tls=%dms http=%dms total=%dms\n",
+			u.Host, ph.status, ph.dns.Milliseconds(), ph.tcp.Milliseconds(), ph.tls.Milliseconds(), ph.http.Milliseconds(), ph.total().Milliseconds())
+	}
+
+	p.printSummary()
+	if n := p.Stats.Snapshot().Received; n > 0 {
+		avg := func(d time.Duration) int64 { return (d / time.Duration(n)).Milliseconds() }
+		p.printf("各阶段平均耗时:\n    dns = %dms，tcp = %dms，tls = %dms，http = %dms\n", avg(sum.dns), avg(sum.tcp), avg(sum.tls), avg(sum.http))
+	}
+}
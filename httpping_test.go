@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseHTTPURL(t *testing.T) {
+	for _, s := range []string{"http://example.com", "https://example.com:8443/health?x=1"} {
+		if _, err := parseHTTPURL(s); err != nil {
+			t.Errorf("parseHTTPURL(%q): %v", s, err)
+		}
+	}
+	for _, s := range []string{"example.com", "ftp://example.com/", "http://", "https:///path", "http://[::1"} {
+		if _, err := parseHTTPURL(s); err == nil || !strings.Contains(err.Error(), "须以 http:// 或 https:// 开头") {
+			t.Errorf("parseHTTPURL(%q) 的错误 = %v", s, err)
+		}
+	}
+}
+
+// 每次请求以HEAD方法新建连接，收到任何状态码都视为成功
+func TestRunHTTP(t *testing.T) {
+	var mu sync.Mutex
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.Method+" "+r.URL.Path+" "+r.UserAgent())
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	parseArgs(t, "-n", "2", "-w", "2000", "-http", srv.URL+"/health")
+	p := newPinger(httpURL)
+	stdout, _ := captureOutput(t, p.RunHTTP)
+	host := strings.TrimPrefix(srv.URL, "http://")
+	if n := strings.Count(stdout, "来自 "+host+" 的回复: 状态=503 dns="); n != 2 {
+		t.Errorf("收到 %d 个回复，应为 2:\n%s", n, stdout)
+	}
+	if !strings.Contains(stdout, "tls=0ms") || !strings.Contains(stdout, "各阶段平均耗时:") {
+		t.Errorf("输出:\n%s", stdout)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(methods) != 2 || methods[0] != "HEAD /health ping" {
+		t.Errorf("服务器收到的请求 = %q", methods)
+	}
+	if ss := p.Stats.Snapshot(); ss.Sent != 2 || ss.Received != 2 {
+		t.Errorf("统计 = %+v", ss)
+	}
+}
+
+// 各阶段的失败原因分别说明，全部失败时不输出各阶段平均耗时
+func TestHTTPProbeFailures(t *testing.T) {
+	tlsSrv := httptest.NewTLSServer(http.NotFoundHandler()) //自签名证书，握手失败
+	defer tlsSrv.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := "http://" + ln.Addr().String() + "/"
+	ln.Close()
+
+	tests := []struct {
+		url, err string
+	}{
+		{tlsSrv.URL, "TLS握手失败"},
+		{closed, "TCP连接失败"},
+		{"http://nx.invalid/", "DNS解析失败"},
+	}
+	for _, tt := range tests {
+		u, _ := parseHTTPURL(tt.url)
+		if _, err := httpProbe(u, time.Now().Add(2*time.Second)); err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("httpProbe(%s) 的错误 = %v，应为 %s", tt.url, err, tt.err)
+		}
+	}
+
+	parseArgs(t, "-n", "1", "-w", "2000", "-http", closed)
+	stdout, _ := captureOutput(t, newPinger(httpURL).RunHTTP)
+	if !strings.Contains(stdout, "请求失败: TCP连接失败") || strings.Contains(stdout, "各阶段平均耗时") {
+		t.Errorf("输出:\n%s", stdout)
+	}
+}
+
+func TestHTTPFlag(t *testing.T) {
+	if errs := argErrors(t, "-http", "example.com"); !hasArgError(errs, `-http: 无效的URL "example.com"`) {
+		t.Errorf("错误 = %q", errs)
+	}
+}
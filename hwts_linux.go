@@ -0,0 +1,188 @@
+package main
+
+import (
+	"net"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// SO_TIMESTAMPING 相关常量，见 include/uapi/linux/net_tstamp.h
+const (
+	sofTimestampingTxHardware  = 1 << 0
+	sofTimestampingTxSoftware  = 1 << 1
+	sofTimestampingRxHardware  = 1 << 2
+	sofTimestampingRxSoftware  = 1 << 3
+	sofTimestampingSoftware    = 1 << 4
+	sofTimestampingRawHardware = 1 << 6
+	sofTimestampingOptCmsg     = 1 << 10
+	sofTimestampingOptTsonly   = 1 << 11
+
+	siocEthtool       = 0x8946
+	siocSHWTStamp     = 0x89b0
+	ethtoolGetTSInfo  = 0x41
+	hwtstampTxOn      = 1
+	hwtstampFilterAll = 1
+)
+
+// struct ethtool_ts_info
+type ethtoolTSInfo struct {
+	cmd            uint32
+	soTimestamping uint32
+	phcIndex       int32
+	txTypes        uint32
+	txReserved     [3]uint32
+	rxFilters      uint32
+	rxReserved     [3]uint32
+}
+
+// struct hwtstamp_config
+type hwtstampConfig struct {
+	flags    int32
+	txType   int32
+	rxFilter int32
+}
+
+// struct ifreq，只用到接口名和数据指针
+type ifreqData struct {
+	name [16]byte
+	data uintptr
+	pad  [16]byte
+}
+
+// tsConn 通过 SO_TIMESTAMPING 取得报文的发送和接收时间戳
+// 发送时间戳由内核放入socket的错误队列，接收时间戳随报文以控制消息(cmsg)返回
+type tsConn struct {
+	ipc      *net.IPConn
+	raw      syscall.RawConn
+	hardware bool   //true 为网卡硬件时间戳，false 为内核软件时间戳
+	oob      []byte //控制消息缓冲区
+}
+
+// 在连接上开启时间戳，网卡支持时使用硬件时间戳，否则使用内核软件时间戳
+func newTSConn(conn net.Conn, host string) (*tsConn, error) {
+	ipc, ok := conn.(*net.IPConn)
+	if !ok {
+		return nil, syscall.EINVAL
+	}
+	raw, err := ipc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	c := &tsConn{ipc: ipc, raw: raw, oob: make([]byte, 512)}
+
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		if iface, err := outgoingInterface(host); err == nil && enableHWTimestamp(int(fd), iface.Name) {
+			flags := sofTimestampingTxHardware | sofTimestampingRxHardware | sofTimestampingRawHardware |
+				sofTimestampingOptCmsg | sofTimestampingOptTsonly
+			if syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_TIMESTAMPING, flags) == nil {
+				c.hardware = true
+				return
+			}
+		}
+		//网卡不支持硬件时间戳，改用软件时间戳
+		flags := sofTimestampingTxSoftware | sofTimestampingRxSoftware | sofTimestampingSoftware |
+			sofTimestampingOptCmsg | sofTimestampingOptTsonly
+		serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_TIMESTAMPING, flags)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if serr != nil {
+		return nil, serr
+	}
+	return c, nil
+}
+
+// 查询网卡的时间戳能力，支持硬件收发时间戳时在网卡上开启
+// 开启需要 CAP_NET_ADMIN，失败时返回false
+func enableHWTimestamp(fd int, ifname string) bool {
+	var ifr ifreqData
+	copy(ifr.name[:len(ifr.name)-1], ifname)
+
+	info := ethtoolTSInfo{cmd: ethtoolGetTSInfo}
+	ifr.data = uintptr(unsafe.Pointer(&info))
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), siocEthtool, uintptr(unsafe.Pointer(&ifr))); errno != 0 {
+		return false
+	}
+	want := uint32(sofTimestampingTxHardware | sofTimestampingRxHardware | sofTimestampingRawHardware)
+	if info.soTimestamping&want != want {
+		return false
+	}
+
+	cfg := hwtstampConfig{txType: hwtstampTxOn, rxFilter: hwtstampFilterAll}
+	ifr.data = uintptr(unsafe.Pointer(&cfg))
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), siocSHWTStamp, uintptr(unsafe.Pointer(&ifr)))
+	return errno == 0
+}
+
+// 当前使用的时间戳类型
+func (c *tsConn) mode() string {
+	if c.hardware {
+		return "硬件时间戳"
+	}
+	return "软件时间戳"
+}
+
+// 接收一个回复，返回由发送和接收时间戳计算出的往返时间
+// 任一时间戳缺失时退回到以 tStart 计时
+func (c *tsConn) read(b []byte, tStart time.Time) (int, time.Duration, error) {
+	n, oobn, _, _, err := c.ipc.ReadMsgIP(b, c.oob)
+	rtt := time.Since(tStart)
+	if err != nil {
+		return 0, rtt, err
+	}
+	rx, rxOK := c.timestamp(c.oob[:oobn])
+	tx, txOK := c.txTimestamp()
+	if rxOK && txOK && rx.After(tx) {
+		rtt = rx.Sub(tx)
+	}
+	return n, rtt, nil
+}
+
+// 从错误队列中取出最近一次发送的时间戳
+func (c *tsConn) txTimestamp() (time.Time, bool) {
+	var (
+		ts time.Time
+		ok bool
+	)
+	c.raw.Control(func(fd uintptr) {
+		for {
+			_, oobn, _, _, err := syscall.Recvmsg(int(fd), nil, c.oob, syscall.MSG_ERRQUEUE|syscall.MSG_DONTWAIT)
+			if err != nil {
+				return //队列已取空
+			}
+			if t, found := c.timestamp(c.oob[:oobn]); found {
+				ts, ok = t, true
+			}
+		}
+	})
+	return ts, ok
+}
+
+// 解析控制消息中的 struct scm_timestamping：ts[0] 为软件时间戳，ts[2] 为网卡原始硬件时间戳
+func (c *tsConn) timestamp(oob []byte) (time.Time, bool) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return time.Time{}, false
+	}
+	for _, m := range msgs {
+		if m.Header.Level != syscall.SOL_SOCKET || m.Header.Type != syscall.SO_TIMESTAMPING {
+			continue
+		}
+		if len(m.Data) < 3*int(unsafe.Sizeof(syscall.Timespec{})) {
+			continue
+		}
+		ts := (*[3]syscall.Timespec)(unsafe.Pointer(&m.Data[0]))
+		t := ts[0]
+		if c.hardware {
+			t = ts[2]
+		}
+		if t.Sec == 0 && t.Nsec == 0 {
+			continue
+		}
+		return time.Unix(t.Unix()), true
+	}
+	return time.Time{}, false
+}
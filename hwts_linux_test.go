@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// 构造携带 struct scm_timestamping 的控制消息
+func scmTimestamping(ts [3]syscall.Timespec) []byte {
+	data := (*[unsafe.Sizeof(ts)]byte)(unsafe.Pointer(&ts))[:]
+	oob := make([]byte, syscall.CmsgSpace(len(data)))
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&oob[0]))
+	h.Level = syscall.SOL_SOCKET
+	h.Type = syscall.SO_TIMESTAMPING
+	h.SetLen(syscall.CmsgLen(len(data)))
+	copy(oob[syscall.CmsgLen(0):], data)
+	return oob
+}
+
+// 软件时间戳取 ts[0]，硬件时间戳取 ts[2]，为0时视为缺失
+func TestTSConnTimestamp(t *testing.T) {
+	sw := syscall.NsecToTimespec(1700000000123456789)
+	hw := syscall.NsecToTimespec(1700000000987654321)
+	tests := []struct {
+		name     string
+		hardware bool
+		oob      []byte
+		want     int64
+		ok       bool
+	}{
+		{"软件时间戳", false, scmTimestamping([3]syscall.Timespec{sw, {}, hw}), 1700000000123456789, true},
+		{"硬件时间戳", true, scmTimestamping([3]syscall.Timespec{sw, {}, hw}), 1700000000987654321, true},
+		{"硬件时间戳缺失", true, scmTimestamping([3]syscall.Timespec{sw, {}, {}}), 0, false},
+		{"没有控制消息", false, nil, 0, false},
+	}
+	for _, tt := range tests {
+		c := &tsConn{hardware: tt.hardware}
+		got, ok := c.timestamp(tt.oob)
+		if ok != tt.ok || (ok && got.UnixNano() != tt.want) {
+			t.Errorf("%s: timestamp = %v, %v，应为 %d, %v", tt.name, got.UnixNano(), ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// 回环接口没有硬件时间戳，改用内核软件时间戳计算往返时间
+func TestTSConnLoopback(t *testing.T) {
+	needRawSocket(t)
+	conn, err := net.Dial("ip4:icmp", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	tsc, err := newTSConn(conn, "127.0.0.1")
+	if err != nil {
+		t.Skipf("无法开启时间戳: %v", err)
+	}
+	if tsc.mode() != "软件时间戳" {
+		t.Errorf("mode = %s", tsc.mode())
+	}
+
+	data := make([]byte, 8+32)
+	if err := fillEcho(data, 5); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	tStart := time.Now()
+	if _, err := conn.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, rtt, err := tsc.read(buf, tStart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		icmp := buf[int(buf[0]&0x0f)*4 : n]
+		if icmp[0] != 0 || binary.BigEndian.Uint16(icmp[6:8]) != 5 {
+			continue //回环上自己发出的请求
+		}
+		if rtt <= 0 || rtt > time.Since(tStart) {
+			t.Errorf("往返时间 %v，应在0与 %v 之间", rtt, time.Since(tStart))
+		}
+		return
+	}
+}
+
+func TestNewTSConnRequiresIPConn(t *testing.T) {
+	if _, err := newTSConn(newMockConn(), "127.0.0.1"); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("newTSConn(mockConn) = %v，应为 EINVAL", err)
+	}
+}
+
+// io_uring收发时取不到时间戳，两者不能同时指定
+func TestHWTSFlags(t *testing.T) {
+	parseArgs(t, "-hw-ts", "127.0.0.1")
+	if !hwTS {
+		t.Error("-hw-ts 没有生效")
+	}
+	if errs := argErrors(t, "-hw-ts", "-iouring", "127.0.0.1"); !hasArgError(errs, "参数 -hw-ts 与 -iouring 不能同时指定") {
+		t.Errorf("错误 = %q", errs)
+	}
+}
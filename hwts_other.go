@@ -0,0 +1,22 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// tsConn 通过 SO_TIMESTAMPING 取得报文时间戳，仅Linux支持
+type tsConn struct{}
+
+func newTSConn(conn net.Conn, host string) (*tsConn, error) {
+	return nil, errors.New("当前平台不支持 SO_TIMESTAMPING")
+}
+
+func (c *tsConn) mode() string { return "" }
+
+func (c *tsConn) read(b []byte, tStart time.Time) (int, time.Duration, error) {
+	return 0, 0, errors.New("当前平台不支持 SO_TIMESTAMPING")
+}
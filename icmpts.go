@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var timestampMsg bool //-timestamp-msg 发送ICMP时间戳请求(类型13)代替回显请求
+
+const (
+	icmpTimestampRequest = 13
+	icmpTimestampReply   = 14
+	timestampMsgLen      = 20 //8字节头部 + 发起、接收、发送三个32位时间戳
+	msPerDay             = 24 * 60 * 60 * 1000
+	timestampNonStandard = 1 << 31 //最高位置1表示时间戳不是UTC零点以来的毫秒数
+)
+
+// UTC零点以来的毫秒数，时间戳请求及应答中的时间格式
+func msSinceMidnightUTC(t time.Time) uint32 {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return uint32(t.Sub(midnight).Milliseconds())
+}
+
+// 两个时间戳之差(毫秒)，跨过UTC零点时按一天取模，结果在 ±12小时之内
+func timestampDiff(to, from uint32) int64 {
+	d := (int64(to) - int64(from)) % msPerDay
+	switch {
+	case d > msPerDay/2:
+		d -= msPerDay
+	case d <= -msPerDay/2:
+		d += msPerDay
+	}
+	return d
+}
+
+// 构造时间戳请求：发起时间戳为当前时间，接收、发送时间戳置0
+func buildTimestampRequest(seq int, now time.Time) ([]byte, error) {
+	pkt := make([]byte, timestampMsgLen)
+	icmp := ICMP{
+		Type:   icmpTimestampRequest,
+		ID:     echoID,
+		SeqNum: uint16(seq),
+	}
+	icmp.Marshal(pkt)
+	binary.BigEndian.PutUint32(pkt[8:12], msSinceMidnightUTC(now))
+
+	sum, err := checkSum(pkt)
+	if err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint16(pkt[2:4], sum)
+	return pkt, nil
+}
+
+// 时间戳应答
+type timestampReply struct {
+	id, seq                      uint16
+	originate, receive, transmit uint32
+}
+
+// 解析时间戳应答(类型14)的ICMP部分
+func parseTimestampReply(icmp []byte) (timestampReply, error) {
+	if len(icmp) < timestampMsgLen {
+		return timestampReply{}, errors.New("应答过短")
+	}
+	if icmp[0] != icmpTimestampReply {
+		return timestampReply{}, fmt.Errorf("类型 %d 不是时间戳应答", icmp[0])
+	}
+	return timestampReply{
+		id:        binary.BigEndian.Uint16(icmp[4:6]),
+		seq:       binary.BigEndian.Uint16(icmp[6:8]),
+		originate: binary.BigEndian.Uint32(icmp[8:12]),
+		receive:   binary.BigEndian.Uint32(icmp[12:16]),
+		transmit:  binary.BigEndian.Uint32(icmp[16:20]),
+	}, nil
+}
+
+// RunTimestamp 以ICMP时间戳请求(RFC 792，类型13)代替回显请求
+// 由应答中的接收、发送时间戳把往返时间拆分为去程、对端处理及回程，
+// 去程与回程包含两端时钟的偏差，两者之和不受偏差影响
+func (p *Pinger) RunTimestamp() {
+	p.Host = icmpHost(p.Arg)
+	conn, err := dialICMP(p.Host, time.Duration(p.Timeout)*time.Millisecond)
+	if err != nil {
+		p.fail(err)
+		p.printf("%s", dialErrorText(p.Host, err))
+		return
+	}
+	defer conn.Close()
+	p.Addr = conn.RemoteAddr().String()
+
+	p.printf("正在向 %s [%s] 发送时间戳请求：\n", p.Host, p.Addr)
+
+	bufp := recvBufPool.Get().(*[]byte)
+	defer recvBufPool.Put(bufp)
+	buf := *bufp
+	warned := false //对端时间戳无效的提示只输出一次
+	var samples []tsSample
+
+	for i := 0; forever || i < p.Count; i++ {
+		if !p.nextProbe(i) {
+			break
+		}
+
+		p.Stats.addSent()
+		tStart := time.Now()
+		req, err := buildTimestampRequest(i, tStart)
+		if err != nil {
+			p.Stats.addSendError(err)
+			p.Stats.addFailure()
+			p.printf("请求失败: %s\n", sendErrorText(err))
+			continue
+		}
+		conn.SetDeadline(tStart.Add(time.Duration(p.Timeout) * time.Millisecond))
+		if _, err := conn.Write(req); err != nil {
+			p.Stats.addSendError(err)
+			p.Stats.addFailure()
+			p.printf("请求失败: %s\n", sendErrorText(err))
+			continue
+		}
+
+		//跳过其他ICMP报文(包括目标为本机时收到的自己的请求)
+		var r timestampReply
+		for {
+			n, rerr := conn.Read(buf)
+			if err = rerr; err != nil {
+				break
+			}
+			if checkIPv4Header(buf[:n]) != nil {
+				continue
+			}
+			var perr error
+			r, perr = parseTimestampReply(buf[int(buf[0]&0x0f)*4 : n])
+			if perr == nil && r.id == echoID && r.seq == uint16(i) {
+				break
+			}
+		}
+		tEnd := time.Now()
+		tSpend := tEnd.Sub(tStart).Milliseconds()
+		if err != nil {
+			p.Stats.addTimeout()
+			p.Stats.addFailure()
+			p.printf("请求超时。\n")
+			continue
+		}
+		p.Stats.addSuccess(tSpend)
+
+		s := tsSample{r.originate, r.receive, r.transmit, msSinceMidnightUTC(tEnd)}
+		samples = append(samples, s)
+		if !s.valid() {
+			if !warned {
+				p.printf("注意: %s 的时间戳无效(为0或最高位为1)，无法拆分去程与回程。\n", p.Host)
+				warned = true
+			}
+			p.printf("来自 %d.%d.%d.%d 的回复: 序号=%d 时间=%dms\n", buf[12], buf[13], buf[14], buf[15], i, tSpend)
+			continue
+		}
+		forward, back := s.legs()
+		process := timestampDiff(r.transmit, r.receive)
+		line := fmt.Sprintf("来自 %d.%d.%d.%d 的回复: 序号=%d 时间=%dms 去程=%dms 处理=%dms 回程=%dms",
+			buf[12], buf[13], buf[14], buf[15], i, tSpend, forward, process, back)
+		if tsOffset {
+			line += fmt.Sprintf(" 偏差=%+.1fms", s.offset())
+		}
+		p.printf("%s\n", line)
+	}
+
+	if ss := p.Stats.Snapshot(); ss.Sent > 0 && ss.Received == 0 {
+		p.printf("%s 不支持时间戳请求或被过滤(%d 个请求均没有应答)。\n", p.Host, ss.Sent)
+	}
+	if tsOffset && len(samples) > 0 {
+		p.printTSOffset(samples)
+	}
+	p.printSummary()
+}
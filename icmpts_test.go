@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMsSinceMidnightUTC(t *testing.T) {
+	tests := []struct {
+		t    time.Time
+		want uint32
+	}{
+		{time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), 0},
+		{time.Date(2024, 3, 1, 10, 0, 0, 5e6, time.UTC), 36000005},
+		{time.Date(2024, 3, 1, 23, 59, 59, 999e6, time.UTC), msPerDay - 1},
+		{time.Date(2024, 3, 1, 8, 0, 0, 0, time.FixedZone("CST", 8*3600)), 0}, //按UTC计算
+	}
+	for _, tt := range tests {
+		if got := msSinceMidnightUTC(tt.t); got != tt.want {
+			t.Errorf("msSinceMidnightUTC(%s) = %d，期望 %d", tt.t, got, tt.want)
+		}
+	}
+}
+
+// 跨过UTC零点时按一天取模
+func TestTimestampDiff(t *testing.T) {
+	tests := []struct {
+		to, from uint32
+		want     int64
+	}{
+		{36000010, 36000000, 10},
+		{36000000, 36000010, -10},
+		{5, msPerDay - 5, 10},
+		{msPerDay - 5, 5, -10},
+		{msPerDay / 2, 0, msPerDay / 2},
+		{0, msPerDay / 2, msPerDay / 2},
+	}
+	for _, tt := range tests {
+		if got := timestampDiff(tt.to, tt.from); got != tt.want {
+			t.Errorf("timestampDiff(%d, %d) = %d，期望 %d", tt.to, tt.from, got, tt.want)
+		}
+	}
+}
+
+// 时间戳请求(RFC 792)：类型13 代码0 检验和 标识 序号，之后为发起、接收、发送时间戳
+func TestBuildTimestampRequest(t *testing.T) {
+	pkt, err := buildTimestampRequest(0x0102, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{13, 0, 0, 0, byte(echoID >> 8), byte(echoID), 0x01, 0x02, 0x02, 0x25, 0x51, 0x00, 0, 0, 0, 0, 0, 0, 0, 0}
+	sum := binary.BigEndian.Uint16(pkt[2:4])
+	pkt[2], pkt[3] = 0, 0
+	if !bytes.Equal(pkt, want) {
+		t.Errorf("请求 = % x，期望 % x", pkt, want)
+	}
+	if got, _ := checkSum(want); got != sum {
+		t.Errorf("检验和 = %#04x，期望 %#04x", sum, got)
+	}
+}
+
+func TestParseTimestampReply(t *testing.T) {
+	reply := []byte{14, 0, 0, 0, 0x12, 0x34, 0, 7, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3}
+	r, err := parseTimestampReply(reply)
+	if err != nil || r != (timestampReply{id: 0x1234, seq: 7, originate: 1, receive: 2, transmit: 3}) {
+		t.Errorf("parseTimestampReply = %+v %v", r, err)
+	}
+	tests := []struct {
+		name string
+		pkt  []byte
+		err  string
+	}{
+		{"过短", reply[:19], "应答过短"},
+		{"回显应答", append([]byte{0}, reply[1:]...), "类型 0 不是时间戳应答"},
+		{"时间戳请求", append([]byte{13}, reply[1:]...), "类型 13 不是时间戳应答"},
+	}
+	for _, tt := range tests {
+		if _, err := parseTimestampReply(tt.pkt); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: 错误 = %v，期望 %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+// 把时间戳请求改为应答，接收、发送时间戳为stamp(发起时间戳)的返回值
+type timestampConn struct {
+	*mockConn
+	stamp func(originate uint32) (receive, transmit uint32)
+}
+
+func (c *timestampConn) Write(b []byte) (int, error) {
+	n, err := c.mockConn.Write(b)
+	icmp := c.reply[20:]
+	icmp[0] = icmpTimestampReply
+	receive, transmit := c.stamp(binary.BigEndian.Uint32(icmp[8:12]))
+	binary.BigEndian.PutUint32(icmp[12:16], receive)
+	binary.BigEndian.PutUint32(icmp[16:20], transmit)
+	fixChecksum(c.reply)
+	return n, err
+}
+
+// 重新计算回复(IP头20字节)中的ICMP检验和
+func fixChecksum(reply []byte) []byte {
+	icmp := reply[20:]
+	icmp[2], icmp[3] = 0, 0
+	sum, _ := checkSum(icmp)
+	binary.BigEndian.PutUint16(icmp[2:4], sum)
+	return reply
+}
+
+// 对端时间戳不是标准格式时只输出往返时间，提示只输出一次
+func TestRunTimestampNonStandard(t *testing.T) {
+	parseArgs(t, "-timestamp-msg", "-n", "2", "-i", "0", "127.0.0.1")
+	predial(t, "127.0.0.1", &timestampConn{mockConn: newMockConn(), stamp: func(uint32) (uint32, uint32) {
+		return timestampNonStandard | 1, timestampNonStandard | 2
+	}})
+	p := newPinger("127.0.0.1")
+	stdout, _ := captureOutput(t, p.RunTimestamp)
+	if ss := p.Stats.Snapshot(); ss.Sent != 2 || ss.Received != 2 {
+		t.Errorf("统计 = %+v", ss)
+	}
+	if strings.Count(stdout, "最高位为1") != 1 || strings.Contains(stdout, "去程=") || strings.Count(stdout, "来自 127.0.0.1 的回复: 序号=") != 2 {
+		t.Errorf("输出:\n%s", stdout)
+	}
+}
+
+// 本机应答时间戳请求，往返时间拆分为去程、处理及回程
+func TestRunTimestamp(t *testing.T) {
+	needRawSocket(t)
+	parseArgs(t, "-timestamp-msg", "-n", "2", "-w", "1000", "-i", "0", "127.0.0.1")
+	p := newPinger("127.0.0.1")
+	stdout, _ := captureOutput(t, p.RunTimestamp)
+	if ss := p.Stats.Snapshot(); ss.Sent != 2 || ss.Received != 2 {
+		t.Errorf("统计 = %+v", ss)
+	}
+	for _, want := range []string{"正在向 127.0.0.1 [127.0.0.1] 发送时间戳请求", "序号=0 时间=", "序号=1 时间=", " 去程=", " 处理=", " 回程="} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("输出中没有 %q:\n%s", want, stdout)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// 按RFC 1624 式3增量更新检验和：报文中一个16位字由old变为new时
+// HC' = ~(~HC + ~old + new)，结果与整体重新计算一致(不会出现-0)；
+// 唯一的例外是更新后报文其余部分全为0，此时得到0x0000而不是0xffff。回显请求的类型字段为8，不会出现这种情况
+func adjustCheckSum(sum, old, new uint16) uint16 {
+	s := uint32(^sum) + uint32(^old) + uint32(new)
+	for s>>16 != 0 {
+		s = s>>16 + s&0xffff
+	}
+	return ^uint16(s)
+}
+
+// 在上一次由fillEcho/refillEcho写好的请求报文中只更新序号，并增量调整检验和
+// 数据部分不变，每次请求的开销与 -l 无关；-tags checksumdebug 时与整体重新计算的结果比对
+func refillEcho(pkt []byte, seq int) {
+	old := binary.BigEndian.Uint16(pkt[6:8])
+	sum := adjustCheckSum(binary.BigEndian.Uint16(pkt[2:4]), old, uint16(seq))
+	binary.BigEndian.PutUint16(pkt[6:8], uint16(seq))
+	binary.BigEndian.PutUint16(pkt[2:4], sum)
+
+	if checkSumDebug {
+		if full, _ := checkSum(pkt); full != 0 {
+			panic(fmt.Sprintf("增量检验和错误: 序号=%d 检验和=%#04x 长度=%d", seq, sum, len(pkt)))
+		}
+	}
+}
@@ -0,0 +1,6 @@
+//go:build checksumdebug
+
+package main
+
+// 以 -tags checksumdebug 构建时，增量更新检验和后再整体计算一次进行比对
+const checkSumDebug = true
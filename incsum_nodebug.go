@@ -0,0 +1,5 @@
+//go:build !checksumdebug
+
+package main
+
+const checkSumDebug = false
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+func TestAdjustCheckSum(t *testing.T) {
+	tests := []struct {
+		name          string
+		sum, old, new uint16
+		want          uint16
+	}{
+		{"RFC 1624 第4节的例子", 0xdd2f, 0x5555, 0x3285, 0x0000},
+		{"字不变", 0x1234, 0xabcd, 0xabcd, 0x1234},
+		{"0变为0xffff", 0x1234, 0x0000, 0xffff, 0x1234}, //反码运算中0xffff与0相同
+		{"序号加1", 0xf7fe, 0x0001, 0x0002, 0xf7fd},
+	}
+	for _, tt := range tests {
+		if got := adjustCheckSum(tt.sum, tt.old, tt.new); got != tt.want {
+			t.Errorf("%s: adjustCheckSum(%#04x, %#04x, %#04x) = %#04x，应为 %#04x", tt.name, tt.sum, tt.old, tt.new, got, tt.want)
+		}
+	}
+}
+
+// 报文中任意一个16位字改变后，增量调整的检验和与整体重新计算的一致
+func FuzzAdjustCheckSum(f *testing.F) {
+	f.Add([]byte{0x08, 0x00, 0x00, 0x00, 0x12, 0x34, 0x00, 0x01}, uint(3), uint16(2))
+	f.Add(make([]byte, 40), uint(0), uint16(0xffff))
+	f.Add(bytes.Repeat([]byte{0xff}, 9), uint(4), uint16(0))
+	f.Fuzz(func(t *testing.T, data []byte, word uint, new uint16) {
+		if len(data) < 4 {
+			return
+		}
+		pkt := append([]byte(nil), data...)
+		binary.BigEndian.PutUint16(pkt[2:4], 0)
+		sum, _ := checkSum(pkt)
+		binary.BigEndian.PutUint16(pkt[2:4], sum)
+
+		i := 2 * (word % uint(len(pkt)/2))
+		if i == 2 {
+			return //检验和字段本身
+		}
+		old := binary.BigEndian.Uint16(pkt[i : i+2])
+		binary.BigEndian.PutUint16(pkt[i:i+2], new)
+		got := adjustCheckSum(sum, old, new)
+
+		binary.BigEndian.PutUint16(pkt[2:4], 0)
+		if allZero(pkt) {
+			return //见adjustCheckSum的说明
+		}
+		want, _ := checkSum(pkt)
+		if got != want {
+			t.Fatalf("第%d字节的字 %#04x->%#04x: 增量 %#04x，重新计算 %#04x", i, old, new, got, want)
+		}
+	})
+}
+
+func allZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// 经refillEcho更新序号后的报文与fillEcho整体重新写入的完全相同
+func FuzzRefillEcho(f *testing.F) {
+	f.Add([]byte("abcdefghijklmnopqrstuvwxyz012345"), uint16(1), uint16(2))
+	f.Add([]byte{}, uint16(0xffff), uint16(0))
+	f.Add([]byte{0xff}, uint16(0), uint16(0xffff))
+	f.Fuzz(func(t *testing.T, payload []byte, from, to uint16) {
+		pkt := append(make([]byte, 8), payload...)
+		if err := fillEcho(pkt, int(from)); err != nil {
+			t.Fatal(err)
+		}
+		refillEcho(pkt, int(to))
+
+		want := append(make([]byte, 8), payload...)
+		if err := fillEcho(want, int(to)); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(pkt[:8], want[:8]) {
+			t.Fatalf("序号 %d->%d，-l %d: 增量更新 % x，重新计算 % x", from, to, len(payload), pkt[:8], want[:8])
+		}
+	})
+}
+
+// 整体重新计算(fillEcho)的开销随 -l 增长，增量更新(refillEcho)与 -l 无关
+func BenchmarkEchoCheckSum(b *testing.B) {
+	for _, size := range []int{32, 1472, 65507} {
+		pkt := make([]byte, 8+size)
+		b.Run(fmt.Sprintf("full/l=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				fillEcho(pkt, i)
+			}
+		})
+		b.Run(fmt.Sprintf("incremental/l=%d", size), func(b *testing.B) {
+			fillEcho(pkt, 0)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				refillEcho(pkt, i)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	influxAddr      string //-influx-addr InfluxDB UDP行协议监听地址，如 localhost:8089
+	influxPerPacket bool   //-influx-per-packet 同时导出每次探测
+)
+
+// InfluxDB行协议的UDP导出，未开启 -influx-addr 时为nil
+var influx *influxExporter
+
+type influxExporter struct {
+	mu       sync.Mutex
+	conn     *net.UDPConn
+	failOnce sync.Once //发送失败只提示一次
+}
+
+// 开启InfluxDB导出
+func startInflux(addr string) error {
+	ua, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialUDP("udp", nil, ua)
+	if err != nil {
+		return err
+	}
+	influx = &influxExporter{conn: conn}
+	return nil
+}
+
+// 关闭InfluxDB导出
+func stopInflux() {
+	if influx == nil {
+		return
+	}
+	influx.conn.Close()
+	influx = nil
+}
+
+// 发送一行，UDP不保证送达，失败时只在第一次提示
+func (e *influxExporter) send(line string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.conn.Write([]byte(line)); err != nil {
+		e.failOnce.Do(func() {
+			fmt.Fprintf(os.Stderr, "InfluxDB: 发送失败: %v\n", err)
+		})
+	}
+}
+
+// 行协议中标签键、值的转义：逗号、等号、空格前加反斜杠
+var influxTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// 度量名及标签部分，如 ping,host=10.0.0.1,name=gw；标签按名称排序
+func influxSeries(measurement, target string, labels map[string]string) string {
+	var b strings.Builder
+	b.WriteString(measurement)
+	b.WriteString(",host=")
+	b.WriteString(influxTagEscaper.Replace(target))
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if k != "host" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteString("," + influxTagEscaper.Replace(k) + "=" + influxTagEscaper.Replace(labels[k]))
+	}
+	return b.String()
+}
+
+// 一批探测结束后，为每个目标发送一行统计：
+// ping,host=TARGET rtt_min=X,rtt_avg=Y,rtt_max=Z,loss_pct=W timestamp
+// 没有回复时只发送loss_pct；无法解析时只发送 dns_error=1i
+func influxBatch(pingers []*Pinger) {
+	if influx == nil {
+		return
+	}
+	now := time.Now().UnixNano()
+	for _, p := range pingers {
+		ss := p.Stats.Snapshot()
+		if ss.Sent == 0 {
+			if isDNSError(p.Err) {
+				influx.send(fmt.Sprintf("%s dns_error=1i %d\n", influxSeries("ping", p.Arg, p.Labels), now))
+			}
+			continue
+		}
+		fields := "loss_pct=" + strconv.FormatFloat(ss.LossPercent(), 'f', -1, 64)
+		if ss.Received > 0 {
+			fields = fmt.Sprintf("rtt_min=%d,rtt_avg=%d,rtt_max=%d,%s", ss.Min, ss.Avg(), ss.Max, fields)
+		}
+		influx.send(fmt.Sprintf("%s %s %d\n", influxSeries("ping", p.Arg, p.Labels), fields, now))
+	}
+}
+
+// -influx-per-packet 时为每次探测发送一行：
+// ping_packet,host=TARGET seq=1i,rtt=X,ttl=64i,outcome="success" timestamp
+func influxProbe(s probeSpan) {
+	if influx == nil || !influxPerPacket {
+		return
+	}
+	fields := fmt.Sprintf("seq=%di,outcome=%q", s.seq, s.outcome)
+	if s.outcome == "success" {
+		fields += fmt.Sprintf(",rtt=%d", s.rtt)
+		if s.ttl > 0 {
+			fields += fmt.Sprintf(",ttl=%di", s.ttl)
+		}
+	}
+	influx.send(fmt.Sprintf("%s %s %d\n", influxSeries("ping_packet", s.target, s.labels), fields, s.start.UnixNano()))
+}
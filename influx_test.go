@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 在本机UDP端口上接收行协议，返回地址及读取n行的函数
+func influxListener(t *testing.T) (string, func(n int) []string) {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	return pc.LocalAddr().String(), func(n int) []string {
+		t.Helper()
+		var lines []string
+		buf := make([]byte, 1500)
+		for len(lines) < n {
+			pc.SetReadDeadline(time.Now().Add(time.Second))
+			m, _, err := pc.ReadFrom(buf)
+			if err != nil {
+				t.Fatalf("收到 %d 行后: %v", len(lines), err)
+			}
+			lines = append(lines, string(buf[:m]))
+		}
+		return lines
+	}
+}
+
+// 每个目标一行统计，没有回复时只有loss_pct，没有发送请求的目标跳过
+func TestInfluxBatch(t *testing.T) {
+	addr, read := influxListener(t)
+	if err := startInflux(addr); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stopInflux)
+
+	up := baselinePinger("10.0.0.1", 10, 20, -1, 30)
+	up.Labels = map[string]string{"name": "gw"}
+	down := baselinePinger("10.0.0.2", -1, -1)
+	idle := &Pinger{Arg: "10.0.0.3", Stats: newStatistics()}
+	influxBatch([]*Pinger{up, idle, down})
+
+	lines := read(2)
+	wants := []*regexp.Regexp{
+		regexp.MustCompile(`^ping,host=10\.0\.0\.1,name=gw rtt_min=10,rtt_avg=20,rtt_max=30,loss_pct=25 \d+\n$`),
+		regexp.MustCompile(`^ping,host=10\.0\.0\.2 loss_pct=100 \d+\n$`),
+	}
+	for i, want := range wants {
+		if !want.MatchString(lines[i]) {
+			t.Errorf("第 %d 行 = %q，期望匹配 %s", i+1, lines[i], want)
+		}
+	}
+}
+
+// -influx-per-packet 时每次探测一行，时间戳为发送时间，失败时没有rtt、ttl
+func TestInfluxProbe(t *testing.T) {
+	addr, read := influxListener(t)
+	if err := startInflux(addr); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stopInflux)
+	t.Cleanup(func() { influxPerPacket = false })
+
+	start := time.Unix(1709251200, 5)
+	influxProbe(probeSpan{target: "10.0.0.1", seq: 1, start: start, rtt: 12, ttl: 64, outcome: "success"})
+	influxPerPacket = true
+	influxProbe(probeSpan{target: "10.0.0.1", labels: map[string]string{"site": "bj"}, seq: 2, start: start, rtt: 12, ttl: 64, outcome: "success"})
+	influxProbe(probeSpan{target: "10.0.0.1", seq: 3, start: start, outcome: "timeout"})
+
+	lines := read(2)
+	want := []string{
+		`ping_packet,host=10.0.0.1,site=bj seq=2i,outcome="success",rtt=12,ttl=64i 1709251200000000005` + "\n",
+		`ping_packet,host=10.0.0.1 seq=3i,outcome="timeout" 1709251200000000005` + "\n",
+	}
+	if strings.Join(lines, "") != strings.Join(want, "") {
+		t.Errorf("行协议 = %q，期望 %q", lines, want)
+	}
+}
+
+func TestInfluxFlags(t *testing.T) {
+	if errs := argErrors(t, "-influx-per-packet", "x"); !hasArgError(errs, "参数 -influx-per-packet 需要与 -influx-addr 同时使用") {
+		t.Errorf("错误 = %q", errs)
+	}
+	if errs := argErrors(t, "-influx-addr", "localhost:8089", "-influx-per-packet", "x"); len(errs) != 0 {
+		t.Errorf("合法的参数报错: %q", errs)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// 正在探测的目标，收到 SIGQUIT 时输出它们的阶段统计
+var interim struct {
+	mu      sync.Mutex
+	pingers []*Pinger
+}
+
+// 设置阶段统计的目标
+func setInterimPingers(pingers []*Pinger) {
+	interim.mu.Lock()
+	interim.pingers = pingers
+	interim.mu.Unlock()
+}
+
+// 输出各目标截至目前的统计到标准错误，不打断标准输出中的逐条结果，同时写入availability事件
+// 与Linux ping的 Ctrl+\ 一致，探测继续进行
+// 在信号处理的goroutine中调用，只读取Pinger中创建后不再改变的字段及加锁的统计数据
+func printInterim() {
+	interim.mu.Lock()
+	pingers := interim.pingers
+	interim.mu.Unlock()
+	for _, p := range pingers {
+		ss := p.Stats.Snapshot()
+		if ss.Sent == 0 {
+			continue
+		}
+		host := normalizeTarget(p.Arg).Host
+		fmt.Fprintf(os.Stderr, "%s%s: 已发送 = %d，已接收 = %d，丢失 = %.2f%%", host, labelSuffix(p.Labels), ss.Sent, ss.Received, ss.LossPercent())
+		if ss.Received > 0 {
+			fmt.Fprintf(os.Stderr, "，最短/平均/最长 = %d/%d/%dms", ss.Min, ss.Avg(), ss.Max)
+		}
+		av := ss.Avail
+		fmt.Fprintf(os.Stderr, "\n    可用率 = %.3f%%，离线次数 = %d，离线时长 = %s，最长离线 = %s%s\n",
+			av.Percent(), av.Outages, av.Downtime.Round(time.Millisecond), av.Longest.Round(time.Millisecond), downSuffix(av))
+		recordAvailability(host, p.Labels, av, false)
+	}
+}
+
+// 目标仍处于离线状态时的说明
+func downSuffix(av AvailSnapshot) string {
+	if !av.Down {
+		return ""
+	}
+	return "，当前离线"
+}
@@ -0,0 +1,6 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+// 当前平台没有 SIGQUIT，不支持阶段统计
+func notifyInterim() {}
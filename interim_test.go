@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// 阶段统计输出到标准错误，还没有发送请求的目标不输出
+func TestPrintInterim(t *testing.T) {
+	up := &Pinger{Arg: "https://192.0.2.1:443/", Labels: map[string]string{"site": "bj"}, Stats: newStatistics()}
+	for _, rtt := range []int64{10, 20, 30} {
+		up.Stats.addSent()
+		up.Stats.addSuccess(rtt)
+	}
+	down := &Pinger{Arg: "192.0.2.2", Stats: newStatistics()}
+	down.Stats.addSent()
+	down.Stats.addFailure()
+	idle := &Pinger{Arg: "192.0.2.3", Stats: newStatistics()}
+
+	setInterimPingers([]*Pinger{up, down, idle})
+	t.Cleanup(func() { setInterimPingers(nil) })
+	stdout, stderr := captureOutput(t, printInterim)
+	if stdout != "" {
+		t.Errorf("标准输出 %q", stdout)
+	}
+	for _, want := range []string{
+		"192.0.2.1 (site=bj): 已发送 = 3，已接收 = 3，丢失 = 0.00%，最短/平均/最长 = 10/20/30ms\n    可用率 = 100.000%，离线次数 = 0",
+		"192.0.2.2: 已发送 = 1，已接收 = 0，丢失 = 100.00%\n    可用率 = 0.000%，离线次数 = 1",
+		"，当前离线\n",
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("标准错误中没有 %q:\n%s", want, stderr)
+		}
+	}
+	if strings.Contains(stderr, "192.0.2.3") {
+		t.Errorf("没有发送请求的目标也输出了:\n%s", stderr)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// SIGQUIT(Ctrl+\) 输出阶段统计
+func notifyInterim() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGQUIT)
+	go func() {
+		for range sig {
+			printInterim()
+		}
+	}()
+}
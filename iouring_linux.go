@@ -0,0 +1,599 @@
+//go:build linux && (amd64 || arm64)
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// io_uring 相关常量，见 include/uapi/linux/io_uring.h
+const (
+	sysIOURingSetup = 425
+	sysIOURingEnter = 426
+
+	iouringOffSQRing = 0
+	iouringOffCQRing = 0x8000000
+	iouringOffSQEs   = 0x10000000
+
+	iouringOpPollAdd     = 6
+	iouringOpAsyncCancel = 14
+	iouringOpLinkTimeout = 15
+	iouringOpSend        = 26
+	iouringOpRecv        = 27
+
+	iosqeIOLink           = 1 << 2
+	iouringSetupCQSize    = 1 << 3
+	iouringFeatSingleMmap = 1 << 0
+	iouringFeatFastPoll   = 1 << 5 //5.7+，同时保证支持SEND/RECV/READ
+	iouringSQEntries      = 1024   //一次io_uring_enter最多提交的SQE数
+	iouringCQEntries      = 4096   //同时在途的SQE数上限，每个目标最多4个(发送、接收、超时、取消)
+	iouringUserDataWake   = 0      //eventfd上的POLL_ADD，唤醒等待中的提交循环
+	pollIn                = 0x1
+	iouringSQESize        = 64
+	iouringCQESize        = 16
+)
+
+type iouringSQOffsets struct {
+	head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type iouringCQOffsets struct {
+	head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type iouringParams struct {
+	sqEntries, cqEntries, flags, sqThreadCPU, sqThreadIdle, features, wqFD uint32
+	resv                                                                   [3]uint32
+	sqOff                                                                  iouringSQOffsets
+	cqOff                                                                  iouringCQOffsets
+}
+
+type iouringSQE struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	opFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFDIn  int32
+	addr3       uint64
+	pad         uint64
+}
+
+type iouringCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+type kernelTimespec struct {
+	sec, nsec int64
+}
+
+// 一组一起提交的SQE(如接收及其LINK_TIMEOUT)，全部完成后关闭done
+type uringOp struct {
+	sqes []iouringSQE
+	res  []int32
+	left int           //尚未完成的SQE数，只由提交循环访问
+	done chan struct{} //为nil表示不等待结果(取消请求)
+}
+
+// 在途SQE所属的请求及其下标
+type uringWait struct {
+	op *uringOp
+	i  int
+}
+
+// uringRing 所有uringConn共享的io_uring
+// 各目标的探测goroutine把请求放入ops后等待结果，由一个提交循环把期间积累的请求
+// 一次io_uring_enter全部提交，并一起收取完成事件按user_data交还各请求。
+// 多个目标并发探测时，发送与接收的系统调用次数不再随报文数增加
+// 提交循环等待完成事件时，新的请求通过eventfd唤醒它
+type uringRing struct {
+	fd      int
+	sqRing  []byte
+	cqRing  []byte
+	sqes    []byte
+	sqHead  *uint32
+	sqTail  *uint32
+	sqMask  uint32
+	sqArray unsafe.Pointer
+	cqHead  *uint32
+	cqTail  *uint32
+	cqMask  uint32
+	cqes    unsafe.Pointer
+
+	file     *os.File        //ring的描述符，以非阻塞模式加入netpoller
+	raw      syscall.RawConn //等待ring可读
+	efd      int             //非阻塞的eventfd
+	sleeping int32           //提交循环即将或正在等待，需要写eventfd唤醒
+	ops      chan *uringOp
+	userData uint64 //已分配的user_data
+	syscalls uint64 //提交及唤醒的系统调用次数，BenchmarkURingTargets按报文数报告
+
+	dead chan struct{} //提交循环因错误退出，之后的请求都返回err
+	err  error
+}
+
+var (
+	uringOnce   sync.Once
+	uringShared *uringRing
+	uringErr    error
+)
+
+// 进程内共享的io_uring，第一次调用时建立并启动提交循环，内核不支持时返回错误
+func sharedURing() (*uringRing, error) {
+	uringOnce.Do(func() {
+		uringShared, uringErr = newURing()
+		if uringErr == nil {
+			go uringShared.run()
+		}
+	})
+	return uringShared, uringErr
+}
+
+func newURing() (*uringRing, error) {
+	params := iouringParams{flags: iouringSetupCQSize, cqEntries: iouringCQEntries}
+	ringFD, _, errno := syscall.Syscall(sysIOURingSetup, iouringSQEntries, uintptr(unsafe.Pointer(&params)), 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("io_uring_setup: %v", errno)
+	}
+	r := &uringRing{fd: int(ringFD), efd: -1, ops: make(chan *uringOp, iouringSQEntries), dead: make(chan struct{})}
+	if params.features&iouringFeatFastPoll == 0 {
+		r.close()
+		return nil, errors.New("内核版本过低(需要5.7及以上)")
+	}
+	if err := r.mmapRings(&params); err != nil {
+		r.close()
+		return nil, err
+	}
+	efd, _, errno := syscall.Syscall(syscall.SYS_EVENTFD2, 0, syscall.O_CLOEXEC|syscall.O_NONBLOCK, 0)
+	if errno != 0 {
+		r.close()
+		return nil, fmt.Errorf("eventfd: %v", errno)
+	}
+	r.efd = int(efd)
+	//非阻塞的描述符由os.NewFile加入netpoller，完成队列非空时ring可读
+	if err := syscall.SetNonblock(r.fd, true); err != nil {
+		r.close()
+		return nil, err
+	}
+	r.file = os.NewFile(uintptr(r.fd), "io_uring")
+	raw, err := r.file.SyscallConn()
+	if err != nil {
+		r.close()
+		return nil, err
+	}
+	r.raw = raw
+	return r, nil
+}
+
+// 映射提交队列、完成队列及SQE数组
+func (r *uringRing) mmapRings(p *iouringParams) error {
+	sqSize := int(p.sqOff.array + p.sqEntries*4)
+	cqSize := int(p.cqOff.cqes + p.cqEntries*iouringCQESize)
+	if p.features&iouringFeatSingleMmap != 0 && cqSize > sqSize {
+		sqSize = cqSize
+	}
+
+	var err error
+	r.sqRing, err = syscall.Mmap(r.fd, iouringOffSQRing, sqSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+	if err != nil {
+		return fmt.Errorf("mmap SQ: %v", err)
+	}
+	r.cqRing = r.sqRing
+	if p.features&iouringFeatSingleMmap == 0 {
+		r.cqRing, err = syscall.Mmap(r.fd, iouringOffCQRing, cqSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+		if err != nil {
+			return fmt.Errorf("mmap CQ: %v", err)
+		}
+	}
+	r.sqes, err = syscall.Mmap(r.fd, iouringOffSQEs, int(p.sqEntries)*iouringSQESize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+	if err != nil {
+		return fmt.Errorf("mmap SQEs: %v", err)
+	}
+
+	sq, cq := unsafe.Pointer(&r.sqRing[0]), unsafe.Pointer(&r.cqRing[0])
+	r.sqHead = (*uint32)(unsafe.Add(sq, p.sqOff.head))
+	r.sqTail = (*uint32)(unsafe.Add(sq, p.sqOff.tail))
+	r.sqMask = *(*uint32)(unsafe.Add(sq, p.sqOff.ringMask))
+	r.sqArray = unsafe.Add(sq, p.sqOff.array)
+	r.cqHead = (*uint32)(unsafe.Add(cq, p.cqOff.head))
+	r.cqTail = (*uint32)(unsafe.Add(cq, p.cqOff.tail))
+	r.cqMask = *(*uint32)(unsafe.Add(cq, p.cqOff.ringMask))
+	r.cqes = unsafe.Add(cq, p.cqOff.cqes)
+	return nil
+}
+
+func (r *uringRing) close() {
+	if r.sqes != nil {
+		syscall.Munmap(r.sqes)
+	}
+	if len(r.cqRing) > 0 && &r.cqRing[0] != &r.sqRing[0] {
+		syscall.Munmap(r.cqRing)
+	}
+	if r.sqRing != nil {
+		syscall.Munmap(r.sqRing)
+	}
+	if r.efd >= 0 {
+		syscall.Close(r.efd)
+	}
+	if r.file != nil {
+		r.file.Close()
+	} else {
+		syscall.Close(r.fd)
+	}
+}
+
+// 分配一个user_data，取消请求按它找到要取消的SQE
+func (r *uringRing) nextUserData() uint64 {
+	return atomic.AddUint64(&r.userData, 1)
+}
+
+// 把一组SQE交给提交循环，不等待完成
+func (r *uringRing) post(op *uringOp) error {
+	select {
+	case r.ops <- op:
+	case <-r.dead:
+		return r.err
+	}
+	if atomic.CompareAndSwapInt32(&r.sleeping, 1, 0) {
+		one := [8]byte{1} //eventfd的计数为本机字节序的uint64，amd64及arm64都是小端
+		syscall.Write(r.efd, one[:])
+		atomic.AddUint64(&r.syscalls, 1)
+	}
+	return nil
+}
+
+// 等待post的请求全部完成，返回各SQE的结果
+func (r *uringRing) wait(op *uringOp) ([]int32, error) {
+	select {
+	case <-op.done:
+		return op.res, nil
+	case <-r.dead:
+		return nil, r.err
+	}
+}
+
+// 提交一组SQE并等待全部完成
+func (r *uringRing) do(sqes ...iouringSQE) ([]int32, error) {
+	op := &uringOp{sqes: sqes, res: make([]int32, len(sqes)), done: make(chan struct{})}
+	if err := r.post(op); err != nil {
+		return nil, err
+	}
+	return r.wait(op)
+}
+
+// 把一个SQE放入提交队列，只由提交循环调用
+func (r *uringRing) push(sqe iouringSQE) {
+	tail := atomic.LoadUint32(r.sqTail)
+	idx := tail & r.sqMask
+	*(*iouringSQE)(unsafe.Pointer(&r.sqes[idx*iouringSQESize])) = sqe
+	*(*uint32)(unsafe.Add(r.sqArray, idx*4)) = idx
+	atomic.StoreUint32(r.sqTail, tail+1)
+}
+
+// 提交循环：取出期间积累的全部请求，一次io_uring_enter提交，再一起收取全部完成事件
+// 没有可做的事时在netpoller上等待ring可读(完成队列非空)，不阻塞在系统调用中占用P，
+// 新的请求写eventfd，eventfd上的POLL_ADD完成使ring可读
+func (r *uringRing) run() {
+	waits := map[uint64]uringWait{}
+	var held *uringOp //在途SQE已达上限，等待有请求完成后再提交
+	inflight, unsubmitted := 0, 0
+	armed := false //eventfd上有在途的POLL_ADD
+	for {
+		if !armed && unsubmitted < iouringSQEntries {
+			r.push(iouringSQE{opcode: iouringOpPollAdd, fd: int32(r.efd), opFlags: pollIn, userData: iouringUserDataWake})
+			inflight++
+			unsubmitted++
+			armed = true
+		}
+		for {
+			op := held
+			held = nil
+			if op == nil {
+				select {
+				case op = <-r.ops:
+				default:
+				}
+			}
+			if op == nil {
+				break
+			}
+			if inflight+len(op.sqes) > iouringCQEntries || unsubmitted+len(op.sqes) > iouringSQEntries {
+				held = op
+				break
+			}
+			for i, sqe := range op.sqes {
+				r.push(sqe)
+				waits[sqe.userData] = uringWait{op, i}
+			}
+			op.left = len(op.sqes)
+			inflight += len(op.sqes)
+			unsubmitted += len(op.sqes)
+		}
+
+		if unsubmitted > 0 {
+			n, _, errno := syscall.Syscall6(sysIOURingEnter, uintptr(r.fd), uintptr(unsubmitted), 0, 0, 0, 0)
+			atomic.AddUint64(&r.syscalls, 1)
+			switch errno {
+			case 0:
+				unsubmitted -= int(n)
+			case syscall.EINTR, syscall.EAGAIN, syscall.EBUSY:
+				//被信号打断，或完成队列已满需要先收取
+			default:
+				r.err = fmt.Errorf("io_uring_enter: %v", errno)
+				close(r.dead)
+				return
+			}
+		}
+
+		reaped := false
+		for head := atomic.LoadUint32(r.cqHead); head != atomic.LoadUint32(r.cqTail); head++ {
+			cqe := (*iouringCQE)(unsafe.Add(r.cqes, (head&r.cqMask)*iouringCQESize))
+			inflight--
+			reaped = true
+			if cqe.userData == iouringUserDataWake {
+				var buf [8]byte
+				syscall.Read(r.efd, buf[:]) //清零计数，非阻塞
+				atomic.AddUint64(&r.syscalls, 1)
+				armed = false
+			} else if w, ok := waits[cqe.userData]; ok {
+				delete(waits, cqe.userData)
+				w.op.res[w.i] = cqe.res
+				if w.op.left--; w.op.left == 0 && w.op.done != nil {
+					close(w.op.done)
+				}
+			}
+			atomic.StoreUint32(r.cqHead, head+1)
+		}
+		if reaped || held == nil && len(r.ops) > 0 {
+			continue
+		}
+
+		//之后post的请求看到sleeping后写eventfd
+		atomic.StoreInt32(&r.sleeping, 1)
+		if held == nil && len(r.ops) > 0 && atomic.CompareAndSwapInt32(&r.sleeping, 1, 0) {
+			continue
+		}
+		err := r.raw.Read(func(uintptr) bool {
+			return atomic.LoadUint32(r.cqHead) != atomic.LoadUint32(r.cqTail)
+		})
+		atomic.StoreInt32(&r.sleeping, 0)
+		if err != nil {
+			r.err = fmt.Errorf("等待io_uring完成事件: %v", err)
+			close(r.dead)
+			return
+		}
+	}
+}
+
+// uringConn 通过共享的io_uring收发ICMP报文
+// 接收超时由内核的 LINK_TIMEOUT 实现。Read、Write由探测goroutine依次调用；
+// SetReadDeadline 可能由其他goroutine调用(如Ctrl+C时的interruptOnStop)，此时以 ASYNC_CANCEL
+// 取消正在等待的接收请求，Read按新的截止时间返回超时或重新提交。
+// 接收请求与取消请求都在mu内交给提交循环，取消不会先于要取消的接收提交
+type uringConn struct {
+	ring   *uringRing
+	file   *os.File //阻塞模式的socket
+	fd     int32
+	remote net.Addr
+	local  net.Addr
+	ts     *kernelTimespec //LINK_TIMEOUT使用，需在请求提交前保持有效
+
+	mu       sync.Mutex
+	deadline time.Time
+	recv     *uringOp //在途的接收请求，由Write预先提交或Read提交
+	recvBuf  []byte   //recv接收到的缓冲区，Write预先提交时为rbuf
+	rbuf     []byte
+	pending  uint64 //recv的user_data，0表示没有
+	waiting  bool   //Read正在等待recv，Close时由Read在返回前关闭socket
+	closed   bool
+}
+
+// 超时错误，与net包的超时错误一样实现 Timeout()
+type uringTimeoutError struct{}
+
+func (uringTimeoutError) Error() string   { return "i/o timeout" }
+func (uringTimeoutError) Timeout() bool   { return true }
+func (uringTimeoutError) Temporary() bool { return true }
+
+// 把已建立的连接切换为io_uring收发，内核不支持时返回错误，由调用方继续使用原连接
+func newURingConn(conn net.Conn) (net.Conn, error) {
+	ring, err := sharedURing()
+	if err != nil {
+		return nil, err
+	}
+	ipc, ok := conn.(*net.IPConn)
+	if !ok {
+		return nil, errors.New("不是原始套接字")
+	}
+
+	//File()返回的是复制的描述符，调用Fd()会将其切换为阻塞模式，原连接随后关闭
+	f, err := ipc.File()
+	if err != nil {
+		return nil, err
+	}
+	c := &uringConn{ring: ring, file: f, fd: int32(f.Fd()), remote: conn.RemoteAddr(), local: conn.LocalAddr(), ts: &kernelTimespec{}}
+	conn.Close()
+	return c, nil
+}
+
+func (c *uringConn) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	send := &uringOp{
+		sqes: []iouringSQE{{opcode: iouringOpSend, fd: c.fd, addr: uint64(uintptr(unsafe.Pointer(&b[0]))), len: uint32(len(b)), userData: c.ring.nextUserData()}},
+		res:  make([]int32, 1),
+		done: make(chan struct{}),
+	}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return 0, net.ErrClosed
+	}
+	err := c.ring.post(send)
+	if err == nil && c.recv == nil {
+		//应答通常紧随请求到达，接收与发送一起提交，Read直接等待这次接收
+		if size := len(b) + 60; len(c.rbuf) < size {
+			c.rbuf = make([]byte, size+1500)
+		}
+		c.postRecv(c.rbuf)
+	}
+	c.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	res, err := c.ring.wait(send)
+	runtime.KeepAlive(b)
+	if err != nil {
+		return 0, err
+	}
+	if res[0] < 0 {
+		return 0, syscall.Errno(-res[0])
+	}
+	return int(res[0]), nil
+}
+
+// 按当前的截止时间提交接收到buf的请求，调用方需持有mu
+func (c *uringConn) postRecv(buf []byte) error {
+	ud := c.ring.nextUserData()
+	op := &uringOp{sqes: []iouringSQE{{opcode: iouringOpRecv, fd: c.fd, addr: uint64(uintptr(unsafe.Pointer(&buf[0]))), len: uint32(len(buf)), userData: ud}}, done: make(chan struct{})}
+	if !c.deadline.IsZero() {
+		d := time.Until(c.deadline)
+		if d <= 0 {
+			return uringTimeoutError{}
+		}
+		c.ts.sec, c.ts.nsec = int64(d/time.Second), int64(d%time.Second)
+		op.sqes[0].flags = iosqeIOLink
+		op.sqes = append(op.sqes, iouringSQE{opcode: iouringOpLinkTimeout, addr: uint64(uintptr(unsafe.Pointer(c.ts))), len: 1, userData: c.ring.nextUserData()})
+	}
+	op.res = make([]int32, len(op.sqes))
+	if err := c.ring.post(op); err != nil {
+		return err
+	}
+	c.recv, c.recvBuf, c.pending = op, buf, ud
+	return nil
+}
+
+func (c *uringConn) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return 0, net.ErrClosed
+		}
+		if c.recv == nil {
+			if err := c.postRecv(b); err != nil {
+				c.mu.Unlock()
+				return 0, err
+			}
+		}
+		op, buf := c.recv, c.recvBuf
+		c.waiting = true
+		c.mu.Unlock()
+
+		res, err := c.ring.wait(op)
+		runtime.KeepAlive(buf)
+		c.mu.Lock()
+		c.recv, c.recvBuf, c.pending, c.waiting = nil, nil, 0, false
+		closed := c.closed
+		c.mu.Unlock()
+		if closed {
+			//接收请求完成前关闭socket，描述符可能被复用，提交循环中的请求会读到其他socket
+			c.file.Close()
+			return 0, net.ErrClosed
+		}
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case res[0] == -int32(syscall.ECANCELED):
+			//LINK_TIMEOUT到期，或截止时间被修改后取消；按当前的截止时间决定返回超时还是重新等待
+			if c.expired() {
+				return 0, uringTimeoutError{}
+			}
+			continue
+		case res[0] < 0:
+			return 0, syscall.Errno(-res[0])
+		}
+		if &buf[0] != &b[0] {
+			return copy(b, buf[:res[0]]), nil
+		}
+		return int(res[0]), nil
+	}
+}
+
+// 当前的截止时间是否已过
+func (c *uringConn) expired() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.deadline.IsZero() && !time.Now().Before(c.deadline)
+}
+
+// 取消正在等待的接收请求，调用方需持有mu
+// 接收请求可能已经完成，此时取消请求返回ENOENT，user_data不同也不会误取消之后的接收
+func (c *uringConn) cancelPending() error {
+	if c.pending == 0 {
+		return nil
+	}
+	return c.ring.post(&uringOp{sqes: []iouringSQE{{opcode: iouringOpAsyncCancel, addr: c.pending, userData: c.ring.nextUserData()}}, res: make([]int32, 1)})
+}
+
+// 修改截止时间，有正在等待的接收请求时将其取消，由Read按新的截止时间处理
+func (c *uringConn) setReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return c.cancelPending()
+}
+
+func (c *uringConn) SetDeadline(t time.Time) error      { return c.setReadDeadline(t) }
+func (c *uringConn) SetReadDeadline(t time.Time) error  { return c.setReadDeadline(t) }
+func (c *uringConn) SetWriteDeadline(t time.Time) error { return nil }
+func (c *uringConn) LocalAddr() net.Addr                { return c.local }
+func (c *uringConn) RemoteAddr() net.Addr               { return c.remote }
+
+// 关闭socket，共享的io_uring不关闭
+// 有在途的接收请求时将其取消：Read正在等待时由Read在请求完成后关闭socket并返回net.ErrClosed，
+// 否则(Write预先提交的接收)等待请求完成后再关闭
+func (c *uringConn) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return net.ErrClosed
+	}
+	c.closed = true
+	op, waiting := c.recv, c.waiting
+	if op != nil {
+		c.cancelPending()
+	}
+	c.mu.Unlock()
+	if waiting {
+		return nil
+	}
+	if op != nil {
+		c.ring.wait(op)
+	}
+	return c.file.Close()
+}
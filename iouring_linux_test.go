@@ -0,0 +1,220 @@
+//go:build linux && (amd64 || arm64)
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 建立指向addr的io_uring连接，内核不支持时跳过
+func newTestURingConn(t *testing.T, addr string) net.Conn {
+	t.Helper()
+	needRawSocket(t)
+	conn, err := net.Dial("ip4:icmp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc, err := newURingConn(conn)
+	if err != nil {
+		conn.Close()
+		t.Skipf("io_uring 不可用: %v", err)
+	}
+	t.Cleanup(func() { uc.Close() })
+	return uc
+}
+
+func TestURingConnExchange(t *testing.T) {
+	uc := newTestURingConn(t, "127.0.0.1")
+	data := make([]byte, 8+32)
+	if err := fillEcho(data, 9); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1500)
+	n, _, _, err := exchange(uc, nil, data, 2*time.Second, buf, true)
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	r, err := parseEchoReply(buf[:n])
+	if err != nil {
+		t.Fatalf("parseEchoReply: %v", err)
+	}
+	if r.Seq != 9 || r.Bytes != 32 {
+		t.Fatalf("应答 = %+v", r)
+	}
+}
+
+// 其他goroutine修改截止时间时，阻塞中的Read按新的截止时间返回
+func TestURingConnDeadlineWakesRead(t *testing.T) {
+	tests := []struct {
+		name     string
+		initial  time.Duration //0表示不设截止时间
+		change   func() time.Time
+		min, max time.Duration
+	}{
+		{"无截止时间时立即超时", 0, time.Now, 0, time.Second},
+		{"缩短截止时间", time.Hour, time.Now, 0, time.Second},
+		{"延长截止时间", 50 * time.Millisecond, func() time.Time { return time.Now().Add(300 * time.Millisecond) }, 250 * time.Millisecond, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := newTestURingConn(t, "203.0.113.1") //TEST-NET-3，不会有报文到达
+			if tt.initial > 0 {
+				uc.SetReadDeadline(time.Now().Add(tt.initial))
+			}
+			done := make(chan error, 1)
+			start := time.Now()
+			go func() {
+				_, err := uc.Read(make([]byte, 1500))
+				done <- err
+			}()
+			time.Sleep(20 * time.Millisecond)
+			uc.SetReadDeadline(tt.change())
+			select {
+			case err := <-done:
+				elapsed := time.Since(start)
+				if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+					t.Fatalf("Read 错误 = %v，应为超时", err)
+				}
+				if elapsed < tt.min || elapsed > tt.max {
+					t.Fatalf("Read 在 %v 后返回，应在 %v-%v 之间", elapsed, tt.min, tt.max)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("修改截止时间没有唤醒阻塞的Read")
+			}
+		})
+	}
+}
+
+// 关闭连接时唤醒阻塞的Read，返回net.ErrClosed
+func TestURingConnCloseWakesRead(t *testing.T) {
+	uc := newTestURingConn(t, "203.0.113.1")
+	done := make(chan error, 1)
+	go func() {
+		_, err := uc.Read(make([]byte, 1500))
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	uc.Close()
+	select {
+	case err := <-done:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Fatalf("Read 错误 = %v，应为 net.ErrClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("关闭连接没有唤醒阻塞的Read")
+	}
+	if _, err := uc.Write(make([]byte, 8)); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("关闭后 Write 错误 = %v", err)
+	}
+}
+
+// 第i个目标的连接，127.0.0.0/8 内的地址都由本机应答
+func uringTargets(tb testing.TB, n int, dial func(string) (net.Conn, error)) []net.Conn {
+	tb.Helper()
+	needRawSocket(tb)
+	conns := make([]net.Conn, n)
+	for i := range conns {
+		conn, err := dial(fmt.Sprintf("127.0.%d.%d", i/250, i%250+1))
+		if err != nil {
+			tb.Skipf("无法为 %d 个目标建立连接: %v", n, err)
+		}
+		tb.Cleanup(func() { conn.Close() })
+		conns[i] = conn
+	}
+	return conns
+}
+
+func dialURing(addr string) (net.Conn, error) {
+	conn, err := net.Dial("ip4:icmp", addr)
+	if err != nil {
+		return nil, err
+	}
+	uc, err := newURingConn(conn)
+	if err != nil {
+		conn.Close()
+	}
+	return uc, err
+}
+
+// 所有目标并发探测一轮，每个应答来自各自的目标且序号为seq
+func uringRound(conns []net.Conn, seq int) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, len(conns))
+	for _, conn := range conns {
+		wg.Add(1)
+		go func(conn net.Conn) {
+			defer wg.Done()
+			data := make([]byte, 8+32)
+			if err := fillEcho(data, seq); err != nil {
+				errs <- err
+				return
+			}
+			buf := make([]byte, 1500)
+			n, _, _, err := exchange(conn, nil, data, 3*time.Second, buf, true)
+			if err != nil {
+				errs <- fmt.Errorf("%s: %v", conn.RemoteAddr(), err)
+				return
+			}
+			r, err := parseEchoReply(buf[:n])
+			if err != nil || r.Seq != seq&0xffff || r.Src.String() != conn.RemoteAddr().String() {
+				errs <- fmt.Errorf("%s: 应答 = %+v, %v", conn.RemoteAddr(), r, err)
+			}
+		}(conn)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// 数百个目标共享一个io_uring并发收发，各自收到自己的应答
+func TestURingConnConcurrentTargets(t *testing.T) {
+	if _, err := sharedURing(); err != nil {
+		t.Skipf("io_uring 不可用: %v", err)
+	}
+	conns := uringTargets(t, 300, dialURing)
+	for seq := 1; seq <= 3; seq++ {
+		if err := uringRound(conns, seq); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// 每轮所有目标并发探测一次：各目标单独的原始套接字分别以标准socket及共享的io_uring收发
+// io_uring时另外报告每个报文(请求及应答)平均的系统调用次数，目标越多一次提交的请求越多
+func BenchmarkURingTargets(b *testing.B) {
+	ring, err := sharedURing()
+	if err != nil {
+		b.Skipf("io_uring 不可用: %v", err)
+	}
+	dials := []struct {
+		name string
+		dial func(string) (net.Conn, error)
+	}{
+		{"socket", func(addr string) (net.Conn, error) { return net.Dial("ip4:icmp", addr) }},
+		{"iouring", dialURing},
+	}
+	for _, n := range []int{1, 10, 100, 1000} {
+		for _, d := range dials {
+			b.Run(fmt.Sprintf("%s/targets=%d", d.name, n), func(b *testing.B) {
+				conns := uringTargets(b, n, d.dial)
+				b.ResetTimer()
+				start, syscalls := time.Now(), atomic.LoadUint64(&ring.syscalls)
+				for i := 0; i < b.N; i++ {
+					if err := uringRound(conns, i+1); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(b.N*n)/time.Since(start).Seconds(), "probes/s")
+				if d.name == "iouring" {
+					b.ReportMetric(float64(atomic.LoadUint64(&ring.syscalls)-syscalls)/float64(2*b.N*n), "syscalls/pkt")
+				}
+			})
+		}
+	}
+}
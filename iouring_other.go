@@ -0,0 +1,13 @@
+//go:build !(linux && (amd64 || arm64))
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+// 把已建立的连接切换为io_uring收发，仅Linux(amd64/arm64)支持
+func newURingConn(conn net.Conn) (net.Conn, error) {
+	return nil, errors.New("当前平台不支持io_uring")
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	ipOptTS          = 68  //时间戳
+	ipOptRouterAlert = 148 //路由器告警
+)
+
+var verbose bool //是否输出详细信息
+
+// 解码IP选项，每个选项返回一行描述
+// 长度非法的选项不会导致越界，而是给出提示并停止解析
+func decodeIPOptions(opts []byte) []string {
+	var lines []string
+	for i := 0; i < len(opts); {
+		t := opts[i]
+		switch t {
+		case ipOptEOL:
+			return lines
+		case ipOptNOP:
+			i++
+			continue
+		}
+
+		if i+1 >= len(opts) {
+			return append(lines, fmt.Sprintf("选项 %d: 缺少长度字段", t))
+		}
+		l := int(opts[i+1])
+		if l < 2 || i+l > len(opts) {
+			return append(lines, fmt.Sprintf("选项 %d: 长度 %d 非法", t, l))
+		}
+		opt := opts[i : i+l]
+		i += l
+
+		switch t {
+		case ipOptRR, ipOptLSRR, ipOptSSRR:
+			lines = append(lines, decodeRouteOption(opt))
+		case ipOptTS:
+			lines = append(lines, decodeTimestampOption(opt))
+		case ipOptRouterAlert:
+			if l != 4 {
+				lines = append(lines, fmt.Sprintf("路由器告警: 长度 %d 非法", l))
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("路由器告警: 值=%d", binary.BigEndian.Uint16(opt[2:4])))
+		default:
+			lines = append(lines, fmt.Sprintf("未知选项: type=%d len=%d data=% x", t, l, opt[2:]))
+		}
+	}
+	return lines
+}
+
+// 解码记录路由/源路由选项：type + length + pointer + 地址列表
+func decodeRouteOption(opt []byte) string {
+	name := map[byte]string{ipOptRR: "记录路由", ipOptLSRR: "松散源路由", ipOptSSRR: "严格源路由"}[opt[0]]
+	if len(opt) < 3 {
+		return name + ": 长度非法"
+	}
+	var hops []string
+	for j := 3; j+4 <= len(opt); j += 4 {
+		hops = append(hops, net.IP(opt[j:j+4]).String())
+	}
+	return fmt.Sprintf("%s: %s", name, strings.Join(hops, " -> "))
+}
+
+// 解码时间戳选项：type + length + pointer + 溢出计数(高4位)/标志(低4位) + 数据
+// 标志为0时只有时间戳，为1或3时为 地址+时间戳 对
+func decodeTimestampOption(opt []byte) string {
+	if len(opt) < 4 {
+		return "时间戳: 长度非法"
+	}
+	ptr := int(opt[2])
+	overflow, flag := opt[3]>>4, opt[3]&0x0f
+
+	//pointer从1开始计数，指向下一个空闲位置，之前的部分为已填写的数据
+	end := ptr - 1
+	if end > len(opt) {
+		end = len(opt)
+	}
+
+	var entries []string
+	switch flag {
+	case 0:
+		for j := 4; j+4 <= end; j += 4 {
+			entries = append(entries, fmt.Sprintf("%dms", binary.BigEndian.Uint32(opt[j:j+4])))
+		}
+	case 1, 3:
+		for j := 4; j+8 <= end; j += 8 {
+			entries = append(entries, fmt.Sprintf("%s@%dms", net.IP(opt[j:j+4]), binary.BigEndian.Uint32(opt[j+4:j+8])))
+		}
+	default:
+		return fmt.Sprintf("时间戳: 未知标志 %d", flag)
+	}
+	return fmt.Sprintf("时间戳: %s (溢出=%d)", strings.Join(entries, " "), overflow)
+}
+
+// 输出回复中携带的IP选项
+func printReplyOptions(buf []byte) {
+	for _, line := range decodeIPOptions(replyIPOptions(buf)) {
+		fmt.Printf("    %s\n", line)
+	}
+}
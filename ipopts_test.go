@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeIPOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []byte
+		want []string
+	}{
+		{"空", nil, nil},
+		{"只有填充", []byte{ipOptNOP, ipOptNOP, ipOptEOL, 0xff}, nil},
+		{"记录路由", []byte{ipOptRR, 11, 12, 10, 0, 0, 1, 10, 0, 0, 2, ipOptEOL}, []string{"记录路由: 10.0.0.1 -> 10.0.0.2"}},
+		{"松散源路由", []byte{ipOptLSRR, 7, 8, 192, 0, 2, 1, ipOptEOL}, []string{"松散源路由: 192.0.2.1"}},
+		{"时间戳", []byte{ipOptTS, 12, 13, 0x20, 0, 0, 0, 100, 0, 0, 1, 0}, []string{"时间戳: 100ms 256ms (溢出=2)"}},
+		{"地址及时间戳", []byte{ipOptTS, 20, 13, 0x01, 10, 0, 0, 1, 0, 0, 0, 5, 0, 0, 0, 0, 0, 0, 0, 0}, []string{"时间戳: 10.0.0.1@5ms (溢出=0)"}},
+		{"时间戳未知标志", []byte{ipOptTS, 4, 5, 0x02}, []string{"时间戳: 未知标志 2"}},
+		{"路由器告警", []byte{ipOptRouterAlert, 4, 0, 0}, []string{"路由器告警: 值=0"}},
+		{"路由器告警长度非法", []byte{ipOptRouterAlert, 3, 0}, []string{"路由器告警: 长度 3 非法"}},
+		{"未知选项", []byte{0x99, 4, 0xab, 0xcd}, []string{"未知选项: type=153 len=4 data=ab cd"}},
+		{"多个选项", []byte{ipOptNOP, ipOptRouterAlert, 4, 0, 1, 0x99, 2}, []string{"路由器告警: 值=1", "未知选项: type=153 len=2 data="}},
+		{"缺少长度", []byte{ipOptRR}, []string{"选项 7: 缺少长度字段"}},
+		{"长度过小", []byte{ipOptRR, 1, 0, 0}, []string{"选项 7: 长度 1 非法"}},
+		{"长度越界", []byte{ipOptRR, 40, 4, 10, 0, 0, 1}, []string{"选项 7: 长度 40 非法"}},
+		{"时间戳指针越界", []byte{ipOptTS, 8, 200, 0x00, 0, 0, 0, 7}, []string{"时间戳: 7ms (溢出=0)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeIPOptions(tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("decodeIPOptions(% x) = %q，应为 %q", tt.opts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplyIPOptions(t *testing.T) {
+	hdr := func(ihl int, n int) []byte {
+		b := make([]byte, n)
+		b[0] = 0x40 | byte(ihl)
+		for i := 20; i < n; i++ {
+			b[i] = byte(i)
+		}
+		return b
+	}
+	tests := []struct {
+		name string
+		buf  []byte
+		want int //选项的字节数
+	}{
+		{"过短", hdr(5, 10), 0},
+		{"没有选项", hdr(5, 28), 0},
+		{"24字节的IP头", hdr(6, 32), 4},
+		{"最长的IP头", hdr(15, 68), 40},
+		{"IHL超过报文长度", hdr(15, 40), 0},
+		{"IHL小于5", hdr(2, 28), 0},
+	}
+	for _, tt := range tests {
+		if got := replyIPOptions(tt.buf); len(got) != tt.want {
+			t.Errorf("%s: 选项长度 = %d，应为 %d", tt.name, len(got), tt.want)
+		}
+	}
+}
+
+// 任意选项字节(包括长度、指针非法的)都不能导致panic
+func FuzzDecodeIPOptions(f *testing.F) {
+	f.Add([]byte{ipOptRR, 11, 12, 10, 0, 0, 1, 10, 0, 0, 2, ipOptEOL})
+	f.Add([]byte{ipOptTS, 12, 13, 0x20, 0, 0, 0, 100, 0, 0, 1, 0})
+	f.Add([]byte{ipOptTS, 20, 255, 0x03, 10, 0, 0, 1, 0, 0, 0, 5})
+	f.Add([]byte{ipOptRouterAlert, 4, 0, 0})
+	f.Add([]byte{ipOptLSRR, 3, 4})
+	f.Add([]byte{0x99, 2, ipOptNOP, 0x44, 0})
+	f.Fuzz(func(t *testing.T, opts []byte) {
+		if len(opts) > 40 {
+			opts = opts[:40] //IP头最多40字节的选项
+		}
+		for _, line := range decodeIPOptions(opts) {
+			if line == "" {
+				t.Fatalf("decodeIPOptions(% x) 返回空行", opts)
+			}
+		}
+
+		//同样的字节作为回复IP头的选项部分
+		buf := make([]byte, 20+len(opts)+8)
+		buf[0] = 0x40 | byte((20+len(opts))/4)
+		copy(buf[20:], opts)
+		decodeIPOptions(replyIPOptions(buf))
+	})
+}
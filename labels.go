@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 命令行中 host=label 形式的标签所使用的标签名
+const cliLabelName = "name"
+
+// 命令行目标的标签，key为展开后的目标
+var targetLabels = map[string]map[string]string{}
+
+// 标签名是否合法
+// 取各输出中最严格的Prometheus规则：[a-zA-Z_][a-zA-Z0-9_]*，且不能以__开头(保留给内部使用)
+func validLabelName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "__") {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// 校验一组标签，标签值可以是任意UTF-8字符串，但不能为空
+func validateLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if !validLabelName(k) {
+			return fmt.Errorf("标签名 %q 不合法，只能包含字母、数字和下划线，不能以数字或__开头", k)
+		}
+		if v == "" {
+			return fmt.Errorf("标签 %q 的值为空", k)
+		}
+	}
+	return nil
+}
+
+// 拆分命令行目标 host=label，没有标签时返回nil
+// URL的查询参数中可能有等号，URL目标不拆分，原样返回
+func splitTargetLabel(arg string) (string, map[string]string, error) {
+	if strings.Contains(arg, "://") {
+		return arg, nil, nil
+	}
+	host, label, ok := strings.Cut(arg, "=")
+	if !ok {
+		return arg, nil, nil
+	}
+	if host == "" || label == "" {
+		return "", nil, fmt.Errorf("无法解析目标 %q，应为 host 或 host=label", arg)
+	}
+	return host, map[string]string{cliLabelName: label}, nil
+}
+
+// 输出中附加在目标之后的标签，如 " (role=gw,site=bj)"，没有标签时为空
+func labelSuffix(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	return " (" + formatLabels(labels) + ")"
+}
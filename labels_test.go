@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidLabelName(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"name", true},
+		{"site_1", true},
+		{"_role", true},
+		{"Region", true},
+		{"", false},
+		{"1site", false},
+		{"__name", false}, //保留给Prometheus内部使用
+		{"site-1", false},
+		{"site.dc", false},
+		{"机房", false},
+	}
+	for _, tt := range tests {
+		if got := validLabelName(tt.name); got != tt.ok {
+			t.Errorf("validLabelName(%q) = %v，期望 %v", tt.name, got, tt.ok)
+		}
+	}
+	if err := validateLabels(map[string]string{"site": "北京 1"}); err != nil {
+		t.Errorf("标签值可以是任意UTF-8字符串: %v", err)
+	}
+	if err := validateLabels(map[string]string{"site": ""}); err == nil {
+		t.Error("空的标签值没有报错")
+	}
+}
+
+func TestSplitTargetLabel(t *testing.T) {
+	tests := []struct {
+		arg, host, label string
+		err              bool
+	}{
+		{"10.0.0.1", "10.0.0.1", "", false},
+		{"10.0.0.1=core-gw", "10.0.0.1", "core-gw", false},
+		{"example.com=web=1", "example.com", "web=1", false}, //只按第一个等号拆分
+		{"=gw", "", "", true},
+		{"10.0.0.1=", "", "", true},
+		{"https://example.com:8443/path?x=y", "https://example.com:8443/path?x=y", "", false}, //URL不拆分
+		{"http://[2001:db8::1]/?a=b&c=d", "http://[2001:db8::1]/?a=b&c=d", "", false},
+	}
+	for _, tt := range tests {
+		host, labels, err := splitTargetLabel(tt.arg)
+		if (err != nil) != tt.err || host != tt.host || labels[cliLabelName] != tt.label {
+			t.Errorf("splitTargetLabel(%q) = %q, %v, %v", tt.arg, host, labels, err)
+		}
+	}
+}
+
+// 命令行 host=label 的标签出现在回复、统计、JSONL/CSV记录中
+func TestLabelsEndToEnd(t *testing.T) {
+	needRawSocket(t)
+	t.Cleanup(func() { targetLabels = map[string]map[string]string{} })
+	for _, ext := range []string{".jsonl", ".csv"} {
+		t.Run(ext, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rec"+ext)
+			parseArgs(t, "-n", "2", "-i", "0", "127.0.0.1=gw")
+			hosts := getArgOfHost()
+			if len(hosts) != 1 || hosts[0] != "127.0.0.1" {
+				t.Fatalf("目标 = %q", hosts)
+			}
+			if err := startRecord(path); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(stopRecord)
+			p := newPinger(hosts[0])
+			stdout, _ := captureOutput(t, p.Run)
+			stopRecord()
+
+			for _, want := range []string{"[127.0.0.1] (name=gw) 具有", "来自 127.0.0.1 (name=gw) 的回复", "127.0.0.1 (name=gw) 的 Ping 统计信息"} {
+				if !strings.Contains(stdout, want) {
+					t.Errorf("输出中没有 %q:\n%s", want, stdout)
+				}
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if ext == ".csv" {
+				rows, err := csv.NewReader(f).ReadAll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				col := -1
+				for i, name := range rows[0] {
+					if name == "labels" {
+						col = i
+					}
+				}
+				if len(rows) != 3 || col < 0 || rows[1][col] != "name=gw" || rows[2][col] != "name=gw" {
+					t.Errorf("CSV记录 = %q", rows)
+				}
+				return
+			}
+			dec := json.NewDecoder(f)
+			var probes int
+			for dec.More() {
+				var r probeRecord
+				if err := dec.Decode(&r); err != nil {
+					t.Fatal(err)
+				}
+				if r.Outcome == "" {
+					continue //start
+				}
+				probes++
+				if r.Labels["name"] != "gw" || len(r.Labels) != 1 {
+					t.Errorf("JSONL记录的标签 = %v", r.Labels)
+				}
+			}
+			if probes != 2 {
+				t.Errorf("JSONL中有 %d 条探测记录，期望 2", probes)
+			}
+		})
+	}
+}
+
+// 回放时接受带labels、timeout_ms列及没有这两列(旧版本)的CSV记录
+func TestParseCSVRecordLabels(t *testing.T) {
+	for _, line := range []string{
+		"2024-03-01T09:00:00Z,10.0.0.1,3,12,64,success,name=gw,1000",
+		"2024-03-01T09:00:00Z,10.0.0.1,3,12,64,success,name=gw",
+		"2024-03-01T09:00:00Z,10.0.0.1,3,12,64,success,",
+		"2024-03-01T09:00:00Z,10.0.0.1,3,12,64,success",
+	} {
+		r, err := parseProbeRecord(line)
+		if err != nil || r.Target != "10.0.0.1" || r.Seq != 3 || r.RTT != 12 || r.Outcome != "success" {
+			t.Errorf("parseProbeRecord(%q) = %+v, %v", line, r, err)
+		}
+	}
+	if _, err := parseProbeRecord("2024-03-01T09:00:00Z,10.0.0.1,3,12,64,success,name=gw,1000,x"); err == nil {
+		t.Error("多出的字段没有报错")
+	}
+}
+
+// 行协议中的标签按名称排序并转义，不覆盖host
+func TestInfluxSeriesLabels(t *testing.T) {
+	tests := []struct {
+		labels map[string]string
+		want   string
+	}{
+		{nil, "ping,host=10.0.0.1"},
+		{map[string]string{"name": "gw"}, "ping,host=10.0.0.1,name=gw"},
+		{map[string]string{"site": "bj", "role": "core"}, "ping,host=10.0.0.1,role=core,site=bj"},
+		{map[string]string{"name": "core gw,1=a"}, `ping,host=10.0.0.1,name=core\ gw\,1\=a`},
+		{map[string]string{"host": "other"}, "ping,host=10.0.0.1"},
+	}
+	for _, tt := range tests {
+		if got := influxSeries("ping", "10.0.0.1", tt.labels); got != tt.want {
+			t.Errorf("influxSeries(%v) = %q，期望 %q", tt.labels, got, tt.want)
+		}
+	}
+}
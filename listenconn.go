@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"syscall"
+
+	"golang.org/x/net/icmp"
+)
+
+// 以未连接的原始套接字模拟与目标的连接：icmp.ListenPacket("ip4:icmp", "0.0.0.0")，
+// 通过WriteTo发往目标，只保留来自目标的报文
+// 用于Dial("ip4:icmp")不可用的平台
+type listenConn struct {
+	*net.IPConn
+	raddr *net.IPAddr
+}
+
+// 建立与目标的模拟连接，失败时返回ListenPacket的错误
+// icmp.PacketConn.ReadFrom会去掉IPv4头，TTL、源地址及IP选项都无法读取，
+// 所以收发直接使用其下的*net.IPConn
+func listenICMP(host string) (net.Conn, error) {
+	raddr, err := net.ResolveIPAddr("ip4", host)
+	if err != nil {
+		return nil, err
+	}
+	pc, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return nil, err
+	}
+	ipc, ok := pc.IPv4PacketConn().PacketConn.(*net.IPConn)
+	if !ok {
+		pc.Close()
+		return nil, errors.New("icmp.ListenPacket 返回的不是原始套接字")
+	}
+	return &listenConn{IPConn: ipc, raddr: raddr}, nil
+}
+
+// 读取来自目标的报文，与连接的原始套接字相同地保留IP头
+// ReadFrom会去掉IPv4头，Pinger.Run等按IP头解析的代码都会出错，这里使用ReadMsgIP
+func (c *listenConn) Read(b []byte) (int, error) {
+	for {
+		n, _, _, from, err := c.ReadMsgIP(b, nil)
+		if err != nil {
+			return n, err
+		}
+		if from != nil && from.IP.Equal(c.raddr.IP) {
+			return n, nil
+		}
+	}
+}
+
+func (c *listenConn) Write(b []byte) (int, error) {
+	return c.WriteTo(b, c.raddr)
+}
+
+func (c *listenConn) RemoteAddr() net.Addr {
+	return c.raddr
+}
+
+// 连接底层的原始套接字，兼容listenConn
+func syscallConnOf(conn net.Conn) (syscall.RawConn, error) {
+	switch c := conn.(type) {
+	case *net.IPConn:
+		return c.SyscallConn()
+	case *listenConn:
+		return c.SyscallConn()
+	}
+	return nil, errors.New("不是原始套接字")
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// 经listenConn收到的应答与连接的原始套接字相同地带IP头，可以按Pinger.Run的方式解析
+func TestListenConnKeepsIPHeader(t *testing.T) {
+	needRawSocket(t)
+	conn, err := listenICMP("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	data := make([]byte, 8+32)
+	if err := fillEcho(data, 7); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1500)
+	n, _, _, err := exchange(conn, nil, data, 2*time.Second, buf, true)
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if err := checkIPv4Header(buf[:n]); err != nil {
+		t.Fatalf("checkIPv4Header: %v", err)
+	}
+	r, err := parseEchoReply(buf[:n])
+	if err != nil {
+		t.Fatalf("parseEchoReply: %v", err)
+	}
+	if !r.Src.Equal(net.IPv4(127, 0, 0, 1)) || r.Seq != 7 || r.Bytes != 32 || r.TTL == 0 {
+		t.Fatalf("应答 = %+v", r)
+	}
+}
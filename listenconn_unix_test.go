@@ -0,0 +1,37 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import (
+	"syscall"
+	"testing"
+)
+
+// 套接字选项同样可以设置在listenConn底层的原始套接字上
+func TestSyscallConnOf(t *testing.T) {
+	needRawSocket(t)
+	conn, err := listenICMP("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := setTTL(conn, 5); err != nil {
+		t.Fatalf("setTTL: %v", err)
+	}
+	raw, err := syscallConnOf(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ttl int
+	var gerr error
+	raw.Control(func(fd uintptr) {
+		ttl, gerr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TTL)
+	})
+	if gerr != nil || ttl != 5 {
+		t.Errorf("IP_TTL = %d, %v，期望 5", ttl, gerr)
+	}
+
+	if _, err := syscallConnOf(newMockConn()); err == nil {
+		t.Error("syscallConnOf(mockConn) 没有报错")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+//由IEEE的oui.txt生成精简的厂商前缀表(需先下载 https://standards-oui.ieee.org/oui/oui.txt)
+//go:generate go run gen_oui.go -in oui.txt -out oui_table.go
+
+var showMAC bool //-mac 在回复中显示直连回复者的MAC地址及厂商(仅Linux)
+
+// 按MAC地址的前3字节(OUI)查找厂商，未知时为空
+func lookupOUI(mac net.HardwareAddr) string {
+	if len(mac) < 3 {
+		return ""
+	}
+	return ouiVendors[fmt.Sprintf("%02x:%02x:%02x", mac[0], mac[1], mac[2])]
+}
+
+// 回复中MAC地址的显示文本，如 " [b8:27:eb:12:34:56 Raspberry Pi Foundation]"，
+// 厂商未知时只有MAC，没有MAC时为空
+func macSuffix(mac net.HardwareAddr) string {
+	if mac == nil {
+		return ""
+	}
+	if vendor := lookupOUI(mac); vendor != "" {
+		return " [" + mac.String() + " " + vendor + "]"
+	}
+	return " [" + mac.String() + "]"
+}
+
+// 跟踪回复者的MAC地址：同一次运行中变化说明可能是ARP欺骗或HA切换异常
+type macTracker struct {
+	last    net.HardwareAddr
+	changes int
+}
+
+// 记录一次回复者的MAC地址，与上一次不同时返回上一次的地址
+func (t *macTracker) observe(mac net.HardwareAddr) (net.HardwareAddr, bool) {
+	prev := t.last
+	t.last = mac
+	if prev == nil || strings.EqualFold(prev.String(), mac.String()) {
+		return nil, false
+	}
+	t.changes++
+	return prev, true
+}
@@ -0,0 +1,10 @@
+package main
+
+import "net"
+
+const macSupported = true
+
+// 从ARP表(邻居表)查找回复者的MAC地址，回复来自直连网络之外时查不到
+func neighborCacheMAC(ip net.IP) (net.HardwareAddr, error) {
+	return lookupARP(ip, "")
+}
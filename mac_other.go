@@ -0,0 +1,15 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+const macSupported = false
+
+// 从邻居表查找MAC地址，仅Linux支持
+func neighborCacheMAC(ip net.IP) (net.HardwareAddr, error) {
+	return nil, errors.New("当前平台不支持读取邻居表")
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func mustMAC(t *testing.T, s string) net.HardwareAddr {
+	t.Helper()
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mac
+}
+
+func TestLookupOUI(t *testing.T) {
+	tests := []struct {
+		mac    string
+		vendor string
+	}{
+		{"b8:27:eb:12:34:56", "Raspberry Pi Foundation"},
+		{"B8-27-EB-12-34-56", "Raspberry Pi Foundation"}, //大写及连字符
+		{"00:0c:29:ab:cd:ef", "VMware, Inc."},
+		{"00:00:0c:01:02:03", "Cisco Systems, Inc"},
+		{"02:00:00:00:00:01", ""}, //本地管理地址
+		{"fe:ff:ff:00:00:00", ""},
+	}
+	for _, tt := range tests {
+		if got := lookupOUI(mustMAC(t, tt.mac)); got != tt.vendor {
+			t.Errorf("lookupOUI(%s) = %q，期望 %q", tt.mac, got, tt.vendor)
+		}
+	}
+	if got := lookupOUI(net.HardwareAddr{0xb8, 0x27}); got != "" {
+		t.Errorf("不足3字节: %q", got)
+	}
+}
+
+// 厂商未知时只显示MAC
+func TestMACSuffix(t *testing.T) {
+	tests := []struct {
+		mac  string //空表示没有MAC
+		want string
+	}{
+		{"b8:27:eb:12:34:56", " [b8:27:eb:12:34:56 Raspberry Pi Foundation]"},
+		{"02:00:00:00:00:01", " [02:00:00:00:00:01]"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		var mac net.HardwareAddr
+		if tt.mac != "" {
+			mac = mustMAC(t, tt.mac)
+		}
+		if got := macSuffix(mac); got != tt.want {
+			t.Errorf("macSuffix(%s) = %q，期望 %q", tt.mac, got, tt.want)
+		}
+	}
+}
+
+// 同一IP的MAC变化时报告上一次的地址并计数，大小写不同不算变化
+func TestMACTracker(t *testing.T) {
+	tests := []struct {
+		name    string
+		macs    []string
+		changed []string //各次回复报告的上一次的地址，空表示没有变化
+		changes int
+	}{
+		{"不变", []string{"b8:27:eb:00:00:01", "b8:27:eb:00:00:01"}, []string{"", ""}, 0},
+		{"变化", []string{"b8:27:eb:00:00:01", "00:0c:29:00:00:02", "00:0c:29:00:00:02"}, []string{"", "b8:27:eb:00:00:01", ""}, 1},
+		{"来回切换", []string{"b8:27:eb:00:00:01", "00:0c:29:00:00:02", "b8:27:eb:00:00:01"}, []string{"", "b8:27:eb:00:00:01", "00:0c:29:00:00:02"}, 2},
+		{"大小写", []string{"b8:27:eb:00:00:0a", "B8:27:EB:00:00:0A"}, []string{"", ""}, 0},
+	}
+	for _, tt := range tests {
+		var tr macTracker
+		for i, s := range tt.macs {
+			prev, changed := tr.observe(mustMAC(t, s))
+			if want := tt.changed[i]; changed != (want != "") || changed && prev.String() != want {
+				t.Errorf("%s 第 %d 次: %v %v，期望 %q", tt.name, i+1, prev, changed, want)
+			}
+		}
+		if tr.changes != tt.changes {
+			t.Errorf("%s: 变化 %d 次，期望 %d", tt.name, tr.changes, tt.changes)
+		}
+	}
+}
+
+// 生成的表中前缀均为小写的 xx:xx:xx
+func TestOUITable(t *testing.T) {
+	re := regexp.MustCompile(`^[0-9a-f]{2}:[0-9a-f]{2}:[0-9a-f]{2}$`)
+	if len(ouiVendors) == 0 {
+		t.Fatal("OUI表为空")
+	}
+	for prefix, vendor := range ouiVendors {
+		if !re.MatchString(prefix) || vendor == "" {
+			t.Errorf("无效的条目 %q: %q", prefix, vendor)
+		}
+	}
+}
+
+// go generate 的步骤：由oui.txt格式的输入只保留列出的厂商，每个厂商最多perVendor条
+func TestGenOUI(t *testing.T) {
+	if testing.Short() {
+		t.Skip("需要编译 gen_oui.go")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("没有go命令")
+	}
+	dir := t.TempDir()
+	in := filepath.Join(dir, "oui.txt")
+	lines := []string{
+		"OUI/MA-L                                                    Organization",
+		"company_id                                                  Organization",
+		"",
+		"B8-27-EB   (hex)\t\tRaspberry Pi Foundation",
+		"B827EB     (base 16)\t\tRaspberry Pi Foundation",
+		"\t\t\t\tMitchell Wood House  Caldecote",
+		"",
+		"00-00-0C   (hex)\t\tCisco Systems, Inc",
+		"00-11-22   (hex)\t\tCIMSYS Inc", //不在厂商列表中
+	}
+	for i := 0; i < 7; i++ { //超过perVendor的前缀被丢弃
+		lines = append(lines, "00-50-5"+string(rune('0'+i))+"   (hex)\t\tVMware, Inc.")
+	}
+	if err := os.WriteFile(in, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "oui_table.go")
+	cmd := exec.Command(goBin, "run", "gen_oui.go", "-in", in, "-out", out)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go run gen_oui.go: %v\n%s", err, output)
+	}
+	src, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(src)
+	for _, want := range []string{
+		"// Code generated by gen_oui.go; DO NOT EDIT.",
+		`"b8:27:eb": "Raspberry Pi Foundation",`,
+		`"00:00:0c": "Cisco Systems, Inc",`,
+		`"00:50:54": "VMware, Inc.",`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("生成的文件中没有 %s:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"00:11:22", "00:50:55", "00:50:56"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("生成的文件中不应有 %s:\n%s", unwanted, got)
+		}
+	}
+}
@@ -1,28 +1,51 @@
 package main
 
 import (
-	"bytes"
 	"encoding/binary"
-	"flag"
 	"fmt"
-	"math"
 	"net"
 	"os"
-	"time"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+//生成gRPC接口的Go代码(需要protoc、protoc-gen-go及protoc-gen-go-grpc)
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative proto/ping/v1/ping.proto
+
+var (
+	timeout     int64 //超时时间
+	count       int   //请求次数
+	size        int   //缓冲区大小
+	asymDetect  bool  //是否检测非对称路由
+	otelEnabled bool  //是否以OpenTelemetry span导出每次探测
+	interval    int64 //两次请求的间隔(毫秒)
 )
 
 var (
-	timeout      int64                 //超时时间
-	count        int                   //请求次数
-	size         int                   //缓冲区大小
-	sendCount    int                   //已发起请求次数
-	successCount int                   //成功请求次数
-	failCount    int                   //失败请求次数
-	minTs        int64 = math.MaxInt32 //最小耗时，设置可计数的默认最大取值范围，以int32划分
-	maxTs        int64 = 0             //最大耗时
-	totalTs      int64                 //总耗时
+	configPath string        //配置文件路径
+	stop       chan struct{} //收到Ctrl+C后关闭，停止发送
+	netnsPath  string        //在该网络命名空间中发送请求
+	useIOUring bool          //使用io_uring收发报文
+	useEBPF    bool          //以XDP程序接收回复报文
+	hwTS       bool          //使用网卡硬件(或内核软件)时间戳计算往返时间
+	forever    bool          //持续ping直到按下Ctrl+C
 )
 
+var echoID = uint16(os.Getpid()) //回显请求的ID
+
+// 接收缓冲区，每次请求复用，避免每次分配64KB
+var recvBufPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 1<<16) //65535
+		return &buf
+	},
+}
+
+const ttlWindowSize = 5   //TTL滑动窗口大小
+const ttlVarianceMax = 10 //TTL方差阈值，超过则认为存在非对称路由
+
 // ICMP icmp数据结构
 type ICMP struct {
 	Type     uint8  //icmp报文type
@@ -33,103 +56,326 @@ type ICMP struct {
 }
 
 func main() {
-	getArgs()              //初始化命令行参数
-	host := getArgOfHost() //取最后一个参数
-	ping(host)             //ping
-}
-
-func ping(host string) {
-	conn, err := net.DialTimeout(
-		"ip4:icmp", //协议
-		host,
-		time.Duration(timeout)*time.Millisecond, //毫秒
-	)
-	if err != nil {
-		fmt.Printf("Ping 请求找不到主机 %s。请检查该名称，然后重试。", host)
-		os.Exit(0)
+	getArgs() //初始化命令行参数
+	if printSchema {
+		printOutputSchema() //机器可读输出的JSON Schema
+		return
 	}
-	defer conn.Close()
-
-	fmt.Printf("正在 Ping %s [%s] 具有 %d 字节的数据：\n", host, conn.RemoteAddr(), size)
-
-	for i := 0; i < count; i++ {
-		sendCount++ //统计请求数
 
-		//定义icmp数据
-		icmp := &ICMP{
-			Type:     8,         //icmp报文type为8位
-			Code:     0,         //code 8位
-			CheckSum: 0,         //校验和 16位
-			ID:       uint16(i), //ID 16位
-			SeqNum:   uint16(i), //序号 16位
+	if netnsPath != "" {
+		//在打开任何文件或socket之前，之后整个进程都在该命名空间中
+		if err := enterNetns(netnsPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
+	}
 
-		//创建缓冲区，以大端方式写入icmp头部
-		//binary.BigEndian（大端模式）：内存的低地址存放着数据高位
-		//binary.LittleEndian(小端模式)：内存的低地址存放着数据低位
-		var buffer bytes.Buffer
-		binary.Write(&buffer, binary.BigEndian, icmp)
-
-		//声明icmp内容部分
-		data := make([]byte, size)
-		buffer.Write(data)
-		data = buffer.Bytes()
+	//Ctrl+C 时停止发送，输出已有的统计信息后退出
+	stop = make(chan struct{})
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		close(stop)
+	}()
+	notifyPause()   //SIGUSR1 暂停，SIGUSR2 恢复
+	notifyInterim() //SIGQUIT 输出阶段统计
 
-		//检验和
-		checkSum, err := checkSum(data)
-		if err != nil {
-			failCount++
-			continue
+	if otelEnabled {
+		startOtel()
+	}
+	if influxAddr != "" {
+		if err := startInflux(influxAddr); err != nil {
+			fmt.Fprintf(os.Stderr, "无法连接InfluxDB: %v\n", err)
+			exit(1)
 		}
+	}
+	if recordPath != "" {
+		if err := startRecord(recordPath); err != nil {
+			fmt.Fprintf(os.Stderr, "无法创建记录文件: %v\n", err)
+			exit(1)
+		}
+	}
+	if pcapPath != "" {
+		if err := startPcap(pcapPath); err != nil {
+			fmt.Fprintf(os.Stderr, "无法创建pcap文件: %v\n", err)
+			exit(1)
+		}
+	}
+	code := 0             //退出码
+	var pingers []*Pinger //已测量的目标，用于保存基线或与基线比较
+	if agentAddr != "" {
+		code = runAgent(agentAddr) //作为 -dual-ended 的代理
+	} else if replayPath != "" {
+		pingers = replayPingers(replayPath) //回放记录，不发送报文
+	} else if heatmapPath != "" {
+		code = runHeatmap(heatmapPath) //按星期及小时汇总记录
+	} else if twampAddr != "" {
+		p := newPinger(twampAddr)
+		p.RunTWAMP() //TWAMP-Light测量
+		pingers = append(pingers, p)
+	} else if dnsServer != "" {
+		p := newPinger(dnsServer)
+		p.RunDNS() //DNS查询往返时间
+		pingers = append(pingers, p)
+	} else if ntpServer != "" {
+		p := newPinger(ntpServer)
+		p.RunNTP() //NTP请求往返时间
+		pingers = append(pingers, p)
+	} else if stunServer != "" {
+		p := newPinger(stunServer)
+		p.RunSTUN() //STUN Binding往返时间及外部地址
+		pingers = append(pingers, p)
+	} else if quicAddr != "" {
+		p := newPinger(quicAddr)
+		p.RunQUIC() //QUIC握手及HTTP/3首字节耗时
+		pingers = append(pingers, p)
+	} else if dualEnded != "" {
+		host, _, _ := net.SplitHostPort(dualEnded) //已在getArgs中校验
+		p := newPinger(host)
+		p.RunDualEnded(dualEnded) //双端区分丢失方向
+		pingers = append(pingers, p)
+	} else if httpURL != "" {
+		p := newPinger(httpURL)
+		p.RunHTTP() //HTTP请求各阶段耗时
+		pingers = append(pingers, p)
+	} else if configPath != "" {
+		pingers = configPingers(configPath)
+		if dropPrivs != "" {
+			var hosts []string
+			for _, p := range pingers {
+				hosts = append(hosts, p.Arg)
+			}
+			dropPrivileges(hosts) //先建立原始套接字再放弃权限
+		}
+		code = runPingers(pingers) //按配置文件ping各目标
+	} else {
+		hosts := getArgOfHost() //取目标参数
+		if fastest {
+			for i, host := range hosts {
+				hosts[i] = pickFastest(host) //只ping预探测最快的地址
+			}
+		}
+		if viaGateway != "" {
+			setupVia(hosts) //经由指定网关的临时主机路由，放弃权限前添加
+		}
+		if dropPrivs != "" {
+			dropPrivileges(hosts) //先建立原始套接字再放弃权限
+		}
+		if pmtud {
+			discoverPMTU(hosts[0]) //探测路径MTU
+		} else if bfdEcho {
+			p := newPinger(hosts[0])
+			p.RunBFD() //BFD回显
+			pingers = append(pingers, p)
+		} else if mplsPrefix != "" {
+			p := newPinger(hosts[0])
+			p.RunMPLS() //MPLS LSP ping
+			pingers = append(pingers, p)
+		} else if probeIface != "" {
+			p := newPinger(hosts[0])
+			p.RunProbe() //RFC 8335 扩展回显
+			pingers = append(pingers, p)
+		} else if timestampMsg {
+			p := newPinger(hosts[0])
+			p.RunTimestamp() //ICMP时间戳请求
+			pingers = append(pingers, p)
+		} else if addrMask {
+			p := newPinger(hosts[0])
+			p.RunAddrMask() //地址掩码请求
+			pingers = append(pingers, p)
+		} else if broadcast {
+			p := newPinger(hosts[0])
+			p.RunBroadcast() //向广播地址发送，收集所有应答
+			pingers = append(pingers, p)
+		} else if multicast {
+			p := newPinger(hosts[0])
+			p.RunMulticast() //向组播组发送，收集所有应答
+			pingers = append(pingers, p)
+		} else if waitFor > 0 {
+			code = runWaitFor(hosts[0]) //等待目标可以访问
+		} else if oneShot {
+			code = runOneShot(hosts[0]) //一次请求，输出 up/down
+		} else if multiDNS {
+			pingers = runMultiDNS(hosts) //每个地址分别ping
+		} else if scheduleExpr != "" {
+			code = runSchedule(hosts) //按cron表达式定时执行
+		} else {
+			for _, host := range hosts {
+				pingers = append(pingers, newPinger(host))
+			}
+			code = runPingers(pingers) //ping
+		}
+	}
+	influxBatch(pingers)
+	graphiteBatch(pingers)
+	cloudWatchBatch(pingers)
+	if c := checkBaseline(pingers); c != 0 && code == 0 {
+		code = c //与基线相比变差或无法读写基线文件
+	}
+	if code == 0 && !aliveOnly && !unreachOnly && sendFailed(pingers) {
+		code = exitSendError //本机无法发送，比路径丢包更严重
+	}
+	if code == 0 && slaRTT > 0 && !slaMet(pingers) {
+		code = exitSLAMissed //往返时间未达到SLA
+	}
+	if reportPath != "" {
+		if err := writeReport(reportPath, pingers); err != nil {
+			fmt.Fprintf(os.Stderr, "生成报告失败: %v\n", err)
+		}
+	}
+	if code != 0 {
+		exit(code)
+	}
+	closeOutputs()
+}
 
-		data[2] = byte(checkSum >> 8) //code，高位
-		data[3] = byte(checkSum)      //checksum，地位
-
-		//设置传输超时时间
-		conn.SetDeadline(time.Now().Add(time.Duration(timeout) * time.Millisecond))
+// 导出剩余的span，写出并关闭记录及pcap文件
+func closeOutputs() {
+	removeViaRoutes()
+	stopOtel()
+	stopInflux()
+	stopRecord()
+	stopPcap()
+}
 
-		tStart := time.Now() //用于统计时间
+// 关闭各输出后退出，开始发送后的所有退出都应经过这里
+func exit(code int) {
+	closeOutputs()
+	os.Exit(code)
+}
 
-		//传输
-		if _, err = conn.Write(data); err != nil {
-			failCount++
-			fmt.Println("请求失败。")
-			continue
-		}
+// 按配置文件创建各个目标的Pinger
+func configPingers(path string) []*Pinger {
+	cfg, err := loadConfig(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取配置文件失败: %v\n", err)
+		exit(1)
+	}
 
-		buf := make([]byte, 1<<16) //65535
-		n, err := conn.Read(buf)   //接收返回数据
+	var pingers []*Pinger
+	for _, t := range cfg.Targets {
+		noticeIgnoredPort(t.Host)
+		p := newPinger(t.Host)
+		cfg.apply(p, t)
+		p.Labels = t.Labels
+		pingers = append(pingers, p)
+	}
+	return pingers
+}
 
-		//计算时间
-		tSpend := time.Since(tStart).Milliseconds()
-		totalTs += tSpend   //累计总花费时间
-		if minTs > tSpend { //最小花费时间
-			minTs = tSpend
+// 依次ping各个目标，表格输出时并发ping并汇总为表格，返回退出码
+// -alive/-unreach 时只输出符合条件的地址，与fping一致，输出列表非空时退出码为0，否则为1
+func runPingers(pingers []*Pinger) int {
+	setInterimPingers(pingers)
+	if debugListen != "" {
+		if _, err := startDebugServer(debugListen, pingers); err != nil {
+			fmt.Fprintf(os.Stderr, "无法开启调试接口: %v\n", err)
+			exit(1)
 		}
-		if maxTs < tSpend { //最大花费时间
-			maxTs = tSpend
+	}
+	if statePath != "" {
+		loadState(statePath, pingers)
+		defer startStateSaver(statePath, pingers)()
+	}
+	if aliveOnly || unreachOnly {
+		if runSweep(pingers) == 0 {
+			return 1
 		}
-
-		if err != nil {
-			failCount++
-			fmt.Println("请求超时。")
-			continue
+		return 0
+	}
+	if outputFormat == "table" {
+		runTable(pingers, tableRows)
+		return 0
+	}
+	for i, j := range probeOrder(len(pingers)) {
+		if i > 0 {
+			if stopped() {
+				return 0
+			}
+			fmt.Println()
 		}
-		successCount++ //统计成功请求数
-		fmt.Printf("来自 %d.%d.%d.%d 的回复: 字节=%d 时间=%dms TTL=%d\n", buf[12], buf[13], buf[14], buf[15], n-28, tSpend, buf[8])
+		pingers[j].Run()
 	}
+	return 0
+}
 
-	//输出总结
-	fmt.Printf("\n%s 的 Ping 统计信息:\n    数据包: 已发送 = %d，已接收 = %d，丢失 = %d (%.2f%% 丢失)，\n往返行程的估计时间(以毫秒为单位):\n    最短 = %dms，最长 = %dms，平均 = %dms\n",
-		conn.RemoteAddr(), sendCount, successCount, failCount, float64(failCount)/float64(sendCount), minTs, maxTs, totalTs/int64(sendCount))
+// 是否已收到Ctrl+C
+func stopped() bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// 以大端方式把icmp头部写入b的前8字节
+// binary.BigEndian（大端模式）：内存的低地址存放着数据高位
+// binary.LittleEndian(小端模式)：内存的低地址存放着数据低位
+func (icmp *ICMP) Marshal(b []byte) {
+	b[0] = icmp.Type
+	b[1] = icmp.Code
+	binary.BigEndian.PutUint16(b[2:4], icmp.CheckSum)
+	binary.BigEndian.PutUint16(b[4:6], icmp.ID)
+	binary.BigEndian.PutUint16(b[6:8], icmp.SeqNum)
+}
+
+// 构造icmp回显请求报文，size为数据部分长度
+func buildEcho(seq, size int) ([]byte, error) {
+	pkt := make([]byte, 8+size)
+	if err := fillEcho(pkt, seq); err != nil {
+		return nil, err
+	}
+	return pkt, nil
+}
+
+// 在预先分配好的报文缓冲区中写入icmp回显请求头部并计算校验和
+// pkt为 8字节头部 + 数据部分，数据部分保持不变，每次请求复用同一个缓冲区，不产生内存分配
+func fillEcho(pkt []byte, seq int) error {
+	//定义icmp数据
+	icmp := ICMP{
+		Type:     8,           //icmp报文type为8位
+		Code:     0,           //code 8位
+		CheckSum: 0,           //校验和 16位，计算前置0
+		ID:       echoID,      //ID 16位，取进程号，用于区分不同进程的请求
+		SeqNum:   uint16(seq), //序号 16位
+	}
+	icmp.Marshal(pkt)
+
+	//检验和
+	checkSum, err := checkSum(pkt)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint16(pkt[2:4], checkSum) //原地写入校验和
+	return nil
 }
 
+// 在该长度附近SSE2实现与ADC循环相当，更长时SSE2实现更快，见BENCHMARKS.md
+const checkSumSSE2Min = 512
+
 // 检验和算法
 // 1、报文内容，相邻两个字节拼接到一起组成一个16bit的数，将这些数累加
-// 2、若长度为奇数，则将剩余的1个字节直接累加
+// 2、若长度为奇数，则将剩余的1个字节作为高8位(低8位补0)累加
 // 3、得到总和后，将该值的高16位与低16位不断求和，直到高16位为0
 // 4、最后的和取反，就为校验和
+// amd64上使用汇编实现：不短于checkSumSSE2Min字节时用SSE2(checksum_sse2_amd64.s)，
+// 较短时用ADC循环(checksum_amd64.s)；其他平台使用纯Go实现checkSumGeneric
 func checkSum(data []byte) (uint16, error) {
+	if haveCheckSumSSE2 && len(data) >= checkSumSSE2Min {
+		return checkSumSSE2(data), nil
+	}
+	if haveCheckSumASM {
+		return checkSumASM(data), nil
+	}
+	return checkSumGeneric(data), nil
+}
+
+// 纯Go实现的检验和，用于没有汇编实现的平台，也用于校验汇编实现
+// 长度为奇数时，最后一个字节作为16位字的高8位、低8位补0(RFC 1071 4.1节)，
+// 与接收方(内核、其他实现)的计算方式一致
+func checkSumGeneric(data []byte) uint16 {
 	len := len(data)
 	idx := 0
 	var sum uint32
@@ -139,7 +385,7 @@ func checkSum(data []byte) (uint16, error) {
 		idx += 2
 	}
 	if len == 1 {
-		sum += uint32(data[idx])
+		sum += uint32(data[idx]) << 8
 	}
 
 	//sum最大值：0xffffffff 16进制
@@ -151,27 +397,89 @@ func checkSum(data []byte) (uint16, error) {
 		hi16 = sum >> 16
 	}
 
-	return uint16(^sum), nil
+	return uint16(^sum)
 }
 
-// 初始化命令行参数
-func getArgs() {
-	flag.Int64Var(&timeout, "w", 1000, "等待每次回复的超时时间(毫秒)")
-	flag.IntVar(&count, "n", 4, "要发送的回显请求数")
-	flag.IntVar(&size, "l", 32, "发送缓冲区大小")
-	flag.Parse()
+// 计算TTL方差
+func ttlVariance(ttls []int) float64 {
+	var sum float64
+	for _, t := range ttls {
+		sum += float64(t)
+	}
+	mean := sum / float64(len(ttls))
+
+	var sq float64
+	for _, t := range ttls {
+		sq += (float64(t) - mean) * (float64(t) - mean)
+	}
+	return sq / float64(len(ttls))
 }
 
-// 取最后一个参数
-func getArgOfHost() string {
-	if len(os.Args) < 2 {
-		fmt.Println(`用法: ping [-n count] [-l size] [-w timeout] target_name
+// 取目标参数，可以指定多个目标，网段展开为其中的各个地址，-f 文件中的目标追加在后
+func getArgOfHost() []string {
+	args := positional
+	if targetFile != "" {
+		lines, err := readTargetFile(targetFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "读取目标列表失败: %v\n", err)
+			exit(1)
+		}
+		args = append(args, lines...)
+	}
+	if len(args) == 0 {
+		usage()
+		exit(0)
+	}
 
-选项:
-   -n count       要发送的回显请求数。
-   -l size        发送缓冲区大小。
-   -w timeout     等待每次回复的超时时间(毫秒)。`)
-		os.Exit(0)
+	//host=label 的标签及 ,key=value 的单独参数对展开后的每个目标都生效
+	var hosts []string
+	for _, arg := range args {
+		host, labels, opts, err := splitTargetArg(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit(2)
+		}
+		expanded, err := expandTargets([]string{host})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit(2)
+		}
+		if labels != nil {
+			for _, h := range expanded {
+				targetLabels[h] = labels
+			}
+		}
+		if opts.hasOptions() {
+			for _, h := range expanded {
+				targetOptions[h] = opts
+			}
+		}
+		noticeIgnoredPort(host)
+		hosts = append(hosts, expanded...)
+	}
+	if len(hosts) > 1 && (pmtud || bfdEcho || mplsPrefix != "" || probeIface != "" || addrMask || timestampMsg || broadcast || multicast || waitFor > 0 || oneShot) {
+		mode := "-pmtud"
+		if oneShot {
+			mode = "-1"
+		} else if bfdEcho {
+			mode = "-bfd"
+		} else if mplsPrefix != "" {
+			mode = "-mpls-lsp"
+		} else if probeIface != "" {
+			mode = "-probe"
+		} else if addrMask {
+			mode = "-addrmask"
+		} else if timestampMsg {
+			mode = "-timestamp-msg"
+		} else if broadcast {
+			mode = "-broadcast"
+		} else if multicast {
+			mode = "-multicast"
+		} else if waitFor > 0 {
+			mode = "-wait-for"
+		}
+		fmt.Fprintf(os.Stderr, "目标不明确: %s，%s 只能指定一个目标。\n", strings.Join(args, " "), mode)
+		exit(2)
 	}
-	return os.Args[len(os.Args)-1]
+	return hosts
 }
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 执行fn并返回其间写入标准输出及标准错误的内容
+func captureOutput(t testing.TB, fn func()) (stdout, stderr string) {
+	t.Helper()
+	capture := func(f **os.File) (func() string, error) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		old := *f
+		*f = w
+		done := make(chan string)
+		go func() {
+			var b bytes.Buffer
+			io.Copy(&b, r)
+			r.Close()
+			done <- b.String()
+		}()
+		return func() string {
+			w.Close()
+			*f = old
+			return <-done
+		}, nil
+	}
+	stopOut, err := capture(&os.Stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopErr, err := capture(&os.Stderr)
+	if err != nil {
+		stopOut()
+		t.Fatal(err)
+	}
+	defer func() {
+		stdout, stderr = stopOut(), stopErr()
+	}()
+	fn()
+	return
+}
+
+// 需要创建原始套接字的测试在没有权限时跳过
+func needRawSocket(t testing.TB) {
+	t.Helper()
+	pc, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		t.Skipf("无法创建原始套接字: %v", err)
+	}
+	pc.Close()
+}
+
+// mockConn 立即以回显应答(带20字节IPv4头)回复写入的请求
+type mockConn struct {
+	reply []byte
+}
+
+func newMockConn() *mockConn {
+	return &mockConn{reply: make([]byte, 0, 1<<16)}
+}
+
+func (c *mockConn) Write(b []byte) (int, error) {
+	r := append(c.reply[:0], 0x45, 0, 0, 0, 0, 0, 0, 0, 64, 1, 0, 0, 127, 0, 0, 1, 127, 0, 0, 1)
+	binary.BigEndian.PutUint16(r[2:4], uint16(20+len(b)))
+	r = append(r, b...)
+	icmp := r[20:]
+	icmp[0] = 0 //回显应答，类型由8改为0，增量调整检验和
+	binary.BigEndian.PutUint16(icmp[2:4], adjustCheckSum(binary.BigEndian.Uint16(icmp[2:4]), 0x0800, 0x0000))
+	c.reply = r
+	return len(b), nil
+}
+
+func (c *mockConn) Read(b []byte) (int, error) {
+	return copy(b, c.reply), nil
+}
+
+func (c *mockConn) Close() error                       { return nil }
+func (c *mockConn) LocalAddr() net.Addr                { return &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)} }
+func (c *mockConn) RemoteAddr() net.Addr               { return &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)} }
+func (c *mockConn) SetDeadline(t time.Time) error      { return nil }
+func (c *mockConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *mockConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func TestTTLVariance(t *testing.T) {
+	tests := []struct {
+		ttls []int
+		want float64
+	}{
+		{[]int{64, 64, 64, 64, 64}, 0},
+		{[]int{60, 64, 60, 64}, 4},
+		{[]int{50, 50, 50, 50, 60}, 16},
+		{[]int{40, 60, 40, 60, 40}, 96},
+	}
+	for _, tt := range tests {
+		if got := ttlVariance(tt.ttls); got != tt.want {
+			t.Errorf("ttlVariance(%v) = %v，期望 %v", tt.ttls, got, tt.want)
+		}
+	}
+}
+
+// 窗口填满前不判断；方差超过阈值时提示，窗口滑过后不再提示
+func TestCheckAsymRoute(t *testing.T) {
+	tests := []struct {
+		name string
+		ttls []int
+		want int //提示的次数
+	}{
+		{"稳定", []int{64, 64, 64, 64, 64, 64}, 0},
+		{"窗口未满", []int{40, 60, 40, 60}, 0},
+		{"小幅波动", []int{60, 64, 60, 64, 60}, 0},
+		{"交替的路径", []int{40, 60, 40, 60, 40}, 1},
+		{"波动后恢复", []int{40, 60, 40, 60, 40, 64, 64, 64, 64, 64}, 5},
+	}
+	for _, tt := range tests {
+		p := newPinger("192.0.2.1")
+		stdout, _ := captureOutput(t, func() {
+			for _, ttl := range tt.ttls {
+				p.checkAsymRoute(ttl)
+			}
+		})
+		if n := strings.Count(stdout, "ASYMMETRIC ROUTING DETECTED"); n != tt.want {
+			t.Errorf("%s: 提示了 %d 次，期望 %d\n%s", tt.name, n, tt.want, stdout)
+		}
+	}
+}
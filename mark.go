@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	fwMark   int      //-mark 发送报文的防火墙标记(SO_MARK)，0表示不设置
+	priority int = -1 //-priority 套接字优先级(SO_PRIORITY)，-1表示不设置
+)
+
+const maxUnprivPriority = 6 //无CAP_NET_ADMIN权限时可设置的最大优先级
+
+// icmpDialer 建立到目标的ICMP连接
+type icmpDialer interface {
+	dial(host string, timeout time.Duration) (net.Conn, error)
+}
+
+// rawDialer 每次新建原始套接字，多目标并发时使用共享套接字
+type rawDialer struct{}
+
+var icmpDial icmpDialer = rawDialer{} //dialICMP 使用的dialer，-drop-privs 时换成preparedDialer
+
+// 建立ICMP连接，-drop-privs 时使用放弃权限前建立的连接
+func dialICMP(host string, timeout time.Duration) (net.Conn, error) {
+	return icmpDial.dial(host, timeout)
+}
+
+// 建立ICMP原始套接字连接，指定 -mark、-priority 时在连接(选择路由)之前设置
+func (rawDialer) dial(host string, timeout time.Duration) (net.Conn, error) {
+	if engine != nil {
+		return engine.dial(host) //多目标并发时共享一个原始套接字
+	}
+	d := net.Dialer{Timeout: timeout}
+	if fwMark != 0 || priority >= 0 {
+		d.Control = func(network, address string, c syscall.RawConn) error {
+			var serr error
+			err := c.Control(func(fd uintptr) {
+				if fwMark != 0 {
+					if serr = setMark(fd, fwMark); serr != nil {
+						return
+					}
+				}
+				if priority >= 0 {
+					serr = setPriority(fd, priority)
+				}
+			})
+			if err != nil {
+				return err
+			}
+			return serr
+		}
+	}
+	conn, err := d.Dial("ip4:icmp", host)
+	if err != nil {
+		return dialICMPFallback(host, err)
+	}
+	return conn, nil
+}
+
+// 流量分类相关的设置，用于开头一行的显示，如 "标记=0x64 优先级=3 ECN=ECT(0)"
+func trafficClassText(ecn *ecnStats) string {
+	var parts []string
+	if fwMark != 0 {
+		parts = append(parts, fmt.Sprintf("标记=0x%x", fwMark))
+	}
+	if priority >= 0 {
+		parts = append(parts, fmt.Sprintf("优先级=%d", priority))
+	}
+	if ecn != nil {
+		parts = append(parts, "ECN="+ecnNames[ecn.sent])
+	}
+	return strings.Join(parts, " ")
+}
+
+// 防火墙标记参数，接受十进制或0x开头的十六进制，取值为32位无符号整数
+type markValue struct {
+	n *int
+}
+
+func (v markValue) String() string {
+	if v.n == nil || *v.n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("0x%x", *v.n)
+}
+
+func (v markValue) Set(s string) error {
+	n, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return fmt.Errorf("应为32位无符号整数，如 100 或 0x64")
+	}
+	*v.n = int(n)
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+const markSupported = true
+
+// 设置SO_MARK，需要CAP_NET_ADMIN权限
+func setMark(fd uintptr, mark int) error {
+	err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_MARK, mark)
+	if err == syscall.EPERM {
+		return fmt.Errorf("设置SO_MARK需要CAP_NET_ADMIN权限: %v", err)
+	}
+	return err
+}
+
+// 设置SO_PRIORITY，0-6以外的取值需要CAP_NET_ADMIN权限
+func setPriority(fd uintptr, prio int) error {
+	err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_PRIORITY, prio)
+	if err == syscall.EPERM {
+		return fmt.Errorf("设置优先级 %d 需要CAP_NET_ADMIN权限(无权限时只能为0-%d): %v", prio, maxUnprivPriority, err)
+	}
+	return err
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// 建立的套接字上设置了 -mark、-priority 的值
+func TestDialTrafficClass(t *testing.T) {
+	needRawSocket(t)
+	parseArgs(t, "-mark", "0x64", "-priority", "5", "127.0.0.1")
+	conn, err := dialICMP("127.0.0.1", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	rc, err := conn.(*net.IPConn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mark, prio int
+	var merr, perr error
+	rc.Control(func(fd uintptr) {
+		mark, merr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_MARK)
+		prio, perr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_PRIORITY)
+	})
+	if merr != nil || perr != nil {
+		t.Fatal(merr, perr)
+	}
+	if mark != 0x64 || prio != 5 {
+		t.Errorf("SO_MARK = %#x，SO_PRIORITY = %d，期望 0x64、5", mark, prio)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+const markSupported = false
+
+// 设置SO_MARK
+func setMark(fd uintptr, mark int) error {
+	return errors.New("当前平台不支持SO_MARK")
+}
+
+// 设置SO_PRIORITY
+func setPriority(fd uintptr, prio int) error {
+	return errors.New("当前平台不支持SO_PRIORITY")
+}
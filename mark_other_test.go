@@ -0,0 +1,28 @@
+//go:build !linux
+
+package main
+
+import "testing"
+
+// 非Linux平台上 -mark、-priority 在参数检查时报错，而不是等到建立连接时
+func TestTrafficClassUnsupported(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-mark", "100", "127.0.0.1"}, "-mark: 防火墙标记(SO_MARK)仅Linux支持"},
+		{[]string{"-priority", "0", "127.0.0.1"}, "-priority: 套接字优先级(SO_PRIORITY)仅Linux支持"},
+		{[]string{"-priority", "-2", "127.0.0.1"}, "-priority: 取值 -2 无效"},
+	}
+	for _, tt := range tests {
+		if errs := argErrors(t, tt.args...); !hasArgError(errs, tt.err) {
+			t.Errorf("参数 %q: 错误 %q，期望 %q", tt.args, errs, tt.err)
+		}
+	}
+	if err := setMark(0, 100); err == nil {
+		t.Error("setMark 没有报错")
+	}
+	if err := setPriority(0, 3); err == nil {
+		t.Error("setPriority 没有报错")
+	}
+}
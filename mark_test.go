@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarkValue(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		err  bool
+	}{
+		{"100", 100, false},
+		{"0x64", 100, false},
+		{"0xffff", 0xffff, false},
+		{"0", 0, false},
+		{"4294967296", 0, true}, //超出32位
+		{"-1", 0, true},
+		{"abc", 0, true},
+	}
+	for _, tt := range tests {
+		var n int
+		err := markValue{&n}.Set(tt.in)
+		if (err != nil) != tt.err || n != tt.want {
+			t.Errorf("Set(%q) = %d, %v，期望 %d，出错 %v", tt.in, n, err, tt.want, tt.err)
+		}
+	}
+}
+
+// 指定 -mark、-priority 时JSONL的每条记录带上设置的值
+func TestMarkRecord(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{nil, `"outcome":"success"}`},
+		{[]string{"-mark", "0x64"}, `"outcome":"success","mark":100}`},
+		{[]string{"-priority", "0"}, `"outcome":"success","priority":0}`}, //0也要记录
+	} {
+		if !markSupported && len(tt.args) > 0 {
+			continue //由TestTrafficClassUnsupported覆盖
+		}
+		path := filepath.Join(t.TempDir(), "rec.jsonl")
+		parseArgs(t, append(tt.args, "-record", path, "127.0.0.1")...)
+		if err := startRecord(path); err != nil {
+			t.Fatal(err)
+		}
+		recordProbe(probeSpan{target: "127.0.0.1", start: start, end: start, outcome: "success"})
+		stopRecord()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(string(data)); !strings.HasSuffix(got, tt.want) {
+			t.Errorf("参数 %q: 记录 %s，期望以 %s 结尾", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestPriorityFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string //空表示没有错误
+	}{
+		{[]string{"127.0.0.1"}, ""},
+		{[]string{"-priority", "-1", "127.0.0.1"}, ""}, //不设置
+		{[]string{"-priority", "-2", "127.0.0.1"}, "-priority: 取值 -2 无效"},
+	}
+	for _, tt := range tests {
+		errs := argErrors(t, tt.args...)
+		if tt.err == "" && len(errs) > 0 || tt.err != "" && !hasArgError(errs, tt.err) {
+			t.Errorf("参数 %q: 错误 %q，期望 %q", tt.args, errs, tt.err)
+		}
+	}
+}
+
+// 标记、优先级、ECN一起显示在开头一行，并记录在JSONL的start事件中
+func TestTrafficClassStart(t *testing.T) {
+	tests := []struct {
+		args  []string
+		text  string
+		start string
+	}{
+		{nil, "", `"args":["127.0.0.1"]}`},
+		{[]string{"-mark", "100"}, "标记=0x64", `"mark":100}`},
+		{[]string{"-priority", "0"}, "优先级=0", `"priority":0}`}, //0也要记录
+		{[]string{"-ecn", "ect1"}, "ECN=ECT(1)", `"ecn":"ECT(1)"}`},
+		{[]string{"-mark", "0x64", "-priority", "3", "-ecn", "ect0"}, "标记=0x64 优先级=3 ECN=ECT(0)", `"mark":100,"priority":3,"ecn":"ECT(0)"}`},
+	}
+	for _, tt := range tests {
+		if !markSupported && len(tt.args) > 0 && tt.args[0] != "-ecn" {
+			continue //由TestTrafficClassUnsupported覆盖
+		}
+		parseArgs(t, append(tt.args, "127.0.0.1")...)
+		var ecn *ecnStats
+		if cp, err := parseECN(ecnMode); err == nil {
+			ecn = &ecnStats{sent: cp}
+		}
+		if got := trafficClassText(ecn); got != tt.text {
+			t.Errorf("参数 %q: 开头一行显示 %q，期望 %q", tt.args, got, tt.text)
+		}
+		data, err := json.Marshal(newRunStartRecord())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(string(data), tt.start) {
+			t.Errorf("参数 %q: start事件 %s，期望以 %s 结尾", tt.args, data, tt.start)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	mplsPrefix   string //-mpls-lsp 要验证的FEC(IPv4前缀)
+	mplsLabelArg string //-mpls-label 标签栈，逗号分隔，外层在前
+)
+
+// MPLS LSP ping 报文格式，见 RFC 4379(RFC 8029) §3
+const (
+	lspPingPort       = 3503 //LSP ping UDP端口
+	lspEchoRequest    = 1    //消息类型：回显请求
+	lspEchoReply      = 2    //消息类型：回显应答
+	lspReplyModeUDP   = 2    //应答方式：以IPv4/IPv6 UDP报文应答
+	lspTLVTargetFEC   = 1    //Target FEC Stack TLV
+	lspSubTLVLDPIPv4  = 1    //LDP IPv4 prefix 子TLV
+	lspHeaderLen      = 32   //固定头部长度
+	mplsLabelMax      = 1<<20 - 1
+	mplsMaxStackDepth = 8
+)
+
+var errLSPUnsupported = errors.New("当前平台不支持 MPLS LSP ping(需要Linux AF_PACKET)")
+
+// LSP ping 回显应答
+type lspReply struct {
+	code     uint8     //返回码
+	subcode  uint8     //返回子码(标签栈深度)
+	handle   uint32    //发送方句柄
+	seq      uint32    //序号
+	received time.Time //应答方收到请求的时间
+}
+
+// 解析 -mpls-label 标签栈
+func parseLabelStack(s string) ([]uint32, error) {
+	var labels []uint32
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		n, err := strconv.ParseUint(f, 10, 32)
+		if err != nil || n > mplsLabelMax {
+			return nil, fmt.Errorf("-mpls-label: 无效的标签 %q", f)
+		}
+		labels = append(labels, uint32(n))
+	}
+	if len(labels) > mplsMaxStackDepth {
+		return nil, fmt.Errorf("-mpls-label: 标签栈最多 %d 层", mplsMaxStackDepth)
+	}
+	return labels, nil
+}
+
+// 解析 -mpls-lsp 的IPv4前缀，不带前缀长度时视为 /32
+func parseLSPPrefix(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		s += "/32"
+	}
+	ip, ipnet, err := net.ParseCIDR(s)
+	if err != nil || ip.To4() == nil {
+		return nil, fmt.Errorf("-mpls-lsp: 无效的IPv4前缀 %q", s)
+	}
+	return ipnet, nil
+}
+
+// 构造MPLS标签栈：标签(20) TC(3) S(1) TTL(8)，最内层置S位
+func buildLabelStack(labels []uint32, ttl uint8) []byte {
+	b := make([]byte, 4*len(labels))
+	for i, l := range labels {
+		entry := l<<12 | uint32(ttl)
+		if i == len(labels)-1 {
+			entry |= 1 << 8 //栈底
+		}
+		binary.BigEndian.PutUint32(b[4*i:], entry)
+	}
+	return b
+}
+
+// 构造LSP ping回显请求，携带 Target FEC Stack TLV(LDP IPv4 prefix)
+func buildLSPEchoRequest(handle, seq uint32, fec *net.IPNet, sent time.Time) []byte {
+	b := make([]byte, lspHeaderLen+4+12)
+	binary.BigEndian.PutUint16(b[0:2], 1) //版本
+	binary.BigEndian.PutUint16(b[2:4], 0) //全局标志
+	b[4] = lspEchoRequest
+	b[5] = lspReplyModeUDP
+	binary.BigEndian.PutUint32(b[8:12], handle)
+	binary.BigEndian.PutUint32(b[12:16], seq)
+	putNTPTime(b[16:24], sent) //收到时间戳(24:32)由应答方填写
+
+	tlv := b[lspHeaderLen:]
+	binary.BigEndian.PutUint16(tlv[0:2], lspTLVTargetFEC)
+	binary.BigEndian.PutUint16(tlv[2:4], 12)
+	binary.BigEndian.PutUint16(tlv[4:6], lspSubTLVLDPIPv4)
+	binary.BigEndian.PutUint16(tlv[6:8], 5) //前缀(4) 前缀长度(1)，另补3字节对齐
+	copy(tlv[8:12], fec.IP.To4())
+	ones, _ := fec.Mask.Size()
+	tlv[12] = uint8(ones)
+	return b
+}
+
+// 解析LSP ping回显应答
+func parseLSPEchoReply(b []byte) (lspReply, error) {
+	if len(b) < lspHeaderLen || b[4] != lspEchoReply {
+		return lspReply{}, errors.New("不是LSP ping回显应答")
+	}
+	return lspReply{
+		code:     b[6],
+		subcode:  b[7],
+		handle:   binary.BigEndian.Uint32(b[8:12]),
+		seq:      binary.BigEndian.Uint32(b[12:16]),
+		received: ntpTime(b[24:32]),
+	}, nil
+}
+
+// 返回码的含义，见 RFC 8029 §3.1
+func lspReturnCode(code uint8) string {
+	switch code {
+	case 0:
+		return "无返回码"
+	case 1:
+		return "请求格式错误"
+	case 2:
+		return "存在不支持的TLV"
+	case 3:
+		return "应答方是该FEC的出口"
+	case 4:
+		return "应答方没有该FEC的映射"
+	case 5:
+		return "下游映射不匹配"
+	case 6:
+		return "上游接口未知"
+	case 8:
+		return "按标签转发"
+	case 9:
+		return "标签已转发，但FEC不匹配"
+	case 10:
+		return "标签映射不匹配"
+	case 11:
+		return "该标签没有对应的FEC"
+	case 12:
+		return "协议不匹配"
+	case 13:
+		return "出栈后提前终止"
+	default:
+		return fmt.Sprintf("返回码 %d", code)
+	}
+}
+
+// 接收到的LSP ping结果：回显应答，或出口返回的ICMP端口不可达
+type lspResult struct {
+	from        net.IP
+	reply       lspReply
+	unreachable bool
+}
+
+// 读取发到本地UDP端口的回显应答
+func readLSPReplies(conn *net.UDPConn, results chan<- lspResult) {
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return //连接已关闭
+		}
+		r, err := parseLSPEchoReply(buf[:n])
+		if err != nil {
+			continue
+		}
+		select {
+		case results <- lspResult{from: from.IP, reply: r}:
+		default:
+		}
+	}
+}
+
+// 读取ICMP端口不可达，原始报文为本进程从port端口发往127.0.0.1的LSP ping请求
+// 请求沿LSP到达出口后，未运行LSP ping的出口会返回端口不可达
+func readLSPUnreachable(conn net.PacketConn, port int, results chan<- lspResult) {
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		b := buf[:n]
+		if len(b) < 8+20 || b[0] != 3 || b[1] != 3 { //type 3 code 3
+			continue
+		}
+		inner := b[8:]
+		ihl := int(inner[0]&0x0f) * 4
+		if len(inner) < ihl+4 || inner[9] != 17 || !net.IP(inner[16:20]).Equal(net.IPv4(127, 0, 0, 1)) {
+			continue
+		}
+		if int(binary.BigEndian.Uint16(inner[ihl:ihl+2])) != port {
+			continue
+		}
+		select {
+		case results <- lspResult{from: from.(*net.IPAddr).IP, unreachable: true}:
+		default:
+		}
+	}
+}
+
+// RunMPLS 沿MPLS LSP发送LSP ping回显请求，验证到 -mpls-lsp 指定FEC的转发路径
+// 请求发往127.0.0.1，IP TTL为1并携带Router Alert选项，确保不会被IP转发，只能按标签交换到达出口
+// 出口返回回显应答；出口未运行LSP ping时返回的ICMP端口不可达同样说明路径可达
+func (p *Pinger) RunMPLS() {
+	p.Host = icmpHost(p.Arg)
+	fec, _ := parseLSPPrefix(mplsPrefix) //已在getArgs中校验
+	labels, _ := parseLabelStack(mplsLabelArg)
+	snd, err := newLSPSender(p.Host, labels)
+	if err != nil {
+		p.fail(err)
+		p.printf("无法发送LSP ping: %v\n", err)
+		return
+	}
+	defer snd.close()
+
+	uc, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		p.fail(err)
+		p.printf("无法监听UDP端口: %v\n", err)
+		return
+	}
+	defer uc.Close()
+	port := uc.LocalAddr().(*net.UDPAddr).Port
+
+	results := make(chan lspResult, 16)
+	go readLSPReplies(uc, results)
+	if ic, err := net.ListenPacket("ip4:icmp", "0.0.0.0"); err == nil {
+		defer ic.Close()
+		go readLSPUnreachable(ic, port, results)
+	}
+
+	var h [4]byte
+	rand.Read(h[:])
+	handle := binary.BigEndian.Uint32(h[:])
+	p.Addr = p.Host
+
+	p.printf("正在经 %s 验证 FEC %s 的LSP (标签栈 %s)：\n", p.Host, fec, mplsLabelArg)
+
+	for i := 0; forever || i < p.Count; i++ {
+		if !p.nextProbe(i) {
+			break
+		}
+
+		p.Stats.addSent()
+		tStart := time.Now()
+		if err := snd.send(buildLSPEchoRequest(handle, uint32(i), fec, tStart), port); err != nil {
+			p.Stats.addFailure()
+			p.printf("请求失败: %v\n", err)
+			continue
+		}
+
+		timer := time.NewTimer(time.Duration(p.Timeout) * time.Millisecond)
+		var res lspResult
+		ok := false
+	wait:
+		for {
+			select {
+			case res = <-results:
+				if res.unreachable || (res.reply.handle == handle && res.reply.seq == uint32(i)) {
+					ok = true
+					break wait
+				}
+			case <-timer.C:
+				break wait
+			}
+		}
+		timer.Stop()
+
+		tSpend := time.Since(tStart).Milliseconds()
+		if !ok {
+			p.Stats.addTimeout()
+			p.Stats.addFailure()
+			p.printf("请求超时。\n")
+			continue
+		}
+		p.Stats.addSuccess(tSpend)
+		if res.unreachable {
+			p.printf("来自 %s 的端口不可达: 请求已沿LSP到达出口，出口未运行LSP ping 时间=%dms\n", res.from, tSpend)
+		} else {
+			p.printf("来自 %s 的应答: 序号=%d 时间=%dms %s(栈深度 %d)\n", res.from, i, tSpend, lspReturnCode(res.reply.code), res.reply.subcode)
+		}
+	}
+
+	p.printSummary()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	ethPMPLSUnicast = 0x8847 //以太网类型：MPLS单播
+	ethHeaderLen    = 14
+	lspIPHeaderLen  = 24 //IP头(20)加Router Alert选项(4)
+	udpHeaderLen    = 8
+	mplsTTL         = 255
+)
+
+// lspSender 通过AF_PACKET把带MPLS标签栈的LSP ping请求直接发给下一跳
+// 内核不会为发往127.0.0.1的报文压入标签，因此以太网帧、标签栈、IP及UDP头部均由本程序构造
+type lspSender struct {
+	fd     int
+	addr   syscall.SockaddrLinklayer
+	srcMAC net.HardwareAddr
+	dstMAC net.HardwareAddr
+	src    net.IP
+	stack  []byte //标签栈
+	ipID   uint16
+}
+
+// 在到下一跳的出口接口上打开AF_PACKET socket，并取得下一跳的MAC地址
+func newLSPSender(nexthop string, labels []uint32) (*lspSender, error) {
+	conn, err := net.Dial("udp4", net.JoinHostPort(nexthop, "3503"))
+	if err != nil {
+		return nil, err
+	}
+	src := conn.LocalAddr().(*net.UDPAddr).IP.To4()
+	dst := conn.RemoteAddr().(*net.UDPAddr).IP.To4()
+	conn.Close()
+
+	iface, err := outgoingInterface(nexthop)
+	if err != nil {
+		return nil, err
+	}
+	if len(iface.HardwareAddr) != 6 {
+		return nil, fmt.Errorf("接口 %s 不是以太网接口", iface.Name)
+	}
+	dstMAC, err := neighborMAC(dst, iface.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, 0) //只发送，不接收
+	if err != nil {
+		return nil, fmt.Errorf("AF_PACKET: %v", err)
+	}
+	s := &lspSender{
+		fd:     fd,
+		srcMAC: iface.HardwareAddr,
+		dstMAC: dstMAC,
+		src:    src,
+		stack:  buildLabelStack(labels, mplsTTL),
+	}
+	s.addr = syscall.SockaddrLinklayer{Protocol: htons(ethPMPLSUnicast), Ifindex: iface.Index, Halen: 6}
+	copy(s.addr.Addr[:], dstMAC)
+	return s, nil
+}
+
+// 发送一个LSP ping请求：以太网头 + 标签栈 + IP头(带Router Alert) + UDP头 + 请求
+func (s *lspSender) send(payload []byte, srcPort int) error {
+	ipOff := ethHeaderLen + len(s.stack)
+	udpOff := ipOff + lspIPHeaderLen
+	frame := make([]byte, udpOff+udpHeaderLen+len(payload))
+
+	copy(frame[0:6], s.dstMAC)
+	copy(frame[6:12], s.srcMAC)
+	binary.BigEndian.PutUint16(frame[12:14], ethPMPLSUnicast)
+	copy(frame[ethHeaderLen:], s.stack)
+
+	s.ipID++
+	ip := frame[ipOff:udpOff]
+	ip[0] = 0x40 | lspIPHeaderLen/4 //版本4，头部长度6
+	binary.BigEndian.PutUint16(ip[2:4], uint16(len(frame)-ipOff))
+	binary.BigEndian.PutUint16(ip[4:6], s.ipID)
+	ip[8] = 1  //TTL为1，标签弹出后不会被IP转发
+	ip[9] = 17 //UDP
+	copy(ip[12:16], s.src)
+	copy(ip[16:20], net.IPv4(127, 0, 0, 1).To4())
+	ip[20], ip[21] = ipOptRouterAlert, 4 //Router Alert，值为0
+	sum, err := checkSum(ip)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint16(ip[10:12], sum)
+
+	udp := frame[udpOff:]
+	binary.BigEndian.PutUint16(udp[0:2], uint16(srcPort))
+	binary.BigEndian.PutUint16(udp[2:4], lspPingPort)
+	binary.BigEndian.PutUint16(udp[4:6], uint16(udpHeaderLen+len(payload)))
+	//IPv4下UDP校验和可以为0，表示不校验
+	copy(udp[udpHeaderLen:], payload)
+
+	return syscall.Sendto(s.fd, frame, 0, &s.addr)
+}
+
+func (s *lspSender) close() {
+	syscall.Close(s.fd)
+}
+
+// 从ARP表中查找邻居的MAC地址，不存在时先发一个UDP报文触发ARP解析
+func neighborMAC(ip net.IP, ifname string) (net.HardwareAddr, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		if mac, err := lookupARP(ip, ifname); err == nil {
+			return mac, nil
+		}
+		if conn, err := net.Dial("udp4", net.JoinHostPort(ip.String(), "9")); err == nil {
+			conn.Write([]byte{0})
+			conn.Close()
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return nil, fmt.Errorf("无法解析下一跳 %s 的MAC地址，LSP ping的目标须为直连的下一跳", ip)
+}
+
+// 读取 /proc/net/arp：IP address, HW type, Flags, HW address, Mask, Device
+// ifname为空时不限接口
+func lookupARP(ip net.IP, ifname string) (net.HardwareAddr, error) {
+	f, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() //表头
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || (ifname != "" && fields[5] != ifname) || !net.ParseIP(fields[0]).Equal(ip) {
+			continue
+		}
+		if fields[2] == "0x0" { //未完成解析
+			break
+		}
+		return net.ParseMAC(fields[3])
+	}
+	return nil, fmt.Errorf("ARP表中没有 %s", ip)
+}
+
+// 主机字节序转换为网络字节序
+func htons(v uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return *(*uint16)(unsafe.Pointer(&b[0]))
+}
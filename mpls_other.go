@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+// lspSender 通过AF_PACKET发送带标签栈的报文，仅Linux支持
+type lspSender struct{}
+
+func newLSPSender(nexthop string, labels []uint32) (*lspSender, error) {
+	return nil, errLSPUnsupported
+}
+
+func (s *lspSender) send(payload []byte, srcPort int) error {
+	return errLSPUnsupported
+}
+
+func (s *lspSender) close() {}
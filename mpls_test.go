@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLabelStack(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want []uint32
+		err  string
+	}{
+		{"16", []uint32{16}, ""},
+		{"16, 24001,3", []uint32{16, 24001, 3}, ""},
+		{"1048575", []uint32{mplsLabelMax}, ""},
+		{"1048576", nil, `无效的标签 "1048576"`},
+		{"16,", nil, `无效的标签 ""`},
+		{"x", nil, `无效的标签 "x"`},
+		{"1,2,3,4,5,6,7,8,9", nil, "标签栈最多 8 层"},
+	}
+	for _, tt := range tests {
+		got, err := parseLabelStack(tt.arg)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parseLabelStack(%q) 的错误 = %v，应包含 %q", tt.arg, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || len(got) != len(tt.want) {
+			t.Errorf("parseLabelStack(%q) = %v, %v，应为 %v", tt.arg, got, err, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("parseLabelStack(%q) = %v，应为 %v", tt.arg, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestParseLSPPrefix(t *testing.T) {
+	tests := []struct {
+		arg, want string
+	}{
+		{"10.0.0.1", "10.0.0.1/32"},
+		{"10.1.2.0/24", "10.1.2.0/24"},
+		{"10.1.2.3/24", "10.1.2.0/24"},
+		{"2001:db8::/32", ""},
+		{"10.0.0.1/33", ""},
+		{"pe1.example", ""},
+	}
+	for _, tt := range tests {
+		got, err := parseLSPPrefix(tt.arg)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("parseLSPPrefix(%q) = %v，应返回错误", tt.arg, got)
+			}
+			continue
+		}
+		if err != nil || got.String() != tt.want {
+			t.Errorf("parseLSPPrefix(%q) = %v, %v，应为 %s", tt.arg, got, err, tt.want)
+		}
+	}
+}
+
+// 标签(20) TC(3) S(1) TTL(8)，只有最内层置S位
+func TestBuildLabelStack(t *testing.T) {
+	got := buildLabelStack([]uint32{16, 24001}, 255)
+	want := []byte{0x00, 0x01, 0x00, 0xff, 0x05, 0xdc, 0x11, 0xff}
+	if !bytes.Equal(got, want) {
+		t.Errorf("buildLabelStack = % x，应为 % x", got, want)
+	}
+}
+
+// 请求的头部及 Target FEC Stack TLV，应答方改写消息类型、返回码及收到时间后能够解析
+func TestLSPEchoRoundTrip(t *testing.T) {
+	_, fec, _ := net.ParseCIDR("10.1.2.0/24")
+	sent := time.Unix(1700000000, 0)
+	b := buildLSPEchoRequest(0x11223344, 5, fec, sent)
+	wantHdr := []byte{0, 1, 0, 0, lspEchoRequest, lspReplyModeUDP, 0, 0, 0x11, 0x22, 0x33, 0x44, 0, 0, 0, 5}
+	if !bytes.Equal(b[:16], wantHdr) {
+		t.Errorf("头部 = % x，应为 % x", b[:16], wantHdr)
+	}
+	if !ntpTime(b[16:24]).Equal(sent) {
+		t.Errorf("发送时间 = %v", ntpTime(b[16:24]))
+	}
+	wantTLV := []byte{0, 1, 0, 12, 0, 1, 0, 5, 10, 1, 2, 0, 24, 0, 0, 0}
+	if !bytes.Equal(b[lspHeaderLen:], wantTLV) {
+		t.Errorf("TLV = % x，应为 % x", b[lspHeaderLen:], wantTLV)
+	}
+
+	if _, err := parseLSPEchoReply(b); err == nil {
+		t.Error("回显请求不应被当作应答")
+	}
+	if _, err := parseLSPEchoReply(b[:lspHeaderLen-1]); err == nil {
+		t.Error("过短的报文应返回错误")
+	}
+	reply := append([]byte(nil), b...)
+	reply[4], reply[6], reply[7] = lspEchoReply, 3, 1
+	putNTPTime(reply[24:32], sent.Add(time.Millisecond))
+	r, err := parseLSPEchoReply(reply) //NTP时间戳换算为纳秒时有舍入
+	if d := r.received.Sub(sent); err != nil || r.code != 3 || r.subcode != 1 || r.handle != 0x11223344 || r.seq != 5 || d < time.Millisecond-time.Microsecond || d > time.Millisecond {
+		t.Errorf("parseLSPEchoReply = %+v, %v", r, err)
+	}
+	if s := lspReturnCode(r.code); s != "应答方是该FEC的出口" {
+		t.Errorf("lspReturnCode(3) = %q", s)
+	}
+	if s := lspReturnCode(200); s != "返回码 200" {
+		t.Errorf("lspReturnCode(200) = %q", s)
+	}
+}
+
+// 发到本地UDP端口的报文中只有回显应答被转发
+func TestReadLSPReplies(t *testing.T) {
+	uc, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip(err)
+	}
+	results := make(chan lspResult, 4)
+	done := make(chan struct{})
+	go func() {
+		readLSPReplies(uc, results)
+		close(done)
+	}()
+
+	c, err := net.DialUDP("udp4", nil, uc.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	_, fec, _ := net.ParseCIDR("10.0.0.1/32")
+	req := buildLSPEchoRequest(1, 7, fec, time.Now())
+	reply := append([]byte(nil), req...)
+	reply[4] = lspEchoReply
+	c.Write(req)
+	c.Write([]byte("x"))
+	c.Write(reply)
+
+	select {
+	case res := <-results:
+		if res.unreachable || res.reply.handle != 1 || res.reply.seq != 7 || !res.from.Equal(net.IPv4(127, 0, 0, 1)) {
+			t.Errorf("结果 = %+v", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("没有收到应答")
+	}
+	uc.Close()
+	<-done
+	if len(results) != 0 {
+		t.Errorf("多出 %d 个结果", len(results))
+	}
+}
+
+// -mpls-lsp 需要有效的前缀及 -mpls-label，且只能指定一个目标
+func TestMPLSFlags(t *testing.T) {
+	parseArgs(t, "-mpls-lsp", "10.0.0.1", "-mpls-label", "16", "192.0.2.1")
+	if mplsPrefix != "10.0.0.1" || mplsLabelArg != "16" {
+		t.Errorf("mplsPrefix = %q, mplsLabelArg = %q", mplsPrefix, mplsLabelArg)
+	}
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-mpls-lsp", "10.0.0.1", "192.0.2.1"}, "-mpls-lsp 需要以 -mpls-label 指定下一跳为该FEC分配的标签"},
+		{[]string{"-mpls-lsp", "2001:db8::/32", "-mpls-label", "16", "192.0.2.1"}, `-mpls-lsp: 无效的IPv4前缀 "2001:db8::/32"`},
+		{[]string{"-mpls-lsp", "10.0.0.1", "-mpls-label", "16,x", "192.0.2.1"}, `-mpls-label: 无效的标签 "x"`},
+	}
+	for _, tt := range tests {
+		if errs := argErrors(t, tt.args...); !hasArgError(errs, tt.err) {
+			t.Errorf("%q 的错误 = %q，应包含 %q", tt.args, errs, tt.err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+var sizeAuto bool //是否根据出口接口MTU自动计算数据长度
+
+// 数据长度参数，除数字外还接受 auto
+type sizeValue struct {
+	n *int
+}
+
+func (v sizeValue) String() string {
+	if v.n == nil {
+		return ""
+	}
+	return strconv.Itoa(*v.n)
+}
+
+func (v sizeValue) Set(s string) error {
+	if s == "auto" {
+		sizeAuto = true
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("应为整数或 auto")
+	}
+	*v.n = n
+	return nil
+}
+
+// 查找发往目标的报文所经过的出口接口
+// 通过连接一个UDP socket(不会真正发送数据)让内核选路，再按源地址找到对应接口
+func outgoingInterface(host string) (*net.Interface, error) {
+	conn, err := net.Dial("udp4", net.JoinHostPort(host, "9"))
+	if err != nil {
+		return nil, err
+	}
+	src := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ifaces {
+		addrs, err := ifaces[i].Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.Equal(src) {
+				return &ifaces[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("找不到源地址 %s 所在的接口", src)
+}
+
+const maxIPPacket = 65535 //IPv4报文最大长度
+
+// 根据出口接口MTU计算最大的不分片数据长度：MTU - IP头(20) - ICMP头(8)
+// 回环接口的MTU可能超过IPv4报文的最大长度，此时以最大长度为准
+func sizeFromMTU(host string) (int, *net.Interface, error) {
+	iface, err := outgoingInterface(host)
+	if err != nil {
+		return 0, nil, err
+	}
+	if iface.MTU <= ipICMPHdrLen {
+		return 0, nil, fmt.Errorf("接口 %s 的MTU %d 过小", iface.Name, iface.MTU)
+	}
+	mtu := iface.MTU
+	if mtu > maxIPPacket {
+		mtu = maxIPPacket
+	}
+	return mtu - ipICMPHdrLen, iface, nil
+}
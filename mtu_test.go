@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+// -l、-s、--size 接受 auto，-size-from-mtu 与之相同
+func TestSizeAutoFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		auto bool
+		size int
+	}{
+		{nil, false, 32},
+		{[]string{"-l", "auto"}, true, 32},
+		{[]string{"-s", "auto"}, true, 32},
+		{[]string{"--size", "auto"}, true, 32},
+		{[]string{"-size-from-mtu"}, true, 32},
+		{[]string{"-l", "100"}, false, 100},
+	}
+	for _, tt := range tests {
+		parseArgs(t, tt.args...)
+		if sizeAuto != tt.auto || size != tt.size {
+			t.Errorf("%q: sizeAuto = %v，size = %d，应为 %v，%d", tt.args, sizeAuto, size, tt.auto, tt.size)
+		}
+	}
+	var n int
+	if err := (sizeValue{&n}).Set("big"); err == nil || err.Error() != "应为整数或 auto" {
+		t.Errorf("Set(\"big\") = %v", err)
+	}
+}
+
+// 发往本机的报文经过回环接口，其MTU超过IPv4报文最大长度时以65535计算
+func TestSizeFromMTU(t *testing.T) {
+	n, iface, err := sizeFromMTU("127.0.0.1")
+	if err != nil {
+		t.Skipf("无法确定出口接口: %v", err)
+	}
+	if iface.Flags&net.FlagLoopback == 0 {
+		t.Errorf("127.0.0.1 的出口接口为 %s", iface.Name)
+	}
+	want := iface.MTU - ipICMPHdrLen
+	if iface.MTU > maxIPPacket {
+		want = maxIPPacket - ipICMPHdrLen
+	}
+	if n != want {
+		t.Errorf("sizeFromMTU = %d (接口 %s MTU=%d)，应为 %d", n, iface.Name, iface.MTU, want)
+	}
+}
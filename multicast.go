@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+var multicast bool //-multicast 向组播地址(IPv4或IPv6)发送回显请求，输出每台应答的组成员
+
+// RunMulticast 加入目标组播组(224.0.0.0/4 或 ff00::/8)后向该组发送回显请求，
+// 每次请求在超时时间内收集所有组成员的应答，每台主机输出一行
+func (p *Pinger) RunMulticast() {
+	if strings.Contains(icmpHost(p.Arg), ":") {
+		p.runMulticast6()
+		return
+	}
+	raddr, conn := p.listenGroup()
+	if conn == nil {
+		return
+	}
+	defer conn.Close()
+	if !raddr.IP.IsMulticast() {
+		err := fmt.Errorf("%s 不是IPv4组播地址(224.0.0.0/4)", raddr)
+		p.fail(err)
+		p.printf("%v\n", err)
+		return
+	}
+	if err := joinMulticast(conn, raddr.IP); err != nil {
+		p.fail(err)
+		p.printf("无法加入组播组 %s: %v\n", raddr, err)
+		return
+	}
+
+	p.printf("正在向组播组 %s 发送 %d 字节的数据，收集 %dms 内的所有应答：\n", p.Addr, p.Size, p.Timeout)
+	p.collectGroupReplies(conn, raddr, groupIPv4)
+}
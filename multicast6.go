@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// ICMPv6回显的类型，见RFC 4443
+const (
+	icmpv6EchoRequest = 128
+	icmpv6EchoReply   = 129
+)
+
+var groupIPv6 = groupProto{fill: fillEcho6, parse: parseGroupReply6}
+
+// 构造ICMPv6回显请求，校验和包含IPv6伪首部，由内核计算
+func fillEcho6(pkt []byte, seq int) error {
+	pkt[0] = icmpv6EchoRequest
+	pkt[1] = 0
+	binary.BigEndian.PutUint16(pkt[2:4], 0)
+	binary.BigEndian.PutUint16(pkt[4:6], echoID)
+	binary.BigEndian.PutUint16(pkt[6:8], uint16(seq))
+	return nil
+}
+
+// 解析ICMPv6的回显应答，IPv6原始套接字不返回IP头，跳数限制取自控制消息
+func parseGroupReply6(pkt, oob []byte, from *net.IPAddr, seq int) (groupReply, bool) {
+	if from == nil || len(pkt) < 8 || pkt[0] != icmpv6EchoReply || binary.BigEndian.Uint16(pkt[4:6]) != echoID || binary.BigEndian.Uint16(pkt[6:8]) != uint16(seq) {
+		return groupReply{}, false
+	}
+	r := groupReply{src: from.IP, name: from.String(), bytes: len(pkt) - 8}
+	if hl := hopLimitOf(oob); hl >= 0 {
+		r.hops = "跳数限制=" + strconv.Itoa(hl)
+	}
+	return r, true
+}
+
+// 组播地址中的接口(ff02::1%eth0)，可以是接口名或序号，没有时返回nil
+func zoneInterface(zone string) (*net.Interface, error) {
+	if zone == "" {
+		return nil, nil
+	}
+	if idx, err := strconv.Atoi(zone); err == nil {
+		return net.InterfaceByIndex(idx)
+	}
+	return net.InterfaceByName(zone)
+}
+
+// 向IPv6组播组(如 ff02::1 所有节点)发送ICMPv6回显请求
+// 链路本地范围的组(ff02::/16)必须以 %接口 指定发送的接口
+func (p *Pinger) runMulticast6() {
+	p.Host = icmpHost(p.Arg)
+	raddr, err := net.ResolveIPAddr("ip6", p.Host)
+	if err != nil {
+		p.fail(err)
+		p.printf("%s", dialErrorText(p.Host, err))
+		return
+	}
+	p.Addr = raddr.String()
+	if !raddr.IP.IsMulticast() || raddr.IP.To4() != nil {
+		err := fmt.Errorf("%s 不是IPv6组播地址(ff00::/8)", raddr)
+		p.fail(err)
+		p.printf("%v\n", err)
+		return
+	}
+	ifi, err := zoneInterface(raddr.Zone)
+	if err != nil {
+		p.fail(err)
+		p.printf("无法找到接口 %s: %v\n", raddr.Zone, err)
+		return
+	}
+	if ifi == nil && (raddr.IP.IsInterfaceLocalMulticast() || raddr.IP.IsLinkLocalMulticast()) {
+		err := fmt.Errorf("%s 是链路本地范围的组播地址，需要以 %%接口 指定接口，如 %s%%eth0", raddr, raddr)
+		p.fail(err)
+		p.printf("%v\n", err)
+		return
+	}
+
+	pc, err := net.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		p.fail(err)
+		p.printf("%s", dialErrorText(p.Host, err))
+		return
+	}
+	conn := pc.(*net.IPConn)
+	defer conn.Close()
+	ifindex := 0
+	if ifi != nil {
+		ifindex = ifi.Index
+	}
+	if err := joinMulticast6(conn, raddr.IP, ifindex); err != nil {
+		p.fail(err)
+		p.printf("无法加入组播组 %s: %v\n", raddr, err)
+		return
+	}
+	setRecvHopLimit(conn) //失败时只是不输出跳数限制
+
+	p.printf("正在向组播组 %s 发送 %d 字节的数据，收集 %dms 内的所有应答：\n", p.Addr, p.Size, p.Timeout)
+	p.collectGroupReplies(conn, raddr, groupIPv6)
+}
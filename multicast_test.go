@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMulticastFlags(t *testing.T) {
+	if errs := argErrors(t, "-broadcast", "-multicast", "224.0.0.1"); !hasArgError(errs, "参数 -broadcast 与 -multicast 不能同时指定") {
+		t.Errorf("-broadcast 与 -multicast 的错误 = %q", errs)
+	}
+	if errs := argErrors(t, "-multicast", "-drop-privs", "nobody", "224.0.0.1"); !hasArgError(errs, "-multicast 同时使用") {
+		t.Errorf("-drop-privs 与 -multicast 的错误 = %q", errs)
+	}
+}
+
+// 目标不是组播地址时不发送请求
+func TestRunMulticastNotGroup(t *testing.T) {
+	needRawSocket(t)
+	parseArgs(t, "-multicast", "-n", "1", "127.0.0.1")
+	p := newPinger("127.0.0.1")
+	stdout, _ := captureOutput(t, p.RunMulticast)
+	if p.Err == nil || p.Stats.Snapshot().Sent != 0 || !strings.Contains(stdout, "127.0.0.1 不是IPv4组播地址(224.0.0.0/4)") {
+		t.Errorf("错误 %v，输出:\n%s", p.Err, stdout)
+	}
+}
+
+// 加入组播组后发送请求；是否有成员应答取决于网络及 icmp_echo_ignore_broadcasts
+func TestRunMulticast(t *testing.T) {
+	needRawSocket(t)
+	parseArgs(t, "-multicast", "-n", "1", "-w", "200", "224.0.0.1")
+	p := newPinger("224.0.0.1")
+	stdout, _ := captureOutput(t, p.RunMulticast)
+	if p.Err != nil {
+		t.Skipf("无法加入组播组: %v", p.Err)
+	}
+	if ss := p.Stats.Snapshot(); ss.Sent != 1 || ss.SendErrors != 0 {
+		t.Errorf("统计 = %+v", ss)
+	}
+	if !strings.Contains(stdout, "正在向组播组 224.0.0.1 发送 32 字节的数据，收集 200ms 内的所有应答") {
+		t.Errorf("输出:\n%s", stdout)
+	}
+}
+
+// ICMPv6回显请求：类型128 代码0，检验和由内核计算
+func TestFillEcho6(t *testing.T) {
+	pkt := make([]byte, 8+4)
+	pkt[2] = 0xff
+	if err := fillEcho6(pkt, 0x0102); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{128, 0, 0, 0, byte(echoID >> 8), byte(echoID), 0x01, 0x02, 0, 0, 0, 0}
+	if !bytes.Equal(pkt, want) {
+		t.Errorf("请求 = % x，期望 % x", pkt, want)
+	}
+}
+
+func TestParseGroupReply(t *testing.T) {
+	req, err := buildEcho(7, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := newMockConn()
+	conn.Write(req)
+	r, ok := parseGroupReply4(conn.reply, nil, nil, 7)
+	if !ok || r.name != "127.0.0.1" || r.bytes != 16 || r.hops != "TTL=64" {
+		t.Errorf("IPv4应答 = %+v, %v", r, ok)
+	}
+	own := append([]byte(nil), conn.reply...)
+	own[20] = 8
+	for name, pkt := range map[string][]byte{"自己的请求": own, "过短": conn.reply[:27], "不是IPv4": append([]byte{0x60}, conn.reply[1:]...)} {
+		if _, ok := parseGroupReply4(pkt, nil, nil, 7); ok {
+			t.Errorf("IPv4 %s: 被当作应答", name)
+		}
+	}
+	if _, ok := parseGroupReply4(conn.reply, nil, nil, 8); ok {
+		t.Error("IPv4 其他序号: 被当作应答")
+	}
+
+	reply6 := []byte{129, 0, 0, 0, byte(echoID >> 8), byte(echoID), 0, 7, 1, 2, 3, 4}
+	from := &net.IPAddr{IP: net.ParseIP("fe80::1"), Zone: "eth0"}
+	r, ok = parseGroupReply6(reply6, nil, from, 7)
+	if !ok || r.name != "fe80::1%eth0" || r.bytes != 4 || r.hops != "" {
+		t.Errorf("IPv6应答 = %+v, %v", r, ok)
+	}
+	tests := []struct {
+		name string
+		pkt  []byte
+		from *net.IPAddr
+		seq  int
+	}{
+		{"回显请求", append([]byte{128}, reply6[1:]...), from, 7},
+		{"其他ID", append(append([]byte(nil), reply6[:4]...), append([]byte{0, 0}, reply6[6:]...)...), from, 7},
+		{"其他序号", reply6, from, 8},
+		{"过短", reply6[:7], from, 7},
+		{"没有来源", reply6, nil, 7},
+	}
+	for _, tt := range tests {
+		if _, ok := parseGroupReply6(tt.pkt, nil, tt.from, tt.seq); ok {
+			t.Errorf("IPv6 %s: 被当作应答", tt.name)
+		}
+	}
+}
+
+// IPv6主机按16字节地址排序，输出带接口的地址
+func TestPrintGroupHosts6(t *testing.T) {
+	p := &Pinger{}
+	stdout, _ := captureOutput(t, func() {
+		p.printGroupHosts(map[string]*groupHost{
+			"fe80::10%eth0": {ip: net.ParseIP("fe80::10"), name: "fe80::10%eth0", replies: 1, minRTT: 2 * time.Millisecond},
+			"fe80::9%eth0":  {ip: net.ParseIP("fe80::9"), name: "fe80::9%eth0", replies: 2, minRTT: time.Millisecond},
+		})
+	})
+	want := "\n共 2 台主机应答:\n" +
+		"    fe80::9%eth0    应答 2 次，最短 1ms\n" +
+		"    fe80::10%eth0   应答 1 次，最短 2ms\n"
+	if stdout != want {
+		t.Errorf("输出:\n%s\n期望:\n%s", stdout, want)
+	}
+}
+
+// 这些错误在建立套接字之前检查，不需要root权限
+func TestRunMulticast6Errors(t *testing.T) {
+	tests := []struct {
+		target string
+		err    string
+	}{
+		{"2001:db8::1", "2001:db8::1 不是IPv6组播地址(ff00::/8)"},
+		{"ff02::1", "ff02::1 是链路本地范围的组播地址，需要以 %接口 指定接口，如 ff02::1%eth0"},
+		{"ff01::1", "ff01::1 是链路本地范围的组播地址"},
+		{"ff02::1%nosuch0", "无法找到接口 nosuch0"},
+	}
+	for _, tt := range tests {
+		parseArgs(t, "-multicast", "-n", "1", tt.target)
+		p := newPinger(tt.target)
+		stdout, _ := captureOutput(t, p.RunMulticast)
+		if p.Err == nil || p.Stats.Snapshot().Sent != 0 || !strings.Contains(stdout, tt.err) {
+			t.Errorf("%s: 错误 %v，输出:\n%s", tt.target, p.Err, stdout)
+		}
+	}
+}
+
+func TestZoneInterface(t *testing.T) {
+	if ifi, err := zoneInterface(""); ifi != nil || err != nil {
+		t.Errorf("没有接口时 = %v, %v", ifi, err)
+	}
+	ifs, err := net.Interfaces()
+	if err != nil || len(ifs) == 0 {
+		t.Skipf("没有网络接口: %v", err)
+	}
+	for _, zone := range []string{ifs[0].Name, strconv.Itoa(ifs[0].Index)} {
+		if ifi, err := zoneInterface(zone); err != nil || ifi.Index != ifs[0].Index {
+			t.Errorf("zoneInterface(%q) = %v, %v", zone, ifi, err)
+		}
+	}
+	if _, err := zoneInterface("nosuch0"); err == nil {
+		t.Error("不存在的接口没有报错")
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+var multiDNS bool //-multi-dns 分别ping主机名解析出的每个IPv4地址
+
+// 同一主机名解析出的各地址
+type dnsGroup struct {
+	host    string
+	pingers []*Pinger
+}
+
+// 解析主机名的所有IPv4地址，去掉重复的地址
+func resolveAll(host string) ([]string, error) {
+	addrs, err := net.LookupHost(host)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var ips []string
+	for _, a := range addrs {
+		if ip := net.ParseIP(a); ip != nil && ip.To4() != nil && !seen[a] {
+			seen[a] = true
+			ips = append(ips, a)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("%s 没有IPv4地址", host)
+	}
+	return ips, nil
+}
+
+// 按主机名的每个IPv4地址分别创建Pinger，地址继承主机名的标签
+func multiDNSGroups(hosts []string) []dnsGroup {
+	var groups []dnsGroup
+	for _, host := range hosts {
+		ips, err := resolveAll(icmpHost(host))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ping 请求找不到主机 %s: %v\n", host, err)
+			continue
+		}
+		g := dnsGroup{host: host}
+		for _, ip := range ips {
+			p := newPinger(ip)
+			p.Labels = targetLabels[host]
+			g.pingers = append(g.pingers, p)
+		}
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// 并发ping各主机名的所有地址，输出每个地址一行及每个主机名的合计行，返回全部Pinger
+func runMultiDNS(hosts []string) []*Pinger {
+	groups := multiDNSGroups(hosts)
+	var pingers []*Pinger
+	for _, g := range groups {
+		pingers = append(pingers, g.pingers...)
+	}
+	if len(pingers) == 0 {
+		exit(1)
+	}
+	runTable(pingers, func([]*Pinger) []tableRow {
+		var rows []tableRow
+		for _, g := range groups {
+			rows = append(rows, tableRows(g.pingers)...)
+			rows = append(rows, aggregateRow(g))
+		}
+		return rows
+	})
+	return pingers
+}
+
+// 主机名所有地址的合计
+func aggregateRow(g dnsGroup) tableRow {
+	total := StatsSnapshot{Last: -1}
+	for _, p := range g.pingers {
+		ss := p.Stats.Snapshot()
+		if ss.Received > 0 {
+			if total.Received == 0 || ss.Min < total.Min {
+				total.Min = ss.Min
+			}
+			if ss.Max > total.Max {
+				total.Max = ss.Max
+			}
+		}
+		total.Sent += ss.Sent
+		total.Received += ss.Received
+		total.Lost += ss.Lost
+		total.Total += ss.Total
+	}
+	return tableRow{name: fmt.Sprintf("%s 合计(%d 个地址)", g.host, len(g.pingers)), stats: total}
+}
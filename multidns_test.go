@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveAll(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+		err  string
+	}{
+		{"127.0.0.1", "127.0.0.1", ""},
+		{"::1", "", "::1 没有IPv4地址"},
+		{"nx.invalid", "", "nx.invalid"},
+	}
+	for _, tt := range tests {
+		ips, err := resolveAll(tt.host)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("resolveAll(%q) 的错误 = %v，应包含 %q", tt.host, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || strings.Join(ips, " ") != tt.want {
+			t.Errorf("resolveAll(%q) = %v, %v，应为 %s", tt.host, ips, err, tt.want)
+		}
+	}
+}
+
+// 每个地址一个Pinger并继承主机名的标签，无法解析的主机名跳过
+func TestMultiDNSGroups(t *testing.T) {
+	t.Cleanup(func() { targetLabels = map[string]map[string]string{} })
+	parseArgs(t, "-multi-dns", "127.0.0.1=gw", "nx.invalid")
+	hosts := getArgOfHost()
+	var groups []dnsGroup
+	_, stderr := captureOutput(t, func() { groups = multiDNSGroups(hosts) })
+	if len(groups) != 1 || groups[0].host != "127.0.0.1" || len(groups[0].pingers) != 1 {
+		t.Fatalf("groups = %+v", groups)
+	}
+	if p := groups[0].pingers[0]; p.Arg != "127.0.0.1" || p.Labels["name"] != "gw" {
+		t.Errorf("Pinger = %s %v", p.Arg, p.Labels)
+	}
+	if !strings.Contains(stderr, "Ping 请求找不到主机 nx.invalid") {
+		t.Errorf("标准错误: %s", stderr)
+	}
+}
+
+// 合计行的最短、最长取各地址中有回复的，计数相加
+func TestAggregateRow(t *testing.T) {
+	target := func(arg string, rtts ...int64) *Pinger {
+		p := &Pinger{Arg: arg, Stats: newStatistics()}
+		for _, rtt := range rtts {
+			p.Stats.addSent()
+			if rtt < 0 {
+				p.Stats.addFailure()
+				continue
+			}
+			p.Stats.addSuccess(rtt)
+		}
+		return p
+	}
+	g := dnsGroup{host: "cdn.example", pingers: []*Pinger{
+		target("192.0.2.1", 10, 20, -1, 30),
+		target("192.0.2.2", -1, -1, -1, -1),
+		target("192.0.2.3", 5, 50, 15, -1),
+	}}
+	row := aggregateRow(g)
+	ss := row.stats
+	if row.name != "cdn.example 合计(3 个地址)" || ss.Sent != 12 || ss.Received != 6 || ss.Lost != 6 {
+		t.Errorf("合计行 %q: %+v", row.name, ss)
+	}
+	if ss.Min != 5 || ss.Max != 50 || ss.Avg() != 21 {
+		t.Errorf("最短/平均/最长 = %d/%d/%d，期望 5/21/50", ss.Min, ss.Avg(), ss.Max)
+	}
+
+	down := aggregateRow(dnsGroup{host: "down.example", pingers: []*Pinger{target("192.0.2.9", -1, -1)}})
+	if ss := down.stats; ss.Received != 0 || ss.Min != 0 || ss.Max != 0 {
+		t.Errorf("没有回复时的合计行: %+v", ss)
+	}
+	parseArgs(t)
+	if out := renderTable([]tableRow{down}); !strings.Contains(out, "down.example 合计(1 个地址)") {
+		t.Errorf("表格:\n%s", out)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// 在指定的网络命名空间中重新执行本程序，在命名空间中时直接返回
+// setns只对调用的线程生效，其他goroutine(并发的多目标、DNS解析等)所在的线程仍在原命名空间中，
+// 而execve后进程的命名空间取自调用的线程，所以在切换后的线程上重新执行，
+// 新进程的所有线程及socket都属于目标命名空间。原命名空间属于调用方(如shell)，进程退出时不需要恢复
+//
+// 不在锁定的线程上只为建立连接切换再恢复：socket的命名空间在创建时确定，而程序中创建socket的
+// 地方很多，且不少不在调用方的线程上(Go的DNS解析、netlink路由及邻居查询、UDP/TCP/QUIC探测、
+// 各导出的HTTP客户端)，逐一包装容易遗漏，遗漏的socket会静默地使用原命名空间
+//
+// 重新执行的代价：
+//   - 命令行参数及环境变量原样交给新进程，参数重新解析，-config 等文件重新读取
+//   - PID不变，回显请求的ID(echoID)及发给该PID的信号不受影响
+//   - 本进程中已执行的操作不会延续，defer及exit中的清理也不会执行，所以必须在打开任何文件、
+//     socket、导出或添加临时路由之前调用(见main)
+//   - 没有设置close-on-exec的文件描述符(如调用方传入的)由新进程继承，Go打开的都已设置
+func enterNetns(path string) error {
+	inside, err := sameNetns(path)
+	if err != nil {
+		return err
+	}
+	if inside {
+		return nil
+	}
+
+	ns, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer ns.Close()
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	runtime.LockOSThread()
+	orig, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", syscall.Gettid()))
+	if err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	defer orig.Close()
+	if err := unix.Setns(int(ns.Fd()), unix.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("切换到网络命名空间 %s 失败: %v", path, err)
+	}
+	err = syscall.Exec(exe, os.Args, os.Environ()) //成功时不返回
+	if rerr := unix.Setns(int(orig.Fd()), unix.CLONE_NEWNET); rerr == nil {
+		runtime.UnlockOSThread()
+	} //恢复失败时该线程保持锁定，不再被其他goroutine使用
+	return fmt.Errorf("无法在网络命名空间 %s 中重新执行: %v", path, err)
+}
+
+// 当前线程(即整个进程，只有enterNetns会切换单个线程)是否已在path指定的网络命名空间中
+func sameNetns(path string) (bool, error) {
+	var want, cur syscall.Stat_t
+	if err := syscall.Stat(path, &want); err != nil {
+		return false, err
+	}
+	//不用/proc/self：它对应主线程，主线程可能被其他goroutine固定后切换过命名空间
+	if err := syscall.Stat("/proc/thread-self/ns/net", &cur); err != nil {
+		return false, err
+	}
+	return want.Dev == cur.Dev && want.Ino == cur.Ino, nil
+}
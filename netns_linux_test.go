@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// 在一个固定的线程上建立新的网络命名空间，返回其路径，测试结束时该线程随goroutine退出
+func newTestNetns(t *testing.T) string {
+	t.Helper()
+	paths := make(chan string)
+	done := make(chan struct{})
+	go func() {
+		runtime.LockOSThread() //不解锁，goroutine结束时线程退出，不会回到调度中
+		if err := syscall.Unshare(syscall.CLONE_NEWNET); err != nil {
+			paths <- ""
+			return
+		}
+		paths <- fmt.Sprintf("/proc/%d/task/%d/ns/net", os.Getpid(), syscall.Gettid())
+		<-done
+	}()
+	path := <-paths
+	if path == "" {
+		t.Skip("无法建立网络命名空间(需要root)")
+	}
+	t.Cleanup(func() { close(done) })
+	return path
+}
+
+func TestSameNetns(t *testing.T) {
+	if same, err := sameNetns("/proc/thread-self/ns/net"); err != nil || !same {
+		t.Fatalf("sameNetns(/proc/thread-self/ns/net) = %v, %v", same, err)
+	}
+	if _, err := sameNetns("/nonexistent/ns"); err == nil {
+		t.Fatal("不存在的路径应报错")
+	}
+	if same, err := sameNetns(newTestNetns(t)); err != nil || same {
+		t.Fatalf("sameNetns(新命名空间) = %v, %v", same, err)
+	}
+}
+
+// 重新执行后整个进程都在目标命名空间中：其他goroutine看到的接口只有新命名空间中的lo
+func TestEnterNetnsReexec(t *testing.T) {
+	path := newTestNetns(t)
+	cmd := exec.Command(os.Args[0], "-test.run=^TestNetnsHelper$")
+	cmd.Env = append(os.Environ(), "PING_TEST_NETNS="+path)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	if !strings.Contains(string(out), "接口=[lo]\n") {
+		t.Fatalf("子进程输出: %s", out)
+	}
+}
+
+// 由TestEnterNetnsReexec在子进程中运行
+func TestNetnsHelper(t *testing.T) {
+	path := os.Getenv("PING_TEST_NETNS")
+	if path == "" {
+		t.Skip("只在子进程中运行")
+	}
+	if err := enterNetns(path); err != nil {
+		t.Fatal(err)
+	}
+	names := make(chan []string)
+	go func() {
+		var list []string
+		ifaces, _ := net.Interfaces()
+		for _, ifi := range ifaces {
+			list = append(list, ifi.Name)
+		}
+		names <- list
+	}()
+	fmt.Printf("接口=%v\n", <-names)
+}
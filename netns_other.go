@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// 在指定的网络命名空间中重新执行本程序，仅Linux支持
+func enterNetns(path string) error {
+	return errors.New("-netns 仅支持Linux")
+}
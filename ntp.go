@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+var ntpServer string //-ntp 要测量的NTP服务器
+
+const (
+	ntpPort        = "123"
+	ntpPacketLen   = 48
+	ntpVersion     = 4
+	ntpModeClient  = 3
+	ntpModeServer  = 4
+	ntpUnsynced    = 16 //层级16表示未同步
+	ntpOriginOff   = 24 //应答中回带的请求发送时间
+	ntpTransmitOff = 40
+)
+
+// NTP应答中的服务器状态
+type ntpReply struct {
+	stratum   int
+	refID     string
+	rootDelay time.Duration
+	rootDisp  time.Duration
+	origin    uint64 //回带的请求发送时间，用于匹配请求
+}
+
+// 构造NTP客户端请求(模式3，版本4)，发送时间写入Transmit Timestamp
+func buildNTPRequest(t time.Time) []byte {
+	b := make([]byte, ntpPacketLen)
+	b[0] = ntpVersion<<3 | ntpModeClient //LI=0
+	putNTPTime(b[ntpTransmitOff:], t)
+	return b
+}
+
+// NTP短格式(16.16定点秒)转为时长
+func ntpShort(v uint32) time.Duration {
+	return time.Duration((uint64(v) * uint64(time.Second)) >> 16)
+}
+
+// 参考时钟ID：层级0(Kiss-o'-Death)和1为ASCII代码，其余为上游服务器的IPv4地址
+func ntpRefID(stratum int, b []byte) string {
+	if stratum <= 1 {
+		return strings.TrimRight(string(b), "\x00")
+	}
+	return net.IP(b).String()
+}
+
+// 解析NTP应答
+func parseNTPReply(b []byte) (ntpReply, error) {
+	if len(b) < ntpPacketLen {
+		return ntpReply{}, fmt.Errorf("应答过短: %d 字节", len(b))
+	}
+	if mode := b[0] & 0x07; mode != ntpModeServer {
+		return ntpReply{}, fmt.Errorf("不是服务器应答(模式 %d)", mode)
+	}
+	r := ntpReply{
+		stratum:   int(b[1]),
+		rootDelay: ntpShort(binary.BigEndian.Uint32(b[4:8])),
+		rootDisp:  ntpShort(binary.BigEndian.Uint32(b[8:12])),
+		origin:    binary.BigEndian.Uint64(b[ntpOriginOff:]),
+	}
+	r.refID = ntpRefID(r.stratum, b[12:16])
+	return r, nil
+}
+
+// RunNTP 以NTP客户端请求(UDP 123)的往返时间代替ICMP，同时输出服务器的层级、参考时钟ID、根延迟及根离散度
+// 层级为0(Kiss-o'-Death)视为失败；层级变化时给出提示
+func (p *Pinger) RunNTP() {
+	t := normalizeTarget(p.Arg)
+	if t.Port == "" {
+		t.Port = ntpPort
+	}
+	p.Host = net.JoinHostPort(t.Host, t.Port)
+	conn, err := net.DialTimeout("udp", p.Host, time.Duration(p.Timeout)*time.Millisecond)
+	if err != nil {
+		p.fail(err)
+		p.printf("无法连接NTP服务器 %s: %v\n", p.Host, err)
+		return
+	}
+	defer conn.Close()
+	p.Addr = conn.RemoteAddr().String()
+
+	p.printf("正在向NTP服务器 %s 发送请求：\n", p.Addr)
+
+	bufp := recvBufPool.Get().(*[]byte)
+	defer recvBufPool.Put(bufp)
+	buf := *bufp
+	lastStratum := -1
+
+	for i := 0; forever || i < p.Count; i++ {
+		if !p.nextProbe(i) {
+			break
+		}
+
+		p.Stats.addSent()
+		conn.SetDeadline(time.Now().Add(time.Duration(p.Timeout) * time.Millisecond))
+
+		tStart := time.Now()
+		req := buildNTPRequest(tStart)
+		origin := binary.BigEndian.Uint64(req[ntpTransmitOff:])
+		if _, err := conn.Write(req); err != nil {
+			p.Stats.addSendError(err)
+			p.Stats.addFailure()
+			p.printf("请求失败: %s\n", sendErrorText(err))
+			continue
+		}
+
+		//跳过迟到的旧应答
+		var r ntpReply
+		for {
+			n, rerr := conn.Read(buf)
+			if err = rerr; err != nil {
+				break
+			}
+			if r, err = parseNTPReply(buf[:n]); err == nil && r.origin == origin {
+				break
+			}
+		}
+		tSpend := time.Since(tStart).Milliseconds()
+		if err == nil && r.stratum == 0 {
+			err = errors.New("服务器拒绝服务(Kiss-o'-Death " + r.refID + ")")
+		}
+		if err != nil {
+			p.Stats.addFailure()
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				p.Stats.addTimeout()
+				p.printf("请求超时。\n")
+			} else {
+				p.printf("请求失败: %v\n", err)
+			}
+			continue
+		}
+		p.Stats.addSuccess(tSpend)
+
+		stratum := fmt.Sprint(r.stratum)
+		if r.stratum >= ntpUnsynced {
+			stratum += "(未同步)"
+		}
+		p.printf("来自 %s 的回复: 序号=%d 时间=%dms 层级=%s 参考=%s 根延迟=%.3fms 根离散度=%.3fms\n",
+			p.Addr, i, tSpend, stratum, r.refID, msFloat(r.rootDelay), msFloat(r.rootDisp))
+		if lastStratum >= 0 && r.stratum != lastStratum {
+			p.printf("注意: 层级由 %d 变为 %d\n", lastStratum, r.stratum)
+		}
+		lastStratum = r.stratum
+	}
+
+	p.printSummary()
+}
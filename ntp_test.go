@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildNTPRequest(t *testing.T) {
+	sent := time.Unix(1700000000, 0)
+	b := buildNTPRequest(sent)
+	if len(b) != ntpPacketLen || b[0] != 0x23 || !bytes.Equal(b[1:ntpTransmitOff], make([]byte, ntpTransmitOff-1)) {
+		t.Errorf("请求 = % x", b)
+	}
+	if got := ntpTime(b[ntpTransmitOff:]); !got.Equal(sent) {
+		t.Errorf("发送时间 = %v，应为 %v", got, sent)
+	}
+}
+
+// 构造服务器应答，origin回带请求的发送时间
+func ntpServerReply(req []byte, stratum byte, refID string) []byte {
+	b := make([]byte, ntpPacketLen)
+	b[0] = ntpVersion<<3 | ntpModeServer
+	b[1] = stratum
+	binary.BigEndian.PutUint32(b[4:8], 0x00018000) //1.5秒
+	binary.BigEndian.PutUint32(b[8:12], 0x00000040)
+	copy(b[12:16], refID)
+	copy(b[ntpOriginOff:ntpOriginOff+8], req[ntpTransmitOff:])
+	return b
+}
+
+func TestParseNTPReply(t *testing.T) {
+	req := buildNTPRequest(time.Unix(1700000000, 0))
+	r, err := parseNTPReply(ntpServerReply(req, 1, "GPS"))
+	if err != nil || r.stratum != 1 || r.refID != "GPS" || r.rootDelay != 1500*time.Millisecond || r.rootDisp != 976562 || r.origin != binary.BigEndian.Uint64(req[ntpTransmitOff:]) {
+		t.Errorf("parseNTPReply = %+v, %v", r, err)
+	}
+	up := ntpServerReply(req, 2, "")
+	copy(up[12:16], []byte{192, 0, 2, 123})
+	if r, _ := parseNTPReply(up); r.refID != "192.0.2.123" {
+		t.Errorf("层级2的参考时钟ID = %q", r.refID)
+	}
+	if _, err := parseNTPReply(req); err == nil || !strings.Contains(err.Error(), "不是服务器应答(模式 3)") {
+		t.Errorf("请求被当作应答: %v", err)
+	}
+	if _, err := parseNTPReply(req[:40]); err == nil || !strings.Contains(err.Error(), "应答过短: 40 字节") {
+		t.Errorf("过短的应答: %v", err)
+	}
+}
+
+// 本地NTP服务器：先回一个origin不符的旧应答，再按stratums依次回复
+func startNTPServer(t *testing.T, stratums ...byte) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for i := 0; ; i++ {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil || n < ntpPacketLen {
+				return
+			}
+			refID := "GPS"
+			if stratums[i] == 0 {
+				refID = "RATE"
+			}
+			reply := ntpServerReply(buf[:n], stratums[i], refID)
+			stale := append([]byte(nil), reply...)
+			stale[ntpOriginOff+7]++
+			pc.WriteTo(stale, addr)
+			pc.WriteTo(reply, addr)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+// 层级变化时提示，Kiss-o'-Death 计为失败，未同步的层级注明
+func TestRunNTP(t *testing.T) {
+	addr := startNTPServer(t, 1, 2, 0, 16)
+	parseArgs(t, "-n", "4", "-w", "2000", "-ntp", addr)
+	p := newPinger(ntpServer)
+	stdout, _ := captureOutput(t, p.RunNTP)
+	for _, want := range []string{
+		"来自 " + addr + " 的回复: 序号=0 ",
+		"层级=1 参考=GPS 根延迟=1500.000ms 根离散度=0.977ms",
+		"注意: 层级由 1 变为 2",
+		"请求失败: 服务器拒绝服务(Kiss-o'-Death RATE)",
+		"层级=16(未同步)",
+		"注意: 层级由 2 变为 16",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("输出中没有 %q:\n%s", want, stdout)
+		}
+	}
+	if ss := p.Stats.Snapshot(); ss.Sent != 4 || ss.Received != 3 {
+		t.Errorf("统计 = %+v", ss)
+	}
+}
+
+func TestRunNTPTimeout(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	parseArgs(t, "-n", "2", "-w", "100", "x")
+	p := newPinger(pc.LocalAddr().String())
+	stdout, _ := captureOutput(t, p.RunNTP)
+	if n := strings.Count(stdout, "请求超时。"); n != 2 {
+		t.Errorf("超时 %d 次，应为 2:\n%s", n, stdout)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Reply 一次回显请求的应答
+type Reply struct {
+	RTT   time.Duration
+	TTL   int
+	Bytes int //回显的数据长度
+	Seq   int
+	Src   net.IP
+
+	pkt     []byte        //收到的报文(含IP头)，收到无效的报文时也有，下一次PingOnce前有效
+	start   time.Time     //发送时间
+	elapsed time.Duration //失败时从发送到返回的时间
+}
+
+// 发送失败，与等待应答超时区分
+type sendError struct{ err error }
+
+func (e *sendError) Error() string { return e.err.Error() }
+func (e *sendError) Unwrap() error { return e.err }
+
+// 连接的原始套接字收到目标发来的ICMP差错(如通信被管理性禁止)时，内核把它记为套接字错误，
+// 下一次读取先返回该错误，差错报文本身仍在接收队列中
+var icmpSocketErrnos = []syscall.Errno{
+	syscall.ENETUNREACH, syscall.EHOSTUNREACH, syscall.EHOSTDOWN,
+	syscall.ECONNREFUSED, syscall.ENOPROTOOPT, syscall.EPROTO,
+}
+
+// 读取错误是否由收到的ICMP差错引起，是则继续读取差错报文
+func isICMPSocketError(err error) bool {
+	for _, errno := range icmpSocketErrnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
+
+// 发送一次回显请求并在wait内等待应答，跳过目标为本机时收到的自己的请求
+// match时还跳过ID或序号与请求不同的回显应答(其他进程的请求、迟到的上一次应答)
+// -pcap 时发送的请求及读到的每个报文(包括跳过的)都写入pcap文件
+// 返回收到的报文长度、发送时间及往返时间；发送失败时返回的错误为 *sendError
+func exchange(conn net.Conn, tsc *tsConn, data []byte, wait time.Duration, buf []byte, match bool) (int, time.Time, time.Duration, error) {
+	conn.SetDeadline(time.Now().Add(wait))
+	tStart := time.Now()
+	if _, err := conn.Write(data); err != nil {
+		return 0, tStart, 0, &sendError{err}
+	}
+	writePcapSent(tStart, conn.LocalAddr(), conn.RemoteAddr(), data)
+
+	var n int
+	var rtt time.Duration
+	var err error
+	for {
+		if tsc != nil {
+			n, rtt, err = tsc.read(buf, tStart) //接收返回数据及时间戳
+		} else {
+			n, err = conn.Read(buf) //接收返回数据
+			rtt = time.Since(tStart)
+		}
+		if isICMPSocketError(err) {
+			continue
+		}
+		if err != nil {
+			return 0, tStart, rtt, err
+		}
+		writePcap(tStart.Add(rtt), buf[:n]) //跳过的报文同样写入
+		if !isEchoRequest(buf[:n]) && !(match && isOtherEchoReply(buf[:n], data)) {
+			break
+		}
+	}
+	return n, tStart, rtt, nil
+}
+
+// 是否为ID或序号与请求req不同的回显应答，无效的报文由调用方报告
+func isOtherEchoReply(pkt, req []byte) bool {
+	if checkIPv4Header(pkt) != nil {
+		return false
+	}
+	ihl := int(pkt[0]&0x0f) * 4
+	if len(pkt) < ihl+8 || pkt[ihl] != 0 {
+		return false
+	}
+	return string(pkt[ihl+4:ihl+8]) != string(req[4:8])
+}
+
+// 收到的报文不是有效的回显应答
+type replyError struct {
+	kind      string //replyInvalid / replyShort / replyICMPError / replyChecksum
+	typ, code byte   //kind为replyICMPError时的类型及代码
+	msg       string
+}
+
+func (e *replyError) Error() string { return e.msg }
+
+const (
+	replyInvalid   = "invalid"    //IP头无效
+	replyShort     = "short"      //不足以容纳ICMP头
+	replyICMPError = "icmp_error" //不是回显应答，如目标不可达
+	replyChecksum  = "checksum"   //ICMP检验和错误
+)
+
+// 校验并解析回显应答(含IP头)，报文无效时返回的错误为 *replyError
+func parseEchoReply(pkt []byte) (Reply, error) {
+	if err := checkIPv4Header(pkt); err != nil {
+		return Reply{}, &replyError{kind: replyInvalid, msg: fmt.Sprintf("收到无效的回复: %v", err)}
+	}
+	ihl := int(pkt[0]&0x0f) * 4
+	if len(pkt) < ihl+8 {
+		return Reply{}, &replyError{kind: replyShort, msg: fmt.Sprintf("收到过短的回复: %d 字节", len(pkt))}
+	}
+	if typ, code := pkt[ihl], pkt[ihl+1]; typ != 0 {
+		return Reply{}, &replyError{kind: replyICMPError, typ: typ, code: code, msg: fmt.Sprintf("收到的不是回显应答: 类型=%d 代码=%d", typ, code)}
+	}
+	//检验和覆盖整个ICMP报文(含检验和字段)，正确时结果为0
+	if sum, _ := checkSum(pkt[ihl:]); sum != 0 {
+		return Reply{}, &replyError{kind: replyChecksum, msg: "校验和错误"}
+	}
+	return Reply{
+		TTL:   int(pkt[8]),
+		Bytes: len(pkt) - ihl - 8,
+		Seq:   int(binary.BigEndian.Uint16(pkt[ihl+6 : ihl+8])),
+		Src:   net.IPv4(pkt[12], pkt[13], pkt[14], pkt[15]),
+	}, nil
+}
+
+// 在已建立的连接上完成一次回显：发送data，等待并校验应答，Run与PingOnce共用
+// strict时ID、序号不同的应答不跳过，与其他异常一起作为 *replyAnomaly 返回
+// 返回的报文(含IP头)指向buf，收到报文但无效时也返回，供调用方输出
+func echo(conn net.Conn, tsc *tsConn, data []byte, wait time.Duration, buf []byte, strict bool) (Reply, []byte, time.Time, error) {
+	n, tStart, rtt, err := exchange(conn, tsc, data, wait, buf, !strict)
+	if err != nil {
+		return Reply{RTT: rtt}, nil, tStart, err
+	}
+	pkt := buf[:n]
+	if strict && checkIPv4Header(pkt) == nil {
+		if a := verifyReply(pkt, addrIP4(conn.RemoteAddr()), data); a != nil {
+			return Reply{RTT: rtt}, pkt, tStart, a
+		}
+	}
+	r, err := parseEchoReply(pkt)
+	r.RTT = rtt
+	return r, pkt, tStart, err
+}
+
+// PingOnce 发送一次回显请求，阻塞到收到ID、序号一致的应答、超时或ctx被取消为止
+// 结果计入Stats，不输出任何信息；ctx被取消时返回ctx.Err()
+// 第一次调用时建立连接，之后的调用复用同一连接，不再重新解析目标，用完后调用Close
+func (p *Pinger) PingOnce(ctx context.Context) (Reply, error) {
+	if err := ctx.Err(); err != nil {
+		return Reply{}, err
+	}
+	wait := p.probeTimeout()
+	dl, byCtx := ctx.Deadline() //等待到ctx的截止时间为止
+	if byCtx = byCtx && time.Until(dl) < wait; byCtx {
+		wait = time.Until(dl)
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	if p.conn == nil {
+		p.Host = icmpHost(p.Arg)
+		conn, err := dialICMP(p.Host, wait)
+		if err != nil {
+			return Reply{}, err
+		}
+		p.conn = conn
+		p.Addr = conn.RemoteAddr().String()
+	}
+
+	//ctx被取消时立即结束等待，返回前等goroutine退出，以免它改动下一次请求的截止时间
+	if ctx.Done() != nil {
+		done, exited := make(chan struct{}), make(chan struct{})
+		defer func() {
+			close(done)
+			<-exited
+		}()
+		go func() {
+			defer close(exited)
+			select {
+			case <-ctx.Done():
+				p.conn.SetReadDeadline(time.Now())
+			case <-done:
+			}
+		}()
+	}
+
+	//请求报文复用，之后的请求只更新序号并增量调整检验和
+	seq := p.Stats.Snapshot().Sent
+	p.Stats.addSent()
+	if len(p.req) != 8+p.Size {
+		p.req = make([]byte, 8+p.Size)
+		if err := fillEcho(p.req, seq); err != nil {
+			p.req = nil
+			p.Stats.addFailure()
+			return Reply{}, err
+		}
+	} else {
+		refillEcho(p.req, seq)
+	}
+	if p.rbuf == nil {
+		p.rbuf = recvBufPool.Get().(*[]byte)
+	}
+
+	r, pkt, tStart, err := echo(p.conn, p.tsc, p.req, wait, *p.rbuf, p.strict)
+	r.pkt, r.start = pkt, tStart
+	if err == nil {
+		p.Stats.addSuccess(r.RTT.Milliseconds())
+		return r, nil
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() && byCtx {
+		<-ctx.Done() //读取的截止时间与ctx的相同，可能先于ctx到期
+	}
+	var sendErr *sendError
+	if errors.As(err, &sendErr) {
+		p.Stats.addSendError(err)
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		p.Stats.addTimeout()
+	}
+	p.Stats.addFailure()
+	failed := Reply{pkt: pkt, start: tStart, elapsed: r.RTT}
+	if ctx.Err() != nil {
+		return failed, ctx.Err()
+	}
+	return failed, err
+}
+
+// Close 关闭PingOnce建立的连接，之后再调用PingOnce时重新建立
+func (p *Pinger) Close() error {
+	if p.rbuf != nil {
+		recvBufPool.Put(p.rbuf)
+		p.rbuf = nil
+	}
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn, p.tsc = nil, nil
+	return err
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 按读取截止时间阻塞的连接：回复中的ID被改为id(0表示不改)，silent时不回复，silentFor为不回复的前几次请求
+// SetReadDeadline可以在另一个goroutine中调用，使阻塞的Read立即返回
+type deadlineConn struct {
+	*mockConn
+	id        uint16
+	silent    bool
+	silentFor int
+	writes    int
+	pending   bool
+
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+func (c *deadlineConn) Write(b []byte) (int, error) {
+	c.mockConn.Write(b)
+	c.writes++
+	if icmp := c.reply[20:]; c.id != 0 {
+		old := binary.BigEndian.Uint16(icmp[4:6])
+		binary.BigEndian.PutUint16(icmp[2:4], adjustCheckSum(binary.BigEndian.Uint16(icmp[2:4]), old, c.id))
+		binary.BigEndian.PutUint16(icmp[4:6], c.id)
+	}
+	c.pending = !c.silent && c.writes > c.silentFor
+	return len(b), nil
+}
+
+func (c *deadlineConn) Read(b []byte) (int, error) {
+	if c.pending {
+		c.pending = false
+		return copy(b, c.reply), nil
+	}
+	for {
+		c.mu.Lock()
+		deadline := c.deadline
+		c.mu.Unlock()
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (c *deadlineConn) SetDeadline(t time.Time) error { return c.SetReadDeadline(t) }
+
+func (c *deadlineConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.deadline = t
+	c.mu.Unlock()
+	return nil
+}
+
+// match时跳过ID与请求不同的回显应答直到超时，否则原样返回；strict时echo作为异常返回
+func TestExchangeOtherReply(t *testing.T) {
+	data := make([]byte, 8+8)
+	if err := fillEcho(data, 0); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1500)
+	if _, _, _, err := exchange(&deadlineConn{mockConn: newMockConn(), id: echoID + 1}, nil, data, 30*time.Millisecond, buf, true); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("match时 err = %v，期望超时", err)
+	}
+	n, _, _, err := exchange(&deadlineConn{mockConn: newMockConn(), id: echoID + 1}, nil, data, 30*time.Millisecond, buf, false)
+	if err != nil || binary.BigEndian.Uint16(buf[24:26]) != echoID+1 {
+		t.Errorf("不match时 = % x, %v", buf[:n], err)
+	}
+	n, _, _, err = exchange(&deadlineConn{mockConn: newMockConn()}, nil, data, 30*time.Millisecond, buf, true)
+	if r, perr := parseEchoReply(buf[:n]); err != nil || perr != nil || r.Seq != 0 || r.Bytes != 8 {
+		t.Errorf("ID相同的应答 = %+v, %v, %v", r, err, perr)
+	}
+
+	_, pkt, _, err := echo(&deadlineConn{mockConn: newMockConn(), id: echoID + 1}, nil, data, 30*time.Millisecond, buf, true)
+	var a *replyAnomaly
+	if !errors.As(err, &a) || a.kind != anomalyID || pkt == nil {
+		t.Errorf("strict时 err = %v，报文 % x", err, pkt)
+	}
+	if _, pkt, _, err := echo(&deadlineConn{mockConn: newMockConn(), silent: true}, nil, data, 30*time.Millisecond, buf, false); pkt != nil || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("超时时 err = %v，报文 % x", err, pkt)
+	}
+}
+
+func TestIsOtherEchoReply(t *testing.T) {
+	req, err := buildEcho(5, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := newMockConn()
+	conn.Write(req)
+	same := append([]byte(nil), conn.reply...)
+	otherSeq := append([]byte(nil), same...)
+	otherSeq[27]++
+	otherID := append([]byte(nil), same...)
+	otherID[24]++
+	unreach := append([]byte(nil), otherID...)
+	unreach[20] = 3
+	tests := []struct {
+		name string
+		pkt  []byte
+		want bool
+	}{
+		{"ID、序号相同", same, false},
+		{"序号不同", otherSeq, true},
+		{"ID不同", otherID, true},
+		{"不是回显应答", unreach, false}, //由parseEchoReply报告
+		{"过短", same[:24], false},
+		{"IP头无效", append([]byte{0x60}, same[1:]...), false},
+	}
+	for _, tt := range tests {
+		if got := isOtherEchoReply(tt.pkt, req); got != tt.want {
+			t.Errorf("%s: isOtherEchoReply = %v，期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 无效的报文按原因返回 *replyError，Run据此分别输出
+func TestParseEchoReplyErrors(t *testing.T) {
+	req, err := buildEcho(1, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := newMockConn()
+	conn.Write(req)
+	reply := append([]byte(nil), conn.reply...)
+	unreach := append([]byte(nil), reply...)
+	unreach[20], unreach[21] = 3, 1
+	bad := append([]byte(nil), reply...)
+	bad[len(bad)-1] ^= 0xff
+	tests := []struct {
+		name string
+		pkt  []byte
+		kind string
+		msg  string
+	}{
+		{"IP头无效", reply[:10], replyInvalid, "收到无效的回复: 报文只有 10 字节"},
+		{"过短", reply[:24], replyShort, "收到过短的回复: 24 字节"},
+		{"目标不可达", unreach, replyICMPError, "收到的不是回显应答: 类型=3 代码=1"},
+		{"检验和错误", bad, replyChecksum, "校验和错误"},
+	}
+	for _, tt := range tests {
+		_, err := parseEchoReply(tt.pkt)
+		var re *replyError
+		if !errors.As(err, &re) || re.kind != tt.kind || !strings.HasPrefix(re.Error(), tt.msg) {
+			t.Errorf("%s: err = %v，期望 %s %q", tt.name, err, tt.kind, tt.msg)
+		}
+	}
+	if _, err := parseEchoReply(unreach); err.(*replyError).typ != 3 || err.(*replyError).code != 1 {
+		t.Errorf("目标不可达的类型、代码 = %+v", err)
+	}
+}
+
+func TestPingOnce(t *testing.T) {
+	needRawSocket(t)
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name    string
+		target  string
+		ctx     func() (context.Context, context.CancelFunc)
+		err     error //nil表示收到应答
+		sent    int
+		maxWait time.Duration //返回前最多等待的时间
+	}{
+		{"收到应答", "127.0.0.1", nil, nil, 1, time.Second},
+		{"超时", "203.0.113.1", nil, os.ErrDeadlineExceeded, 1, time.Second},
+		{"等待时取消", "203.0.113.1", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(20*time.Millisecond, cancel)
+			return ctx, cancel
+		}, context.Canceled, 1, 150 * time.Millisecond},
+		{"ctx的截止时间早于超时", "203.0.113.1", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 20*time.Millisecond)
+		}, context.DeadlineExceeded, 1, 150 * time.Millisecond},
+		{"已取消", "127.0.0.1", func() (context.Context, context.CancelFunc) {
+			return canceled, func() {}
+		}, context.Canceled, 0, 10 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseArgs(t, "-w", "200", "-l", "8", tt.target)
+			ctx, cancel := context.Background(), context.CancelFunc(func() {})
+			if tt.ctx != nil {
+				ctx, cancel = tt.ctx()
+			}
+			defer cancel()
+			p := newPinger(tt.target)
+			start := time.Now()
+			r, err := p.PingOnce(ctx)
+			if elapsed := time.Since(start); elapsed > tt.maxWait {
+				t.Errorf("用时 %s，超过 %s", elapsed, tt.maxWait)
+			}
+			if tt.err == nil && err != nil || tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v，期望 %v", err, tt.err)
+			}
+			ss := p.Stats.Snapshot()
+			if tt.err == nil {
+				if r.Seq != 0 || r.Bytes != 8 || r.TTL == 0 || !r.Src.Equal(net.IPv4(127, 0, 0, 1)) || ss.Received != 1 {
+					t.Errorf("应答 = %+v，收到 %d", r, ss.Received)
+				}
+				return
+			}
+			if r.Src != nil || r.RTT != 0 || ss.Received != 0 || ss.Sent != tt.sent {
+				t.Errorf("失败时应答 = %+v，统计 %+v", r, ss)
+			}
+		})
+	}
+}
+
+// 多次调用复用同一连接，序号依次递增；Close后再调用时重新建立连接
+func TestPingOnceReusesConn(t *testing.T) {
+	parseArgs(t, "-w", "200", "-l", "8", "127.0.0.1")
+	first := &deadlineConn{mockConn: newMockConn()}
+	predial(t, "127.0.0.1", first)
+	p := newPinger("127.0.0.1")
+	for i := 0; i < 3; i++ {
+		r, err := p.PingOnce(context.Background())
+		if err != nil || r.Seq != i {
+			t.Fatalf("第 %d 次: 序号 %d, %v", i+1, r.Seq, err)
+		}
+	}
+	if first.writes != 3 {
+		t.Errorf("在第一个连接上发送了 %d 次，期望3次", first.writes)
+	}
+	p.Close()
+
+	second := &deadlineConn{mockConn: newMockConn()}
+	predial(t, "127.0.0.1", second)
+	if r, err := p.PingOnce(context.Background()); err != nil || r.Seq != 3 || second.writes != 1 {
+		t.Errorf("Close后: 序号 %d, %v，新连接发送了 %d 次", r.Seq, err, second.writes)
+	}
+	p.Close()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+var oneShot bool //-1 只发送一次请求，输出一行 "up 12.4ms" 或 "down"
+
+const oneShotTimeout = 500 //-1 时默认的超时时间(毫秒)，可以用 -w 覆盖
+
+// -1 的默认值：超时时间缩短为oneShotTimeout，数据长度为0(只有8字节的ICMP头)
+// 命令行中显式指定的 -w、-l 不变
+func applyOneShotDefaults() {
+	if !explicit["timeout"] {
+		timeout = oneShotTimeout
+	}
+	if !explicit["size"] {
+		size = 0
+	}
+}
+
+// 发送一次请求，只向标准输出写一行：收到回复为 "up 12.4ms"，否则为 "down"
+// 返回退出码：收到回复为0，否则为1；超时以外的原因输出到标准错误
+func runOneShot(host string) int {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := stop //测试中会替换stop，goroutine只等待调用时的通道
+	go func() {
+		select {
+		case <-s:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	p := newPinger(host)
+	defer p.Close()
+	r, err := p.PingOnce(ctx)
+	if err == nil {
+		fmt.Printf("up %.1fms\n", float64(r.RTT)/float64(time.Millisecond))
+		return 0
+	}
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", host, err)
+	}
+	fmt.Println("down")
+	return 1
+}
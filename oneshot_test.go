@@ -0,0 +1,104 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+// -1 缩短默认的超时时间并且不带数据，显式指定的 -w、-l 不变
+func TestOneShotDefaults(t *testing.T) {
+	tests := []struct {
+		args    []string
+		timeout int64
+		size    int
+	}{
+		{[]string{"127.0.0.1"}, 1000, 32},
+		{[]string{"-1", "127.0.0.1"}, oneShotTimeout, 0},
+		{[]string{"-1", "-w", "200", "127.0.0.1"}, 200, 0},
+		{[]string{"-1", "-l", "16", "127.0.0.1"}, oneShotTimeout, 16},
+		{[]string{"-1", "-l", "32", "-w", "1000", "127.0.0.1"}, 1000, 32},
+	}
+	for _, tt := range tests {
+		parseArgs(t, tt.args...)
+		if timeout != tt.timeout || size != tt.size {
+			t.Errorf("%v: 超时 %d 大小 %d，期望 %d、%d", tt.args, timeout, size, tt.timeout, tt.size)
+		}
+	}
+}
+
+func TestOneShotConflicts(t *testing.T) {
+	for _, args := range [][]string{
+		{"-1", "-t", "127.0.0.1"},
+		{"-1", "-n", "2", "127.0.0.1"},
+		{"-1", "-schedule", "* * * * *", "127.0.0.1"},
+		{"-1", "-cycle-period", "1m", "127.0.0.1"},
+	} {
+		if errs := argErrors(t, args...); !hasArgError(errs, "-1") {
+			t.Errorf("%v 没有报错: %q", args, errs)
+		}
+	}
+}
+
+// 记录每个请求长度的连接
+type sizeConn struct {
+	*mockConn
+	sizes []int
+}
+
+func (c *sizeConn) Write(b []byte) (int, error) {
+	c.sizes = append(c.sizes, len(b))
+	return c.mockConn.Write(b)
+}
+
+// 标准输出只有一行，收到回复时退出码为0，否则为1；请求只有8字节的ICMP头
+func TestOneShotOutput(t *testing.T) {
+	up := regexp.MustCompile(`^up \d+\.\dms\n$`)
+	parseArgs(t, "-1", "127.0.0.1")
+	conn := &sizeConn{mockConn: newMockConn()}
+	predial(t, "127.0.0.1", conn)
+	var code int
+	stdout, stderr := captureOutput(t, func() { code = runOneShot("127.0.0.1") })
+	if code != 0 || !up.MatchString(stdout) || stderr != "" {
+		t.Errorf("收到回复: 退出码 %d，标准输出 %q，标准错误 %q", code, stdout, stderr)
+	}
+	if len(conn.sizes) != 1 || conn.sizes[0] != 8 {
+		t.Errorf("请求长度 = %v，期望只有一个8字节的请求", conn.sizes)
+	}
+
+	parseArgs(t, "-1", "-w", "50", "127.0.0.1")
+	predial(t, "127.0.0.1", &dropConn{&seqConn{mockConn: newMockConn()}})
+	stdout, stderr = captureOutput(t, func() { code = runOneShot("127.0.0.1") })
+	if code != 1 || stdout != "down\n" || stderr != "" {
+		t.Errorf("超时: 退出码 %d，标准输出 %q，标准错误 %q", code, stdout, stderr)
+	}
+}
+
+// 回环地址为up；不可路由的地址在默认的超时时间内为down
+func TestOneShotLive(t *testing.T) {
+	needRawSocket(t)
+	up := regexp.MustCompile(`^up \d+\.\dms\n$`)
+	tests := []struct {
+		host string
+		code int
+	}{
+		{"127.0.0.1", 0},
+		{"240.0.0.1", 1}, //保留地址，不会有回复
+	}
+	for _, tt := range tests {
+		parseArgs(t, "-1", tt.host)
+		var code int
+		start := time.Now()
+		stdout, _ := captureOutput(t, func() { code = runOneShot(tt.host) })
+		elapsed := time.Since(start)
+		if code != tt.code {
+			t.Errorf("%s: 退出码 %d，期望 %d", tt.host, code, tt.code)
+		}
+		if tt.code == 0 && !up.MatchString(stdout) || tt.code == 1 && stdout != "down\n" {
+			t.Errorf("%s: 标准输出 %q", tt.host, stdout)
+		}
+		if elapsed > 2*oneShotTimeout*time.Millisecond {
+			t.Errorf("%s: 用时 %v，超过默认的超时时间", tt.host, elapsed)
+		}
+	}
+}
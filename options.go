@@ -0,0 +1,801 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// IPv4下ICMP回显请求的最大数据长度：65535 - 20(IP头) - 8(ICMP头)
+const maxPayload = 65507
+
+var (
+	explicit   = map[string]bool{} //命令行中显式指定的参数(按规范名)
+	positional []string            //非参数项，即目标
+)
+
+// 初始化命令行参数，有错误时一次性输出全部错误后退出
+func getArgs() {
+	if errs := loadArgs(); len(errs) > 0 {
+		for _, e := range errs {
+			fmt.Fprintln(os.Stderr, e)
+		}
+		os.Exit(2)
+	}
+}
+
+// 注册并解析命令行参数，返回所有错误
+// 同一个参数同时兼容Windows风格(-n -l -w)、Linux风格(-c -s -W -i)以及长参数名(--count 等)，
+// 各别名解析后换算为统一单位，同一参数的多个别名不能同时指定
+func loadArgs() []string {
+	var (
+		wTimeout, longTimeout   int64   //毫秒
+		sTimeout                float64 //秒
+		nCount, cCount, lCount  int
+		lSize, sSize, longSize  int
+		iInterval, longInterval float64 //秒
+	)
+
+	//超时时间：-w 为毫秒(Windows)，-W 为秒(Linux)，--timeout 为毫秒
+	flag.Int64Var(&wTimeout, "w", 1000, "等待每次回复的超时时间(毫秒)")
+	flag.Float64Var(&sTimeout, "W", 1, "等待每次回复的超时时间(秒)")
+	flag.Int64Var(&longTimeout, "timeout", 1000, "等待每次回复的超时时间(毫秒)")
+	flag.BoolVar(&adaptiveTimeout, "adaptive-timeout", false, "根据观测到的往返时间调整每次请求的超时时间")
+	flag.Int64Var(&adaptiveFloor, "adaptive-floor", 20, "自适应超时时间的下限(毫秒)")
+	flag.Float64Var(&adaptiveK, "adaptive-k", 2, "自适应超时时间中平滑往返时间的倍数")
+	//请求次数
+	flag.IntVar(&nCount, "n", 4, "要发送的回显请求数")
+	flag.IntVar(&cCount, "c", 4, "要发送的回显请求数")
+	flag.IntVar(&lCount, "count", 4, "要发送的回显请求数")
+	//缓冲区大小
+	lSize, sSize, longSize = 32, 32, 32
+	flag.Var(sizeValue{&lSize}, "l", "发送缓冲区大小，auto 表示根据出口接口MTU计算")
+	flag.Var(sizeValue{&sSize}, "s", "发送缓冲区大小，auto 表示根据出口接口MTU计算")
+	flag.Var(sizeValue{&longSize}, "size", "发送缓冲区大小，auto 表示根据出口接口MTU计算")
+	flag.BoolVar(&sizeAuto, "size-from-mtu", false, "根据出口接口MTU计算发送缓冲区大小")
+	//请求间隔：与Linux ping一致，单位为秒
+	flag.Float64Var(&iInterval, "i", 0, "两次请求的间隔(秒)")
+	flag.Float64Var(&longInterval, "interval", 0, "两次请求的间隔(秒)")
+
+	flag.BoolVar(&forever, "t", false, "持续ping直到按下Ctrl+C，结束时输出可用性统计")
+	flag.DurationVar(&cycleActive, "cycle-active", 0, "每个周期中探测的时长，如 30s")
+	flag.DurationVar(&cyclePeriod, "cycle-period", 0, "周期长度，如 10m，每个周期开始时探测 -cycle-active 时长")
+	flag.BoolVar(&useBackoff, "backoff", false, "连续失败时按倍数延长请求间隔，成功后恢复")
+	flag.Float64Var(&backoffMax, "backoff-max", 60, "退避后的最大请求间隔(秒)")
+	flag.BoolVar(&verbose, "v", false, "输出详细信息")
+	fwMark = 0
+	flag.Var(markValue{&fwMark}, "mark", "发送报文的防火墙标记(SO_MARK)，用于测试策略路由(仅Linux)")
+	flag.StringVar(&ecnMode, "ecn", "", "在发送报文中设置ECN标记(ect0或ect1)，统计回复中的ECN标记")
+	flag.IntVar(&priority, "priority", -1, "套接字优先级(SO_PRIORITY)，决定进入qdisc的哪个频段(仅Linux)")
+	flag.IntVar(&rcvBuf, "rcvbuf", 0, "原始套接字的接收缓冲区大小(字节)，默认使用系统设置")
+	flag.IntVar(&sendTTL, "ttl", 0, "发送报文的TTL(1-255)，默认使用系统设置")
+	flag.BoolVar(&showHops, "hops", false, "在回复的TTL后显示按常见初始TTL估计的跳数")
+	flag.BoolVar(&veryVerbose, "vv", false, "在每条回复后输出报文的十六进制内容及解码后的各字段")
+	flag.IntVar(&dumpMax, "dump-max", 256, "-vv 时每条回复最多输出的字节数")
+	flag.BoolVar(&asymDetect, "asym-detect", false, "根据回复TTL的波动检测非对称路由")
+	flag.DurationVar(&waitFor, "wait-for", 0, "每隔 -i 秒ping一次，直到目标有回复，最多等待该时长，如 5m")
+	flag.BoolVar(&fastest, "fastest", false, "先向主机名的每个IPv4地址各发送一次请求，之后只ping最快的地址")
+	flag.BoolVar(&multiDNS, "multi-dns", false, "分别ping主机名解析出的每个IPv4地址，输出各地址及合计")
+	flag.StringVar(&outputFormat, "format", "", "输出格式，table 表示多目标汇总表格")
+	flag.StringVar(&sortBy, "sort", "", "表格排序方式：loss、avg、name")
+	flag.BoolVar(&unreachableOnly, "unreachable-only", false, "表格中只显示无法访问的目标")
+	flag.StringVar(&targetFile, "f", "", "从文件读取目标列表，每行一个")
+	flag.StringVar(&viaGateway, "via", "", "为目标临时添加经由该网关的主机路由，结束时删除(仅Linux)")
+	flag.StringVar(&dropPrivs, "drop-privs", "", "创建原始套接字后切换到该用户(user[:group])再开始探测(仅Unix)")
+	flag.StringVar(&debugListen, "debug-listen", "", "在该地址(如 :6060)提供 /debug/vars 及 /debug/pprof/")
+	flag.BoolVar(&shuffle, "shuffle", false, "以随机顺序探测各目标")
+	flag.Int64Var(&shuffleSeed, "seed", 0, "-shuffle 及 -interval-jitter 的随机种子，相同的种子得到相同的顺序")
+	flag.Float64Var(&intervalJitter, "interval-jitter", 0, "每次请求的间隔在 ±该百分比范围内随机浮动")
+	flag.BoolVar(&oneShot, "1", false, "只发送一次请求，输出一行 up 12.4ms 或 down，退出码为0或1")
+	flag.BoolVar(&aliveOnly, "alive", false, "只输出有回复的地址，每行一个")
+	flag.BoolVar(&unreachOnly, "unreach", false, "只输出没有回复的地址，每行一个")
+	flag.StringVar(&scheduleExpr, "schedule", "", "按cron表达式(分 时 日 月 星期)定时执行，如 \"0 * * * *\" 表示每小时整点")
+	flag.StringVar(&schedulePath, "o", "", "-schedule 每次执行后把统计摘要追加到该文件(JSONL)")
+	flag.StringVar(&saveBaselinePath, "save-baseline", "", "把本次的统计结果保存为基线(JSON)")
+	flag.StringVar(&saveBaselinePath, "baseline-save", "", "同 -save-baseline")
+	flag.DurationVar(&slaRTT, "sla-rtt", 0, "往返时间的SLA阈值，如 50ms，结束时输出不超过该阈值的请求所占的百分比")
+	flag.Float64Var(&slaTarget, "sla-target", 99, "-sla-rtt 要求的达标百分比，低于该值时退出码为5")
+	flag.BoolVar(&trimOutliers, "trim-outliers", false, "同时输出去除离群值后的最短、平均、最长耗时")
+	flag.Float64Var(&trimPct, "trim-pct", 5, "-trim-outliers 时两端各去除的百分比")
+	flag.StringVar(&trimMethod, "trim-method", "pct", "去除离群值的方法：pct(两端按百分比)或iqr(四分位距)")
+	flag.BoolVar(&baselineTrimmed, "baseline-trimmed", false, "基线的保存与比较使用去除离群值后的耗时")
+	flag.StringVar(&compareBaselinePath, "compare-baseline", "", "结束后与该基线比较，变差超过允许范围时退出码为6")
+	flag.StringVar(&compareBaselinePath, "baseline-load", "", "同 -compare-baseline")
+	flag.Float64Var(&baselineTolerance, "baseline-tolerance", 20, "与基线比较时耗时指标允许的增幅(百分比)")
+	flag.Int64Var(&allowAvgIncrease, "allow-avg-increase-ms", 10, "与基线比较时允许的平均耗时增加(毫秒)")
+	flag.Float64Var(&allowLossIncrease, "allow-loss-increase-pct", 1, "与基线比较时允许的丢失率增加(百分点)")
+	flag.StringVar(&recordPath, "record", "", "把每次探测的结果写入文件(JSONL，扩展名为.csv时为CSV)")
+	flag.StringVar(&replayPath, "replay", "", "回放 -record 记录的文件，重新统计而不发送报文")
+	flag.StringVar(&replaySince, "since", "", "回放时只统计该时间之后的记录")
+	flag.StringVar(&replayUntil, "until", "", "回放时只统计该时间之前的记录")
+	flag.StringVar(&heatmapPath, "db-heatmap", "", "按星期及小时汇总 -record 记录的文件，输出往返时间中位数及丢包率的热力图")
+	flag.StringVar(&heatmapTZ, "tz", "Local", "-db-heatmap 划分小时及星期使用的时区，如 UTC、Asia/Shanghai")
+	flag.BoolVar(&printSchema, "print-schema", false, "输出 -record、基线文件及 -schedule 摘要当前版本的JSON Schema后退出")
+	flag.StringVar(&statePath, "state", "", "持久化监控状态的JSON文件，启动时读取并接着上次继续")
+	flag.BoolVar(&noDrain, "no-drain", false, "按下Ctrl+C时立即结束，不等待正在进行的请求")
+	flag.BoolVar(&noSharedSocket, "no-shared-socket", false, "表格及 -alive/-unreach 时每个目标单独建立原始套接字")
+	flag.BoolVar(&strictMode, "strict", false, "严格校验回复，存在任何协议异常时视为失败")
+	flag.Float64Var(&chaosLoss, "chaos-loss", 0, "按该百分比随机丢弃发出的请求，模拟发送端丢包")
+	flag.StringVar(&pcapPath, "pcap", "", "把发送的回显请求及收到的ICMP报文写入pcap文件")
+	flag.StringVar(&reportPath, "report", "", "结束后生成报告(Markdown，扩展名为.html时为HTML)")
+	flag.StringVar(&configPath, "config", "", "配置文件路径，配置多个目标及默认参数")
+	flag.BoolVar(&pmtud, "pmtud", false, "以二分法探测路径MTU")
+	flag.StringVar(&sourceRouteArg, "j", "", "松散源路由，逗号分隔的中间地址(最多9个)")
+	flag.BoolVar(&useEBPF, "ebpf", false, "以XDP程序在网卡接收处取出本进程的回显应答，经perf环形缓冲区交给程序(仅Linux)")
+	flag.BoolVar(&useIOUring, "iouring", false, "使用io_uring收发报文(仅Linux 5.7+)")
+	flag.BoolVar(&hwTS, "hw-ts", false, "使用SO_TIMESTAMPING时间戳计算往返时间，网卡不支持时使用软件时间戳(仅Linux)")
+	flag.BoolVar(&bfdEcho, "bfd", false, "发送BFD回显报文(UDP 3785)，验证对端的BFD回显环回")
+	flag.StringVar(&mplsPrefix, "mpls-lsp", "", "以MPLS LSP ping验证到该IPv4前缀(FEC)的LSP，目标为直连的下一跳(仅Linux)")
+	flag.StringVar(&probeIface, "probe", "", "以RFC 8335 扩展回显(PROBE)查询目标上该接口(名称、索引或地址)的状态")
+	flag.BoolVar(&showMAC, "mac", false, "在回复中显示直连回复者的MAC地址及厂商，MAC变化时给出警告(仅Linux)")
+	flag.BoolVar(&addrMask, "addrmask", false, "发送ICMP地址掩码请求(类型17)，输出目标应答的子网掩码")
+	flag.BoolVar(&addrMask, "mask", false, "同 -addrmask")
+	flag.BoolVar(&broadcast, "broadcast", false, "向广播地址发送回显请求，输出每台应答的主机")
+	flag.BoolVar(&multicast, "multicast", false, "加入组播组(IPv4或IPv6)并向其发送回显请求，输出每台应答的组成员")
+	flag.BoolVar(&timestampMsg, "timestamp-msg", false, "发送ICMP时间戳请求(类型13)，把往返时间拆分为去程、处理及回程")
+	flag.BoolVar(&tsOffset, "offset", false, "-timestamp-msg 时估计对端时钟偏差(中位数)及路径不对称")
+	flag.StringVar(&mplsLabelArg, "mpls-label", "", "LSP ping使用的标签栈，逗号分隔，外层在前")
+	flag.StringVar(&dnsServer, "dns", "", "以DNS查询(UDP，默认端口53)的往返时间代替ICMP，测量该DNS服务器")
+	flag.StringVar(&dnsQuery, "dns-query", dnsDefaultQry, "-dns 查询A记录的域名")
+	flag.StringVar(&ntpServer, "ntp", "", "以NTP请求(UDP，默认端口123)的往返时间代替ICMP，测量该NTP服务器")
+	flag.StringVar(&stunServer, "stun", "", "以STUN Binding请求(UDP，默认端口3478)的往返时间代替ICMP，并输出外部地址")
+	flag.StringVar(&quicAddr, "quic", "", "以HTTP/3请求(QUIC，默认端口443)代替ICMP，测量首个应答、握手及首字节的耗时")
+	flag.StringVar(&httpURL, "http", "", "以HTTP HEAD请求代替ICMP，分别测量DNS、TCP、TLS及HTTP响应的耗时")
+	flag.StringVar(&dualEnded, "dual-ended", "", "向代理(host:port，另一端以 -agent 运行)所在主机发送请求，结束时区分去程与回程丢失")
+	flag.StringVar(&agentAddr, "agent", "", "作为 -dual-ended 的代理监听该地址(如 :7878)，报告收到的回显请求")
+	flag.StringVar(&twampAddr, "twamp", "", "以TWAMP-Light向反射器(host[:port]，默认端口862)发送UDP测试报文")
+	flag.StringVar(&netnsPath, "netns", "", "在指定的网络命名空间中发送请求(仅Linux)")
+	flag.BoolVar(&otelEnabled, "otel", false, "以OpenTelemetry span导出每次探测(OTEL_EXPORTER_OTLP_ENDPOINT)")
+	flag.StringVar(&influxAddr, "influx-addr", "", "结束后以InfluxDB行协议通过UDP发送统计，如 localhost:8089")
+	flag.BoolVar(&influxPerPacket, "influx-per-packet", false, "-influx-addr 时同时发送每次探测的结果")
+	flag.StringVar(&graphiteAddr, "graphite", "", "结束后以Graphite明文协议通过TCP发送统计，如 localhost:2003")
+	flag.StringVar(&cloudWatchNamespace, "cloudwatch", "", "结束后把统计作为该命名空间下的CloudWatch指标发送(需以 -tags cloudwatch 编译)")
+	flag.Usage = usage
+	positional = parseInterspersed(os.Args[1:])
+
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	//环境变量提供的默认值，优先级低于命令行参数
+	var errs []string
+	envDefault := func(key string, def int64) int64 {
+		v, ok := os.LookupEnv(key)
+		if !ok || v == "" {
+			return def
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			errs = append(errs, fmt.Sprintf("环境变量 %s 的取值 %q 无效", key, v))
+			return def
+		}
+		return n
+	}
+
+	resolve := func(name string, target *int64, def int64, values map[string]int64) {
+		var used []string
+		for _, alias := range sortedAliases(values) {
+			if set[alias] {
+				used = append(used, "-"+alias)
+				*target = values[alias]
+			}
+		}
+		switch len(used) {
+		case 0:
+			*target = def
+		case 1:
+			explicit[name] = true
+		default:
+			errs = append(errs, fmt.Sprintf("参数 %s 不能同时指定", strings.Join(used, " 与 ")))
+		}
+	}
+
+	resolve("timeout", &timeout, envDefault("PING_TIMEOUT", 1000), map[string]int64{
+		"w":       wTimeout,
+		"W":       int64(sTimeout * 1000), //秒换算为毫秒
+		"timeout": longTimeout,
+	})
+	var n, l int64
+	resolve("count", &n, envDefault("PING_COUNT", 4), map[string]int64{"n": int64(nCount), "c": int64(cCount), "count": int64(lCount)})
+	resolve("size", &l, envDefault("PING_SIZE", 32), map[string]int64{"l": int64(lSize), "s": int64(sSize), "size": int64(longSize)})
+	count, size = int(n), int(l)
+	if (aliveOnly || unreachOnly) && !explicit["count"] {
+		count = 1 //扫描时默认每个地址只发送一次请求，与fping一致
+	}
+	resolve("interval", &interval, 0, map[string]int64{
+		"i":        int64(iInterval * 1000), //秒换算为毫秒
+		"interval": int64(longInterval * 1000),
+	})
+
+	if sourceRouteArg != "" {
+		hops, err := parseSourceRoute(sourceRouteArg)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		sourceRoute = hops
+	}
+
+	if veryVerbose {
+		verbose = true
+	}
+	if cyclePeriod > 0 && !explicit["count"] {
+		forever = true //周期探测默认持续到按下Ctrl+C
+	}
+	if oneShot {
+		applyOneShotDefaults()
+	}
+	return append(errs, validateArgs()...)
+}
+
+// 校验各参数的取值，返回所有错误，由调用方一次性输出
+func validateArgs() []string {
+	var errs []string
+	if !sizeAuto && (size < 0 || size > maxPayload) {
+		errs = append(errs, fmt.Sprintf("-l: 取值 %d 超出范围 0-%d", size, maxPayload))
+	}
+	if timeout <= 0 {
+		errs = append(errs, fmt.Sprintf("-w: 超时时间必须大于0，实际为 %d", timeout))
+	}
+	if adaptiveFloor <= 0 {
+		errs = append(errs, fmt.Sprintf("-adaptive-floor: 无效的取值 %d", adaptiveFloor))
+	}
+	if adaptiveK <= 0 {
+		errs = append(errs, fmt.Sprintf("-adaptive-k: 无效的取值 %v", adaptiveK))
+	}
+	if count <= 0 && !forever {
+		errs = append(errs, fmt.Sprintf("-n: 请求次数必须大于0，实际为 %d", count))
+	}
+	switch outputFormat {
+	case "", "table":
+	default:
+		errs = append(errs, fmt.Sprintf("-format: 不支持的输出格式 %q", outputFormat))
+	}
+	if fwMark != 0 && !markSupported {
+		errs = append(errs, "-mark: 防火墙标记(SO_MARK)仅Linux支持")
+	}
+	if priority < -1 {
+		errs = append(errs, fmt.Sprintf("-priority: 取值 %d 无效", priority))
+	} else if priority >= 0 && !markSupported {
+		errs = append(errs, "-priority: 套接字优先级(SO_PRIORITY)仅Linux支持")
+	}
+	if ecnMode != "" {
+		if _, err := parseECN(ecnMode); err != nil {
+			errs = append(errs, fmt.Sprintf("-ecn: %v", err))
+		}
+	}
+	if (cycleActive > 0) != (cyclePeriod > 0) {
+		errs = append(errs, "参数 -cycle-active 与 -cycle-period 必须同时指定")
+	} else if cyclePeriod > 0 && cycleActive >= cyclePeriod {
+		errs = append(errs, fmt.Sprintf("-cycle-active %v 必须小于 -cycle-period %v", cycleActive, cyclePeriod))
+	}
+	if cycleActive < 0 || cyclePeriod < 0 {
+		errs = append(errs, "-cycle-active、-cycle-period 不能为负数")
+	}
+	if trimPct < 0 || trimPct >= 50 {
+		errs = append(errs, fmt.Sprintf("-trim-pct: 取值 %v 超出范围 0-50", trimPct))
+	}
+	switch trimMethod {
+	case "pct", "iqr":
+	default:
+		errs = append(errs, fmt.Sprintf("-trim-method: 不支持的方法 %q", trimMethod))
+	}
+	if rcvBuf < 0 {
+		errs = append(errs, fmt.Sprintf("-rcvbuf: 取值 %d 无效", rcvBuf))
+	}
+	if sendTTL < 0 || sendTTL > 255 {
+		errs = append(errs, fmt.Sprintf("-ttl: 取值 %d 超出范围 1-255", sendTTL))
+	}
+	if chaosLoss < 0 || chaosLoss > 100 {
+		errs = append(errs, fmt.Sprintf("-chaos-loss: 取值 %v 超出范围 0-100", chaosLoss))
+	}
+	if dumpMax <= 0 {
+		errs = append(errs, fmt.Sprintf("-dump-max: 无效的取值 %d", dumpMax))
+	}
+	if hwTS && useIOUring {
+		errs = append(errs, "参数 -hw-ts 与 -iouring 不能同时指定")
+	}
+	if useEBPF && strictMode {
+		errs = append(errs, "参数 -ebpf 与 -strict 不能同时指定，XDP程序只交付ID匹配的应答，其他应答无法作为异常报告")
+	}
+	if useEBPF && (useIOUring || hwTS) {
+		errs = append(errs, "参数 -ebpf 不能与 -iouring、-hw-ts 同时指定，应答经perf环形缓冲区交给程序，不经socket")
+	}
+	if backoffMax <= 0 {
+		errs = append(errs, fmt.Sprintf("-backoff-max: 无效的取值 %v", backoffMax))
+	}
+	if mplsPrefix != "" {
+		if _, err := parseLSPPrefix(mplsPrefix); err != nil {
+			errs = append(errs, err.Error())
+		}
+		if mplsLabelArg == "" {
+			errs = append(errs, "-mpls-lsp 需要以 -mpls-label 指定下一跳为该FEC分配的标签")
+		} else if _, err := parseLabelStack(mplsLabelArg); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if _, err := buildDNSQuery(0, dnsQuery); err != nil {
+		errs = append(errs, "-dns-query: "+err.Error())
+	}
+	if httpURL != "" {
+		if _, err := parseHTTPURL(httpURL); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if _, err := loadHeatmapTZ(heatmapTZ); err != nil {
+		errs = append(errs, err.Error())
+	}
+	for _, t := range []struct{ name, value string }{{"since", replaySince}, {"until", replayUntil}} {
+		if t.value == "" {
+			continue
+		}
+		if _, err := parseReplayTime(t.value); err != nil {
+			errs = append(errs, "-"+t.name+": "+err.Error())
+		}
+	}
+	if intervalJitter < 0 || intervalJitter > 100 {
+		errs = append(errs, fmt.Sprintf("-interval-jitter: 取值 %v 超出范围 0-100", intervalJitter))
+	}
+	if waitFor < 0 {
+		errs = append(errs, fmt.Sprintf("-wait-for: 无效的取值 %s", waitFor))
+	}
+	if viaGateway != "" {
+		switch {
+		case !viaSupported:
+			errs = append(errs, "-via: 当前平台不支持修改路由表")
+		case net.ParseIP(viaGateway).To4() == nil:
+			errs = append(errs, fmt.Sprintf("-via: 无效的IPv4网关 %q", viaGateway))
+		case dropPrivs != "":
+			errs = append(errs, "-via 不能与 -drop-privs 同时使用，放弃权限后无法删除临时路由")
+		case configPath != "" || replayPath != "" || twampAddr != "" || dnsServer != "" || ntpServer != "" || stunServer != "" || quicAddr != "" || httpURL != "":
+			errs = append(errs, "-via 只用于命令行中的ICMP目标")
+		}
+	}
+	if dropPrivs != "" {
+		switch {
+		case !dropPrivsSupported:
+			errs = append(errs, "-drop-privs: 当前平台不支持切换用户")
+		case replayPath != "" || twampAddr != "" || dnsServer != "" || ntpServer != "" || stunServer != "" || quicAddr != "" || httpURL != "":
+			errs = append(errs, "-drop-privs 只用于ICMP探测，UDP/TCP探测本身不需要root权限")
+		case bfdEcho || mplsPrefix != "" || multiDNS || waitFor > 0 || broadcast || multicast:
+			errs = append(errs, "-drop-privs 不能与 -bfd、-mpls-lsp、-multi-dns、-wait-for、-broadcast、-multicast 同时使用，这些模式在探测过程中还需要创建套接字")
+		}
+	}
+	if slaRTT < 0 {
+		errs = append(errs, fmt.Sprintf("-sla-rtt: 无效的取值 %s", slaRTT))
+	}
+	if slaTarget < 0 || slaTarget > 100 {
+		errs = append(errs, fmt.Sprintf("-sla-target: 取值 %v 超出范围 0-100", slaTarget))
+	}
+	if baselineTolerance < 0 {
+		errs = append(errs, fmt.Sprintf("-baseline-tolerance: 应为不小于0的百分比，实际为 %v", baselineTolerance))
+	}
+	if dualEnded != "" {
+		if _, _, err := net.SplitHostPort(dualEnded); err != nil {
+			errs = append(errs, fmt.Sprintf("-dual-ended: 无效的代理地址 %q，应为 host:port", dualEnded))
+		}
+	}
+	if scheduleExpr != "" {
+		if _, err := parseCron(scheduleExpr); err != nil {
+			errs = append(errs, "-schedule: "+err.Error())
+		}
+		switch {
+		case forever:
+			errs = append(errs, "参数 -schedule 与 -t 不能同时指定，每次定时执行需要以 -n 结束")
+		case configPath != "" || statePath != "" || cyclePeriod > 0 || dropPrivs != "" || debugListen != "":
+			errs = append(errs, "参数 -schedule 不能与 -config、-state、-cycle-period、-drop-privs、-debug-listen 同时使用")
+		}
+	} else if schedulePath != "" {
+		errs = append(errs, "参数 -o 需要与 -schedule 同时使用")
+	}
+	if oneShot && (forever || explicit["count"] || scheduleExpr != "" || cyclePeriod > 0) {
+		errs = append(errs, "参数 -1 只发送一次请求，不能与 -t、-n、-schedule、-cycle-period 同时指定")
+	}
+	if broadcast && multicast {
+		errs = append(errs, "参数 -broadcast 与 -multicast 不能同时指定")
+	}
+	if tsOffset && !timestampMsg {
+		errs = append(errs, "参数 -offset 需要与 -timestamp-msg 同时使用")
+	}
+	if graphiteAddr != "" {
+		if _, _, err := net.SplitHostPort(graphiteAddr); err != nil {
+			errs = append(errs, fmt.Sprintf("-graphite: 无效的地址 %q，应为 host:port", graphiteAddr))
+		}
+	}
+	if cloudWatchNamespace != "" && !cloudWatchSupported {
+		errs = append(errs, "-cloudwatch: 当前程序编译时未包含AWS SDK，需以 -tags cloudwatch 重新编译")
+	}
+	if showMAC && !macSupported {
+		errs = append(errs, "-mac: 当前平台不支持读取邻居表")
+	}
+	if influxPerPacket && influxAddr == "" {
+		errs = append(errs, "参数 -influx-per-packet 需要与 -influx-addr 同时使用")
+	}
+	if fastest && multiDNS {
+		errs = append(errs, "参数 -fastest 与 -multi-dns 不能同时指定")
+	}
+	if aliveOnly && unreachOnly {
+		errs = append(errs, "参数 -alive 与 -unreach 不能同时指定")
+	}
+	switch sortBy {
+	case "", "loss", "avg", "name":
+	default:
+		errs = append(errs, fmt.Sprintf("-sort: 不支持的排序方式 %q", sortBy))
+	}
+	return errs
+}
+
+// 解析命令行参数，允许参数出现在目标之后(如 ping 8.8.8.8 -n 10)
+// flag包遇到第一个非参数项就会停止解析，这里取出该项后继续解析剩余部分
+// -- 之后的各项都是目标，不再解析为参数
+func parseInterspersed(args []string) []string {
+	var rest []string
+	for {
+		flag.CommandLine.Parse(args)
+		parsed := len(args) - flag.NArg()
+		if parsed > 0 && args[parsed-1] == "--" {
+			return append(rest, flag.Args()...)
+		}
+		args = flag.Args()
+		if len(args) == 0 {
+			return rest
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+}
+
+// 别名按固定顺序排列，保证错误提示稳定
+func sortedAliases(values map[string]int64) []string {
+	order := []string{"w", "W", "timeout", "n", "c", "count", "l", "s", "size", "i", "interval"}
+	var aliases []string
+	for _, a := range order {
+		if _, ok := values[a]; ok {
+			aliases = append(aliases, a)
+		}
+	}
+	return aliases
+}
+
+// 输出用法
+func usage() {
+	fmt.Println(`用法: ping [-t] [-cycle-active dur -cycle-period dur] [-state file] [-drop-privs user[:group]] [-via gateway] [-debug-listen addr] [-no-drain] [-backoff [-backoff-max sec]] [-n count] [-l size] [-w timeout [-adaptive-timeout]] [-i interval] [-j host-list] [-ttl n] [-hops] [-rcvbuf bytes] [-mark fwmark] [-priority n] [-ecn ect0|ect1] [-v|-vv [-dump-max n]] [-asym-detect] [-mac] [-otel] [-influx-addr host:port [-influx-per-packet]] [-graphite host:port] [-cloudwatch namespace] [-pmtud] [-netns path] [-iouring] [-ebpf] [-hw-ts] [-pcap file] [-chaos-loss pct] [-strict] target_name ...
+      ping [-n count] [-l size] [-w timeout] -config file
+      ping -format table [-sort loss|avg|name] [-unreachable-only] [-no-shared-socket] target_name ...
+      ping -multi-dns [-sort loss|avg|name] [-n count] [-w timeout] target_name ...
+      ping -fastest [-n count] [-w timeout] [-i interval] target_name ...
+      ping -wait-for dur [-w timeout] [-i interval] target_name
+      ping [-n count] [-l size] [-w timeout] [-i interval] -twamp host[:port]
+      ping [-t] [-n count] [-w timeout] [-i interval] -dual-ended host:port
+      ping -agent [addr]:port
+      ping [-t] [-n count] [-w timeout] [-i interval] -bfd target_name
+      ping [-n count] [-w timeout] -mpls-lsp prefix -mpls-label label[,label...] nexthop
+      ping [-t] [-n count] [-w timeout] [-i interval] -probe interface target_name
+      ping [-t] [-n count] [-w timeout] [-i interval] -addrmask target_name
+      ping [-t] [-n count] [-w timeout] [-i interval] -broadcast broadcast_address
+      ping [-t] [-n count] [-w timeout] [-i interval] -multicast group_address
+      ping [-t] [-n count] [-w timeout] [-i interval] -timestamp-msg [-offset] target_name
+      ping [-save-baseline file] [-compare-baseline file] [-baseline-tolerance pct] [-baseline-trimmed] target_name ...
+      ping -trim-outliers [-trim-pct pct | -trim-method iqr] target_name ...
+      ping -sla-rtt dur [-sla-target pct] target_name ...
+      ping -1 [-w timeout] [-l size] target_name
+      ping -schedule cron_expr [-o file] [-n count] target_name ...
+      ping [-t] [-n count] [-w timeout] [-i interval] -dns server[:port] [-dns-query name]
+      ping [-t] [-n count] [-w timeout] [-i interval] -ntp server[:port]
+      ping [-t] [-n count] [-w timeout] [-i interval] -stun server[:port]
+      ping [-t] [-n count] [-w timeout] [-i interval] -quic host[:port]
+      ping [-t] [-n count] [-w timeout] [-i interval] -http url
+      ping -replay file [-since time] [-until time] [-compare-baseline file] [-report file]
+      ping -db-heatmap file [-tz zone] [-since time] [-until time]
+      ping [-t] [-report file.md|file.html] target_name ...
+      ping -alive|-unreach [-f file] [-shuffle [-seed n]] [-no-shared-socket] target_name|network/prefix ...
+      ping -print-schema
+
+选项:
+   -t             持续ping直到按下Ctrl+C，结束时额外输出离线次数、
+                  离线时长、最长离线及可用率。从第一次请求起离线时，离线
+                  从开始探测时算起；结束时仍离线的，离线计算到结束时。
+   -cycle-active dur
+   -cycle-period dur
+                  周期探测：每个周期(如 10m)开始时探测一段时间(如 30s)，之后
+                  暂停到下一个周期，并输出以周期开始时间标记的本周期统计。
+                  累计统计跨周期累加，暂停期间不计入可用率。未指定 -n 时
+                  持续到按下Ctrl+C。
+   -state file    定期(每10秒)及结束时把各目标的累计统计、在线/离线状态、
+                  连续失败次数及最后的序号写入该JSON文件，启动时读取并接着
+                  上次继续，两次运行之间的间隔按暂停处理。文件损坏或版本
+                  不兼容时给出警告并从零开始。
+   -via gateway   经由该网关ping(如验证备用默认网关)：开始前为每个目标在main表中
+                  添加一条经由gateway的/32临时路由，结束时(包括按下Ctrl+C)删除。
+                  已有到目标的主机路由时拒绝，不覆盖；添加过程中出错时删除已添加
+                  的路由。需要CAP_NET_ADMIN权限(仅Linux)。
+   -drop-privs user[:group]
+                  先为各目标创建原始套接字(包括 -mark、-priority 的设置)，再切换
+                  到该用户及组(默认为用户的主组)开始探测，切换失败时退出而不以
+                  root继续运行(仅Unix)。之后无法再创建原始套接字，-debug-listen
+                  在切换后才监听，不能使用1024以下的端口。每个目标占用一个
+                  文件描述符，网段及 -f 展开后超过256个目标时拒绝运行。
+   -debug-listen addr
+                  在该地址(如 :6060)提供HTTP调试接口：/debug/vars 为expvar格式
+                  的JSON，其中 targets 为各目标的已发送、已接收、丢失率、最近
+                  一次耗时、在线状态(unknown/up/down)及无法开始探测的原因；
+                  /debug/pprof/ 为net/http/pprof的性能分析。
+   -no-drain      按下Ctrl+C时立即结束，正在等待回复的请求计为丢失。
+                  默认等待该请求收到回复或超时后再输出统计信息。
+   -backoff       连续3次请求失败后，每次失败把请求间隔加倍，
+                  第一次成功后恢复为 -i 指定的间隔。
+   -backoff-max sec
+                  退避后的最大请求间隔(秒)，默认60。
+   -n count       要发送的回显请求数。(-c、--count)
+   -l size        发送缓冲区大小。(-s、--size)
+                  auto 表示取出口接口MTU减去IP及ICMP头部，
+                  即最大的不分片报文。(-size-from-mtu)
+   -w timeout     等待每次回复的超时时间(毫秒)。(--timeout)
+   -W timeout     等待每次回复的超时时间(秒)。
+   -adaptive-timeout
+                  按TCP计算RTO的方式，根据往返时间调整每次请求的超时时间：
+                  max(下限, k×平滑往返时间 + 4×往返时间偏差)，连续超时时
+                  逐次加倍(最长60秒)。收到3个回复之前使用 -w。-v 时输出每次
+                  请求的超时时间，-record 中记录为 timeout_ms。
+   -adaptive-floor ms
+                  自适应超时时间的下限(毫秒)，默认20。
+   -adaptive-k k  自适应超时时间中平滑往返时间的倍数，默认2。
+   -i interval    两次请求的间隔(秒)。(--interval)
+   -j host-list   松散源路由，逗号分隔的中间地址(最多9个)。
+   -ttl n         发送报文的TTL(1-255)，指定时在开头一行显示。
+   -hops          在回复的TTL后显示估计的跳数，如 "TTL=53 (约 11 跳)"。
+                  按不小于回复TTL的最小常见初始TTL(64/128/255)估计。
+                  -record 的JSONL记录中总是包含估计的跳数(hops)。
+   -mark fwmark   以SO_MARK为发送的报文设置防火墙标记，用于按fwmark选择
+                  路由表的策略路由测试(仅Linux，需要CAP_NET_ADMIN)。
+                  可以是十进制或0x开头的十六进制，-record 的JSONL中记录该标记。
+   -priority n    以SO_PRIORITY设置套接字优先级，决定报文进入qdisc的哪个频段，
+                  与DSCP无关(仅Linux)。0-6无需特权，更高的取值需要CAP_NET_ADMIN。
+                  与 -mark、-ecn 一起显示在开头一行，-record 的JSONL中每条记录
+                  及第一行的 start 事件中都记录这三项设置。
+   -ecn mode      在发送报文IP头中设置ECN标记ECT(0)(ect0)或ECT(1)(ect1)，
+                  统计信息中汇总回复中的标记被保留、清除、改写或标记为CE
+                  的次数，用于检查路径是否清除ECN；-v 时逐条输出。
+                  有回复被标记为CE时给出 CONGESTION EXPERIENCED 警告，说明
+                  路径上的主动队列管理(如RED、CoDel)在丢包前已标记拥塞。
+   -rcvbuf bytes  设置原始套接字的接收缓冲区大小，内核截断时给出警告。
+                  高频率或多目标时缓冲区溢出的报文会被内核丢弃，
+                  Linux下统计信息中单独报告内核丢弃的报文数。
+   -v             输出详细信息，如回复中携带的IP选项。
+   -vv            在 -v 的基础上，每条回复后输出IP头、ICMP头及数据的
+                  十六进制内容，以及解码后的各字段和检验和校验结果。
+   -dump-max n    -vv 时每条回复最多输出的字节数，默认256。
+   -asym-detect   根据回复TTL的波动检测非对称路由。
+   -format table  同时ping多个目标，结束后输出汇总表格，
+                  在终端中每秒刷新。
+   -sort key      表格排序方式：loss(丢失率)、avg(平均耗时)、name(目标)。
+   -unreachable-only
+                  表格中只显示无法访问的目标。
+   -multi-dns     主机名有多条A记录(轮询DNS、CDN、负载均衡)时，分别并发ping
+                  每个IPv4地址，以表格输出每个地址一行及该主机名的合计行。
+   -wait-for dur  每隔 -i 秒(默认1秒)发送一次请求，直到目标有回复为止，
+                  最多等待dur(如 5m)，等待期间每次失败在标准错误输出一个点。
+                  收到回复时退出码为0，超时或按下Ctrl+C时为1。用于部署脚本中
+                  等待新启动的服务器可以访问。
+   -fastest       主机名有多个IPv4地址时，先并发向每个地址发送一次请求，输出
+                  各地址的结果后只ping往返时间最短的地址。有回复的地址优先，
+                  时间相同时取解析结果中靠前的；都没有回复时给出警告并使用
+                  第一个地址。
+   -f file        从文件读取目标列表，每行一个，#开头为注释。
+                  目标也可以是网段，如 192.168.1.0/24。
+   -1             供脚本使用：只发送一次请求，标准输出只有一行 "up 12.4ms"
+                  或 "down"，不输出其他信息；收到回复时退出码为0，否则为1。
+                  超时时间默认为500毫秒，数据长度默认为0，可以用 -w、-l 指定。
+   -alive         只输出有回复的地址，每行一个，其他信息输出到
+                  标准错误；列表非空时退出码为0，否则为1。
+                  未指定 -n 时每个地址只发送一次请求。
+   -unreach       只输出没有回复的地址，用法同 -alive。
+   -no-shared-socket
+                  -format table 及 -alive/-unreach 默认所有目标共享一个未连接的
+                  原始套接字，按(源地址, ID, 序号)分发应答，文件描述符数量不随
+                  目标数增加；指定该参数时改为每个目标单独建立连接。使用 -j、
+                  -ttl、-rcvbuf、-ecn、-mark、-priority、-ebpf、-iouring、
+                  -hw-ts、-drop-privs 时也是每个目标单独建立连接(不支持Windows)。
+   -shuffle       以随机顺序探测网段及 -f 中的各目标，避免按顺序扫描时被
+                  入侵防御设备中途封禁。统计及 -alive/-unreach 的输出仍按
+                  原顺序；逐个ping时各目标的输出按探测顺序。
+   -seed n        -shuffle 及 -interval-jitter 的随机种子，相同的种子得到
+                  相同的探测顺序。未指定时随机选取并输出到标准错误。
+   -interval-jitter pct
+                  每次请求的间隔在 ±pct% 范围内均匀浮动(0-100)，避免与
+                  周期性的背景流量同步。
+   -config file   从配置文件读取目标列表及默认参数，
+                  命令行参数优先于配置文件。
+   -twamp addr    以TWAMP-Light(RFC 5357)向反射器发送UDP测试报文，
+                  输出往返及去程、回程时延，默认端口862。
+                  -l 为测试报文的填充长度。
+   -dual-ended host:port
+                  向代理所在的主机发送回显请求，代理(另一端以 -agent 运行)通过
+                  TCP报告收到了哪些请求；结束时把超时的请求分为去程丢失(代理
+                  没有收到)与回程丢失(代理收到但回复没有到达)。
+   -agent [addr]:port
+                  作为 -dual-ended 的代理运行，只报告，回复仍由对端内核发出。
+                  需要创建原始套接字的权限，直到按下Ctrl+C。
+   -bfd           发送BFD回显报文(RFC 5880 §6.4，UDP 3785)，由对端
+                  环回到本机3785端口，验证对端的BFD回显功能。
+   -mpls-lsp prefix
+                  以MPLS LSP ping(RFC 4379)验证到该IPv4前缀的LSP(仅Linux)，
+                  目标为直连的下一跳，请求发往127.0.0.1:3503，
+                  只能沿标签交换路径到达出口。
+   -mpls-label list
+                  LSP ping压入的标签栈，逗号分隔，外层在前，
+                  通常为下一跳为该FEC分配的标签。
+   -probe iface   以扩展回显(RFC 8335 PROBE，ICMP类型42/43)查询目标节点上
+                  该接口的状态，iface为接口名称、索引或IP地址。
+                  目标返回参数问题表示不支持；连续多次没有回应时给出提示
+                  (Linux需开启 net.ipv4.icmp_echo_enable_probe)。
+   -broadcast     向广播地址(255.255.255.255 或子网广播地址，如 192.168.1.255)
+                  发送回显请求，每次请求在 -w 时间内收集所有主机的应答，每台
+                  主机输出一行，结束时列出所有应答过的主机。不使用ARP发现
+                  局域网中的主机；多数系统默认不应答广播(Linux 见
+                  net.ipv4.icmp_echo_ignore_broadcasts)。
+   -multicast     加入IPv4组播组(224.0.0.0/4，如 224.0.0.1)并向其发送回显请求，
+                  与 -broadcast 相同地收集及输出所有组成员的应答，用于发现
+                  组成员；组播报文的TTL为1，只到达本网段。Linux的组成员同样
+                  受 net.ipv4.icmp_echo_ignore_broadcasts 控制。
+                  也可以是IPv6组播组，以ICMPv6发送，如 ff02::1%接口名 发现该链路
+                  上的所有节点；链路本地范围的组必须以 %接口名 指定接口，应答
+                  中显示跳数限制(Linux 见 net.ipv6.icmp.echo_ignore_multicast)。
+   -addrmask, -mask
+                  发送ICMP地址掩码请求(RFC 950，类型17)，输出目标应答的
+                  子网掩码；所有请求都没有应答时报告为不支持。
+   -mac           在回复中显示回复者的MAC地址及厂商，如 [b8:27:eb:12:34:56
+                  Raspberry Pi Foundation]，取自内核的ARP表，只适用于直连网络
+                  中的目标(仅Linux)；厂商表只包含常见厂商，未知时只显示MAC。
+                  同一次运行中MAC地址变化(ARP欺骗或HA切换异常)时给出警告，
+                  并在统计信息中显示变化次数。
+   -timestamp-msg 发送ICMP时间戳请求(RFC 792，类型13)，由应答中的接收、发送
+                  时间戳把往返时间拆分为去程、对端处理及回程。时间戳为UTC零点
+                  以来的毫秒数，跨过零点时按一天取模；去程与回程包含两端时钟
+                  的偏差(两者之和不受影响)，只有时钟同步时才是单向时延。
+   -offset        与 -timestamp-msg 同时使用，每个回复附加由四个时间戳估计的
+                  对端时钟偏差，结束时输出偏差的中位数及路径不对称(两个方向
+                  排队时延中位数之差，不受时钟偏差影响)。对端时间戳为0或
+                  最高位为1时视为无效，不参与估计。
+   -save-baseline file, -baseline-save file
+                  把本次各目标的丢失率、最短/平均/P95/最长耗时及标准差
+                  保存为基线文件(JSON)。
+   -compare-baseline file, -baseline-load file
+                  结束后与基线比较，输出丢失率及耗时的变化，
+                  超出允许范围时退出码为6，无法读取基线文件时为1。
+   -baseline-tolerance pct
+                  平均/P95/最长耗时及标准差允许的增幅，默认20%；
+                  基线为0或增加不超过1ms时不判定。
+   -allow-avg-increase-ms ms
+                  允许的平均耗时增加，默认10毫秒。
+   -allow-loss-increase-pct pct
+                  允许的丢失率增加，默认1个百分点。
+   -baseline-trimmed
+                  保存及比较基线时，耗时使用去除离群值后的结果，丢失率不变。
+   -schedule cron_expr
+                  按cron表达式(分 时 日 月 星期，如 "0 * * * *" 表示每小时
+                  整点)定时执行：每次触发时对全部目标完整执行 -n 次请求，
+                  然后等待下一次触发，直到按下Ctrl+C。连续3次无法解析的目标
+                  标记为 dns_error，只提示一次，之后以1分钟起、每次加倍(最长
+                  1小时)的间隔重试解析，恢复后自动转为正常。
+   -o file        -schedule 每次执行后把各目标的丢失率、最短/平均/P95/最长
+                  耗时及标准差追加到文件，每次一行JSON。
+   -sla-rtt dur   结束时输出往返时间不超过dur(如 50ms)的请求所占的百分比，
+                  以及超过阈值、丢失的请求数，丢失的请求计为未达标。
+   -sla-target pct
+                  -sla-rtt 要求的达标百分比，默认99；任一目标低于该值时
+                  退出码为5。
+   -trim-outliers 统计信息中同时输出去除离群值后的最短、最长、平均耗时，
+                  丢失率始终按全部请求计算。
+   -trim-pct pct  两端各去除的百分比，默认5。
+   -trim-method m pct 按百分比去除；iqr 去除四分位距1.5倍之外的样本。
+   -dns server    以DNS查询的往返时间代替ICMP，测量DNS服务器，
+                  默认端口53。收到任何应答(包括NXDOMAIN)即为成功。
+   -dns-query name
+                  -dns 查询A记录的域名，默认 test.invalid。
+   -ntp server    以NTP客户端请求的往返时间代替ICMP，测量NTP服务器，
+                  默认端口123。同时输出服务器的层级、参考时钟ID、根延迟
+                  及根离散度，层级变化时给出提示。
+   -stun server   以STUN Binding请求的往返时间代替ICMP，测量STUN服务器，
+                  默认端口3478。每次回复输出服务器看到的外部(NAT转换后)
+                  地址，地址变化时给出提示。
+   -quic host     以HTTP/3 GET请求代替ICMP，默认端口443。每次新建QUIC连接
+                  (ALPN h3，校验服务器证书)，从发出第一个报文起分别输出
+                  收到服务器首个应答、完成握手及收到响应头(首字节)的耗时。
+                  握手失败时说明服务器是否有过应答。
+   -http url      以HTTP HEAD请求代替ICMP，每次重新建立连接，分别输出
+                  DNS解析、TCP连接、TLS握手(https)及HTTP响应的耗时。
+   -record file   把每次ICMP探测的时间、目标、标签、序号、耗时、TTL及结果写入
+                  文件，每行一个JSON对象；扩展名为 .csv 时写CSV。
+                  回复TTL连续两次为新的取值时输出提示(路径可能已改变)，
+                  并在JSONL中写入 "event":"path_change" 事件，回放时跳过。
+                  目标离线、恢复时写入 outage_start、outage_end 事件；-t 结束
+                  时及收到 SIGQUIT 时写入 availability 事件(可用率及离线统计)。
+                  JSONL的第一行为 "event":"start"，包含格式版本 schema_version
+                  及命令行参数。
+   -print-schema  输出 -record 的JSONL/CSV、基线文件及 -schedule 摘要当前格式
+                  版本的JSON Schema(字段名、类型及含义)后退出，供下游校验。
+                  增加字段时增加次版本号，删除或改名字段时增加主版本号。
+   -replay file   回放 -record 记录的文件，按目标重新计算统计信息、
+                  P50/P95/P99及可用性，不发送报文。无法解析的行跳过。
+   -since time    回放时只统计该时间及之后的记录。
+   -until time    回放时只统计该时间及之前的记录。
+                  时间格式为 "2006-01-02 15:04:05"(本地时间)或RFC 3339。
+                  同样适用于 -db-heatmap。
+   -db-heatmap file
+                  读取 -record 记录的文件(JSONL或CSV)，按小时(行)及星期(列)
+                  汇总每个目标的探测，输出往返时间中位数及丢包率，以阴影
+                  ░░ ▒▒ ▓▓ ██ 由好到差表示；没有样本的格子为 ·。不发送报文。
+                  本工具没有 -db 历史数据库，数据只来自 -record 的记录文件。
+   -tz zone       -db-heatmap 划分小时及星期的时区，默认 Local(本机时区)，
+                  如 UTC、Asia/Shanghai。
+   -strict        严格校验每个回复，以下任何一种异常都视为失败并给出原因：
+                  长度与IP头中的总长度不符、源地址不是目标、不是回显应答、
+                  ICMP检验和错误、TTL为0、标识/序号/数据与请求不符。
+                  统计信息中分别给出各类异常的次数，-record 的JSONL记录
+                  中以 "anomaly" 字段标明异常类型。
+   -chaos-loss pct
+                  按pct%的概率随机跳过发送(不发出报文)，按超时处理并计入
+                  丢失，用于测试应用对部分丢包的处理。结束时单独给出丢弃数。
+   -pcap file     把发送的回显请求及收到的所有ICMP报文写入pcap文件
+                  (LINKTYPE_RAW，纳秒时间戳)，可直接用Wireshark打开。
+                  发送报文的IP头由内核填写，文件中的IP头按源、目的地址构造。
+   -report file   结束后(包括按下Ctrl+C时)生成报告，包括参数、汇总、
+                  百分位、耗时分布及离线记录；扩展名为 .html 时生成HTML
+                  并附带耗时曲线，否则生成Markdown。
+   -otel          以OpenTelemetry span导出每次探测，
+                  导出地址取自 OTEL_EXPORTER_OTLP_ENDPOINT。
+   -influx-addr host:port
+                  结束后(-schedule 时每次执行后)以InfluxDB行协议通过UDP向
+                  该地址发送各目标的统计，如 localhost:8089：
+                  ping,host=目标 rtt_min=X,rtt_avg=Y,rtt_max=Z,loss_pct=W 时间戳
+                  目标的标签作为tag，没有回复时只有loss_pct。
+   -influx-per-packet
+                  同时为每次探测发送一行 ping_packet(seq、rtt、ttl、outcome)。
+   -graphite host:port
+                  结束后(-schedule 时每次执行后)连接Graphite明文协议接收端
+                  发送 ping.目标.rtt.min/avg/max、loss_pct、sent、received，
+                  目标中的点替换为下划线；每次发送后断开连接。
+   -cloudwatch namespace
+                  结束后(-schedule 时每次执行后)在该命名空间下发送CloudWatch
+                  指标 RTT.Min、RTT.Avg、RTT.Max(毫秒)及 PacketLoss(百分比)，
+                  维度为 Host=目标；AWS凭证按标准顺序获取(环境变量、配置文件、
+                  实例角色等)。需以 -tags cloudwatch 编译。
+   -pmtud         以二分法探测路径MTU(仅Linux)。
+   -netns path    在指定的网络命名空间中发送请求(仅Linux)，
+                  如 /var/run/netns/myns。程序切换后在该命名空间中重新执行，
+                  所有模式的请求、DNS解析及各导出都在其中进行。重新执行时
+                  参数及环境变量原样传入，-config 等文件会再读取一次。
+   -iouring       使用io_uring收发报文(仅Linux 5.7+)，内核不支持时自动改用
+                  标准socket。所有目标共享一个io_uring，并发探测时各目标的
+                  请求一次提交，系统调用次数不随目标数增加；但不一定更快，
+                  见BENCHMARKS.md。
+   -ebpf          在到目标的出口接口上挂载XDP程序(仅Linux 5.9+)，本进程的回显
+                  应答在网卡接收处经perf环形缓冲区交给程序后丢弃，不经内核的
+                  ICMP及socket处理；引用本进程请求的ICMP差错同样交给程序，并仍
+                  交给内核。驱动不支持时使用通用模式。同一接口上的目标共用一个
+                  程序，最后一个目标结束时卸载。需要CAP_BPF及CAP_NET_ADMIN，
+                  非root、内核不支持或接口上已有XDP程序时改用标准socket。
+                  应答须从出口接口返回，不处理带VLAN标签的帧。只交付ID匹配的
+                  应答，所以不能与 -strict 同时使用，也不能与 -iouring、-hw-ts
+                  同时使用。
+   -hw-ts         以SO_TIMESTAMPING的收发时间戳计算往返时间(仅Linux)，
+                  网卡不支持硬件时间戳时使用内核软件时间戳。
+
+目标可以写为 host=label 附加标签(标签名为name)，如 10.0.0.1=core-gw；
+之后可以用逗号附加该目标单独的 w(毫秒)、n、l、i(秒)，优先于命令行参数，如
+ping 192.168.1.1=gw,w=200 example.com=remote,w=2000,n=20，此时统计信息
+中显示各目标实际使用的参数。
+配置文件中以 labels 配置，标签名只能包含字母、数字和下划线。
+标签出现在回复、统计信息、表格、-record、-otel 及 -influx-addr 中。
+参数可以写在目标之前或之后。环境变量 PING_COUNT、PING_TIMEOUT(毫秒)、
+PING_SIZE 可设置 -n、-w、-l 的默认值。
+运行中发送 SIGUSR1 暂停发送、SIGUSR2 恢复(Windows不支持)，暂停期间
+统计数据保留，暂停时长不计入可用率。发送 SIGQUIT(Ctrl+\)在标准错误
+输出各目标截至目前的统计及可用率，探测继续进行(Windows不支持)。
+本机无法发送请求(如网络不可达、无缓冲区空间)时单独计数，与超时分开显示，
+此时退出码为3。`)
+}
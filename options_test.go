@@ -0,0 +1,189 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"testing"
+)
+
+// 以给定的命令行参数调用loadArgs，参数有误时测试失败，测试结束时恢复各参数的默认值
+func parseArgs(t testing.TB, args ...string) []string {
+	t.Helper()
+	if errs := argErrors(t, args...); len(errs) > 0 {
+		t.Fatalf("参数 %q: %v", args, errs)
+	}
+	return positional
+}
+
+// 以给定的命令行参数调用loadArgs，返回其中的错误
+// loadArgs在注册参数时把全局变量重置为默认值，所以每次调用前都使用新的FlagSet
+func argErrors(t testing.TB, args ...string) []string {
+	t.Helper()
+	run := func(args []string) []string {
+		flag.CommandLine = flag.NewFlagSet("ping", flag.ContinueOnError)
+		explicit = map[string]bool{}
+		os.Args = append([]string{"ping"}, args...)
+		return loadArgs()
+	}
+	oldArgs, oldFlags := os.Args, flag.CommandLine
+	t.Cleanup(func() {
+		run(nil)
+		os.Args, flag.CommandLine = oldArgs, oldFlags
+	})
+	return run(args)
+}
+
+// validateArgs的结果中是否有包含sub的错误
+func hasArgError(errs []string, sub string) bool {
+	for _, e := range errs {
+		if strings.Contains(e, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// Windows风格、Linux风格及长参数名的别名，换算为统一的单位
+func TestFlagAliases(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		count    int
+		size     int
+		timeout  int64 //毫秒
+		interval int64 //毫秒
+	}{
+		{"默认值", nil, 4, 32, 1000, 0},
+		{"Windows风格", []string{"-n", "2", "-l", "64", "-w", "500"}, 2, 64, 500, 0},
+		{"Linux风格", []string{"-c", "3", "-s", "56", "-W", "2", "-i", "0.2"}, 3, 56, 2000, 200},
+		{"-W 为秒且可以是小数", []string{"-W", "0.25"}, 4, 32, 250, 0},
+		{"长参数名", []string{"--count", "5", "--size", "100", "--timeout", "700", "--interval", "1.5"}, 5, 100, 700, 1500},
+		{"长参数名单横线", []string{"-count", "6"}, 6, 32, 1000, 0},
+		{"不同参数的别名可以混用", []string{"-c", "2", "-l", "8", "--timeout", "300"}, 2, 8, 300, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseArgs(t, append(tt.args, "127.0.0.1")...)
+			if count != tt.count || size != tt.size || timeout != tt.timeout || interval != tt.interval {
+				t.Fatalf("n=%d l=%d w=%d i=%d，应为 n=%d l=%d w=%d i=%d", count, size, timeout, interval, tt.count, tt.size, tt.timeout, tt.interval)
+			}
+		})
+	}
+}
+
+// 同一参数的多个别名同时指定时报错，错误中列出冲突的别名
+func TestFlagAliasConflicts(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-n", "2", "-c", "3"}, "参数 -n 与 -c 不能同时指定"},
+		{[]string{"-c", "3", "--count", "3"}, "参数 -c 与 -count 不能同时指定"},
+		{[]string{"-w", "500", "-W", "1"}, "参数 -w 与 -W 不能同时指定"},
+		{[]string{"-l", "1", "-s", "1", "-size", "1"}, "参数 -l 与 -s 与 -size 不能同时指定"},
+		{[]string{"-i", "1", "--interval", "1"}, "参数 -i 与 -interval 不能同时指定"},
+	}
+	for _, tt := range tests {
+		errs := argErrors(t, append(tt.args, "127.0.0.1")...)
+		if !hasArgError(errs, tt.want) {
+			t.Errorf("%q 的错误 = %q，应包含 %q", tt.args, errs, tt.want)
+		}
+	}
+}
+
+// 参数可以位于目标之前或之后，目标取自非参数项
+func TestPositionalArgs(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		hosts []string
+		count int
+	}{
+		{"参数在前", []string{"-n", "10", "8.8.8.8"}, []string{"8.8.8.8"}, 10},
+		{"参数在后", []string{"8.8.8.8", "-n", "10"}, []string{"8.8.8.8"}, 10},
+		{"参数在中间", []string{"a", "-n", "2", "b"}, []string{"a", "b"}, 2},
+		{"没有目标时数值不被当作目标", []string{"-n", "10"}, nil, 10},
+		{"--之后都是目标", []string{"-n", "1", "--", "-c", "x"}, []string{"-c", "x"}, 1},
+		{"--之后的参数不再解析", []string{"--", "a", "-n", "5"}, []string{"a", "-n", "5"}, 4},
+		{"布尔参数不吞掉目标", []string{"-t", "example.com"}, []string{"example.com"}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts := parseArgs(t, tt.args...)
+			if strings.Join(hosts, " ") != strings.Join(tt.hosts, " ") || count != tt.count {
+				t.Fatalf("目标 = %q，n = %d，应为 %q，n = %d", hosts, count, tt.hosts, tt.count)
+			}
+		})
+	}
+}
+
+// PING_COUNT、PING_TIMEOUT、PING_SIZE 提供默认值，命令行参数优先
+func TestEnvDefaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		count   int
+		size    int
+		timeout int64
+		err     string
+	}{
+		{"环境变量", map[string]string{"PING_COUNT": "7", "PING_SIZE": "100", "PING_TIMEOUT": "250"}, nil, 7, 100, 250, ""},
+		{"命令行优先", map[string]string{"PING_COUNT": "7", "PING_TIMEOUT": "250"}, []string{"-c", "2", "-W", "1"}, 2, 32, 1000, ""},
+		{"空值忽略", map[string]string{"PING_COUNT": ""}, nil, 4, 32, 1000, ""},
+		{"非数字", map[string]string{"PING_COUNT": "many"}, nil, 4, 32, 1000, `环境变量 PING_COUNT 的取值 "many" 无效`},
+		{"负数", map[string]string{"PING_SIZE": "-1"}, nil, 4, 32, 1000, `环境变量 PING_SIZE 的取值 "-1" 无效`},
+		{"超出范围由校验报告", map[string]string{"PING_SIZE": "70000"}, nil, 4, 70000, 1000, "-l: 取值 70000 超出范围"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			errs := argErrors(t, append(tt.args, "127.0.0.1")...)
+			if tt.err != "" {
+				if !hasArgError(errs, tt.err) {
+					t.Fatalf("错误 = %q，应包含 %q", errs, tt.err)
+				}
+				return
+			}
+			if len(errs) > 0 {
+				t.Fatal(errs)
+			}
+			if count != tt.count || size != tt.size || timeout != tt.timeout {
+				t.Fatalf("n=%d l=%d w=%d，应为 n=%d l=%d w=%d", count, size, timeout, tt.count, tt.size, tt.timeout)
+			}
+		})
+	}
+}
+
+// 各项取值错误一次性全部返回，而不是遇到第一个就停止
+func TestValidateArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		errs []string //空表示没有错误
+	}{
+		{[]string{"-l", "0", "-n", "1", "-w", "1"}, nil},
+		{[]string{"-l", "65507"}, nil},
+		{[]string{"-t", "-n", "0"}, nil}, //-t 时不限制次数
+		{[]string{"-l", "65508"}, []string{"-l: 取值 65508 超出范围 0-65507"}},
+		{[]string{"-l", "1000000000"}, []string{"-l: 取值 1000000000 超出范围 0-65507"}},
+		{[]string{"-l", "-1"}, []string{"-l: 取值 -1 超出范围"}},
+		{[]string{"-w", "0"}, []string{"-w: 超时时间必须大于0，实际为 0"}},
+		{[]string{"-n", "-3"}, []string{"-n: 请求次数必须大于0，实际为 -3"}},
+		{[]string{"-l", "70000", "-w", "-5", "-n", "0"}, []string{"-l: 取值 70000", "-w: 超时时间必须大于0", "-n: 请求次数必须大于0"}},
+	}
+	for _, tt := range tests {
+		errs := argErrors(t, append(tt.args, "127.0.0.1")...)
+		if len(errs) != len(tt.errs) {
+			t.Errorf("参数 %q: 错误 %q，期望 %q", tt.args, errs, tt.errs)
+			continue
+		}
+		for _, e := range tt.errs {
+			if !hasArgError(errs, e) {
+				t.Errorf("参数 %q: 错误 %q 中没有 %q", tt.args, errs, e)
+			}
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OTLP/HTTP JSON 导出相关常量
+const (
+	otelDefaultEndpoint = "http://localhost:4318" //OTEL_EXPORTER_OTLP_ENDPOINT 未设置时的默认地址
+	otelQueueSize       = 2048                    //span队列长度，队列满时丢弃，保证探测循环不被阻塞
+	otelBatchSize       = 256                     //每批导出的span数量
+	otelFlushInterval   = 5 * time.Second         //定时导出间隔
+	otelShutdownTimeout = 5 * time.Second         //退出时等待导出完成的最长时间
+)
+
+var otelExp *otelExporter //span导出器，未开启 -otel 时为nil
+
+// 单次探测对应的span
+type probeSpan struct {
+	target  string
+	labels  map[string]string
+	seq     int
+	start   time.Time
+	end     time.Time
+	rtt     int64 //毫秒
+	ttl     int
+	timeout time.Duration //本次请求的超时时间
+	outcome string        //success / timeout / send_error / error / chaos_drop / anomaly
+	anomaly string        //outcome为anomaly时的异常类型
+}
+
+// otelExporter 以OTLP/HTTP JSON协议批量导出span
+// 探测循环只负责把span放进队列，导出在后台goroutine中完成
+type otelExporter struct {
+	url      string
+	traceID  string //同一次运行的所有探测共用一个trace
+	queue    chan probeSpan
+	done     chan struct{}
+	client   *http.Client
+	failOnce sync.Once //导出失败只提示一次
+}
+
+// 开启span导出
+func startOtel() {
+	url := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if url == "" {
+		endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if endpoint == "" {
+			endpoint = otelDefaultEndpoint
+		}
+		url = strings.TrimRight(endpoint, "/") + "/v1/traces"
+	}
+
+	otelExp = &otelExporter{
+		url:     url,
+		traceID: randomHex(16),
+		queue:   make(chan probeSpan, otelQueueSize),
+		done:    make(chan struct{}),
+		client:  &http.Client{Timeout: otelShutdownTimeout},
+	}
+	go otelExp.run()
+}
+
+// 关闭span导出，导出队列中剩余的span
+func stopOtel() {
+	if otelExp == nil {
+		return
+	}
+	close(otelExp.queue)
+	select {
+	case <-otelExp.done:
+	case <-time.After(otelShutdownTimeout):
+		fmt.Fprintln(os.Stderr, "OpenTelemetry: 等待导出超时，部分span未发送")
+	}
+}
+
+// 记录一次探测，未开启 -otel 时直接返回
+func recordSpan(s probeSpan) {
+	if otelExp == nil {
+		return
+	}
+	select {
+	case otelExp.queue <- s:
+	default: //队列已满，丢弃
+	}
+}
+
+// 后台导出：攒够一批或到达定时间隔时导出
+func (e *otelExporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(otelFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]probeSpan, 0, otelBatchSize)
+	for {
+		select {
+		case s, ok := <-e.queue:
+			if !ok {
+				e.export(batch)
+				return
+			}
+			batch = append(batch, s)
+			if len(batch) >= otelBatchSize {
+				e.export(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			e.export(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// 导出一批span
+func (e *otelExporter) export(batch []probeSpan) {
+	if len(batch) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(e.payload(batch))
+	if err != nil {
+		e.fail(err)
+		return
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		e.fail(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		e.fail(fmt.Errorf("HTTP %s", resp.Status))
+	}
+}
+
+func (e *otelExporter) fail(err error) {
+	e.failOnce.Do(func() {
+		fmt.Fprintf(os.Stderr, "OpenTelemetry: 导出span到 %s 失败: %v\n", e.url, err)
+	})
+}
+
+// 组装OTLP JSON请求体
+func (e *otelExporter) payload(batch []probeSpan) map[string]any {
+	spans := make([]map[string]any, 0, len(batch))
+	for _, s := range batch {
+		status := 1 //STATUS_CODE_OK
+		if s.outcome != "success" {
+			status = 2 //STATUS_CODE_ERROR
+		}
+		spans = append(spans, map[string]any{
+			"traceId":           e.traceID,
+			"spanId":            randomHex(8),
+			"name":              "icmp.echo",
+			"kind":              3, //SPAN_KIND_CLIENT
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        spanAttrs(s),
+			"status":            map[string]any{"code": status},
+		})
+	}
+
+	return map[string]any{
+		"resourceSpans": []map[string]any{{
+			"resource": map[string]any{
+				"attributes": []map[string]any{otelAttr("service.name", "stringValue", "icmptool")},
+			},
+			"scopeSpans": []map[string]any{{
+				"scope": map[string]any{"name": "icmptool"},
+				"spans": spans,
+			}},
+		}},
+	}
+}
+
+// span的属性，目标的标签以 label.<name> 为属性名
+func spanAttrs(s probeSpan) []map[string]any {
+	attrs := []map[string]any{
+		otelAttr("target", "stringValue", s.target),
+		otelAttr("seq", "intValue", strconv.Itoa(s.seq)),
+		otelAttr("rtt", "intValue", strconv.FormatInt(s.rtt, 10)),
+		otelAttr("ttl", "intValue", strconv.Itoa(s.ttl)),
+		otelAttr("outcome", "stringValue", s.outcome),
+	}
+	for _, k := range sortedKeys(s.labels) {
+		attrs = append(attrs, otelAttr("label."+k, "stringValue", s.labels[k]))
+	}
+	return attrs
+}
+
+func otelAttr(key, kind string, value any) map[string]any {
+	return map[string]any{"key": key, "value": map[string]any{kind: value}}
+}
+
+// 生成n字节的随机数，以16进制字符串返回
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 记录收到的OTLP请求的接收端
+type otlpReceiver struct {
+	mu     sync.Mutex
+	paths  []string
+	bodies []map[string]any
+}
+
+func newOTLPReceiver(t *testing.T, status int) (*otlpReceiver, *httptest.Server) {
+	r := &otlpReceiver{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		data, _ := io.ReadAll(req.Body)
+		var body map[string]any
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("请求体不是JSON: %v\n%s", err, data)
+		}
+		r.mu.Lock()
+		r.paths = append(r.paths, req.URL.Path)
+		r.bodies = append(r.bodies, body)
+		r.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return r, srv
+}
+
+func (r *otlpReceiver) spans() []map[string]any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var spans []map[string]any
+	for _, body := range r.bodies {
+		for _, rs := range body["resourceSpans"].([]any) {
+			for _, ss := range rs.(map[string]any)["scopeSpans"].([]any) {
+				for _, s := range ss.(map[string]any)["spans"].([]any) {
+					spans = append(spans, s.(map[string]any))
+				}
+			}
+		}
+	}
+	return spans
+}
+
+func spanAttr(span map[string]any, key string) any {
+	for _, a := range span["attributes"].([]any) {
+		a := a.(map[string]any)
+		if a["key"] == key {
+			for _, v := range a["value"].(map[string]any) {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// 退出时导出队列中剩余的span，同一次运行共用trace ID，失败的探测标记为错误
+func TestOtelExport(t *testing.T) {
+	recv, srv := newOTLPReceiver(t, http.StatusOK)
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", srv.URL+"/")
+	t.Cleanup(func() { otelExp = nil })
+
+	startOtel()
+	start := time.Unix(1700000000, 0)
+	recordSpan(probeSpan{target: "192.0.2.1", seq: 0, start: start, end: start.Add(3 * time.Millisecond), rtt: 3, ttl: 64, outcome: "success"})
+	recordSpan(probeSpan{target: "192.0.2.1", seq: 1, start: start.Add(time.Second), end: start.Add(2 * time.Second), rtt: 1000, outcome: "timeout"})
+	stopOtel()
+
+	if len(recv.paths) != 1 || recv.paths[0] != "/v1/traces" {
+		t.Fatalf("请求路径 = %v，期望一次 /v1/traces", recv.paths)
+	}
+	spans := recv.spans()
+	if len(spans) != 2 {
+		t.Fatalf("收到 %d 个span，期望 2", len(spans))
+	}
+	if spans[0]["traceId"] != spans[1]["traceId"] || len(spans[0]["traceId"].(string)) != 32 {
+		t.Errorf("traceId = %v, %v", spans[0]["traceId"], spans[1]["traceId"])
+	}
+	if spans[0]["spanId"] == spans[1]["spanId"] {
+		t.Errorf("两个span的spanId相同: %v", spans[0]["spanId"])
+	}
+	if spans[0]["startTimeUnixNano"] != "1700000000000000000" || spans[0]["endTimeUnixNano"] != "1700000000003000000" {
+		t.Errorf("时间 = %v - %v", spans[0]["startTimeUnixNano"], spans[0]["endTimeUnixNano"])
+	}
+	tests := []struct {
+		outcome, seq, ttl string
+		status            float64
+	}{
+		{"success", "0", "64", 1},
+		{"timeout", "1", "0", 2},
+	}
+	for i, tt := range tests {
+		s := spans[i]
+		if spanAttr(s, "outcome") != tt.outcome || spanAttr(s, "seq") != tt.seq || spanAttr(s, "ttl") != tt.ttl || spanAttr(s, "target") != "192.0.2.1" {
+			t.Errorf("span %d 的属性 = %v", i, s["attributes"])
+		}
+		if code := s["status"].(map[string]any)["code"]; code != tt.status {
+			t.Errorf("span %d 的状态 = %v，期望 %v", i, code, tt.status)
+		}
+	}
+}
+
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT 优先于 OTEL_EXPORTER_OTLP_ENDPOINT，原样使用
+func TestOtelTracesEndpoint(t *testing.T) {
+	recv, srv := newOTLPReceiver(t, http.StatusOK)
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", srv.URL+"/custom")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://192.0.2.1:4318")
+	t.Cleanup(func() { otelExp = nil })
+
+	startOtel()
+	recordSpan(probeSpan{target: "192.0.2.1", outcome: "success"})
+	stopOtel()
+	if len(recv.paths) != 1 || recv.paths[0] != "/custom" {
+		t.Errorf("请求路径 = %v，期望 /custom", recv.paths)
+	}
+}
+
+// 导出失败只提示一次，不影响探测
+func TestOtelExportFailure(t *testing.T) {
+	_, srv := newOTLPReceiver(t, http.StatusServiceUnavailable)
+	t.Cleanup(func() { otelExp = nil })
+
+	otelExp = &otelExporter{url: srv.URL, traceID: randomHex(16), client: srv.Client()}
+	_, stderr := captureOutput(t, func() {
+		otelExp.export([]probeSpan{{outcome: "success"}})
+		otelExp.export([]probeSpan{{outcome: "success"}})
+	})
+	if n := strings.Count(stderr, "OpenTelemetry: 导出span到"); n != 1 || !strings.Contains(stderr, "503") {
+		t.Errorf("标准错误中提示了 %d 次: %s", n, stderr)
+	}
+}
+
+// 未开启 -otel 时recordSpan直接返回
+func TestRecordSpanDisabled(t *testing.T) {
+	otelExp = nil
+	recordSpan(probeSpan{outcome: "success"})
+	stopOtel()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 新的TTL需要连续出现的次数才认为路径已改变，避免个别报文造成误报
+const ttlChangeConfirm = 2
+
+// 跟踪回复TTL的变化：TTL改变几乎总是意味着路由改变
+type ttlTracker struct {
+	counts   map[int]int //各TTL取值出现的次数
+	current  int         //当前认定的TTL，0表示还没有回复
+	pending  int         //与current不同、尚待确认的TTL
+	pendingN int         //pending连续出现的次数
+}
+
+// 记录一次回复的TTL，新的TTL连续出现ttlChangeConfirm次时返回原TTL、新TTL及true
+func (t *ttlTracker) observe(ttl int) (from, to int, changed bool) {
+	if t.counts == nil {
+		t.counts = map[int]int{}
+	}
+	t.counts[ttl]++
+	switch {
+	case t.current == 0:
+		t.current = ttl
+		return 0, 0, false
+	case ttl == t.current:
+		t.pending, t.pendingN = 0, 0
+		return 0, 0, false
+	case ttl == t.pending:
+		t.pendingN++
+	default:
+		t.pending, t.pendingN = ttl, 1
+	}
+	if t.pendingN < ttlChangeConfirm {
+		return 0, 0, false
+	}
+	from, to = t.current, ttl
+	t.current, t.pending, t.pendingN = ttl, 0, 0
+	return from, to, true
+}
+
+// 出现过的各TTL及次数，如 "117 (40 次)，53 (12 次)"，按次数从多到少排列
+// 只有一个取值时为空
+func (t *ttlTracker) summary() string {
+	if len(t.counts) < 2 {
+		return ""
+	}
+	ttls := make([]int, 0, len(t.counts))
+	for ttl := range t.counts {
+		ttls = append(ttls, ttl)
+	}
+	sort.Slice(ttls, func(i, j int) bool {
+		if t.counts[ttls[i]] != t.counts[ttls[j]] {
+			return t.counts[ttls[i]] > t.counts[ttls[j]]
+		}
+		return ttls[i] > ttls[j]
+	})
+	parts := make([]string, len(ttls))
+	for i, ttl := range ttls {
+		parts[i] = fmt.Sprintf("%d (%d 次)", ttl, t.counts[ttl])
+	}
+	return strings.Join(parts, "，")
+}
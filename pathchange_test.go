@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 新的TTL连续出现两次才报告，个别报文不算路径改变
+func TestTTLTracker(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttls    []int
+		changes []string //依次报告的 "原TTL->新TTL"
+		summary string
+	}{
+		{"不变", []int{117, 117, 117}, nil, ""},
+		{"改变", []int{117, 117, 53, 53, 53}, []string{"117->53"}, "53 (3 次)，117 (2 次)"},
+		{"单个异常报文", []int{117, 53, 117, 117}, nil, "117 (3 次)，53 (1 次)"},
+		{"交替", []int{117, 53, 117, 53, 117}, nil, "117 (3 次)，53 (2 次)"},
+		{"新值被打断后重新计数", []int{117, 53, 60, 53, 53}, []string{"117->53"}, "53 (3 次)，117 (1 次)，60 (1 次)"},
+		{"改变后恢复", []int{64, 60, 60, 64, 64}, []string{"64->60", "60->64"}, "64 (3 次)，60 (2 次)"},
+		{"第一次回复", []int{53}, nil, ""},
+		{"次数相同时TTL大的在前", []int{60, 64, 64, 60}, []string{"60->64"}, "64 (2 次)，60 (2 次)"},
+	}
+	for _, tt := range tests {
+		var tr ttlTracker
+		var changes []string
+		for _, ttl := range tt.ttls {
+			if from, to, changed := tr.observe(ttl); changed {
+				changes = append(changes, fmt.Sprintf("%d->%d", from, to))
+			}
+		}
+		if strings.Join(changes, " ") != strings.Join(tt.changes, " ") {
+			t.Errorf("%s %v: 报告 %q，期望 %q", tt.name, tt.ttls, changes, tt.changes)
+		}
+		if got := tr.summary(); got != tt.summary {
+			t.Errorf("%s %v: summary() = %q，期望 %q", tt.name, tt.ttls, got, tt.summary)
+		}
+	}
+}
+
+// 按给定的TTL序列依次回复的连接
+type ttlConn struct {
+	*mockConn
+	ttls []int
+}
+
+func (c *ttlConn) Write(b []byte) (int, error) {
+	if _, err := c.mockConn.Write(b); err != nil {
+		return 0, err
+	}
+	c.reply[8] = byte(c.ttls[0])
+	if len(c.ttls) > 1 {
+		c.ttls = c.ttls[1:]
+	}
+	return len(b), nil
+}
+
+// 路径改变时输出提示，在JSONL中写入path_change事件，回放时跳过该事件
+func TestPathChangeEvent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rec.jsonl")
+	parseArgs(t, "-n", "6", "-i", "0", "127.0.0.1")
+	if err := startRecord(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stopRecord)
+	predial(t, "127.0.0.1", &ttlConn{mockConn: newMockConn(), ttls: []int{117, 117, 53, 117, 53, 53}})
+	p := newPinger("127.0.0.1")
+	stdout, _ := captureOutput(t, p.Run)
+	stopRecord()
+
+	if n := strings.Count(stdout, "路径可能已改变"); n != 1 || !strings.Contains(stdout, "TTL 由 117 变为 53，路径可能已改变") {
+		t.Errorf("提示出现 %d 次:\n%s", n, stdout)
+	}
+	if !strings.Contains(stdout, "回复TTL: 117 (3 次)，53 (3 次)") {
+		t.Errorf("统计信息中没有各TTL的次数:\n%s", stdout)
+	}
+	events := readEvents(t, path)
+	if len(events) != 1 || events[0].Event != "path_change" || events[0].FromTTL != 117 || events[0].ToTTL != 53 || events[0].Seq != 5 {
+		t.Errorf("事件 = %+v，期望一个第5次请求的path_change 117->53", events)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	pingers, bad, err := replayRecords(f)
+	if err != nil || bad != 0 || len(pingers) != 1 {
+		t.Fatalf("回放: %d 个目标，%d 行无法解析，%v", len(pingers), bad, err)
+	}
+	if ss := pingers[0].Stats.Snapshot(); ss.Sent != 6 || ss.Received != 6 {
+		t.Errorf("回放的统计 = 发送 %d 收到 %d，期望各为 6", ss.Sent, ss.Received)
+	}
+}
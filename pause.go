@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// 暂停/恢复状态，由信号切换，各Pinger在发送下一次请求前检查
+type pauseState struct {
+	mu      sync.Mutex
+	paused  bool
+	resumed chan struct{} //恢复时关闭
+}
+
+var probing pauseState
+
+// 暂停发送，已经暂停时返回false
+func (s *pauseState) pause() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paused {
+		return false
+	}
+	s.paused, s.resumed = true, make(chan struct{})
+	return true
+}
+
+// 恢复发送，未暂停时返回false
+func (s *pauseState) resume() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.paused {
+		return false
+	}
+	s.paused = false
+	close(s.resumed)
+	return true
+}
+
+// 是否处于暂停状态
+func (s *pauseState) isPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+// 暂停时阻塞直到恢复或收到Ctrl+C
+func (s *pauseState) wait() {
+	s.mu.Lock()
+	if !s.paused {
+		s.mu.Unlock()
+		return
+	}
+	ch := s.resumed
+	s.mu.Unlock()
+
+	select {
+	case <-ch:
+	case <-stop:
+	}
+}
+
+// 处理暂停/恢复信号，提示输出到标准错误，不影响标准输出中的结果
+func handlePauseSignal(pause bool) {
+	switch {
+	case pause && probing.pause():
+		fmt.Fprintln(os.Stderr, "已暂停，发送 SIGUSR2 恢复。")
+	case !pause && probing.resume():
+		fmt.Fprintln(os.Stderr, "已恢复。")
+	}
+}
+
+// 等待发送第i次请求的时机：两次请求的间隔，以及暂停
+// 暂停的时长不计入可用性统计，恢复后立即发送，之后的间隔从恢复时重新计算
+// 收到Ctrl+C、done关闭或until返回true时返回false
+func (p *Pinger) nextProbe(i int) bool {
+	p.noteOutage()
+	if stopped() || p.halted() {
+		return false
+	}
+	wait := time.Duration(p.Interval) * time.Millisecond
+	if useBackoff && i > 0 {
+		wait = p.backoffInterval(wait)
+	}
+	wait = jitterInterval(wait)
+	if i > 0 && wait > 0 {
+		//退避后的间隔可能长达数十秒，等待期间响应Ctrl+C
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-stop:
+			t.Stop()
+			return false
+		case <-p.done:
+			t.Stop()
+			return false
+		}
+	}
+	if probing.isPaused() {
+		p.Stats.pause(time.Now())
+		probing.wait()
+		p.Stats.resume(time.Now())
+	}
+	if cyclePeriod > 0 && !p.cycleWindow() {
+		return false
+	}
+	return !stopped() && !p.halted()
+}
+
+// 是否因 done 关闭或 until 返回true而停止
+func (p *Pinger) halted() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+	}
+	return p.until != nil && p.until()
+}
@@ -0,0 +1,6 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+// 当前平台没有 SIGUSR1/SIGUSR2，不支持暂停
+func notifyPause() {}
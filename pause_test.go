@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// 重复的暂停、恢复信号只有第一次生效
+func TestPauseState(t *testing.T) {
+	var s pauseState
+	steps := []struct {
+		pause  bool
+		ok     bool
+		paused bool
+	}{
+		{false, false, false}, //未暂停时恢复
+		{true, true, true},
+		{true, false, true}, //重复暂停
+		{false, true, false},
+		{false, false, false}, //重复恢复
+		{true, true, true},    //可以再次暂停
+	}
+	for i, st := range steps {
+		var ok bool
+		if st.pause {
+			ok = s.pause()
+		} else {
+			ok = s.resume()
+		}
+		if ok != st.ok || s.isPaused() != st.paused {
+			t.Errorf("第 %d 步(pause=%v) = %v，暂停中 %v，期望 %v、%v", i, st.pause, ok, s.isPaused(), st.ok, st.paused)
+		}
+	}
+}
+
+// 暂停时wait阻塞到恢复或收到Ctrl+C，未暂停时立即返回
+func TestPauseWait(t *testing.T) {
+	oldStop := stop
+	stop = make(chan struct{})
+	t.Cleanup(func() { stop = oldStop })
+
+	var s pauseState
+	waited := func(release func()) time.Duration {
+		done := make(chan time.Duration)
+		start := time.Now()
+		go func() {
+			s.wait()
+			done <- time.Since(start)
+		}()
+		time.Sleep(50 * time.Millisecond)
+		release()
+		select {
+		case d := <-done:
+			return d
+		case <-time.After(time.Second):
+			t.Fatal("wait没有返回")
+			return 0
+		}
+	}
+
+	if d := waited(func() {}); d >= 50*time.Millisecond {
+		t.Errorf("未暂停时wait阻塞了 %s", d)
+	}
+	s.pause()
+	if d := waited(func() { s.resume() }); d < 50*time.Millisecond {
+		t.Errorf("暂停时wait只阻塞了 %s", d)
+	}
+	s.pause()
+	if d := waited(func() { close(stop) }); d < 50*time.Millisecond {
+		t.Errorf("暂停时wait只阻塞了 %s", d)
+	}
+}
+
+// 暂停期间nextProbe不返回，暂停的时长不计入运行时长，恢复后立即发送下一次请求
+func TestNextProbePaused(t *testing.T) {
+	t.Cleanup(func() { probing.resume() })
+	p := &Pinger{Count: 10, Stats: newStatistics()}
+	p.Stats.addSent()
+	p.Stats.addSuccess(1)
+
+	probing.pause()
+	next := make(chan bool)
+	go func() { next <- p.nextProbe(1) }()
+	select {
+	case <-next:
+		t.Fatal("暂停期间nextProbe返回了")
+	case <-time.After(200 * time.Millisecond):
+	}
+	resumed := time.Now()
+	probing.resume()
+	select {
+	case ok := <-next:
+		if !ok {
+			t.Fatal("恢复后nextProbe返回false")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("恢复后nextProbe没有返回")
+	}
+	if d := time.Since(resumed); d > 100*time.Millisecond {
+		t.Errorf("恢复后 %s 才发送下一次请求", d)
+	}
+	if rt := p.Stats.Snapshot().Avail.Runtime; rt >= 200*time.Millisecond {
+		t.Errorf("运行时长 = %s，暂停的时长应被扣除", rt)
+	}
+}
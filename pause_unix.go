@@ -0,0 +1,20 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// SIGUSR1 暂停发送，SIGUSR2 恢复发送
+func notifyPause() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for s := range sig {
+			handlePauseSignal(s == syscall.SIGUSR1)
+		}
+	}()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+var pcapPath string //收发的报文写入该pcap文件
+
+const (
+	pcapMagicNano    = 0xa1b23c4d //纳秒精度时间戳的pcap文件
+	pcapVersionMajor = 2
+	pcapVersionMinor = 4
+	pcapSnapLen      = 1 << 16
+	pcapLinkTypeRaw  = 101 //LINKTYPE_RAW，报文从IP头开始
+	pcapFileHdrLen   = 24
+	pcapRecordHdrLen = 16
+)
+
+// pcap文件写入器，可被多个目标并发调用
+type pcapWriter struct {
+	mu     sync.Mutex
+	f      *os.File
+	w      *bufio.Writer
+	closed bool
+}
+
+var pcapw *pcapWriter
+
+// 创建pcap文件并写入文件头
+func startPcap(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	pcapw = &pcapWriter{f: f, w: bufio.NewWriter(f)}
+	if _, err := pcapw.w.Write(pcapFileHeader()); err != nil {
+		f.Close()
+		pcapw = nil
+		return err
+	}
+	return nil
+}
+
+// 写出缓冲区中的报文并关闭文件
+func stopPcap() {
+	pw := pcapw
+	if pw == nil {
+		return
+	}
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	pw.w.Flush()
+	pw.f.Close()
+	pw.closed = true
+}
+
+// pcap文件头，小端序
+func pcapFileHeader() []byte {
+	b := make([]byte, pcapFileHdrLen)
+	binary.LittleEndian.PutUint32(b[0:4], pcapMagicNano)
+	binary.LittleEndian.PutUint16(b[4:6], pcapVersionMajor)
+	binary.LittleEndian.PutUint16(b[6:8], pcapVersionMinor)
+	//b[8:16] 时区及时间戳精度，均为0
+	binary.LittleEndian.PutUint32(b[16:20], pcapSnapLen)
+	binary.LittleEndian.PutUint32(b[20:24], pcapLinkTypeRaw)
+	return b
+}
+
+// 每个报文前的记录头
+func pcapRecordHeader(t time.Time, n int) []byte {
+	b := make([]byte, pcapRecordHdrLen)
+	binary.LittleEndian.PutUint32(b[0:4], uint32(t.Unix()))
+	binary.LittleEndian.PutUint32(b[4:8], uint32(t.Nanosecond()))
+	binary.LittleEndian.PutUint32(b[8:12], uint32(n))
+	binary.LittleEndian.PutUint32(b[12:16], uint32(n))
+	return b
+}
+
+// 写入一个以IP头开始的报文，未开启 -pcap 时不做任何事
+func writePcap(t time.Time, pkt []byte) {
+	pw := pcapw
+	if pw == nil {
+		return
+	}
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.closed { //退出时仍在接收的报文
+		return
+	}
+	pw.w.Write(pcapRecordHeader(t, len(pkt)))
+	pw.w.Write(pkt)
+}
+
+// 为发送的ICMP报文补上IPv4头后写入pcap文件
+// 发送时IP头由内核填写，这里按相同的源、目的地址构造，TTL等字段取常用值
+func writePcapSent(t time.Time, src, dst net.Addr, icmp []byte) {
+	if pcapw == nil {
+		return
+	}
+	pkt := make([]byte, 20+len(icmp))
+	pkt[0] = 0x45 //版本4，头长度20
+	binary.BigEndian.PutUint16(pkt[2:4], uint16(len(pkt)))
+	pkt[8] = 64 //TTL
+	pkt[9] = 1  //ICMP
+	copy(pkt[12:16], addrIP4(src))
+	copy(pkt[16:20], addrIP4(dst))
+	sum, _ := checkSum(pkt[:20])
+	binary.BigEndian.PutUint16(pkt[10:12], sum)
+	copy(pkt[20:], icmp)
+	writePcap(t, pkt)
+}
+
+// 取出地址中的IPv4地址，无法取得时为0.0.0.0
+func addrIP4(a net.Addr) net.IP {
+	var ip net.IP
+	switch a := a.(type) {
+	case *net.IPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return net.IPv4zero.To4()
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 写入两个报文后按pcap格式逐字段检查文件：文件头、记录头的时间戳及长度、补上的IPv4头
+func TestPcapFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.pcap")
+	if err := startPcap(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pcapw = nil })
+
+	sentAt := time.Date(2024, 3, 1, 9, 0, 0, 123456789, time.UTC)
+	recvAt := sentAt.Add(1500 * time.Microsecond)
+	echo, err := buildEcho(7, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &net.IPAddr{IP: net.IPv4(192, 0, 2, 2)}
+	dst := &net.IPAddr{IP: net.IPv4(192, 0, 2, 1)}
+	writePcapSent(sentAt, src, dst, echo)
+	conn := newMockConn()
+	conn.Write(echo)
+	reply := append([]byte(nil), conn.reply...) //以IP头开始的回显应答
+	writePcap(recvAt, reply)
+	stopPcap()
+	writePcap(recvAt, reply) //关闭后写入的报文被丢弃
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) < pcapFileHdrLen {
+		t.Fatalf("文件只有 %d 字节", len(data))
+	}
+	le := binary.LittleEndian
+	hdr := data[:pcapFileHdrLen]
+	if m := le.Uint32(hdr[0:4]); m != 0xa1b23c4d {
+		t.Errorf("magic = %#x，期望纳秒精度的 0xa1b23c4d", m)
+	}
+	if maj, min := le.Uint16(hdr[4:6]), le.Uint16(hdr[6:8]); maj != 2 || min != 4 {
+		t.Errorf("版本 = %d.%d，期望 2.4", maj, min)
+	}
+	if snap, link := le.Uint32(hdr[16:20]), le.Uint32(hdr[20:24]); snap != 65536 || link != 101 {
+		t.Errorf("snaplen = %d，linktype = %d，期望 65536、101(LINKTYPE_RAW)", snap, link)
+	}
+
+	var pkts [][]byte
+	for rest := data[pcapFileHdrLen:]; len(rest) > 0; {
+		if len(rest) < pcapRecordHdrLen {
+			t.Fatalf("记录头被截断: 剩余 %d 字节", len(rest))
+		}
+		sec, nsec := le.Uint32(rest[0:4]), le.Uint32(rest[4:8])
+		incl, orig := le.Uint32(rest[8:12]), le.Uint32(rest[12:16])
+		if incl != orig {
+			t.Errorf("记录 %d 的 incl_len %d != orig_len %d", len(pkts), incl, orig)
+		}
+		want := []time.Time{sentAt, recvAt}
+		if i := len(pkts); i < len(want) && !time.Unix(int64(sec), int64(nsec)).Equal(want[i]) {
+			t.Errorf("记录 %d 的时间 = %v，期望 %v", i, time.Unix(int64(sec), int64(nsec)).UTC(), want[i])
+		}
+		rest = rest[pcapRecordHdrLen:]
+		if int(incl) > len(rest) {
+			t.Fatalf("记录 %d 长度 %d 超出文件", len(pkts), incl)
+		}
+		pkts = append(pkts, rest[:incl])
+		rest = rest[incl:]
+	}
+	if len(pkts) != 2 {
+		t.Fatalf("文件中有 %d 个报文，期望 2", len(pkts))
+	}
+
+	sent := pkts[0]
+	if len(sent) != 20+len(echo) || sent[0] != 0x45 || sent[9] != 1 {
+		t.Fatalf("发送的报文IP头不正确: % x", sent[:20])
+	}
+	if n := binary.BigEndian.Uint16(sent[2:4]); int(n) != len(sent) {
+		t.Errorf("IP总长度 = %d，期望 %d", n, len(sent))
+	}
+	if sum, _ := checkSum(sent[:20]); sum != 0 {
+		t.Errorf("IP头检验和错误")
+	}
+	if !net.IP(sent[12:16]).Equal(src.IP) || !net.IP(sent[16:20]).Equal(dst.IP) {
+		t.Errorf("地址 = %v -> %v", net.IP(sent[12:16]), net.IP(sent[16:20]))
+	}
+	if !bytes.Equal(sent[20:], echo) {
+		t.Errorf("ICMP部分与发送的报文不同")
+	}
+	if !bytes.Equal(pkts[1], reply) {
+		t.Errorf("收到的报文与写入的不同")
+	}
+}
+
+// 依次返回packets中的报文，用完后读取超时
+type packetsConn struct {
+	*mockConn
+	packets [][]byte
+}
+
+func (c *packetsConn) Read(b []byte) (int, error) {
+	if len(c.packets) == 0 {
+		return 0, os.ErrDeadlineExceeded
+	}
+	n := copy(b, c.packets[0])
+	c.packets = c.packets[1:]
+	return n, nil
+}
+
+// 等待应答时跳过的报文(本机自己的请求、ID或序号不同的应答)也写入pcap文件
+func TestPcapSkippedPackets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.pcap")
+	if err := startPcap(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pcapw = nil })
+
+	data := make([]byte, 8+8)
+	if err := fillEcho(data, 3); err != nil {
+		t.Fatal(err)
+	}
+	conn := &packetsConn{mockConn: newMockConn()}
+	conn.mockConn.Write(data)
+	reply := append([]byte(nil), conn.reply...)
+	request := append([]byte(nil), reply...)
+	request[20] = 8 //回环时收到的自己的请求
+	otherID := append([]byte(nil), reply...)
+	otherID[24]++
+	otherSeq := append([]byte(nil), reply...)
+	otherSeq[27]++
+	conn.packets = [][]byte{request, otherID, otherSeq, reply}
+
+	buf := make([]byte, 1500)
+	n, _, _, err := exchange(conn, nil, data, time.Second, buf, true)
+	if err != nil || !bytes.Equal(buf[:n], reply) {
+		t.Fatalf("exchange = % x, %v，期望收到匹配的应答", buf[:n], err)
+	}
+	stopPcap()
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pkts [][]byte
+	for rest := raw[pcapFileHdrLen:]; len(rest) >= pcapRecordHdrLen; {
+		incl := int(binary.LittleEndian.Uint32(rest[8:12]))
+		pkts = append(pkts, rest[pcapRecordHdrLen:pcapRecordHdrLen+incl])
+		rest = rest[pcapRecordHdrLen+incl:]
+	}
+	want := [][]byte{nil, request, otherID, otherSeq, reply} //第一个为发送的请求
+	if len(pkts) != len(want) {
+		t.Fatalf("文件中有 %d 个报文，期望 %d", len(pkts), len(want))
+	}
+	if !bytes.Equal(pkts[0][20:], data) {
+		t.Errorf("第一个报文不是发送的请求: % x", pkts[0])
+	}
+	for i := 1; i < len(want); i++ {
+		if !bytes.Equal(pkts[i], want[i]) {
+			t.Errorf("报文 %d = % x，期望 % x", i, pkts[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,26 @@
+//go:build darwin || freebsd || netbsd || openbsd
+
+package main
+
+import "net"
+
+// macOS及BSD上Dial("ip4:icmp")失败时改用icmp.ListenPacket建立的listenConn，以WriteTo发往目标
+func dialICMPFallback(host string, dialErr error) (net.Conn, error) {
+	if isPermissionError(dialErr) {
+		return nil, dialErr //没有root权限时两种方式都无法创建原始套接字
+	}
+	conn, err := listenICMP(host)
+	if err != nil {
+		return nil, dialErr
+	}
+	return conn, nil
+}
+
+func isPlatformPermissionError(err error) bool {
+	return false
+}
+
+// 权限不足时的解决办法
+func permissionHint() string {
+	return "发送ICMP需要root权限，请使用 sudo 重试。"
+}
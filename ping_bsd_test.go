@@ -0,0 +1,41 @@
+//go:build darwin || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// Dial失败后改用的listenConn收到的应答带IP头，按Pinger.Run的方式解析
+func TestDialFallbackReply(t *testing.T) {
+	needRawSocket(t)
+	conn, err := dialICMPFallback("127.0.0.1", errors.New("dial ip4:icmp: 不支持"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, ok := conn.(*listenConn); !ok {
+		t.Fatalf("dialICMPFallback 返回 %T，应为 *listenConn", conn)
+	}
+
+	data := make([]byte, 8+16)
+	if err := fillEcho(data, 3); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1500)
+	n, _, _, err := exchange(conn, nil, data, 2*time.Second, buf, true)
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	r, err := parseEchoReply(buf[:n])
+	if err != nil {
+		t.Fatalf("parseEchoReply: %v", err)
+	}
+	//TTL来自IP头，icmp.PacketConn.ReadFrom会去掉IP头
+	if !r.Src.Equal(net.IPv4(127, 0, 0, 1)) || r.Seq != 3 || r.Bytes != 16 || r.TTL == 0 {
+		t.Fatalf("应答 = %+v", r)
+	}
+}
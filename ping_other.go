@@ -0,0 +1,27 @@
+//go:build !(windows || darwin || freebsd || netbsd || openbsd)
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// 其他平台上Dial("ip4:icmp")失败时没有替代方式
+func dialICMPFallback(host string, dialErr error) (net.Conn, error) {
+	return nil, dialErr
+}
+
+func isPlatformPermissionError(err error) bool {
+	return false
+}
+
+// 权限不足时的解决办法：以root运行，或为程序授予CAP_NET_RAW
+func permissionHint() string {
+	path, err := os.Executable()
+	if err != nil {
+		path = os.Args[0]
+	}
+	return fmt.Sprintf("请以root身份运行，或执行 sudo setcap cap_net_raw+ep %s 为程序授予创建原始套接字的权限。", path)
+}
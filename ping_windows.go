@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+const wsaeacces = syscall.Errno(10013) //WSAEACCES：没有创建原始套接字的权限
+
+// Windows上Dial("ip4:icmp")失败时改用icmp.ListenPacket建立的listenConn
+func dialICMPFallback(host string, dialErr error) (net.Conn, error) {
+	if isPermissionError(dialErr) {
+		return nil, dialErr //没有管理员权限时两种方式都无法创建原始套接字
+	}
+	conn, err := listenICMP(host)
+	if err != nil {
+		return nil, dialErr
+	}
+	return conn, nil
+}
+
+func isPlatformPermissionError(err error) bool {
+	return errors.Is(err, wsaeacces)
+}
+
+// 权限不足时的解决办法
+func permissionHint() string {
+	return "Windows上发送ICMP需要管理员权限，请在“以管理员身份运行”的命令提示符中重试。"
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Pinger 对单个目标执行一轮ping
+type Pinger struct {
+	Arg      string            //命令行或配置文件中的原始目标
+	Labels   map[string]string //目标的标签，来自配置文件或命令行 host=label
+	Timeout  int64             //超时时间(毫秒)
+	Count    int               //请求次数
+	Size     int               //缓冲区大小
+	Interval int64             //两次请求的间隔(毫秒)
+	Quiet    bool              //不输出逐条回复及统计信息，由调用方汇总输出
+
+	Host  string      //规范化后的主机
+	Addr  string      //目标IP，连接建立后填写
+	Err   error       //无法开始ping的原因
+	Stats *Statistics //统计数据
+
+	ttlWindow []int    //最近若干次回复的TTL，用于检测非对称路由
+	backoff   *backoff //-backoff 时的间隔控制
+	backedOff bool     //是否曾因连续失败延长间隔
+
+	responders  responderAnalyzer //检测是否有多台主机应答同一地址
+	ttls        ttlTracker        //回复TTL的变化，用于发现路径改变
+	macs        macTracker        //-mac 时回复者MAC地址的变化
+	errs        errCoalescer      //连续重复的ICMP差错只输出一次
+	kernelDrops int               //结束时读取的内核丢弃数(接收缓冲区溢出)
+	ecn         *ecnStats         //-ecn 时回复中ECN标记的统计
+	cycle       cycleState        //-cycle-period 时当前周期的状态
+	rto         rttEstimator      //-adaptive-timeout 时的往返时间估计
+	done        <-chan struct{}   //关闭后停止发送，RunUntilLoss中为ctx.Done()
+	until       func() bool       //每次请求前调用，返回true时停止
+	dual        *dualPeer         //-dual-ended 时与代理的连接
+	dualLost    []int             //-dual-ended 时超时的请求序号
+	outage      bool              //已写入outage_start事件，尚未写入outage_end
+
+	conn   net.Conn //PingOnce使用的连接，Run建立后交给PingOnce，Close时关闭
+	tsc    *tsConn  //-hw-ts 时读取收发时间戳
+	strict bool     //-strict 时ID、序号不同的应答作为异常返回
+	req    []byte   //请求报文，每次请求复用
+	rbuf   *[]byte  //接收缓冲区，Close时放回recvBufPool
+}
+
+// 以命令行参数为默认值创建Pinger
+// 命令行目标的单独参数(host,w=200)优先于命令行参数
+func newPinger(arg string) *Pinger {
+	p := &Pinger{
+		Arg:      arg,
+		Labels:   targetLabels[arg],
+		Timeout:  timeout,
+		Count:    count,
+		Size:     size,
+		Interval: interval,
+		Stats:    newStatistics(),
+	}
+	if opts, ok := targetOptions[arg]; ok {
+		opts.override(p)
+	}
+	return p
+}
+
+// 记录无法开始ping的原因，同时写入统计数据以便探测期间安全读取
+func (p *Pinger) fail(err error) {
+	p.Err = err
+	p.Stats.setError(err)
+}
+
+// 输出逐条信息，Quiet时不输出
+func (p *Pinger) printf(format string, a ...any) {
+	if !p.Quiet {
+		p.errs.interrupt()
+		fmt.Printf(format, a...)
+	}
+}
+
+// Run 执行ping并输出统计信息
+func (p *Pinger) Run() {
+	arg := p.Arg
+	p.Host = icmpHost(arg)
+	host := p.Host
+	if skip, err := dnsFailures.skip(arg); skip {
+		p.fail(err) //已标记为无法解析，未到重试时间
+		return
+	}
+	conn, err := dialICMP(host, time.Duration(p.Timeout)*time.Millisecond) //毫秒
+	if err != nil {
+		marked := dnsFailures.unresolvable(arg)
+		p.fail(err)
+		if !marked {
+			p.printf("%s", dialErrorText(host, err))
+		}
+		announceDNSEvent(arg, p.Labels, dnsFailures.observe(arg, err), err)
+		return
+	}
+	announceDNSEvent(arg, p.Labels, dnsFailures.observe(arg, nil), nil)
+	p.conn = conn
+	defer p.Close() //p.conn可能被替换为io_uring连接
+	p.Addr = conn.RemoteAddr().String()
+
+	if len(sourceRoute) > 0 {
+		if err := setIPOptions(conn, buildLSRROption(sourceRoute)); err != nil {
+			p.fail(err)
+			p.printf("无法设置源路由: %v\n", err)
+			return
+		}
+	}
+	if sendTTL > 0 {
+		if err := setTTL(conn, sendTTL); err != nil {
+			p.fail(err)
+			p.printf("无法设置TTL: %v\n", err)
+			return
+		}
+	}
+	if rcvBuf > 0 {
+		p.applyRcvBuf(conn)
+	}
+	if ecnMode != "" {
+		cp, _ := parseECN(ecnMode) //已在getArgs中校验
+		if err := setTOS(conn, cp); err != nil {
+			p.fail(err)
+			p.printf("无法设置ECN标记: %v\n", err)
+			return
+		}
+		p.ecn = &ecnStats{sent: cp}
+	}
+	sock := conn //io_uring、XDP会替换conn，丢包计数从原始套接字读取
+	if useEBPF {
+		if xc, err := newXDPConn(conn); err != nil {
+			p.printf("无法使用XDP接收应答，改用标准socket: %v\n", err)
+		} else {
+			conn = xc
+			p.conn = xc
+		}
+	}
+	if useIOUring {
+		if uc, err := newURingConn(conn); err != nil {
+			p.printf("io_uring 不可用，改用标准socket: %v\n", err)
+		} else {
+			conn = uc
+			p.conn = uc
+		}
+	}
+
+	defer interruptOnStop(conn)()
+
+	var tsc *tsConn //-hw-ts 时读取收发时间戳
+	if hwTS {
+		if tsc, err = newTSConn(conn, host); err != nil {
+			p.printf("无法开启时间戳，改用系统时钟计时: %v\n", err)
+		}
+	}
+	p.tsc, p.strict = tsc, strictMode
+
+	extra := ""
+	if tsc != nil {
+		extra += " (" + tsc.mode() + ")"
+	}
+	if sendTTL > 0 {
+		extra += fmt.Sprintf(" (发送TTL=%d)", sendTTL)
+	}
+	if tc := trafficClassText(p.ecn); tc != "" {
+		extra += " (" + tc + ")"
+	}
+	if sizeAuto {
+		n, iface, err := sizeFromMTU(host)
+		if err != nil {
+			p.fail(err)
+			p.printf("无法根据MTU计算数据长度: %v\n", err)
+			return
+		}
+		p.Size = n
+		extra += fmt.Sprintf(" (接口 %s MTU=%d)", iface.Name, iface.MTU)
+	}
+	if host != arg {
+		extra += " (输入: " + arg + ")"
+	}
+	extra += labelSuffix(p.Labels)
+	p.printf("正在 Ping %s [%s]%s 具有 %d 字节的数据：\n", host, conn.RemoteAddr(), extra, p.Size)
+
+	base := p.Stats.Snapshot().Sent //-state 恢复时序号接着上次继续
+	timeouts := 0                   //连续超时次数
+	for i := 0; forever || i < p.Count; i++ {
+		if !p.nextProbe(i) {
+			break
+		}
+
+		seq := base + i
+		wait := p.probeTimeout() //本次请求的超时时间
+
+		if chaosDrop() {
+			//模拟发送端丢包：不发送，按超时处理
+			tStart := time.Now()
+			p.Stats.addSent()
+			tSpend := p.chaosWait(wait).Milliseconds()
+			p.Stats.addChaosDrop()
+			p.rto.timedOut()
+			p.Stats.addTimeout()
+			p.Stats.addFailure()
+			p.printf("请求超时。\n")
+			recordProbe(probeSpan{target: host, labels: p.Labels, seq: seq, start: tStart, end: time.Now(), rtt: tSpend, timeout: wait, outcome: "chaos_drop"})
+			continue
+		}
+
+		//发送、接收及统计由PingOnce完成，这里只负责输出
+		r, err := p.PingOnce(context.Background())
+		buf, tStart := r.pkt, r.start
+		var sendErr *sendError
+		if errors.As(err, &sendErr) {
+			p.printf("请求失败: %s。\n", sendErrorText(err))
+			recordProbe(probeSpan{target: host, labels: p.Labels, seq: seq, start: tStart, end: time.Now(), timeout: wait, outcome: "send_error"})
+			continue
+		}
+
+		//计算时间
+		rtt := r.RTT
+		if err != nil {
+			rtt = r.elapsed
+		}
+		tSpend := rtt.Milliseconds()
+
+		if buf == nil {
+			p.printf("请求超时。\n")
+			if !p.Quiet {
+				p.printProbeTimeout(wait)
+			}
+			p.rto.timedOut()
+			if p.dual != nil {
+				p.dualLost = append(p.dualLost, seq)
+			}
+			recordProbe(probeSpan{target: host, labels: p.Labels, seq: seq, start: tStart, end: time.Now(), rtt: tSpend, timeout: wait, outcome: "timeout"})
+			timeouts++
+			if len(sourceRoute) > 0 && timeouts == srcRouteHint {
+				p.printf("提示: 多数网络会丢弃带源路由选项的报文，请求可能被网络丢弃。\n")
+			}
+			continue
+		}
+		timeouts = 0
+		if err != nil {
+			outcome, anomaly := "error", ""
+			var a *replyAnomaly
+			var re *replyError
+			switch {
+			case errors.As(err, &a):
+				p.Stats.addAnomaly(a.kind)
+				p.printf("协议异常: %s\n", a.reason)
+				outcome, anomaly = "anomaly", a.kind
+			case errors.As(err, &re) && re.kind == replyICMPError:
+				p.printICMPError(re.typ, re.code, net.IP(buf[12:16]).String())
+			case errors.As(err, &re) && re.kind == replyChecksum:
+				p.Stats.addChecksumError()
+				p.printf("来自 %d.%d.%d.%d%s 的回复: 校验和错误\n", buf[12], buf[13], buf[14], buf[15], labelSuffix(p.Labels))
+			default:
+				p.printf("%v\n", err) //IP头无效或过短，限速的路由器可能返回截断的ICMP报文
+			}
+			recordProbe(probeSpan{target: host, labels: p.Labels, seq: seq, start: tStart, end: time.Now(), rtt: tSpend, timeout: wait, outcome: outcome, anomaly: anomaly})
+			continue
+		}
+		rttText := fmt.Sprintf("%dms", tSpend)
+		if tsc != nil {
+			rttText = fmt.Sprintf("%.3fms", float64(rtt)/float64(time.Millisecond)) //时间戳精度足够显示到微秒
+		}
+		payload := r.Bytes       //回显的数据长度，与发送的 -l 一致
+		var mac net.HardwareAddr //-mac 时直连回复者的MAC地址
+		if showMAC {
+			mac, _ = neighborCacheMAC(net.IP(buf[12:16]))
+		}
+		//buf[8] 是IP头中的TTL(ICMP头中没有TTL)，已由checkIPv4Header保证在范围内
+		p.printf("来自 %d.%d.%d.%d%s%s 的回复: 字节=%d 时间=%s TTL=%s\n", buf[12], buf[13], buf[14], buf[15], macSuffix(mac), labelSuffix(p.Labels), payload, rttText, ttlText(int(buf[8])))
+		if mac != nil {
+			if prev, changed := p.macs.observe(mac); changed {
+				p.printf("警告: %d.%d.%d.%d 的MAC地址由 %s 变为 %s，可能是ARP欺骗或HA切换异常！\n", buf[12], buf[13], buf[14], buf[15], prev, mac)
+			}
+		}
+		if payload != p.Size {
+			p.printf("警告: 回复中的数据为 %d 字节，发送的是 %d 字节\n", payload, p.Size)
+		}
+		var ecnReply int
+		if p.ecn != nil {
+			ecnReply = p.ecn.observe(buf[1]) //buf[1] 为IP头中的TOS
+		}
+		if !p.Quiet {
+			if verbose {
+				p.printProbeTimeout(wait)
+				if p.ecn != nil {
+					p.printf("    ECN: 发送 %s，回复 %s\n", ecnNames[p.ecn.sent], ecnNames[ecnReply])
+				}
+				printReplyOptions(buf)
+				if veryVerbose {
+					printReplyDump(buf, dumpMax)
+				}
+			} else if len(sourceRoute) > 0 {
+				printReplyRoute(buf)
+			}
+		}
+
+		recordProbe(probeSpan{target: host, labels: p.Labels, seq: seq, start: tStart, end: time.Now(), rtt: tSpend, ttl: int(buf[8]), timeout: wait, outcome: "success"})
+		p.rto.update(rtt)
+
+		if from, to, changed := p.ttls.observe(int(buf[8])); changed {
+			p.printf("TTL 由 %d 变为 %d，路径可能已改变\n", from, to)
+			recordPathChange(host, p.Labels, seq, from, to)
+		}
+		if asymDetect {
+			p.checkAsymRoute(int(buf[8]))
+		}
+		if p.responders.observe(int(buf[8]), string(buf[len(buf)-payload:]) == string(p.req[8:])) {
+			p.printf("警告: %s 可能有多台主机在应答(地址冲突或HA切换异常)，详见统计信息。\n", host)
+		}
+	}
+
+	if n, err := socketDrops(sock); err == nil {
+		p.kernelDrops = n
+	}
+	p.printSummary()
+}
+
+// 校验收到的报文以合法的IPv4头开始：版本为4，头长度不小于20字节且不超过报文长度
+func checkIPv4Header(pkt []byte) error {
+	if len(pkt) < 20 {
+		return fmt.Errorf("报文只有 %d 字节，不足IPv4头长度", len(pkt))
+	}
+	if v := pkt[0] >> 4; v != 4 {
+		return fmt.Errorf("IP版本为 %d，不是IPv4", v)
+	}
+	if ihl := int(pkt[0]&0x0f) * 4; ihl < 20 || ihl > len(pkt) {
+		return fmt.Errorf("IP头长度 %d 非法(报文 %d 字节)", ihl, len(pkt))
+	}
+	return nil
+}
+
+// 是否为ICMP回显请求(类型8)
+func isEchoRequest(pkt []byte) bool {
+	if checkIPv4Header(pkt) != nil {
+		return false
+	}
+	ihl := int(pkt[0]&0x0f) * 4
+	return len(pkt) >= ihl+8 && pkt[ihl] == 8
+}
+
+// 输出统计信息
+func (p *Pinger) printSummary() {
+	p.noteOutage() //最后一次请求的结果可能改变了状态
+	ss := p.Stats.Snapshot()
+	if ss.Sent == 0 {
+		return
+	}
+
+	name := p.Addr
+	name += labelSuffix(p.Labels)
+	p.printf("\n%s 的 Ping 统计信息:\n", name)
+	if len(targetOptions) > 0 || configPath != "" {
+		p.printf("    参数: %s\n", p.optionsText())
+	}
+	p.printf("    数据包: 已发送 = %d，已接收 = %d，丢失 = %d (%.2f%% 丢失)，\n", ss.Sent, ss.Received, ss.Lost, ss.LossPercent())
+	if ss.Received > 0 {
+		p.printf("往返行程的估计时间(以毫秒为单位):\n    最短 = %dms，最长 = %dms，平均 = %dms\n", ss.Min, ss.Max, ss.Avg())
+		if trimOutliers {
+			if t, ok := trimRTT(p.Stats.sampleList()); ok {
+				p.printf("    去除离群值后(%s，去除 %d 个): 最短 = %dms，最长 = %dms，平均 = %dms\n", trimMethodText(), t.dropped, t.min, t.max, t.avg)
+			}
+		}
+	} else {
+		p.printf("往返行程的估计时间(以毫秒为单位):\n    最短 = -，最长 = -，平均 = -\n")
+	}
+	if len(ss.Anomalies) > 0 {
+		p.printf("协议异常(-strict):\n")
+		for _, kind := range anomalyKinds {
+			if n := ss.Anomalies[kind]; n > 0 {
+				p.printf("    %s = %d\n", anomalyNames[kind], n)
+			}
+		}
+	}
+	p.printErrorSummary()
+	if p.macs.changes > 0 {
+		p.printf("    警告: 回复者的MAC地址变化了 %d 次(最后为%s)。\n", p.macs.changes, macSuffix(p.macs.last))
+	}
+	if s := p.ttls.summary(); s != "" {
+		p.printf("    回复TTL: %s\n", s)
+	}
+	if ev := p.responders.evidence(); len(ev) > 0 {
+		p.printf("警告: 可能有多台主机使用该地址:\n")
+		for _, line := range ev {
+			p.printf("    %s\n", line)
+		}
+	}
+	if ss.ChaosDropped > 0 {
+		p.printf("    其中 %d 个请求被 -chaos-loss 丢弃，并未发送。\n", ss.ChaosDropped)
+	}
+	if p.ecn != nil {
+		p.printf("    %s。\n", p.ecn.summary())
+		if n := p.ecn.congested(); n > 0 {
+			p.printf("    警告: CONGESTION EXPERIENCED (%d 个回复被标记为CE)，路径上的主动队列管理(如RED、CoDel)已在丢包前标记拥塞。\n", n)
+		}
+	}
+	if ss.SendErrors > 0 {
+		//本机无法发送与路径丢包分开显示
+		p.printf("    其中 %d 个请求本机发送失败(最近一次: %s)，并非路径丢失。\n", ss.SendErrors, ss.LastSendError)
+		p.printf("    其中 %d 个请求超时。\n", ss.Timeouts)
+	}
+	if p.dual != nil {
+		p.printLossDirection()
+	}
+	if ss.ChecksumErrors > 0 {
+		p.printf("    其中 %d 个回复校验和错误。\n", ss.ChecksumErrors)
+	}
+	if p.kernelDrops > 0 {
+		p.printf("    内核丢弃 %d 个数据包(接收缓冲区溢出)，这部分丢失不是路径丢失，可用 -rcvbuf 增大接收缓冲区。\n", p.kernelDrops)
+	}
+	if p.backedOff {
+		p.printf("    注: 连续失败期间请求间隔曾被延长，发送频率并不均匀，丢失率按实际发送的请求计算。\n")
+	}
+	if slaRTT > 0 {
+		p.printSLA()
+	}
+	if forever {
+		p.printAvailability(ss.Avail)
+		recordAvailability(p.Host, p.Labels, ss.Avail, true)
+	}
+}
+
+// 输出可用性统计
+func (p *Pinger) printAvailability(av AvailSnapshot) {
+	p.printf("可用性:\n    运行时长 = %s，离线次数 = %d，离线时长 = %s，最长离线 = %s，可用率 = %.3f%%\n",
+		av.Runtime.Round(time.Millisecond), av.Outages, av.Downtime.Round(time.Millisecond), av.Longest.Round(time.Millisecond), av.Percent())
+}
+
+// 离线或恢复后写入outage_start/outage_end事件
+// 每次请求前及结束时调用，事件时间取状态变化的时间，结束时仍离线的只有outage_start
+func (p *Pinger) noteOutage() {
+	av := p.Stats.Snapshot().Avail
+	if av.Known && av.Down != p.outage {
+		p.outage = av.Down
+		recordOutage(p.Host, p.Labels, av)
+	}
+}
+
+// 检测非对称路由
+// 记录最近ttlWindowSize次回复的TTL，窗口填满后计算方差
+// 方差超过阈值说明回程报文经过了不同的路径（ECMP负载均衡或路由抖动）
+func (p *Pinger) checkAsymRoute(ttl int) {
+	p.ttlWindow = append(p.ttlWindow, ttl)
+	if len(p.ttlWindow) > ttlWindowSize {
+		p.ttlWindow = p.ttlWindow[1:]
+	}
+	if len(p.ttlWindow) < ttlWindowSize {
+		return
+	}
+
+	variance := ttlVariance(p.ttlWindow)
+	if variance > ttlVarianceMax {
+		p.printf("ASYMMETRIC ROUTING DETECTED (TTL variance: %.2f)\n", variance)
+	}
+}
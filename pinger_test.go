@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// 回复中的字节数为回显的数据长度，与 -l 一致时不警告
+func TestReplyByteCount(t *testing.T) {
+	needRawSocket(t)
+	for _, size := range []string{"0", "32", "1000"} {
+		parseArgs(t, "-n", "1", "-l", size, "127.0.0.1")
+		stdout, _ := captureOutput(t, func() { newPinger("127.0.0.1").Run() })
+		if !strings.Contains(stdout, "字节="+size+" ") || strings.Contains(stdout, "警告") {
+			t.Errorf("-l %s 的输出:\n%s", size, stdout)
+		}
+	}
+}
+
+func TestCheckIPv4Header(t *testing.T) {
+	hdr := []byte{0x45, 0, 0, 28, 0, 0, 0, 0, 64, 1, 0, 0, 127, 0, 0, 1, 127, 0, 0, 1}
+	reply := append(append([]byte(nil), hdr...), 0, 0, 0, 0, 0, 0, 0, 0)
+	withOpts := append([]byte{0x46}, reply[1:]...)
+	tests := []struct {
+		name string
+		pkt  []byte
+		err  string //空表示合法
+	}{
+		{"回显应答", reply, ""},
+		{"只有IP头", hdr, ""},
+		{"带选项", append(withOpts, 0, 0, 0, 0), ""},
+		{"空报文", nil, "报文只有 0 字节"},
+		{"不足IP头", reply[:19], "报文只有 19 字节"},
+		{"IPv6", append([]byte{0x60}, reply[1:]...), "IP版本为 6"},
+		{"头长度过小", append([]byte{0x44}, reply[1:]...), "IP头长度 16 非法"},
+		{"头长度超出报文", append([]byte{0x4f}, reply[1:]...), "IP头长度 60 非法(报文 28 字节)"},
+	}
+	for _, tt := range tests {
+		err := checkIPv4Header(tt.pkt)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: checkIPv4Header = %v，期望 %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+// 目标为本机时原始套接字会收到自己发出的回显请求
+func TestIsEchoRequest(t *testing.T) {
+	req, err := buildEcho(1, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := newMockConn()
+	conn.Write(req)
+	reply := append([]byte(nil), conn.reply...)
+	own := append([]byte(nil), reply...)
+	own[20] = 8
+	tests := []struct {
+		name string
+		pkt  []byte
+		want bool
+	}{
+		{"回显请求", own, true},
+		{"回显应答", reply, false},
+		{"截断的回显请求", own[:27], false},
+		{"不是IPv4", append([]byte{0x60}, own[1:]...), false},
+		{"空报文", nil, false},
+	}
+	for _, tt := range tests {
+		if got := isEchoRequest(tt.pkt); got != tt.want {
+			t.Errorf("%s: isEchoRequest = %v，期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 正确的回复对整个ICMP报文求检验和结果为0，任何一位出错都不为0
+func TestReplyChecksum(t *testing.T) {
+	req, err := buildEcho(3, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := newMockConn()
+	conn.Write(req)
+	if sum, _ := checkSum(conn.reply[20:]); sum != 0 {
+		t.Errorf("正确的回复检验和 = %#04x", sum)
+	}
+	for _, i := range []int{20, 24, 27, len(conn.reply) - 1} {
+		bad := append([]byte(nil), conn.reply...)
+		bad[i] ^= 0x01
+		if sum, _ := checkSum(bad[20:]); sum == 0 {
+			t.Errorf("第 %d 字节出错时检验和仍为0", i)
+		}
+	}
+}
+
+// 检验和错误的回复计为失败，统计信息中单独列出
+func TestChecksumErrorSummary(t *testing.T) {
+	p := &Pinger{Addr: "127.0.0.1", Stats: newStatistics()}
+	for i := 0; i < 3; i++ {
+		p.Stats.addSent()
+		if i == 0 {
+			p.Stats.addSuccess(1)
+			continue
+		}
+		p.Stats.addChecksumError()
+		p.Stats.addFailure()
+	}
+	if ss := p.Stats.Snapshot(); ss.ChecksumErrors != 2 || ss.Lost != 2 || ss.Received != 1 {
+		t.Errorf("统计 = %+v", ss)
+	}
+	stdout, _ := captureOutput(t, p.printSummary)
+	if !strings.Contains(stdout, "其中 2 个回复校验和错误。") {
+		t.Errorf("输出:\n%s", stdout)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// 一次请求的处理：更新序号、收发、解析应答，与Pinger.Run的每次循环相同
+func benchmarkPingLoop(b *testing.B, pooled bool) {
+	const size = 32
+	conn := newMockConn()
+	data := make([]byte, 8+size)
+	if err := fillEcho(data, 0); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		refillEcho(data, i)
+		var bufp *[]byte
+		var buf []byte
+		if pooled {
+			bufp = recvBufPool.Get().(*[]byte)
+			buf = *bufp
+		} else {
+			buf = make([]byte, 1<<16)
+		}
+		if _, _, _, err := echo(conn, nil, data, time.Second, buf, false); err != nil {
+			b.Fatal(err)
+		}
+		if pooled {
+			recvBufPool.Put(bufp)
+		}
+	}
+}
+
+func BenchmarkPingLoop(b *testing.B) {
+	b.Run("pool", func(b *testing.B) { benchmarkPingLoop(b, true) })
+	b.Run("alloc", func(b *testing.B) { benchmarkPingLoop(b, false) })
+}
+
+// 请求头的序列化：binary.Write 与预先分配的缓冲区上的 ICMP.Marshal
+func BenchmarkEchoHeader(b *testing.B) {
+	icmp := ICMP{Type: 8, ID: echoID}
+	b.Run("binary.Write", func(b *testing.B) {
+		var buf bytes.Buffer
+		b.SetBytes(8)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf.Reset()
+			icmp.SeqNum = uint16(i)
+			binary.Write(&buf, binary.BigEndian, icmp)
+		}
+	})
+	b.Run("Marshal", func(b *testing.B) {
+		pkt := make([]byte, 8)
+		b.SetBytes(8)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			icmp.SeqNum = uint16(i)
+			icmp.Marshal(pkt)
+		}
+	})
+}
+
+// 每次请求复用同一个报文缓冲区：写入ICMP头、计算或增量调整检验和都不分配内存
+func TestEchoHotPathAllocs(t *testing.T) {
+	for _, size := range []int{0, 32, 1472, 65507} {
+		pkt := make([]byte, 8+size)
+		for i := range pkt[8:] {
+			pkt[8+i] = byte(i)
+		}
+		seq := 0
+		tests := []struct {
+			name string
+			fn   func()
+		}{
+			{"Marshal", func() { seq++; (&ICMP{Type: 8, ID: echoID, SeqNum: uint16(seq)}).Marshal(pkt) }},
+			{"fillEcho", func() { seq++; fillEcho(pkt, seq) }},
+			{"refillEcho", func() { seq++; refillEcho(pkt, seq) }},
+		}
+		for _, tt := range tests {
+			if allocs := testing.AllocsPerRun(100, tt.fn); allocs != 0 {
+				t.Errorf("%s(-l %d) 每次分配 %.1f 次，应为0", tt.name, size, allocs)
+			}
+		}
+		//最后一次写入的报文仍然有效
+		if err := fillEcho(pkt, seq); err != nil {
+			t.Fatal(err)
+		}
+		if sum, _ := checkSum(pkt); sum != 0 || binary.BigEndian.Uint16(pkt[6:8]) != uint16(seq) {
+			t.Errorf("-l %d: 检验和 %#04x，序号 %d", size, sum, binary.BigEndian.Uint16(pkt[6:8]))
+		}
+	}
+}
+
+// 性能下限，见BENCHMARKS.md：设置 PING_BENCH_MIN_PPS(每秒请求数)时检查，CI中设为50000
+func TestPingLoopThroughput(t *testing.T) {
+	v := os.Getenv("PING_BENCH_MIN_PPS")
+	if v == "" {
+		t.Skip("未设置 PING_BENCH_MIN_PPS")
+	}
+	min, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		t.Fatalf("PING_BENCH_MIN_PPS=%q: %v", v, err)
+	}
+	r := testing.Benchmark(func(b *testing.B) { benchmarkPingLoop(b, true) })
+	pps := float64(time.Second) / float64(r.NsPerOp())
+	t.Logf("%s，%.0f 次/秒", r, pps)
+	if pps < min {
+		t.Fatalf("每秒 %.0f 次请求，低于下限 %.0f", pps, min)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// 路径MTU探测相关常量
+const (
+	pmtudStartSize = 1472 //以太网MTU 1500 减去 IP头(20) 和 ICMP头(8)
+	pmtudPrecision = 8    //成功与失败的数据长度相差不超过8字节时认为已收敛
+	ipICMPHdrLen   = 28   //IP头 + ICMP头
+)
+
+var pmtud bool //是否探测路径MTU
+
+// 探测结果
+const (
+	probeOK = iota
+	probeFragNeeded
+	probeTimeout
+)
+
+// 以二分法逐步调整数据长度，探测到目标的路径MTU
+// 发送的报文设置了DF标志，超出路径MTU时会收到“需要分片”(type 3 code 4)或超时
+func discoverPMTU(arg string) {
+	host := icmpHost(arg)
+	conn, err := dialICMP(host, time.Duration(timeout)*time.Millisecond)
+	if err != nil {
+		fmt.Print(dialErrorText(host, err))
+		return
+	}
+	defer conn.Close()
+
+	if err := setDontFragment(conn); err != nil {
+		fmt.Printf("无法设置DF标志: %v\n", err)
+		return
+	}
+
+	fmt.Printf("正在探测到 %s [%s] 的路径MTU：\n", host, conn.RemoteAddr())
+
+	good, bad := 0, pmtudStartSize+1 //已知可达的最大长度、已知不可达的最小长度
+	cur := pmtudStartSize
+	for seq := 0; bad-good > pmtudPrecision; seq++ {
+		if stopped() {
+			return
+		}
+
+		switch result := probeSize(conn, seq, cur); result {
+		case probeOK:
+			fmt.Printf("数据长度 %d: 成功\n", cur)
+			good = cur
+		default:
+			if result == probeFragNeeded {
+				fmt.Printf("数据长度 %d: 需要分片\n", cur)
+			} else {
+				fmt.Printf("数据长度 %d: 请求超时\n", cur)
+			}
+			bad = cur
+		}
+
+		if good == 0 {
+			cur = bad / 2 //还没有成功过，直接减半
+		} else {
+			cur = (good + bad) / 2
+		}
+		if cur <= 0 {
+			break
+		}
+	}
+
+	if good == 0 {
+		fmt.Printf("\n未能探测到 %s 的路径MTU：所有请求均失败。\n", conn.RemoteAddr())
+		return
+	}
+	fmt.Printf("\n%s 的路径MTU = %d 字节 (数据长度 %d 字节)\n", conn.RemoteAddr(), good+ipICMPHdrLen, good)
+}
+
+// 以指定数据长度发送一次回显请求
+func probeSize(conn net.Conn, seq, size int) int {
+	data, err := buildEcho(seq, size)
+	if err != nil {
+		return probeTimeout
+	}
+
+	conn.SetDeadline(time.Now().Add(time.Duration(timeout) * time.Millisecond))
+	if _, err := conn.Write(data); err != nil {
+		//超出本机出口MTU时内核直接返回EMSGSIZE
+		if errors.Is(err, errMsgSize) {
+			return probeFragNeeded
+		}
+		return probeTimeout
+	}
+
+	bufp := recvBufPool.Get().(*[]byte)
+	defer recvBufPool.Put(bufp)
+	buf := *bufp
+	for {
+		n, err := conn.Read(buf)
+		switch {
+		case errors.Is(err, errMsgSize):
+			return probeFragNeeded //内核已根据"需要分片"更新了路径MTU
+		case isICMPSocketError(err):
+			continue //差错报文仍在接收队列中，读取后按类型判断
+		case err != nil:
+			return probeTimeout
+		}
+		if checkIPv4Header(buf[:n]) != nil {
+			continue
+		}
+		ihl := int(buf[0]&0x0f) * 4 //IP头可能带选项，ICMP头不一定从第20字节开始
+		if n < ihl+8 {
+			continue
+		}
+		icmp := buf[ihl:n]
+		switch {
+		case icmp[0] == 0 && int(icmp[6])<<8|int(icmp[7]) == seq&0xffff: //回显应答
+			return probeOK
+		case icmp[0] == 3 && icmp[1] == 4: //目标不可达：需要分片
+			return probeFragNeeded
+		}
+	}
+}
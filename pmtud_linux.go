@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net"
+	"syscall"
+)
+
+var errMsgSize error = syscall.EMSGSIZE
+
+// 设置DF标志，禁止报文分片
+func setDontFragment(conn net.Conn) error {
+	raw, err := conn.(*net.IPConn).SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_DO)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+var errMsgSize = errors.New("message too long")
+
+// 设置DF标志，禁止报文分片
+func setDontFragment(conn net.Conn) error {
+	return errors.New("当前平台不支持设置DF标志")
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// 模拟路径MTU为mtu的连接：超出时回复"需要分片"，localMTU以内才能发出，silent时超出也不回复
+// sockErr非nil时超出后的第一次读取返回该错误，如内核把收到的差错记为套接字错误
+type mtuConn struct {
+	*mockConn
+	mtu, localMTU int
+	silent        bool
+	sockErr       error
+	lost          bool //最近一次请求没有回复
+	pendingErr    bool //下一次读取返回sockErr
+}
+
+func (c *mtuConn) Write(b []byte) (int, error) {
+	if c.localMTU > 0 && 20+len(b) > c.localMTU {
+		return 0, errMsgSize
+	}
+	c.mockConn.Write(b)
+	c.lost = false
+	if 20+len(b) > c.mtu {
+		c.lost = c.silent
+		c.pendingErr = c.sockErr != nil
+		c.reply[20], c.reply[21] = 3, 4
+	}
+	return len(b), nil
+}
+
+func (c *mtuConn) Read(b []byte) (int, error) {
+	if c.pendingErr {
+		c.pendingErr = false
+		return 0, c.sockErr
+	}
+	if c.lost {
+		return 0, os.ErrDeadlineExceeded
+	}
+	return c.mockConn.Read(b)
+}
+
+func TestProbeSize(t *testing.T) {
+	tests := []struct {
+		name string
+		conn *mtuConn
+		size int
+		want int
+	}{
+		{"成功", &mtuConn{mockConn: newMockConn(), mtu: 1500}, 1472, probeOK},
+		{"需要分片", &mtuConn{mockConn: newMockConn(), mtu: 1400}, 1472, probeFragNeeded},
+		{"超出本机MTU", &mtuConn{mockConn: newMockConn(), mtu: 9000, localMTU: 1400}, 1472, probeFragNeeded},
+		{"超时", &mtuConn{mockConn: newMockConn(), mtu: 1400, silent: true}, 1472, probeTimeout},
+		{"恰好等于路径MTU", &mtuConn{mockConn: newMockConn(), mtu: 1400}, 1400 - ipICMPHdrLen, probeOK},
+		{"读取时EMSGSIZE", &mtuConn{mockConn: newMockConn(), mtu: 1400, silent: true, sockErr: errMsgSize}, 1472, probeFragNeeded},
+		{"差错记为套接字错误", &mtuConn{mockConn: newMockConn(), mtu: 1400, sockErr: syscall.EHOSTUNREACH}, 1472, probeFragNeeded},
+		{"套接字错误后超时", &mtuConn{mockConn: newMockConn(), mtu: 1400, silent: true, sockErr: syscall.EHOSTUNREACH}, 1472, probeTimeout},
+	}
+	for _, tt := range tests {
+		if got := probeSize(tt.conn, 7, tt.size); got != tt.want {
+			t.Errorf("%s: probeSize = %d，期望 %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 序号不同的回显应答被跳过，直到读取超时
+func TestProbeSizeSkipsOtherSeq(t *testing.T) {
+	conn := &seqMismatchConn{mtuConn{mockConn: newMockConn(), mtu: 1500}, 0}
+	if got := probeSize(conn, 7, 100); got != probeTimeout {
+		t.Errorf("probeSize = %d，期望 probeTimeout", got)
+	}
+	if conn.reads < 2 {
+		t.Errorf("只读取了 %d 次", conn.reads)
+	}
+}
+
+// 先回复一个序号不同的应答，之后读取超时
+type seqMismatchConn struct {
+	mtuConn
+	reads int
+}
+
+func (c *seqMismatchConn) Read(b []byte) (int, error) {
+	c.reads++
+	if c.reads > 1 {
+		return 0, os.ErrDeadlineExceeded
+	}
+	n, err := c.mtuConn.Read(b)
+	b[27]++ //序号的低字节
+	return n, err
+}
+
+// 回复的IP头带4字节NOP选项(头长度24字节)的连接
+type optionsConn struct {
+	*mockConn
+	fragNeeded bool //以"需要分片"差错代替回显应答
+}
+
+func (c *optionsConn) Write(b []byte) (int, error) {
+	if _, err := c.mockConn.Write(b); err != nil {
+		return 0, err
+	}
+	r := make([]byte, 0, len(c.reply)+4)
+	r = append(r, c.reply[:20]...)
+	r = append(r, 1, 1, 1, 1) //NOP选项
+	r = append(r, c.reply[20:]...)
+	r[0] = 0x46
+	binary.BigEndian.PutUint16(r[2:4], uint16(len(r)))
+	if c.fragNeeded {
+		r[24], r[25] = 3, 4
+	}
+	c.reply = r
+	return len(b), nil
+}
+
+// 带IP选项的回复中ICMP头从第24字节开始，-strict 不把它判为异常
+func TestReplyWithIPOptions(t *testing.T) {
+	conn := &optionsConn{mockConn: newMockConn()}
+	req, err := buildEcho(0x0102, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write(req)
+	pkt := conn.reply
+	if len(pkt) != 24+8+16 || checkIPv4Header(pkt) != nil || int(pkt[0]&0x0f)*4 != 24 {
+		t.Fatalf("构造的回复不正确: % x", pkt)
+	}
+	if isEchoRequest(pkt) {
+		t.Error("带选项的回显应答被当作回显请求")
+	}
+	if a := verifyReply(pkt, net.IPv4(127, 0, 0, 1), req); a != nil {
+		t.Errorf("-strict 把带选项的回复判为异常: %s", a.reason)
+	}
+}
+
+// -pmtud 的探测按IP头长度定位ICMP头
+func TestProbeSizeWithIPOptions(t *testing.T) {
+	parseArgs(t, "-w", "100", "127.0.0.1")
+	if got := probeSize(&optionsConn{mockConn: newMockConn()}, 7, 1400); got != probeOK {
+		t.Errorf("回显应答: probeSize = %d，期望 probeOK", got)
+	}
+	if got := probeSize(&optionsConn{mockConn: newMockConn(), fragNeeded: true}, 7, 1400); got != probeFragNeeded {
+		t.Errorf("需要分片: probeSize = %d，期望 probeFragNeeded", got)
+	}
+}
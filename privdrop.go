@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var dropPrivs string //-drop-privs user[:group] 创建原始套接字后切换到该用户
+
+var privsDropped bool //是否已放弃权限，之后无法再创建原始套接字
+
+// -drop-privs 最多预先建立的连接数，每个目标占用一个文件描述符，
+// 展开网段后目标过多会超出文件描述符限制(通常为1024)
+const maxDropPrivsTargets = 256
+
+// privOps 切换用户所需的系统调用
+type privOps interface {
+	Setgroups(gids []int) error
+	Setgid(gid int) error
+	Setuid(uid int) error
+	Getuid() int
+	Getgid() int
+	Geteuid() int
+	Getegid() int
+}
+
+var privs privOps = sysPrivOps{} //dropPrivileges 使用的系统调用
+
+// preparedDialer 返回放弃权限前为各目标建立的连接，key为icmpHost处理后的主机，
+// 用完或没有时交给next
+type preparedDialer struct {
+	mu    sync.Mutex
+	conns map[string][]net.Conn
+	next  icmpDialer
+}
+
+func newPreparedDialer(next icmpDialer) *preparedDialer {
+	return &preparedDialer{conns: map[string][]net.Conn{}, next: next}
+}
+
+func (d *preparedDialer) add(host string, conn net.Conn) {
+	d.mu.Lock()
+	d.conns[host] = append(d.conns[host], conn)
+	d.mu.Unlock()
+}
+
+func (d *preparedDialer) dial(host string, timeout time.Duration) (net.Conn, error) {
+	d.mu.Lock()
+	conns := d.conns[host]
+	if len(conns) > 0 {
+		d.conns[host] = conns[1:]
+		d.mu.Unlock()
+		return conns[0], nil
+	}
+	d.mu.Unlock()
+	return d.next.dial(host, timeout)
+}
+
+// 解析 user[:group]，用户及组可以是名称或数字ID，未指定组时使用用户的主组
+func lookupAccount(spec string) (uid, gid int, err error) {
+	name, group, hasGroup := strings.Cut(spec, ":")
+	u, err := user.Lookup(name)
+	if err != nil {
+		if u, err = user.LookupId(name); err != nil {
+			return 0, 0, fmt.Errorf("找不到用户 %q", name)
+		}
+	}
+	if uid, err = strconv.Atoi(u.Uid); err != nil {
+		return 0, 0, fmt.Errorf("用户 %q 的ID %q 不是数字", name, u.Uid)
+	}
+	gidText := u.Gid
+	if hasGroup {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			if g, err = user.LookupGroupId(group); err != nil {
+				return 0, 0, fmt.Errorf("找不到组 %q", group)
+			}
+		}
+		gidText = g.Gid
+	}
+	if gid, err = strconv.Atoi(gidText); err != nil {
+		return 0, 0, fmt.Errorf("组ID %q 不是数字", gidText)
+	}
+	return uid, gid, nil
+}
+
+// 切换到uid/gid：必须先设置附加组及主组，再设置用户，否则已没有修改组的权限
+// 切换后确认实际及有效的用户、组，并确认无法再切换回root，否则返回错误
+func dropTo(ops privOps, uid, gid int) error {
+	if err := ops.Setgroups([]int{gid}); err != nil {
+		return fmt.Errorf("setgroups: %v", err)
+	}
+	if err := ops.Setgid(gid); err != nil {
+		return fmt.Errorf("setgid(%d): %v", gid, err)
+	}
+	if err := ops.Setuid(uid); err != nil {
+		return fmt.Errorf("setuid(%d): %v", uid, err)
+	}
+	if ops.Getuid() != uid || ops.Geteuid() != uid || ops.Getgid() != gid || ops.Getegid() != gid {
+		return fmt.Errorf("切换后的用户为 %d:%d(有效用户 %d:%d)，不是 %d:%d", ops.Getuid(), ops.Getgid(), ops.Geteuid(), ops.Getegid(), uid, gid)
+	}
+	//保存的用户ID仍为0时可以切换回root，权限没有真正放弃
+	if uid != 0 && ops.Setuid(0) == nil {
+		return errors.New("切换后仍可以通过setuid(0)恢复root权限")
+	}
+	return nil
+}
+
+// 为各目标建立原始套接字后放弃权限，任何一步失败都退出，不以root继续运行
+func dropPrivileges(hosts []string) {
+	uid, gid, err := lookupAccount(dropPrivs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-drop-privs: %v\n", err)
+		exit(2)
+	}
+	if len(hosts) > maxDropPrivsTargets {
+		fmt.Fprintf(os.Stderr, "-drop-privs: 共 %d 个目标，超过 %d 个。放弃权限前要为每个目标建立一个原始套接字，请减少目标(如缩小网段)或不使用 -drop-privs\n", len(hosts), maxDropPrivsTargets)
+		exit(2)
+	}
+	prepared := newPreparedDialer(icmpDial)
+	for _, h := range hosts {
+		host := icmpHost(h)
+		conn, err := icmpDial.dial(host, time.Duration(timeout)*time.Millisecond)
+		if err != nil {
+			fmt.Fprint(os.Stderr, dialErrorText(host, err))
+			exit(1)
+		}
+		prepared.add(host, conn)
+	}
+	icmpDial = prepared
+	if err := dropTo(privs, uid, gid); err != nil {
+		fmt.Fprintf(os.Stderr, "无法放弃权限，拒绝继续运行: %v\n", err)
+		exit(1)
+	}
+	privsDropped = true
+}
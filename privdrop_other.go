@@ -0,0 +1,19 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import "errors"
+
+const dropPrivsSupported = false
+
+var errNoDropPrivs = errors.New("当前平台不支持切换用户")
+
+type sysPrivOps struct{}
+
+func (sysPrivOps) Setgroups(gids []int) error { return errNoDropPrivs }
+func (sysPrivOps) Setgid(gid int) error       { return errNoDropPrivs }
+func (sysPrivOps) Setuid(uid int) error       { return errNoDropPrivs }
+func (sysPrivOps) Getuid() int                { return -1 }
+func (sysPrivOps) Getgid() int                { return -1 }
+func (sysPrivOps) Geteuid() int               { return -1 }
+func (sysPrivOps) Getegid() int               { return -1 }
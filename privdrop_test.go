@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// 让dialICMP对host返回conn，代替原始套接字
+func predial(t *testing.T, host string, conn net.Conn) {
+	t.Helper()
+	d, ok := icmpDial.(*preparedDialer)
+	if !ok {
+		old := icmpDial
+		d = newPreparedDialer(old)
+		icmpDial = d
+		t.Cleanup(func() { icmpDial = old })
+	}
+	d.add(host, conn)
+}
+
+// 记录调用顺序的privOps，初始为root；fail为失败的调用，ignore为不生效(但不报错)的部分：
+// setgid、setuid不改变任何ID，setegid、seteuid只改变实际ID，有效ID仍为0
+// reversible时放弃root后setuid(0)仍然成功(如保存的用户ID仍为0)
+type fakePrivOps struct {
+	calls        []string
+	fail, ignore string
+	reversible   bool
+	uid, gid     int
+	euid, egid   int
+	before       func() //第一次调用时执行，用于检查此时的状态
+}
+
+func (f *fakePrivOps) call(name string, arg any) error {
+	if f.before != nil {
+		f.before()
+		f.before = nil
+	}
+	f.calls = append(f.calls, fmt.Sprintf("%s(%v)", name, arg))
+	if name == f.fail {
+		return syscall.EPERM
+	}
+	return nil
+}
+
+func (f *fakePrivOps) Setgroups(gids []int) error { return f.call("setgroups", gids) }
+
+func (f *fakePrivOps) Setgid(gid int) error {
+	if err := f.call("setgid", gid); err != nil {
+		return err
+	}
+	if f.ignore != "setgid" {
+		f.gid = gid
+	}
+	if f.ignore != "setgid" && f.ignore != "setegid" {
+		f.egid = gid
+	}
+	return nil
+}
+
+func (f *fakePrivOps) Setuid(uid int) error {
+	if err := f.call("setuid", uid); err != nil {
+		return err
+	}
+	if f.euid != 0 && !f.reversible {
+		return syscall.EPERM //已不是root，不能切换到其他用户
+	}
+	if f.ignore != "setuid" {
+		f.uid = uid
+	}
+	if f.ignore != "setuid" && f.ignore != "seteuid" {
+		f.euid = uid
+	}
+	return nil
+}
+
+func (f *fakePrivOps) Getuid() int  { return f.uid }
+func (f *fakePrivOps) Getgid() int  { return f.gid }
+func (f *fakePrivOps) Geteuid() int { return f.euid }
+func (f *fakePrivOps) Getegid() int { return f.egid }
+
+// 先设置附加组及主组再设置用户，最后确认无法切换回root；任何一步失败都不再继续，
+// 切换后的实际或有效用户、组不一致时报错
+func TestDropTo(t *testing.T) {
+	all := []string{"setgroups([1000])", "setgid(1000)", "setuid(1000)", "setuid(0)"}
+	tests := []struct {
+		name         string
+		fail, ignore string
+		reversible   bool
+		calls        []string
+		err          string //空表示成功
+	}{
+		{"成功", "", "", false, all, ""},
+		{"setgroups失败", "setgroups", "", false, all[:1], "setgroups: "},
+		{"setgid失败", "setgid", "", false, all[:2], "setgid(1000): "},
+		{"setuid失败", "setuid", "", false, all[:3], "setuid(1000): "},
+		{"setuid不生效", "", "setuid", false, all[:3], "切换后的用户为 0:1000(有效用户 0:1000)，不是 1000:1000"},
+		{"setgid不生效", "", "setgid", false, all[:3], "切换后的用户为 1000:0(有效用户 1000:0)，不是 1000:1000"},
+		{"有效用户仍为root", "", "seteuid", false, all[:3], "切换后的用户为 1000:1000(有效用户 0:1000)，不是 1000:1000"},
+		{"有效组仍为root", "", "setegid", false, all[:3], "切换后的用户为 1000:1000(有效用户 1000:0)，不是 1000:1000"},
+		{"可以切换回root", "", "", true, all, "切换后仍可以通过setuid(0)恢复root权限"},
+	}
+	for _, tt := range tests {
+		ops := &fakePrivOps{fail: tt.fail, ignore: tt.ignore, reversible: tt.reversible}
+		err := dropTo(ops, 1000, 1000)
+		if !reflect.DeepEqual(ops.calls, tt.calls) {
+			t.Errorf("%s: 调用 %v，期望 %v", tt.name, ops.calls, tt.calls)
+		}
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.err)) {
+			t.Errorf("%s: err = %v，期望 %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+// 部分放弃权限时进程以退出码1结束，不以root继续运行
+func TestDropPrivilegesPartial(t *testing.T) {
+	if !dropPrivsSupported {
+		t.Skip("当前平台不支持 -drop-privs")
+	}
+	for _, mode := range []string{"seteuid", "reversible"} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestDropPrivilegesHelper$")
+		cmd.Env = append(os.Environ(), "PING_TEST_DROP="+mode)
+		out, err := cmd.CombinedOutput()
+		var ee *exec.ExitError
+		if !errors.As(err, &ee) || ee.ExitCode() != 1 || !strings.Contains(string(out), "无法放弃权限，拒绝继续运行") {
+			t.Errorf("%s: %v\n%s", mode, err, out)
+		}
+	}
+}
+
+// 展开后的目标超过上限时在建立连接之前退出，不耗尽文件描述符
+func TestDropPrivilegesTooManyTargets(t *testing.T) {
+	if !dropPrivsSupported {
+		t.Skip("当前平台不支持 -drop-privs")
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestDropPrivilegesHelper$")
+	cmd.Env = append(os.Environ(), "PING_TEST_DROP=many")
+	out, err := cmd.CombinedOutput()
+	var ee *exec.ExitError
+	if !errors.As(err, &ee) || ee.ExitCode() != 2 || !strings.Contains(string(out), "共 510 个目标，超过 256 个") {
+		t.Errorf("%v\n%s", err, out)
+	}
+}
+
+// 由TestDropPrivilegesPartial及TestDropPrivilegesTooManyTargets在子进程中运行，dropPrivileges应退出进程
+func TestDropPrivilegesHelper(t *testing.T) {
+	mode := os.Getenv("PING_TEST_DROP")
+	if mode == "" {
+		t.Skip("只在子进程中运行")
+	}
+	parseArgs(t, "-drop-privs", "65534:65534", "127.0.0.1")
+	if mode == "many" {
+		hosts, _ := expandTargets([]string{"10.0.0.0/23"})
+		dropPrivileges(hosts)
+		fmt.Println("建立了所有连接")
+		return
+	}
+	predial(t, "127.0.0.1", newMockConn())
+	privs = &fakePrivOps{ignore: strings.TrimPrefix(mode, "reversible"), reversible: mode == "reversible"}
+	dropPrivileges([]string{"127.0.0.1"})
+	fmt.Println("放弃权限后继续运行")
+}
+
+// 为每个目标建立连接之后才切换用户，之后dialICMP取用预先建立的连接
+func TestDropPrivilegesOrder(t *testing.T) {
+	if !dropPrivsSupported {
+		t.Skip("当前平台不支持 -drop-privs")
+	}
+	u, err := user.Current()
+	if err != nil {
+		t.Skip(err)
+	}
+	parseArgs(t, "-drop-privs", u.Uid, "127.0.0.1", "127.0.0.2")
+	hosts := []string{"127.0.0.1", "127.0.0.2"}
+	conns := []*mockConn{newMockConn(), newMockConn()}
+	for i, h := range hosts {
+		predial(t, h, conns[i])
+	}
+
+	ops := &fakePrivOps{}
+	var dialed int
+	ops.before = func() {
+		d := icmpDial.(*preparedDialer)
+		d.mu.Lock()
+		for _, h := range hosts {
+			dialed += len(d.conns[h])
+		}
+		d.mu.Unlock()
+	}
+	oldPrivs := privs
+	privs = ops
+	oldDial := icmpDial
+	t.Cleanup(func() { privs, privsDropped, icmpDial = oldPrivs, false, oldDial })
+
+	dropPrivileges(hosts)
+	if dialed != len(hosts) {
+		t.Errorf("切换用户时已建立 %d 个连接，期望 %d", dialed, len(hosts))
+	}
+	calls := 4 //setgroups、setgid、setuid及确认无法切换回root的setuid(0)
+	if u.Uid == "0" {
+		calls = 3
+	}
+	if len(ops.calls) != calls || !privsDropped {
+		t.Errorf("调用 %v，privsDropped = %v", ops.calls, privsDropped)
+	}
+	for i, h := range hosts {
+		if conn, err := dialICMP(h, 0); err != nil || conn != conns[i] {
+			t.Errorf("%s: dialICMP = %v, %v，期望预先建立的连接", h, conn, err)
+		}
+	}
+}
+
+// 放弃权限后再创建原始套接字时说明原因
+func TestDialAfterDrop(t *testing.T) {
+	parseArgs(t, "127.0.0.1")
+	t.Cleanup(func() { privsDropped = false })
+	err := os.NewSyscallError("socket", syscall.EPERM)
+	privsDropped = true
+	if got := dialErrorText("127.0.0.1", err); !strings.HasPrefix(got, "已按 -drop-privs 放弃权限，无法再创建原始套接字") {
+		t.Errorf("放弃权限后: %q", got)
+	}
+	privsDropped = false
+	if got := dialErrorText("127.0.0.1", err); !strings.HasPrefix(got, "无法创建原始套接字，权限不足") {
+		t.Errorf("未放弃权限时: %q", got)
+	}
+	if got := dialErrorText("127.0.0.1", errors.New("no such host")); !strings.HasPrefix(got, "Ping 请求找不到主机 127.0.0.1") {
+		t.Errorf("其他错误: %q", got)
+	}
+}
+
+func TestDropPrivsFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-drop-privs", "nobody", "-wait-for", "5m", "x"}, "-drop-privs 不能与 -bfd、-mpls-lsp、-multi-dns、-wait-for"},
+		{[]string{"-drop-privs", "nobody", "-bfd", "x"}, "-drop-privs 不能与"},
+		{[]string{"-drop-privs", "nobody", "-ntp", "192.0.2.1"}, "-drop-privs 只用于ICMP探测"},
+		{[]string{"-drop-privs", "nobody", "-via", "192.0.2.1", "x"}, "-via 不能与 -drop-privs 同时使用"},
+	}
+	for _, tt := range tests {
+		if strings.HasPrefix(tt.err, "-via") && !viaSupported {
+			continue
+		}
+		if !dropPrivsSupported {
+			tt.err = "-drop-privs: 当前平台不支持切换用户"
+		}
+		if errs := argErrors(t, tt.args...); !hasArgError(errs, tt.err) {
+			t.Errorf("参数 %q: 错误 %q，期望 %q", tt.args, errs, tt.err)
+		}
+	}
+}
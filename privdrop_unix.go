@@ -0,0 +1,18 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import "syscall"
+
+const dropPrivsSupported = true
+
+// 直接调用系统的privOps
+type sysPrivOps struct{}
+
+func (sysPrivOps) Setgroups(gids []int) error { return syscall.Setgroups(gids) }
+func (sysPrivOps) Setgid(gid int) error       { return syscall.Setgid(gid) }
+func (sysPrivOps) Setuid(uid int) error       { return syscall.Setuid(uid) }
+func (sysPrivOps) Getuid() int                { return syscall.Getuid() }
+func (sysPrivOps) Getgid() int                { return syscall.Getgid() }
+func (sysPrivOps) Geteuid() int               { return syscall.Geteuid() }
+func (sysPrivOps) Getegid() int               { return syscall.Getegid() }
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// 是否为权限不足导致的错误
+func isPermissionError(err error) bool {
+	return errors.Is(err, os.ErrPermission) || errors.Is(err, syscall.EACCES) ||
+		errors.Is(err, syscall.EPERM) || isPlatformPermissionError(err)
+}
+
+// 无法建立ICMP连接时的提示：权限不足时给出当前平台的解决办法，
+// 设置流量分类失败时给出原因，其余按找不到主机处理
+func dialErrorText(host string, err error) string {
+	var dnsErr *net.DNSError
+	if (fwMark != 0 || priority >= 0) && !errors.As(err, &dnsErr) {
+		return fmt.Sprintf("无法设置流量分类并建立连接: %v\n", err)
+	}
+	if privsDropped && isPermissionError(err) {
+		return fmt.Sprintf("已按 -drop-privs 放弃权限，无法再创建原始套接字: %v\n", err)
+	}
+	if isPermissionError(err) {
+		return fmt.Sprintf("无法创建原始套接字，权限不足: %v\n%s\n", err, permissionHint())
+	}
+	return fmt.Sprintf("Ping 请求找不到主机 %s。请检查该名称，然后重试。\n", host)
+}
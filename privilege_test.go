@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestIsPermissionError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"EPERM", syscall.EPERM, true},
+		{"EACCES", syscall.EACCES, true},
+		{"os.ErrPermission", os.ErrPermission, true},
+		{"Dial返回的错误", &net.OpError{Op: "dial", Net: "ip4:icmp", Err: os.NewSyscallError("socket", syscall.EPERM)}, true},
+		{"包装的错误", fmt.Errorf("设置选项: %w", syscall.EACCES), true},
+		{"找不到主机", &net.DNSError{Err: "no such host", Name: "nx.invalid", IsNotFound: true}, false},
+		{"其他错误", syscall.ENOENT, false},
+		{"普通错误", errors.New("operation not permitted"), false}, //只按错误类型判断，不匹配文本
+	}
+	for _, tt := range tests {
+		if got := isPermissionError(tt.err); got != tt.want {
+			t.Errorf("%s: isPermissionError(%v) = %v，期望 %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+// 权限不足时给出当前平台的解决办法，而不是报告找不到主机
+func TestDialErrorText(t *testing.T) {
+	parseArgs(t, "127.0.0.1")
+	permErr := &net.OpError{Op: "dial", Net: "ip4:icmp", Err: os.NewSyscallError("socket", syscall.EPERM)}
+	got := dialErrorText("127.0.0.1", permErr)
+	if !strings.Contains(got, "无法创建原始套接字，权限不足") || !strings.Contains(got, permissionHint()) {
+		t.Errorf("权限不足时的提示: %q", got)
+	}
+	if runtime.GOOS == "linux" && !strings.Contains(got, "setcap cap_net_raw+ep") {
+		t.Errorf("Linux上应提示setcap: %q", got)
+	}
+
+	dnsErr := &net.DNSError{Err: "no such host", Name: "nx.invalid", IsNotFound: true}
+	if got := dialErrorText("nx.invalid", dnsErr); got != "Ping 请求找不到主机 nx.invalid。请检查该名称，然后重试。\n" {
+		t.Errorf("找不到主机时的提示: %q", got)
+	}
+
+	if !markSupported {
+		return
+	}
+	parseArgs(t, "-mark", "1", "127.0.0.1")
+	if got := dialErrorText("127.0.0.1", permErr); !strings.HasPrefix(got, "无法设置流量分类并建立连接") {
+		t.Errorf("-mark 时的提示: %q", got)
+	}
+	if got := dialErrorText("nx.invalid", dnsErr); !strings.Contains(got, "找不到主机 nx.invalid") {
+		t.Errorf("-mark 时找不到主机的提示: %q", got)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+var probeIface string //-probe 以RFC 8335 扩展回显探测目标上的该接口(名称、索引或地址)
+
+const (
+	icmpExtEchoRequest = 42
+	icmpExtEchoReply   = 43
+	icmpParamProblem   = 12
+	icmpDestUnreach    = 3
+
+	extVersion         = 2 //ICMP扩展结构版本，见RFC 4884
+	extClassIfaceID    = 3 //接口标识对象
+	extCTypeName       = 1
+	extCTypeIndex      = 2
+	extCTypeAddress    = 3
+	afiIPv4            = 1
+	afiIPv6            = 2
+	extEchoLocalBit    = 0x01 //L位：被探测的接口在目标节点上
+	probeSilentLimit   = 3    //连续多少次没有任何回应后提示目标可能不支持
+	extEchoHeaderLen   = 8
+	extHeaderLen       = 4
+	extObjectHeaderLen = 4
+)
+
+// 扩展回显应答的代码
+var extEchoCodes = []string{"无错误", "查询格式错误", "没有该接口", "没有该表项", "多个接口符合查询"}
+
+// 构造接口标识对象：数字为接口索引，IP地址为接口地址，其余为接口名称
+func buildIfaceIDObject(iface string) ([]byte, error) {
+	var ctype byte
+	var payload []byte
+	if idx, err := strconv.ParseUint(iface, 10, 32); err == nil {
+		ctype = extCTypeIndex
+		payload = make([]byte, 4)
+		binary.BigEndian.PutUint32(payload, uint32(idx))
+	} else if ip := net.ParseIP(iface); ip != nil {
+		ctype = extCTypeAddress
+		afi, addr := uint16(afiIPv6), []byte(ip.To16())
+		if ip4 := ip.To4(); ip4 != nil {
+			afi, addr = afiIPv4, ip4
+		}
+		payload = make([]byte, 4, 4+len(addr))
+		binary.BigEndian.PutUint16(payload[0:2], afi)
+		payload[2] = byte(len(addr))
+		payload = append(payload, addr...)
+	} else {
+		if iface == "" || len(iface) > 255 {
+			return nil, fmt.Errorf("无效的接口名称 %q", iface)
+		}
+		ctype = extCTypeName
+		payload = make([]byte, (len(iface)+3)/4*4) //以0补足到4字节的整数倍
+		copy(payload, iface)
+	}
+
+	obj := make([]byte, extObjectHeaderLen, extObjectHeaderLen+len(payload))
+	binary.BigEndian.PutUint16(obj[0:2], uint16(extObjectHeaderLen+len(payload)))
+	obj[2], obj[3] = extClassIfaceID, ctype
+	return append(obj, payload...), nil
+}
+
+// 构造扩展回显请求(类型42)：ICMP头 + ICMP扩展结构(版本2，带检验和) + 接口标识对象
+// 序号只有8位，第8字节的最低位为L位
+func buildExtEchoRequest(id uint16, seq uint8, obj []byte) ([]byte, error) {
+	b := make([]byte, extEchoHeaderLen+extHeaderLen+len(obj))
+	b[0] = icmpExtEchoRequest
+	binary.BigEndian.PutUint16(b[4:6], id)
+	b[6] = seq
+	b[7] = extEchoLocalBit
+
+	ext := b[extEchoHeaderLen:]
+	ext[0] = extVersion << 4
+	copy(ext[extHeaderLen:], obj)
+	sum, err := checkSum(ext)
+	if err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint16(ext[2:4], sum)
+
+	if sum, err = checkSum(b); err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint16(b[2:4], sum)
+	return b, nil
+}
+
+// 扩展回显应答
+type extEchoReply struct {
+	id     uint16
+	seq    uint8
+	code   int
+	state  int
+	active bool
+	ipv4   bool
+	ipv6   bool
+}
+
+// 解析扩展回显应答(类型43)的ICMP部分
+func parseExtEchoReply(icmp []byte) (extEchoReply, error) {
+	if len(icmp) < extEchoHeaderLen {
+		return extEchoReply{}, errors.New("应答过短")
+	}
+	if icmp[0] != icmpExtEchoReply {
+		return extEchoReply{}, fmt.Errorf("类型 %d 不是扩展回显应答", icmp[0])
+	}
+	flags := icmp[7]
+	return extEchoReply{
+		id:     binary.BigEndian.Uint16(icmp[4:6]),
+		seq:    icmp[6],
+		code:   int(icmp[1]),
+		state:  int(flags >> 5),
+		active: flags&0x04 != 0,
+		ipv4:   flags&0x02 != 0,
+		ipv6:   flags&0x01 != 0,
+	}, nil
+}
+
+// 应答的说明
+func (r extEchoReply) String() string {
+	if r.code != 0 {
+		if r.code < len(extEchoCodes) {
+			return extEchoCodes[r.code]
+		}
+		return fmt.Sprintf("代码 %d", r.code)
+	}
+	s := "接口未启用"
+	if r.active {
+		s = "接口已启用"
+		if r.ipv4 {
+			s += " IPv4"
+		}
+		if r.ipv6 {
+			s += " IPv6"
+		}
+	}
+	return s
+}
+
+// 差错报文(参数问题、目标不可达)是否由本进程的扩展回显请求引起
+// 差错报文的数据部分为原始IP头及其后至少8字节
+func isOwnExtEchoError(icmp []byte, id uint16, seq uint8) bool {
+	if len(icmp) < 8+20 {
+		return false
+	}
+	inner := icmp[8:]
+	ihl := int(inner[0]&0x0f) * 4
+	if inner[9] != 1 || len(inner) < ihl+8 {
+		return false
+	}
+	orig := inner[ihl:]
+	return orig[0] == icmpExtEchoRequest && binary.BigEndian.Uint16(orig[4:6]) == id && orig[6] == seq
+}
+
+// RunProbe 以RFC 8335 扩展回显(PROBE)查询目标节点上某个接口的状态
+// 目标返回参数问题时说明不支持PROBE；连续多次没有任何回应时给出提示
+func (p *Pinger) RunProbe() {
+	p.Host = icmpHost(p.Arg)
+	conn, err := dialICMP(p.Host, time.Duration(p.Timeout)*time.Millisecond)
+	if err != nil {
+		p.fail(err)
+		p.printf("%s", dialErrorText(p.Host, err))
+		return
+	}
+	defer conn.Close()
+	p.Addr = conn.RemoteAddr().String()
+
+	obj, err := buildIfaceIDObject(probeIface)
+	if err != nil {
+		p.fail(err)
+		p.printf("%v\n", err)
+		return
+	}
+	p.printf("正在以扩展回显(PROBE)查询 %s [%s] 上的接口 %s：\n", p.Host, p.Addr, probeIface)
+
+	bufp := recvBufPool.Get().(*[]byte)
+	defer recvBufPool.Put(bufp)
+	buf := *bufp
+	silent := 0 //连续没有任何回应的次数
+
+	for i := 0; forever || i < p.Count; i++ {
+		if !p.nextProbe(i) {
+			break
+		}
+
+		p.Stats.addSent()
+		seq := uint8(i)
+		req, err := buildExtEchoRequest(echoID, seq, obj)
+		if err != nil {
+			p.Stats.addSendError(err)
+			p.Stats.addFailure()
+			p.printf("请求失败: %s\n", sendErrorText(err))
+			continue
+		}
+		conn.SetDeadline(time.Now().Add(time.Duration(p.Timeout) * time.Millisecond))
+
+		tStart := time.Now()
+		if _, err := conn.Write(req); err != nil {
+			p.Stats.addSendError(err)
+			p.Stats.addFailure()
+			p.printf("请求失败: %s\n", sendErrorText(err))
+			continue
+		}
+
+		//读取到本次请求的应答或差错报文为止，跳过其他ICMP报文
+		var r extEchoReply
+		var icmpErr string
+		for {
+			n, rerr := conn.Read(buf)
+			if err = rerr; err != nil {
+				break
+			}
+			if checkIPv4Header(buf[:n]) != nil {
+				continue
+			}
+			icmp := buf[int(buf[0]&0x0f)*4 : n]
+			if len(icmp) < extEchoHeaderLen {
+				continue
+			}
+			if icmp[0] == icmpExtEchoReply {
+				if r, err = parseExtEchoReply(icmp); err == nil && r.id == echoID && r.seq == seq {
+					break
+				}
+				continue
+			}
+			if (icmp[0] == icmpParamProblem || icmp[0] == icmpDestUnreach) && isOwnExtEchoError(icmp, echoID, seq) {
+				icmpErr = fmt.Sprintf("类型=%d 代码=%d", icmp[0], icmp[1])
+				if icmp[0] == icmpParamProblem {
+					icmpErr = "参数问题，目标不支持扩展回显(PROBE)"
+				}
+				break
+			}
+		}
+		tSpend := time.Since(tStart).Milliseconds()
+
+		switch {
+		case err != nil:
+			p.Stats.addTimeout()
+			p.Stats.addFailure()
+			p.printf("请求超时。\n")
+			if silent++; silent == probeSilentLimit {
+				p.printf("提示: 连续 %d 次没有任何回应，目标可能不支持扩展回显(PROBE)，Linux需开启 net.ipv4.icmp_echo_enable_probe。\n", silent)
+			}
+			continue
+		case icmpErr != "":
+			silent = 0
+			p.Stats.addFailure()
+			p.printf("来自 %d.%d.%d.%d 的回复: %s\n", buf[12], buf[13], buf[14], buf[15], icmpErr)
+			continue
+		}
+		silent = 0
+		p.Stats.addSuccess(tSpend)
+		p.printf("来自 %d.%d.%d.%d 的回复: 序号=%d 时间=%dms 结果=%s\n", buf[12], buf[13], buf[14], buf[15], seq, tSpend, r)
+	}
+
+	p.printSummary()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// 以空格分隔的十六进制字节
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// 接口标识对象(RFC 8335 2.1)：长度(16) Class-Num=3 C-Type，之后为名称、索引或地址
+func TestBuildIfaceIDObject(t *testing.T) {
+	tests := []struct {
+		iface string
+		want  string
+	}{
+		{"eth0", "0008 03 01 65746830"},
+		{"lo", "0008 03 01 6c6f0000"},                                             //名称以0补足到4字节的整数倍
+		{"ens192", "000c 03 01 656e73313932 0000"},                                //6字节补足为8字节
+		{"2", "0008 03 02 00000002"},                                              //接口索引
+		{"4294967295", "0008 03 02 ffffffff"},                                     //最大的索引
+		{"192.0.2.1", "000c 03 03 0001 04 00 c0000201"},                           //AFI=1，地址长度4
+		{"2001:db8::1", "0018 03 03 0002 10 00 20010db8000000000000000000000001"}, //AFI=2，地址长度16
+		{"4294967296", "0010 03 01 34323934393637323936 0000"},                    //超出32位时按名称处理
+	}
+	for _, tt := range tests {
+		got, err := buildIfaceIDObject(tt.iface)
+		if err != nil {
+			t.Errorf("%s: %v", tt.iface, err)
+			continue
+		}
+		if want := unhex(t, tt.want); !bytes.Equal(got, want) {
+			t.Errorf("%s:\n得到 % x\n期望 % x", tt.iface, got, want)
+		}
+	}
+	for _, iface := range []string{"", strings.Repeat("x", 256)} {
+		if _, err := buildIfaceIDObject(iface); err == nil {
+			t.Errorf("接口名称 %q 没有报错", iface)
+		}
+	}
+}
+
+// 扩展回显请求(RFC 8335 2)：类型42 代码0 检验和 标识 序号(8位) 保留+L位，之后为RFC 4884扩展结构
+func TestBuildExtEchoRequest(t *testing.T) {
+	obj, err := buildIfaceIDObject("eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := buildExtEchoRequest(0x1234, 5, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := unhex(t, "2a 00 beca 1234 05 01"+ //ICMP头，L位置1
+		"20 00 0f52"+ //扩展结构头：版本2，扩展检验和
+		"0008 03 01 65746830") //接口标识对象
+	if !bytes.Equal(got, want) {
+		t.Fatalf("\n得到 % x\n期望 % x", got, want)
+	}
+	if sum, _ := checkSum(got); sum != 0 {
+		t.Errorf("ICMP检验和不正确")
+	}
+	if sum, _ := checkSum(got[extEchoHeaderLen:]); sum != 0 {
+		t.Errorf("扩展结构检验和不正确")
+	}
+}
+
+// 扩展回显应答(RFC 8335 3)：第8字节为 State(3位) Res(2位) A 4 6
+func TestParseExtEchoReply(t *testing.T) {
+	tests := []struct {
+		name string
+		icmp string
+		want extEchoReply
+		text string
+	}{
+		{"启用IPv4", "2b 00 0000 1234 05 06", extEchoReply{id: 0x1234, seq: 5, active: true, ipv4: true}, "接口已启用 IPv4"},
+		{"启用双栈", "2b 00 0000 1234 06 07", extEchoReply{id: 0x1234, seq: 6, active: true, ipv4: true, ipv6: true}, "接口已启用 IPv4 IPv6"},
+		{"未启用", "2b 00 0000 0001 00 00", extEchoReply{id: 1}, "接口未启用"},
+		{"状态位", "2b 00 0000 0001 00 a4", extEchoReply{id: 1, state: 5, active: true}, "接口已启用"},
+		{"没有该接口", "2b 02 0000 0001 09 00", extEchoReply{id: 1, seq: 9, code: 2}, "没有该接口"},
+		{"未知代码", "2b 07 0000 0001 09 00", extEchoReply{id: 1, seq: 9, code: 7}, "代码 7"},
+	}
+	for _, tt := range tests {
+		got, err := parseExtEchoReply(unhex(t, tt.icmp))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want || got.String() != tt.text {
+			t.Errorf("%s: %+v (%s)，期望 %+v (%s)", tt.name, got, got, tt.want, tt.text)
+		}
+	}
+	for _, bad := range []string{"2b 00 0000 1234 05", "00 00 0000 1234 05 06"} {
+		if _, err := parseExtEchoReply(unhex(t, bad)); err == nil {
+			t.Errorf("%s 没有报错", bad)
+		}
+	}
+}
+
+// 差错报文的数据部分是原始IP头及请求的前8字节，据此判断是否由本进程的请求引起
+func TestIsOwnExtEchoError(t *testing.T) {
+	inner := "45 00 0020 0000 0000 40 01 0000 c0000202 c0000201" //原始IP头，协议1
+	tests := []struct {
+		name string
+		icmp string
+		want bool
+	}{
+		{"本进程的请求", "0c 00 0000 00000000 " + inner + " 2a 00 beca 1234 05 01", true},
+		{"序号不同", "0c 00 0000 00000000 " + inner + " 2a 00 beca 1234 06 01", false},
+		{"标识不同", "0c 00 0000 00000000 " + inner + " 2a 00 beca 4321 05 01", false},
+		{"普通回显请求", "03 03 0000 00000000 " + inner + " 08 00 beca 1234 05 01", false},
+		{"不是ICMP", "03 03 0000 00000000 45 00 0020 0000 0000 40 11 0000 c0000202 c0000201 2a 00 beca 1234 05 01", false},
+		{"过短", "0c 00 0000 00000000 " + inner, false},
+	}
+	for _, tt := range tests {
+		if got := isOwnExtEchoError(unhex(t, tt.icmp), 0x1234, 5); got != tt.want {
+			t.Errorf("%s: %v，期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/quic-go/logging"
+)
+
+var quicAddr string //-quic 要测量的QUIC服务器
+
+const quicPort = "443"
+
+// 校验服务器证书使用的根证书，nil时使用系统根证书，测试中替换为自签名证书
+var quicRootCAs *x509.CertPool
+
+// 一次QUIC请求各阶段的耗时，均从发出第一个Initial报文起计时
+type quicPhases struct {
+	first     time.Duration //收到服务器的第一个报文(Initial、Retry或版本协商)
+	handshake time.Duration //QUIC握手(含TLS 1.3)完成
+	ttfb      time.Duration //收到HTTP/3响应头
+	status    int
+}
+
+// 记录收到服务器第一个报文的时间，回调在quic-go的连接协程中执行
+type quicFirstPacket struct {
+	mu sync.Mutex
+	at time.Time
+}
+
+func (f *quicFirstPacket) mark() {
+	f.mu.Lock()
+	if f.at.IsZero() {
+		f.at = time.Now()
+	}
+	f.mu.Unlock()
+}
+
+func (f *quicFirstPacket) since(t time.Time) (time.Duration, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.at.Sub(t), !f.at.IsZero()
+}
+
+func (f *quicFirstPacket) tracer() *logging.ConnectionTracer {
+	return &logging.ConnectionTracer{
+		ReceivedVersionNegotiationPacket: func(logging.ArbitraryLenConnectionID, logging.ArbitraryLenConnectionID, []logging.VersionNumber) {
+			f.mark()
+		},
+		ReceivedRetry:             func(*logging.Header) { f.mark() },
+		ReceivedLongHeaderPacket:  func(*logging.ExtendedHeader, logging.ByteCount, logging.ECN, []logging.Frame) { f.mark() },
+		ReceivedShortHeaderPacket: func(*logging.ShortHeader, logging.ByteCount, logging.ECN, []logging.Frame) { f.mark() },
+	}
+}
+
+// 服务器有应答但握手或请求没有完成
+type quicError struct {
+	stage string
+	first time.Duration //服务器第一个应答的耗时，没有应答时为负数
+	err   error
+}
+
+func (e *quicError) Error() string {
+	if e.first < 0 {
+		return fmt.Sprintf("%s: %v", e.stage, e.err)
+	}
+	return fmt.Sprintf("%s(服务器已在 %dms 时应答): %v", e.stage, e.first.Milliseconds(), e.err)
+}
+
+func (e *quicError) Unwrap() error { return e.err }
+
+// 建立QUIC连接(ALPN h3)并发送HTTP/3 GET请求，分别记录第一个应答、握手完成及收到响应头的时间
+// 每次请求使用新的UDP套接字和连接，不复用会话，也不使用0-RTT
+func quicProbe(addr, serverName string, deadline time.Time) (quicPhases, error) {
+	var ph quicPhases
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	var fp quicFirstPacket
+	tlsConf := &tls.Config{ServerName: serverName, NextProtos: []string{http3.NextProtoH3}, RootCAs: quicRootCAs}
+	quicConf := &quic.Config{
+		Tracer: func(context.Context, logging.Perspective, quic.ConnectionID) *logging.ConnectionTracer {
+			return fp.tracer()
+		},
+	}
+
+	tStart := time.Now()
+	conn, err := quic.DialAddr(ctx, addr, tlsConf, quicConf)
+	first, ok := fp.since(tStart)
+	if !ok {
+		first = -1
+	}
+	if err != nil {
+		return ph, &quicError{"QUIC握手失败", first, err}
+	}
+	ph.first = first
+	defer conn.CloseWithError(0, "")
+	ph.handshake = time.Since(tStart)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+addr+"/", nil)
+	if err != nil {
+		return ph, err
+	}
+	req.Host = serverName
+	req.Header.Set("User-Agent", "ping")
+	rt := &http3.SingleDestinationRoundTripper{Connection: conn}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return ph, &quicError{"HTTP/3请求失败", ph.first, err}
+	}
+	ph.ttfb = time.Since(tStart)
+	ph.status = resp.StatusCode
+	resp.Body.Close()
+	return ph, nil
+}
+
+// 服务器没有任何应答且超时
+func isQUICTimeout(err error) bool {
+	var qe *quicError
+	var ne net.Error
+	return errors.As(err, &qe) && qe.first < 0 && errors.As(err, &ne) && ne.Timeout()
+}
+
+// RunQUIC 以HTTP/3请求代替ICMP，每次新建QUIC连接，分别测量服务器第一个应答、握手完成及首字节的耗时
+// 握手完成并收到任何HTTP状态码都视为成功
+func (p *Pinger) RunQUIC() {
+	t := normalizeTarget(p.Arg)
+	if t.Port == "" {
+		t.Port = quicPort
+	}
+	p.Host = net.JoinHostPort(t.Host, t.Port)
+	raddr, err := net.ResolveUDPAddr("udp", p.Host)
+	if err != nil {
+		p.fail(err)
+		p.printf("Ping 请求找不到主机 %s。请检查该名称，然后重试。\n", t.Host)
+		return
+	}
+	p.Addr = raddr.String()
+
+	p.printf("正在向QUIC服务器 %s 发送HTTP/3请求 (ALPN %s)：\n", p.Addr, http3.NextProtoH3)
+
+	var sum quicPhases //成功请求各阶段耗时合计
+	for i := 0; forever || i < p.Count; i++ {
+		if !p.nextProbe(i) {
+			break
+		}
+
+		p.Stats.addSent()
+		tStart := time.Now()
+		ph, err := quicProbe(p.Addr, t.Host, tStart.Add(time.Duration(p.Timeout)*time.Millisecond))
+		tSpend := time.Since(tStart).Milliseconds()
+		if err != nil {
+			p.Stats.addFailure()
+			if isQUICTimeout(err) {
+				p.Stats.addTimeout()
+				p.printf("请求超时。\n")
+			} else {
+				p.printf("请求失败: %v\n", err)
+			}
+			continue
+		}
+		p.Stats.addSuccess(tSpend)
+		sum.first, sum.handshake, sum.ttfb = sum.first+ph.first, sum.handshake+ph.handshake, sum.ttfb+ph.ttfb
+		p.printf("来自 %s 的回复: 序号=%d 状态=%d 首个应答=%dms 握手=%dms 首字节=%dms\n",
+			p.Addr, i, ph.status, ph.first.Milliseconds(), ph.handshake.Milliseconds(), ph.ttfb.Milliseconds())
+	}
+
+	p.printSummary()
+	if n := p.Stats.Snapshot().Received; n > 0 {
+		avg := func(d time.Duration) int64 { return (d / time.Duration(n)).Milliseconds() }
+		p.printf("各阶段平均耗时:\n    首个应答 = %dms，握手 = %dms，首字节 = %dms\n", avg(sum.first), avg(sum.handshake), avg(sum.ttfb))
+	}
+}
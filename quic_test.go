@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// 本地HTTP/3服务器，使用httptest的自签名证书(对127.0.0.1有效)
+// trust为true时测试期间信任该证书
+func startHTTP3Server(t *testing.T, trust bool, handler http.Handler) string {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(nil)
+	ts.StartTLS()
+	certs, cert := ts.TLS.Certificates, ts.Certificate()
+	ts.Close()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http3.Server{Handler: handler, TLSConfig: &tls.Config{Certificates: certs, NextProtos: []string{http3.NextProtoH3}}}
+	go srv.Serve(pc)
+	t.Cleanup(func() {
+		srv.Close()
+		pc.Close()
+	})
+
+	if trust {
+		pool := x509.NewCertPool()
+		pool.AddCert(cert)
+		old := quicRootCAs
+		quicRootCAs = pool
+		t.Cleanup(func() { quicRootCAs = old })
+	}
+	return pc.LocalAddr().String()
+}
+
+// 每次请求新建连接并以GET请求根路径，收到任何状态码都视为成功
+func TestRunQUIC(t *testing.T) {
+	var mu sync.Mutex
+	var reqs []string
+	addr := startHTTP3Server(t, true, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		reqs = append(reqs, r.Method+" "+r.URL.Path+" "+r.UserAgent()+" "+r.RemoteAddr)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	parseArgs(t, "-n", "2", "-w", "2000", "-quic", addr)
+	p := newPinger(quicAddr)
+	stdout, _ := captureOutput(t, p.RunQUIC)
+	for _, want := range []string{
+		"正在向QUIC服务器 " + addr + " 发送HTTP/3请求 (ALPN h3)",
+		"来自 " + addr + " 的回复: 序号=0 状态=204 首个应答=",
+		"来自 " + addr + " 的回复: 序号=1 状态=204 首个应答=",
+		"各阶段平均耗时:\n    首个应答 = ",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("输出中没有 %q:\n%s", want, stdout)
+		}
+	}
+	if ss := p.Stats.Snapshot(); ss.Sent != 2 || ss.Received != 2 {
+		t.Errorf("统计 = %+v", ss)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reqs) != 2 || !strings.HasPrefix(reqs[0], "GET / ping ") || reqs[0] == reqs[1] {
+		t.Errorf("服务器收到的请求 = %q，期望两次来自不同端口的GET", reqs)
+	}
+}
+
+// 各阶段的耗时依次递增
+func TestQUICProbePhases(t *testing.T) {
+	addr := startHTTP3Server(t, true, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	ph, err := quicProbe(addr, "127.0.0.1", time.Now().Add(2*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ph.status != http.StatusOK || ph.first <= 0 || ph.handshake < ph.first || ph.ttfb < ph.handshake+20*time.Millisecond {
+		t.Errorf("各阶段 = %+v", ph)
+	}
+}
+
+// 证书不受信任时握手失败，但说明服务器已经应答；没有任何应答时为超时
+func TestQUICProbeFailures(t *testing.T) {
+	addr := startHTTP3Server(t, false, http.NotFoundHandler())
+	_, err := quicProbe(addr, "127.0.0.1", time.Now().Add(2*time.Second))
+	if err == nil || !strings.HasPrefix(err.Error(), "QUIC握手失败(服务器已在 ") || isQUICTimeout(err) {
+		t.Errorf("自签名证书: %v", err)
+	}
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	_, err = quicProbe(pc.LocalAddr().String(), "127.0.0.1", time.Now().Add(100*time.Millisecond))
+	if !isQUICTimeout(err) || !strings.HasPrefix(err.Error(), "QUIC握手失败: ") {
+		t.Errorf("没有应答: %v", err)
+	}
+}
+
+func TestRunQUICTimeout(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	parseArgs(t, "-n", "1", "-w", "100", "x")
+	p := newPinger(pc.LocalAddr().String())
+	stdout, _ := captureOutput(t, p.RunQUIC)
+	if !strings.Contains(stdout, "请求超时。") || strings.Contains(stdout, "各阶段平均耗时") {
+		t.Errorf("输出:\n%s", stdout)
+	}
+	if ss := p.Stats.Snapshot(); ss.Sent != 1 || ss.Received != 0 {
+		t.Errorf("统计 = %+v", ss)
+	}
+}
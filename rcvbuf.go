@@ -0,0 +1,17 @@
+package main
+
+import "net"
+
+var rcvBuf int //-rcvbuf 原始套接字的接收缓冲区大小(字节)，0表示使用系统默认值
+
+// 按 -rcvbuf 设置接收缓冲区，内核截断时给出警告
+func (p *Pinger) applyRcvBuf(conn net.Conn) {
+	got, err := setRcvBuf(conn, rcvBuf)
+	if err != nil {
+		p.printf("无法设置接收缓冲区: %v\n", err)
+		return
+	}
+	if got < rcvBuf {
+		p.printf("警告: 接收缓冲区被内核限制为 %d 字节(请求 %d 字节)，Linux可调大 net.core.rmem_max。\n", got, rcvBuf)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	soMeminfo      = 55 //SO_MEMINFO，Linux 4.6+
+	skMeminfoDrops = 8  //SK_MEMINFO_DROPS
+	skMeminfoVars  = 9
+)
+
+// 设置接收缓冲区大小，返回内核实际采用的大小
+// Linux的getsockopt返回值是设置值的两倍(包含内核的管理开销)，这里折算回设置值，
+// 超过net.core.rmem_max时会被截断
+func setRcvBuf(conn net.Conn, size int) (int, error) {
+	raw, err := conn.(*net.IPConn).SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var got int
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		if serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUF, size); serr != nil {
+			return
+		}
+		got, serr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUF)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return got / 2, serr
+}
+
+// 读取socket因接收缓冲区满等原因丢弃的报文数
+// 优先使用SO_MEMINFO，内核不支持时从 /proc/net/raw 的drops列按inode查找
+func socketDrops(conn net.Conn) (int, error) {
+	ipc, ok := conn.(*net.IPConn)
+	if !ok {
+		return 0, fmt.Errorf("不是原始套接字")
+	}
+	raw, err := ipc.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	drops, ino := -1, uint64(0)
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		var info [skMeminfoVars]uint32
+		size := uint32(unsafe.Sizeof(info))
+		_, _, errno := syscall.Syscall6(sysGetsockopt, fd, syscall.SOL_SOCKET, soMeminfo,
+			uintptr(unsafe.Pointer(&info[0])), uintptr(unsafe.Pointer(&size)), 0)
+		if errno == 0 && size >= (skMeminfoDrops+1)*4 {
+			drops = int(info[skMeminfoDrops])
+			return
+		}
+		var st syscall.Stat_t
+		if serr = syscall.Fstat(int(fd), &st); serr == nil {
+			ino = st.Ino
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	if drops >= 0 {
+		return drops, nil
+	}
+	if serr != nil {
+		return 0, serr
+	}
+	return procRawDrops(ino)
+}
+
+// 从 /proc/net/raw 读取指定inode的socket的drops列(最后一列)
+func procRawDrops(ino uint64) (int, error) {
+	f, err := os.Open("/proc/net/raw")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return parseRawDrops(f, ino)
+}
+
+// 解析 /proc/net/raw 格式的内容
+func parseRawDrops(r io.Reader, ino uint64) (int, error) {
+	want := strconv.FormatUint(ino, 10)
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() //表头
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		//sl local rem st tx:rx tr:when retrnsmt uid timeout inode ref pointer drops
+		if len(fields) < 13 || fields[9] != want {
+			continue
+		}
+		return strconv.Atoi(fields[len(fields)-1])
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("在 /proc/net/raw 中找不到inode %d", ino)
+}
@@ -0,0 +1,5 @@
+package main
+
+// syscall包在386上未定义SYS_GETSOCKOPT(经socketcall调用)，Linux 4.3起有单独的系统调用，
+// SO_MEMINFO需要4.6+，不会用在更早的内核上
+const sysGetsockopt = 365
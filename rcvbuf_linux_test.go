@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseRawDrops(t *testing.T) {
+	const table = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+    1: 00000000:0001 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 41234 2 0000000000000000 0
+    1: 00000000:0001 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 41240 2 0000000000000000 17
+`
+	tests := []struct {
+		ino   uint64
+		drops int
+		ok    bool
+	}{
+		{41234, 0, true},
+		{41240, 17, true},
+		{99999, 0, false},
+	}
+	for _, tt := range tests {
+		drops, err := parseRawDrops(strings.NewReader(table), tt.ino)
+		if (err == nil) != tt.ok || drops != tt.drops {
+			t.Errorf("inode %d: drops=%d err=%v", tt.ino, drops, err)
+		}
+	}
+}
+
+// 设置的接收缓冲区折算回设置值，丢包计数可以读取
+func TestSetRcvBufAndDrops(t *testing.T) {
+	needRawSocket(t)
+	conn, err := net.Dial("ip4:icmp", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	got, err := setRcvBuf(conn, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 4096 {
+		t.Errorf("setRcvBuf(4096) = %d", got) //低于rmem_max，不会被截断
+	}
+	if drops, err := socketDrops(conn); err != nil || drops != 0 {
+		t.Errorf("socketDrops = %d, %v", drops, err)
+	}
+}
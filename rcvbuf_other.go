@@ -0,0 +1,18 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+// 设置接收缓冲区大小
+func setRcvBuf(conn net.Conn, size int) (int, error) {
+	return 0, errors.New("当前平台不支持设置接收缓冲区")
+}
+
+// 读取socket丢弃的报文数
+func socketDrops(conn net.Conn) (int, error) {
+	return 0, errors.New("当前平台不支持读取丢包计数")
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestRcvBufFlag(t *testing.T) {
+	tests := []struct {
+		args   []string
+		want   int
+		shared bool //两个目标时是否使用共享套接字
+	}{
+		{[]string{"127.0.0.1"}, 0, true},
+		{[]string{"-rcvbuf", "262144", "127.0.0.1"}, 262144, false},
+		{[]string{"127.0.0.1", "-rcvbuf", "4096"}, 4096, false},
+	}
+	for _, tt := range tests {
+		parseArgs(t, tt.args...)
+		if rcvBuf != tt.want {
+			t.Errorf("%v: rcvBuf = %d，应为 %d", tt.args, rcvBuf, tt.want)
+		}
+		if got := useSharedSocket(2); got != (tt.shared && sharedSocketSupported) {
+			t.Errorf("%v: useSharedSocket = %v", tt.args, got)
+		}
+	}
+
+	if !hasArgError(argErrors(t, "-rcvbuf", "-1", "127.0.0.1"), "-rcvbuf") {
+		t.Error("-rcvbuf -1 应报错")
+	}
+}
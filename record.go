@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	recordPath  string //逐次探测结果的记录文件
+	replayPath  string //回放的记录文件
+	replaySince string //回放的起始时间
+	replayUntil string //回放的结束时间
+)
+
+// 记录文件中的一次探测，desc为 -print-schema 输出的字段说明
+type probeRecord struct {
+	Time     time.Time         `json:"time" desc:"发送请求的时间"`
+	Target   string            `json:"target" desc:"规范化后的目标"`
+	Seq      int               `json:"seq" desc:"请求序号"`
+	RTT      int64             `json:"rtt_ms" desc:"往返时间(毫秒)，超时时为等待的时间"`
+	TTL      int               `json:"ttl,omitempty" desc:"回复的TTL"`
+	Hops     *int              `json:"hops,omitempty" desc:"由TTL估计的跳数，仅JSONL"`
+	Timeout  int64             `json:"timeout_ms,omitempty" desc:"本次请求的超时时间(毫秒)，-adaptive-timeout 时随往返时间变化"`
+	Outcome  string            `json:"outcome" desc:"结果：success、timeout、send_error、error、chaos_drop、anomaly"`
+	Anomaly  string            `json:"anomaly,omitempty" desc:"-strict 时的协议异常类型，仅JSONL"`
+	Labels   map[string]string `json:"labels,omitempty" desc:"目标的标签，CSV中为 k=v,k=v"`
+	Mark     int               `json:"mark,omitempty" desc:"-mark 设置的防火墙标记，仅JSONL"`
+	Priority *int              `json:"priority,omitempty" desc:"-priority 设置的套接字优先级，仅JSONL"`
+}
+
+// 记录文件中的事件，与探测记录以event字段区分，仅JSONL
+type eventRecord struct {
+	Time    time.Time         `json:"time" desc:"事件发生的时间"`
+	Target  string            `json:"target" desc:"目标"`
+	Event   string            `json:"event" desc:"事件：path_change、dns_error、dns_recovered、outage_start、outage_end、availability"`
+	Seq     int               `json:"seq,omitempty" desc:"path_change 时发现变化的请求序号"`
+	FromTTL int               `json:"from_ttl,omitempty" desc:"path_change 时原来的回复TTL"`
+	ToTTL   int               `json:"to_ttl,omitempty" desc:"path_change 时新的回复TTL"`
+	Labels  map[string]string `json:"labels,omitempty" desc:"目标的标签"`
+
+	Duration     int64    `json:"duration_ms,omitempty" desc:"outage_end 时这次离线的时长(毫秒)"`
+	Runtime      int64    `json:"runtime_ms,omitempty" desc:"availability 时的运行时长(毫秒)，不含暂停"`
+	Downtime     int64    `json:"downtime_ms,omitempty" desc:"outage_end、availability 时的离线时长合计(毫秒)，包括尚未恢复的离线"`
+	Outages      int      `json:"outages,omitempty" desc:"outage_start、outage_end、availability 时的离线次数"`
+	Longest      int64    `json:"longest_ms,omitempty" desc:"availability 时最长一次离线(毫秒)"`
+	Availability *float64 `json:"availability,omitempty" desc:"outage_end、availability 时的可用率(百分比)"`
+	Down         bool     `json:"down,omitempty" desc:"availability 时目标仍处于离线状态"`
+	Final        bool     `json:"final,omitempty" desc:"availability 时为结束时的统计，否则为运行中(SIGQUIT)的统计"`
+}
+
+// JSONL记录文件的第一行，说明格式版本及本次运行的参数
+type runStartRecord struct {
+	Time          time.Time `json:"time" desc:"开始记录的时间"`
+	Event         string    `json:"event" desc:"固定为 start"`
+	SchemaVersion string    `json:"schema_version" desc:"输出格式的版本，见 -print-schema"`
+	Args          []string  `json:"args" desc:"命令行参数(不含程序名)"`
+	Mark          int       `json:"mark,omitempty" desc:"-mark 设置的防火墙标记"`
+	Priority      *int      `json:"priority,omitempty" desc:"-priority 设置的套接字优先级"`
+	ECN           string    `json:"ecn,omitempty" desc:"-ecn 设置的ECN标记：ECT(0) 或 ECT(1)"`
+}
+
+var csvHeader = []string{"time", "target", "seq", "rtt_ms", "ttl", "outcome", "labels", "timeout_ms"}
+
+// 探测记录器，扩展名为 .csv 时写CSV，否则每行一个JSON对象(JSONL)
+type recorder struct {
+	mu  sync.Mutex
+	f   *os.File
+	w   *bufio.Writer
+	csv *csv.Writer
+}
+
+var rec *recorder
+
+// 开始记录
+func startRecord(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	rec = &recorder{f: f, w: bufio.NewWriter(f)}
+	if strings.HasSuffix(strings.ToLower(path), ".csv") {
+		rec.csv = csv.NewWriter(rec.w)
+		rec.csv.Write(csvHeader)
+		return nil
+	}
+	data, _ := json.Marshal(newRunStartRecord())
+	rec.w.Write(append(data, '\n'))
+	return nil
+}
+
+// JSONL的第一行，流量分类的设置(-mark、-priority、-ecn)与开头一行显示的一致
+func newRunStartRecord() runStartRecord {
+	r := runStartRecord{Time: time.Now(), Event: "start", SchemaVersion: schemaVersion, Args: os.Args[1:], Mark: fwMark}
+	if priority >= 0 {
+		prio := priority
+		r.Priority = &prio
+	}
+	if cp, err := parseECN(ecnMode); err == nil {
+		r.ECN = ecnNames[cp]
+	}
+	return r
+}
+
+// 结束记录，写出缓冲区中的内容
+func stopRecord() {
+	if rec == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.csv != nil {
+		rec.csv.Flush()
+	}
+	rec.w.Flush()
+	rec.f.Close()
+	rec = nil
+}
+
+// 记录一次探测：导出OpenTelemetry span及InfluxDB行，并写入记录文件
+func recordProbe(s probeSpan) {
+	recordSpan(s)
+	influxProbe(s)
+	if rec == nil {
+		return
+	}
+
+	r := probeRecord{Time: s.start, Target: s.target, Seq: s.seq, RTT: s.rtt, TTL: s.ttl, Timeout: s.timeout.Milliseconds(), Outcome: s.outcome, Anomaly: s.anomaly, Labels: s.labels, Mark: fwMark}
+	if priority >= 0 {
+		prio := priority
+		r.Priority = &prio
+	}
+	if s.ttl > 0 {
+		hops, _ := estimateHops(s.ttl)
+		r.Hops = &hops
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.csv != nil {
+		rec.csv.Write([]string{r.Time.Format(time.RFC3339Nano), r.Target, strconv.Itoa(r.Seq), strconv.FormatInt(r.RTT, 10), strconv.Itoa(r.TTL), r.Outcome, formatLabels(r.Labels), strconv.FormatInt(r.Timeout, 10)})
+		return
+	}
+	data, _ := json.Marshal(r)
+	rec.w.Write(append(data, '\n'))
+}
+
+// 回复TTL改变(路径可能已改变)时写入path_change事件
+func recordPathChange(target string, labels map[string]string, seq, from, to int) {
+	recordEvent(eventRecord{Time: time.Now(), Target: target, Event: "path_change", Seq: seq, FromTTL: from, ToTTL: to, Labels: labels})
+}
+
+// 目标离线或恢复时写入outage_start/outage_end事件，时间为状态变化的时间
+func recordOutage(target string, labels map[string]string, av AvailSnapshot) {
+	e := eventRecord{Time: av.Since, Target: target, Event: "outage_start", Outages: av.Outages, Labels: labels}
+	if !av.Down {
+		pct := av.Percent()
+		e.Event, e.Duration, e.Downtime, e.Availability = "outage_end", av.Last.Milliseconds(), av.Downtime.Milliseconds(), &pct
+	}
+	recordEvent(e)
+}
+
+// 写入availability事件，final为true时为结束时的统计
+func recordAvailability(target string, labels map[string]string, av AvailSnapshot, final bool) {
+	pct := av.Percent()
+	recordEvent(eventRecord{
+		Time: time.Now(), Target: target, Event: "availability", Labels: labels,
+		Runtime: av.Runtime.Milliseconds(), Downtime: av.Downtime.Milliseconds(), Outages: av.Outages,
+		Longest: av.Longest.Milliseconds(), Availability: &pct, Down: av.Down, Final: final,
+	})
+}
+
+// 写入一个事件，CSV记录中不包含事件
+func recordEvent(e eventRecord) {
+	if rec == nil || rec.csv != nil {
+		return
+	}
+	data, _ := json.Marshal(e)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.w.Write(append(data, '\n'))
+}
+
+// 是否为事件记录行，回放时跳过
+func isEventRecord(line string) bool {
+	var e struct {
+		Event string `json:"event"`
+	}
+	return strings.HasPrefix(line, "{") && json.Unmarshal([]byte(line), &e) == nil && e.Event != ""
+}
+
+// 解析一行记录，JSONL或CSV
+func parseProbeRecord(line string) (probeRecord, error) {
+	var r probeRecord
+	if strings.HasPrefix(line, "{") {
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			return r, err
+		}
+	} else {
+		fields, err := csv.NewReader(strings.NewReader(line)).Read()
+		if err != nil {
+			return r, err
+		}
+		//旧版本的记录文件没有labels、timeout_ms列；这两列只用于外部分析，回放时不解析
+		if len(fields) < len(csvHeader)-2 || len(fields) > len(csvHeader) {
+			return r, fmt.Errorf("字段数 %d 不正确", len(fields))
+		}
+		if r.Time, err = time.Parse(time.RFC3339Nano, fields[0]); err != nil {
+			return r, err
+		}
+		r.Target, r.Outcome = fields[1], fields[5]
+		if r.Seq, err = strconv.Atoi(fields[2]); err != nil {
+			return r, err
+		}
+		if r.RTT, err = strconv.ParseInt(fields[3], 10, 64); err != nil {
+			return r, err
+		}
+		if r.TTL, err = strconv.Atoi(fields[4]); err != nil {
+			return r, err
+		}
+	}
+	if r.Time.IsZero() || r.Target == "" || r.Outcome == "" {
+		return r, fmt.Errorf("缺少字段")
+	}
+	return r, nil
+}
+
+// 解析 -since/-until 的时间：RFC 3339 或本地时间 2006-01-02 15:04:05
+func parseReplayTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无效的时间 %q，格式为 2006-01-02 15:04:05 或 RFC 3339", s)
+}
+
+// 读取记录文件，按目标重新统计，不发送任何报文
+// 无法解析的行跳过并在最后给出行数
+func replayPingers(path string) []*Pinger {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取记录失败: %v\n", err)
+		exit(1)
+	}
+	defer f.Close()
+	pingers, bad, err := replayRecords(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取记录失败: %v\n", err)
+		exit(1)
+	}
+
+	for _, p := range pingers {
+		p.printReplay()
+	}
+	if len(pingers) == 0 {
+		fmt.Fprintln(os.Stderr, "没有符合条件的记录")
+	}
+	if bad > 0 {
+		fmt.Fprintf(os.Stderr, "跳过 %d 行无法解析的记录\n", bad)
+	}
+	return pingers
+}
+
+// 把记录按目标送入各自的统计，返回各目标(按首次出现的顺序)及无法解析的行数
+func replayRecords(r io.Reader) ([]*Pinger, int, error) {
+	var since, until time.Time
+	if replaySince != "" {
+		since, _ = parseReplayTime(replaySince) //已在getArgs中校验
+	}
+	if replayUntil != "" {
+		until, _ = parseReplayTime(replayUntil)
+	}
+
+	var pingers []*Pinger
+	byTarget := map[string]*Pinger{}
+	bad := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, csvHeader[0]+",") || isEventRecord(line) {
+			continue //空行、CSV表头及事件
+		}
+		pr, err := parseProbeRecord(line)
+		if err != nil {
+			bad++
+			continue
+		}
+		if (!since.IsZero() && pr.Time.Before(since)) || (!until.IsZero() && pr.Time.After(until)) {
+			continue
+		}
+
+		p := byTarget[pr.Target]
+		if p == nil {
+			p = newPinger(pr.Target)
+			p.Host, p.Addr = pr.Target, pr.Target
+			byTarget[pr.Target] = p
+			pingers = append(pingers, p)
+		}
+		p.Stats.addRecord(pr.Time, pr.RTT, pr.Outcome == "success")
+	}
+	return pingers, bad, scanner.Err()
+}
+
+// 输出回放的统计信息，包括百分位及可用性
+func (p *Pinger) printReplay() {
+	p.printSummary()
+	if p.Stats.Snapshot().Received > 0 {
+		title := "百分位"
+		if note := p.Stats.sampleNote(); note != "" {
+			title += "(" + note + ")"
+		}
+		ps := p.Stats.percentiles(50, 95, 99)
+		p.printf("%s:\n    P50 = %dms，P95 = %dms，P99 = %dms\n", title, ps[0], ps[1], ps[2])
+	}
+	if !forever { //-t 时printSummary已输出
+		p.printAvailability(p.Stats.Snapshot().Avail)
+	}
+}
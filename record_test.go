@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 写入记录文件的几次探测
+func writeRecord(t *testing.T, name string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := startRecord(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stopRecord)
+	t0 := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i, rtt := range []int64{10, 30, -1, 20} {
+		s := probeSpan{target: "192.0.2.1", seq: i, start: t0.Add(time.Duration(i) * time.Second), rtt: rtt, ttl: 57, timeout: time.Second, outcome: "success"}
+		if rtt < 0 {
+			s.rtt, s.ttl, s.outcome = 1000, 0, "timeout"
+		}
+		recordProbe(s)
+	}
+	recordProbe(probeSpan{target: "198.51.100.7", start: t0.Add(4 * time.Second), outcome: "send_error"})
+	stopRecord()
+	return path
+}
+
+// 记录的JSONL及CSV都能回放，统计与探测时一致
+func TestRecordReplay(t *testing.T) {
+	for _, name := range []string{"probes.jsonl", "probes.CSV"} {
+		t.Run(name, func(t *testing.T) {
+			path := writeRecord(t, name)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			if name == "probes.CSV" {
+				if lines[0] != "time,target,seq,rtt_ms,ttl,outcome,labels,timeout_ms" || lines[1] != "2024-03-01T09:00:00Z,192.0.2.1,0,10,57,success,,1000" {
+					t.Errorf("CSV:\n%s", data)
+				}
+			} else if !strings.Contains(lines[0], `"event":"start","schema_version":"`+schemaVersion+`"`) || lines[1] != `{"time":"2024-03-01T09:00:00Z","target":"192.0.2.1","seq":0,"rtt_ms":10,"ttl":57,"hops":7,"timeout_ms":1000,"outcome":"success"}` {
+				t.Errorf("JSONL:\n%s", data)
+			}
+
+			parseArgs(t, "-replay", path)
+			f, _ := os.Open(path)
+			defer f.Close()
+			pingers, bad, err := replayRecords(f)
+			if err != nil || bad != 0 || len(pingers) != 2 {
+				t.Fatalf("replayRecords = %d 个目标，%d 行无法解析，%v", len(pingers), bad, err)
+			}
+			ss := pingers[0].Stats.Snapshot()
+			if pingers[0].Arg != "192.0.2.1" || ss.Sent != 4 || ss.Received != 3 || ss.Min != 10 || ss.Max != 30 || ss.Avg() != 20 {
+				t.Errorf("%s 的统计 = %+v", pingers[0].Arg, ss)
+			}
+			//运行时长截至最后一条记录，第3秒离线、第4秒恢复
+			if av := ss.Avail; av.Runtime != 3*time.Second || av.Outages != 1 || av.Downtime != time.Second {
+				t.Errorf("可用性 = %+v", av)
+			}
+			//失败的请求不计入耗时
+			if ss := pingers[1].Stats.Snapshot(); ss.Sent != 1 || ss.Lost != 1 || ss.Total != 0 {
+				t.Errorf("%s 的统计 = %+v", pingers[1].Arg, ss)
+			}
+		})
+	}
+}
+
+// -since/-until 之外的记录及无法解析的行不参与统计
+func TestReplayFilter(t *testing.T) {
+	input := `{"time":"2024-03-01T09:00:00Z","target":"a","seq":0,"rtt_ms":10,"outcome":"success"}
+{"time":"2024-03-01T09:00:01Z","target":"a","seq":1,"rtt_ms":20,"outcome":"success"}
+
+time,target,seq,rtt_ms,ttl,outcome
+2024-03-01T09:00:02Z,a,2,30,64,success
+2024-03-01T09:00:03Z,a,3,40,64,success
+{"time":"2024-03-01T09:00:04Z","target":"a"}
+{"target":
+2024-03-01T09:00:05Z,a,x,40,64,success
+2024-03-01T09:00:05Z,a,5,40
+`
+	parseArgs(t, "-replay", "x", "-since", "2024-03-01T09:00:01Z", "-until", "2024-03-01T09:00:02Z")
+	pingers, bad, err := replayRecords(strings.NewReader(input))
+	if err != nil || bad != 4 || len(pingers) != 1 {
+		t.Fatalf("replayRecords = %d 个目标，%d 行无法解析，%v", len(pingers), bad, err)
+	}
+	if ss := pingers[0].Stats.Snapshot(); ss.Sent != 2 || ss.Min != 20 || ss.Max != 30 {
+		t.Errorf("统计 = %+v", ss)
+	}
+}
+
+func TestParseReplayTime(t *testing.T) {
+	tests := []struct {
+		s    string
+		want time.Time
+	}{
+		{"2024-03-01T09:00:00+08:00", time.Date(2024, 3, 1, 1, 0, 0, 0, time.UTC)},
+		{"2024-03-01 09:00:00", time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local)},
+		{"2024-03-01", time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		if got, err := parseReplayTime(tt.s); err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseReplayTime(%q) = %v, %v，应为 %v", tt.s, got, err, tt.want)
+		}
+	}
+	if errs := argErrors(t, "-replay", "x", "-since", "昨天"); !hasArgError(errs, `-since: 无效的时间 "昨天"`) {
+		t.Errorf("错误 = %q", errs)
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var reportPath string //结束后生成的报告，扩展名为 .html 时为HTML，否则为Markdown
+
+// 耗时分布的区间上限(毫秒，不含)，最后一个区间无上限
+var histogramBounds = []int64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000}
+
+const (
+	histogramBarWidth = 40 //分布图中最长的条
+	chartWidth        = 720
+	chartHeight       = 240
+	chartPadding      = 40
+)
+
+// 报告中的一个参数
+type reportParam struct {
+	Name, Value string
+}
+
+// 报告中的一个目标
+type reportTarget struct {
+	Name        string
+	Addr        string
+	Err         string
+	Stats       StatsSnapshot
+	Loss        string
+	Avg         int64
+	Percentiles []reportParam
+	SampleNote  string //较早的请求结果已被覆盖时，百分位及分布依据的范围
+	Histogram   []histogramBucket
+	Outages     []outageRow
+	Chart       htmltemplate.HTML //HTML报告中的耗时曲线(SVG)
+}
+
+// 耗时分布的一个区间
+type histogramBucket struct {
+	Label string
+	Count int
+	Bar   string
+}
+
+// 离线记录的一行
+type outageRow struct {
+	Start, End, Duration string
+	Ongoing              bool
+}
+
+// 报告数据
+type reportData struct {
+	Generated string
+	Params    []reportParam
+	Targets   []reportTarget
+}
+
+const markdownReport = `# Ping 报告
+
+生成时间: {{.Generated}}
+
+## 参数
+
+| 参数 | 取值 |
+|---|---|
+{{range .Params}}| {{.Name}} | {{.Value}} |
+{{end}}
+## 汇总
+
+| 目标 | 地址 | 已发送 | 已接收 | 丢失 | 最短 | 平均 | 最长 |
+|---|---|---:|---:|---:|---:|---:|---:|
+{{range .Targets}}{{if .Err}}| {{.Name}} | - | - | - | - | - | - | 错误: {{.Err}} |
+{{else}}| {{.Name}} | {{.Addr}} | {{.Stats.Sent}} | {{.Stats.Received}} | {{.Loss}} | {{if .Stats.Received}}{{.Stats.Min}}ms | {{.Avg}}ms | {{.Stats.Max}}ms{{else}}- | - | -{{end}} |
+{{end}}{{end}}{{range .Targets}}{{if not .Err}}
+## {{.Name}}
+{{if .Percentiles}}
+### 百分位
+{{if .SampleNote}}
+依据{{.SampleNote}}。
+{{end}}
+| 百分位 | 耗时 |
+|---|---:|
+{{range .Percentiles}}| {{.Name}} | {{.Value}} |
+{{end}}
+### 耗时分布
+
+` + "```" + `
+{{range .Histogram}}{{printf "%-12s" .Label}} {{printf "%6d" .Count}}{{if .Bar}} {{.Bar}}{{end}}
+{{end}}` + "```" + `
+{{end}}
+### 离线记录
+{{if .Outages}}
+| 开始 | 结束 | 时长 |
+|---|---|---:|
+{{range .Outages}}| {{.Start}} | {{if .Ongoing}}(未恢复){{else}}{{.End}}{{end}} | {{.Duration}} |
+{{end}}{{else}}
+无
+{{end}}{{end}}{{end}}`
+
+const htmlReport = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>Ping 报告</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; }
+td.num { text-align: right; }
+pre { background: #f6f6f6; padding: 8px; }
+</style>
+</head>
+<body>
+<h1>Ping 报告</h1>
+<p>生成时间: {{.Generated}}</p>
+
+<h2>参数</h2>
+<table>
+<tr><th>参数</th><th>取值</th></tr>
+{{range .Params}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+
+<h2>汇总</h2>
+<table>
+<tr><th>目标</th><th>地址</th><th>已发送</th><th>已接收</th><th>丢失</th><th>最短</th><th>平均</th><th>最长</th></tr>
+{{range .Targets}}{{if .Err}}<tr><td>{{.Name}}</td><td colspan="7">错误: {{.Err}}</td></tr>
+{{else}}<tr><td>{{.Name}}</td><td>{{.Addr}}</td><td class="num">{{.Stats.Sent}}</td><td class="num">{{.Stats.Received}}</td><td class="num">{{.Loss}}</td>{{if .Stats.Received}}<td class="num">{{.Stats.Min}}ms</td><td class="num">{{.Avg}}ms</td><td class="num">{{.Stats.Max}}ms</td>{{else}}<td>-</td><td>-</td><td>-</td>{{end}}</tr>
+{{end}}{{end}}</table>
+{{range .Targets}}{{if not .Err}}
+<h2>{{.Name}}</h2>
+{{.Chart}}
+{{if .Percentiles}}<h3>百分位</h3>
+{{if .SampleNote}}<p>依据{{.SampleNote}}。</p>
+{{end}}<table>
+<tr><th>百分位</th><th>耗时</th></tr>
+{{range .Percentiles}}<tr><td>{{.Name}}</td><td class="num">{{.Value}}</td></tr>
+{{end}}</table>
+
+<h3>耗时分布</h3>
+<pre>{{range .Histogram}}{{printf "%-12s" .Label}} {{printf "%6d" .Count}}{{if .Bar}} {{.Bar}}{{end}}
+{{end}}</pre>
+{{end}}
+<h3>离线记录</h3>
+{{if .Outages}}<table>
+<tr><th>开始</th><th>结束</th><th>时长</th></tr>
+{{range .Outages}}<tr><td>{{.Start}}</td><td>{{if .Ongoing}}(未恢复){{else}}{{.End}}{{end}}</td><td class="num">{{.Duration}}</td></tr>
+{{end}}</table>
+{{else}}<p>无</p>
+{{end}}{{end}}{{end}}
+</body>
+</html>
+`
+
+// 生成报告，扩展名为 .html/.htm 时为HTML，否则为Markdown
+func writeReport(path string, pingers []*Pinger) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ext := strings.ToLower(filepath.Ext(path))
+	html := ext == ".html" || ext == ".htm"
+	data := buildReport(pingers, html, time.Now())
+	if html {
+		return renderReport(f, htmltemplate.Must(htmltemplate.New("report").Parse(htmlReport)), data)
+	}
+	return renderReport(f, template.Must(template.New("report").Parse(markdownReport)), data)
+}
+
+// 模板的公共接口，text/template 与 html/template 都满足
+type reportTemplate interface {
+	Execute(w io.Writer, data any) error
+}
+
+func renderReport(w io.Writer, t reportTemplate, data reportData) error {
+	return t.Execute(w, data)
+}
+
+// 收集报告数据
+func buildReport(pingers []*Pinger, html bool, now time.Time) reportData {
+	data := reportData{Generated: now.Format("2006-01-02 15:04:05"), Params: reportParams(pingers)}
+	for _, p := range pingers {
+		t := reportTarget{Name: p.Arg, Addr: p.Addr}
+		if p.Err != nil {
+			t.Err = p.Err.Error()
+			data.Targets = append(data.Targets, t)
+			continue
+		}
+		t.Stats = p.Stats.Snapshot()
+		t.Loss = fmt.Sprintf("%.2f%%", t.Stats.LossPercent())
+		t.Avg = t.Stats.Avg()
+		samples := p.Stats.sampleList()
+		if t.Stats.Received > 0 {
+			qs := []float64{50, 90, 95, 99}
+			for i, v := range p.Stats.percentiles(qs...) {
+				t.Percentiles = append(t.Percentiles, reportParam{fmt.Sprintf("P%g", qs[i]), fmt.Sprintf("%dms", v)})
+			}
+			t.SampleNote = p.Stats.sampleNote()
+			t.Histogram = histogram(samples)
+		}
+		for _, o := range p.Stats.outagePeriods() {
+			t.Outages = append(t.Outages, outageRow{
+				Start:    o.Start.Format("2006-01-02 15:04:05"),
+				End:      o.End.Format("2006-01-02 15:04:05"),
+				Duration: o.End.Sub(o.Start).Round(time.Millisecond).String(),
+				Ongoing:  o.Ongoing,
+			})
+		}
+		if html {
+			t.Chart = latencyChart(samples)
+		}
+		data.Targets = append(data.Targets, t)
+	}
+	return data
+}
+
+// 报告中列出的参数
+func reportParams(pingers []*Pinger) []reportParam {
+	var targets []string
+	for _, p := range pingers {
+		targets = append(targets, p.Arg)
+	}
+	countText := fmt.Sprint(count)
+	if forever {
+		countText = "持续(-t)"
+	}
+	return []reportParam{
+		{"目标", strings.Join(targets, ", ")},
+		{"请求次数", countText},
+		{"数据长度", fmt.Sprintf("%d 字节", size)},
+		{"超时时间", fmt.Sprintf("%dms", timeout)},
+		{"请求间隔", fmt.Sprintf("%dms", interval)},
+	}
+}
+
+// 按固定区间统计成功请求的耗时分布
+func histogram(samples []probeSample) []histogramBucket {
+	counts := make([]int, len(histogramBounds)+1)
+	for _, s := range samples {
+		if !s.OK {
+			continue
+		}
+		i := 0
+		for i < len(histogramBounds) && s.RTT >= histogramBounds[i] {
+			i++
+		}
+		counts[i]++
+	}
+
+	most := 0
+	for _, c := range counts {
+		if c > most {
+			most = c
+		}
+	}
+	buckets := make([]histogramBucket, len(counts))
+	for i, c := range counts {
+		lower := int64(0)
+		if i > 0 {
+			lower = histogramBounds[i-1]
+		}
+		label := fmt.Sprintf(">=%dms", lower)
+		if i < len(histogramBounds) {
+			label = fmt.Sprintf("%d-%dms", lower, histogramBounds[i])
+		}
+		bar := 0
+		if most > 0 {
+			bar = (c*histogramBarWidth + most - 1) / most
+		}
+		buckets[i] = histogramBucket{Label: label, Count: c, Bar: strings.Repeat("#", bar)}
+	}
+	return buckets
+}
+
+// 以SVG绘制耗时随时间的变化，失败的请求在底部以红色竖线标出
+func latencyChart(samples []probeSample) htmltemplate.HTML {
+	if len(samples) == 0 {
+		return ""
+	}
+	start, end := samples[0].At, samples[len(samples)-1].At
+	span := end.Sub(start)
+	if span <= 0 {
+		span = time.Second
+	}
+	maxRTT := int64(1)
+	for _, s := range samples {
+		if s.OK && s.RTT > maxRTT {
+			maxRTT = s.RTT
+		}
+	}
+
+	plotW, plotH := chartWidth-2*chartPadding, chartHeight-2*chartPadding
+	x := func(t time.Time) float64 {
+		return chartPadding + float64(t.Sub(start))/float64(span)*float64(plotW)
+	}
+	y := func(rtt int64) float64 {
+		return float64(chartPadding+plotH) - float64(rtt)/float64(maxRTT)*float64(plotH)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="#ccc"/>`, chartPadding, chartPadding, plotW, plotH)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="12" text-anchor="end">%dms</text>`, chartPadding-4, chartPadding+4, maxRTT)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="12" text-anchor="end">0ms</text>`, chartPadding-4, chartPadding+plotH+4)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="12">%s</text>`, chartPadding, chartHeight-10, start.Format("15:04:05"))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="12" text-anchor="end">%s</text>`, chartPadding+plotW, chartHeight-10, end.Format("15:04:05"))
+
+	//每个像素列只保留耗时最长的一次成功请求及一条失败标记，避免 -t 长时间运行时曲线过大
+	type column struct {
+		rtt    int64
+		ok, ko bool
+	}
+	cols := make([]column, plotW+1)
+	for _, s := range samples {
+		c := &cols[int(x(s.At))-chartPadding]
+		if !s.OK {
+			c.ko = true
+		} else if !c.ok || s.RTT > c.rtt {
+			c.ok, c.rtt = true, s.RTT
+		}
+	}
+	var points []string
+	for i, c := range cols {
+		px := chartPadding + i
+		if c.ok {
+			points = append(points, fmt.Sprintf("%d,%.1f", px, y(c.rtt)))
+		}
+		if c.ko {
+			fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#d33"/>`, px, chartPadding+plotH, px, chartPadding+plotH-10)
+		}
+	}
+	if len(points) > 0 {
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="#36c" stroke-width="1.5" points="%s"/>`, strings.Join(points, " "))
+	}
+	b.WriteString(`</svg>`)
+	return htmltemplate.HTML(b.String())
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	htmltemplate "html/template"
+	"testing"
+	"text/template"
+	"time"
+)
+
+// 固定的合成数据：一个目标有40次请求(含一段离线)，另一个目标无法解析
+func syntheticPingers() []*Pinger {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local)
+	ok := &Pinger{Arg: "example.com", Addr: "93.184.216.34", Stats: newStatistics()}
+	for i := 0; i < 40; i++ {
+		at := start.Add(time.Duration(i) * time.Second)
+		rtt := int64(10 + (i*7)%23) //10-32ms
+		switch {
+		case i >= 20 && i < 24: //离线4秒
+			ok.Stats.addRecord(at, 0, false)
+		case i == 35:
+			ok.Stats.addRecord(at, 480, true)
+		default:
+			ok.Stats.addRecord(at, rtt, true)
+		}
+	}
+	bad := &Pinger{Arg: "nx.invalid", Stats: newStatistics(), Err: errors.New("无法解析主机 nx.invalid")}
+	return []*Pinger{ok, bad}
+}
+
+func TestReportGolden(t *testing.T) {
+	parseArgs(t) //报告中的参数取默认值
+	now := time.Date(2024, 3, 1, 9, 1, 0, 0, time.Local)
+	tests := []struct {
+		golden string
+		html   bool
+		tmpl   reportTemplate
+	}{
+		{"report.md.golden", false, template.Must(template.New("report").Parse(markdownReport))},
+		{"report.html.golden", true, htmltemplate.Must(htmltemplate.New("report").Parse(htmlReport))},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := renderReport(&out, tt.tmpl, buildReport(syntheticPingers(), tt.html, now)); err != nil {
+			t.Fatal(err)
+		}
+		checkGolden(t, tt.golden, out.Bytes())
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	responderMinCount = 3 //某个TTL至少出现的次数，偶尔一次的变化不算
+	responderMinFlips = 4 //TTL在不同取值之间来回切换的最少次数
+)
+
+// 根据各次回复的特征判断目标地址是否有多台主机在应答(地址冲突、HA切换失败等)
+// 证据包括：回复TTL在两个(或多个)稳定取值之间反复切换；部分回复原样返回数据而部分没有
+type responderAnalyzer struct {
+	ttls       map[int]int //各TTL取值出现的次数
+	lastTTL    int
+	flips      int //相邻两次回复TTL不同的次数
+	payloadOK  int //原样返回请求数据的回复数
+	payloadBad int //返回数据与请求不同的回复数
+	warned     bool
+}
+
+// 记录一次回复，第一次出现多台主机应答的迹象时返回true
+func (a *responderAnalyzer) observe(ttl int, payloadOK bool) bool {
+	if a.ttls == nil {
+		a.ttls = map[int]int{}
+	} else if ttl != a.lastTTL {
+		a.flips++
+	}
+	a.ttls[ttl]++
+	a.lastTTL = ttl
+	if payloadOK {
+		a.payloadOK++
+	} else {
+		a.payloadBad++
+	}
+
+	if a.warned || len(a.evidence()) == 0 {
+		return false
+	}
+	a.warned = true
+	return true
+}
+
+// 多台主机应答的证据，每条一行，没有证据时为空
+func (a *responderAnalyzer) evidence() []string {
+	var lines []string
+	var stable []int //出现次数足够多的TTL
+	for ttl, n := range a.ttls {
+		if n >= responderMinCount {
+			stable = append(stable, ttl)
+		}
+	}
+	if len(stable) >= 2 && a.flips >= responderMinFlips {
+		sort.Ints(stable)
+		desc := ""
+		for i, ttl := range stable {
+			if i > 0 {
+				desc += "，"
+			}
+			desc += fmt.Sprintf("TTL=%d %d 次", ttl, a.ttls[ttl])
+		}
+		lines = append(lines, fmt.Sprintf("回复TTL在 %d 个取值之间切换了 %d 次(%s)", len(stable), a.flips, desc))
+	}
+	if a.payloadOK > 0 && a.payloadBad > 0 {
+		lines = append(lines, fmt.Sprintf("%d 个回复原样返回了请求数据，%d 个回复的数据与请求不同", a.payloadOK, a.payloadBad))
+	}
+	return lines
+}
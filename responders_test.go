@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// 一次回复的特征
+type responderReply struct {
+	ttl       int
+	payloadOK bool
+}
+
+// 按回复序列喂给分析器，检查第几个回复时开始警告及统计信息中的证据
+func TestResponderAnalyzer(t *testing.T) {
+	ttls := func(vs ...int) []responderReply {
+		var rs []responderReply
+		for _, v := range vs {
+			rs = append(rs, responderReply{v, true})
+		}
+		return rs
+	}
+	tests := []struct {
+		name     string
+		replies  []responderReply
+		warnAt   int      //第几个回复(从1开始)时返回true，0表示不警告
+		evidence []string //证据中应包含的内容
+	}{
+		{"TTL稳定", ttls(64, 64, 64, 64, 64, 64, 64, 64), 0, nil},
+		{"路径改变一次", ttls(64, 64, 64, 64, 60, 60, 60, 60), 0, nil},
+		{"偶尔一次不同", ttls(64, 63, 64, 64, 63, 64, 64, 64), 0, nil}, //63只出现2次
+		{"两个取值反复切换", ttls(64, 128, 64, 128, 64, 128, 64), 6, []string{"在 2 个取值之间切换了", "TTL=64 4 次，TTL=128 3 次"}},
+		{"切换次数不足", ttls(64, 64, 64, 128, 128, 128, 64), 0, nil},
+		{"三个取值", ttls(60, 61, 62, 60, 61, 62, 60, 61, 62), 8, []string{"在 3 个取值之间切换了"}}, //第8个回复时才有两个取值各出现3次
+		{"部分回复数据不同", []responderReply{{64, true}, {64, true}, {64, false}, {64, true}}, 3, []string{"3 个回复原样返回了请求数据，1 个回复的数据与请求不同"}},
+		{"数据全都不同", []responderReply{{64, false}, {64, false}, {64, false}}, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a responderAnalyzer
+			warnAt := 0
+			for i, r := range tt.replies {
+				if a.observe(r.ttl, r.payloadOK) {
+					if warnAt != 0 {
+						t.Errorf("第 %d 个回复时再次警告", i+1)
+					}
+					warnAt = i + 1
+				}
+			}
+			if warnAt != tt.warnAt {
+				t.Errorf("第 %d 个回复时警告，期望 %d", warnAt, tt.warnAt)
+			}
+			ev := strings.Join(a.evidence(), "\n")
+			if len(tt.evidence) == 0 && ev != "" {
+				t.Errorf("不应有证据: %s", ev)
+			}
+			for _, want := range tt.evidence {
+				if !strings.Contains(ev, want) {
+					t.Errorf("证据中没有 %q:\n%s", want, ev)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+var (
+	adaptiveTimeout bool    //-adaptive-timeout 根据观测到的往返时间调整每次请求的超时时间
+	adaptiveFloor   int64   //自适应超时时间的下限(毫秒)
+	adaptiveK       float64 //自适应超时时间中平滑往返时间的倍数
+)
+
+const (
+	rtoMinSamples = 3                //样本数达到该值前使用 -w
+	rtoMax        = 60 * time.Second //自适应超时时间的上限，与TCP一致
+)
+
+// rttEstimator 按RFC 6298维护平滑往返时间(SRTT)及其偏差(RTTVAR)
+// 只负责估计，不依赖命令行参数
+type rttEstimator struct {
+	srtt    time.Duration
+	rttvar  time.Duration
+	samples int
+	backoff int //最近一次成功后连续超时的次数，每次超时把超时时间加倍
+}
+
+// 加入一次成功请求的往返时间
+func (e *rttEstimator) update(rtt time.Duration) {
+	if e.samples == 0 {
+		e.srtt, e.rttvar = rtt, rtt/2
+	} else {
+		diff := e.srtt - rtt
+		if diff < 0 {
+			diff = -diff
+		}
+		e.rttvar = (3*e.rttvar + diff) / 4
+		e.srtt = (7*e.srtt + rtt) / 8
+	}
+	e.samples++
+	e.backoff = 0
+}
+
+// 记录一次超时，下一次的超时时间加倍(Karn算法)，避免路径变慢后一直超时而得不到新样本
+func (e *rttEstimator) timedOut() {
+	if e.samples > 0 && e.backoff < 16 {
+		e.backoff++
+	}
+}
+
+// 样本是否足够
+func (e *rttEstimator) ready() bool {
+	return e.samples >= rtoMinSamples
+}
+
+// 超时时间：max(floor, k×SRTT + 4×RTTVAR)，连续超时时加倍，不超过rtoMax
+func (e *rttEstimator) timeout(k float64, floor time.Duration) time.Duration {
+	d := time.Duration(k*float64(e.srtt)) + 4*e.rttvar
+	if d < floor {
+		d = floor
+	}
+	for i := 0; i < e.backoff && d < rtoMax; i++ {
+		d *= 2
+	}
+	if d > rtoMax {
+		d = rtoMax
+	}
+	return d
+}
+
+// 本次请求的超时时间：-adaptive-timeout 且样本足够时按估计值，否则为 -w
+func (p *Pinger) probeTimeout() time.Duration {
+	if adaptiveTimeout && p.rto.ready() {
+		return p.rto.timeout(adaptiveK, time.Duration(adaptiveFloor)*time.Millisecond)
+	}
+	return time.Duration(p.Timeout) * time.Millisecond
+}
+
+// -v 时输出本次请求使用的超时时间
+func (p *Pinger) printProbeTimeout(d time.Duration) {
+	if !adaptiveTimeout || !verbose {
+		return
+	}
+	if !p.rto.ready() {
+		p.printf("    超时时间: %dms (样本不足，使用 -w)\n", d.Milliseconds())
+		return
+	}
+	p.printf("    超时时间: %dms (SRTT=%s RTTVAR=%s)\n", d.Milliseconds(), fmtRTT(p.rto.srtt), fmtRTT(p.rto.rttvar))
+}
+
+// 以毫秒显示，保留三位小数
+func fmtRTT(d time.Duration) string {
+	return fmt.Sprintf("%.3fms", float64(d)/float64(time.Millisecond))
+}
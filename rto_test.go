@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 按RFC 6298更新SRTT、RTTVAR
+func TestRTTEstimator(t *testing.T) {
+	ms := time.Millisecond
+	steps := []struct {
+		rtt          time.Duration
+		srtt, rttvar time.Duration
+		ready        bool
+	}{
+		{100 * ms, 100 * ms, 50 * ms, false}, //第一个样本：RTTVAR=R/2
+		{100 * ms, 100 * ms, 37500 * time.Microsecond, false},
+		{20 * ms, 90 * ms, 48125 * time.Microsecond, true}, //RTTVAR=(3×37.5+80)/4，SRTT=(7×100+20)/8
+	}
+	var e rttEstimator
+	for i, st := range steps {
+		e.update(st.rtt)
+		if e.srtt != st.srtt || e.rttvar != st.rttvar || e.ready() != st.ready {
+			t.Errorf("第 %d 个样本 %v: SRTT=%v RTTVAR=%v ready=%v，期望 %v、%v、%v", i+1, st.rtt, e.srtt, e.rttvar, e.ready(), st.srtt, st.rttvar, st.ready)
+		}
+	}
+}
+
+func TestRTOTimeout(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name    string
+		srtt    time.Duration
+		rttvar  time.Duration
+		backoff int
+		k       float64
+		floor   time.Duration
+		want    time.Duration
+	}{
+		{"k×SRTT+4×RTTVAR", 100 * ms, 10 * ms, 0, 2, 20 * ms, 240 * ms},
+		{"k可以是小数", 100 * ms, 10 * ms, 0, 1.5, 20 * ms, 190 * ms},
+		{"不低于下限", 2 * ms, 100 * time.Microsecond, 0, 2, 20 * ms, 20 * ms},
+		{"卫星链路", 900 * ms, 30 * ms, 0, 2, 20 * ms, 1920 * ms},
+		{"连续超时加倍", 100 * ms, 10 * ms, 2, 2, 20 * ms, 960 * ms},
+		{"下限也加倍", 2 * ms, 0, 1, 2, 20 * ms, 40 * ms},
+		{"不超过上限", 100 * ms, 10 * ms, 16, 2, 20 * ms, rtoMax},
+		{"估计值超过上限", 40 * time.Second, 10 * time.Second, 0, 2, 20 * ms, rtoMax},
+	}
+	for _, tt := range tests {
+		e := rttEstimator{srtt: tt.srtt, rttvar: tt.rttvar, samples: rtoMinSamples, backoff: tt.backoff}
+		if got := e.timeout(tt.k, tt.floor); got != tt.want {
+			t.Errorf("%s: timeout = %v，期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 超时加倍到16次为止，成功后恢复；没有样本时超时不累计
+func TestRTOBackoff(t *testing.T) {
+	var e rttEstimator
+	e.timedOut()
+	if e.backoff != 0 {
+		t.Errorf("没有样本时 backoff = %d", e.backoff)
+	}
+	e.update(10 * time.Millisecond)
+	for i := 0; i < 20; i++ {
+		e.timedOut()
+	}
+	if e.backoff != 16 {
+		t.Errorf("backoff = %d，期望 16", e.backoff)
+	}
+	e.update(10 * time.Millisecond)
+	if e.backoff != 0 {
+		t.Errorf("成功后 backoff = %d", e.backoff)
+	}
+}
+
+// 样本足够前使用 -w，之后使用估计值；每次请求的超时时间记录为timeout_ms，-v 时输出
+func TestAdaptiveTimeout(t *testing.T) {
+	needRawSocket(t)
+	path := filepath.Join(t.TempDir(), "rec.jsonl")
+	parseArgs(t, "-n", "5", "-i", "0", "-w", "1000", "-adaptive-timeout", "-adaptive-floor", "20", "127.0.0.1")
+	if err := startRecord(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stopRecord)
+	p := newPinger("127.0.0.1")
+	p.Quiet = true
+	p.Run()
+	stopRecord()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int64
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n")[1:] {
+		var r probeRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r.Timeout)
+	}
+	want := []int64{1000, 1000, 1000, 20, 20} //回环的往返时间远小于下限
+	if len(got) != len(want) {
+		t.Fatalf("timeout_ms = %v，期望 %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("timeout_ms = %v，期望 %v", got, want)
+			break
+		}
+	}
+
+	parseArgs(t, "-w", "1000", "-adaptive-timeout", "-v", "127.0.0.1")
+	q := &Pinger{Timeout: 1000}
+	out, _ := captureOutput(t, func() { q.printProbeTimeout(q.probeTimeout()) })
+	if out != "    超时时间: 1000ms (样本不足，使用 -w)\n" {
+		t.Errorf("样本不足时输出 %q", out)
+	}
+	for i := 0; i < rtoMinSamples; i++ {
+		q.rto.update(100 * time.Millisecond)
+	}
+	out, _ = captureOutput(t, func() { q.printProbeTimeout(q.probeTimeout()) })
+	if !strings.HasPrefix(out, "    超时时间: 312ms (SRTT=100.000ms RTTVAR=28.125ms)") {
+		t.Errorf("样本足够时输出 %q", out)
+	}
+}
+
+func TestAdaptiveFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-adaptive-timeout", "-adaptive-floor", "5", "-adaptive-k", "1.5", "x"}, ""},
+		{[]string{"-adaptive-floor", "0", "x"}, "-adaptive-floor: 无效的取值 0"},
+		{[]string{"-adaptive-k", "-1", "x"}, "-adaptive-k: 无效的取值 -1"},
+	}
+	for _, tt := range tests {
+		errs := argErrors(t, tt.args...)
+		if tt.err == "" && len(errs) > 0 || tt.err != "" && !hasArgError(errs, tt.err) {
+			t.Errorf("参数 %q: 错误 %q，期望 %q", tt.args, errs, tt.err)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	scheduleExpr string //-schedule 按cron表达式定时执行，如 "0 * * * *"
+	schedulePath string //-o 每次定时执行后追加统计摘要的文件(JSONL)
+)
+
+// cron表达式各字段的取值范围
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"分钟", 0, 59},
+	{"小时", 0, 23},
+	{"日", 1, 31},
+	{"月", 1, 12},
+	{"星期", 0, 7}, //0和7都表示星期日
+}
+
+// 解析后的cron表达式，每个字段为允许取值的位图
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool //日、星期字段为 *
+}
+
+// 解析标准的5字段cron表达式：分 时 日 月 星期
+// 每个字段支持 *、数字、a-b 范围、/n 步长及逗号分隔的列表；
+// 日与星期都不为 * 时，满足其一即可(与cron相同)；5年内不会触发的表达式视为错误
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron表达式 %q 应为5个字段(分 时 日 月 星期)", expr)
+	}
+	var bits [5]uint64
+	for i, f := range fields {
+		b, err := parseCronField(f, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("cron表达式 %q 的%s字段: %v", expr, cronFields[i].name, err)
+		}
+		bits[i] = b
+	}
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1 //7与0都表示星期日
+	}
+	c := &cronSchedule{
+		minute: bits[0], hour: bits[1], dom: bits[2], month: bits[3], dow: bits[4],
+		domAny: fields[2] == "*", dowAny: fields[4] == "*",
+	}
+	if c.next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron表达式 %q 不会触发(如 2月30日)", expr)
+	}
+	return c, nil
+}
+
+// 解析cron表达式的一个字段，返回允许取值的位图
+func parseCronField(f string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(f, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("无效的步长 %q", stepText)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("无效的取值 %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("无效的取值 %q", part)
+				}
+			} else if hasStep {
+				hi = max //如 5/15 表示从5开始每15
+			}
+			if lo < min || hi > max || lo > hi {
+				return 0, fmt.Errorf("取值 %q 超出范围 %d-%d", part, min, max)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// 日期是否满足日与星期字段
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// t之后(不含t所在的分钟)第一个满足表达式的时间，5年内没有时返回零值(如 2月30日)
+func (c *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// 一次定时执行的统计摘要
+type scheduleSummary struct {
+	SchemaVersion string          `json:"schema_version" desc:"输出格式的版本，见 -print-schema"`
+	Start         time.Time       `json:"start" desc:"本次执行开始的时间"`
+	End           time.Time       `json:"end" desc:"本次执行结束的时间"`
+	Targets       []baselineEntry `json:"targets" desc:"各目标的统计"`
+}
+
+// 把一次定时执行的统计摘要追加到文件，每次一行
+func appendScheduleSummary(path string, s scheduleSummary) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// 按 -schedule 定时执行：每到触发时间对全部目标完整执行一次，
+// 结束后把统计摘要追加到 -o 指定的文件，再等待下一次触发，直到按下Ctrl+C
+func runSchedule(hosts []string) int {
+	sched, _ := parseCron(scheduleExpr) //已在getArgs中校验
+	for {
+		next := sched.next(time.Now())
+		fmt.Printf("下一次执行: %s\n", next.Format("2006-01-02 15:04"))
+		select {
+		case <-time.After(time.Until(next)):
+		case <-stop:
+			return 0
+		}
+
+		fmt.Printf("\n=== 定时执行 %s ===\n", next.Format("2006-01-02 15:04"))
+		var pingers []*Pinger
+		for _, host := range hosts {
+			pingers = append(pingers, newPinger(host))
+		}
+		s := scheduleSummary{SchemaVersion: schemaVersion, Start: time.Now()}
+		runPingers(pingers)
+		s.End = time.Now()
+		influxBatch(pingers)
+		graphiteBatch(pingers)
+		cloudWatchBatch(pingers)
+		if schedulePath != "" {
+			for _, p := range pingers {
+				s.Targets = append(s.Targets, baselineEntryOf(p))
+			}
+			if err := appendScheduleSummary(schedulePath, s); err != nil {
+				fmt.Fprintf(os.Stderr, "写入统计摘要失败: %v\n", err)
+			}
+		}
+		if stopped() {
+			return 0
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{"* * * *", "应为5个字段"},
+		{"60 * * * *", "分钟字段: 取值 \"60\" 超出范围 0-59"},
+		{"* 5-3 * * *", "小时字段: 取值 \"5-3\" 超出范围 0-23"},
+		{"* * 0 * *", "日字段: 取值 \"0\" 超出范围 1-31"},
+		{"*/0 * * * *", "无效的步长 \"0\""},
+		{"* * * jan *", "月字段: 无效的取值 \"jan\""},
+		{"0 0 30 2 *", "不会触发"},
+	}
+	for _, tt := range tests {
+		if _, err := parseCron(tt.expr); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("parseCron(%q) 的错误 = %v，应包含 %q", tt.expr, err, tt.err)
+		}
+	}
+}
+
+// 下一次触发时间不含当前所在的分钟；日与星期都指定时满足其一即可
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		expr, now, want string
+	}{
+		{"* * * * *", "2024-03-01 09:00:30", "2024-03-01 09:01:00"},
+		{"0 * * * *", "2024-03-01 09:00:00", "2024-03-01 10:00:00"},
+		{"*/15 * * * *", "2024-03-01 09:16:00", "2024-03-01 09:30:00"},
+		{"5/20 8-9 * * *", "2024-03-01 09:46:00", "2024-03-02 08:05:00"},
+		{"30 2 1,15 * *", "2024-03-01 03:00:00", "2024-03-15 02:30:00"},
+		{"0 0 * * 7", "2024-03-01 00:00:00", "2024-03-03 00:00:00"},  //7与0都表示星期日
+		{"0 0 13 * 5", "2024-03-01 12:00:00", "2024-03-08 00:00:00"}, //13日或星期五
+		{"0 0 29 2 *", "2024-03-01 00:00:00", "2028-02-29 00:00:00"},
+		{"59 23 31 12 *", "2024-12-31 23:59:00", "2025-12-31 23:59:00"},
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tt.expr, err)
+		}
+		if got := c.next(at(tt.now)); !got.Equal(at(tt.want)) {
+			t.Errorf("%q 在 %s 之后 = %s，期望 %s", tt.expr, tt.now, got.Format("2006-01-02 15:04:05"), tt.want)
+		}
+	}
+}
+
+// 每次执行的统计摘要追加为一行JSON
+func TestAppendScheduleSummary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.jsonl")
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		p := baselinePinger("192.0.2.1", 10, -1, 30)
+		s := scheduleSummary{Start: start.Add(time.Duration(i) * time.Hour), End: start.Add(time.Duration(i)*time.Hour + 3*time.Second)}
+		s.Targets = append(s.Targets, baselineEntryOf(p))
+		if err := appendScheduleSummary(path, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("摘要文件:\n%s", data)
+	}
+	var s scheduleSummary
+	if err := json.Unmarshal([]byte(lines[1]), &s); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Start.Equal(start.Add(time.Hour)) || len(s.Targets) != 1 || s.Targets[0].Target != "192.0.2.1" || s.Targets[0].Sent != 3 || s.Targets[0].Received != 2 {
+		t.Errorf("第二行 = %+v", s)
+	}
+}
+
+func TestScheduleFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-schedule", "61 * * * *", "x"}, "-schedule: cron表达式 \"61 * * * *\" 的分钟字段"},
+		{[]string{"-schedule", "0 * * * *", "-t", "x"}, "参数 -schedule 与 -t 不能同时指定"},
+		{[]string{"-schedule", "0 * * * *", "-state", "s.json", "x"}, "参数 -schedule 不能与 -config、-state"},
+		{[]string{"-o", "summary.jsonl", "x"}, "参数 -o 需要与 -schedule 同时使用"},
+	}
+	for _, tt := range tests {
+		if errs := argErrors(t, tt.args...); !hasArgError(errs, tt.err) {
+			t.Errorf("参数 %q: 错误 %q，期望 %q", tt.args, errs, tt.err)
+		}
+	}
+	if errs := argErrors(t, "-schedule", "*/5 * * * 1-5", "-o", "summary.jsonl", "-n", "10", "x"); len(errs) != 0 {
+		t.Errorf("合法的参数报错: %q", errs)
+	}
+}
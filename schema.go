@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// 机器可读输出(-record 的JSONL/CSV、基线文件、-schedule 的统计摘要)的格式版本
+// 增加字段时增加次版本号，删除或改名字段、改变字段含义时增加主版本号
+const schemaVersion = "1.0"
+
+var printSchema bool //-print-schema 输出当前版本的JSON Schema后退出
+
+// 由结构体生成JSON Schema：字段名取json标签，说明取desc标签，没有omitempty的字段为必需字段
+// 不允许出现未定义的字段，输出与结构体不一致时校验即失败
+func jsonSchemaOf(t reflect.Type) map[string]any {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonSchemaOf(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": jsonSchemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchemaOf(t.Elem())}
+	case reflect.Struct:
+		props := map[string]any{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			s := jsonSchemaOf(f.Type)
+			if desc := f.Tag.Get("desc"); desc != "" {
+				s["description"] = desc
+			}
+			props[name] = s
+			if !strings.Contains(","+opts+",", ",omitempty,") {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{"type": "object", "properties": props, "required": required, "additionalProperties": false}
+	}
+	panic(fmt.Sprintf("jsonSchemaOf: 不支持的类型 %s", t))
+}
+
+// 当前版本的完整JSON Schema
+// JSONL记录文件的每一行为 start、探测记录或事件之一，CSV的列见 x-csv-columns
+func outputSchema() map[string]any {
+	start := jsonSchemaOf(reflect.TypeOf(runStartRecord{}))
+	start["properties"].(map[string]any)["event"].(map[string]any)["const"] = "start"
+	return map[string]any{
+		"$schema":        "https://json-schema.org/draft/2020-12/schema",
+		"title":          "ping 机器可读输出",
+		"version":        schemaVersion,
+		"x-csv-columns":  csvHeader,
+		"x-version-rule": "增加字段时增加次版本号，删除或改名字段、改变字段含义时增加主版本号",
+		"$defs": map[string]any{
+			"start":            start,
+			"probe":            jsonSchemaOf(reflect.TypeOf(probeRecord{})),
+			"event":            jsonSchemaOf(reflect.TypeOf(eventRecord{})),
+			"baseline_file":    jsonSchemaOf(reflect.TypeOf(baselineFile{})),
+			"schedule_summary": jsonSchemaOf(reflect.TypeOf(scheduleSummary{})),
+			"record_line": map[string]any{
+				"description": "-record 的JSONL文件中的一行",
+				"oneOf": []any{
+					map[string]any{"$ref": "#/$defs/start"},
+					map[string]any{"$ref": "#/$defs/probe"},
+					map[string]any{"$ref": "#/$defs/event"},
+				},
+			},
+		},
+	}
+}
+
+// 输出JSON Schema
+func printOutputSchema() {
+	data, _ := json.MarshalIndent(outputSchema(), "", "  ")
+	fmt.Println(string(data))
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 以 -print-schema 的输出为准，不直接使用outputSchema的结果
+func printedSchema(t *testing.T) map[string]any {
+	t.Helper()
+	stdout, _ := captureOutput(t, printOutputSchema)
+	var schema map[string]any
+	if err := json.Unmarshal([]byte(stdout), &schema); err != nil {
+		t.Fatalf("-print-schema 的输出不是JSON: %v\n%s", err, stdout)
+	}
+	return schema
+}
+
+// 按JSON Schema校验一个值，只实现outputSchema用到的关键字
+func validateSchema(root, schema map[string]any, v any, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		def, ok := root["$defs"].(map[string]any)[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: 无法解析 %s", path, ref)
+		}
+		return validateSchema(root, def, v, path)
+	}
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		var matched []int
+		var errs []string
+		for i, s := range oneOf {
+			if err := validateSchema(root, s.(map[string]any), v, path); err != nil {
+				errs = append(errs, err.Error())
+			} else {
+				matched = append(matched, i)
+			}
+		}
+		if len(matched) != 1 {
+			return fmt.Errorf("%s: 匹配了oneOf中的 %v，应恰好匹配一个: %s", path, matched, strings.Join(errs, "；"))
+		}
+		return nil
+	}
+	if c, ok := schema["const"]; ok && v != c {
+		return fmt.Errorf("%s: %v，应为 %v", path, v, c)
+	}
+	switch schema["type"] {
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: %v 不是字符串", path, v)
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				return fmt.Errorf("%s: %q 不是date-time", path, s)
+			}
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: %v 不是布尔值", path, v)
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if _, err := n.Int64(); !ok || err != nil {
+			return fmt.Errorf("%s: %v 不是整数", path, v)
+		}
+	case "number":
+		n, ok := v.(json.Number)
+		if _, err := n.Float64(); !ok || err != nil {
+			return fmt.Errorf("%s: %v 不是数值", path, v)
+		}
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: %v 不是数组", path, v)
+		}
+		for i, item := range items {
+			if err := validateSchema(root, schema["items"].(map[string]any), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: %v 不是对象", path, v)
+		}
+		props, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				return fmt.Errorf("%s: 缺少必需的字段 %s", path, name)
+			}
+		}
+		for name, value := range obj {
+			s, ok := props[name].(map[string]any)
+			if !ok {
+				switch extra := schema["additionalProperties"].(type) {
+				case bool:
+					return fmt.Errorf("%s: 未定义的字段 %s", path, name)
+				case map[string]any:
+					s = extra
+				}
+			}
+			if err := validateSchema(root, s, value, path+"."+name); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s: 未知的类型 %v", path, schema["type"])
+	}
+	return nil
+}
+
+// 以json.Number解析，区分整数与小数
+func decodeJSON(t *testing.T, data []byte) any {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("%v: %s", err, data)
+	}
+	return v
+}
+
+func def(t *testing.T, schema map[string]any, name string) map[string]any {
+	t.Helper()
+	d, ok := schema["$defs"].(map[string]any)[name].(map[string]any)
+	if !ok {
+		t.Fatalf("Schema中没有 %s", name)
+	}
+	return d
+}
+
+// 各定义的字段均有说明，版本号与程序一致
+func TestPrintSchema(t *testing.T) {
+	schema := printedSchema(t)
+	if schema["version"] != schemaVersion {
+		t.Errorf("version = %v，期望 %s", schema["version"], schemaVersion)
+	}
+	var cols []string
+	for _, c := range schema["x-csv-columns"].([]any) {
+		cols = append(cols, c.(string))
+	}
+	if strings.Join(cols, ",") != strings.Join(csvHeader, ",") {
+		t.Errorf("x-csv-columns = %v，期望 %v", cols, csvHeader)
+	}
+	var names []string
+	for name := range schema["$defs"].(map[string]any) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if got := strings.Join(names, " "); got != "baseline_file event probe record_line schedule_summary start" {
+		t.Errorf("$defs = %s", got)
+	}
+	var checkDesc func(path string, s map[string]any)
+	checkDesc = func(path string, s map[string]any) {
+		props, _ := s["properties"].(map[string]any)
+		for name, p := range props {
+			p := p.(map[string]any)
+			if d, _ := p["description"].(string); d == "" {
+				t.Errorf("%s.%s 没有说明", path, name)
+			}
+			if items, ok := p["items"].(map[string]any); ok {
+				checkDesc(path+"."+name+"[]", items)
+			}
+		}
+	}
+	for _, name := range names {
+		checkDesc(name, def(t, schema, name))
+	}
+}
+
+// 实际写入的记录文件中每一行(start、探测记录及各种事件)都符合record_line
+func TestRecordLinesValidate(t *testing.T) {
+	schema := printedSchema(t)
+	line := def(t, schema, "record_line")
+	path := filepath.Join(t.TempDir(), "rec.jsonl")
+	parseArgs(t, "-n", "6", "-i", "0", "-w", "50", "-priority", "0", "127.0.0.1=gw")
+	targetLabels = map[string]map[string]string{}
+	t.Cleanup(func() { targetLabels = map[string]map[string]string{} })
+	hosts := getArgOfHost()
+	if err := startRecord(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stopRecord)
+
+	//探测记录及path_change
+	predial(t, hosts[0], &ttlConn{mockConn: newMockConn(), ttls: []int{64, 64, 60, 60}})
+	p := newPinger(hosts[0])
+	p.Quiet = true
+	p.Run()
+	//超时的探测记录
+	predial(t, hosts[0], &dropConn{&seqConn{mockConn: newMockConn()}})
+	q := newPinger(hosts[0])
+	q.Quiet = true
+	q.Run()
+	//其他事件
+	av := stateTarget("127.0.0.1", true, false, false, false, true).Stats.Snapshot().Avail
+	recordAvailability("127.0.0.1", p.Labels, av, true)
+	recordOutage("127.0.0.1", p.Labels, av)
+	av.Down = true
+	recordOutage("127.0.0.1", nil, av)
+	captureOutput(t, func() {
+		announceDNSEvent("web.test", nil, dnsEventError, errNXDomain)
+		announceDNSEvent("web.test", nil, dnsEventRecovered, nil)
+	})
+	stopRecord()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	kinds := map[string]int{}
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		v := decodeJSON(t, sc.Bytes())
+		if err := validateSchema(schema, line, v, fmt.Sprintf("第%d行", n)); err != nil {
+			t.Errorf("%v\n%s", err, sc.Text())
+		}
+		obj := v.(map[string]any)
+		kind, _ := obj["event"].(string)
+		if kind == "" {
+			kind = obj["outcome"].(string)
+		}
+		kinds[kind]++
+	}
+	for _, kind := range []string{"start", "success", "timeout", "path_change", "availability", "outage_start", "outage_end", dnsEventError, dnsEventRecovered} {
+		if kinds[kind] == 0 {
+			t.Errorf("记录中没有 %s: %v", kind, kinds)
+		}
+	}
+}
+
+// 基线文件及 -schedule 的统计摘要符合各自的定义
+func TestSummaryFilesValidate(t *testing.T) {
+	schema := printedSchema(t)
+	dir := t.TempDir()
+	parseArgs(t, "127.0.0.1")
+	pingers := []*Pinger{stateTarget("10.0.0.1", true, true, false), stateTarget("10.0.0.2", false)}
+
+	basePath := filepath.Join(dir, "base.json")
+	if err := saveBaseline(basePath, pingers); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateSchema(schema, def(t, schema, "baseline_file"), decodeJSON(t, data), "基线文件"); err != nil {
+		t.Error(err)
+	}
+
+	sumPath := filepath.Join(dir, "summary.jsonl")
+	s := scheduleSummary{SchemaVersion: schemaVersion, Start: time.Now(), End: time.Now()}
+	for _, p := range pingers {
+		s.Targets = append(s.Targets, baselineEntryOf(p))
+	}
+	if err := appendScheduleSummary(sumPath, s); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(sumPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateSchema(schema, def(t, schema, "schedule_summary"), decodeJSON(t, data), "统计摘要"); err != nil {
+		t.Error(err)
+	}
+}
+
+// 与定义不一致的行校验失败：未定义的字段、缺少必需的字段、类型不符
+func TestSchemaRejects(t *testing.T) {
+	schema := printedSchema(t)
+	line := def(t, schema, "record_line")
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"未定义的字段", `{"time":"2024-03-01T09:00:00Z","target":"10.0.0.1","seq":1,"rtt_ms":3,"outcome":"success","jitter_ms":1}`},
+		{"缺少必需的字段", `{"time":"2024-03-01T09:00:00Z","target":"10.0.0.1","seq":1,"outcome":"success"}`},
+		{"类型不符", `{"time":"2024-03-01T09:00:00Z","target":"10.0.0.1","seq":"1","rtt_ms":3,"outcome":"success"}`},
+		{"小数的整数字段", `{"time":"2024-03-01T09:00:00Z","target":"10.0.0.1","seq":1,"rtt_ms":3.5,"outcome":"success"}`},
+		{"时间格式", `{"time":"2024-03-01 09:00","target":"10.0.0.1","seq":1,"rtt_ms":3,"outcome":"success"}`},
+		{"start缺少版本", `{"time":"2024-03-01T09:00:00Z","event":"start","args":[]}`},
+	}
+	for _, tt := range tests {
+		if err := validateSchema(schema, line, decodeJSON(t, []byte(tt.line)), tt.name); err == nil {
+			t.Errorf("%s: 校验通过了 %s", tt.name, tt.line)
+		}
+	}
+	ok := `{"time":"2024-03-01T09:00:00Z","target":"10.0.0.1","seq":1,"rtt_ms":3,"outcome":"success","labels":{"name":"gw"}}`
+	if err := validateSchema(schema, line, decodeJSON(t, []byte(ok)), "正确的行"); err != nil {
+		t.Error(err)
+	}
+}
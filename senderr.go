@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// 有请求因本地错误发送失败时的退出码，与路径丢包(0)、与基线相比变差(1)、参数错误(2)区分
+const exitSendError = 3
+
+// 常见的本地发送错误及说明
+var sendErrnoText = []struct {
+	errno syscall.Errno
+	text  string
+}{
+	{syscall.ENETUNREACH, "网络不可达"},
+	{syscall.EHOSTUNREACH, "主机不可达"},
+	{syscall.EHOSTDOWN, "主机已关闭"},
+	{syscall.ENETDOWN, "网络已关闭"},
+	{syscall.ENOBUFS, "无缓冲区空间"},
+	{syscall.EMSGSIZE, "报文过长"},
+	{syscall.EPERM, "操作被禁止(可能被防火墙拦截)"},
+	{syscall.EACCES, "权限不足"},
+}
+
+// 是否有目标因本地错误发送失败
+func sendFailed(pingers []*Pinger) bool {
+	for _, p := range pingers {
+		if p.Stats.Snapshot().SendErrors > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// 发送错误的说明，不认识的错误原样输出
+func sendErrorText(err error) string {
+	for _, e := range sendErrnoText {
+		if errors.Is(err, e.errno) {
+			return e.text
+		}
+	}
+	return err.Error()
+}
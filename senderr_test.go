@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// 与net.IPConn.Write返回的错误相同的包装
+func writeErr(errno syscall.Errno) error {
+	return &net.OpError{Op: "write", Net: "ip4:icmp", Err: os.NewSyscallError("sendto", errno)}
+}
+
+func TestSendErrorText(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{writeErr(syscall.ENETUNREACH), "网络不可达"},
+		{writeErr(syscall.EHOSTUNREACH), "主机不可达"},
+		{writeErr(syscall.ENOBUFS), "无缓冲区空间"},
+		{writeErr(syscall.EMSGSIZE), "报文过长"},
+		{writeErr(syscall.EPERM), "操作被禁止(可能被防火墙拦截)"},
+		{&sendError{writeErr(syscall.ENETDOWN)}, "网络已关闭"},
+		{fmt.Errorf("第 3 次: %w", writeErr(syscall.EHOSTDOWN)), "主机已关闭"},
+		{syscall.EACCES, "权限不足"},
+		{writeErr(syscall.EINVAL), "write ip4:icmp: sendto: " + syscall.EINVAL.Error()}, //不认识的错误原样输出
+		{errors.New("其他错误"), "其他错误"},
+	}
+	for _, tt := range tests {
+		if got := sendErrorText(tt.err); got != tt.want {
+			t.Errorf("sendErrorText(%v) = %q，期望 %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+// 本机发送失败与超时分别计数，统计信息中分行显示，丢失数包括两者
+func TestSendErrorCounting(t *testing.T) {
+	p := &Pinger{Addr: "127.0.0.1", Stats: newStatistics()}
+	for _, err := range []error{nil, writeErr(syscall.ENOBUFS), nil, writeErr(syscall.ENETUNREACH), os.ErrDeadlineExceeded} {
+		p.Stats.addSent()
+		switch {
+		case err == nil:
+			p.Stats.addSuccess(1)
+			continue
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			p.Stats.addTimeout()
+		default:
+			p.Stats.addSendError(&sendError{err})
+		}
+		p.Stats.addFailure()
+	}
+
+	ss := p.Stats.Snapshot()
+	if ss.Sent != 5 || ss.Received != 2 || ss.Lost != 3 || ss.SendErrors != 2 || ss.Timeouts != 1 || ss.LastSendError != "网络不可达" {
+		t.Errorf("统计 = %+v", ss)
+	}
+	stdout, _ := captureOutput(t, p.printSummary)
+	for _, want := range []string{
+		"其中 2 个请求本机发送失败(最近一次: 网络不可达)，并非路径丢失。",
+		"其中 1 个请求超时。",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("输出中没有 %q:\n%s", want, stdout)
+		}
+	}
+	if !sendFailed([]*Pinger{p}) {
+		t.Error("sendFailed = false")
+	}
+}
+
+// 只有超时时不显示发送失败，也不按发送失败退出
+func TestTimeoutsOnly(t *testing.T) {
+	needRawSocket(t)
+	parseArgs(t, "-n", "2", "-i", "0", "-w", "50", "203.0.113.1")
+	p := newPinger("203.0.113.1")
+	stdout, _ := captureOutput(t, p.Run)
+	if ss := p.Stats.Snapshot(); ss.SendErrors != 0 || ss.Timeouts != 2 || ss.Lost != 2 {
+		t.Errorf("统计 = %+v", ss)
+	}
+	if !strings.Contains(stdout, "请求超时。") || strings.Contains(stdout, "本机发送失败") || sendFailed([]*Pinger{p}) {
+		t.Errorf("只有超时时按发送失败处理:\n%s", stdout)
+	}
+}
@@ -0,0 +1,5 @@
+//go:build !windows
+
+package main
+
+const sharedSocketSupported = true //多目标并发时可以共享一个原始套接字
@@ -0,0 +1,4 @@
+package main
+
+// Windows上ReadMsgIP不可用，ReadFrom会去掉IP头，多目标并发时仍每个目标单独建立连接
+const sharedSocketSupported = false
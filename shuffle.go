@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	shuffle        bool    //-shuffle 打乱目标的探测顺序
+	shuffleSeed    int64   //-seed 随机种子，0表示按当前时间选取
+	intervalJitter float64 //-interval-jitter 每次请求的间隔随机浮动的百分比
+)
+
+// 打乱顺序及间隔抖动共用的随机数，在第一次使用时按种子创建
+var (
+	seededRand *rand.Rand
+	seededOnce sync.Once
+	seededMu   sync.Mutex
+)
+
+// 取得按 -seed 创建的随机数，未指定种子时选取一个并输出到标准错误以便复现
+func seeded() *rand.Rand {
+	seededOnce.Do(func() {
+		seed := shuffleSeed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+			if shuffle {
+				fmt.Fprintf(os.Stderr, "随机种子: %d (以 -seed %d 复现探测顺序)\n", seed, seed)
+			}
+		}
+		seededRand = rand.New(rand.NewSource(seed))
+	})
+	return seededRand
+}
+
+// 探测顺序：-shuffle 时为随机排列，否则为原顺序
+// 只改变探测的先后，统计及输出仍按目标对应
+func probeOrder(n int) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	if shuffle {
+		seededMu.Lock()
+		shuffleIndexes(seeded(), order)
+		seededMu.Unlock()
+	}
+	return order
+}
+
+// 以r打乱order，相同的种子得到相同的顺序
+func shuffleIndexes(r *rand.Rand, order []int) {
+	r.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+}
+
+// 间隔在 ±pct% 范围内均匀浮动，pct为0时原样返回
+func jitter(r *rand.Rand, d time.Duration, pct float64) time.Duration {
+	if pct <= 0 || d <= 0 {
+		return d
+	}
+	f := 1 + (r.Float64()*2-1)*pct/100
+	return time.Duration(float64(d) * f)
+}
+
+// 本次请求前的间隔：-interval-jitter 时随机浮动
+func jitterInterval(d time.Duration) time.Duration {
+	if intervalJitter <= 0 {
+		return d
+	}
+	seededMu.Lock()
+	defer seededMu.Unlock()
+	return jitter(seeded(), d, intervalJitter)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 重新按当前的 -seed 创建随机数
+func resetSeeded(t *testing.T) {
+	seededOnce, seededRand = sync.Once{}, nil
+	t.Cleanup(func() { seededOnce, seededRand = sync.Once{}, nil })
+}
+
+func TestJitter(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	tests := []struct {
+		d      time.Duration
+		pct    float64
+		lo, hi time.Duration
+	}{
+		{time.Second, 0, time.Second, time.Second}, //不抖动
+		{0, 50, 0, 0}, //-i 0 时仍不等待
+		{time.Second, 10, 900 * time.Millisecond, 1100 * time.Millisecond},
+		{time.Second, 100, 0, 2 * time.Second},
+		{200 * time.Millisecond, 25, 150 * time.Millisecond, 250 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		var sum time.Duration
+		var below, above int
+		const n = 10000
+		for i := 0; i < n; i++ {
+			got := jitter(r, tt.d, tt.pct)
+			if got < tt.lo || got > tt.hi {
+				t.Fatalf("jitter(%v, %g) = %v，超出 [%v, %v]", tt.d, tt.pct, got, tt.lo, tt.hi)
+			}
+			sum += got
+			if got < tt.d {
+				below++
+			} else if got > tt.d {
+				above++
+			}
+		}
+		//均匀分布：平均值接近原间隔，两侧的次数大致相同
+		if mean := sum / n; tt.pct > 0 && tt.d > 0 {
+			if diff := mean - tt.d; diff < -tt.d/100 || diff > tt.d/100 {
+				t.Errorf("jitter(%v, %g) 的平均值 = %v", tt.d, tt.pct, mean)
+			}
+			if below < n*45/100 || above < n*45/100 {
+				t.Errorf("jitter(%v, %g): 小于 %d 次，大于 %d 次", tt.d, tt.pct, below, above)
+			}
+		}
+	}
+}
+
+// 相同的种子得到相同的顺序及抖动，不同的种子顺序不同
+func TestShuffleDeterministic(t *testing.T) {
+	order := func(seed int64) []int {
+		o := make([]int, 254)
+		for i := range o {
+			o[i] = i
+		}
+		shuffleIndexes(rand.New(rand.NewSource(seed)), o)
+		return o
+	}
+	a, b := order(42), order(42)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("相同的种子得到了不同的顺序")
+	}
+	if reflect.DeepEqual(a, order(43)) {
+		t.Error("不同的种子得到了相同的顺序")
+	}
+	sorted := append([]int(nil), a...)
+	sort.Ints(sorted)
+	for i, v := range sorted {
+		if v != i {
+			t.Fatalf("打乱后不是原序号的排列: %v", a)
+		}
+	}
+
+	r1, r2 := rand.New(rand.NewSource(7)), rand.New(rand.NewSource(7))
+	for i := 0; i < 100; i++ {
+		if x, y := jitter(r1, time.Second, 20), jitter(r2, time.Second, 20); x != y {
+			t.Fatalf("第 %d 次: 相同的种子得到 %v、%v", i, x, y)
+		}
+	}
+}
+
+// 未指定 -shuffle 时按原顺序；指定 -seed 时顺序可以复现，未指定时输出选取的种子
+func TestProbeOrder(t *testing.T) {
+	identity := []int{0, 1, 2, 3, 4, 5, 6, 7}
+
+	parseArgs(t, "10.0.0.0/29")
+	resetSeeded(t)
+	if got := probeOrder(8); !reflect.DeepEqual(got, identity) {
+		t.Errorf("没有 -shuffle 时 probeOrder = %v", got)
+	}
+
+	parseArgs(t, "-shuffle", "-seed", "42", "10.0.0.0/29")
+	resetSeeded(t)
+	want := append([]int(nil), identity...)
+	shuffleIndexes(rand.New(rand.NewSource(42)), want)
+	_, stderr := captureOutput(t, func() {
+		if got := probeOrder(8); !reflect.DeepEqual(got, want) {
+			t.Errorf("-seed 42: probeOrder = %v，期望 %v", got, want)
+		}
+	})
+	if stderr != "" {
+		t.Errorf("指定了 -seed 时输出了 %q", stderr)
+	}
+
+	parseArgs(t, "-shuffle", "10.0.0.0/29")
+	resetSeeded(t)
+	_, stderr = captureOutput(t, func() { probeOrder(8) })
+	if !strings.HasPrefix(stderr, "随机种子: ") || !strings.Contains(stderr, "(以 -seed ") {
+		t.Errorf("未指定 -seed 时标准错误 = %q", stderr)
+	}
+}
+
+func TestJitterFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-interval-jitter", "100", "x"}, ""},
+		{[]string{"-interval-jitter", "101", "x"}, "-interval-jitter: 取值 101 超出范围 0-100"},
+		{[]string{"-interval-jitter", "-5", "x"}, "-interval-jitter: 取值 -5 超出范围 0-100"},
+	}
+	for _, tt := range tests {
+		errs := argErrors(t, tt.args...)
+		if tt.err == "" && len(errs) > 0 || tt.err != "" && !hasArgError(errs, tt.err) {
+			t.Errorf("参数 %q: 错误 %q，期望 %q", tt.args, errs, tt.err)
+		}
+	}
+}
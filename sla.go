@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	slaRTT    time.Duration //-sla-rtt 往返时间的SLA阈值，0表示不检查
+	slaTarget float64       //-sla-target 要求往返时间不超过阈值的请求所占的百分比
+)
+
+// 未达到往返时间SLA时的退出码
+const exitSLAMissed = 5
+
+// 往返时间SLA的结果：丢失的请求计为未达标
+type slaResult struct {
+	within   int //往返时间不超过阈值的请求数
+	exceeded int //收到回复但超过阈值的请求数
+	lost     int
+}
+
+// 请求总数
+func (r slaResult) total() int {
+	return r.within + r.exceeded + r.lost
+}
+
+// 达标率(百分比)，没有请求时为100
+func (r slaResult) percent() float64 {
+	if r.total() == 0 {
+		return 100
+	}
+	return float64(r.within) / float64(r.total()) * 100
+}
+
+// 按阈值统计各次请求
+func slaOf(samples []probeSample, threshold time.Duration) slaResult {
+	var r slaResult
+	limit := threshold.Milliseconds()
+	for _, s := range samples {
+		switch {
+		case !s.OK:
+			r.lost++
+		case s.RTT <= limit:
+			r.within++
+		default:
+			r.exceeded++
+		}
+	}
+	return r
+}
+
+// 各目标是否都达到往返时间SLA
+func slaMet(pingers []*Pinger) bool {
+	for _, p := range pingers {
+		if p.Err == nil && slaOf(p.Stats.sampleList(), slaRTT).percent() < slaTarget {
+			return false
+		}
+	}
+	return true
+}
+
+// 输出往返时间SLA的结果
+func (p *Pinger) printSLA() {
+	r := slaOf(p.Stats.sampleList(), slaRTT)
+	verdict := "达标"
+	if r.percent() < slaTarget {
+		verdict = "未达标"
+	}
+	p.printf("SLA: %.2f%% 的请求往返时间 ≤ %dms (目标 %g%%，%s)，%d 个超过阈值，%d 个丢失。\n",
+		r.percent(), slaRTT.Milliseconds(), slaTarget, verdict, r.exceeded, r.lost)
+}
+
+// RunUntilLoss 持续ping，直到累计丢失率超过threshold(0.0–1.0)或ctx被取消，返回此时的统计数据
+// 丢失率超过阈值时返回的错误为nil；ctx被取消时返回ctx.Err()；无法开始ping时返回原因
+// 本机发送失败比路径丢包更严重，出现一次即停止并返回错误
+// 每次请求前检查，丢失率在一次请求失败后立即生效，不等待后续请求
+func (p *Pinger) RunUntilLoss(ctx context.Context, threshold float64) (StatsSnapshot, error) {
+	if threshold < 0 || threshold > 1 || math.IsNaN(threshold) {
+		return p.Stats.Snapshot(), errors.New("丢失率阈值应在 0.0–1.0 之间")
+	}
+	p.Count = math.MaxInt
+	p.done = ctx.Done()
+	p.until = func() bool {
+		ss := p.Stats.Snapshot()
+		return ss.SendErrors > 0 || ss.Sent > 0 && float64(ss.Lost)/float64(ss.Sent) > threshold
+	}
+	defer func() { p.done, p.until = nil, nil }()
+
+	p.Run()
+	if p.Err != nil {
+		return p.Stats.Snapshot(), p.Err
+	}
+	if ss := p.Stats.Snapshot(); ss.SendErrors > 0 {
+		return ss, fmt.Errorf("本机发送失败: %s", ss.LastSendError)
+	}
+	if !p.until() {
+		return p.Stats.Snapshot(), ctx.Err()
+	}
+	return p.Stats.Snapshot(), nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 丢失率超过阈值时停止并返回nil；ctx结束前未超过时返回ctx.Err()
+// -chaos-loss 100 时每次请求都丢失，回环地址则全部回复
+func TestRunUntilLoss(t *testing.T) {
+	needRawSocket(t)
+	tests := []struct {
+		name      string
+		args      []string
+		threshold float64
+		ctxWait   time.Duration //0表示不设截止时间
+		err       error
+		sent      int //-1表示不检查
+		lost      int
+	}{
+		{"全部丢失", []string{"-chaos-loss", "100"}, 0.5, 0, nil, 1, 1},
+		{"第一次丢失", []string{"-chaos-loss", "100"}, 0, 0, nil, 1, 1},
+		{"未超过阈值", nil, 0, 50 * time.Millisecond, context.DeadlineExceeded, -1, 0},
+		{"阈值为1时不会超过", []string{"-chaos-loss", "100"}, 1, 50 * time.Millisecond, context.DeadlineExceeded, -1, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseArgs(t, append(tt.args, "-i", "0", "-w", "10", "127.0.0.1")...)
+			ctx := context.Background()
+			if tt.ctxWait > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ctxWait)
+				defer cancel()
+			}
+			p := newPinger("127.0.0.1")
+			p.Quiet = true
+			p.Interval = 1
+			ss, err := p.RunUntilLoss(ctx, tt.threshold)
+			if !errors.Is(err, tt.err) || tt.err == nil && err != nil {
+				t.Fatalf("err = %v，期望 %v", err, tt.err)
+			}
+			if tt.sent >= 0 && ss.Sent != tt.sent {
+				t.Errorf("发送了 %d 次，期望 %d", ss.Sent, tt.sent)
+			}
+			if tt.lost >= 0 && ss.Lost != tt.lost {
+				t.Errorf("丢失 %d 次，期望 %d", ss.Lost, tt.lost)
+			}
+		})
+	}
+}
+
+func TestRunUntilLossThreshold(t *testing.T) {
+	parseArgs(t, "127.0.0.1")
+	for _, threshold := range []float64{-0.1, 1.5} {
+		p := newPinger("127.0.0.1")
+		if ss, err := p.RunUntilLoss(context.Background(), threshold); err == nil || ss.Sent != 0 {
+			t.Errorf("阈值 %v: %+v, %v", threshold, ss, err)
+		}
+	}
+}
+
+// 丢失的请求计为未达标，阈值按毫秒比较，等于阈值时达标
+func TestSLAOf(t *testing.T) {
+	samples := func(rtts ...int64) []probeSample {
+		var list []probeSample
+		for _, rtt := range rtts {
+			list = append(list, probeSample{RTT: rtt, OK: rtt >= 0})
+		}
+		return list
+	}
+	tests := []struct {
+		name    string
+		samples []probeSample
+		want    slaResult
+		percent float64
+	}{
+		{"没有请求", nil, slaResult{}, 100},
+		{"全部达标", samples(1, 50, 49), slaResult{within: 3}, 100},
+		{"超过阈值及丢失", samples(10, 51, -1, 20), slaResult{within: 2, exceeded: 1, lost: 1}, 50},
+		{"全部丢失", samples(-1, -1), slaResult{lost: 2}, 0},
+	}
+	for _, tt := range tests {
+		r := slaOf(tt.samples, 50*time.Millisecond+900*time.Microsecond)
+		if r != tt.want || r.percent() != tt.percent {
+			t.Errorf("%s: slaOf = %+v (%.2f%%)，期望 %+v (%.2f%%)", tt.name, r, r.percent(), tt.want, tt.percent)
+		}
+	}
+}
+
+// 任一目标低于 -sla-target 时未达标，无法探测的目标不参与判断
+func TestSLAMet(t *testing.T) {
+	target := func(results ...bool) *Pinger {
+		p := &Pinger{Addr: "192.0.2.1", Stats: newStatistics()}
+		t0 := time.Now().Add(-time.Minute)
+		for i, ok := range results {
+			p.Stats.addRecord(t0.Add(time.Duration(i)*time.Second), 10, ok)
+		}
+		return p
+	}
+	parseArgs(t, "-sla-rtt", "50ms", "-sla-target", "75", "x")
+	good, bad := target(true, true, true, false), target(true, false, false, true)
+	failed := target(false)
+	failed.Err = errors.New("找不到主机")
+	if !slaMet([]*Pinger{good, failed}) {
+		t.Error("75% 达标时 slaMet = false")
+	}
+	if slaMet([]*Pinger{good, bad}) {
+		t.Error("50% 达标时 slaMet = true")
+	}
+
+	stdout, _ := captureOutput(t, bad.printSLA)
+	if want := "SLA: 50.00% 的请求往返时间 ≤ 50ms (目标 75%，未达标)，0 个超过阈值，2 个丢失。"; !strings.Contains(stdout, want) {
+		t.Errorf("输出:\n%s\n应包含 %q", stdout, want)
+	}
+}
+
+func TestSLAFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-sla-rtt", "-5ms", "x"}, "-sla-rtt: 无效的取值 -5ms"},
+		{[]string{"-sla-rtt", "50ms", "-sla-target", "101", "x"}, "-sla-target: 取值 101 超出范围 0-100"},
+		{[]string{"-sla-rtt", "50ms", "-sla-target", "-1", "x"}, "-sla-target: 取值 -1 超出范围 0-100"},
+	}
+	for _, tt := range tests {
+		if errs := argErrors(t, tt.args...); !hasArgError(errs, tt.err) {
+			t.Errorf("参数 %q: 错误 %q，期望 %q", tt.args, errs, tt.err)
+		}
+	}
+	if errs := argErrors(t, "-sla-rtt", "50ms", "-sla-target", "99.9", "x"); len(errs) != 0 {
+		t.Errorf("合法的参数报错: %q", errs)
+	}
+}
@@ -0,0 +1,48 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+// 设置发送报文的IP选项
+func setIPOptions(conn net.Conn, opts []byte) error {
+	return errors.New("当前平台不支持设置IP选项")
+}
+
+// 设置发送报文的TTL
+func setTTL(conn net.Conn, ttl int) error {
+	return errors.New("当前平台不支持设置TTL")
+}
+
+// 设置发送报文IP头中的TOS字段(DSCP及ECN)
+func setTOS(conn net.Conn, tos int) error {
+	return errors.New("当前平台不支持设置TOS")
+}
+
+// 允许向广播地址发送(SO_BROADCAST)
+func setBroadcast(conn net.Conn) error {
+	return errors.New("当前平台不支持发送广播")
+}
+
+// 在默认接口上加入IPv4组播组(IP_ADD_MEMBERSHIP)
+func joinMulticast(conn net.Conn, group net.IP) error {
+	return errors.New("当前平台不支持加入组播组")
+}
+
+// 在指定接口上加入IPv6组播组(IPV6_JOIN_GROUP)
+func joinMulticast6(conn net.Conn, group net.IP, ifindex int) error {
+	return errors.New("当前平台不支持加入组播组")
+}
+
+// 接收报文时以控制消息附带IPv6头中的跳数限制(IPV6_RECVHOPLIMIT)
+func setRecvHopLimit(conn net.Conn) error {
+	return errors.New("当前平台不支持读取跳数限制")
+}
+
+// 从控制消息中取出跳数限制，没有时返回-1
+func hopLimitOf(oob []byte) int {
+	return -1
+}
@@ -0,0 +1,139 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// 设置发送报文的IP选项
+func setIPOptions(conn net.Conn, opts []byte) error {
+	raw, err := syscallConnOf(conn)
+	if err != nil {
+		return err
+	}
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptString(int(fd), syscall.IPPROTO_IP, syscall.IP_OPTIONS, string(opts))
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+// 设置发送报文的TTL
+func setTTL(conn net.Conn, ttl int) error {
+	raw, err := syscallConnOf(conn)
+	if err != nil {
+		return err
+	}
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TTL, ttl)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+// 设置发送报文IP头中的TOS字段(DSCP及ECN)
+func setTOS(conn net.Conn, tos int) error {
+	raw, err := syscallConnOf(conn)
+	if err != nil {
+		return err
+	}
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, tos)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+// 允许向广播地址发送(SO_BROADCAST)
+func setBroadcast(conn net.Conn) error {
+	raw, err := syscallConnOf(conn)
+	if err != nil {
+		return err
+	}
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+// 在默认接口上加入IPv4组播组(IP_ADD_MEMBERSHIP)
+func joinMulticast(conn net.Conn, group net.IP) error {
+	raw, err := syscallConnOf(conn)
+	if err != nil {
+		return err
+	}
+	mreq := &syscall.IPMreq{}
+	copy(mreq.Multiaddr[:], group.To4())
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptIPMreq(int(fd), syscall.IPPROTO_IP, syscall.IP_ADD_MEMBERSHIP, mreq)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+// 在指定接口上加入IPv6组播组(IPV6_JOIN_GROUP)，ifindex为0时由内核选择接口
+func joinMulticast6(conn net.Conn, group net.IP, ifindex int) error {
+	raw, err := syscallConnOf(conn)
+	if err != nil {
+		return err
+	}
+	mreq := &syscall.IPv6Mreq{Interface: uint32(ifindex)}
+	copy(mreq.Multiaddr[:], group.To16())
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptIPv6Mreq(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_JOIN_GROUP, mreq)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+// 接收报文时以控制消息附带IPv6头中的跳数限制(IPV6_RECVHOPLIMIT)
+func setRecvHopLimit(conn net.Conn) error {
+	raw, err := syscallConnOf(conn)
+	if err != nil {
+		return err
+	}
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, ipv6RecvHopLimit, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+// 从控制消息中取出跳数限制，没有时返回-1
+func hopLimitOf(oob []byte) int {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return -1
+	}
+	for _, m := range msgs {
+		if m.Header.Level == syscall.IPPROTO_IPV6 && m.Header.Type == ipv6HopLimit && len(m.Data) >= 4 {
+			return int(*(*int32)(unsafe.Pointer(&m.Data[0])))
+		}
+	}
+	return -1
+}
@@ -0,0 +1,36 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import (
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// 控制消息中的IPV6_HOPLIMIT为int，其他控制消息跳过
+func TestHopLimitOf(t *testing.T) {
+	cmsg := func(level, typ int32, v int32) []byte {
+		b := make([]byte, syscall.CmsgSpace(4))
+		h := (*syscall.Cmsghdr)(unsafe.Pointer(&b[0]))
+		h.Level, h.Type = level, typ
+		h.SetLen(syscall.CmsgLen(4))
+		*(*int32)(unsafe.Pointer(&b[syscall.CmsgLen(0)])) = v
+		return b
+	}
+	tests := []struct {
+		name string
+		oob  []byte
+		want int
+	}{
+		{"跳数限制", cmsg(syscall.IPPROTO_IPV6, ipv6HopLimit, 255), 255},
+		{"其他控制消息在前", append(cmsg(syscall.IPPROTO_IPV6, ipv6HopLimit+1, 7), cmsg(syscall.IPPROTO_IPV6, ipv6HopLimit, 1)...), 1},
+		{"没有控制消息", nil, -1},
+		{"只有其他层", cmsg(syscall.SOL_SOCKET, ipv6HopLimit, 64), -1},
+	}
+	for _, tt := range tests {
+		if got := hopLimitOf(tt.oob); got != tt.want {
+			t.Errorf("%s: hopLimitOf = %d，期望 %d", tt.name, got, tt.want)
+		}
+	}
+}
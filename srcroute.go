@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// 松散源路由相关常量
+const (
+	ipOptEOL      = 0   //选项列表结束
+	ipOptNOP      = 1   //无操作，用于填充
+	ipOptRR       = 7   //记录路由
+	ipOptLSRR     = 131 //松散源路由
+	ipOptSSRR     = 137 //严格源路由
+	maxSourceHops = 9   //IP头最多容纳9个中间地址
+	srcRouteHint  = 3   //连续超时多少次后提示源路由报文可能被丢弃
+)
+
+var (
+	sourceRouteArg string   //-j 参数，逗号分隔的中间地址
+	sourceRoute    []net.IP //松散源路由的中间地址
+)
+
+// 解析 -j 参数，最多9个IPv4地址
+func parseSourceRoute(arg string) ([]net.IP, error) {
+	var hops []net.IP
+	for _, s := range strings.Split(arg, ",") {
+		s = strings.TrimSpace(s)
+		ip := net.ParseIP(s).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("-j: %q 不是有效的IPv4地址", s)
+		}
+		hops = append(hops, ip)
+	}
+	if len(hops) > maxSourceHops {
+		return nil, fmt.Errorf("-j: 最多只能指定 %d 个中间地址，实际为 %d 个", maxSourceHops, len(hops))
+	}
+	return hops, nil
+}
+
+// 构造松散源路由选项：type(131) + length + pointer(4) + 地址列表，末尾以EOL补齐到4字节
+// 选项位于IP头中，由内核计算IP头校验和，ICMP校验和不覆盖IP选项
+func buildLSRROption(hops []net.IP) []byte {
+	opt := []byte{ipOptLSRR, byte(3 + 4*len(hops)), 4}
+	for _, ip := range hops {
+		opt = append(opt, ip.To4()...)
+	}
+	for len(opt)%4 != 0 {
+		opt = append(opt, ipOptEOL)
+	}
+	return opt
+}
+
+// 从回复的IP头中取出选项部分，IP头不完整时返回nil
+func replyIPOptions(buf []byte) []byte {
+	if len(buf) < 20 {
+		return nil
+	}
+	ihl := int(buf[0]&0x0f) * 4
+	if ihl <= 20 || ihl > len(buf) {
+		return nil
+	}
+	return buf[20:ihl]
+}
+
+// 从IP选项中找出路由类选项(源路由或记录路由)，返回其中记录的地址
+func parseRouteOption(opts []byte) (kind byte, route []net.IP) {
+	for i := 0; i < len(opts); {
+		switch opts[i] {
+		case ipOptEOL:
+			return 0, nil
+		case ipOptNOP:
+			i++
+			continue
+		}
+		if i+1 >= len(opts) {
+			return 0, nil
+		}
+		l := int(opts[i+1])
+		if l < 2 || i+l > len(opts) {
+			return 0, nil //长度非法
+		}
+		if t := opts[i]; t == ipOptLSRR || t == ipOptSSRR || t == ipOptRR {
+			if l < 3 {
+				return 0, nil
+			}
+			for j := i + 3; j+4 <= i+l; j += 4 {
+				route = append(route, net.IP(append([]byte(nil), opts[j:j+4]...)))
+			}
+			return t, route
+		}
+		i += l
+	}
+	return 0, nil
+}
+
+// 输出回复中携带的路由
+func printReplyRoute(buf []byte) {
+	kind, route := parseRouteOption(replyIPOptions(buf))
+	if kind == 0 {
+		return
+	}
+	hops := make([]string, len(route))
+	for i, ip := range route {
+		hops[i] = ip.String()
+	}
+	fmt.Printf("    路由: %s\n", strings.Join(hops, " -> "))
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseSourceRoute(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want string
+		err  string
+	}{
+		{"10.0.0.1", "10.0.0.1", ""},
+		{"10.0.0.1, 10.0.0.2,10.0.0.3", "10.0.0.1 10.0.0.2 10.0.0.3", ""},
+		{"1.1.1.1,2.2.2.2,3.3.3.3,4.4.4.4,5.5.5.5,6.6.6.6,7.7.7.7,8.8.8.8,9.9.9.9", "1.1.1.1 2.2.2.2 3.3.3.3 4.4.4.4 5.5.5.5 6.6.6.6 7.7.7.7 8.8.8.8 9.9.9.9", ""},
+		{"1.1.1.1,2.2.2.2,3.3.3.3,4.4.4.4,5.5.5.5,6.6.6.6,7.7.7.7,8.8.8.8,9.9.9.9,10.10.10.10", "", "最多只能指定 9 个中间地址，实际为 10 个"},
+		{"gw.example", "", `"gw.example" 不是有效的IPv4地址`},
+		{"2001:db8::1", "", "不是有效的IPv4地址"},
+		{"10.0.0.1,", "", `"" 不是有效的IPv4地址`},
+	}
+	for _, tt := range tests {
+		hops, err := parseSourceRoute(tt.arg)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parseSourceRoute(%q) 的错误 = %v，应包含 %q", tt.arg, err, tt.err)
+			}
+			continue
+		}
+		var got []string
+		for _, ip := range hops {
+			got = append(got, ip.String())
+		}
+		if err != nil || strings.Join(got, " ") != tt.want {
+			t.Errorf("parseSourceRoute(%q) = %v, %v，应为 %s", tt.arg, got, err, tt.want)
+		}
+	}
+}
+
+// 选项为 131 + 长度 + 指针4 + 地址，以EOL补齐到4字节
+func TestBuildLSRROption(t *testing.T) {
+	tests := []struct {
+		hops []net.IP
+		want []byte
+	}{
+		{ips("10.0.0.1"), []byte{131, 7, 4, 10, 0, 0, 1, 0}},
+		{ips("10.0.0.1", "10.0.0.2"), []byte{131, 11, 4, 10, 0, 0, 1, 10, 0, 0, 2, 0}},
+	}
+	for _, tt := range tests {
+		if got := buildLSRROption(tt.hops); !bytes.Equal(got, tt.want) {
+			t.Errorf("buildLSRROption(%v) = %v，应为 %v", tt.hops, got, tt.want)
+		}
+	}
+	nine := buildLSRROption(ips("1.1.1.1", "2.2.2.2", "3.3.3.3", "4.4.4.4", "5.5.5.5", "6.6.6.6", "7.7.7.7", "8.8.8.8", "9.9.9.9"))
+	if len(nine) != 40 || nine[1] != 39 {
+		t.Errorf("9个地址的选项长度 = %d(长度字段 %d)，IP选项最多40字节", len(nine), nine[1])
+	}
+}
+
+func TestParseRouteOption(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []byte
+		kind  byte
+		route string
+	}{
+		{"松散源路由", []byte{131, 11, 12, 10, 0, 0, 1, 10, 0, 0, 2, 0}, ipOptLSRR, "10.0.0.1 10.0.0.2"},
+		{"NOP之后的记录路由", []byte{1, 7, 7, 8, 192, 0, 2, 1}, ipOptRR, "192.0.2.1"},
+		{"严格源路由", []byte{137, 7, 4, 10, 0, 0, 1, 0}, ipOptSSRR, "10.0.0.1"},
+		{"跳过其他选项", []byte{148, 4, 0, 0, 131, 7, 4, 10, 0, 0, 1, 0}, ipOptLSRR, "10.0.0.1"},
+		{"EOL", []byte{0, 131, 7, 4, 10, 0, 0, 1}, 0, ""},
+		{"长度超出", []byte{131, 20, 4, 10, 0, 0, 1, 0}, 0, ""},
+		{"长度过短", []byte{131, 1, 4, 0}, 0, ""},
+		{"没有长度", []byte{131}, 0, ""},
+		{"没有选项", nil, 0, ""},
+	}
+	for _, tt := range tests {
+		kind, route := parseRouteOption(tt.opts)
+		var got []string
+		for _, ip := range route {
+			got = append(got, ip.String())
+		}
+		if kind != tt.kind || strings.Join(got, " ") != tt.route {
+			t.Errorf("%s: parseRouteOption = %d %v，应为 %d %s", tt.name, kind, got, tt.kind, tt.route)
+		}
+	}
+}
+
+// 回复中带源路由选项时输出其中的路由，没有选项时不输出
+func TestPrintReplyRoute(t *testing.T) {
+	hdr := []byte{0x48, 0, 0, 40, 0, 0, 0, 0, 64, 1, 0, 0, 192, 0, 2, 1, 127, 0, 0, 1}
+	opts := []byte{131, 11, 12, 10, 0, 0, 1, 10, 0, 0, 2, 0}
+	reply := append(append(hdr, opts...), 0, 0, 0, 0, 0, 0, 0, 0)
+	if got := replyIPOptions(reply); !bytes.Equal(got, opts) {
+		t.Fatalf("replyIPOptions = %v，应为 %v", got, opts)
+	}
+	stdout, _ := captureOutput(t, func() { printReplyRoute(reply) })
+	if stdout != "    路由: 10.0.0.1 -> 10.0.0.2\n" {
+		t.Errorf("输出 %q", stdout)
+	}
+
+	plain := []byte{0x45, 0, 0, 28, 0, 0, 0, 0, 64, 1, 0, 0, 192, 0, 2, 1, 127, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0}
+	if stdout, _ := captureOutput(t, func() { printReplyRoute(plain) }); stdout != "" {
+		t.Errorf("没有选项时输出 %q", stdout)
+	}
+	for _, buf := range [][]byte{nil, plain[:10], {0x4f, 0, 0, 0}} {
+		if opts := replyIPOptions(buf); opts != nil {
+			t.Errorf("replyIPOptions(% x) = %v，IP头不完整时应为nil", buf, opts)
+		}
+	}
+}
+
+func TestSourceRouteFlag(t *testing.T) {
+	t.Cleanup(func() { sourceRoute = nil })
+	parseArgs(t, "-j", "10.0.0.1,10.0.0.2", "192.0.2.1")
+	if len(sourceRoute) != 2 || !sourceRoute[1].Equal(net.IPv4(10, 0, 0, 2)) {
+		t.Errorf("sourceRoute = %v", sourceRoute)
+	}
+	if errs := argErrors(t, "-j", "gw.example", "192.0.2.1"); !hasArgError(errs, "-j: \"gw.example\" 不是有效的IPv4地址") {
+		t.Errorf("错误 = %q", errs)
+	}
+}
+
+func ips(ss ...string) []net.IP {
+	var r []net.IP
+	for _, s := range ss {
+		r = append(r, net.ParseIP(s).To4())
+	}
+	return r
+}
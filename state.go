@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var statePath string //-state 持久化的监控状态文件
+
+const (
+	stateVersion  = 1
+	stateInterval = 10 * time.Second //定期写入状态文件的间隔
+)
+
+// 状态文件
+type monitorState struct {
+	Version int           `json:"version"`
+	Saved   time.Time     `json:"saved"`
+	Targets []targetState `json:"targets"`
+}
+
+// 单个目标的累计状态
+type targetState struct {
+	Target              string    `json:"target"`
+	Sent                int       `json:"sent"`
+	Received            int       `json:"received"`
+	Lost                int       `json:"lost"`
+	MinMs               int64     `json:"min_ms"`
+	MaxMs               int64     `json:"max_ms"`
+	TotalMs             float64   `json:"total_ms"`
+	LastSeq             int       `json:"last_seq"` //最后一次请求的序号，-1表示尚未发送
+	Known               bool      `json:"known"`    //是否已有探测结果
+	Down                bool      `json:"down"`
+	Since               time.Time `json:"since"` //进入当前在线/离线状态的时间
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	Start               time.Time `json:"start"`
+	DowntimeMs          int64     `json:"downtime_ms"` //已结束的离线时长
+	LongestMs           int64     `json:"longest_ms"`
+	Outages             int       `json:"outages"`
+	PausedMs            int64     `json:"paused_ms"`
+	SendErrors          int       `json:"send_errors,omitempty"`
+	Timeouts            int       `json:"timeouts,omitempty"`
+}
+
+// 导出累计状态
+func (s *Statistics) exportState(target string) targetState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := &s.avail
+	return targetState{
+		Target: target, Sent: s.sendCount, Received: s.successCount, Lost: s.failCount,
+		MinMs: s.minRTT(), MaxMs: s.maxTs.Load(), TotalMs: s.totalTs, LastSeq: s.sendCount - 1,
+		Known: a.known, Down: a.down, Since: a.since, ConsecutiveFailures: s.consecFail,
+		Start: a.start, DowntimeMs: a.downtime.Milliseconds(), LongestMs: a.longest.Milliseconds(),
+		Outages: a.outages, PausedMs: a.paused.Milliseconds(),
+		SendErrors: s.sendErrs, Timeouts: s.timeouts,
+	}
+}
+
+// 从状态文件恢复累计状态
+// 上次保存到本次启动之间没有探测，按暂停处理，不计入运行时长及离线时长；
+// 恢复后在线/离线状态与保存时相同，之后的探测结果与之一致时不会产生状态变化
+func (s *Statistics) restoreState(ts targetState, saved, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sendCount, s.successCount, s.failCount = ts.Sent, ts.Received, ts.Lost
+	s.totalTs, s.totalComp = ts.TotalMs, 0
+	if ts.Received > 0 {
+		s.minTs.Store(ts.MinMs)
+	} else {
+		s.minTs.Store(-1)
+	}
+	s.maxTs.Store(ts.MaxMs)
+	s.consecFail = ts.ConsecutiveFailures
+	s.sendErrs, s.timeouts = ts.SendErrors, ts.Timeouts
+
+	a := &s.avail
+	a.start, a.known, a.down, a.since = ts.Start, ts.Known, ts.Down, ts.Since
+	a.downtime = time.Duration(ts.DowntimeMs) * time.Millisecond
+	a.longest = time.Duration(ts.LongestMs) * time.Millisecond
+	a.outages = ts.Outages
+	a.paused = time.Duration(ts.PausedMs) * time.Millisecond
+	a.periods = nil
+	if a.down {
+		a.periods = []outagePeriod{{Start: a.since}}
+	}
+	if !a.start.IsZero() {
+		a.pausedAt = saved
+		a.resume(now)
+	}
+}
+
+// 读取状态文件并恢复各目标的累计状态
+// 文件不存在时从零开始；文件损坏或版本不兼容时给出警告并忽略
+func loadState(path string, pingers []*Pinger) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 读取状态文件失败，从零开始: %v\n", err)
+		return
+	}
+	var st monitorState
+	if err := json.Unmarshal(data, &st); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 状态文件 %s 已损坏，从零开始: %v\n", path, err)
+		return
+	}
+	if st.Version != stateVersion {
+		fmt.Fprintf(os.Stderr, "警告: 状态文件 %s 的版本 %d 不兼容(需要 %d)，从零开始\n", path, st.Version, stateVersion)
+		return
+	}
+
+	byTarget := map[string]targetState{}
+	for _, ts := range st.Targets {
+		byTarget[ts.Target] = ts
+	}
+	now := time.Now()
+	for _, p := range pingers {
+		if ts, ok := byTarget[p.Arg]; ok {
+			p.Stats.restoreState(ts, st.Saved, now)
+			p.outage = ts.Known && ts.Down //保存时已离线的，恢复后不再写入outage_start事件
+		}
+	}
+}
+
+// 写入状态文件，先写临时文件再改名，写入中途退出不会留下损坏的文件
+func saveState(path string, pingers []*Pinger) error {
+	st := monitorState{Version: stateVersion, Saved: time.Now()}
+	for _, p := range pingers {
+		st.Targets = append(st.Targets, p.Stats.exportState(p.Arg))
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// 定期写入状态文件，返回的函数停止定期写入并写入最终状态
+func startStateSaver(path string, pingers []*Pinger) func() {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		t := time.NewTicker(stateInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if err := saveState(path, pingers); err != nil {
+					fmt.Fprintf(os.Stderr, "写入状态文件失败: %v\n", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+		if err := saveState(path, pingers); err != nil {
+			fmt.Fprintf(os.Stderr, "写入状态文件失败: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 按结果序列构造已有统计的目标
+func stateTarget(arg string, results ...bool) *Pinger {
+	p := &Pinger{Arg: arg, Stats: newStatistics()}
+	t0 := time.Now().Add(-time.Minute)
+	for i, ok := range results {
+		p.Stats.addRecord(t0.Add(time.Duration(i)*time.Second), int64(10+i), ok)
+	}
+	return p
+}
+
+// 保存后恢复的累计状态与原来一致，两次运行之间的时间按暂停处理
+func TestStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	orig := []*Pinger{
+		stateTarget("127.0.0.1", true, false, true, true),
+		stateTarget("127.0.0.2", true, false, false), //保存时离线
+	}
+	if err := saveState(path, orig); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := []*Pinger{{Arg: "127.0.0.1", Stats: newStatistics()}, {Arg: "127.0.0.2", Stats: newStatistics()}, {Arg: "127.0.0.3", Stats: newStatistics()}}
+	_, stderr := captureOutput(t, func() { loadState(path, restored) })
+	if stderr != "" {
+		t.Errorf("恢复时输出了警告: %s", stderr)
+	}
+	for i, p := range orig {
+		want, got := p.Stats.exportState(p.Arg), restored[i].Stats.exportState(p.Arg)
+		if got.PausedMs < want.PausedMs { //保存到恢复之间的时间计为暂停
+			t.Errorf("%s: 暂停时长 %dms 少于保存时的 %dms", p.Arg, got.PausedMs, want.PausedMs)
+		}
+		//离线中的暂停不计入离线时长，进入离线的时间随之后移
+		if !got.Start.Equal(want.Start) || got.Since.Before(want.Since) || !want.Down && !got.Since.Equal(want.Since) {
+			t.Errorf("%s: 恢复后开始于 %v、状态自 %v，保存时 %v、%v", p.Arg, got.Start, got.Since, want.Start, want.Since)
+		}
+		got.PausedMs, got.Start, got.Since = want.PausedMs, want.Start, want.Since
+		if got != want {
+			t.Errorf("%s: 恢复后 %+v\n保存时 %+v", p.Arg, got, want)
+		}
+	}
+	if ss := restored[2].Stats.Snapshot(); ss.Sent != 0 {
+		t.Errorf("状态文件中没有的目标被恢复了: %+v", ss)
+	}
+	if !restored[1].outage || restored[0].outage {
+		t.Errorf("恢复后的离线状态 = %v、%v，期望 false、true", restored[0].outage, restored[1].outage)
+	}
+}
+
+// 恢复后序号接着上次继续，保存时离线、恢复后收到回复计为一次恢复，只写入outage_end事件
+func TestStateResume(t *testing.T) {
+	needRawSocket(t)
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+	if err := saveState(statePath, []*Pinger{stateTarget("127.0.0.1", true, false, false)}); err != nil {
+		t.Fatal(err)
+	}
+	recPath := filepath.Join(dir, "rec.jsonl")
+	parseArgs(t, "-n", "2", "-i", "0", "-record", recPath, "127.0.0.1")
+	if err := startRecord(recPath); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stopRecord)
+
+	p := newPinger("127.0.0.1")
+	p.Quiet = true
+	loadState(statePath, []*Pinger{p})
+	p.Run()
+	stopRecord()
+
+	data, err := os.ReadFile(recPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seqs []int
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if isEventRecord(line) {
+			continue
+		}
+		var r probeRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("%v: %s", err, line)
+		}
+		seqs = append(seqs, r.Seq)
+	}
+	if len(seqs) != 2 || seqs[0] != 3 || seqs[1] != 4 {
+		t.Errorf("恢复后的请求序号 = %v，期望 [3 4]", seqs)
+	}
+	if ss := p.Stats.Snapshot(); ss.Sent != 5 || ss.Received != 3 || ss.Avail.Down || ss.Avail.Outages != 1 {
+		t.Errorf("恢复后的统计 = %+v", ss)
+	}
+	events := readEvents(t, recPath)
+	if len(events) != 1 || events[0].Event != "outage_end" || events[0].Outages != 1 {
+		t.Errorf("事件 = %+v，期望只有一个outage_end", events)
+	}
+}
+
+// 状态文件损坏或版本不兼容时给出警告并从零开始，文件不存在时不警告
+func TestLoadStateInvalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		body string //空表示文件不存在
+		want string
+	}{
+		{"文件不存在", "", ""},
+		{"不是JSON", "{not json", "已损坏"},
+		{"截断", `{"version":1,"targets":[{"target":"127.0.0.1","sent":`, "已损坏"},
+		{"版本不兼容", `{"version":99,"targets":[{"target":"127.0.0.1","sent":5}]}`, "版本 99 不兼容"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "state"+string(rune('a'+i))+".json")
+			if tt.body != "" {
+				if err := os.WriteFile(path, []byte(tt.body), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			p := &Pinger{Arg: "127.0.0.1", Stats: newStatistics()}
+			_, stderr := captureOutput(t, func() { loadState(path, []*Pinger{p}) })
+			if tt.want == "" && stderr != "" || !strings.Contains(stderr, tt.want) {
+				t.Errorf("标准错误 = %q，期望包含 %q", stderr, tt.want)
+			}
+			if ss := p.Stats.Snapshot(); ss.Sent != 0 {
+				t.Errorf("统计 = %+v，应从零开始", ss)
+			}
+		})
+	}
+}
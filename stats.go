@@ -0,0 +1,387 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statistics 单个目标的统计数据
+// 探测过程中由探测goroutine写入，可同时通过Snapshot()安全读取
+type Statistics struct {
+	mu           sync.Mutex
+	sendCount    int            //已发起请求次数
+	successCount int            //成功请求次数
+	failCount    int            //失败请求次数
+	minTs        atomic.Int64   //成功请求的最小耗时，没有成功请求时为-1
+	maxTs        atomic.Int64   //成功请求的最大耗时
+	totalTs      float64        //成功请求的总耗时，以Kahan求和累计，长时间运行也不会溢出或丢失精度
+	totalComp    float64        //Kahan求和的补偿项
+	lastTs       int64          //最近一次耗时，-1表示最近一次失败
+	consecFail   int            //连续失败次数
+	chaosDropped int            //被 -chaos-loss 丢弃而未发送的请求数，已计入sendCount及failCount
+	checksumErrs int            //ICMP检验和错误的回复数，已计入failCount
+	sendErrs     int            //本地发送失败的请求数，已计入failCount
+	timeouts     int            //等待回复超时的请求数，已计入failCount
+	lastSendErr  string         //最近一次发送失败的说明
+	anomalies    map[string]int //-strict 时各类协议异常的次数，已计入failCount
+	lastErr      string         //无法开始探测的原因
+	avail        availability
+	samples      sampleRing //最近若干次请求的结果，用于计算百分位及输出报告
+	end          time.Time  //回放记录时为最后一条记录的时间，统计截至该时间
+}
+
+// 一次请求的结果
+type probeSample struct {
+	At  time.Time
+	RTT int64 //毫秒，失败时为0
+	OK  bool
+}
+
+// 保留的最近请求结果数，约为 -t 每秒一次时的一天
+// -t、-cycle-period、-state 长期运行时内存不再随请求数增长；最短、最长、平均及丢失率仍统计全部请求，
+// 百分位、耗时分布、SLA等按保留的结果计算
+const maxSamples = 86400
+
+// 定长的请求结果环形缓冲区，写满后覆盖最早的结果
+type sampleRing struct {
+	limit int //容量，0表示maxSamples
+	buf   []probeSample
+	next  int //写满后下一个覆盖的位置
+	total int //写入过的结果总数，同时是下一个结果的序号
+}
+
+func (r *sampleRing) add(s probeSample) {
+	limit := r.limit
+	if limit <= 0 {
+		limit = maxSamples
+	}
+	r.total++
+	if len(r.buf) < limit {
+		r.buf = append(r.buf, s)
+		return
+	}
+	r.buf[r.next] = s
+	r.next = (r.next + 1) % limit
+}
+
+// 序号不小于from的结果中仍保留的部分，按时间顺序复制
+func (r *sampleRing) since(from int) []probeSample {
+	if oldest := r.total - len(r.buf); from < oldest {
+		from = oldest
+	}
+	if from >= r.total {
+		return nil
+	}
+	out := make([]probeSample, 0, r.total-from)
+	skip := from - (r.total - len(r.buf))
+	out = append(out, r.buf[r.next:]...)
+	out = append(out, r.buf[:r.next]...)
+	return out[skip:]
+}
+
+// StatsSnapshot 某一时刻的统计数据
+type StatsSnapshot struct {
+	Sent     int
+	Received int
+	Lost     int
+	Min      int64
+	Max      int64
+	Total    float64
+	Last     int64
+	Avail    AvailSnapshot
+
+	ChaosDropped   int
+	ChecksumErrors int
+	SendErrors     int
+	Timeouts       int
+	LastSendError  string
+	Anomalies      map[string]int
+	LastError      string
+}
+
+func newStatistics() *Statistics {
+	s := &Statistics{lastTs: -1}
+	s.minTs.Store(-1)
+	return s
+}
+
+// 记录发起一次请求
+func (s *Statistics) addSent() {
+	s.mu.Lock()
+	s.sendCount++
+	s.avail.begin(time.Now())
+	s.mu.Unlock()
+}
+
+// 记录一次成功
+func (s *Statistics) addSuccess(ts int64) {
+	s.mu.Lock()
+	s.success(ts, time.Now())
+	s.mu.Unlock()
+}
+
+// 记录一次成功，调用方需持有锁
+// 最短、最长耗时以CAS更新，不依赖锁，同时更新时不会相互覆盖
+func (s *Statistics) success(ts int64, at time.Time) {
+	//最短、最长及平均耗时只统计成功的请求
+	storeMin(&s.minTs, ts)
+	storeMax(&s.maxTs, ts)
+	s.addTotal(float64(ts))
+	s.successCount++
+	s.consecFail = 0
+	s.samples.add(probeSample{At: at, RTT: ts, OK: true})
+	s.lastTs = ts
+	s.avail.record(true, at)
+}
+
+// 以CAS把v记为最小值，当前为-1(还没有成功请求)时直接写入
+func storeMin(a *atomic.Int64, v int64) {
+	for {
+		cur := a.Load()
+		if cur >= 0 && cur <= v {
+			return
+		}
+		if a.CompareAndSwap(cur, v) {
+			return
+		}
+	}
+}
+
+// 以CAS把v记为最大值
+func storeMax(a *atomic.Int64, v int64) {
+	for {
+		cur := a.Load()
+		if cur >= v {
+			return
+		}
+		if a.CompareAndSwap(cur, v) {
+			return
+		}
+	}
+}
+
+// 成功请求的最小耗时，没有成功请求时为0
+func (s *Statistics) minRTT() int64 {
+	return max(s.minTs.Load(), 0)
+}
+
+// Kahan补偿求和，调用方需持有锁
+func (s *Statistics) addTotal(v float64) {
+	y := v - s.totalComp
+	t := s.totalTs + y
+	s.totalComp = (t - s.totalTs) - y
+	s.totalTs = t
+}
+
+// 记录一次失败
+func (s *Statistics) addFailure() {
+	s.mu.Lock()
+	s.failure(time.Now())
+	s.mu.Unlock()
+}
+
+// 记录一次本地发送失败(如网络不可达、无缓冲区空间)，与超时分开统计，需另外调用addFailure
+func (s *Statistics) addSendError(err error) {
+	s.mu.Lock()
+	s.sendErrs++
+	s.lastSendErr = sendErrorText(err)
+	s.mu.Unlock()
+}
+
+// 记录一次等待回复超时，需另外调用addFailure
+func (s *Statistics) addTimeout() {
+	s.mu.Lock()
+	s.timeouts++
+	s.mu.Unlock()
+}
+
+// 记录无法开始探测的原因
+func (s *Statistics) setError(err error) {
+	s.mu.Lock()
+	s.lastErr = err.Error()
+	s.mu.Unlock()
+}
+
+// 记录一次失败，调用方需持有锁
+func (s *Statistics) failure(at time.Time) {
+	s.failCount++
+	s.consecFail++
+	s.samples.add(probeSample{At: at})
+	s.lastTs = -1
+	s.avail.record(false, at)
+}
+
+// 记录一次被 -chaos-loss 丢弃的请求
+func (s *Statistics) addChaosDrop() {
+	s.mu.Lock()
+	s.chaosDropped++
+	s.mu.Unlock()
+}
+
+// 记录一次检验和错误的回复
+func (s *Statistics) addChecksumError() {
+	s.mu.Lock()
+	s.checksumErrs++
+	s.mu.Unlock()
+}
+
+// 记录一次协议异常
+func (s *Statistics) addAnomaly(kind string) {
+	s.mu.Lock()
+	if s.anomalies == nil {
+		s.anomalies = map[string]int{}
+	}
+	s.anomalies[kind]++
+	s.mu.Unlock()
+}
+
+// 按记录中的时间补记一次请求，用于回放
+func (s *Statistics) addRecord(at time.Time, ts int64, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sendCount++
+	s.avail.begin(at)
+	if ok {
+		s.success(ts, at)
+	} else {
+		s.failure(at)
+	}
+	if at.After(s.end) {
+		s.end = at
+	}
+}
+
+// 暂停探测
+func (s *Statistics) pause(at time.Time) {
+	s.mu.Lock()
+	s.avail.pause(at)
+	s.mu.Unlock()
+}
+
+// 恢复探测
+func (s *Statistics) resume(at time.Time) {
+	s.mu.Lock()
+	s.avail.resume(at)
+	s.mu.Unlock()
+}
+
+// 成功请求耗时的第q百分位(最近秩法)，没有成功请求时为0
+func (s *Statistics) percentile(q float64) int64 {
+	return s.percentiles(q)[0]
+}
+
+// 一次计算多个百分位，只排序一次
+func (s *Statistics) percentiles(qs ...float64) []int64 {
+	s.mu.Lock()
+	sorted := make([]int64, 0, len(s.samples.buf))
+	for _, sm := range s.samples.buf {
+		if sm.OK {
+			sorted = append(sorted, sm.RTT)
+		}
+	}
+	s.mu.Unlock()
+
+	out := make([]int64, len(qs))
+	if len(sorted) == 0 {
+		return out
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for i, q := range qs {
+		rank := int(math.Ceil(q / 100 * float64(len(sorted))))
+		if rank < 1 {
+			rank = 1
+		}
+		out[i] = sorted[rank-1]
+	}
+	return out
+}
+
+// 保留的请求结果的副本，按时间顺序
+func (s *Statistics) sampleList() []probeSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.samples.since(0)
+}
+
+// 序号不小于from的请求结果(仍保留的部分)及下一次请求的序号，用于按周期统计
+func (s *Statistics) samplesSince(from int) ([]probeSample, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.samples.since(from), s.samples.total
+}
+
+// 较早的请求结果已被覆盖时，说明百分位等依据的范围，如 "最近 86400 次请求"，否则为空
+func (s *Statistics) sampleNote() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if kept := len(s.samples.buf); kept < s.samples.total {
+		return fmt.Sprintf("最近 %d 次请求", kept)
+	}
+	return ""
+}
+
+// 各次离线的副本
+func (s *Statistics) outagePeriods() []outagePeriod {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if !s.end.IsZero() {
+		now = s.end
+	}
+	return s.avail.outagePeriods(now)
+}
+
+// Snapshot 返回当前统计数据的副本
+func (s *Statistics) Snapshot() StatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if !s.end.IsZero() {
+		now = s.end
+	}
+	var anomalies map[string]int
+	if len(s.anomalies) > 0 {
+		anomalies = make(map[string]int, len(s.anomalies))
+		for k, v := range s.anomalies {
+			anomalies[k] = v
+		}
+	}
+	return StatsSnapshot{
+		Sent:     s.sendCount,
+		Received: s.successCount,
+		Lost:     s.failCount,
+		Min:      s.minRTT(),
+		Max:      s.maxTs.Load(),
+		Total:    s.totalTs,
+		Last:     s.lastTs,
+		Avail:    s.avail.snapshot(now),
+
+		ChaosDropped:   s.chaosDropped,
+		ChecksumErrors: s.checksumErrs,
+		SendErrors:     s.sendErrs,
+		Timeouts:       s.timeouts,
+		LastSendError:  s.lastSendErr,
+		Anomalies:      anomalies,
+		LastError:      s.lastErr,
+	}
+}
+
+// 丢失率(百分比)
+func (ss StatsSnapshot) LossPercent() float64 {
+	if ss.Sent == 0 {
+		return 0
+	}
+	return float64(ss.Lost) / float64(ss.Sent) * 100
+}
+
+// 成功请求的平均耗时，没有成功请求时为0
+func (ss StatsSnapshot) Avg() int64 {
+	if ss.Received == 0 {
+		return 0
+	}
+	return int64(ss.Total / float64(ss.Received))
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSampleRing(t *testing.T) {
+	at := time.Unix(0, 0)
+	tests := []struct {
+		limit, added int
+		from         int
+		want         []int64 //RTT，第i次写入的RTT为i
+		total        int
+	}{
+		{limit: 4, added: 0, from: 0, want: nil, total: 0},
+		{limit: 4, added: 3, from: 0, want: []int64{0, 1, 2}, total: 3},
+		{limit: 4, added: 4, from: 0, want: []int64{0, 1, 2, 3}, total: 4},
+		{limit: 4, added: 6, from: 0, want: []int64{2, 3, 4, 5}, total: 6},
+		{limit: 4, added: 6, from: 4, want: []int64{4, 5}, total: 6},
+		{limit: 4, added: 9, from: 7, want: []int64{7, 8}, total: 9},
+		{limit: 4, added: 9, from: 9, want: nil, total: 9},
+		{limit: 4, added: 11, from: 2, want: []int64{7, 8, 9, 10}, total: 11},
+	}
+	for _, tt := range tests {
+		r := sampleRing{limit: tt.limit}
+		for i := 0; i < tt.added; i++ {
+			r.add(probeSample{At: at, RTT: int64(i), OK: true})
+		}
+		got := r.since(tt.from)
+		if len(r.buf) > tt.limit || r.total != tt.total || len(got) != len(tt.want) {
+			t.Errorf("limit=%d added=%d since(%d): %v, total=%d", tt.limit, tt.added, tt.from, got, r.total)
+			continue
+		}
+		for i := range got {
+			if got[i].RTT != tt.want[i] {
+				t.Errorf("limit=%d added=%d since(%d) = %v，应为 %v", tt.limit, tt.added, tt.from, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+// 超过保留数量后内存不再增长，累计统计仍包含全部请求，百分位注明依据的范围
+func TestStatisticsSampleCap(t *testing.T) {
+	s := newStatistics()
+	s.samples.limit = 100
+	at := time.Unix(0, 0)
+	for i := 0; i < 1000; i++ {
+		s.addRecord(at.Add(time.Duration(i)*time.Second), int64(i+1), true)
+	}
+	ss := s.Snapshot()
+	if ss.Received != 1000 || ss.Min != 1 || ss.Max != 1000 {
+		t.Errorf("累计统计: %+v", ss)
+	}
+	if n := len(s.sampleList()); n != 100 {
+		t.Errorf("保留 %d 个结果", n)
+	}
+	if p := s.percentile(50); p != 950 {
+		t.Errorf("P50 = %d，应为最近100次的中位数950", p)
+	}
+	if note := s.sampleNote(); note != "最近 100 次请求" {
+		t.Errorf("sampleNote = %q", note)
+	}
+}
+
+// 最短、最长及平均耗时只统计成功的请求，没有成功请求时为0
+func TestStatisticsSuccessOnly(t *testing.T) {
+	tests := []struct {
+		name          string
+		rtts          []int64 //<0表示超时
+		min, max, avg int64
+	}{
+		{"全部成功", []int64{10, 30, 20}, 10, 30, 20},
+		{"含超时", []int64{-1, 10, -1, 30}, 10, 30, 20},
+		{"全部超时", []int64{-1, -1}, 0, 0, 0},
+		{"没有请求", nil, 0, 0, 0},
+	}
+	at := time.Unix(0, 0)
+	for _, tt := range tests {
+		s := newStatistics()
+		for _, rtt := range tt.rtts {
+			s.addRecord(at, rtt, rtt >= 0)
+		}
+		ss := s.Snapshot()
+		if ss.Min != tt.min || ss.Max != tt.max || ss.Avg() != tt.avg {
+			t.Errorf("%s: 最短/最长/平均 = %d/%d/%d，应为 %d/%d/%d", tt.name, ss.Min, ss.Max, ss.Avg(), tt.min, tt.max, tt.avg)
+		}
+	}
+}
+
+// 没有收到回复时统计信息中的耗时显示为 -
+func TestPrintSummaryNoReply(t *testing.T) {
+	p := &Pinger{Addr: "192.0.2.1", Stats: newStatistics()}
+	p.Stats.addSent()
+	p.Stats.addFailure()
+	stdout, _ := captureOutput(t, p.printSummary)
+	if !strings.Contains(stdout, "最短 = -，最长 = -，平均 = -") {
+		t.Errorf("输出:\n%s", stdout)
+	}
+}
+
+// 总耗时很大时逐个累加的小耗时也不会被舍入掉
+func TestStatisticsKahanTotal(t *testing.T) {
+	s := newStatistics()
+	s.addTotal(1e16) //float64在1e16附近的间隔为2，直接累加1会被舍入掉
+	for i := 0; i < 10; i++ {
+		s.addTotal(1)
+	}
+	if got := s.Snapshot().Total; got != 1e16+10 {
+		t.Errorf("总耗时 = %.0f，应为 %.0f", got, 1e16+10)
+	}
+}
+
+// 最短、最长耗时不持锁并发更新，CAS失败时重试，结果不被覆盖；耗时为0也记为最短
+func TestStoreMinMax(t *testing.T) {
+	var lo, hi atomic.Int64
+	lo.Store(-1)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 1000; i >= 0; i-- {
+				v := int64(i*8 + w)
+				storeMin(&lo, v)
+				storeMax(&hi, v)
+			}
+		}(w)
+	}
+	wg.Wait()
+	if lo.Load() != 0 || hi.Load() != 1000*8+7 {
+		t.Errorf("最短=%d 最长=%d，应为 0 和 %d", lo.Load(), hi.Load(), 1000*8+7)
+	}
+}
+
+// 10个goroutine同时向mockConn发送请求并写入同一个Statistics，结束后最短、最长、计数都应正确
+// 以 go test -race 运行时同时检查数据竞争
+func TestStatisticsConcurrent(t *testing.T) {
+	const workers, probes = 10, 200
+	s := newStatistics()
+	stopReading := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() { //探测期间读取统计数据，与阶段统计相同
+		defer readers.Done()
+		for {
+			select {
+			case <-stopReading:
+				return
+			default:
+				s.Snapshot()
+				s.percentile(50)
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			conn := newMockConn()
+			data := make([]byte, 8+32)
+			buf := make([]byte, 1500)
+			for i := 0; i < probes; i++ {
+				if err := fillEcho(data, i); err != nil {
+					t.Error(err)
+					return
+				}
+				s.addSent()
+				conn.Write(data)
+				if n, _ := conn.Read(buf); n != 20+len(data) || buf[20] != 0 {
+					t.Errorf("回复 % x", buf[:n])
+					return
+				}
+				if i%10 == 9 {
+					s.addFailure()
+					continue
+				}
+				s.addSuccess(int64(w*1000 + i + 1)) //最短为1，最长为9000+199
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(stopReading)
+	readers.Wait()
+
+	ss := s.Snapshot()
+	wantOK := workers * probes * 9 / 10
+	if ss.Sent != workers*probes || ss.Received != wantOK || ss.Lost != workers*probes-wantOK {
+		t.Errorf("计数错误: %+v", ss)
+	}
+	if ss.Min != 1 || ss.Max != (workers-1)*1000+probes-1 {
+		t.Errorf("最短=%d 最长=%d", ss.Min, ss.Max)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+var strictMode bool //-strict 回复存在任何协议异常时视为失败
+
+// 协议异常的类型，按检查及输出的顺序排列
+const (
+	anomalyLength   = "length"   //报文长度与IP头中的总长度不符
+	anomalySource   = "source"   //回复的源地址不是目标
+	anomalyType     = "type"     //不是回显应答
+	anomalyChecksum = "checksum" //ICMP检验和错误
+	anomalyTTL      = "ttl"      //TTL为0
+	anomalyID       = "id"       //标识与请求不符
+	anomalySeq      = "seq"      //序号与请求不符
+	anomalyPayload  = "payload"  //数据与请求不符
+)
+
+var anomalyKinds = []string{anomalyLength, anomalySource, anomalyType, anomalyChecksum, anomalyTTL, anomalyID, anomalySeq, anomalyPayload}
+
+var anomalyNames = map[string]string{
+	anomalyLength:   "长度不符",
+	anomalySource:   "源地址不符",
+	anomalyType:     "类型错误",
+	anomalyChecksum: "检验和错误",
+	anomalyTTL:      "TTL为0",
+	anomalyID:       "标识不符",
+	anomalySeq:      "序号不符",
+	anomalyPayload:  "数据不符",
+}
+
+// 回复中的一个协议异常
+type replyAnomaly struct {
+	kind   string
+	reason string
+}
+
+func (a *replyAnomaly) Error() string { return a.reason }
+
+// 严格校验回复报文(从IP头开始)，返回发现的第一个异常，没有异常时返回nil
+// target为目标地址，req为发送的ICMP回显请求
+func verifyReply(pkt []byte, target net.IP, req []byte) *replyAnomaly {
+	if len(pkt) < 20 {
+		return &replyAnomaly{anomalyLength, fmt.Sprintf("报文只有 %d 字节，不足IP头长度", len(pkt))}
+	}
+	ihl := int(pkt[0]&0x0f) * 4
+	if total := int(binary.BigEndian.Uint16(pkt[2:4])); total != len(pkt) {
+		return &replyAnomaly{anomalyLength, fmt.Sprintf("收到 %d 字节，IP头中的总长度为 %d", len(pkt), total)}
+	}
+	if ihl < 20 || len(pkt) < ihl+8 {
+		return &replyAnomaly{anomalyLength, fmt.Sprintf("IP头长度 %d，报文 %d 字节，不足以容纳ICMP头", ihl, len(pkt))}
+	}
+	if src := net.IP(pkt[12:16]); !src.Equal(target) {
+		return &replyAnomaly{anomalySource, fmt.Sprintf("回复来自 %s，目标为 %s", src, target)}
+	}
+
+	icmp := pkt[ihl:]
+	if icmp[0] != 0 || icmp[1] != 0 {
+		return &replyAnomaly{anomalyType, fmt.Sprintf("类型=%d 代码=%d，不是回显应答", icmp[0], icmp[1])}
+	}
+	if sum, _ := checkSum(icmp); sum != 0 {
+		return &replyAnomaly{anomalyChecksum, fmt.Sprintf("ICMP检验和 0x%04x 不正确", binary.BigEndian.Uint16(icmp[2:4]))}
+	}
+	if pkt[8] == 0 {
+		return &replyAnomaly{anomalyTTL, "TTL为0"}
+	}
+	if id, want := binary.BigEndian.Uint16(icmp[4:6]), binary.BigEndian.Uint16(req[4:6]); id != want {
+		return &replyAnomaly{anomalyID, fmt.Sprintf("标识=%d，请求为 %d", id, want)}
+	}
+	if seq, want := binary.BigEndian.Uint16(icmp[6:8]), binary.BigEndian.Uint16(req[6:8]); seq != want {
+		return &replyAnomaly{anomalySeq, fmt.Sprintf("序号=%d，请求为 %d", seq, want)}
+	}
+	if string(icmp[8:]) != string(req[8:]) {
+		return &replyAnomaly{anomalyPayload, fmt.Sprintf("数据与请求不同(回复 %d 字节，请求 %d 字节)", len(icmp)-8, len(req)-8)}
+	}
+	return nil
+}
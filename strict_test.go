@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+)
+
+// 由请求构造正确的回显应答(从IP头开始)，源地址为127.0.0.1
+func craftReply(t *testing.T, req []byte) []byte {
+	t.Helper()
+	conn := newMockConn()
+	if _, err := conn.Write(req); err != nil {
+		t.Fatal(err)
+	}
+	return append([]byte(nil), conn.reply...)
+}
+
+// 修改回复后重新计算ICMP检验和，使检验和之外的异常能被检查到
+func fixICMPSum(pkt []byte) {
+	icmp := pkt[20:]
+	icmp[2], icmp[3] = 0, 0
+	sum, _ := checkSum(icmp)
+	binary.BigEndian.PutUint16(icmp[2:4], sum)
+}
+
+// 每种协议异常一个构造的报文
+func TestVerifyReply(t *testing.T) {
+	target := net.IPv4(127, 0, 0, 1)
+	req, err := buildEcho(7, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		mutate func([]byte) []byte
+		kind   string //期望的异常类型，空表示没有异常
+	}{
+		{"正确的回复", func(p []byte) []byte { return p }, ""},
+		{"总长度不符", func(p []byte) []byte { return p[:len(p)-1] }, anomalyLength},
+		{"不足IP头", func(p []byte) []byte { return p[:12] }, anomalyLength},
+		{"不足ICMP头", func(p []byte) []byte {
+			p = p[:24]
+			binary.BigEndian.PutUint16(p[2:4], 24)
+			return p
+		}, anomalyLength},
+		{"源地址不符", func(p []byte) []byte { copy(p[12:16], []byte{192, 0, 2, 9}); return p }, anomalySource},
+		{"不是回显应答", func(p []byte) []byte { p[20] = 8; fixICMPSum(p); return p }, anomalyType},
+		{"代码不为0", func(p []byte) []byte { p[21] = 1; fixICMPSum(p); return p }, anomalyType},
+		{"检验和错误", func(p []byte) []byte { p[22] ^= 0xff; return p }, anomalyChecksum},
+		{"TTL为0", func(p []byte) []byte { p[8] = 0; return p }, anomalyTTL},
+		{"标识不符", func(p []byte) []byte { p[24] ^= 0xff; fixICMPSum(p); return p }, anomalyID},
+		{"序号不符", func(p []byte) []byte { p[27]++; fixICMPSum(p); return p }, anomalySeq},
+		{"数据不符", func(p []byte) []byte { p[len(p)-1] ^= 0xff; fixICMPSum(p); return p }, anomalyPayload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := verifyReply(tt.mutate(craftReply(t, req)), target, req)
+			switch {
+			case tt.kind == "" && a != nil:
+				t.Errorf("正确的回复报告了异常 %s: %s", a.kind, a.reason)
+			case tt.kind != "" && a == nil:
+				t.Errorf("没有发现异常，期望 %s", tt.kind)
+			case tt.kind != "" && a.kind != tt.kind:
+				t.Errorf("异常 = %s (%s)，期望 %s", a.kind, a.reason, tt.kind)
+			case a != nil && a.reason == "":
+				t.Errorf("异常 %s 没有原因", a.kind)
+			}
+		})
+	}
+}
+
+// 每种异常单独计数，统计信息中按固定顺序输出
+func TestAnomalyCounters(t *testing.T) {
+	p := &Pinger{Addr: "127.0.0.1", Stats: newStatistics()}
+	for _, kind := range []string{anomalySeq, anomalySource, anomalySeq} {
+		p.Stats.addSent()
+		p.Stats.addAnomaly(kind)
+		p.Stats.addFailure()
+	}
+	ss := p.Stats.Snapshot()
+	if ss.Anomalies[anomalySeq] != 2 || ss.Anomalies[anomalySource] != 1 || len(ss.Anomalies) != 2 {
+		t.Errorf("Anomalies = %v", ss.Anomalies)
+	}
+	ss.Anomalies[anomalyTTL] = 1 //快照是副本
+	if p.Stats.Snapshot().Anomalies[anomalyTTL] != 0 {
+		t.Error("修改快照影响了统计数据")
+	}
+
+	out, _ := captureOutput(t, p.printSummary)
+	i, j := strings.Index(out, "源地址不符 = 1"), strings.Index(out, "序号不符 = 2")
+	if !strings.Contains(out, "协议异常(-strict):") || i < 0 || j < i {
+		t.Errorf("统计信息中的协议异常不正确:\n%s", out)
+	}
+}
+
+// -ebpf 只交付ID匹配的应答，-strict 无法报告这种异常
+func TestStrictFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		ok   bool
+	}{
+		{[]string{"-strict", "127.0.0.1"}, true},
+		{[]string{"-ebpf", "127.0.0.1"}, true},
+		{[]string{"-strict", "-ebpf", "127.0.0.1"}, false},
+	}
+	for _, tt := range tests {
+		errs := argErrors(t, tt.args...)
+		if ok := !hasArgError(errs, "-ebpf"); ok != tt.ok {
+			t.Errorf("%v: %q", tt.args, errs)
+		}
+	}
+}
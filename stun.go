@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+var stunServer string //-stun 要测量的STUN服务器
+
+const (
+	stunPort          = "3478"
+	stunHeaderLen     = 20
+	stunMagicCookie   = 0x2112a442
+	stunBindingReq    = 0x0001
+	stunBindingResp   = 0x0101
+	stunBindingErr    = 0x0111
+	stunAttrMapped    = 0x0001
+	stunAttrErrorCode = 0x0009
+	stunAttrXorMapped = 0x0020
+	stunFamilyIPv4    = 0x01
+	stunFamilyIPv6    = 0x02
+)
+
+var errSTUNTxID = errors.New("事务ID不匹配") //迟到的旧应答
+
+// 构造STUN Binding请求(RFC 5389)，不带属性
+func buildSTUNRequest(txID []byte) []byte {
+	b := make([]byte, stunHeaderLen)
+	binary.BigEndian.PutUint16(b[0:2], stunBindingReq)
+	binary.BigEndian.PutUint32(b[4:8], stunMagicCookie)
+	copy(b[8:20], txID)
+	return b
+}
+
+// 解析Binding应答，返回外部(经NAT转换后)的地址
+// 优先使用XOR-MAPPED-ADDRESS，没有时使用MAPPED-ADDRESS
+func parseSTUNResponse(b, txID []byte) (*net.UDPAddr, error) {
+	if len(b) < stunHeaderLen || binary.BigEndian.Uint32(b[4:8]) != stunMagicCookie {
+		return nil, errors.New("不是STUN报文")
+	}
+	if string(b[8:20]) != string(txID) {
+		return nil, errSTUNTxID
+	}
+	typ, length := binary.BigEndian.Uint16(b[0:2]), int(binary.BigEndian.Uint16(b[2:4]))
+	if stunHeaderLen+length > len(b) {
+		return nil, errors.New("报文长度非法")
+	}
+
+	var mapped *net.UDPAddr
+	for attrs := b[stunHeaderLen : stunHeaderLen+length]; len(attrs) >= 4; {
+		at, al := binary.BigEndian.Uint16(attrs[0:2]), int(binary.BigEndian.Uint16(attrs[2:4]))
+		if 4+al > len(attrs) {
+			return nil, errors.New("属性长度非法")
+		}
+		v := attrs[4 : 4+al]
+		switch at {
+		case stunAttrXorMapped:
+			addr, err := stunAddress(v, b[4:20])
+			if err != nil {
+				return nil, err
+			}
+			return addr, nil
+		case stunAttrMapped:
+			addr, err := stunAddress(v, nil)
+			if err != nil {
+				return nil, err
+			}
+			mapped = addr
+		case stunAttrErrorCode:
+			if typ == stunBindingErr && len(v) >= 4 {
+				return nil, fmt.Errorf("服务器返回错误 %d %s", int(v[2]&0x07)*100+int(v[3]), v[4:])
+			}
+		}
+		attrs = attrs[minInt(4+(al+3)/4*4, len(attrs)):] //属性按4字节对齐
+	}
+	if typ == stunBindingErr {
+		return nil, errors.New("服务器返回错误")
+	}
+	if typ != stunBindingResp {
+		return nil, fmt.Errorf("意外的消息类型 0x%04x", typ)
+	}
+	if mapped == nil {
+		return nil, errors.New("应答中没有映射地址")
+	}
+	return mapped, nil
+}
+
+// 解析地址属性，xor不为空时按XOR-MAPPED-ADDRESS与魔数及事务ID异或
+func stunAddress(v, xor []byte) (*net.UDPAddr, error) {
+	if len(v) < 4 {
+		return nil, errors.New("地址属性过短")
+	}
+	var ip net.IP
+	switch v[1] {
+	case stunFamilyIPv4:
+		ip = make(net.IP, net.IPv4len)
+	case stunFamilyIPv6:
+		ip = make(net.IP, net.IPv6len)
+	default:
+		return nil, fmt.Errorf("未知的地址族 %d", v[1])
+	}
+	if len(v) < 4+len(ip) {
+		return nil, errors.New("地址属性过短")
+	}
+	port := binary.BigEndian.Uint16(v[2:4])
+	copy(ip, v[4:])
+	if xor != nil {
+		port ^= stunMagicCookie >> 16
+		for i := range ip {
+			ip[i] ^= xor[i]
+		}
+	}
+	return &net.UDPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// RunSTUN 以STUN Binding请求的往返时间代替ICMP，并输出服务器看到的外部地址
+// 所有请求使用同一个本地端口，外部地址变化时给出提示，可用于观察NAT映射是否稳定
+func (p *Pinger) RunSTUN() {
+	t := normalizeTarget(p.Arg)
+	if t.Port == "" {
+		t.Port = stunPort
+	}
+	p.Host = net.JoinHostPort(t.Host, t.Port)
+	conn, err := net.DialTimeout("udp", p.Host, time.Duration(p.Timeout)*time.Millisecond)
+	if err != nil {
+		p.fail(err)
+		p.printf("无法连接STUN服务器 %s: %v\n", p.Host, err)
+		return
+	}
+	defer conn.Close()
+	p.Addr = conn.RemoteAddr().String()
+
+	p.printf("正在从 %s 向STUN服务器 %s 发送Binding请求：\n", conn.LocalAddr(), p.Addr)
+
+	bufp := recvBufPool.Get().(*[]byte)
+	defer recvBufPool.Put(bufp)
+	buf := *bufp
+	txID := make([]byte, 12)
+	var last string //上一次的外部地址
+
+	for i := 0; forever || i < p.Count; i++ {
+		if !p.nextProbe(i) {
+			break
+		}
+
+		p.Stats.addSent()
+		if _, err := rand.Read(txID); err != nil {
+			p.Stats.addSendError(err)
+			p.Stats.addFailure()
+			p.printf("请求失败: %s\n", sendErrorText(err))
+			continue
+		}
+		conn.SetDeadline(time.Now().Add(time.Duration(p.Timeout) * time.Millisecond))
+
+		tStart := time.Now()
+		if _, err := conn.Write(buildSTUNRequest(txID)); err != nil {
+			p.Stats.addSendError(err)
+			p.Stats.addFailure()
+			p.printf("请求失败: %s\n", sendErrorText(err))
+			continue
+		}
+
+		//跳过迟到的旧应答
+		var mapped *net.UDPAddr
+		for {
+			n, rerr := conn.Read(buf)
+			if err = rerr; err != nil {
+				break
+			}
+			if mapped, err = parseSTUNResponse(buf[:n], txID); err != errSTUNTxID {
+				break
+			}
+		}
+		tSpend := time.Since(tStart).Milliseconds()
+		if err != nil {
+			p.Stats.addFailure()
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				p.Stats.addTimeout()
+				p.printf("请求超时。\n")
+			} else {
+				p.printf("请求失败: %v\n", err)
+			}
+			continue
+		}
+		p.Stats.addSuccess(tSpend)
+
+		external := net.JoinHostPort(mapped.IP.String(), strconv.Itoa(mapped.Port))
+		p.printf("来自 %s 的回复: 序号=%d 外部地址=%s 时间=%dms\n", p.Addr, i, external, tSpend)
+		if last != "" && external != last {
+			p.printf("注意: 外部地址由 %s 变为 %s，NAT映射发生了变化\n", last, external)
+		}
+		last = external
+	}
+
+	p.printSummary()
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+)
+
+// 构造STUN应答，attrs为已编码的属性
+func stunMessage(typ uint16, txID []byte, attrs ...[]byte) []byte {
+	b := make([]byte, stunHeaderLen)
+	binary.BigEndian.PutUint16(b[0:2], typ)
+	binary.BigEndian.PutUint32(b[4:8], stunMagicCookie)
+	copy(b[8:20], txID)
+	for _, a := range attrs {
+		b = append(b, a...)
+	}
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)-stunHeaderLen))
+	return b
+}
+
+func stunAttr(typ uint16, v []byte) []byte {
+	a := make([]byte, 4, 4+len(v)+3)
+	binary.BigEndian.PutUint16(a[0:2], typ)
+	binary.BigEndian.PutUint16(a[2:4], uint16(len(v)))
+	a = append(a, v...)
+	for len(a)%4 != 0 {
+		a = append(a, 0)
+	}
+	return a
+}
+
+func hexBytes(s string) []byte {
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestBuildSTUNRequest(t *testing.T) {
+	txID := hexBytes("b7e7a701bc34d686fa87dfae")
+	if got, want := buildSTUNRequest(txID), hexBytes("0001 0000 2112a442 b7e7a701bc34d686fa87dfae"); string(got) != string(want) {
+		t.Errorf("buildSTUNRequest = %x，应为 %x", got, want)
+	}
+}
+
+// XOR-MAPPED-ADDRESS 的取值来自 RFC 5769 §2.2、§2.3
+func TestParseSTUNResponse(t *testing.T) {
+	txID := hexBytes("b7e7a701bc34d686fa87dfae")
+	other := hexBytes("000000000000000000000000")
+	xor4 := stunAttr(stunAttrXorMapped, hexBytes("0001 a147 e112a643"))
+	xor6 := stunAttr(stunAttrXorMapped, hexBytes("0002 a147 0113a9fa a5d3f179 bc25f4b5 bed2b9d9"))
+	mapped := stunAttr(stunAttrMapped, hexBytes("0001 1f90 c6336407"))
+	software := stunAttr(0x8022, []byte("test vector"))
+	tests := []struct {
+		name string
+		msg  []byte
+		want string
+		err  string
+	}{
+		{"IPv4", stunMessage(stunBindingResp, txID, software, xor4), "192.0.2.1:32853", ""},
+		{"IPv6", stunMessage(stunBindingResp, txID, xor6), "[2001:db8:1234:5678:11:2233:4455:6677]:32853", ""},
+		{"XOR优先", stunMessage(stunBindingResp, txID, mapped, xor4), "192.0.2.1:32853", ""},
+		{"只有MAPPED-ADDRESS", stunMessage(stunBindingResp, txID, mapped), "198.51.100.7:8080", ""},
+		{"旧应答", stunMessage(stunBindingResp, other, xor4), "", "事务ID不匹配"},
+		{"错误应答", stunMessage(stunBindingErr, txID, stunAttr(stunAttrErrorCode, append([]byte{0, 0, 4, 20}, "Unknown Attribute"...))), "", "服务器返回错误 420 Unknown Attribute"},
+		{"没有错误码的错误应答", stunMessage(stunBindingErr, txID), "", "服务器返回错误"},
+		{"没有地址", stunMessage(stunBindingResp, txID, software), "", "应答中没有映射地址"},
+		{"请求", stunMessage(stunBindingReq, txID), "", "意外的消息类型 0x0001"},
+		{"未知地址族", stunMessage(stunBindingResp, txID, stunAttr(stunAttrXorMapped, hexBytes("0003 a147 e112a643"))), "", "未知的地址族 3"},
+		{"地址过短", stunMessage(stunBindingResp, txID, stunAttr(stunAttrXorMapped, hexBytes("0002 a147 e112a643"))), "", "地址属性过短"},
+		{"不是STUN", []byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n"), "", "不是STUN报文"},
+	}
+	for _, tt := range tests {
+		addr, err := parseSTUNResponse(tt.msg, txID)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: 错误 = %v，应为 %s", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || addr.String() != tt.want {
+			t.Errorf("%s: %v, %v，应为 %s", tt.name, addr, err, tt.want)
+		}
+	}
+
+	long := stunMessage(stunBindingResp, txID, xor4)
+	binary.BigEndian.PutUint16(long[2:4], 100)
+	if _, err := parseSTUNResponse(long, txID); err == nil || err.Error() != "报文长度非法" {
+		t.Errorf("长度超出报文时: %v", err)
+	}
+}
+
+// 本地STUN服务器：先回一个事务ID不同的旧应答，第二次起映射的端口加1，模拟NAT映射变化
+func TestRunSTUN(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for i := 0; ; i++ {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil || n < stunHeaderLen {
+				return
+			}
+			txID := append([]byte(nil), buf[8:20]...)
+			port := uint16(40000)
+			if i > 0 {
+				port++
+			}
+			v := []byte{0, stunFamilyIPv4, 0, 0, 203, 0, 113, 9}
+			binary.BigEndian.PutUint16(v[2:4], port^stunMagicCookie>>16)
+			binary.BigEndian.PutUint32(v[4:8], binary.BigEndian.Uint32(v[4:8])^stunMagicCookie)
+			pc.WriteTo(stunMessage(stunBindingResp, make([]byte, 12), stunAttr(stunAttrXorMapped, v)), addr)
+			pc.WriteTo(stunMessage(stunBindingResp, txID, stunAttr(stunAttrXorMapped, v)), addr)
+		}
+	}()
+
+	addr := pc.LocalAddr().String()
+	parseArgs(t, "-n", "3", "-w", "2000", "-stun", addr)
+	p := newPinger(stunServer)
+	stdout, _ := captureOutput(t, p.RunSTUN)
+	for _, want := range []string{
+		"来自 " + addr + " 的回复: 序号=0 外部地址=203.0.113.9:40000 ",
+		"来自 " + addr + " 的回复: 序号=1 外部地址=203.0.113.9:40001 ",
+		"注意: 外部地址由 203.0.113.9:40000 变为 203.0.113.9:40001，NAT映射发生了变化",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("输出中没有 %q:\n%s", want, stdout)
+		}
+	}
+	if n := strings.Count(stdout, "注意:"); n != 1 {
+		t.Errorf("提示了 %d 次:\n%s", n, stdout)
+	}
+	if ss := p.Stats.Snapshot(); ss.Sent != 3 || ss.Received != 3 {
+		t.Errorf("统计 = %+v", ss)
+	}
+}
+
+func TestRunSTUNTimeout(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	parseArgs(t, "-n", "2", "-w", "100", "x")
+	stdout, _ := captureOutput(t, newPinger(pc.LocalAddr().String()).RunSTUN)
+	if n := strings.Count(stdout, "请求超时。"); n != 2 {
+		t.Errorf("超时 %d 次，应为 2:\n%s", n, stdout)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	maxSweepHosts    = 65536 //一个网段最多展开的地址数
+	sweepConcurrency = 256   //同时ping的目标数
+)
+
+var (
+	targetFile  string //目标列表文件，每行一个目标
+	aliveOnly   bool   //只输出有回复的地址
+	unreachOnly bool   //只输出没有回复的地址
+)
+
+// 展开目标参数：网段(如 192.168.1.0/24)展开为其中的各个主机地址，其余(包括带路径的URL)原样返回
+func expandTargets(args []string) ([]string, error) {
+	var targets []string
+	for _, arg := range args {
+		if !strings.Contains(arg, "/") || strings.Contains(arg, "://") {
+			targets = append(targets, arg)
+			continue
+		}
+		hosts, err := expandCIDR(arg)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, hosts...)
+	}
+	return targets, nil
+}
+
+// 展开IPv4网段，/31、/32以外的网段不包含网络地址和广播地址
+func expandCIDR(cidr string) ([]string, error) {
+	ip, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil || ip.To4() == nil {
+		return nil, fmt.Errorf("无效的IPv4网段 %s", cidr)
+	}
+	ones, bits := ipnet.Mask.Size()
+	total := uint64(1) << uint(bits-ones)
+	if total > maxSweepHosts {
+		return nil, fmt.Errorf("网段 %s 包含 %d 个地址，超出上限 %d", cidr, total, maxSweepHosts)
+	}
+
+	first := binary.BigEndian.Uint32(ipnet.IP.To4())
+	start, end := uint64(0), total
+	if total > 2 {
+		start, end = 1, total-1
+	}
+
+	hosts := make([]string, 0, end-start)
+	for i := start; i < end; i++ {
+		b := make(net.IP, 4)
+		binary.BigEndian.PutUint32(b, first+uint32(i))
+		hosts = append(hosts, b.String())
+	}
+	return hosts, nil
+}
+
+// 读取目标列表文件，忽略空行和#开头的注释
+func readTargetFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var targets []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		targets = append(targets, line)
+	}
+	return targets, scanner.Err()
+}
+
+// 并发ping所有目标，只把有回复(-alive)或没有回复(-unreach)的地址输出到标准输出，每行一个
+// 返回输出的地址个数，其他信息一律输出到标准错误
+func runSweep(pingers []*Pinger) int {
+	defer startSharedSocket(len(pingers))()
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, sweepConcurrency)
+	//在启动前占用并发名额，使目标按 probeOrder 的顺序开始探测
+	for _, i := range probeOrder(len(pingers)) {
+		p := pingers[i]
+		p.Quiet = true
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(p *Pinger) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if !stopped() {
+				p.Run()
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	printed := 0
+	for _, p := range pingers {
+		if p.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", p.Arg, p.Err)
+		}
+		alive := p.Stats.Snapshot().Received > 0
+		if alive == aliveOnly {
+			fmt.Println(p.Arg)
+			printed++
+		}
+	}
+	return printed
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandCIDR(t *testing.T) {
+	tests := []struct {
+		cidr  string
+		first string
+		last  string
+		n     int
+		err   string
+	}{
+		{"192.168.1.0/24", "192.168.1.1", "192.168.1.254", 254, ""},
+		{"192.168.1.77/30", "192.168.1.77", "192.168.1.78", 2, ""},
+		{"10.0.0.0/31", "10.0.0.0", "10.0.0.1", 2, ""},
+		{"10.0.0.5/32", "10.0.0.5", "10.0.0.5", 1, ""},
+		{"10.0.0.0/16", "10.0.0.1", "10.0.255.254", 65534, ""},
+		{"10.0.0.0/15", "", "", 0, "超出上限 65536"},
+		{"2001:db8::/120", "", "", 0, "无效的IPv4网段"},
+		{"10.0.0.0/33", "", "", 0, "无效的IPv4网段"},
+	}
+	for _, tt := range tests {
+		hosts, err := expandCIDR(tt.cidr)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expandCIDR(%s) 的错误 = %v，应包含 %q", tt.cidr, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || len(hosts) != tt.n || hosts[0] != tt.first || hosts[len(hosts)-1] != tt.last {
+			t.Errorf("expandCIDR(%s) = %d 个地址, %v，应为 %d 个(%s - %s)", tt.cidr, len(hosts), err, tt.n, tt.first, tt.last)
+		}
+	}
+}
+
+// 网段展开为各地址，其他目标原样保留，顺序不变
+func TestExpandTargets(t *testing.T) {
+	got, err := expandTargets([]string{"example.com", "10.0.0.0/30", "10.1.1.1", "https://example.com/a/b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "example.com 10.0.0.1 10.0.0.2 10.1.1.1 https://example.com/a/b"; strings.Join(got, " ") != want {
+		t.Errorf("expandTargets = %v，应为 %s", got, want)
+	}
+	if _, err := expandTargets([]string{"10.0.0.0/8"}); err == nil {
+		t.Error("超出上限的网段应返回错误")
+	}
+}
+
+func TestReadTargetFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	body := "# 核心设备\n10.0.0.1\n\n  core-gw  \n# 10.0.0.2\n192.168.0.0/30\n"
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readTargetFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "10.0.0.1 core-gw 192.168.0.0/30"; strings.Join(got, " ") != want {
+		t.Errorf("readTargetFile = %q，应为 %s", got, want)
+	}
+	if _, err := readTargetFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("文件不存在时应返回错误")
+	}
+}
+
+// 扫描时默认每个地址只发送一次，-alive 与 -unreach 不能同时指定
+func TestSweepFlags(t *testing.T) {
+	parseArgs(t, "-alive", "10.0.0.0/30")
+	if count != 1 {
+		t.Errorf("-alive 时 count = %d，应为 1", count)
+	}
+	parseArgs(t, "-unreach", "-n", "3", "10.0.0.0/30")
+	if count != 3 {
+		t.Errorf("-unreach -n 3 时 count = %d，应为 3", count)
+	}
+	if errs := argErrors(t, "-alive", "-unreach", "10.0.0.1"); !hasArgError(errs, "参数 -alive 与 -unreach 不能同时指定") {
+		t.Errorf("错误 = %q", errs)
+	}
+}
+
+// 标准输出只有符合条件的地址，每行一个；无法开始ping的目标在标准错误中说明
+func TestRunSweep(t *testing.T) {
+	needRawSocket(t)
+	tests := []struct {
+		alive  bool
+		stdout string
+		n      int
+	}{
+		{true, "127.0.0.1\n", 1},
+		{false, "203.0.113.1\nnx.invalid\n", 2},
+	}
+	for _, tt := range tests {
+		parseArgs(t, "-n", "1", "-w", "200", "x")
+		aliveOnly, unreachOnly = tt.alive, !tt.alive
+		var pingers []*Pinger
+		for _, arg := range []string{"127.0.0.1", "203.0.113.1", "nx.invalid"} {
+			pingers = append(pingers, newPinger(arg))
+		}
+		var n int
+		stdout, stderr := captureOutput(t, func() { n = runSweep(pingers) })
+		if stdout != tt.stdout || n != tt.n {
+			t.Errorf("-alive=%v: 输出 %q (%d 个)，应为 %q", tt.alive, stdout, n, tt.stdout)
+		}
+		if !strings.HasPrefix(stderr, "nx.invalid: ") {
+			t.Errorf("-alive=%v: 标准错误 %q", tt.alive, stderr)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	outputFormat    string //输出格式，table 表示多目标汇总表格
+	sortBy          string //表格排序方式：loss、avg、name
+	unreachableOnly bool   //表格中只显示无法访问的目标
+)
+
+const tableRefresh = time.Second //终端中实时刷新表格的间隔
+
+// 表格中的一行
+type tableRow struct {
+	name  string
+	stats StatsSnapshot
+	err   error
+}
+
+// 并发ping所有目标，结束后输出汇总表格；标准输出为终端时每秒刷新一次
+// rows由各目标的统计数据生成表格的各行
+func runTable(pingers []*Pinger, rows func([]*Pinger) []tableRow) {
+	defer startSharedSocket(len(pingers))()
+	var wg sync.WaitGroup
+	for _, p := range pingers {
+		p.Quiet = true
+		wg.Add(1)
+		go func(p *Pinger) {
+			defer wg.Done()
+			p.Run()
+		}(p)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	lines := 0 //上一次输出的行数，刷新时先回到表格开头
+	if isTerminal(os.Stdout) {
+		ticker := time.NewTicker(tableRefresh)
+		defer ticker.Stop()
+	live:
+		for {
+			select {
+			case <-done:
+				break live
+			case <-ticker.C:
+				lines = redraw(renderTable(rows(pingers)), lines)
+			}
+		}
+	}
+	<-done
+	redraw(renderTable(rows(pingers)), lines)
+}
+
+// 清除上一次输出的表格后重新输出
+func redraw(table string, lines int) int {
+	if lines > 0 {
+		fmt.Printf("\x1b[%dA\x1b[J", lines)
+	}
+	fmt.Print(table)
+	return strings.Count(table, "\n")
+}
+
+// 读取各目标的统计数据，按参数过滤和排序
+func tableRows(pingers []*Pinger) []tableRow {
+	var rows []tableRow
+	for _, p := range pingers {
+		row := tableRow{name: p.Arg + labelSuffix(p.Labels), stats: p.Stats.Snapshot(), err: p.Err}
+		if unreachableOnly && row.stats.Received > 0 {
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	switch sortBy {
+	case "loss":
+		//无法开始探测(如无法解析)的目标与全部丢失一样排在前面
+		loss := func(r tableRow) float64 {
+			if r.err != nil {
+				return 100
+			}
+			return r.stats.LossPercent()
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			return loss(rows[i]) > loss(rows[j])
+		})
+	case "avg":
+		//没有回复的目标没有平均耗时，排在最后
+		sort.SliceStable(rows, func(i, j int) bool {
+			ri, rj := rows[i].stats.Received > 0 && rows[i].err == nil, rows[j].stats.Received > 0 && rows[j].err == nil
+			if ri != rj {
+				return ri
+			}
+			return rows[i].stats.Avg() < rows[j].stats.Avg()
+		})
+	case "name":
+		sort.SliceStable(rows, func(i, j int) bool {
+			return rows[i].name < rows[j].name
+		})
+	}
+	return rows
+}
+
+// 把各行渲染为按内容宽度对齐的表格
+func renderTable(rows []tableRow) string {
+	cells := [][]string{{"目标", "已发送", "已接收", "丢失", "最短", "平均", "最长", "最近"}}
+	for _, r := range rows {
+		ss := r.stats
+		if r.err != nil {
+			state := "错误"
+			if isDNSError(r.err) {
+				state = dnsEventError //无法解析与100%丢失区分
+			}
+			cells = append(cells, []string{r.name, "-", "-", "-", "-", "-", "-", state})
+			continue
+		}
+		row := []string{r.name, fmt.Sprint(ss.Sent), fmt.Sprint(ss.Received), "-", "-", "-", "-", "-"}
+		if ss.Sent > 0 {
+			row[3] = fmt.Sprintf("%.1f%%", ss.LossPercent())
+		}
+		if ss.Received > 0 {
+			row[4], row[5], row[6] = fmt.Sprintf("%dms", ss.Min), fmt.Sprintf("%dms", ss.Avg()), fmt.Sprintf("%dms", ss.Max)
+		}
+		if ss.Last >= 0 {
+			row[7] = fmt.Sprintf("%dms", ss.Last)
+		}
+		cells = append(cells, row)
+	}
+
+	widths := make([]int, len(cells[0]))
+	for _, row := range cells {
+		for i, c := range row {
+			if w := displayWidth(c); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+
+	var b strings.Builder
+	for _, row := range cells {
+		for i, c := range row {
+			pad := strings.Repeat(" ", widths[i]-displayWidth(c))
+			switch {
+			case i == 0: //目标列左对齐
+				b.WriteString(c + pad)
+			default: //数值列右对齐
+				b.WriteString("  " + pad + c)
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// 字符串在终端中的显示宽度，中文等宽字符占两列
+func displayWidth(s string) int {
+	w := 0
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		if r >= 0x1100 {
+			w += 2
+		} else {
+			w++
+		}
+	}
+	return w
+}
+
+// 是否为终端
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "以当前输出更新testdata中的golden文件")
+
+// 比较输出与testdata中的golden文件，-update 时以输出更新文件
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s 与输出不一致(go test -run %s -update 更新):\n%s", name, t.Name(), got)
+	}
+}
+
+// 表格的合成数据：往返时间(毫秒，<0表示超时)
+func tablePingers() []*Pinger {
+	target := func(arg string, labels map[string]string, rtts ...int64) *Pinger {
+		p := &Pinger{Arg: arg, Labels: labels, Stats: newStatistics()}
+		for _, rtt := range rtts {
+			p.Stats.addSent()
+			if rtt < 0 {
+				p.Stats.addFailure()
+				continue
+			}
+			p.Stats.addSuccess(rtt)
+		}
+		return p
+	}
+	nx := target("nx.invalid", nil)
+	nx.Err = &net.DNSError{Err: "no such host", Name: "nx.invalid", IsNotFound: true}
+	denied := target("10.9.9.9", nil)
+	denied.Err = errors.New("permission denied")
+	return []*Pinger{
+		target("core-gw", map[string]string{"site": "北京"}, 2, 3, 1, 2),
+		target("10.0.0.20", nil, 120, -1, 180, -1),
+		target("example.com", nil, 35, 30, 28, 1234),
+		target("10.0.0.3", nil, -1, -1, -1, -1),
+		nx,
+		denied,
+	}
+}
+
+func TestRenderTableGolden(t *testing.T) {
+	tests := []struct {
+		golden string
+		args   []string
+	}{
+		{"table.golden", nil},
+		{"table-sort-loss.golden", []string{"-sort", "loss"}},
+		{"table-sort-avg.golden", []string{"-sort", "avg"}},
+		{"table-sort-name.golden", []string{"-sort", "name"}},
+		{"table-unreachable.golden", []string{"-unreachable-only"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			parseArgs(t, append([]string{"-format", "table"}, tt.args...)...)
+			checkGolden(t, tt.golden, []byte(renderTable(tableRows(tablePingers()))))
+		})
+	}
+}
+
+// 各列按内容的显示宽度对齐，中文占两列
+func TestRenderTableAligned(t *testing.T) {
+	parseArgs(t)
+	lines := strings.Split(strings.TrimSuffix(renderTable(tableRows(tablePingers())), "\n"), "\n")
+	for _, l := range lines[1:] {
+		if displayWidth(l) != displayWidth(lines[0]) {
+			t.Errorf("行宽 %d 与表头 %d 不同:\n%s\n%s", displayWidth(l), displayWidth(lines[0]), lines[0], l)
+		}
+	}
+}
+
+// 探测进行中读取统计数据生成表格，-race 下不能有数据竞争
+func TestTableRowsWhileProbing(t *testing.T) {
+	parseArgs(t)
+	p := &Pinger{Arg: "a", Stats: newStatistics()}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			p.Stats.addSent()
+			p.Stats.addSuccess(int64(i % 50))
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			if ss := p.Stats.Snapshot(); ss.Sent != 1000 || ss.Received != 1000 {
+				t.Fatalf("发送 %d，收到 %d", ss.Sent, ss.Received)
+			}
+			return
+		default:
+			renderTable(tableRows([]*Pinger{p}))
+		}
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		s string
+		w int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"目标", 4},
+		{"core-gw site=北京", 17},
+		{"12.5%", 5},
+	}
+	for _, tt := range tests {
+		if got := displayWidth(tt.s); got != tt.w {
+			t.Errorf("displayWidth(%q) = %d，应为 %d", tt.s, got, tt.w)
+		}
+	}
+}
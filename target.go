@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Target 由命令行参数解析出的目标
+type Target struct {
+	Host   string //主机名或IP，IPv6不带方括号
+	Port   string //端口，未指定时为空
+	Scheme string //URL协议，如 https，非URL时为空
+}
+
+// 规范化目标参数
+// 支持 URL(https://example.com:8443/path)、host:port、[IPv6]:port、[IPv6] 以及普通主机名/IP
+func normalizeTarget(arg string) Target {
+	//URL：取出主机名、端口和协议；没有主机的URL(如 file:///path)原样作为主机，解析时报错
+	if strings.Contains(arg, "://") {
+		if u, err := url.Parse(arg); err == nil && u.Host != "" {
+			return Target{Host: u.Hostname(), Port: u.Port(), Scheme: u.Scheme}
+		}
+		return Target{Host: arg}
+	}
+
+	//host:port 或 [IPv6]:port
+	if host, port, err := net.SplitHostPort(arg); err == nil {
+		return Target{Host: host, Port: port}
+	}
+
+	//[IPv6]
+	if strings.HasPrefix(arg, "[") && strings.HasSuffix(arg, "]") {
+		return Target{Host: arg[1 : len(arg)-1]}
+	}
+
+	//普通主机名、IPv4 或不带方括号的IPv6
+	return Target{Host: arg}
+}
+
+// 取出ICMP模式下使用的主机，忽略端口；每次建立连接都会调用，不输出提示
+func icmpHost(arg string) string {
+	return normalizeTarget(arg).Host
+}
+
+// ICMP不使用端口，目标指定了端口时给出提示，读取目标参数时对每个目标调用一次
+func noticeIgnoredPort(arg string) {
+	if t := normalizeTarget(arg); t.Port != "" {
+		fmt.Fprintf(os.Stderr, "提示: ICMP 不使用端口，已忽略 %s 中的端口 %s。\n", arg, t.Port)
+	}
+}
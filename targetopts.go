@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 命令行目标的单独参数，key为展开后的目标，如 10.0.0.1=gw,w=200,n=20
+var targetOptions = map[string]TargetConfig{}
+
+// 拆分命令行目标 host[=label][,key=value...]，返回主机、标签及单独参数
+// URL的路径、查询参数中可能有逗号和等号，URL目标不拆分，原样返回
+func splitTargetArg(arg string) (string, map[string]string, TargetConfig, error) {
+	if strings.Contains(arg, "://") {
+		return arg, nil, TargetConfig{}, nil
+	}
+	head, rest, hasOpts := strings.Cut(arg, ",")
+	host, labels, err := splitTargetLabel(head)
+	if err != nil {
+		return "", nil, TargetConfig{}, err
+	}
+	var opts TargetConfig
+	if hasOpts {
+		if opts, err = parseTargetOptions(rest); err != nil {
+			return "", nil, TargetConfig{}, fmt.Errorf("目标 %q: %v", arg, err)
+		}
+	}
+	return host, labels, opts, nil
+}
+
+// 解析逗号分隔的 key=value，key与命令行参数同名：w(毫秒)、n、l、i(秒)
+func parseTargetOptions(s string) (TargetConfig, error) {
+	var t TargetConfig
+	for _, kv := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || key == "" || value == "" {
+			return t, fmt.Errorf("无法解析 %q，应为 key=value", kv)
+		}
+		switch key {
+		case "w", "timeout":
+			n, err := parsePositiveInt(value, false)
+			if err != nil {
+				return t, fmt.Errorf("选项 %s: %v", key, err)
+			}
+			t.Timeout = &n
+		case "n", "c", "count":
+			n, err := parsePositiveInt(value, false)
+			if err != nil {
+				return t, fmt.Errorf("选项 %s: %v", key, err)
+			}
+			v := int(n)
+			t.Count = &v
+		case "l", "s", "size":
+			n, err := parsePositiveInt(value, true)
+			if err == nil && n > maxPayload {
+				err = fmt.Errorf("取值 %d 超出范围 0-%d", n, maxPayload)
+			}
+			if err != nil {
+				return t, fmt.Errorf("选项 %s: %v", key, err)
+			}
+			v := int(n)
+			t.Size = &v
+		case "i", "interval":
+			sec, err := strconv.ParseFloat(value, 64)
+			if err != nil || sec < 0 {
+				return t, fmt.Errorf("选项 %s: 应为不小于0的秒数，实际为 %s", key, value)
+			}
+			ms := int64(sec * 1000)
+			t.Interval = &ms
+		default:
+			return t, fmt.Errorf("未知的选项 %q，可用的选项为 w、n、l、i", key)
+		}
+	}
+	return t, nil
+}
+
+// 以目标的单独参数覆盖命令行参数，优先级高于命令行参数
+func (t TargetConfig) override(p *Pinger) {
+	if t.Timeout != nil {
+		p.Timeout = *t.Timeout
+	}
+	if t.Count != nil {
+		p.Count = *t.Count
+	}
+	if t.Size != nil {
+		p.Size = *t.Size
+	}
+	if t.Interval != nil {
+		p.Interval = *t.Interval
+	}
+}
+
+// 是否配置了任何单独参数
+func (t TargetConfig) hasOptions() bool {
+	return t.Timeout != nil || t.Count != nil || t.Size != nil || t.Interval != nil
+}
+
+// 各目标参数可能不同时，统计信息中显示该目标实际使用的参数
+func (p *Pinger) optionsText() string {
+	count := strconv.Itoa(p.Count)
+	if forever {
+		count = "不限"
+	}
+	return fmt.Sprintf("超时=%dms 次数=%s 大小=%d 间隔=%s", p.Timeout, count, p.Size, time.Duration(p.Interval)*time.Millisecond)
+}
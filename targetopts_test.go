@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSplitTargetArg(t *testing.T) {
+	tests := []struct {
+		arg   string
+		host  string
+		label string
+		opts  string //optionsOf的结果，空表示没有单独参数
+		err   string //空表示没有错误
+	}{
+		{"10.0.0.1", "10.0.0.1", "", "", ""},
+		{"10.0.0.1=gw", "10.0.0.1", "gw", "", ""},
+		{"10.0.0.1,w=200", "10.0.0.1", "", "w=200", ""},
+		{"10.0.0.1=gw,w=200,n=20", "10.0.0.1", "gw", "w=200 n=20", ""},
+		{"example.com=remote,l=0,i=0.5", "example.com", "remote", "l=0 i=500", ""},
+		{"10.0.0.1,timeout=50,count=3,size=64,interval=2", "10.0.0.1", "", "w=50 n=3 l=64 i=2000", ""}, //长参数名
+		{"10.0.0.1,c=3,s=16", "10.0.0.1", "", "n=3 l=16", ""},                                          //Linux风格
+		{"10.0.0.1,x=1", "", "", "", `目标 "10.0.0.1,x=1": 未知的选项 "x"，可用的选项为 w、n、l、i`},
+		{"10.0.0.1,w", "", "", "", `无法解析 "w"，应为 key=value`},
+		{"10.0.0.1,w=", "", "", "", `无法解析 "w="`},
+		{"10.0.0.1,", "", "", "", `无法解析 ""`},
+		{"10.0.0.1,w=abc", "", "", "", "选项 w: 应为整数，实际为 abc"},
+		{"10.0.0.1,w=0", "", "", "", "选项 w: 取值 0 超出范围"},
+		{"10.0.0.1,n=-1", "", "", "", "选项 n: 取值 -1 超出范围"},
+		{"10.0.0.1,l=65508", "", "", "", "选项 l: 取值 65508 超出范围"},
+		{"10.0.0.1,i=-1", "", "", "", "选项 i: 应为不小于0的秒数，实际为 -1"},
+		{"=gw,w=200", "", "", "", "目标"},
+		{"https://example.com/a,b", "https://example.com/a,b", "", "", ""}, //URL不拆分
+		{"https://example.com:8443/path?x=y,w=200", "https://example.com:8443/path?x=y,w=200", "", "", ""},
+	}
+	for _, tt := range tests {
+		host, labels, opts, err := splitTargetArg(tt.arg)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: err = %v，期望包含 %q", tt.arg, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || host != tt.host || labels[cliLabelName] != tt.label || optionsOf(opts) != tt.opts {
+			t.Errorf("%s: %q %v %q %v，期望 %q %q %q", tt.arg, host, labels, optionsOf(opts), err, tt.host, tt.label, tt.opts)
+		}
+	}
+}
+
+// 单独参数的简短表示，如 "w=200 n=20"
+func optionsOf(t TargetConfig) string {
+	var parts []string
+	if t.Timeout != nil {
+		parts = append(parts, fmt.Sprintf("w=%d", *t.Timeout))
+	}
+	if t.Count != nil {
+		parts = append(parts, fmt.Sprintf("n=%d", *t.Count))
+	}
+	if t.Size != nil {
+		parts = append(parts, fmt.Sprintf("l=%d", *t.Size))
+	}
+	if t.Interval != nil {
+		parts = append(parts, fmt.Sprintf("i=%d", *t.Interval))
+	}
+	return strings.Join(parts, " ")
+}
+
+// 单独参数 > 命令行参数 > 默认值，未单独设置的参数沿用命令行参数
+func TestTargetOptionPrecedence(t *testing.T) {
+	t.Cleanup(func() {
+		targetOptions = map[string]TargetConfig{}
+		targetLabels = map[string]map[string]string{}
+	})
+	type params struct {
+		timeout  int64
+		count    int
+		size     int
+		interval int64
+	}
+	tests := []struct {
+		name string
+		args []string
+		want params
+	}{
+		{"默认值", []string{"10.0.0.1"}, params{1000, 4, 32, 0}},
+		{"命令行参数", []string{"-w", "500", "-n", "2", "-l", "64", "-i", "1", "10.0.0.1"}, params{500, 2, 64, 1000}},
+		{"单独参数覆盖默认值", []string{"10.0.0.1,w=200,n=20,l=16,i=0.5"}, params{200, 20, 16, 500}},
+		{"单独参数覆盖命令行参数", []string{"-w", "500", "-n", "2", "-l", "64", "-i", "1", "10.0.0.1,w=200,n=20,l=16,i=0.5"}, params{200, 20, 16, 500}},
+		{"部分覆盖", []string{"-w", "500", "-n", "2", "10.0.0.1=gw,n=20"}, params{500, 20, 32, 0}},
+		{"长参数名", []string{"-w", "500", "10.0.0.1,timeout=50,count=3"}, params{50, 3, 32, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targetOptions = map[string]TargetConfig{}
+			parseArgs(t, tt.args...)
+			hosts := getArgOfHost()
+			if len(hosts) != 1 || hosts[0] != "10.0.0.1" {
+				t.Fatalf("目标 = %q", hosts)
+			}
+			p := newPinger(hosts[0])
+			if got := (params{p.Timeout, p.Count, p.Size, p.Interval}); got != tt.want {
+				t.Errorf("%v: 参数 %+v，期望 %+v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+// 其他目标不受单独参数影响；网段展开后的每个地址都使用同样的单独参数
+func TestTargetOptionScope(t *testing.T) {
+	t.Cleanup(func() { targetOptions = map[string]TargetConfig{} })
+	parseArgs(t, "-w", "500", "10.0.0.1,w=200", "10.0.0.2", "10.0.1.0/31,n=7")
+	hosts := getArgOfHost()
+	want := map[string][2]int64{ //超时、次数
+		"10.0.0.1": {200, 4},
+		"10.0.0.2": {500, 4},
+		"10.0.1.0": {500, 7},
+		"10.0.1.1": {500, 7},
+	}
+	if len(hosts) != len(want) {
+		t.Fatalf("目标 = %q", hosts)
+	}
+	for _, h := range hosts {
+		p := newPinger(h)
+		if got := [2]int64{p.Timeout, int64(p.Count)}; got != want[h] {
+			t.Errorf("%s: 超时、次数 = %v，期望 %v", h, got, want[h])
+		}
+	}
+}
+
+// 有单独参数时统计信息中显示该目标实际使用的参数
+func TestTargetOptionSummary(t *testing.T) {
+	t.Cleanup(func() { targetOptions = map[string]TargetConfig{} })
+	tests := []struct {
+		args []string
+		want string //空表示不显示
+	}{
+		{[]string{"-n", "2", "-i", "0", "127.0.0.1"}, ""},
+		{[]string{"-n", "2", "-i", "0", "127.0.0.1,w=300,l=16"}, "参数: 超时=300ms 次数=2 大小=16 间隔=0s"},
+		{[]string{"-i", "0", "127.0.0.1,n=3"}, "参数: 超时=1000ms 次数=3 大小=32 间隔=0s"},
+	}
+	for _, tt := range tests {
+		targetOptions = map[string]TargetConfig{}
+		parseArgs(t, tt.args...)
+		hosts := getArgOfHost()
+		predial(t, hosts[0], newMockConn())
+		p := newPinger(hosts[0])
+		stdout, _ := captureOutput(t, p.Run)
+		if tt.want == "" {
+			if strings.Contains(stdout, "参数:") {
+				t.Errorf("%v: 没有单独参数时不应显示参数:\n%s", tt.args, stdout)
+			}
+			continue
+		}
+		if !strings.Contains(stdout, tt.want) {
+			t.Errorf("%v: 输出中没有 %q:\n%s", tt.args, tt.want, stdout)
+		}
+		if ss := p.Stats.Snapshot(); ss.Sent != p.Count {
+			t.Errorf("%v: 发送 %d 次，期望 %d", tt.args, ss.Sent, p.Count)
+		}
+	}
+}
+
+// URL目标中的等号、逗号不作为标签和单独参数，规范化后得到原来的主机
+func TestURLTargetArgs(t *testing.T) {
+	t.Cleanup(func() {
+		targetOptions = map[string]TargetConfig{}
+		targetLabels = map[string]map[string]string{}
+	})
+	urls := []string{"https://example.com:8443/path?x=y", "https://example.com/a,b", "http://[2001:db8::1]/q?a=1,b=2"}
+	parseArgs(t, urls...)
+	hosts := getArgOfHost()
+	if strings.Join(hosts, " ") != strings.Join(urls, " ") {
+		t.Fatalf("目标 = %q，期望 %q", hosts, urls)
+	}
+	if len(targetLabels) != 0 || len(targetOptions) != 0 {
+		t.Errorf("标签 %v，单独参数 %v，期望都没有", targetLabels, targetOptions)
+	}
+	for i, want := range []string{"example.com", "example.com", "2001:db8::1"} {
+		if got := normalizeTarget(hosts[i]).Host; got != want {
+			t.Errorf("%s: 主机 = %q，期望 %q", hosts[i], got, want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+var (
+	trimOutliers    bool    //-trim-outliers 同时输出去除离群值后的耗时统计
+	trimPct         float64 //-trim-pct 两端各去除的百分比
+	trimMethod      string  //-trim-method pct 或 iqr
+	baselineTrimmed bool    //-baseline-trimmed 基线的保存与比较使用去除离群值后的耗时
+)
+
+// 去除离群值后的耗时统计，丢失率不受影响
+type trimmedRTT struct {
+	min, avg, max, p95 int64
+	kept, dropped      int
+}
+
+// 成功请求的耗时，按从小到大排列
+func sortedRTTs(samples []probeSample) []int64 {
+	var rtts []int64
+	for _, s := range samples {
+		if s.OK {
+			rtts = append(rtts, s.RTT)
+		}
+	}
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+	return rtts
+}
+
+// 两端各去除pct%的样本(向下取整)，sorted须已排序
+func trimPercent(sorted []int64, pct float64) []int64 {
+	k := int(float64(len(sorted)) * pct / 100)
+	if 2*k >= len(sorted) {
+		return sorted
+	}
+	return sorted[k : len(sorted)-k]
+}
+
+// 去除 [Q1-1.5IQR, Q3+1.5IQR] 之外的样本，sorted须已排序
+func trimIQR(sorted []int64) []int64 {
+	if len(sorted) < 4 {
+		return sorted
+	}
+	q1, q3 := quantile(sorted, 0.25), quantile(sorted, 0.75)
+	lo, hi := q1-1.5*(q3-q1), q3+1.5*(q3-q1)
+	var kept []int64
+	for _, v := range sorted {
+		if float64(v) >= lo && float64(v) <= hi {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// 已排序样本的分位数(线性插值)
+func quantile(sorted []int64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return float64(sorted[i])
+	}
+	return float64(sorted[i]) + (pos-float64(i))*float64(sorted[i+1]-sorted[i])
+}
+
+// 按 -trim-method 去除离群值并计算最短、平均、P95、最长，没有成功请求时返回false
+func trimRTT(samples []probeSample) (trimmedRTT, bool) {
+	sorted := sortedRTTs(samples)
+	if len(sorted) == 0 {
+		return trimmedRTT{}, false
+	}
+	kept := trimPercent(sorted, trimPct)
+	if trimMethod == "iqr" {
+		kept = trimIQR(sorted)
+	}
+	var total int64
+	for _, v := range kept {
+		total += v
+	}
+	rank := int(math.Ceil(0.95 * float64(len(kept))))
+	if rank < 1 {
+		rank = 1
+	}
+	return trimmedRTT{
+		min: kept[0], max: kept[len(kept)-1], avg: total / int64(len(kept)), p95: kept[rank-1],
+		kept: len(kept), dropped: len(sorted) - len(kept),
+	}, true
+}
+
+// 去除方法的说明
+func trimMethodText() string {
+	if trimMethod == "iqr" {
+		return "IQR"
+	}
+	return fmt.Sprintf("两端各 %g%%", trimPct)
+}
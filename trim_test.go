@@ -0,0 +1,170 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// n 个样本 1..n
+func seq(n int) []int64 {
+	s := make([]int64, n)
+	for i := range s {
+		s[i] = int64(i + 1)
+	}
+	return s
+}
+
+func TestTrimPercent(t *testing.T) {
+	tests := []struct {
+		n    int
+		pct  float64
+		want []int64
+	}{
+		{20, 5, seq(19)[1:]},  //两端各去除1个
+		{20, 10, seq(18)[2:]}, //两端各去除2个
+		{20, 0, seq(20)},
+		{19, 5, seq(19)}, //0.95个向下取整为0
+		{3, 40, seq(3)[1:2]},
+		{2, 49, seq(2)}, //不足时不去除，而不是去除全部
+		{1, 49, seq(1)},
+	}
+	for _, tt := range tests {
+		if got := trimPercent(seq(tt.n), tt.pct); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("trimPercent(1..%d, %g) = %v，期望 %v", tt.n, tt.pct, got, tt.want)
+		}
+	}
+}
+
+func TestTrimIQR(t *testing.T) {
+	tests := []struct {
+		in, want []int64
+	}{
+		{[]int64{10, 11, 12, 13, 14, 900}, []int64{10, 11, 12, 13, 14}},    //Q1=11.25 Q3=13.75，上限17.5
+		{[]int64{1, 50, 51, 52, 53, 54}, []int64{50, 51, 52, 53, 54}},      //下限一侧
+		{[]int64{10, 10, 10, 10, 10, 10}, []int64{10, 10, 10, 10, 10, 10}}, //IQR为0时保留相同的值
+		{[]int64{10, 20, 30, 40}, []int64{10, 20, 30, 40}},
+		{[]int64{1, 2, 900}, []int64{1, 2, 900}}, //不足4个样本时不去除
+	}
+	for _, tt := range tests {
+		if got := trimIQR(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("trimIQR(%v) = %v，期望 %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	tests := []struct {
+		sorted []int64
+		q      float64
+		want   float64
+	}{
+		{[]int64{10, 11, 12, 13, 14, 900}, 0.25, 11.25},
+		{[]int64{10, 11, 12, 13, 14, 900}, 0.75, 13.75},
+		{[]int64{10, 20}, 0.5, 15},
+		{[]int64{10, 20}, 1, 20},
+		{[]int64{7}, 0.25, 7},
+	}
+	for _, tt := range tests {
+		if got := quantile(tt.sorted, tt.q); got != tt.want {
+			t.Errorf("quantile(%v, %g) = %g，期望 %g", tt.sorted, tt.q, got, tt.want)
+		}
+	}
+}
+
+// 22次请求：耗时20-38ms的19次、一次900ms的尖峰、2次丢失
+func spikePinger() *Pinger {
+	p := &Pinger{Arg: "10.0.0.1", Addr: "10.0.0.1", Stats: newStatistics()}
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local)
+	for i := 0; i < 22; i++ {
+		at := start.Add(time.Duration(i) * time.Second)
+		switch {
+		case i == 5:
+			p.Stats.addRecord(at, 900, true)
+		case i == 10 || i == 11:
+			p.Stats.addRecord(at, 0, false)
+		case i < 5:
+			p.Stats.addRecord(at, int64(20+i), true)
+		case i < 10:
+			p.Stats.addRecord(at, int64(20+i-1), true)
+		default:
+			p.Stats.addRecord(at, int64(20+i-3), true)
+		}
+	}
+	return p
+}
+
+func TestTrimRTT(t *testing.T) {
+	tests := []struct {
+		args []string
+		want trimmedRTT
+	}{
+		{nil, trimmedRTT{min: 21, avg: 29, max: 38, p95: 38, kept: 18, dropped: 2}},             //两端各5%
+		{[]string{"-trim-pct", "0"}, trimmedRTT{min: 20, avg: 72, max: 900, p95: 38, kept: 20}}, //P95不受一个尖峰的影响,
+		{[]string{"-trim-method", "iqr"}, trimmedRTT{min: 20, avg: 29, max: 38, p95: 38, kept: 19, dropped: 1}},
+	}
+	p := spikePinger()
+	for _, tt := range tests {
+		parseArgs(t, append(tt.args, "10.0.0.1")...)
+		got, ok := trimRTT(p.Stats.sampleList())
+		if !ok || got != tt.want {
+			t.Errorf("参数 %q: %+v，期望 %+v", tt.args, got, tt.want)
+		}
+	}
+	if _, ok := trimRTT([]probeSample{{OK: false}}); ok {
+		t.Error("没有成功的请求时应返回false")
+	}
+}
+
+// 原始与去除离群值后的耗时一起输出，丢失率不受影响
+func TestTrimSummary(t *testing.T) {
+	parseArgs(t, "10.0.0.1")
+	plain, _ := captureOutput(t, spikePinger().printSummary)
+	parseArgs(t, "-trim-outliers", "10.0.0.1")
+	trimmed, _ := captureOutput(t, spikePinger().printSummary)
+
+	loss := "数据包: 已发送 = 22，已接收 = 20，丢失 = 2 (9.09% 丢失)"
+	raw := "最短 = 20ms，最长 = 900ms，平均 = 72ms"
+	for _, want := range []string{loss, raw, "去除离群值后(两端各 5%，去除 2 个): 最短 = 21ms，最长 = 38ms，平均 = 29ms"} {
+		if !strings.Contains(trimmed, want) {
+			t.Errorf("-trim-outliers 的统计信息中没有 %q:\n%s", want, trimmed)
+		}
+	}
+	if !strings.Contains(plain, loss) || !strings.Contains(plain, raw) || strings.Contains(plain, "去除离群值") {
+		t.Errorf("没有 -trim-outliers 时的统计信息:\n%s", plain)
+	}
+}
+
+// -baseline-trimmed 只替换基线中的耗时，不改变丢失率
+func TestBaselineTrimmed(t *testing.T) {
+	parseArgs(t, "10.0.0.1")
+	raw := baselineEntryOf(spikePinger())
+	parseArgs(t, "-baseline-trimmed", "-trim-method", "iqr", "10.0.0.1")
+	got := baselineEntryOf(spikePinger())
+	if got.Min != 20 || got.Avg != 29 || got.P95 != 38 || got.Max != 38 {
+		t.Errorf("去除离群值后的基线 = %+v", got)
+	}
+	if raw.Max != 900 || got.Sent != raw.Sent || got.Received != raw.Received || got.Loss != raw.Loss {
+		t.Errorf("基线 %+v，原始 %+v", got, raw)
+	}
+}
+
+func TestTrimFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string //空表示没有错误
+	}{
+		{[]string{"-trim-outliers", "-trim-pct", "49.5", "x"}, ""},
+		{[]string{"-trim-pct", "50", "x"}, "-trim-pct: 取值 50 超出范围"},
+		{[]string{"-trim-pct", "-1", "x"}, "-trim-pct: 取值 -1 超出范围"},
+		{[]string{"-trim-method", "iqr", "x"}, ""},
+		{[]string{"-trim-method", "mad", "x"}, `-trim-method: 不支持的方法 "mad"`},
+	}
+	for _, tt := range tests {
+		errs := argErrors(t, tt.args...)
+		if tt.err == "" && len(errs) > 0 || tt.err != "" && !hasArgError(errs, tt.err) {
+			t.Errorf("参数 %q: 错误 %q，期望 %q", tt.args, errs, tt.err)
+		}
+	}
+}
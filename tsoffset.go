@@ -0,0 +1,113 @@
+package main
+
+import (
+	"sort"
+)
+
+var tsOffset bool //-offset 时间戳模式下估计对端时钟偏差及路径不对称
+
+// 一次时间戳请求的四个时间：发起、对端接收、对端发送、本端收到应答，均为UTC零点以来的毫秒数
+type tsSample struct {
+	originate, receive, transmit, end uint32
+}
+
+// 对端时间戳是否为常见的无效值：0(未填写)或最高位为1(非标准格式)
+// 真实的0只出现在UTC零点整，这里一并视为无效
+func tsGarbage(v uint32) bool {
+	return v == 0 || v&timestampNonStandard != 0
+}
+
+// 样本中对端的接收、发送时间戳是否有效
+func (s tsSample) valid() bool {
+	return !tsGarbage(s.receive) && !tsGarbage(s.transmit)
+}
+
+// 去程、回程(毫秒)，均包含两端时钟的偏差
+func (s tsSample) legs() (forward, back int64) {
+	return timestampDiff(s.receive, s.originate), timestampDiff(s.end, s.transmit)
+}
+
+// 对端时钟相对本端的偏差(毫秒)，与NTP相同假设去程与回程相等：((T2-T1) + (T3-T4)) / 2
+func (s tsSample) offset() float64 {
+	forward, back := s.legs()
+	return float64(forward-back) / 2
+}
+
+// 时钟偏差分析的结果
+type tsOffsetResult struct {
+	Samples   int     //有效样本数
+	Garbage   int     //对端时间戳无效而被排除的样本数
+	Offset    float64 //时钟偏差的中位数(毫秒)
+	Asymmetry float64 //去程与回程排队时延中位数之差(毫秒)，正值表示去程更拥塞
+}
+
+// 分析一组样本：无效样本只计数，不参与中位数
+// 排队时延为每个方向相对本方向最小值的增量，两端时钟的偏差在相减时抵消，
+// 因此不对称估计不依赖时钟同步，但无法发现两个方向固定时延(如路由)的差异
+func analyzeTSOffset(samples []tsSample) tsOffsetResult {
+	var r tsOffsetResult
+	var offsets []float64
+	var forwards, backs []int64
+	for _, s := range samples {
+		if !s.valid() {
+			r.Garbage++
+			continue
+		}
+		forward, back := s.legs()
+		forwards = append(forwards, forward)
+		backs = append(backs, back)
+		offsets = append(offsets, s.offset())
+	}
+	r.Samples = len(offsets)
+	if r.Samples == 0 {
+		return r
+	}
+	r.Offset = medianFloat(offsets)
+	r.Asymmetry = medianFloat(queuing(forwards)) - medianFloat(queuing(backs))
+	return r
+}
+
+// 每个值相对最小值的增量
+func queuing(ds []int64) []float64 {
+	min := ds[0]
+	for _, d := range ds[1:] {
+		if d < min {
+			min = d
+		}
+	}
+	q := make([]float64, len(ds))
+	for i, d := range ds {
+		q[i] = float64(d - min)
+	}
+	return q
+}
+
+// 中位数，偶数个时取中间两个的平均值；不修改参数
+func medianFloat(vs []float64) float64 {
+	if len(vs) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), vs...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// 输出时钟偏差分析的汇总及注意事项
+func (p *Pinger) printTSOffset(samples []tsSample) {
+	r := analyzeTSOffset(samples)
+	p.printf("时钟偏差估计:\n")
+	if r.Garbage > 0 {
+		p.printf("    注意: %d 个应答的时间戳无效(为0或最高位为1)，已排除。\n", r.Garbage)
+	}
+	if r.Samples == 0 {
+		p.printf("    没有有效的时间戳，无法估计。\n")
+		return
+	}
+	p.printf("    对端时钟偏差(中位数) = %+.1fms，路径不对称(去程-回程排队时延) = %+.1fms，有效样本 = %d\n",
+		r.Offset, r.Asymmetry, r.Samples)
+	p.printf("    注意: 偏差假设去程与回程的时延相等，固定的路径不对称会全部计入偏差；时间戳精度为1ms。\n")
+}
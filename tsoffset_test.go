@@ -0,0 +1,156 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// 按单向时延及对端时钟偏差(毫秒)构造样本，时间戳按UTC零点取模
+func tsProbe(originate, forward, process, back, offset int64) tsSample {
+	wrap := func(ms int64) uint32 { return uint32((ms%msPerDay + msPerDay) % msPerDay) }
+	receive := originate + forward + offset
+	return tsSample{
+		originate: wrap(originate),
+		receive:   wrap(receive),
+		transmit:  wrap(receive + process),
+		end:       wrap(originate + forward + process + back),
+	}
+}
+
+func TestTSGarbage(t *testing.T) {
+	tests := []struct {
+		v       uint32
+		garbage bool
+	}{
+		{0, true},
+		{timestampNonStandard, true},
+		{timestampNonStandard | 12345, true},
+		{0xffffffff, true},
+		{1, false},
+		{msPerDay - 1, false},
+		{43200000, false}, //UTC正午
+	}
+	for _, tt := range tests {
+		if got := tsGarbage(tt.v); got != tt.garbage {
+			t.Errorf("tsGarbage(%#x) = %v，期望 %v", tt.v, got, tt.garbage)
+		}
+	}
+}
+
+func TestTSSampleOffset(t *testing.T) {
+	tests := []struct {
+		name          string
+		s             tsSample
+		forward, back int64
+		offset        float64
+	}{
+		{"时钟同步", tsProbe(36000000, 10, 1, 10, 0), 10, 10, 0},
+		{"对端快50ms", tsProbe(36000000, 10, 1, 10, 50), 60, -40, 50},
+		{"对端慢30ms", tsProbe(36000000, 10, 1, 10, -30), -20, 40, -30},
+		{"去程慢，计入偏差", tsProbe(36000000, 30, 1, 10, 0), 30, 10, 10},
+		{"跨过UTC零点", tsProbe(msPerDay-5, 10, 1, 10, 0), 10, 10, 0},
+		{"对端已过零点", tsProbe(msPerDay-20, 10, 1, 10, 15), 25, -5, 15},
+	}
+	for _, tt := range tests {
+		forward, back := tt.s.legs()
+		if forward != tt.forward || back != tt.back || tt.s.offset() != tt.offset {
+			t.Errorf("%s: 去程 %d 回程 %d 偏差 %v，期望 %d、%d、%v", tt.name, forward, back, tt.s.offset(), tt.forward, tt.back, tt.offset)
+		}
+	}
+}
+
+// 无效的时间戳只计数，不影响中位数
+func TestAnalyzeTSOffset(t *testing.T) {
+	garbage := []tsSample{
+		{originate: 36000000, receive: 0, transmit: 0, end: 36000020},
+		{originate: 36000000, receive: timestampNonStandard | 1, transmit: timestampNonStandard | 2, end: 36000020},
+		{originate: 36000000, receive: 36000050, transmit: 0, end: 36000020},
+		{originate: 36000000, receive: 0xffffffff, transmit: 36000060, end: 36000020},
+	}
+	tests := []struct {
+		name    string
+		samples []tsSample
+		want    tsOffsetResult
+	}{
+		{"没有样本", nil, tsOffsetResult{}},
+		{"稳定的偏差", []tsSample{
+			tsProbe(36000000, 10, 1, 10, 50),
+			tsProbe(36001000, 10, 1, 10, 50),
+			tsProbe(36002000, 10, 1, 10, 50),
+		}, tsOffsetResult{Samples: 3, Offset: 50}},
+		{"无效样本多于有效样本", append([]tsSample{
+			tsProbe(36000000, 10, 1, 10, 50),
+			tsProbe(36001000, 12, 1, 10, 50),
+			tsProbe(36002000, 10, 1, 12, 50),
+		}, garbage...), tsOffsetResult{Samples: 3, Garbage: 4, Offset: 50}},
+		{"全部无效", garbage, tsOffsetResult{Garbage: 4}},
+		{"去程排队", []tsSample{
+			tsProbe(36000000, 10, 1, 10, 100),
+			tsProbe(36001000, 30, 1, 10, 100),
+			tsProbe(36002000, 50, 1, 10, 100),
+		}, tsOffsetResult{Samples: 3, Offset: 110, Asymmetry: 20}},
+		{"回程排队", []tsSample{
+			tsProbe(36000000, 10, 1, 10, -20),
+			tsProbe(36001000, 10, 1, 40, -20),
+			tsProbe(36002000, 10, 1, 50, -20),
+			tsProbe(36003000, 10, 1, 10, -20),
+		}, tsOffsetResult{Samples: 4, Offset: -27.5, Asymmetry: -15}},
+		{"跨过UTC零点", []tsSample{
+			tsProbe(msPerDay-3, 10, 1, 10, 7),
+			tsProbe(msPerDay-2, 10, 1, 10, 7),
+			tsProbe(1, 10, 1, 10, 7),
+		}, tsOffsetResult{Samples: 3, Offset: 7}},
+	}
+	for _, tt := range tests {
+		if got := analyzeTSOffset(tt.samples); got != tt.want {
+			t.Errorf("%s: %+v，期望 %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMedianFloat(t *testing.T) {
+	tests := []struct {
+		vs   []float64
+		want float64
+	}{
+		{nil, 0},
+		{[]float64{3}, 3},
+		{[]float64{3, 1, 2}, 2},
+		{[]float64{4, 1, 3, 2}, 2.5},
+		{[]float64{-5, 100, -5}, -5},
+	}
+	for _, tt := range tests {
+		orig := append([]float64(nil), tt.vs...)
+		if got := medianFloat(tt.vs); got != tt.want {
+			t.Errorf("medianFloat(%v) = %v，期望 %v", tt.vs, got, tt.want)
+		}
+		for i := range orig {
+			if tt.vs[i] != orig[i] {
+				t.Errorf("medianFloat修改了参数: %v", tt.vs)
+				break
+			}
+		}
+	}
+}
+
+func TestPrintTSOffset(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []tsSample
+		want    []string
+	}{
+		{"有效", []tsSample{tsProbe(36000000, 10, 1, 10, 50), {originate: 36000000, end: 36000020}},
+			[]string{"1 个应答的时间戳无效", "对端时钟偏差(中位数) = +50.0ms", "路径不对称(去程-回程排队时延) = +0.0ms，有效样本 = 1", "假设去程与回程的时延相等"}},
+		{"全部无效", []tsSample{{originate: 36000000, end: 36000020}},
+			[]string{"1 个应答的时间戳无效", "没有有效的时间戳，无法估计"}},
+	}
+	for _, tt := range tests {
+		p := &Pinger{}
+		stdout, _ := captureOutput(t, func() { p.printTSOffset(tt.samples) })
+		for _, want := range tt.want {
+			if !strings.Contains(stdout, want) {
+				t.Errorf("%s: 输出中没有 %q:\n%s", tt.name, want, stdout)
+			}
+		}
+	}
+}
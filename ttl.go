@@ -0,0 +1,31 @@
+package main
+
+import "fmt"
+
+var (
+	sendTTL  int  //-ttl 发送报文的TTL，0表示使用系统默认值
+	showHops bool //-hops 在回复的TTL后显示估计的跳数
+)
+
+// 常见系统的初始TTL，从小到大
+var commonInitialTTLs = []int{64, 128, 255}
+
+// 根据回复的TTL估计经过的跳数
+// 假设对端使用不小于ttl的最小常见初始TTL(64/128/255)，返回跳数及所假设的初始TTL
+func estimateHops(ttl int) (hops, initial int) {
+	for _, initial := range commonInitialTTLs {
+		if ttl <= initial {
+			return initial - ttl, initial
+		}
+	}
+	return 0, ttl
+}
+
+// 回复中TTL的显示文本，-hops 时附带估计的跳数
+func ttlText(ttl int) string {
+	if !showHops {
+		return fmt.Sprint(ttl)
+	}
+	hops, _ := estimateHops(ttl)
+	return fmt.Sprintf("%d (约 %d 跳)", ttl, hops)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEstimateHops(t *testing.T) {
+	tests := []struct {
+		ttl, hops, initial int
+	}{
+		{64, 0, 64},
+		{53, 11, 64},
+		{1, 63, 64},
+		{65, 63, 128}, //大于64时只能是128或255
+		{128, 0, 128},
+		{117, 11, 128},
+		{129, 126, 255},
+		{245, 10, 255},
+		{255, 0, 255},
+	}
+	for _, tt := range tests {
+		hops, initial := estimateHops(tt.ttl)
+		if hops != tt.hops || initial != tt.initial {
+			t.Errorf("estimateHops(%d) = %d, %d，期望 %d, %d", tt.ttl, hops, initial, tt.hops, tt.initial)
+		}
+	}
+}
+
+func TestTTLText(t *testing.T) {
+	parseArgs(t, "example.com")
+	if got := ttlText(53); got != "53" {
+		t.Errorf("未指定 -hops 时 ttlText(53) = %q", got)
+	}
+	parseArgs(t, "-hops", "example.com")
+	if got := ttlText(53); got != "53 (约 11 跳)" {
+		t.Errorf("-hops 时 ttlText(53) = %q", got)
+	}
+}
+
+func TestTTLFlag(t *testing.T) {
+	parseArgs(t, "-ttl", "5", "example.com")
+	if sendTTL != 5 {
+		t.Errorf("sendTTL = %d，期望 5", sendTTL)
+	}
+	for _, v := range []string{"-1", "256"} {
+		if errs := argErrors(t, "-ttl", v, "example.com"); !hasArgError(errs, "-ttl") {
+			t.Errorf("-ttl %s 没有报错: %q", v, errs)
+		}
+	}
+}
+
+// JSONL记录中有回复时带有估计的跳数，超时的记录没有该字段
+func TestRecordHops(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rec.jsonl")
+	if err := startRecord(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stopRecord)
+	now := time.Now()
+	recordProbe(probeSpan{target: "192.0.2.1", seq: 0, start: now, end: now, rtt: 3, ttl: 53, outcome: "success"})
+	recordProbe(probeSpan{target: "192.0.2.1", seq: 1, start: now, end: now, rtt: 1000, outcome: "timeout"})
+	stopRecord()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 { //start + 2
+		t.Fatalf("记录文件有 %d 行:\n%s", len(lines), data)
+	}
+	var ok, lost map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &ok); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[2]), &lost); err != nil {
+		t.Fatal(err)
+	}
+	if ok["hops"] != 11.0 || ok["ttl"] != 53.0 {
+		t.Errorf("成功的记录 = %s，期望 ttl 53、hops 11", lines[1])
+	}
+	if _, has := lost["hops"]; has {
+		t.Errorf("超时的记录不应有hops: %s", lines[2])
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+var twampAddr string //TWAMP-Light 反射器地址
+
+// TWAMP-Light 测试报文格式，见 RFC 4656 §4.1.2 及 RFC 5357 §4.2.1(非认证模式)
+const (
+	twampPort       = "862"      //TWAMP 默认端口
+	twampSenderLen  = 14         //发送报文：序号(4) 时间戳(8) 误差估计(2)
+	twampReflectLen = 41         //反射报文：序号(4) 时间戳(8) 误差估计(2) MBZ(2) 接收时间戳(8) 发送方序号(4) 发送方时间戳(8) 发送方误差估计(2) MBZ(2) 发送方TTL(1)
+	twampErrEst     = 0x0001     //误差估计：未同步(S=0)，Scale=0，Multiplier=1
+	ntpEpochOffset  = 2208988800 //1900-01-01 到 1970-01-01 的秒数
+)
+
+// 反射器返回的测试报文
+type twampReflect struct {
+	seq       uint32    //反射器的序号
+	sent      time.Time //反射器发出时间(T3)
+	received  time.Time //反射器收到时间(T2)
+	senderSeq uint32    //对应的发送序号
+	senderTTL uint8     //发送报文到达反射器时的TTL
+}
+
+// 把时间写为NTP时间戳：32位秒 + 32位小数
+func putNTPTime(b []byte, t time.Time) {
+	binary.BigEndian.PutUint32(b, uint32(t.Unix()+ntpEpochOffset))
+	binary.BigEndian.PutUint32(b[4:], uint32((uint64(t.Nanosecond())<<32)/uint64(time.Second)))
+}
+
+// 解析NTP时间戳
+func ntpTime(b []byte) time.Time {
+	sec := int64(binary.BigEndian.Uint32(b)) - ntpEpochOffset
+	frac := uint64(binary.BigEndian.Uint32(b[4:]))
+	return time.Unix(sec, int64((frac*uint64(time.Second))>>32))
+}
+
+// 解析反射报文
+func parseTWAMPReflect(b []byte) (twampReflect, error) {
+	if len(b) < twampReflectLen {
+		return twampReflect{}, fmt.Errorf("反射报文过短: %d 字节", len(b))
+	}
+	return twampReflect{
+		seq:       binary.BigEndian.Uint32(b[0:4]),
+		sent:      ntpTime(b[4:12]),
+		received:  ntpTime(b[16:24]),
+		senderSeq: binary.BigEndian.Uint32(b[24:28]),
+		senderTTL: b[40],
+	}, nil
+}
+
+// 未指定端口时使用TWAMP默认端口
+func twampTarget(addr string) string {
+	t := normalizeTarget(addr)
+	if t.Port == "" {
+		t.Port = twampPort
+	}
+	return net.JoinHostPort(t.Host, t.Port)
+}
+
+// RunTWAMP 以 TWAMP-Light(RFC 5357 §8，不使用控制连接)向反射器发送UDP测试报文
+// 往返时延扣除了反射器内部的处理时间；去程、回程时延依赖两端时钟同步
+func (p *Pinger) RunTWAMP() {
+	p.Host = twampTarget(p.Arg)
+	conn, err := net.DialTimeout("udp", p.Host, time.Duration(p.Timeout)*time.Millisecond)
+	if err != nil {
+		p.fail(err)
+		p.printf("无法连接 TWAMP 反射器 %s: %v\n", p.Host, err)
+		return
+	}
+	defer conn.Close()
+	p.Addr = conn.RemoteAddr().String()
+
+	p.printf("正在向 TWAMP 反射器 %s 发送测试报文，具有 %d 字节的填充：\n", p.Addr, p.Size)
+	p.printf("(去程、回程时延依赖两端时钟同步)\n")
+
+	pkt := make([]byte, twampSenderLen+p.Size) //发送报文，填充部分全为0
+	binary.BigEndian.PutUint16(pkt[12:14], twampErrEst)
+	bufp := recvBufPool.Get().(*[]byte)
+	defer recvBufPool.Put(bufp)
+	buf := *bufp
+
+	for i := 0; forever || i < p.Count; i++ {
+		if !p.nextProbe(i) {
+			break
+		}
+
+		p.Stats.addSent()
+		conn.SetDeadline(time.Now().Add(time.Duration(p.Timeout) * time.Millisecond))
+
+		t1 := time.Now()
+		binary.BigEndian.PutUint32(pkt[0:4], uint32(i))
+		putNTPTime(pkt[4:12], t1)
+		if _, err := conn.Write(pkt); err != nil {
+			p.Stats.addSendError(err)
+			p.Stats.addFailure()
+			p.printf("请求失败: %s\n", sendErrorText(err))
+			continue
+		}
+
+		//读取到与本次序号对应的反射报文为止，跳过迟到的旧报文
+		var r twampReflect
+		for {
+			n, rerr := conn.Read(buf)
+			if err = rerr; err != nil {
+				break
+			}
+			if r, err = parseTWAMPReflect(buf[:n]); err == nil && r.senderSeq == uint32(i) {
+				break
+			}
+		}
+		t4 := time.Now()
+		if err != nil {
+			p.Stats.addFailure()
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				p.Stats.addTimeout()
+				p.printf("请求超时。\n")
+			} else {
+				p.printf("请求失败: %v\n", err)
+			}
+			continue
+		}
+
+		rtt := t4.Sub(t1) - r.sent.Sub(r.received) //扣除反射器处理时间
+		forward := r.received.Sub(t1)
+		backward := t4.Sub(r.sent)
+		p.Stats.addSuccess(rtt.Milliseconds())
+		p.printf("来自 %s 的回复: 序号=%d 往返=%.3fms 去程=%.3fms 回程=%.3fms TTL=%d\n",
+			p.Addr, i, msFloat(rtt), msFloat(forward), msFloat(backward), r.senderTTL)
+	}
+
+	p.printSummary()
+}
+
+// 时长换算为毫秒，保留小数
+func msFloat(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
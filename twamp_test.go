@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// NTP时间戳的小数部分精度约为0.23ns
+func TestNTPTime(t *testing.T) {
+	for _, want := range []time.Time{
+		time.Unix(0, 0),
+		time.Unix(1700000000, 123456789),
+		time.Date(2030, 1, 2, 3, 4, 5, 999999999, time.UTC),
+	} {
+		b := make([]byte, 8)
+		putNTPTime(b, want)
+		if d := ntpTime(b).Sub(want); d > time.Nanosecond || d < -time.Nanosecond {
+			t.Errorf("ntpTime(putNTPTime(%v)) 相差 %v", want, d)
+		}
+	}
+	b := make([]byte, 8)
+	putNTPTime(b, time.Unix(0, 0))
+	if sec, want := binary.BigEndian.Uint32(b), uint32(ntpEpochOffset); sec != want {
+		t.Errorf("1970-01-01 的NTP秒数 = %d，应为 %d", sec, want)
+	}
+}
+
+func TestParseTWAMPReflect(t *testing.T) {
+	t2 := time.Unix(1700000000, 5000)
+	t3 := t2.Add(150 * time.Microsecond)
+	b := make([]byte, twampReflectLen+3)
+	binary.BigEndian.PutUint32(b[0:4], 77)
+	putNTPTime(b[4:12], t3)
+	putNTPTime(b[16:24], t2)
+	binary.BigEndian.PutUint32(b[24:28], 9)
+	b[40] = 250
+	r, err := parseTWAMPReflect(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.seq != 77 || r.senderSeq != 9 || r.senderTTL != 250 || r.sent.Sub(r.received) != 150*time.Microsecond {
+		t.Errorf("parseTWAMPReflect = %+v", r)
+	}
+	if _, err := parseTWAMPReflect(b[:twampReflectLen-1]); err == nil || !strings.Contains(err.Error(), "反射报文过短") {
+		t.Errorf("过短的报文: %v", err)
+	}
+}
+
+func TestTWAMPTarget(t *testing.T) {
+	tests := []struct {
+		arg, want string
+	}{
+		{"192.0.2.1", "192.0.2.1:862"},
+		{"192.0.2.1:8620", "192.0.2.1:8620"},
+		{"reflector.example", "reflector.example:862"},
+		{"[2001:db8::1]:1862", "[2001:db8::1]:1862"},
+	}
+	for _, tt := range tests {
+		if got := twampTarget(tt.arg); got != tt.want {
+			t.Errorf("twampTarget(%q) = %q，应为 %q", tt.arg, got, tt.want)
+		}
+	}
+}
+
+// 本机的TWAMP-Light反射器：先回复一个过期序号的报文，再回复本次的报文，处理时间为delay
+func startReflector(t *testing.T, delay time.Duration) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 1500)
+		for seq := uint32(0); ; seq++ {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < twampSenderLen {
+				continue
+			}
+			t2 := time.Now()
+			time.Sleep(delay)
+			reply := make([]byte, twampReflectLen)
+			binary.BigEndian.PutUint32(reply[0:4], seq)
+			putNTPTime(reply[16:24], t2)
+			copy(reply[24:38], buf[:14])
+			reply[40] = 255
+			stale := append([]byte(nil), reply...)
+			binary.BigEndian.PutUint32(stale[24:28], binary.BigEndian.Uint32(buf[0:4])-1)
+			pc.WriteTo(stale, addr)
+			putNTPTime(reply[4:12], time.Now())
+			pc.WriteTo(reply, addr)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+// 跳过序号不符的报文，往返时延扣除反射器的处理时间
+func TestRunTWAMP(t *testing.T) {
+	addr := startReflector(t, 50*time.Millisecond)
+	parseArgs(t, "-n", "3", "-w", "2000", "-twamp", addr)
+	p := newPinger(twampAddr)
+	stdout, _ := captureOutput(t, p.RunTWAMP)
+	if n := strings.Count(stdout, "来自 "+addr+" 的回复"); n != 3 {
+		t.Fatalf("收到 %d 个回复，应为 3:\n%s", n, stdout)
+	}
+	if !strings.Contains(stdout, "序号=2 ") || !strings.Contains(stdout, "TTL=255") {
+		t.Errorf("输出:\n%s", stdout)
+	}
+	ss := p.Stats.Snapshot()
+	if ss.Sent != 3 || ss.Received != 3 || ss.Max >= 50 {
+		t.Errorf("统计 = %+v，往返时延应不含反射器的50ms处理时间", ss)
+	}
+}
+
+func TestRunTWAMPTimeout(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	parseArgs(t, "-n", "2", "-w", "100", "x")
+	p := newPinger(pc.LocalAddr().String())
+	stdout, _ := captureOutput(t, p.RunTWAMP)
+	if n := strings.Count(stdout, "请求超时。"); n != 2 {
+		t.Errorf("超时 %d 次，应为 2:\n%s", n, stdout)
+	}
+	if ss := p.Stats.Snapshot(); ss.Sent != 2 || ss.Lost != 2 {
+		t.Errorf("统计 = %+v", ss)
+	}
+}
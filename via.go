@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+)
+
+var viaGateway string //-via 经由该网关ping：为目标临时添加一条主机路由，结束时删除
+
+// 主机路由的操作，Linux下由netlink实现
+type routeTable interface {
+	//main表中到dst的/32主机路由的网关，没有该路由时ok为false
+	hostRoute(dst net.IP) (gw net.IP, ok bool, err error)
+	//添加经由gw到dst的/32路由，已存在时失败(不覆盖)
+	addHostRoute(dst, gw net.IP) error
+	//删除经由gw到dst的/32路由
+	delHostRoute(dst, gw net.IP) error
+}
+
+// 已添加的临时路由
+type tempRoute struct {
+	dst, gw net.IP
+}
+
+var (
+	viaMu     sync.Mutex
+	viaRoutes []tempRoute //按添加顺序，删除时倒序
+	viaTable  routeTable
+)
+
+// 为每个目标添加经由gw的临时主机路由
+// 已有到目标的主机路由时拒绝(不覆盖，也就不需要恢复)；任何一步失败时删除本次已添加的路由
+func installViaRoutes(rt routeTable, dsts []net.IP, gw net.IP) error {
+	viaMu.Lock()
+	defer viaMu.Unlock()
+	viaTable = rt
+	for _, dst := range dsts {
+		if err := addViaRoute(rt, dst, gw); err != nil {
+			removeViaRoutesLocked()
+			return err
+		}
+	}
+	return nil
+}
+
+// 添加一条临时路由并确认内核中生效的是它
+func addViaRoute(rt routeTable, dst, gw net.IP) error {
+	for _, r := range viaRoutes {
+		if r.dst.Equal(dst) {
+			return nil //多个目标解析到同一地址
+		}
+	}
+	if old, ok, err := rt.hostRoute(dst); err != nil {
+		return fmt.Errorf("无法读取路由表: %v", err)
+	} else if ok {
+		return fmt.Errorf("已有到 %s 的主机路由(网关 %s)，不覆盖", dst, gatewayText(old))
+	}
+	if err := rt.addHostRoute(dst, gw); err != nil {
+		return fmt.Errorf("无法添加经由 %s 到 %s 的路由: %v", gw, dst, err)
+	}
+	viaRoutes = append(viaRoutes, tempRoute{dst, gw})
+	if got, ok, err := rt.hostRoute(dst); err != nil || !ok || !got.Equal(gw) {
+		return fmt.Errorf("添加的到 %s 的路由未生效", dst)
+	}
+	return nil
+}
+
+// 删除所有临时路由，结束及Ctrl+C时经closeOutputs调用，可以重复调用
+func removeViaRoutes() {
+	viaMu.Lock()
+	defer viaMu.Unlock()
+	removeViaRoutesLocked()
+}
+
+func removeViaRoutesLocked() {
+	for i := len(viaRoutes) - 1; i >= 0; i-- {
+		r := viaRoutes[i]
+		if err := viaTable.delHostRoute(r.dst, r.gw); err != nil {
+			fmt.Fprintf(os.Stderr, "无法删除临时路由 %s/32 via %s，请手动删除: %v\n", r.dst, r.gw, err)
+		}
+	}
+	viaRoutes = nil
+}
+
+// 路由的网关，直连路由没有网关
+func gatewayText(gw net.IP) string {
+	if gw == nil {
+		return "无，直连"
+	}
+	return gw.String()
+}
+
+// 解析各目标并添加经由 -via 网关的临时路由，失败时退出
+func setupVia(hosts []string) {
+	gw := net.ParseIP(viaGateway).To4() //已在getArgs中校验
+	var dsts []net.IP
+	for _, host := range hosts {
+		addr, err := net.ResolveIPAddr("ip4", icmpHost(host))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-via: 无法解析 %s: %v\n", host, err)
+			exit(1)
+		}
+		dsts = append(dsts, addr.IP.To4())
+	}
+	if err := installViaRoutes(newRouteTable(), dsts, gw); err != nil {
+		fmt.Fprintf(os.Stderr, "-via: %v\n", err)
+		exit(1)
+	}
+	for _, r := range viaRoutes {
+		fmt.Fprintf(os.Stderr, "已添加临时路由 %s/32 via %s，结束时删除。\n", r.dst, r.gw)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const viaSupported = true //-via 需要以netlink修改路由表
+
+// 以netlink(NETLINK_ROUTE)读写main表中的主机路由
+type netlinkRoutes struct{}
+
+func newRouteTable() routeTable {
+	return netlinkRoutes{}
+}
+
+func (netlinkRoutes) hostRoute(dst net.IP) (net.IP, bool, error) {
+	rib, err := syscall.NetlinkRIB(syscall.RTM_GETROUTE, syscall.AF_INET)
+	if err != nil {
+		return nil, false, err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWROUTE || len(m.Data) < syscall.SizeofRtMsg {
+			continue
+		}
+		rtm := (*syscall.RtMsg)(unsafe.Pointer(&m.Data[0]))
+		if rtm.Dst_len != 32 || rtm.Table != syscall.RT_TABLE_MAIN {
+			continue
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(&m)
+		if err != nil {
+			return nil, false, err
+		}
+		var rdst, gw net.IP
+		for _, a := range attrs {
+			switch a.Attr.Type {
+			case syscall.RTA_DST:
+				rdst = net.IP(a.Value)
+			case syscall.RTA_GATEWAY:
+				gw = net.IP(a.Value)
+			}
+		}
+		if rdst.Equal(dst) {
+			return gw, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (netlinkRoutes) addHostRoute(dst, gw net.IP) error {
+	//NLM_F_EXCL：已有相同的路由时由内核拒绝，不会覆盖
+	return routeRequest(syscall.RTM_NEWROUTE, syscall.NLM_F_CREATE|syscall.NLM_F_EXCL, syscall.RT_SCOPE_UNIVERSE, dst, gw)
+}
+
+func (netlinkRoutes) delHostRoute(dst, gw net.IP) error {
+	return routeRequest(syscall.RTM_DELROUTE, 0, syscall.RT_SCOPE_NOWHERE, dst, gw)
+}
+
+// 发送一条路由请求并等待内核的确认
+// 报文为 nlmsghdr + rtmsg + RTA_DST + RTA_GATEWAY，均按主机字节序
+func routeRequest(typ uint16, flags uint16, scope uint8, dst, gw net.IP) error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return os.NewSyscallError("socket", err)
+	}
+	defer syscall.Close(fd)
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return os.NewSyscallError("bind", err)
+	}
+
+	const attrLen = syscall.SizeofRtAttr + 4
+	b := make([]byte, syscall.SizeofNlMsghdr+syscall.SizeofRtMsg+2*attrLen)
+	*(*syscall.NlMsghdr)(unsafe.Pointer(&b[0])) = syscall.NlMsghdr{
+		Len:   uint32(len(b)),
+		Type:  typ,
+		Flags: syscall.NLM_F_REQUEST | syscall.NLM_F_ACK | flags,
+		Seq:   1,
+	}
+	*(*syscall.RtMsg)(unsafe.Pointer(&b[syscall.SizeofNlMsghdr])) = syscall.RtMsg{
+		Family:   syscall.AF_INET,
+		Dst_len:  32,
+		Table:    syscall.RT_TABLE_MAIN,
+		Protocol: syscall.RTPROT_STATIC,
+		Scope:    scope,
+		Type:     syscall.RTN_UNICAST,
+	}
+	off := syscall.SizeofNlMsghdr + syscall.SizeofRtMsg
+	for _, a := range []struct {
+		typ uint16
+		ip  net.IP
+	}{{syscall.RTA_DST, dst}, {syscall.RTA_GATEWAY, gw}} {
+		*(*syscall.RtAttr)(unsafe.Pointer(&b[off])) = syscall.RtAttr{Len: attrLen, Type: a.typ}
+		copy(b[off+syscall.SizeofRtAttr:], a.ip.To4())
+		off += attrLen
+	}
+	if err := syscall.Sendto(fd, b, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return os.NewSyscallError("sendto", err)
+	}
+
+	buf := make([]byte, 4096)
+	n, _, err := syscall.Recvfrom(fd, buf, 0)
+	if err != nil {
+		return os.NewSyscallError("recvfrom", err)
+	}
+	msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+	if err != nil {
+		return err
+	}
+	for _, m := range msgs {
+		if m.Header.Type != syscall.NLMSG_ERROR || len(m.Data) < 4 {
+			continue
+		}
+		//nlmsgerr 的error为负的errno，0表示成功
+		if errno := -*(*int32)(unsafe.Pointer(&m.Data[0])); errno != 0 {
+			return syscall.Errno(errno)
+		}
+		return nil
+	}
+	return errors.New("netlink 没有返回确认")
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+const viaSupported = false //-via 只支持Linux
+
+var errViaUnsupported = errors.New("当前平台不支持修改路由表")
+
+// 当前平台没有路由表操作，-via 在getArgs中即被拒绝
+type noRoutes struct{}
+
+func newRouteTable() routeTable {
+	return noRoutes{}
+}
+
+func (noRoutes) hostRoute(dst net.IP) (net.IP, bool, error) { return nil, false, errViaUnsupported }
+func (noRoutes) addHostRoute(dst, gw net.IP) error          { return errViaUnsupported }
+func (noRoutes) delHostRoute(dst, gw net.IP) error          { return errViaUnsupported }
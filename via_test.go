@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+// 记录各操作顺序的路由表
+type fakeRouteTable struct {
+	routes  map[string]net.IP //dst -> 网关，直连路由的网关为nil
+	ops     []string
+	getErr  error
+	addErr  map[string]error //按目标返回的添加错误
+	delErr  error
+	ignored map[string]bool //添加成功但不生效(被其他路由遮盖)的目标
+}
+
+func newFakeRouteTable() *fakeRouteTable {
+	return &fakeRouteTable{routes: map[string]net.IP{}, addErr: map[string]error{}, ignored: map[string]bool{}}
+}
+
+func (f *fakeRouteTable) hostRoute(dst net.IP) (net.IP, bool, error) {
+	f.ops = append(f.ops, "get "+dst.String())
+	if f.getErr != nil {
+		return nil, false, f.getErr
+	}
+	gw, ok := f.routes[dst.String()]
+	return gw, ok, nil
+}
+
+func (f *fakeRouteTable) addHostRoute(dst, gw net.IP) error {
+	f.ops = append(f.ops, "add "+dst.String())
+	if err := f.addErr[dst.String()]; err != nil {
+		return err
+	}
+	if _, ok := f.routes[dst.String()]; ok {
+		return errors.New("file exists")
+	}
+	if !f.ignored[dst.String()] {
+		f.routes[dst.String()] = gw
+	}
+	return nil
+}
+
+func (f *fakeRouteTable) delHostRoute(dst, gw net.IP) error {
+	f.ops = append(f.ops, "del "+dst.String())
+	if f.delErr != nil {
+		return f.delErr
+	}
+	delete(f.routes, dst.String())
+	return nil
+}
+
+// 添加、确认及失败时回滚的顺序，不需要root权限
+func TestInstallViaRoutes(t *testing.T) {
+	gw := net.ParseIP("192.0.2.254").To4()
+	tests := []struct {
+		name   string
+		dsts   []net.IP
+		setup  func(*fakeRouteTable)
+		err    string
+		ops    string
+		routes int //安装后表中的主机路由数
+	}{
+		{"全部成功", ips("10.0.0.1", "10.0.0.2"), nil, "",
+			"get 10.0.0.1,add 10.0.0.1,get 10.0.0.1,get 10.0.0.2,add 10.0.0.2,get 10.0.0.2", 2},
+		{"重复的目标", ips("10.0.0.1", "10.0.0.1"), nil, "",
+			"get 10.0.0.1,add 10.0.0.1,get 10.0.0.1", 1},
+		{"已有主机路由", ips("10.0.0.1", "10.0.0.2"), func(f *fakeRouteTable) { f.routes["10.0.0.2"] = net.IPv4(10, 9, 9, 9) },
+			"已有到 10.0.0.2 的主机路由(网关 10.9.9.9)，不覆盖",
+			"get 10.0.0.1,add 10.0.0.1,get 10.0.0.1,get 10.0.0.2,del 10.0.0.1", 1},
+		{"已有直连的主机路由", ips("10.0.0.1"), func(f *fakeRouteTable) { f.routes["10.0.0.1"] = nil },
+			"网关 无，直连", "get 10.0.0.1", 1},
+		{"添加失败", ips("10.0.0.1", "10.0.0.2", "10.0.0.3"), func(f *fakeRouteTable) { f.addErr["10.0.0.2"] = errors.New("network is unreachable") },
+			"无法添加经由 192.0.2.254 到 10.0.0.2 的路由: network is unreachable",
+			"get 10.0.0.1,add 10.0.0.1,get 10.0.0.1,get 10.0.0.2,add 10.0.0.2,del 10.0.0.1", 0},
+		{"添加的路由未生效", ips("10.0.0.1", "10.0.0.2"), func(f *fakeRouteTable) { f.ignored["10.0.0.2"] = true },
+			"添加的到 10.0.0.2 的路由未生效",
+			"get 10.0.0.1,add 10.0.0.1,get 10.0.0.1,get 10.0.0.2,add 10.0.0.2,get 10.0.0.2,del 10.0.0.2,del 10.0.0.1", 0},
+		{"无法读取路由表", ips("10.0.0.1"), func(f *fakeRouteTable) { f.getErr = errors.New("permission denied") },
+			"无法读取路由表: permission denied", "get 10.0.0.1", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { viaRoutes, viaTable = nil, nil })
+			rt := newFakeRouteTable()
+			if tt.setup != nil {
+				tt.setup(rt)
+			}
+			err := installViaRoutes(rt, tt.dsts, gw)
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("err = %v，期望 %q", err, tt.err)
+			}
+			if got := strings.Join(rt.ops, ","); got != tt.ops {
+				t.Errorf("操作顺序:\n得到 %s\n期望 %s", got, tt.ops)
+			}
+			if len(rt.routes) != tt.routes {
+				t.Errorf("路由表 = %v，期望 %d 条", rt.routes, tt.routes)
+			}
+			if err != nil && len(viaRoutes) != 0 {
+				t.Errorf("失败后仍记录了临时路由 %v", viaRoutes)
+			}
+		})
+	}
+}
+
+// 结束时倒序删除，可以重复调用；删除失败时提示手动删除并继续
+func TestRemoveViaRoutes(t *testing.T) {
+	t.Cleanup(func() { viaRoutes, viaTable = nil, nil })
+	gw := net.ParseIP("192.0.2.254").To4()
+	rt := newFakeRouteTable()
+	if err := installViaRoutes(rt, ips("10.0.0.1", "10.0.0.2", "10.0.0.3"), gw); err != nil {
+		t.Fatal(err)
+	}
+	rt.ops = nil
+	removeViaRoutes()
+	removeViaRoutes()
+	if got := strings.Join(rt.ops, ","); got != "del 10.0.0.3,del 10.0.0.2,del 10.0.0.1" {
+		t.Errorf("删除顺序 = %s", got)
+	}
+	if len(rt.routes) != 0 {
+		t.Errorf("路由表中还有 %v", rt.routes)
+	}
+
+	if err := installViaRoutes(rt, ips("10.0.0.1", "10.0.0.2"), gw); err != nil {
+		t.Fatal(err)
+	}
+	rt.ops, rt.delErr = nil, errors.New("operation not permitted")
+	_, stderr := captureOutput(t, removeViaRoutes)
+	if got := strings.Join(rt.ops, ","); got != "del 10.0.0.2,del 10.0.0.1" {
+		t.Errorf("删除失败后没有继续: %s", got)
+	}
+	for _, want := range []string{"无法删除临时路由 10.0.0.2/32 via 192.0.2.254，请手动删除", "10.0.0.1/32"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("标准错误中没有 %q: %s", want, stderr)
+		}
+	}
+	if len(viaRoutes) != 0 {
+		t.Errorf("删除后仍记录了 %v", viaRoutes)
+	}
+}
+
+func TestViaFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		ok   bool
+	}{
+		{[]string{"-via", "192.0.2.254", "127.0.0.1"}, true},
+		{[]string{"-via", "gw.example", "127.0.0.1"}, false},
+		{[]string{"-via", "2001:db8::1", "127.0.0.1"}, false},
+		{[]string{"-via", "192.0.2.254", "-drop-privs", "nobody", "127.0.0.1"}, false},
+		{[]string{"-via", "192.0.2.254", "-dns", "127.0.0.1"}, false},
+	}
+	for _, tt := range tests {
+		errs := argErrors(t, tt.args...)
+		if ok := !hasArgError(errs, "-via"); ok != (tt.ok && viaSupported) {
+			t.Errorf("%v: %q", tt.args, errs)
+		}
+	}
+}
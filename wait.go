@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+var waitFor time.Duration //-wait-for 等待目标可以访问，最多等待该时长
+
+// WaitForHost 每隔interval调用一次PingOnce，收到回复时返回nil，ctx结束时返回ctx.Err()
+// 等待期间每次失败在标准错误输出一个点；权限不足等无法重试的错误直接返回
+func WaitForHost(ctx context.Context, host string, interval time.Duration) error {
+	p := newPinger(host)
+	p.Quiet = true
+	defer p.Close() //各次请求复用同一连接
+	dots := 0
+	defer func() {
+		if dots > 0 {
+			fmt.Fprintln(os.Stderr)
+		}
+	}()
+	for {
+		if _, err := p.PingOnce(ctx); err == nil {
+			return nil
+		} else if isPermissionError(err) {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fmt.Fprint(os.Stderr, ".")
+		dots++
+
+		t := time.NewTimer(interval)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// 等待目标可以访问，返回退出码：可以访问时为0，超时或中断时为1
+func runWaitFor(host string) int {
+	ctx, cancel := context.WithTimeout(context.Background(), waitFor)
+	defer cancel()
+	s := stop //测试中会替换stop，goroutine只等待调用时的通道
+	go func() {
+		select {
+		case <-s:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	every := time.Duration(interval) * time.Millisecond
+	if every <= 0 {
+		every = time.Second
+	}
+	start := time.Now()
+	fmt.Fprintf(os.Stderr, "正在等待 %s 可以访问(最多 %s)：\n", host, waitFor)
+	if err := WaitForHost(ctx, host, every); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			fmt.Fprintf(os.Stderr, "%s 在 %s 内没有回复。\n", host, waitFor)
+		} else {
+			fmt.Fprintf(os.Stderr, "等待 %s 中断: %v\n", host, err)
+		}
+		return 1
+	}
+	fmt.Printf("%s 可以访问(等待了 %s)。\n", host, time.Since(start).Round(time.Millisecond))
+	return 0
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 收到回复时返回nil，没有回复时每次失败输出一个点
+func TestWaitForHost(t *testing.T) {
+	needRawSocket(t)
+	parseArgs(t, "-w", "10", "127.0.0.1")
+	var err error
+	_, stderr := captureOutput(t, func() { err = WaitForHost(context.Background(), "127.0.0.1", time.Millisecond) })
+	if err != nil || stderr != "" {
+		t.Errorf("err = %v，标准错误 %q", err, stderr)
+	}
+
+	parseArgs(t, "-w", "10", "203.0.113.1")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, stderr = captureOutput(t, func() { err = WaitForHost(ctx, "203.0.113.1", time.Millisecond) })
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.HasPrefix(stderr, "..") || strings.Trim(stderr, ".\n") != "" {
+		t.Errorf("err = %v，标准错误 %q", err, stderr)
+	}
+}
+
+// ctx被取消或到达截止时间时立即返回ctx.Err()，不必等到本次请求超时
+func TestWaitForHostContext(t *testing.T) {
+	needRawSocket(t)
+	tests := []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+		err  error
+	}{
+		{"取消", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+			return ctx, cancel
+		}, context.Canceled},
+		{"截止时间", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 50*time.Millisecond)
+		}, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseArgs(t, "-w", "1000", "203.0.113.1")
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			start := time.Now()
+			var err error
+			captureOutput(t, func() { err = WaitForHost(ctx, "203.0.113.1", 10*time.Millisecond) })
+			if !errors.Is(err, tt.err) {
+				t.Errorf("err = %v，期望 %v", err, tt.err)
+			}
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("用时 %s，没有在ctx结束时返回", elapsed)
+			}
+		})
+	}
+}
+
+// -wait-for 的退出码及输出
+func TestRunWaitFor(t *testing.T) {
+	needRawSocket(t)
+	parseArgs(t, "-wait-for", "1s", "-i", "0.01", "-w", "10", "127.0.0.1")
+	var code int
+	stdout, stderr := captureOutput(t, func() { code = runWaitFor("127.0.0.1") })
+	if code != 0 || !strings.HasPrefix(stdout, "127.0.0.1 可以访问") || !strings.Contains(stderr, "正在等待 127.0.0.1 可以访问(最多 1s)") {
+		t.Errorf("可以访问: 退出码 %d\n%s%s", code, stdout, stderr)
+	}
+
+	parseArgs(t, "-wait-for", "50ms", "-i", "0.01", "-w", "1000", "203.0.113.1")
+	stdout, stderr = captureOutput(t, func() { code = runWaitFor("203.0.113.1") })
+	if code != 1 || stdout != "" || !strings.Contains(stderr, "203.0.113.1 在 50ms 内没有回复。") {
+		t.Errorf("超时: 退出码 %d\n%s%s", code, stdout, stderr)
+	}
+}